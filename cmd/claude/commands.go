@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// setup is how much of the run a command needs before it starts. Each
+// level includes the ones before it.
+type setup int
+
+const (
+	// setupDir is claudeDir alone
+	setupDir setup = iota
+	// setupTransport adds the profile, --ci and the provider transport
+	setupTransport
+	// setupProject adds encryption, redaction and the project's config
+	setupProject
+	// setupSession adds the session lock, unless the command only reads
+	setupSession
+)
+
+// command is a mode of claude, selected by its flag or subcommand
+type command struct {
+	name     string
+	selected func(o *options) bool
+	setup    setup
+	reads    bool // only reads claudeDir: runs next to a session, unlocked
+	run      func(opts *options, claudeDir string) error
+}
+
+// commands in order of precedence: the first selected one runs, a turn if
+// none is. Those below setupSession write nothing claudeDir's lock guards,
+// or take it themselves.
+var commands = []command{
+	// Installs a unit or a crontab; the runs lock for themselves
+	{
+		name:     "cron",
+		selected: func(o *options) bool { return o.cron },
+		setup:    setupDir,
+		run: func(opts *options, claudeDir string) error {
+			job, err := cronJob(opts, claudeDir)
+			if err != nil {
+				return err
+			}
+			return claude.CronCommand(job, opts.cronFormat, opts.cronInstall)
+		},
+	},
+	// Importing creates claudeDir, so it runs before anything touches it
+	{
+		name:     "import-session",
+		selected: func(o *options) bool { return o.importSession != "" },
+		setup:    setupDir,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ImportSessionCommand(claudeDir, opts.importSession,
+				opts.importTrusted)
+		},
+	},
+	// The daemon configures and locks each project of the turns it serves
+	{
+		name:     "serve",
+		selected: func(o *options) bool { return o.serve },
+		setup:    setupTransport,
+		run:      serveCommand,
+	},
+	{
+		name:     "connect",
+		selected: func(o *options) bool { return o.connect },
+		setup:    setupTransport,
+		run:      connectCommand,
+	},
+	// The proxy saves no turns; it locks to update the stats
+	{
+		name:     "openai-proxy",
+		selected: func(o *options) bool { return o.openAIProxy != "" },
+		setup:    setupProject,
+		run:      proxyCommand,
+	},
+	// Editor filters only read claudeDir for the model
+	{
+		name:     "instruction",
+		selected: func(o *options) bool { return o.instruction != "" },
+		setup:    setupProject,
+		run: func(opts *options, claudeDir string) error {
+			return claude.FilterCommand(context.Background(), toClaudeOptions(opts),
+				claudeDir, apiURL, opts.instruction, os.Stdin, os.Stdout)
+		},
+	},
+	// Jobs take the lock in their own process
+	{
+		name:     "job",
+		selected: func(o *options) bool { return o.job },
+		setup:    setupProject,
+		run:      jobCommand,
+	},
+	{
+		name:     "export-session",
+		selected: func(o *options) bool { return o.exportSession != "" },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ExportSessionCommand(claudeDir, opts.exportSession)
+		},
+	},
+	{
+		name:     "fork",
+		selected: func(o *options) bool { return o.fork != "" },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ForkCommand(claudeDir, opts.fork, opts.forkDir)
+		},
+	},
+	{
+		name:     "index",
+		selected: func(o *options) bool { return o.index },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			workingDir, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting cwd: %w", err)
+			}
+			return claude.IndexCommand(workingDir, claudeDir, embedOptions(opts))
+		},
+	},
+	{
+		name:     "models-list",
+		selected: func(o *options) bool { return o.modelsList },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ListModelsCommand(claudeDir, opts.ollamaURL,
+				opts.output == claude.OutputJSON)
+		},
+	},
+	{
+		name:     "models-refresh",
+		selected: func(o *options) bool { return o.modelsRefresh },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return claude.RefreshModelsCommand(claudeDir, opts.ollamaURL)
+		},
+	},
+	{
+		name:     "execute",
+		selected: func(o *options) bool { return o.execute },
+		setup:    setupSession,
+		run:      executeCommand,
+	},
+	// Edit the last prompt and resend it in place of its turn
+	{
+		name:     "amend",
+		selected: func(o *options) bool { return o.amend },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			prompt, ts, err := claude.AmendPrompt(claudeDir)
+			if err != nil {
+				return err
+			}
+			opts.amendTurn = ts
+			return executeWithSavedInput(prompt, opts, claudeDir)
+		},
+	},
+	// --stage saves the message for --execute; --estimate only reads
+	{
+		name:     "stage",
+		selected: func(o *options) bool { return o.stage },
+		setup:    setupSession,
+		run:      estimateCommand,
+	},
+	{
+		name:     "estimate",
+		selected: func(o *options) bool { return o.estimate },
+		setup:    setupSession,
+		reads:    true,
+		run:      estimateCommand,
+	},
+	{
+		name:     "stats",
+		selected: func(o *options) bool { return o.showStats },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.StatsCommand(claudeDir, opts.output == claude.OutputJSON)
+		},
+	},
+	{
+		name:     "usage-export",
+		selected: func(o *options) bool { return o.usageExport },
+		setup:    setupSession,
+		reads:    true,
+		run:      usageExportCommand,
+	},
+	{
+		name:     "history",
+		selected: func(o *options) bool { return o.history },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.HistoryCommand(claudeDir, opts.tag)
+		},
+	},
+	{
+		name:     "show-turn",
+		selected: func(o *options) bool { return o.showTurn != "" },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ShowTurnCommand(claudeDir, opts.showTurn)
+		},
+	},
+	{
+		name:     "transcript",
+		selected: func(o *options) bool { return o.transcript != "" },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.TranscriptCommand(os.Stdout, claudeDir, opts.transcript)
+		},
+	},
+	{
+		name:     "compare",
+		selected: func(o *options) bool { return o.compare != "" },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			// --compare=TS1,TS2 or --compare TS1 TS2
+			turns := append(splitList(opts.compare), flag.Args()...)
+			if len(turns) != 2 {
+				return fmt.Errorf("--compare needs two turn timestamps, got %d", len(turns))
+			}
+			return claude.CompareCommand(os.Stdout, claudeDir, turns[0], turns[1])
+		},
+	},
+	{
+		name:     "show-system",
+		selected: func(o *options) bool { return o.showSystem },
+		setup:    setupSession,
+		reads:    true,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ShowSystemCommand(claudeDir, opts.systemPrompt, opts.systemFiles,
+				defaultSystemPrompt)
+		},
+	},
+	{
+		name:     "last",
+		selected: func(o *options) bool { return o.last },
+		setup:    setupSession,
+		reads:    true,
+		run:      lastCommand,
+	},
+	{
+		name:     "playbook",
+		selected: func(o *options) bool { return o.playbook != "" },
+		setup:    setupSession,
+		run:      playbookCommand,
+	},
+	{
+		name:     "recover",
+		selected: func(o *options) bool { return o.recover },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			action := claude.RecoverShow
+			switch {
+			case opts.finalize && opts.discard:
+				return fmt.Errorf("--finalize and --discard are mutually exclusive")
+			case opts.finalize:
+				action = claude.RecoverFinalize
+			case opts.discard:
+				action = claude.RecoverDiscard
+			}
+			return claude.RecoverCommand(claudeDir, action)
+		},
+	},
+	{
+		name:     "reset",
+		selected: func(o *options) bool { return o.reset },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return resetConversation(claudeDir)
+		},
+	},
+	{
+		name:     "replay",
+		selected: func(o *options) bool { return o.replay != noReplay },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return claude.ReplayResponse(claudeDir, toClaudeOptions(opts))
+		},
+	},
+	{
+		name:     "prune-old",
+		selected: func(o *options) bool { return o.pruneOld > 0 },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return storage.PruneResponses(claudeDir, opts.pruneOld, opts.isVerbose())
+		},
+	},
+	{
+		name:     "drop-last",
+		selected: func(o *options) bool { return o.dropLast > 0 },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			return claude.DropLastCommand(claudeDir, opts.dropLast, opts.force)
+		},
+	},
+	{
+		name:     "reindex",
+		selected: func(o *options) bool { return o.reindex },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			n, err := storage.RebuildIndex(claudeDir)
+			if err != nil {
+				return fmt.Errorf("rebuilding history index: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Indexed %d turns\n", n)
+			return nil
+		},
+	},
+	// Quarantining moves damaged files; checking only reads
+	{
+		name:     "fsck --quarantine",
+		selected: func(o *options) bool { return o.fsck && o.quarantine },
+		setup:    setupSession,
+		run:      fsckCommand,
+	},
+	{
+		name:     "fsck",
+		selected: func(o *options) bool { return o.fsck },
+		setup:    setupSession,
+		reads:    true,
+		run:      fsckCommand,
+	},
+	{
+		name:     "gc",
+		selected: func(o *options) bool { return o.gc },
+		setup:    setupSession,
+		run: func(opts *options, claudeDir string) error {
+			const day = 24 * time.Hour
+			return claude.GCCommand(claudeDir, storage.GCOptions{
+				MaxAge:        time.Duration(opts.gcMaxAge) * day,
+				MaxSize:       int64(opts.gcMaxSize) << 20,
+				CompressAfter: time.Duration(opts.gcCompressAfter) * day,
+			})
+		},
+	},
+}
+
+// turnCommand is the command of a run that selects none: a turn
+var turnCommand = command{
+	name:  "turn",
+	setup: setupSession,
+	run:   turn,
+}
+
+// selectCommand returns the command the flags select
+func selectCommand(opts *options) *command {
+	for i := range commands {
+		if commands[i].selected(opts) {
+			return &commands[i]
+		}
+	}
+	return &turnCommand
+}
+
+func serveCommand(opts *options, claudeDir string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	base := toClaudeOptions(opts)
+	base.SemanticSearch = !opts.noSemanticSearch
+	return claude.Serve(ctx, opts.socket, base, apiURL, defaultSystemPrompt)
+}
+
+func connectCommand(opts *options, claudeDir string) error {
+	if opts.sessions {
+		return claude.SessionsCommand(opts.socket)
+	}
+	userMsg, err := readPrompt(opts)
+	if err != nil {
+		return err
+	}
+	return claude.ConnectCommand(opts.socket, connectRequest(opts, claudeDir, userMsg))
+}
+
+func proxyCommand(opts *options, claudeDir string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return claude.ServeOpenAI(ctx, opts.openAIProxy, toClaudeOptions(opts), claudeDir, apiURL)
+}
+
+// executeCommand resends the last user message of the conversation, or the
+// one --stage saved
+func executeCommand(opts *options, claudeDir string) error {
+	messages, err := storage.LoadConversationHistory(claudeDir)
+	if err != nil {
+		return fmt.Errorf("loading conversation: %w", err)
+	}
+
+	var userMsg string
+
+	// Try to get last user message from completed conversation
+	if len(messages) > 0 {
+		userMsg, err = claude.GetLastUserMessage(messages)
+		if err != nil {
+			return fmt.Errorf("no user message in conversation")
+		}
+	} else {
+		// No complete pairs - check for unpaired request (from --stage)
+		entries, err := os.ReadDir(claudeDir)
+		if err != nil {
+			return fmt.Errorf("no conversation history")
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "request_") {
+				reqPath := filepath.Join(claudeDir, entry.Name())
+				req, err := storage.LoadRequest(reqPath)
+				if err != nil {
+					continue
+				}
+				userMsg, _ = claude.GetLastUserMessage(req.Messages)
+				break
+			}
+		}
+		if userMsg == "" {
+			return fmt.Errorf("no message to execute")
+		}
+	}
+
+	// Override max-cost if provided
+	if opts.maxCostFlag > 0 {
+		opts.maxCost = opts.maxCostFlag
+	}
+
+	// Normal execution flow with last user message
+	return executeWithSavedInput(userMsg, opts, claudeDir)
+}
+
+// estimateCommand handles --estimate and --stage. --estimate only reads
+// .claude; --stage also saves the message for --execute.
+func estimateCommand(opts *options, claudeDir string) error {
+	userMsg, err := readPrompt(opts)
+	if err != nil {
+		return err
+	}
+	opts.turnModel, userMsg = claude.ParseDirective(userMsg)
+
+	// Load conversation history
+	messages, _ := storage.LoadConversationHistory(claudeDir)
+
+	// Get model for pricing
+	configPath := filepath.Join(claudeDir, "config.json")
+	cfg := storage.LoadOrCreateConfig(configPath)
+	model, err := claude.ResolveModelAlias(claude.SelectModel(opts.model, cfg.Model), claudeDir)
+	if err != nil {
+		return err
+	}
+	copts := toClaudeOptions(opts)
+	turnModel := model
+	if opts.turnModel != "" {
+		if turnModel, err = claude.ResolveDirective(opts.turnModel, copts, cfg.Model, claudeDir); err != nil {
+			return err
+		}
+	}
+
+	// Estimate and display: every call also carries the system prompt
+	// and the tool schemas
+	sysBlocks, err := claude.ComposeSystemPrompt(claude.SelectSystemPrompt(
+		opts.systemPrompt, cfg.SystemPrompt, defaultSystemPrompt), opts.systemFiles)
+	if err != nil {
+		return err
+	}
+	sysPrompt := llm.JoinSystem(sysBlocks)
+	estimate := claude.EstimateCost(userMsg, sysPrompt, messages,
+		claude.GetTools(copts), copts.MaxIterations, turnModel)
+	claude.DisplayEstimate(estimate, opts.stage)
+	if !opts.stage {
+		return nil
+	}
+
+	// Save this message to conversation so --execute can use it
+	messages = append(messages, claude.MessageContent{
+		Role: "user",
+		Content: []claude.ContentBlock{{
+			Type: "text",
+			Text: userMsg,
+		}},
+	})
+	timestamp := storage.CurrentTimestamp()
+	if err := storage.SaveRequest(claudeDir, timestamp, messages); err != nil {
+		return fmt.Errorf("saving request: %w", err)
+	}
+
+	// Update config
+	cfg.Model = model
+	if cfg.FirstRun == "" {
+		cfg.FirstRun = storage.CurrentTimestamp()
+	}
+	cfg.LastRun = storage.CurrentTimestamp()
+	configPath = filepath.Join(claudeDir, "config.json")
+	if err := storage.SaveJSON(configPath, cfg); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	return nil
+}
+
+func usageExportCommand(opts *options, claudeDir string) error {
+	w := os.Stdout
+	if opts.outputFile != "" {
+		f, err := storage.Create(opts.outputFile)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return claude.UsageExportCommand(w, claudeDir, opts.usageFrom, opts.usageTo, opts.output)
+}
+
+func lastCommand(opts *options, claudeDir string) error {
+	resp, err := claude.LastResponse(claudeDir)
+	if err != nil {
+		return err
+	}
+	respBody, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("marshaling response: %w", err)
+	}
+	return writeOutput(opts.outputFile, opts.output == claude.OutputJSON,
+		claude.ExtractResponse(resp), respBody)
+}
+
+func playbookCommand(opts *options, claudeDir string) error {
+	if opts.githubPR > 0 {
+		return fmt.Errorf("--github-pr reports single turns, not playbooks")
+	}
+	pb, err := claude.LoadPlaybook(opts.playbook)
+	if err != nil {
+		return err
+	}
+	if _, err := claude.WebhookFormat(opts.webhookFormat, opts.postWebhook); err != nil {
+		return err
+	}
+	start := time.Now()
+	// The answer of the last step is what --post-webhook posts
+	var answer string
+	output := func(file string, jsonOutput bool, text string, body []byte) error {
+		answer = text
+		return writeOutput(file, jsonOutput, text, body)
+	}
+	err = claude.RunPlaybook(pb, toClaudeOptions(opts), claudeDir,
+		apiURL, defaultSystemPrompt, output)
+	notifyDone(opts, start, nil, err)
+	if opts.postWebhook != "" {
+		summary := &claude.RunSummary{}
+		if err != nil {
+			summary.ExitStatus, summary.Error = 1, err.Error()
+		}
+		if werr := postWebhook(opts, summary, answer); werr != nil {
+			if err == nil {
+				return werr
+			}
+			slog.Warn("--post-webhook", "err", werr)
+		}
+	}
+	return err
+}
+
+func fsckCommand(opts *options, claudeDir string) error {
+	return claude.FsckCommand(claudeDir, opts.quarantine)
+}
+
+// turn runs the prompt read from -c or stdin: one turn, a --plan or a
+// --compare-models run
+func turn(opts *options, claudeDir string) error {
+	userMsg, err := readPrompt(opts)
+	if err != nil {
+		return err
+	}
+	if opts.compareModels != "" {
+		return claude.CompareModelsCommand(os.Stdout, toClaudeOptions(opts), claudeDir, apiURL,
+			defaultSystemPrompt, userMsg, splitList(opts.compareModels), opts.compareDir)
+	}
+	opts.turnModel, userMsg = claude.ParseDirective(userMsg)
+	if opts.plan {
+		return isolated(opts, userMsg, func() error {
+			start := time.Now()
+			err := claude.RunPlan(toClaudeOptions(opts), claudeDir, apiURL,
+				defaultSystemPrompt, userMsg, writeOutput)
+			notifyDone(opts, start, nil, err)
+			return err
+		})
+	}
+	return executeWithSavedInput(userMsg, opts, claudeDir)
+}
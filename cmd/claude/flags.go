@@ -0,0 +1,952 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/logging"
+)
+
+// flags.go - The flag table
+//
+// Every flag is declared once in flagTable, with its category, a one line
+// usage and optionally a longer description. The table registers the
+// flags and generates -h, --help-full and --man, so the help can't drift
+// from the flags.
+
+// Flag categories, in the order help shows them
+const (
+	catModes       = "Modes"
+	catRouting     = "Smart Routing"
+	catCost        = "Cost Estimation"
+	catPermissions = "Permissions"
+	catConfig      = "Configuration"
+	catNetwork     = "Network"
+)
+
+var flagCategories = []string{catModes, catRouting, catCost, catPermissions, catConfig, catNetwork}
+
+// flagDef declares a flag
+type flagDef struct {
+	name     string
+	category string
+	// value is a pointer to the options field (*bool, *string, *int,
+	// *float64 or *time.Duration) or a flag.Value
+	value interface{}
+	def   interface{} // default of pointer values, nil for the zero value
+	arg   string      // placeholder of the value in help, e.g. FILE
+	usage string      // one line, for -h
+	long  string      // more detail for --help-full and --man
+}
+
+// usageExamples are the examples of -h and --man
+var usageExamples = []struct{ comment, commands string }{
+	{"Dry-run (shows what would happen)", `echo "add error handling to users.go" | claude`},
+	{"Execute with write permission", `echo "add tests" | claude --tool=write`},
+	{"Follow up without a pipe, show the previous answer again",
+		"claude -c \"now add a test for the error case\"\nclaude --last"},
+	{"Replay last run and execute everything",
+		"claude --replay --tool=all\nclaude --replay=20260104_153022 --tool=all\n" +
+			`claude --replay="" --only=write_file --tool=write`},
+	{"Show statistics", "claude --stats"},
+	{"Browse conversation history", "claude --history\nclaude --show-turn=20260104_153022"},
+	{"Route Claude through AWS Bedrock or Google Vertex AI", `echo "review main.go" | claude --provider=bedrock`},
+	{"Use a saved profile from config.json", `echo "refactor this" | claude --profile=local-only`},
+	{"Embed the project for semantic_search", "claude index --embed-model=nomic-embed-text"},
+	{"Save a prompt with a parameter and run it",
+		"claude prompt save review -c \"review {{file}} for bugs\"\nclaude prompt run review file=main.go"},
+	{"Use local Ollama with fallback to Claude", `echo "explain this code" | claude --prefer-local --allow-fallback`},
+}
+
+// flagTable declares the flags of opts
+func flagTable(opts *options) []flagDef {
+	return []flagDef{
+		// Modes
+		{
+			name: "c", category: catModes, value: &opts.prompt, arg: "PROMPT",
+			usage: "prompt to send, continuing the conversation (piped stdin is appended to it)",
+			long: "Without -c the prompt is read from stdin. With it, piped stdin is appended to " +
+				"the prompt after a blank line, so `git diff | claude -c \"review this\"` sends both.",
+		},
+		{
+			name: "continue", category: catModes, value: &opts.prompt, arg: "PROMPT",
+			usage: "same as -c",
+		},
+		{
+			name: "help-full", category: catModes, value: &opts.helpFull,
+			usage: "show every flag with its full description",
+		},
+		{
+			name: "man", category: catModes, value: &opts.man,
+			usage: "print a man page (roff), e.g. claude --man | man -l -",
+		},
+		{
+			name: "models-list", category: catModes, value: &opts.modelsList,
+			usage: "list available Claude and Ollama models (creates cache if missing)",
+			long: "Shows size, quantization, context window and tool and vision support of each " +
+				"model; --output=json prints the list for scripts.",
+		},
+		{
+			name: "models-refresh", category: catModes, value: &opts.modelsRefresh,
+			usage: "refresh models cache from Claude API and Ollama",
+		},
+		{
+			name: "reset", category: catModes, value: &opts.reset,
+			usage: "reset conversation (delete .claude/ directory)",
+		},
+		{
+			name: "stats", category: catModes, value: &opts.showStats,
+			usage: "show usage per model, provider and day (--output=json for dashboards)",
+		},
+		{
+			name: "usage-export", category: catModes, value: &opts.usageExport,
+			usage: "export tokens and cost per day, user and model as CSV (--output=json for JSON)",
+			long: "Covers the saved turns between --from and --to, for a team cost dashboard. " +
+				"The user of a turn is $" + claude.EnvUser + ", else \"user\" in config.json, " +
+				"else the login name, recorded when the turn ran.",
+		},
+		{
+			name: "from", category: catModes, value: &opts.usageFrom, arg: "DATE",
+			usage: "first day of --usage-export, e.g. 2006-01-02 (default: the first turn)",
+		},
+		{
+			name: "to", category: catModes, value: &opts.usageTo, arg: "DATE",
+			usage: "last day of --usage-export, inclusive (default: today)",
+		},
+		{
+			name: "history", category: catModes, value: &opts.history,
+			usage: "list saved conversation turns with prompts, models, tokens and costs",
+			long:  "Forks are shown under the turn they continue from.",
+		},
+		{
+			name: "tag", category: catModes, value: &opts.tag, arg: "NAMES",
+			usage: "comma-separated labels for this turn; with --history: only list turns with this tag",
+			long:  "Tags are lowercase letters, digits, '.', '_' and '-'.",
+		},
+		{
+			name: "show-turn", category: catModes, value: &opts.showTurn, arg: "TIMESTAMP",
+			usage: "show a saved turn in full (timestamp like 20260104_153022)",
+		},
+		{
+			name: "transcript", category: catModes, value: &opts.transcript, arg: "TIMESTAMP",
+			usage: "print the transcript of a saved turn as JSON: the prompt, assistant messages and tool results in order",
+		},
+		{
+			name: "compare", category: catModes, value: &opts.compare, arg: "TS1,TS2",
+			usage: "diff the answers and proposed files of two saved turns",
+		},
+		{
+			name: "compare-models", category: catModes, value: &opts.compareModels, arg: "MODELS",
+			usage: "send the prompt to these comma-separated models at once and show the answers side by side",
+			long: "The calls have no tools and aren't saved as turns; the answers, tokens, cost and " +
+				"latency of each model are recorded in .claude/compare/.",
+		},
+		{
+			name: "compare-dir", category: catModes, value: &opts.compareDir, arg: "DIR",
+			usage: "with --compare-models: write each answer to DIR/MODEL.md instead",
+		},
+		{
+			name: "last", category: catModes, value: &opts.last,
+			usage: "print the previous answer again",
+			long:  "Honors --output and --output-file.",
+		},
+		{
+			name: "replay", category: catModes, value: &opts.replay, def: noReplay, arg: "TIMESTAMP",
+			usage: "replay response (empty=latest, or timestamp like 20260104_153022)",
+			long: "Re-executes the tool calls of a saved response with the permissions of --tool, " +
+				"without calling the model. --only, --tool-ids and --interactive narrow what runs.",
+		},
+		{
+			name: "only", category: catModes, value: &opts.replayOnly, arg: "TOOLS",
+			usage: "with --replay: only re-execute these tools (comma-separated, e.g. write_file)",
+		},
+		{
+			name: "tool-ids", category: catModes, value: &opts.replayToolIDs, arg: "IDS",
+			usage: "with --replay: only re-execute these tool_use IDs (comma-separated)",
+		},
+		{
+			name: "interactive", category: catModes, value: &opts.replayInteractive,
+			usage: "with --replay: ask before re-executing each tool",
+		},
+		{
+			name: "playbook", category: catModes, value: &opts.playbook, arg: "FILE",
+			usage: "run the prompts of a YAML/JSON playbook in order, stopping at the first failing step",
+			long: "Each step is a turn with its own prompt and optionally its own model, tool " +
+				"permissions and budget.",
+		},
+		{
+			name: "recover", category: catModes, value: &opts.recover,
+			usage: "show turns interrupted by a crash",
+			long: "Turns are journaled while they run; --finalize saves what an interrupted turn " +
+				"got done to the history and --discard drops it.",
+		},
+		{
+			name: "finalize", category: catModes, value: &opts.finalize,
+			usage: "with --recover: save interrupted turns to the history",
+		},
+		{
+			name: "discard", category: catModes, value: &opts.discard,
+			usage: "with --recover: drop interrupted turns (changed files stay changed)",
+		},
+		{
+			name: "prune-old", category: catModes, value: &opts.pruneOld, arg: "N",
+			usage: "keep only last N request/response pairs, delete older",
+		},
+		{
+			name: "drop-last", category: catModes, value: (*countFlag)(&opts.dropLast), arg: "N",
+			usage: "delete the last N turns (default 1) from the history, after asking",
+			long:  "The backups of files the dropped turns changed are kept.",
+		},
+		{
+			name: "force", category: catModes, value: &opts.force,
+			usage: "with --drop-last: don't ask; with --replay: overwrite files changed since the original run",
+		},
+		{
+			name: "reindex", category: catModes, value: &opts.reindex,
+			usage: "rebuild the history index from the request/response files",
+		},
+		{
+			name: "fsck", category: catModes, value: &opts.fsck,
+			usage: "check saved turns for corruption and changes since they were saved",
+		},
+		{
+			name: "quarantine", category: catModes, value: &opts.quarantine,
+			usage: "with --fsck: move corrupt turns to .claude/quarantine/",
+		},
+		{
+			name: "gc", category: catModes, value: &opts.gc,
+			usage: "compress old turns and delete turns over the --gc-max-* limits",
+		},
+		{
+			name: "gc-max-age", category: catModes, value: &opts.gcMaxAge, arg: "DAYS",
+			usage: "with --gc: delete turns older than this many days (0 = no limit)",
+		},
+		{
+			name: "gc-max-size", category: catModes, value: &opts.gcMaxSize, arg: "MB",
+			usage: "with --gc: delete the oldest turns until .claude is at most this many MB (0 = no limit)",
+		},
+		{
+			name: "gc-compress-after", category: catModes, value: &opts.gcCompressAfter, def: 7, arg: "DAYS",
+			usage: "with --gc: gzip turns older than this many days (0 = never)",
+		},
+		{
+			name: "plan", category: catModes, value: &opts.plan,
+			usage: "ask for a plan of tool calls and edits first, run it once approved",
+			long: "The model first answers with a plan instead of acting. After you approve it " +
+				"the plan is carried out in the same turn with the permissions of --tool.",
+		},
+		{
+			name: "plan-approve", category: catModes, value: &opts.planApprove,
+			usage: "run the --plan plan without asking",
+		},
+		{
+			name: "steer", category: catModes, value: &opts.steer,
+			usage: "steer the run: a line typed on the terminal goes to the model with the next call",
+			long: "Type a message and press Enter while the turn runs, e.g. \"stop editing foo.go, " +
+				"focus on the tests\": it is sent after the results of the tool calls under way, " +
+				"or as a follow-up when the model already answered.",
+		},
+		{
+			name: "watch", category: catModes, value: &opts.watch,
+			usage: "show a live panel of the run: iteration, last tool call, tokens, cost and elapsed time",
+		},
+		{
+			name: "embed-provider", category: catModes, value: &opts.embedProvider,
+			def: claude.EmbedProviderOllama, arg: "NAME",
+			usage: "with claude index: embeddings API, ollama or openai (OpenAI-compatible, key in EMBEDDINGS_API_KEY)",
+		},
+		{
+			name: "embed-model", category: catModes, value: &opts.embedModel,
+			def: claude.DefaultEmbedModel, arg: "MODEL",
+			usage: "with claude index: embeddings model, e.g. nomic-embed-text, text-embedding-3-small, voyage-code-3",
+		},
+		{
+			name: "embed-url", category: catModes, value: &opts.embedURL, arg: "URL",
+			usage: fmt.Sprintf("with claude index: embeddings API URL (default: --ollama-url, or %s for openai)",
+				llm.OpenAIBaseURL),
+		},
+		{
+			name: "schedule", category: catModes, value: &opts.cronSchedule,
+			def: claude.DefaultCronSchedule, arg: "SPEC",
+			usage: "with claude cron: when to run, as 5 crontab fields or @hourly, @daily, @weekly, @monthly",
+		},
+		{
+			name: "cron-format", category: catModes, value: &opts.cronFormat,
+			def: claude.CronCrontab, arg: "FORMAT",
+			usage: "with claude cron: crontab or systemd (a user service and timer)",
+		},
+		{
+			name: "install", category: catModes, value: &opts.cronInstall,
+			usage: "with claude cron: add the entry to your crontab or write the systemd units, instead of printing them",
+		},
+		{
+			name: "serve", category: catModes, value: &opts.serve,
+			usage: "run as a daemon answering turns on a Unix socket (see --socket)",
+			long: "Turns run one at a time, each in the .claude directory of its project with " +
+				"that project's key, policy and hooks. Tool plugins aren't loaded.",
+		},
+		{
+			name: "connect", category: catModes, value: &opts.connect,
+			usage: "send the prompt to the --serve daemon instead of running it in this process",
+			long: "The prompt, the working directory and the --model, --tool, --max-cost and " +
+				"--max-iterations given on the command line go to the daemon; flags that aren't " +
+				"given take the daemon's values.",
+		},
+		{
+			name: "sessions", category: catModes, value: &opts.sessions,
+			usage: "with --connect: list the sessions the daemon served",
+		},
+		{
+			name: "socket", category: catModes, value: &opts.socket, def: claude.DefaultSocketPath(), arg: "PATH",
+			usage: "Unix socket of --serve and --connect",
+		},
+		{
+			name: "openai-proxy", category: catModes, value: &opts.openAIProxy, arg: "ADDR",
+			usage: fmt.Sprintf("serve the OpenAI chat API on this loopback address (e.g. %s) for editors and tools; "+
+				"--max-cost bounds its Claude spend", claude.DefaultOpenAIProxyAddr),
+			long: "The model of a request picks the provider like --model; auto lets the router " +
+				"choose between the --model Ollama model and Claude. No turns are saved, but every " +
+				"call is recorded in the audit log and the provider stats.",
+		},
+		{
+			name: "instruction", category: catModes, value: &opts.instruction, arg: "TEXT",
+			usage: "editor filter: apply this instruction to the code on stdin and print only the result " +
+				"(e.g. :'<,'>!claude --instruction=\"add error handling\")",
+			long: "Prose and markdown fences in the answer are stripped. If the call fails the " +
+				"region is printed unchanged, so the editor keeps the selection.",
+		},
+		{
+			name: "export-session", category: catModes, value: &opts.exportSession, arg: "FILE",
+			usage: "bundle the .claude directory into a .tar.gz file with checksums",
+		},
+		{
+			name: "import-session", category: catModes, value: &opts.importSession, arg: "FILE",
+			usage: "verify and unpack a --export-session bundle as the .claude directory",
+		},
+		{
+			name: "import-trusted", category: catModes, value: &opts.importTrusted,
+			usage: "with --import-session: keep the bundle's hooks, formatters, tool plugins, validators and workspace roots",
+		},
+		{
+			name: "fork", category: catModes, value: &opts.fork, arg: "TIMESTAMP",
+			usage: "start a new session in --fork-dir with the history up to and including this turn TIMESTAMP",
+			long:  "The original session is left as it is; both record the fork for --history.",
+		},
+		{
+			name: "fork-dir", category: catModes, value: &opts.forkDir, arg: "DIR",
+			usage: "with --fork: project directory of the new session",
+		},
+		{
+			name: "show-system", category: catModes, value: &opts.showSystem,
+			usage: "print the system prompt the next turn would use and where it comes from " +
+				"(--system, CLAUDE_SYSTEM_PROMPT, config.json or the default)",
+		},
+
+		// Smart Routing
+		{
+			name: "prefer-local", category: catRouting, value: &opts.preferLocal, def: true,
+			usage: "prefer local Ollama models when possible (default: true)",
+		},
+		{
+			name: "allow-fallback", category: catRouting, value: &opts.allowFallback, def: true,
+			usage: "allow fallback to Claude if Ollama fails (default: true)",
+		},
+		{
+			name: "fallback-chain", category: catRouting, value: &opts.fallbackChain, arg: "MODELS",
+			usage: "comma-separated models tried in order when a call fails, e.g. haiku,sonnet",
+			long: "Each model takes over for the rest of the session. A chain naming the " +
+				"primary model continues after it. Without a chain Ollama falls back to " +
+				claude.DefaultModel + ". The --fallback flags not given come from the " +
+				"fallback section of .claude/config.json.",
+		},
+		{
+			name: "fallback-on", category: catRouting, value: &opts.fallbackOn, arg: "CLASSES",
+			usage: "error classes that retry and fall back: timeout, connect, 5xx, 429, tools, other (default: all)",
+		},
+		{
+			name: "fallback-retries", category: catRouting, value: &opts.fallbackRetries, arg: "N",
+			usage: "retries of a failing model, with backoff, before falling back",
+		},
+		{
+			name: "max-claude-ratio", category: catRouting, value: &opts.maxClaudeRatio, def: 0.10, arg: "RATIO",
+			usage: "maximum ratio of Claude vs total requests (0.0-1.0, default: 0.10 = 10%)",
+		},
+		{
+			name: "explain-routing", category: catRouting, value: &opts.explainRouting,
+			usage: "show the router's analysis of the prompt: complexity, features, quota and the rule that fired",
+			long: "The explanation goes to stderr and into the run summary (--summary-json, " +
+				"--output=json-full). A turn shows where the router would send its prompt " +
+				"and still runs on --model; --openai-proxy shows it for every request to " +
+				"model auto.",
+		},
+		{
+			name: "provider", category: catRouting, value: &opts.provider, def: claude.ProviderClaude, arg: "NAME",
+			usage: "backend for Claude models: claude (Anthropic API), vertex, bedrock",
+			long: "vertex and bedrock authenticate with the cloud's own credentials instead of " +
+				"ANTHROPIC_API_KEY.",
+		},
+
+		// Cost Estimation
+		{
+			name: "estimate", category: catCost, value: &opts.estimate,
+			usage: "estimate cost without executing (shows cost for piped input)",
+			long: "The estimate counts the system prompt, tool schemas and history every call " +
+				"resends, and gives a range from one iteration to --max-iterations.",
+		},
+		{
+			name: "stage", category: catCost, value: &opts.stage,
+			usage: "estimate cost and save the message for a later --execute",
+		},
+		{
+			name: "execute", category: catCost, value: &opts.execute,
+			usage: "re-execute last user message from conversation",
+		},
+		{
+			name: "amend", category: catCost, value: &opts.amend,
+			usage: "edit the last prompt in $EDITOR and resend it, replacing its turn in the history",
+			long:  "The old turn is dropped only once the new one is saved.",
+		},
+		{
+			name: "max-cost-override", category: catCost, value: &opts.maxCostFlag, arg: "DOLLARS",
+			usage: "override max-cost for this run (use with --execute)",
+		},
+
+		// Permissions
+		{
+			name: "tool", category: catPermissions, value: &opts.tool, def: claude.DefaultTool, arg: "PERMS",
+			usage: "tool permissions: \"\" (dry-run), none, read, write, command, all, or comma-separated",
+			long: "In dry-run mode the model may call tools but writes and commands are only " +
+				"shown. read allows read_file, write file changes, command bash commands.",
+		},
+		{
+			name: "force-tool", category: catPermissions, value: &opts.forceTool, arg: "CHOICE",
+			usage: "tool_choice for the first call of the turn: auto, any, none or a tool name (e.g. write_file)",
+		},
+		{
+			name: "sequential-tools", category: catPermissions, value: &opts.sequentialTools,
+			usage: "allow one tool call per response so tool side effects happen strictly in order",
+		},
+		{
+			name: "tool-budget", category: catPermissions, value: &opts.toolBudget, arg: "SPEC",
+			usage: "cap tool calls per turn, e.g. read_file=20,bash_command=5; * caps all tools together",
+			long: "Calls over a budget aren't run: the model gets a tool error telling it to " +
+				"continue with what it has. Stops local models that loop on the same tool.",
+		},
+		{
+			name: "read-only", category: catPermissions, value: &opts.readOnly,
+			usage: "write nothing at all: no .claude files or config updates, tools limited to --tool=read",
+			long: "For checkouts that must stay clean. The turn still sees the saved conversation " +
+				"but isn't added to it, and any write attempt of the storage layer fails.",
+		},
+		{
+			name: "isolate", category: catPermissions, value: &opts.isolate,
+			usage: "work on a new git branch in a temporary worktree, leaving the working tree alone; " +
+				"with command tools and gh: open a pull request",
+		},
+		{
+			name: "pending-patch", category: catPermissions, value: &opts.pendingPatch,
+			usage: "in dry-run mode: also save the proposed changes as .claude/pending_<timestamp>.patch for git apply",
+		},
+		{
+			name: "no-semantic-search", category: catPermissions, value: &opts.noSemanticSearch,
+			usage: "don't offer the semantic_search tool even when .claude/index exists",
+		},
+		{
+			name: "rag", category: catPermissions, value: &opts.rag, arg: "K",
+			usage: "add the N chunks of the claude index closest to the prompt to the message (0 = off)",
+		},
+		{
+			name: "rag-tokens", category: catPermissions, value: &opts.ragTokens, def: claude.DefaultRAGTokens, arg: "N",
+			usage: "with --rag: token budget of the added chunks",
+		},
+
+		// Configuration
+		{
+			name: "model", category: catConfig, value: &opts.model, arg: "MODEL",
+			usage: fmt.Sprintf("model to use, or alias sonnet, haiku, opus, latest (default: %s)",
+				claude.DefaultModel),
+			long: "Claude models start with claude-; fake:SCENARIO plays a scripted scenario " +
+				"(hello, edit, .claude/fakes/SCENARIO.json or a file) offline; other names are " +
+				"Ollama models. The aliases " +
+				"pick the newest model of the family in the models cache. The model is remembered " +
+				"in .claude/config.json for the next turns.",
+		},
+		{
+			name: "max-tokens", category: catConfig, value: &opts.maxTokens, arg: "N",
+			usage: "maximum output tokens per API call (0 = model's limit, as much as --timeout allows)",
+			long: "Answers cut off at the limit are continued automatically, up to 3 times a turn; a " +
+				"response cut off inside a tool call is requested again with twice the limit.",
+		},
+		{
+			name: "max-cost", category: catConfig, value: &opts.maxCost, def: claude.DefaultMaxCost, arg: "DOLLARS",
+			usage: "maximum cost in dollars per conversation (0 = unlimited)",
+		},
+		{
+			name: "spend-alerts", category: catConfig, value: &opts.spendAlerts, arg: "PERCENTS",
+			usage: "warn when spend crosses these percentages of --max-cost and --daily-budget, or off (default: 50,80)",
+			long: "Alerts show on stderr and in the footer, go into the run summary and run the " +
+				"budget hooks of .claude/hooks.json. The default comes from the budget section " +
+				"of .claude/config.json when set.",
+		},
+		{
+			name: "daily-budget", category: catConfig, value: &opts.dailyBudget, arg: "DOLLARS",
+			usage: "soft limit on the project's spend per day: alerts, never stops a run (0 = none)",
+		},
+		{
+			name: "max-iterations", category: catConfig, value: &opts.maxIterations,
+			def: claude.DefaultMaxIterations, arg: "N",
+			usage: "maximum tool loop iterations (0 = unlimited)",
+		},
+		{
+			name: "truncate", category: catConfig, value: &opts.truncate, arg: "N",
+			usage: "keep only last N messages in conversation (0 = keep all)",
+			long: "Whole turns are dropped from the oldest end so tool calls keep their results; " +
+				"the saved history is kept.",
+		},
+		{
+			name: "ollama-url", category: catConfig, value: &opts.ollamaURL, def: claude.DefaultOllamaURL, arg: "URL",
+			usage: "Ollama API URL",
+		},
+		{
+			name: "ollama-auto-pull", category: catConfig, value: &opts.ollamaAutoPull,
+			usage: "download the Ollama model with /api/pull when it isn't installed, then retry",
+		},
+		{
+			name: "profile", category: catConfig, value: &opts.profile, arg: "NAME",
+			usage: "apply a named settings profile from .claude/config.json (flags override it)",
+		},
+		{
+			name: "image", category: catConfig, value: &opts.images, arg: "FILES",
+			usage: "comma-separated image files (PNG, JPEG, GIF, WebP) to attach to the prompt",
+			long:  "Up to 5 MB each; needs Claude or a vision Ollama model.",
+		},
+		{
+			name: "system", category: catConfig, value: &opts.systemPrompt, arg: "PROMPT",
+			usage: "custom system prompt",
+		},
+		{
+			name: "system-file", category: catConfig, value: &opts.systemFiles, arg: "FILE",
+			usage: "append the content of FILE to the system prompt as a block of its own; repeatable",
+			long:  "Files come after the prompt in the order given; Claude gets one system block per file.",
+		},
+		{
+			name: "prefill", category: catConfig, value: &opts.prefill, arg: "TEXT",
+			usage: "start the answer with TEXT, e.g. '```json' to get JSON",
+			long:  "The model goes on from TEXT and the answer shown and saved begins with it. Trailing whitespace is dropped.",
+		},
+		{
+			name: "project-context", category: catConfig, value: &opts.projectContext,
+			usage: fmt.Sprintf("add the project file tree (honoring .gitignore, up to %d files) to the system prompt",
+				claude.MaxTreeFiles),
+		},
+		{
+			name: "env-context", category: catConfig, value: &opts.envContext,
+			usage: "add the date, OS, working directory and git branch to the system prompt",
+		},
+		{
+			name: "verbosity", category: catConfig, value: &opts.verbosity, def: claude.DefaultVerbosity, arg: "LEVEL",
+			usage: "output verbosity: silent, normal, verbose, debug",
+			long: "Also the level of the diagnostic log: error, warn, info and debug. Except with " +
+				"silent, a status line is shown on stderr while waiting for the model.",
+		},
+		{
+			name: "verify", category: catConfig, value: &opts.verify, arg: "CMD",
+			usage: "command run after files are written, e.g. \"go build ./... && go test ./...\"; " +
+				"failures are fed back to the model",
+		},
+		{
+			name: "validate", category: catConfig, value: &opts.validate,
+			usage: "run written files through the validator of their type (gofmt and go vet, eslint, jq); " +
+				"failures are fed back to the model",
+			long: "Set or turn off validators by extension with \"validators\" in .claude/config.json, " +
+				"e.g. {\".py\": \"ruff check {file}\", \".ts\": \"\"}.",
+		},
+		{
+			name: "max-fix-attempts", category: catConfig, value: &opts.maxFixAttempts,
+			def: claude.DefaultMaxFixAttempts, arg: "N",
+			usage: "feed --validate failures back at most N times a turn",
+		},
+		{
+			name: "best-of", category: catConfig, value: &opts.bestOf, arg: "N",
+			usage: "sample the first response of the turn N times and keep the best (costs up to N times as much)",
+			long: "Every candidate is kept in the turn's metadata (--show-turn) and counts toward " +
+				"--max-cost. Needs a temperature above 0, so not with --ci.",
+		},
+		{
+			name: "judge", category: catConfig, value: &opts.judge,
+			def: claude.JudgeConsensus, arg: "MODEL",
+			usage: "with --best-of: the model that picks the best candidate, or consensus for the one most like the others",
+		},
+		{
+			name: "format-writes", category: catConfig, value: &opts.formatWrites,
+			usage: "format written files first: goimports or gofmt for Go, others as policy.json \"format\" sets",
+			long: "Formatters read the content on stdin and write it to stdout, e.g. " +
+				"{\"format\": {\".ts\": \"prettier --stdin-filepath {file}\"}}.",
+		},
+		{
+			name: "compress-results", category: catConfig, value: &opts.compressResults, arg: "N",
+			usage: "compress tool results over N tokens before adding them to the conversation (0 = off)",
+			long:  "The full result stays available to the model through get_tool_result.",
+		},
+		{
+			name: "compress-model", category: catConfig, value: &opts.compressModel, arg: "MODEL",
+			usage: "local Ollama model summarizing compressed results (default: keep head and tail)",
+		},
+		{
+			name: "output", category: catConfig, value: &opts.output, def: claude.DefaultOutput, arg: "FORMAT",
+			usage: "output format: text, json (the final API response), json-full (prompt, every call, " +
+				"tool calls with results, usage, cost and timing in one document), or patch " +
+				"(write_file changes become a unified diff instead of touching the tree); csv for --usage-export",
+		},
+		{
+			name: "output-file", category: catConfig, value: &opts.outputFile, arg: "FILE",
+			usage: "write output to file instead of stdout",
+		},
+		{
+			name: "ci", category: catConfig, value: &opts.ci,
+			usage: "non-interactive CI mode: no color or prompts, requires .claude/policy.json and " +
+				"explicit --max-cost/--max-iterations, temperature 0, writes .claude/summary.json",
+		},
+		{
+			name: "summary-json", category: catConfig, value: &opts.summaryJSON, arg: "FILE",
+			usage: "write a JSON run summary (tokens, cost, tools, files, exit status) to this file, e.g. /dev/fd/3",
+			long:  "The summary is written on failure too.",
+		},
+		{
+			name: "github-pr", category: catConfig, value: &opts.githubPR, arg: "N",
+			usage: "post the answer, the --output=patch patch and the run summary as a comment on pull request N",
+			long: "Needs GITHUB_TOKEN. The repository is GITHUB_REPOSITORY or the origin remote; " +
+				"GITHUB_API_URL selects GitHub Enterprise. Failed runs are reported too.",
+		},
+		{
+			name: "post-webhook", category: catConfig, value: &opts.postWebhook, arg: "URL",
+			usage: "post the final answer and run summary as JSON to this webhook when the run ends",
+			long:  "Failed runs are posted too. Playbooks post the answer of their last step.",
+		},
+		{
+			name: "webhook-format", category: catConfig, value: &opts.webhookFormat, arg: "FORMAT",
+			usage: "with --post-webhook: json, or slack to add Block Kit blocks (default: slack for hooks.slack.com URLs)",
+		},
+		{
+			name: "notify", category: catConfig, value: &opts.notify,
+			usage: "ring the terminal bell and send a desktop notification (notify-send/osascript) when a run finishes",
+		},
+		{
+			name: "notify-after", category: catConfig, value: &opts.notifyAfter, def: 30 * time.Second, arg: "DURATION",
+			usage: "with --notify: only notify for runs taking at least this long",
+		},
+		{
+			name: "no-footer", category: catConfig, value: &opts.noFooter,
+			usage: "don't print the model, tokens, cost, iterations and time of the run on stderr after it",
+		},
+		{
+			name: "color", category: catConfig, value: &opts.color, def: display.ColorAuto, arg: "WHEN",
+			usage: "color output: auto (terminals, honoring NO_COLOR and CLICOLOR_FORCE), always, never",
+		},
+		{
+			name: "diff-context", category: catConfig, value: &opts.diffContext, def: display.DefaultDiffContext, arg: "N",
+			usage: "unchanged lines shown around each change in file diffs",
+		},
+		{
+			name: "diff-view", category: catConfig, value: &opts.diffView, def: display.DiffUnified, arg: "VIEW",
+			usage: fmt.Sprintf("file diff layout: unified, or split (old and new side by side on terminals "+
+				"at least %d columns wide)", display.MinSplitWidth),
+		},
+		{
+			name: "log-file", category: catConfig, value: &opts.logFile, arg: "FILE",
+			usage: "append diagnostics (per --verbosity) to this file instead of stderr",
+		},
+		{
+			name: "log-format", category: catConfig, value: &opts.logFormat, def: logging.FormatText, arg: "FORMAT",
+			usage: "diagnostic log format: text, json",
+		},
+		{
+			name: "resume-dir", category: catConfig, value: &opts.resumeDir, arg: "DIR",
+			usage: "directory for conversation state (default: current directory)",
+		},
+		{
+			name: "wait", category: catConfig, value: &opts.wait, arg: "DURATION",
+			usage: "wait up to this long (e.g. 30s) for another session to release .claude/lock",
+			long: "Sessions that write to .claude take this lock; a lock whose process has exited " +
+				"is taken over.",
+		},
+		{
+			name: "no-save", category: catConfig, value: &opts.noSave,
+			usage: "keep the conversation in memory: no turn files, backups, audit entries or config updates",
+			long: "For sensitive one-off questions. The turn still sees the saved conversation " +
+				"and tools work as --tool allows, but nothing about it is left in .claude.",
+		},
+		{
+			name: "no-lock", category: catConfig, value: &opts.noLock,
+			usage: "don't lock .claude against concurrent sessions",
+		},
+
+		// Network
+		{
+			name: "api-url", category: catNetwork, value: &opts.apiURL, def: apiURL, arg: "URLS",
+			usage: "Claude Messages API URL; comma-separated URLs (e.g. the API and a gateway) fail over in order",
+			long: "An endpoint that can't be reached, fails with 5xx or is overloaded (529) cools " +
+				"down, longer with every failure in a row; one that is rate limited (429, or an " +
+				"exhausted anthropic-ratelimit-* limit) waits for its reset. Requests go to the first " +
+				"endpoint that isn't cooling down.",
+		},
+		{
+			name: "timeout", category: catNetwork, value: &opts.timeout, def: claude.DefaultTimeout, arg: "SECONDS",
+			usage: "HTTP timeout in seconds",
+		},
+		{
+			name: "record", category: catNetwork, value: &opts.record, arg: "FILE",
+			usage: "record the HTTP requests to the LLM APIs and their responses to the cassette FILE",
+			long: "Request headers aren't recorded and credentials are scrubbed from URLs and bodies, " +
+				"so cassettes can be committed as test fixtures or shared as demos.",
+		},
+		{
+			name: "replay-http", category: catNetwork, value: &opts.replayHTTP, arg: "FILE",
+			usage: "answer the HTTP requests to the LLM APIs from the cassette FILE, offline",
+			long: "Each request gets the first unplayed response recorded for its method and URL, " +
+				"preferring an exact body match. ANTHROPIC_API_KEY may be any value.",
+		},
+		{
+			name: "proxy", category: catNetwork, value: &opts.proxy, arg: "URL",
+			usage: "HTTP(S) proxy URL (default: HTTPS_PROXY/HTTP_PROXY, NO_PROXY is honored)",
+		},
+		{
+			name: "ca-cert", category: catNetwork, value: &opts.caCert, arg: "FILE",
+			usage: "PEM file with extra CA certificates to trust (self-hosted gateways)",
+		},
+		{
+			name: "insecure-skip-verify", category: catNetwork, value: &opts.insecureSkipVerify,
+			usage: "disable TLS certificate verification (unsafe, testing only)",
+		},
+	}
+}
+
+// registerFlags defines the flags of table on fs
+func registerFlags(fs *flag.FlagSet, table []flagDef) {
+	for _, d := range table {
+		switch v := d.value.(type) {
+		case *bool:
+			fs.BoolVar(v, d.name, defaultOf[bool](d.def), d.usage)
+		case *string:
+			fs.StringVar(v, d.name, defaultOf[string](d.def), d.usage)
+		case *int:
+			fs.IntVar(v, d.name, defaultOf[int](d.def), d.usage)
+		case *float64:
+			fs.Float64Var(v, d.name, defaultOf[float64](d.def), d.usage)
+		case *time.Duration:
+			fs.DurationVar(v, d.name, defaultOf[time.Duration](d.def), d.usage)
+		case flag.Value:
+			fs.Var(v, d.name, d.usage)
+		default:
+			panic(fmt.Sprintf("flag %s: unsupported value %T", d.name, d.value))
+		}
+	}
+}
+
+// defaultOf returns def as a T, the zero value for nil. A default of the
+// wrong type panics at startup rather than silently becoming zero.
+func defaultOf[T any](def interface{}) T {
+	if def == nil {
+		var zero T
+		return zero
+	}
+	return def.(T)
+}
+
+// flagName returns how help shows d: -c PROMPT, --model=MODEL, --last
+func flagName(d flagDef) string {
+	dashes := "--"
+	if len(d.name) == 1 {
+		dashes = "-"
+	}
+	switch {
+	case d.arg == "" || isBoolFlag(d):
+		if d.arg != "" {
+			return fmt.Sprintf("%s%s[=%s]", dashes, d.name, d.arg) // countFlag
+		}
+		return dashes + d.name
+	case len(d.name) == 1:
+		return fmt.Sprintf("-%s %s", d.name, d.arg)
+	default:
+		return fmt.Sprintf("--%s=%s", d.name, d.arg)
+	}
+}
+
+// isBoolFlag reports whether d may be given without a value
+func isBoolFlag(d flagDef) bool {
+	if _, ok := d.value.(*bool); ok {
+		return true
+	}
+	b, ok := d.value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagDefault returns the default help mentions, strings quoted, or ""
+// for none
+func flagDefault(fs *flag.FlagSet, d flagDef) string {
+	f := fs.Lookup(d.name)
+	if f == nil || d.def == nil || isBoolFlag(d) || d.def == noReplay {
+		return ""
+	}
+	if strings.Contains(d.usage, "default") {
+		return "" // the usage says it
+	}
+	if _, ok := d.value.(*string); ok {
+		return strconv.Quote(f.DefValue)
+	}
+	return f.DefValue
+}
+
+// printUsage writes -h: the examples and the flags by category, one line
+// each
+func printUsage(w io.Writer, fs *flag.FlagSet, table []flagDef) {
+	fmt.Fprintf(w, "Usage: claude [options]\n")
+	fmt.Fprintf(w, "       claude index [options]\n")
+	fmt.Fprintf(w, "       claude prompt save|list|run [NAME] [PARAM=VALUE...] [options]\n")
+	fmt.Fprintf(w, "       claude cron [NAME [PARAM=VALUE...]] [options]\n")
+	fmt.Fprintf(w, "       claude job submit|list|attach|cancel [ID] [options]\n\n")
+	fmt.Fprintf(w, "A CLI for interacting with Claude AI with tool support.\n\n")
+	fmt.Fprintf(w, "Examples:\n")
+	for _, ex := range usageExamples {
+		fmt.Fprintf(w, "  # %s\n", ex.comment)
+		for _, line := range strings.Split(ex.commands, "\n") {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, cat := range flagCategories {
+		fmt.Fprintf(w, "%s:\n", cat)
+		for _, d := range table {
+			if d.category != cat {
+				continue
+			}
+			fmt.Fprintf(w, "  %s\n", flagName(d))
+			usage := d.usage
+			if def := flagDefault(fs, d); def != "" {
+				usage += fmt.Sprintf(" (default %s)", def)
+			}
+			fmt.Fprintf(w, "    \t%s\n", usage)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Run claude --help-full for the details of every flag, or claude --man | man -l - for the man page.\n")
+}
+
+// printHelpFull writes --help-full: every flag with its long description
+func printHelpFull(w io.Writer, fs *flag.FlagSet, table []flagDef) {
+	fmt.Fprintf(w, "Usage: claude [options]\n")
+	fmt.Fprintf(w, "       claude index [options]\n")
+	fmt.Fprintf(w, "       claude prompt save|list|run [NAME] [PARAM=VALUE...] [options]\n")
+	fmt.Fprintf(w, "       claude cron [NAME [PARAM=VALUE...]] [options]\n")
+	fmt.Fprintf(w, "       claude job submit|list|attach|cancel [ID] [options]\n")
+	for _, cat := range flagCategories {
+		fmt.Fprintf(w, "\n%s\n%s\n", strings.ToUpper(cat), strings.Repeat("=", len(cat)))
+		for _, d := range table {
+			if d.category != cat {
+				continue
+			}
+			fmt.Fprintf(w, "\n  %s\n", flagName(d))
+			text := capitalize(d.usage) + "."
+			if d.long != "" {
+				text += " " + d.long
+			}
+			if def := flagDefault(fs, d); def != "" {
+				text += fmt.Sprintf(" Default: %s.", def)
+			}
+			for _, line := range display.WrapText(text, 72) {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+}
+
+// printMan writes --man: a man page in roff
+func printMan(w io.Writer, fs *flag.FlagSet, table []flagDef) {
+	fmt.Fprintf(w, ".TH CLAUDE 1 \"\" \"go-claude\" \"User Commands\"\n")
+	fmt.Fprintf(w, ".SH NAME\nclaude \\- a CLI for Claude and local models with tool support\n")
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B claude\n[\\fIoptions\\fR]\n.br\n.B claude index\n[\\fIoptions\\fR]\n.br\n"+
+		".B claude prompt\nsave|list|run [\\fINAME\\fR] [\\fIPARAM\\fR=\\fIVALUE\\fR...] [\\fIoptions\\fR]\n.br\n"+
+		".B claude cron\n[\\fINAME\\fR [\\fIPARAM\\fR=\\fIVALUE\\fR...]] [\\fIoptions\\fR]\n.br\n"+
+		".B claude job\nsubmit|list|attach|cancel [\\fIID\\fR] [\\fIoptions\\fR]\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roffEscape("claude sends the prompt read from stdin, "+
+		"or given with -c, to the model, continuing the conversation saved in .claude/ of "+
+		"the current directory. The model may use tools to read and change files and run "+
+		"commands, within the permissions of --tool; by default it is a dry run that only "+
+		"shows what would happen."))
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, cat := range flagCategories {
+		fmt.Fprintf(w, ".SS %s\n", roffEscape(cat))
+		for _, d := range table {
+			if d.category != cat {
+				continue
+			}
+			fmt.Fprintf(w, ".TP\n%s\n", roffFlag(d))
+			text := capitalize(d.usage) + "."
+			if d.long != "" {
+				text += " " + d.long
+			}
+			if def := flagDefault(fs, d); def != "" {
+				text += fmt.Sprintf(" Default: %s.", def)
+			}
+			fmt.Fprintf(w, "%s\n", roffEscape(text))
+		}
+	}
+	fmt.Fprintf(w, ".SH EXAMPLES\n")
+	for _, ex := range usageExamples {
+		fmt.Fprintf(w, ".PP\n%s:\n.PP\n.RS\n.nf\n%s\n.fi\n.RE\n", roffEscape(ex.comment), roffEscape(ex.commands))
+	}
+	fmt.Fprintf(w, ".SH FILES\n.TP\n.I .claude/\n%s\n", roffEscape("Conversation history, "+
+		"configuration, backups of changed files and the audit log of the project."))
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n.TP\n.B ANTHROPIC_API_KEY\n%s\n", roffEscape("Key of the "+
+		"Anthropic API, needed for Claude models unless --provider is vertex or bedrock. "+
+		"Several comma-separated keys spread the requests round-robin."))
+}
+
+// roffFlag formats the name of d for a .TP tag: \fB\-\-model\fR=\fIMODEL\fR
+func roffFlag(d flagDef) string {
+	name := flagName(d)
+	bold, arg := name, ""
+	if i := strings.IndexAny(name, "=[ "); i >= 0 {
+		bold, arg = name[:i], name[i:]
+	}
+	out := `\fB` + strings.ReplaceAll(bold, "-", `\-`) + `\fR`
+	if arg != "" {
+		sep := arg[:1]
+		rest := strings.Trim(arg[1:], "=]")
+		switch sep {
+		case "[":
+			out += `[=\fI` + rest + `\fR]`
+		default:
+			out += sep + `\fI` + rest + `\fR`
+		}
+	}
+	return out
+}
+
+// roffEscape escapes text for roff: backslashes, and dots and quotes
+// starting a line
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -3,17 +3,29 @@
 package main
 
 import (
+	"context"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/marcopeereboom/go-claude/pkg/claude"
 	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/llm/recorder"
+	"github.com/marcopeereboom/go-claude/pkg/logging"
 	"github.com/marcopeereboom/go-claude/pkg/storage"
+	"github.com/marcopeereboom/go-claude/pkg/telemetry"
 )
 
 //go:embed defaultprompt.txt
@@ -31,158 +43,268 @@ func main() {
 
 func run() error {
 	opts := parseFlags()
+	switch {
+	case opts.helpFull:
+		printHelpFull(os.Stdout, flag.CommandLine, flagTable(opts))
+		return nil
+	case opts.man:
+		printMan(os.Stdout, flag.CommandLine, flagTable(opts))
+		return nil
+	}
+	if err := display.SetColorMode(opts.color); err != nil {
+		return err
+	}
+	if err := display.SetDiffOptions(opts.diffContext, opts.diffView); err != nil {
+		return err
+	}
 
-	claudeDir, err := getClaudeDir(opts.resumeDir)
+	if opts.readOnly {
+		if err := checkReadOnly(opts); err != nil {
+			return err
+		}
+		storage.SetReadOnly(true)
+	}
+	if opts.noSave {
+		if opts.stage || opts.amend || opts.serve {
+			return fmt.Errorf("--no-save can't be used with --stage, --amend or --serve")
+		}
+		storage.SetEphemeral(true)
+	}
+
+	closeLog, err := logging.Setup(opts.verbosity, opts.logFormat, opts.logFile)
 	if err != nil {
 		return err
 	}
+	defer closeLog()
 
-	// Handle models commands first (don't need stdin)
-	if opts.modelsList {
-		return claude.ListModelsCommand(claudeDir, opts.ollamaURL)
+	// OTLP export only when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTelemetry, err := telemetry.Setup(context.Background())
+	if err != nil {
+		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			slog.Warn("flushing telemetry", "err", err)
+		}
+	}()
 
-	if opts.modelsRefresh {
-		return claude.RefreshModelsCommand(claudeDir, opts.ollamaURL)
+	claudeDir, err := getClaudeDir(opts.resumeDir)
+	if err != nil {
+		return err
 	}
 
-	// Handle --execute mode (use last message from conversation)
-	if opts.execute {
-		messages, err := storage.LoadConversationHistory(claudeDir)
-		if err != nil {
-			return fmt.Errorf("loading conversation: %w", err)
+	if opts.promptLib {
+		if done, err := promptCommand(opts, claudeDir); done {
+			return err
 		}
+	}
 
-		var userMsg string
+	cmd := selectCommand(opts)
+	slog.Debug("command", "name", cmd.name)
+	if cmd.setup >= setupTransport {
+		if err := configureRun(opts, claudeDir); err != nil {
+			return err
+		}
+	}
+	if cmd.setup >= setupProject {
+		if err := configureProject(opts, claudeDir); err != nil {
+			return err
+		}
+	}
 
-		// Try to get last user message from completed conversation
-		if len(messages) > 0 {
-			userMsg, err = claude.GetLastUserMessage(messages)
+	// Serialize sessions that write to claudeDir: every command that does
+	// runs past this point
+	if cmd.setup >= setupSession {
+		if !cmd.reads && !opts.noLock && !opts.readOnly && !opts.noSave {
+			lock, err := storage.AcquireLock(claudeDir, opts.wait)
 			if err != nil {
-				return fmt.Errorf("no user message in conversation")
-			}
-		} else {
-			// No complete pairs - check for unpaired request (from --estimate)
-			entries, err := os.ReadDir(claudeDir)
-			if err != nil {
-				return fmt.Errorf("no conversation history")
-			}
-			for _, entry := range entries {
-				if strings.HasPrefix(entry.Name(), "request_") {
-					reqPath := filepath.Join(claudeDir, entry.Name())
-					req, err := storage.LoadRequest(reqPath)
-					if err != nil {
-						continue
-					}
-					userMsg, _ = claude.GetLastUserMessage(req.Messages)
-					break
+				if errors.Is(err, storage.ErrLocked) {
+					return fmt.Errorf("%w (use --wait to wait for it or --no-lock to skip locking)", err)
 				}
+				return err
 			}
-			if userMsg == "" {
-				return fmt.Errorf("no message to execute")
-			}
-		}
-
-		// Override max-cost if provided
-		if opts.maxCostFlag > 0 {
-			opts.maxCost = opts.maxCostFlag
+			defer func() {
+				if err := lock.Release(); err != nil {
+					slog.Warn("releasing lock", "err", err)
+				}
+			}()
 		}
-
-		// Normal execution flow with last user message
-		return executeWithSavedInput(userMsg, opts, claudeDir)
+		opts.semanticSearch = !opts.noSemanticSearch && storage.HasEmbeddingIndex(claudeDir)
 	}
 
-	// Handle --estimate mode
-	if opts.estimate {
-		// Must have stdin
-		stat, _ := os.Stdin.Stat()
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("no input (pipe required)")
-		}
+	return cmd.run(opts, claudeDir)
+}
 
-		userMsg, err := readInput()
+// configureRun applies the profile and --ci, and sets up the transport of
+// every provider client
+func configureRun(opts *options, claudeDir string) error {
+	var profile *storage.Profile
+	if opts.profile != "" {
+		var err error
+		profile, err = claude.LoadProfile(claudeDir, opts.profile)
 		if err != nil {
 			return err
 		}
+		applyProfile(opts, profile)
+	}
 
-		// Load conversation history
-		messages, _ := storage.LoadConversationHistory(claudeDir)
-
-		// Get model for pricing
-		configPath := filepath.Join(claudeDir, "config.json")
-		cfg := storage.LoadOrCreateConfig(configPath)
-		model := claude.SelectModel(opts.model, cfg.Model)
-
-		// Estimate and display
-		estimate := claude.EstimateCost(userMsg, messages, model)
-		claude.DisplayEstimate(estimate)
-
-		// Save this message to conversation so --execute can use it
-		messages = append(messages, claude.MessageContent{
-			Role: "user",
-			Content: []claude.ContentBlock{{
-				Type: "text",
-				Text: userMsg,
-			}},
-		})
-		timestamp := storage.CurrentTimestamp()
-		if err := storage.SaveRequest(claudeDir, timestamp, messages); err != nil {
-			return fmt.Errorf("saving request: %w", err)
-		}
-
-		// Update config
-		cfg.Model = model
-		if cfg.FirstRun == "" {
-			cfg.FirstRun = storage.CurrentTimestamp()
+	if opts.ci {
+		if err := applyCI(opts, profile, claudeDir); err != nil {
+			return err
 		}
-		cfg.LastRun = storage.CurrentTimestamp()
-		configPath = filepath.Join(claudeDir, "config.json")
-		if err := storage.SaveJSON(configPath, cfg); err != nil {
-			return fmt.Errorf("saving config: %w", err)
+	}
+	// Again: the profile and --ci may have added tools or a summary file
+	if opts.readOnly {
+		if err := checkReadOnly(opts); err != nil {
+			return err
 		}
-
-		return nil
 	}
 
-	// Handle special modes that don't need full setup
-	if opts.showStats {
-		return showStats(claudeDir)
+	// Proxy and TLS settings apply to every provider client, as do
+	// recording and replaying cassettes
+	wrap, err := cassetteTransport(opts.record, opts.replayHTTP)
+	if err != nil {
+		return err
+	}
+	if err := llm.ConfigureTransport(llm.TransportConfig{
+		ProxyURL:           opts.proxy,
+		CACertFile:         opts.caCert,
+		InsecureSkipVerify: opts.insecureSkipVerify,
+		Timeout:            time.Duration(opts.timeout) * time.Second,
+		Wrap:               wrap,
+	}); err != nil {
+		return err
 	}
+	apiURL = opts.apiURL
+	return nil
+}
 
+// configureProject loads what claudeDir configures: encryption, redaction,
+// the workspace, limits, formatters, agents, plugins and hooks
+func configureProject(opts *options, claudeDir string) error {
+	// Unlock encrypted conversation files (reset must work without a key)
+	if !opts.reset {
+		if err := claude.ConfigureEncryption(claudeDir); err != nil {
+			return err
+		}
+	}
+	if err := claude.ConfigureRedaction(claudeDir); err != nil {
+		return err
+	}
 	if opts.reset {
-		return resetConversation(claudeDir, opts.isVerbose())
+		return nil
 	}
-
-	if opts.replay != "NOREPLAY" {
-		return claude.ReplayResponse(claudeDir, toClaudeOptions(opts))
+	if err := claude.ConfigureWorkspace(claudeDir); err != nil {
+		return err
 	}
-
-	if opts.pruneOld > 0 {
-		return storage.PruneResponses(claudeDir, opts.pruneOld, opts.isVerbose())
+	if err := claude.ConfigureCommandLimits(claudeDir); err != nil {
+		return err
 	}
-
-	// Check if stdin is a pipe/redirect, not interactive terminal
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return fmt.Errorf("checking stdin: %w", err)
+	if err := claude.ConfigureFormatters(claudeDir); err != nil {
+		return err
 	}
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		// Interactive terminal - no input piped
-		flag.Usage()
-		return fmt.Errorf("no input provided (pipe or redirect required)")
+	if err := claude.ConfigureAgents(claudeDir); err != nil {
+		return err
+	}
+	if err := claude.ConfigureToolPlugins(claudeDir); err != nil {
+		return err
 	}
+	return claude.ConfigureHooks(claudeDir)
+}
 
-	// Normal execution
-	userMsg, err := readInput()
+// isolated runs fn in an --isolate worktree, or in the working directory
+// without --isolate. The worktree is finished even when fn fails, so the
+// changes of a failed turn can still be reviewed.
+func isolated(opts *options, prompt string, fn func() error) error {
+	if !opts.isolate {
+		return fn()
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting cwd: %w", err)
+	}
+	iso, err := claude.Isolate(workingDir)
 	if err != nil {
 		return err
 	}
-	return executeWithSavedInput(userMsg, opts, claudeDir)
+	if err := os.Chdir(iso.Dir); err != nil {
+		return err
+	}
+	err = fn()
+	if cerr := os.Chdir(workingDir); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	// Only a successful turn is worth a pull request
+	openPR := err == nil && toClaudeOptions(opts).CanExecuteCommand()
+	if ferr := iso.Finish(prompt, openPR); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
 }
 
 func executeWithSavedInput(userMsg string, opts *options, claudeDir string) error {
+	return isolated(opts, userMsg, func() error {
+		return executeTurn(userMsg, opts, claudeDir)
+	})
+}
+
+func executeTurn(userMsg string, opts *options, claudeDir string) (err error) {
+	start := time.Now()
+
+	// Before the turn, so a missing token or repository costs nothing
+	var pr *claude.GitHubPR
+	if opts.githubPR > 0 {
+		if pr, err = githubPR(opts); err != nil {
+			return err
+		}
+	}
+	if _, err := claude.WebhookFormat(opts.webhookFormat, opts.postWebhook); err != nil {
+		return err
+	}
+
 	// Initialize session
+	var answer string
 	sess, err := claude.InitSession(toClaudeOptions(opts), claudeDir, apiURL, defaultSystemPrompt)
+	defer func() { notifyDone(opts, start, sess.Summary(err), err) }()
+
+	// Failed runs are reported too, so the pull request shows why
+	if pr != nil {
+		defer func() {
+			url, perr := pr.Comment(claude.PRComment(sess.Summary(err), answer, sess.Patch()))
+			switch {
+			case perr != nil && err == nil:
+				err = perr
+			case perr != nil:
+				slog.Warn("--github-pr", "err", perr)
+			default:
+				fmt.Fprintf(os.Stderr, "Commented on %s#%d: %s\n", pr.Repo, pr.Number, url)
+			}
+		}()
+	}
+
+	if opts.postWebhook != "" {
+		defer func() {
+			if werr := postWebhook(opts, sess.Summary(err), answer); werr != nil {
+				if err == nil {
+					err = werr
+					return
+				}
+				slog.Warn("--post-webhook", "err", werr)
+			}
+		}()
+	}
+
+	// The summary is written for failed runs too so CI can inspect them
+	if opts.summaryJSON != "" {
+		defer func() {
+			if serr := claude.WriteSummary(opts.summaryJSON, sess.Summary(err)); serr != nil && err == nil {
+				err = serr
+			}
+		}()
+	}
 	if err != nil {
 		return err
 	}
@@ -192,9 +314,99 @@ func executeWithSavedInput(userMsg string, opts *options, claudeDir string) erro
 	if err != nil {
 		return err
 	}
+	answer = result.AssistantText()
 
 	// Save and output results
-	return claude.FinalizeSession(sess, result, storage.SaveJSON, writeOutput)
+	if err := claude.FinalizeSession(sess, result, storage.SaveJSON, writeOutput); err != nil {
+		return err
+	}
+
+	// What this run cost, without diffing --stats totals
+	if !opts.noFooter && opts.verbosity != claude.VerbositySilent && display.IsTTY(os.Stderr) {
+		display.Info("%s", sess.Summary(nil).Footer(time.Since(start)))
+	}
+	return nil
+}
+
+// githubPR returns the --github-pr pull request
+func githubPR(opts *options) (*claude.GitHubPR, error) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting cwd: %w", err)
+	}
+	pr, err := claude.NewGitHubPR(opts.githubPR, workingDir)
+	if err != nil {
+		return nil, err
+	}
+	if pr.Client, err = reportClient(opts); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// reportClient returns the HTTP client of --github-pr and --post-webhook:
+// the proxy and TLS settings of the providers, without cassettes
+func reportClient(opts *options) (*http.Client, error) {
+	return llm.NewHTTPClient(llm.TransportConfig{
+		ProxyURL:           opts.proxy,
+		CACertFile:         opts.caCert,
+		InsecureSkipVerify: opts.insecureSkipVerify,
+		Timeout:            time.Duration(opts.timeout) * time.Second,
+	})
+}
+
+// postWebhook posts the answer and summary of a run to --post-webhook
+func postWebhook(opts *options, summary *claude.RunSummary, answer string) error {
+	format, err := claude.WebhookFormat(opts.webhookFormat, opts.postWebhook)
+	if err != nil {
+		return err
+	}
+	client, err := reportClient(opts)
+	if err != nil {
+		return err
+	}
+	project, _ := os.Getwd()
+	return claude.PostWebhook(client, opts.postWebhook,
+		claude.NewWebhookPayload(summary, answer, project, format))
+}
+
+// notifyDone sends the --notify notification for a run that took at
+// least --notify-after. summary is nil for playbooks.
+func notifyDone(opts *options, start time.Time, summary *claude.RunSummary, err error) {
+	elapsed := time.Since(start)
+	if !opts.notify || elapsed < opts.notifyAfter {
+		return
+	}
+
+	title := "claude: done"
+	msg := fmt.Sprintf("Finished in %s", elapsed.Round(time.Second))
+	if err != nil {
+		title = "claude: failed"
+		msg = fmt.Sprintf("Failed after %s: %s", elapsed.Round(time.Second),
+			strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	if summary != nil {
+		msg += fmt.Sprintf(" ($%.4f, %d files changed)", summary.Cost,
+			len(summary.FilesChanged))
+	}
+	display.Notify(title, msg)
+}
+
+// embedOptions returns the embedder of claude index, defaulting the URL
+// per provider
+func embedOptions(opts *options) claude.EmbedOptions {
+	eo := claude.EmbedOptions{
+		Provider: opts.embedProvider,
+		Model:    opts.embedModel,
+		URL:      opts.embedURL,
+	}
+	if eo.URL == "" {
+		eo.URL = opts.ollamaURL
+		if eo.Provider == claude.EmbedProviderOpenAI {
+			eo.URL = llm.OpenAIBaseURL
+		}
+	}
+	return eo
 }
 
 // toClaudeOptions converts main options to claude.Options
@@ -211,145 +423,578 @@ func toClaudeOptions(opts *options) *claude.Options {
 		Tool:           opts.tool,
 		Output:         opts.output,
 		SystemPrompt:   opts.systemPrompt,
-		ResumeDir:      opts.resumeDir,
+		SystemFiles:    opts.systemFiles,
+		Prefill:        opts.prefill,
 		OutputFile:     opts.outputFile,
 		Replay:         opts.replay,
-		MaxCostFlag:    opts.maxCostFlag,
-		ModelsList:     opts.modelsList,
-		ModelsRefresh:  opts.modelsRefresh,
-		Reset:          opts.reset,
-		ShowStats:      opts.showStats,
-		PruneOld:       opts.pruneOld,
-		Estimate:       opts.estimate,
-		Execute:        opts.execute,
+		Amend:          opts.amendTurn,
 		PreferLocal:    opts.preferLocal,
 		AllowFallback:  opts.allowFallback,
 		MaxClaudeRatio: opts.maxClaudeRatio,
+		Provider:       opts.provider,
+		ExplainRouting: opts.explainRouting,
+		TurnModel:      opts.turnModel,
+		Temperature:    opts.temperature,
+		Verify:         opts.verify,
+		Validate:       opts.validate,
+		MaxFixAttempts: opts.maxFixAttempts,
+		FormatWrites:   opts.formatWrites,
+		BestOf:         opts.bestOf,
+		Judge:          opts.judge,
+
+		CompressResults: opts.compressResults,
+		CompressModel:   opts.compressModel,
+		ProjectContext:  opts.projectContext,
+		EnvContext:      opts.envContext,
+		OllamaAutoPull:  opts.ollamaAutoPull,
+		PlanApprove:     opts.planApprove,
+		ForceTool:       opts.forceTool,
+		SequentialTools: opts.sequentialTools,
+		Steer:           opts.steer,
+		Watch:           opts.watch,
+		Images:          splitList(opts.images),
+		SemanticSearch:  opts.semanticSearch,
+		RAG:             opts.rag,
+		RAGTokens:       opts.ragTokens,
+		Tags:            splitList(opts.tag),
+		PendingPatch:    opts.pendingPatch,
+		ToolBudgets:     opts.toolBudget,
+		SpendAlerts:     opts.spendAlerts,
+		DailyBudget:     opts.dailyBudget,
+		FallbackChain:   splitList(opts.fallbackChain),
+		FallbackOn:      splitList(opts.fallbackOn),
+		FallbackRetries: opts.fallbackRetries,
+
+		ReplayOnly:        splitList(opts.replayOnly),
+		ReplayToolIDs:     splitList(opts.replayToolIDs),
+		ReplayInteractive: opts.replayInteractive,
+		ReplayForce:       opts.force,
+	}
+}
+
+// applyProfile fills in options from a profile. Flags given explicitly on
+// the command line always win over the profile.
+func applyProfile(opts *options, p *storage.Profile) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if p.Model != "" && !set["model"] {
+		opts.model = p.Model
+	}
+	if p.SystemPrompt != "" && !set["system"] {
+		opts.systemPrompt = p.SystemPrompt
+	}
+	if p.MaxTokens > 0 && !set["max-tokens"] {
+		opts.maxTokens = p.MaxTokens
+	}
+	if p.MaxCost != nil && !set["max-cost"] {
+		opts.maxCost = *p.MaxCost
+	}
+	if p.MaxIterations != nil && !set["max-iterations"] {
+		opts.maxIterations = *p.MaxIterations
+	}
+	if p.MaxClaudeRatio != nil && !set["max-claude-ratio"] {
+		opts.maxClaudeRatio = *p.MaxClaudeRatio
+	}
+	if p.Tool != nil && !set["tool"] {
+		opts.tool = *p.Tool
+	}
+	if p.OllamaURL != "" && !set["ollama-url"] {
+		opts.ollamaURL = p.OllamaURL
+	}
+	if p.Verify != "" && !set["verify"] {
+		opts.verify = p.Verify
+	}
+
+	switch p.Provider {
+	case claude.ProviderOllama:
+		// Local only: never fall back to the paid API
+		if !set["allow-fallback"] {
+			opts.allowFallback = false
+		}
+	case claude.ProviderClaude, claude.ProviderVertex, claude.ProviderBedrock:
+		if !set["provider"] {
+			opts.provider = p.Provider
+		}
+		if !set["prefer-local"] {
+			opts.preferLocal = false
+		}
+	}
+}
+
+// applyCI enforces --ci: plain output, no prompts, a policy file, explicit
+// budgets, deterministic sampling and a run summary
+func applyCI(opts *options, p *storage.Profile, claudeDir string) error {
+	if opts.replayInteractive {
+		return fmt.Errorf("--interactive can't be used with --ci")
+	}
+	if opts.steer {
+		return fmt.Errorf("--steer can't be used with --ci")
+	}
+	if opts.amend {
+		return fmt.Errorf("--amend opens an editor and can't be used with --ci")
+	}
+	// These ask for a go-ahead unless it is given up front
+	if opts.plan && !opts.planApprove {
+		return fmt.Errorf("--plan with --ci requires --plan-approve")
+	}
+	if opts.dropLast > 0 && !opts.force {
+		return fmt.Errorf("--drop-last with --ci requires --force")
+	}
+
+	// Budgets must be chosen on purpose, not inherited from defaults
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["max-cost"] && (p == nil || p.MaxCost == nil) {
+		return fmt.Errorf("--ci requires an explicit --max-cost")
+	}
+	if !set["max-iterations"] && (p == nil || p.MaxIterations == nil) {
+		return fmt.Errorf("--ci requires an explicit --max-iterations")
+	}
+	if opts.maxCost <= 0 || opts.maxIterations <= 0 {
+		return fmt.Errorf("--ci doesn't allow unlimited budgets " +
+			"(--max-cost and --max-iterations must be > 0)")
+	}
+
+	if _, err := storage.RequirePolicy(claudeDir); err != nil {
+		return fmt.Errorf("--ci: %w", err)
+	}
+
+	display.SetColor(false)
+	zero := 0.0
+	opts.temperature = &zero
+	if opts.summaryJSON == "" {
+		opts.summaryJSON = filepath.Join(claudeDir, "summary.json")
+	}
+	return nil
+}
+
+// checkReadOnly rejects what --read-only can't do without writing and
+// limits the tools to reading
+func checkReadOnly(opts *options) error {
+	writers := []struct {
+		flag string
+		set  bool
+	}{
+		{"reset", opts.reset},
+		{"models-refresh", opts.modelsRefresh},
+		{"replay", opts.replay != noReplay},
+		{"playbook", opts.playbook != ""},
+		{"recover", opts.recover},
+		{"prune-old", opts.pruneOld > 0},
+		{"drop-last", opts.dropLast > 0},
+		{"reindex", opts.reindex},
+		{"fsck --quarantine", opts.fsck && opts.quarantine},
+		{"gc", opts.gc},
+		{"plan", opts.plan},
+		{"index", opts.index},
+		{"serve", opts.serve},
+		{"openai-proxy", opts.openAIProxy != ""},
+		{"export-session", opts.exportSession != ""},
+		{"import-session", opts.importSession != ""},
+		{"fork", opts.fork != ""},
+		{"stage", opts.stage},
+		{"amend", opts.amend},
+		{"isolate", opts.isolate},
+		{"pending-patch", opts.pendingPatch},
+		{"output-file", opts.outputFile != ""},
+		{"summary-json", opts.summaryJSON != ""},
+		{"log-file", opts.logFile != ""},
+		{"record", opts.record != ""},
+		{"compare-dir", opts.compareDir != ""},
+	}
+	for _, w := range writers {
+		if w.set {
+			return fmt.Errorf("--%s writes files and can't be used with --read-only", w.flag)
+		}
+	}
+	// Installing writes units or the crontab, and the scheduled runs write
+	// their answers and logs under .claude/cron
+	if opts.cron {
+		return fmt.Errorf("claude cron schedules runs that write files and can't be used with --read-only")
+	}
+	copts := toClaudeOptions(opts)
+	if copts.CanExecuteWrite() || copts.CanExecuteCommand() {
+		return fmt.Errorf("--read-only allows --tool=read at most, not %s", opts.tool)
+	}
+	if opts.tool == claude.DefaultTool {
+		opts.tool = claude.ToolRead // dry-run would still log proposed writes
+	}
+	return nil
+}
+
+// connectRequest is the --connect request for prompt: the flags given on
+// the command line override the daemon's
+func connectRequest(opts *options, claudeDir, prompt string) *claude.TurnRequest {
+	req := &claude.TurnRequest{Dir: filepath.Dir(claudeDir), Prompt: prompt}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "model":
+			req.Model = opts.model
+		case "tool":
+			req.Tool = &opts.tool
+		case "max-cost":
+			req.MaxCost = opts.maxCost
+		case "max-iterations":
+			req.MaxIterations = opts.maxIterations
+		}
+	})
+	return req
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// countFlag is a count that may be given without a value: --drop-last is
+// --drop-last=1
+type countFlag int
+
+func (c *countFlag) String() string { return strconv.Itoa(int(*c)) }
+
+func (c *countFlag) IsBoolFlag() bool { return true }
+
+func (c *countFlag) Set(s string) error {
+	if s == "true" {
+		*c = 1
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("want a count of at least 1")
+	}
+	*c = countFlag(n)
+	return nil
+}
+
+// cassetteTransport returns the transport wrapper of --record or
+// --replay-http, nil for neither
+func cassetteTransport(record, replay string) (func(http.RoundTripper) http.RoundTripper, error) {
+	path, mode := record, recorder.ModeRecord
+	switch {
+	case record != "" && replay != "":
+		return nil, fmt.Errorf("--record and --replay-http can't be used together")
+	case record == "" && replay == "":
+		return nil, nil
+	case replay != "":
+		path, mode = replay, recorder.ModeReplay
+	}
+	rec, err := recorder.New(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Transport, nil
+}
+
+// budgetsFlag holds --tool-budget: calls per turn by tool name. Repeated
+// flags add to it.
+type budgetsFlag map[string]int
+
+func (b *budgetsFlag) String() string { return claude.FormatToolBudgets(*b) }
+
+func (b *budgetsFlag) Set(s string) error {
+	budgets, err := claude.ParseToolBudgets(s)
+	if err != nil {
+		return err
+	}
+	if *b == nil {
+		*b = make(budgetsFlag)
+	}
+	for name, n := range budgets {
+		(*b)[name] = n
+	}
+	return nil
+}
+
+// alertsFlag holds --spend-alerts: nil when not given, empty for off
+type alertsFlag []int
+
+func (a *alertsFlag) String() string { return claude.FormatSpendAlerts(*a) }
+
+func (a *alertsFlag) Set(s string) error {
+	alerts, err := claude.ParseSpendAlerts(s)
+	if err != nil {
+		return err
 	}
+	*a = alerts
+	return nil
 }
 
+// filesFlag holds a flag given once per file, like --system-file
+type filesFlag []string
+
+func (f *filesFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *filesFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// noReplay is the --replay value when it isn't given
+const noReplay = "NOREPLAY"
+
 func parseFlags() *options {
 	opts := &options{}
-
+	table := flagTable(opts)
+	registerFlags(flag.CommandLine, table)
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: claude [options]\n\n")
-		fmt.Fprintf(os.Stderr, "A CLI for interacting with Claude AI with tool support.\n\n")
-		fmt.Fprintf(os.Stderr, "Examples:\n")
-		fmt.Fprintf(os.Stderr, "  # Dry-run (shows what would happen)\n")
-		fmt.Fprintf(os.Stderr, "  echo \"add error handling to users.go\" | claude\n\n")
-		fmt.Fprintf(os.Stderr, "  # Execute with write permission\n")
-		fmt.Fprintf(os.Stderr, "  echo \"add tests\" | claude --tool=write\n\n")
-		fmt.Fprintf(os.Stderr, "  # Replay last run and execute everything\n")
-		fmt.Fprintf(os.Stderr, "  claude --replay --tool=all\n")
-		fmt.Fprintf(os.Stderr, "  claude --replay=20260104_153022 --tool=all\n\n")
-		fmt.Fprintf(os.Stderr, "  # Show statistics\n")
-		fmt.Fprintf(os.Stderr, "  claude --stats\n\n")
-		fmt.Fprintf(os.Stderr, "  # Use local Ollama with fallback to Claude\n")
-		fmt.Fprintf(os.Stderr, "  echo \"explain this code\" | claude --prefer-local --allow-fallback\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-	}
-
-	// Modes
-	flag.BoolVar(&opts.modelsList, "models-list", false,
-		"list available Claude and Ollama models (creates cache if missing)")
-	flag.BoolVar(&opts.modelsRefresh, "models-refresh", false,
-		"refresh models cache from Claude API and Ollama")
-	flag.BoolVar(&opts.reset, "reset", false,
-		"reset conversation (delete .claude/ directory)")
-	flag.BoolVar(&opts.showStats, "stats", false,
-		"show conversation statistics")
-
-	flag.StringVar(&opts.replay, "replay", "NOREPLAY",
-		"replay response (empty=latest, or timestamp like 20260104_153022)")
-	flag.IntVar(&opts.pruneOld, "prune-old", 0,
-		"keep only last N request/response pairs, delete older")
-
-	// Cost estimation
-	flag.BoolVar(&opts.estimate, "estimate", false,
-		"estimate cost without executing (shows cost for piped input)")
-	flag.BoolVar(&opts.execute, "execute", false,
-		"re-execute last user message from conversation")
-	flag.Float64Var(&opts.maxCostFlag, "max-cost-override", 0,
-		"override max-cost for this run (use with --execute)")
-
-	// Core settings
-	flag.StringVar(&opts.model, "model", "",
-		fmt.Sprintf("model to use (default: %s)", claude.DefaultModel))
-	flag.IntVar(&opts.maxTokens, "max-tokens", claude.DefaultMaxTokens,
-		"maximum tokens per API call")
-	flag.Float64Var(&opts.maxCost, "max-cost", claude.DefaultMaxCost,
-		"maximum cost in dollars per conversation (0 = unlimited)")
-	flag.IntVar(&opts.maxIterations, "max-iterations", claude.DefaultMaxIterations,
-		"maximum tool loop iterations (0 = unlimited)")
-	flag.IntVar(&opts.timeout, "timeout", claude.DefaultTimeout,
-		"HTTP timeout in seconds")
-	flag.IntVar(&opts.truncate, "truncate", 0,
-		"keep only last N messages in conversation (0 = keep all)")
-	flag.StringVar(&opts.ollamaURL, "ollama-url", claude.DefaultOllamaURL,
-		"Ollama API URL")
-
-	// Smart routing
-	flag.BoolVar(&opts.preferLocal, "prefer-local", true,
-		"prefer local Ollama models when possible (default: true)")
-	flag.BoolVar(&opts.allowFallback, "allow-fallback", true,
-		"allow fallback to Claude if Ollama fails (default: true)")
-	flag.Float64Var(&opts.maxClaudeRatio, "max-claude-ratio", 0.10,
-		"maximum ratio of Claude vs total requests (0.0-1.0, default: 0.10 = 10%)")
-
-	// Behavior
-	flag.StringVar(&opts.verbosity, "verbosity", claude.DefaultVerbosity,
-		"output verbosity: silent, normal, verbose, debug")
-	flag.StringVar(&opts.tool, "tool", claude.DefaultTool,
-		"tool permissions: \"\" (dry-run), none, read, write, command, all, or comma-separated")
-	flag.StringVar(&opts.output, "output", claude.DefaultOutput,
-		"output format: text, json")
-
-	// Advanced
-	flag.StringVar(&opts.systemPrompt, "system", "",
-		"custom system prompt")
-	flag.StringVar(&opts.resumeDir, "resume-dir", "",
-		"directory for conversation state (default: current directory)")
-	flag.StringVar(&opts.outputFile, "output-file", "",
-		"write output to file instead of stdout")
-
-	flag.Parse()
+		printUsage(os.Stderr, flag.CommandLine, table)
+	}
+
+	// Subcommands come first: claude index [options], claude prompt
+	// save|list|run [NAME] [options]
+	args := os.Args[1:]
+	switch {
+	case len(args) > 0 && args[0] == "index":
+		opts.index = true
+		args = args[1:]
+	case len(args) > 0 && args[0] == "cron":
+		// claude cron [NAME [PARAM=VALUE...]] [options]
+		opts.cron = true
+		args = args[1:]
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			opts.promptName = args[0]
+			args = args[1:]
+		}
+	case len(args) > 0 && args[0] == "job":
+		// claude job submit|list|attach|cancel [ID] [options]
+		opts.job = true
+		if len(args) > 1 {
+			opts.jobCmd = args[1]
+		}
+		args = args[min(len(args), 2):]
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			opts.jobID = args[0]
+			args = args[1:]
+		}
+	case len(args) > 0 && args[0] == "prompt":
+		opts.promptLib = true
+		if len(args) > 1 {
+			opts.promptCmd = args[1]
+		}
+		args = args[min(len(args), 2):]
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			opts.promptName = args[0]
+			args = args[1:]
+		}
+	}
+	flag.CommandLine.Parse(args)
+	// The NAME=VALUE arguments of claude prompt run may come between flags
+	for (opts.promptLib || opts.cron) && flag.NArg() > 0 {
+		opts.promptArgs = append(opts.promptArgs, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
 
 	return opts
 }
 
-func showStats(claudeDir string) error {
-	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+// cronFlags are the flags of claude cron itself, not passed to the runs
+var cronFlags = map[string]bool{
+	"schedule": true, "cron-format": true, "install": true, "playbook": true,
+}
 
-	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+// cronJob returns the job claude cron schedules: the playbook or saved
+// prompt with the flags given, budgets and output files made explicit
+func cronJob(opts *options, claudeDir string) (*claude.CronJob, error) {
+	exe, err := os.Executable()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("finding the claude binary: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting cwd: %w", err)
+	}
+	job := &claude.CronJob{Schedule: opts.cronSchedule, Dir: dir, Command: []string{exe}}
+	switch {
+	case opts.playbook != "" && opts.promptName != "":
+		return nil, fmt.Errorf("claude cron runs a playbook or a saved prompt, not both")
+	case opts.playbook != "":
+		path, err := filepath.Abs(opts.playbook)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := claude.LoadPlaybook(path); err != nil {
+			return nil, err
+		}
+		job.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		job.Command = append(job.Command, "--playbook="+path)
+	case opts.promptName != "":
+		// Checked now, read at run time so edits to the prompt apply
+		if _, err := claude.PromptRunText(claudeDir, opts.promptName, opts.promptArgs); err != nil {
+			return nil, err
+		}
+		job.Name = opts.promptName
+		job.Command = append(job.Command, "prompt", "run", opts.promptName)
+		job.Command = append(job.Command, opts.promptArgs...)
+	default:
+		return nil, fmt.Errorf("claude cron needs a saved prompt name or --playbook=FILE")
+	}
+	if err := storage.ValidatePromptName(job.Name); err != nil {
+		return nil, fmt.Errorf("claude cron: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Project: %s\n", claudeDir)
-	fmt.Fprintf(os.Stderr, "Model: %s\n", cfg.Model)
-	fmt.Fprintf(os.Stderr, "Total tokens: %d in, %d out\n",
-		cfg.TotalInput, cfg.TotalOutput)
-	fmt.Fprintf(os.Stderr, "Approximate cost: $%.4f\n",
-		float64(cfg.TotalInput)*3.0/1000000+
-			float64(cfg.TotalOutput)*15.0/1000000)
-	fmt.Fprintf(os.Stderr, "Conversation turns: %d\n", len(pairs))
-	fmt.Fprintf(os.Stderr, "First run: %s\n", cfg.FirstRun)
-	fmt.Fprintf(os.Stderr, "Last run: %s\n", cfg.LastRun)
-
-	// Show provider stats
-	claudeReqs := cfg.ClaudeStats.RequestCount
-	ollamaReqs := cfg.OllamaStats.RequestCount
-	totalReqs := claudeReqs + ollamaReqs
-	if totalReqs > 0 {
-		claudePct := float64(claudeReqs) / float64(totalReqs) * 100
-		ollamaPct := float64(ollamaReqs) / float64(totalReqs) * 100
-		fmt.Fprintf(os.Stderr, "\nProvider usage:\n")
-		fmt.Fprintf(os.Stderr, "  Claude: %d requests (%.1f%%)\n", claudeReqs, claudePct)
-		fmt.Fprintf(os.Stderr, "  Ollama: %d requests (%.1f%%)\n", ollamaReqs, ollamaPct)
+	set := make(map[string]bool)
+	flag.CommandLine.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	job.Command = append(job.Command, passedFlags(cronFlags)...)
+
+	// Budgets are explicit unless a profile sets them
+	if !set["profile"] {
+		if !set["max-cost"] {
+			job.Command = append(job.Command, fmt.Sprintf("--max-cost=%g", opts.maxCost))
+		}
+		if !set["max-iterations"] {
+			job.Command = append(job.Command, fmt.Sprintf("--max-iterations=%d", opts.maxIterations))
+		}
+	}
+	// Unattended runs write their answer and diagnostics to files
+	cronDir := filepath.Join(".claude", "cron")
+	if !set["output-file"] {
+		job.Command = append(job.Command, "--output-file="+filepath.Join(cronDir, job.Name+".out"))
+	}
+	if !set["log-file"] {
+		job.Command = append(job.Command, "--log-file="+filepath.Join(cronDir, job.Name+".log"))
+	}
+	if !set["output-file"] || !set["log-file"] {
+		if err := storage.MkdirAll(filepath.Join(claudeDir, "cron"), 0o755); err != nil {
+			return nil, err
+		}
 	}
+	return job, nil
+}
 
-	return nil
+// passedFlags returns the flags given on the command line, but for skip,
+// as arguments for another claude process
+func passedFlags(skip map[string]bool) []string {
+	var args []string
+	flag.CommandLine.Visit(func(f *flag.Flag) {
+		if skip[f.Name] {
+			return
+		}
+		switch v := f.Value.(type) {
+		case *filesFlag:
+			for _, file := range *v {
+				args = append(args, "--"+f.Name+"="+file)
+			}
+		case interface{ IsBoolFlag() bool }:
+			if v.IsBoolFlag() && f.Value.String() == "true" {
+				args = append(args, "--"+f.Name)
+				return
+			}
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		default:
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// jobFlags are the flags of claude job submit not passed to the job: the
+// prompt is saved with it
+var jobFlags = map[string]bool{"c": true}
+
+// jobCommand handles claude job
+func jobCommand(opts *options, claudeDir string) error {
+	needID := func() error {
+		if opts.jobID == "" {
+			return fmt.Errorf("claude job %s needs a job ID (see claude job list)", opts.jobCmd)
+		}
+		return nil
+	}
+	switch opts.jobCmd {
+	case claude.JobSubmit:
+		prompt, err := readPrompt(opts)
+		if err != nil {
+			return err
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("finding the claude binary: %w", err)
+		}
+		job, err := claude.SubmitJob(claudeDir, exe, prompt, passedFlags(jobFlags))
+		if err != nil {
+			return err
+		}
+		fmt.Println(job.ID)
+		fmt.Fprintf(os.Stderr, "Submitted, follow it with: claude job attach %s\n", job.ID)
+		return nil
+	case claude.JobList:
+		return claude.JobListCommand(os.Stdout, claudeDir)
+	case claude.JobAttach:
+		if err := needID(); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return claude.JobAttachCommand(ctx, claudeDir, opts.jobID)
+	case claude.JobCancel:
+		if err := needID(); err != nil {
+			return err
+		}
+		return claude.JobCancelCommand(claudeDir, opts.jobID)
+	case claude.JobRun:
+		if err := needID(); err != nil {
+			return err
+		}
+		opts.semanticSearch = !opts.noSemanticSearch && storage.HasEmbeddingIndex(claudeDir)
+		return claude.RunJob(toClaudeOptions(opts), claudeDir, apiURL, defaultSystemPrompt,
+			opts.jobID, writeOutput)
+	case "":
+		return fmt.Errorf("claude job needs a subcommand: %s, %s, %s or %s",
+			claude.JobSubmit, claude.JobList, claude.JobAttach, claude.JobCancel)
+	}
+	return fmt.Errorf("unknown job subcommand %q (want %s, %s, %s or %s)", opts.jobCmd,
+		claude.JobSubmit, claude.JobList, claude.JobAttach, claude.JobCancel)
+}
+
+// promptCommand handles claude prompt. It returns done when the
+// subcommand is complete; claude prompt run sets the prompt of the turn
+// instead.
+func promptCommand(opts *options, claudeDir string) (done bool, err error) {
+	needName := func() error {
+		if opts.promptName == "" {
+			return fmt.Errorf("claude prompt %s needs a prompt name", opts.promptCmd)
+		}
+		return nil
+	}
+	switch opts.promptCmd {
+	case claude.PromptList:
+		return true, claude.PromptListCommand(os.Stdout, claudeDir)
+	case claude.PromptSave:
+		if err := needName(); err != nil {
+			return true, err
+		}
+		text, err := readPrompt(opts)
+		if err != nil {
+			return true, err
+		}
+		return true, claude.PromptSaveCommand(claudeDir, opts.promptName, text)
+	case claude.PromptRun:
+		if err := needName(); err != nil {
+			return true, err
+		}
+		text, err := claude.PromptRunText(claudeDir, opts.promptName, opts.promptArgs)
+		if err != nil {
+			return true, err
+		}
+		// -c adds to the saved prompt like piped stdin does
+		if opts.prompt != "" {
+			text += "\n\n" + opts.prompt
+		}
+		opts.prompt = text
+		return false, nil
+	case "":
+		return true, fmt.Errorf("claude prompt needs a subcommand: %s, %s or %s",
+			claude.PromptSave, claude.PromptList, claude.PromptRun)
+	}
+	return true, fmt.Errorf("unknown prompt subcommand %q (want %s, %s or %s)",
+		opts.promptCmd, claude.PromptSave, claude.PromptList, claude.PromptRun)
 }
 
 func getClaudeDir(resumeDir string) (string, error) {
@@ -361,9 +1006,45 @@ func getClaudeDir(resumeDir string) (string, error) {
 			return "", fmt.Errorf("getting cwd: %w", err)
 		}
 	}
+	// Absolute so it survives the --isolate chdir
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(dir, ".claude"), nil
 }
 
+// readPrompt returns the user message: the -c prompt, piped stdin, or
+// both with stdin appended to the prompt (cat log | claude -c "why?")
+func readPrompt(opts *options) (string, error) {
+	// Check if stdin is a pipe/redirect, not interactive terminal
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return "", fmt.Errorf("checking stdin: %w", err)
+	}
+	piped := (stat.Mode() & os.ModeCharDevice) == 0
+
+	switch {
+	case opts.prompt == "" && !piped:
+		// Interactive terminal - no input piped
+		flag.Usage()
+		return "", fmt.Errorf("no input provided (pipe, redirect or -c PROMPT required)")
+	case opts.prompt == "":
+		return readInput()
+	case !piped:
+		return opts.prompt, nil
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+	if len(input) == 0 {
+		return opts.prompt, nil
+	}
+	return opts.prompt + "\n\n" + string(input), nil
+}
+
 func readInput() (string, error) {
 	input, err := io.ReadAll(os.Stdin)
 	if err != nil {
@@ -391,13 +1072,13 @@ func writeOutput(outputFile string, jsonOutput bool,
 	switch {
 	case outputFile != "":
 		// Never write escape codes to files
-		err := os.WriteFile(outputFile, []byte(output), 0o644)
+		err := storage.WriteFile(outputFile, []byte(output), 0o644)
 		if err != nil {
 			return fmt.Errorf("writing output file: %w", err)
 		}
 	default:
 		// FormatResponse handles TTY check and chroma highlighting
-		if !jsonOutput && display.IsTTY(os.Stdout) {
+		if !jsonOutput && display.UseColor(os.Stdout) {
 			display.FormatResponse(os.Stdout, output)
 		} else {
 			if strings.HasSuffix(output, "\n") {
@@ -412,13 +1093,11 @@ func writeOutput(outputFile string, jsonOutput bool,
 	return nil
 }
 
-func resetConversation(claudeDir string, verbose bool) error {
-	if err := os.RemoveAll(claudeDir); err != nil {
+func resetConversation(claudeDir string) error {
+	if err := storage.RemoveAll(claudeDir); err != nil {
 		return fmt.Errorf("removing %s: %w", claudeDir, err)
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Reset: removed %s\n", claudeDir)
-	}
+	slog.Info("reset", "removed", claudeDir)
 	return nil
 }
 
@@ -435,6 +1114,8 @@ type options struct {
 	tool           string
 	output         string
 	systemPrompt   string
+	systemFiles    filesFlag
+	prefill        string
 	resumeDir      string
 	outputFile     string
 	replay         string
@@ -449,6 +1130,184 @@ type options struct {
 	preferLocal    bool
 	allowFallback  bool
 	maxClaudeRatio float64
+	provider       string
+
+	replayOnly        string
+	replayToolIDs     string
+	replayInteractive bool
+
+	history    bool
+	showTurn   string
+	transcript string
+	compare    string
+
+	compareModels string
+	compareDir    string
+
+	// claude prompt save|list|run NAME [NAME=VALUE...], and the prompt of
+	// claude cron
+	promptLib  bool
+	promptCmd  string
+	promptName string
+	promptArgs []string
+
+	// claude job submit|list|attach|cancel [ID]
+	job    bool
+	jobCmd string
+	jobID  string
+
+	// claude cron
+	cron         bool
+	cronSchedule string
+	cronFormat   string
+	cronInstall  bool
+
+	profile string
+
+	proxy              string
+	caCert             string
+	insecureSkipVerify bool
+
+	logFile   string
+	logFormat string
+
+	summaryJSON string
+	githubPR    int
+
+	postWebhook   string
+	webhookFormat string
+
+	ci          bool
+	temperature *float64
+
+	verify string
+
+	validate       bool
+	maxFixAttempts int
+	formatWrites   bool
+
+	bestOf int
+	judge  string
+
+	compressResults int
+	compressModel   string
+
+	projectContext bool
+
+	wait   time.Duration
+	noLock bool
+
+	recover  bool
+	finalize bool
+	discard  bool
+
+	prompt string
+	last   bool
+
+	playbook string
+
+	notify      bool
+	notifyAfter time.Duration
+
+	ollamaAutoPull bool
+
+	stage bool
+
+	plan        bool
+	planApprove bool
+	steer       bool
+	watch       bool
+
+	exportSession string
+	importSession string
+	importTrusted bool
+
+	gc              bool
+	gcMaxAge        int
+	gcMaxSize       int
+	gcCompressAfter int
+
+	reindex bool
+
+	fsck       bool
+	quarantine bool
+
+	forceTool       string
+	sequentialTools bool
+
+	images string
+
+	index            bool
+	embedProvider    string
+	embedModel       string
+	embedURL         string
+	noSemanticSearch bool
+	semanticSearch   bool // set when .claude/index exists
+
+	rag       int
+	ragTokens int
+
+	tag string
+
+	isolate bool
+
+	showSystem bool
+
+	noFooter bool
+
+	color string
+
+	diffContext int
+	diffView    string
+
+	pendingPatch bool
+
+	fork    string
+	forkDir string
+
+	dropLast int
+	force    bool
+
+	amend     bool
+	amendTurn string // timestamp of the turn --amend replaces
+
+	serve    bool
+	connect  bool
+	sessions bool
+	socket   string
+
+	openAIProxy string
+	instruction string
+
+	helpFull bool
+	man      bool
+
+	toolBudget budgetsFlag
+
+	fallbackChain   string
+	fallbackOn      string
+	fallbackRetries int
+
+	apiURL string
+
+	record     string
+	replayHTTP string
+
+	explainRouting bool
+
+	turnModel string // from a prompt directive, not a flag
+
+	envContext bool
+
+	spendAlerts alertsFlag
+	dailyBudget float64
+
+	usageExport bool
+	usageFrom   string
+	usageTo     string
+
+	readOnly bool
+	noSave   bool
 }
 
 func (o *options) isVerbose() bool {
@@ -0,0 +1,275 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// agent.go - Sub-agents (the spawn_agent tool, policy.json agents)
+//
+// With agents enabled in .claude/policy.json the model can hand a
+// self-contained task, "explore the repo and report how errors are
+// handled", to a sub-agent: a conversation of its own that starts from the
+// task alone, runs the tools the policy and the turn's --tool both allow,
+// and is bounded by the policy's cost and iteration caps. Its report comes
+// back as the tool result, so the exploration doesn't fill the context of
+// the turn. Nothing of the sub-agent is saved as a turn; its tool calls
+// are audited under the turn it works for, and its tokens and cost count
+// toward that turn and its --max-cost. Sub-agents can't spawn their own.
+
+const (
+	// DefaultAgentMaxCost is the cost cap of a sub-agent without a policy cap
+	DefaultAgentMaxCost = 0.25 // dollars
+	// DefaultAgentMaxIterations is the iteration cap without a policy cap
+	DefaultAgentMaxIterations = 10
+)
+
+// agentPrompt is the default system prompt of a sub-agent
+const agentPrompt = `You are a sub-agent of a coding assistant. ` +
+	`Work on the task you are given with the tools you have.`
+
+// agentReport ends the task of every sub-agent: the caller sees nothing else
+const agentReport = "\n\nWhen you are done, reply with a concise report of what you " +
+	"found or did, with file paths and line numbers where they help. The report " +
+	"is all the caller sees of your work."
+
+// agentPolicy is the agents section of the policy, defaults applied
+var agentPolicy = storage.AgentPolicy{
+	Tool:          ToolRead,
+	MaxCost:       DefaultAgentMaxCost,
+	MaxIterations: DefaultAgentMaxIterations,
+}
+
+// ConfigureAgents loads the sub-agent policy of .claude/policy.json
+func ConfigureAgents(claudeDir string) error {
+	policy, err := storage.LoadPolicy(claudeDir)
+	if err != nil {
+		return err
+	}
+	a := policy.Agents
+	if a.MaxCost < 0 || a.MaxIterations < 0 {
+		return fmt.Errorf("policy agents: caps must not be negative")
+	}
+	if a.Tool == "" {
+		a.Tool = ToolRead
+	} else if !validAgentTool(a.Tool) {
+		return fmt.Errorf("policy agents: invalid tool %q (want read, write, command or all)",
+			a.Tool)
+	}
+	if a.MaxCost == 0 {
+		a.MaxCost = DefaultAgentMaxCost
+	}
+	if a.MaxIterations == 0 {
+		a.MaxIterations = DefaultAgentMaxIterations
+	}
+	agentPolicy = a
+	return nil
+}
+
+// validAgentTool reports whether mode is a --tool mode a sub-agent can run
+// with, e.g. "read" or "write,command"
+func validAgentTool(mode string) bool {
+	for _, m := range strings.Split(mode, ",") {
+		switch m {
+		case ToolRead, ToolWrite, ToolCommand, ToolAll:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// agentTool returns the --tool mode of a sub-agent asking for want: what
+// want, the turn's mode and the policy's all allow
+func agentTool(want, turn, policy string) string {
+	write, command := true, true
+	for _, mode := range []string{want, turn, policy} {
+		o := &Options{Tool: mode}
+		write = write && o.CanExecuteWrite()
+		command = command && o.CanExecuteCommand()
+	}
+	switch {
+	case write && command:
+		return ToolWrite + "," + ToolCommand
+	case write:
+		return ToolWrite
+	case command:
+		return ToolCommand
+	}
+	return ToolRead
+}
+
+// remainingCost returns what is left of --max-cost for a sub-agent: the
+// turn's spend before its tool calls and the sub-agents they already ran
+// count against it
+func (o *Options) remainingCost() float64 {
+	left := o.MaxCost - o.turnCost
+	for _, run := range o.agentRuns {
+		left -= run.Cost
+	}
+	return left
+}
+
+// ephemeral reports whether the session saves nothing of its turn: with
+// --read-only and --no-save, and for sub-agents, whose work is a tool
+// result of the turn they work for
+func (s *session) ephemeral() bool {
+	return storage.Ephemeral() || s.opts.agentOf != ""
+}
+
+// recordAgents adds the sub-agents the last tool calls ran to the summary
+// and the provider stats, and returns what they cost
+func (s *session) recordAgents() float64 {
+	cost := 0.0
+	for _, run := range s.opts.agentRuns {
+		s.summary.InputTokens += run.InputTokens
+		s.summary.OutputTokens += run.OutputTokens
+		s.summary.Cost += run.Cost
+		for tool, n := range run.ToolsExecuted {
+			if s.summary.ToolsExecuted == nil {
+				s.summary.ToolsExecuted = make(map[string]int)
+			}
+			s.summary.ToolsExecuted[tool] += n
+		}
+		for _, path := range run.FilesChanged {
+			if !containsString(s.summary.FilesChanged, path) {
+				s.summary.FilesChanged = append(s.summary.FilesChanged, path)
+			}
+		}
+		s.config.TotalInput += run.InputTokens
+		s.config.TotalOutput += run.OutputTokens
+		storage.UpdateProviderStats(s.config, run.Provider, run.InputTokens, run.OutputTokens)
+		cost += run.Cost
+	}
+	s.opts.agentRuns = nil
+	return cost
+}
+
+// spawnAgentTool runs a task in a sub-agent (spawn_agent)
+type spawnAgentTool struct{}
+
+func (spawnAgentTool) Name() string { return "spawn_agent" }
+
+func (spawnAgentTool) Schema() Tool {
+	return Tool{
+		Name: "spawn_agent",
+		Description: "Hand a self-contained task, e.g. \"explore the repo and report " +
+			"how errors are handled\", to a sub-agent: a separate conversation with " +
+			"its own tools and budget that returns a report of its work. The " +
+			"sub-agent sees none of this conversation, so the task must say " +
+			"everything it needs to know.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"task": map[string]string{
+					"type":        "string",
+					"description": "What the sub-agent should do and report",
+				},
+				"tool": map[string]string{
+					"type": "string",
+					"description": "What the sub-agent may do: read (default), write, " +
+						"command, or all; capped by the policy and this session",
+				},
+				"max_iterations": map[string]string{
+					"type": "integer",
+					"description": fmt.Sprintf("iterations the sub-agent may take "+
+						"(default and max %d)", agentPolicy.MaxIterations),
+				},
+			},
+			"required": []string{"task"},
+		},
+	}
+}
+
+func (spawnAgentTool) Available(opts *Options) bool {
+	return agentPolicy.Enabled && opts.agentOf == ""
+}
+
+func (spawnAgentTool) Execute(ctx context.Context, call ToolCall) (ContentBlock, error) {
+	startTime := time.Now()
+	toolUse := call.Use
+
+	task, ok := toolUse.Input["task"].(string)
+	if !ok || strings.TrimSpace(task) == "" {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+			toolUse.Input, "task must be a non-empty string",
+			call.ConversationID, startTime)
+	}
+	want, _ := toolUse.Input["tool"].(string)
+	if want == "" {
+		want = ToolRead
+	}
+	if !validAgentTool(want) {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+			toolUse.Input, "tool must be read, write, command or all",
+			call.ConversationID, startTime)
+	}
+	maxIter := min(intInput(toolUse.Input, "max_iterations", agentPolicy.MaxIterations),
+		agentPolicy.MaxIterations)
+	if maxIter <= 0 {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+			toolUse.Input, "max_iterations must be > 0", call.ConversationID, startTime)
+	}
+
+	// The sub-agent runs like the turn, but for what is of the turn's
+	// prompt; --output=patch records its writes in the turn's patch
+	sub := *call.Opts
+	sub.agentOf = call.ConversationID
+	sub.Tool = agentTool(want, call.Opts.Tool, agentPolicy.Tool)
+	sub.MaxIterations = maxIter
+	sub.MaxCost = agentPolicy.MaxCost
+	if call.Opts.MaxCost > 0 {
+		left := call.Opts.remainingCost()
+		if left <= 0 {
+			return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+				toolUse.Input, fmt.Sprintf("no budget left for a sub-agent: the turn spent "+
+					"its --max-cost of $%.4f", call.Opts.MaxCost),
+				call.ConversationID, startTime)
+		}
+		sub.MaxCost = min(sub.MaxCost, left)
+	}
+	if agentPolicy.Model != "" {
+		sub.Model, sub.TurnModel, sub.MaxTokens = agentPolicy.Model, "", 0
+	}
+	sub.Images, sub.Prefill, sub.ForceTool, sub.Amend = nil, "", "", ""
+	sub.BestOf, sub.RAG, sub.ExplainRouting = 0, 0, false
+	if sub.Output != OutputPatch {
+		sub.Output = OutputText
+	}
+
+	sess, err := InitSession(&sub, call.ClaudeDir, call.Opts.apiURL, agentPrompt)
+	if err != nil {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+			toolUse.Input, fmt.Sprintf("starting sub-agent: %v", err),
+			call.ConversationID, startTime)
+	}
+	result, err := ExecuteConversation(sess, task+agentReport)
+	summary := sess.Summary(err)
+	call.Opts.agentRuns = append(call.Opts.agentRuns, summary)
+	if err != nil {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "spawn_agent",
+			toolUse.Input, fmt.Sprintf("sub-agent failed after %d iterations ($%.4f): %v",
+				summary.Iterations, summary.Cost, err),
+			call.ConversationID, startTime)
+	}
+
+	logAuditEntry(call.ClaudeDir, "spawn_agent", toolUse.Input, map[string]interface{}{
+		"success":    true,
+		"model":      summary.Model,
+		"tool":       sub.Tool,
+		"iterations": summary.Iterations,
+		"cost":       summary.Cost,
+	}, true, call.ConversationID, startTime, false)
+
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUse.ID,
+		Content: fmt.Sprintf("Sub-agent report (%s, --tool=%s, %d iterations, $%.4f):\n\n%s",
+			summary.Model, sub.Tool, summary.Iterations, summary.Cost,
+			result.AssistantText()),
+	}, nil
+}
@@ -0,0 +1,166 @@
+package claude_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// explorerFake reads main.go and reports on it
+const explorerFake = `{"responses": [
+	{"content": [{"type": "tool_use", "name": "read_file", "input": {"path": "main.go"}}],
+	 "usage": {"input_tokens": 50, "output_tokens": 5}},
+	{"text": "main.go has one function.", "usage": {"input_tokens": 70, "output_tokens": 7}}
+]}`
+
+func hasTool(tools []claude.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSpawnAgent(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		"main.go":                     "package main\n\nfunc main() {}\n",
+		".claude/fakes/explorer.json": explorerFake,
+		".claude/policy.json":         `{"agents": {"enabled": true, "tool": "write", "model": "fake:explorer"}}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+	if err := claude.ConfigureAgents(claudeDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { claude.ConfigureAgents(t.TempDir()) })
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SetTool(claude.ToolRead)
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{
+		toolUseResponse("toolu_agent", "spawn_agent", map[string]interface{}{
+			"task": "explore the repo and report", "tool": "all",
+		}),
+		textResponse("The repo is one empty main.", "end_turn"),
+	}}
+	sess.SetLLM(mock)
+	if _, err := claude.ExecuteConversation(sess, "what is in this repo?"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasTool(mock.requests[0].Tools, "spawn_agent") {
+		t.Error("spawn_agent not offered with agents enabled")
+	}
+	// The turn only reads, whatever the policy and the task allow
+	report := lastContent(mock.requests[1])
+	for _, want := range []string{"fake:explorer", "--tool=read", "2 iterations", "main.go has one function."} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	summary := sess.Summary(nil)
+	if summary.InputTokens < 120 || summary.OutputTokens < 12 {
+		t.Errorf("summary = %d/%d tokens, want the sub-agent's included",
+			summary.InputTokens, summary.OutputTokens)
+	}
+	if summary.ToolsExecuted["spawn_agent"] != 1 || summary.ToolsExecuted["read_file"] != 1 {
+		t.Errorf("tools executed = %v", summary.ToolsExecuted)
+	}
+
+	// Only the turn is saved, and its journal is gone
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil || len(pairs) != 1 {
+		t.Errorf("turns = %v, %v, want the one", pairs, err)
+	}
+	if journals, _ := filepath.Glob(filepath.Join(claudeDir, "journal_*.json")); len(journals) != 0 {
+		t.Errorf("journals left: %v", journals)
+	}
+	// The sub-agent's tool calls are audited under the turn
+	audit, err := storage.LoadAuditLog(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := false
+	for _, e := range audit {
+		read = read || (e.Tool == "read_file" && len(pairs) == 1 && e.ConversationID == pairs[0])
+	}
+	if !read {
+		t.Errorf("no read_file of the turn in the audit log: %+v", audit)
+	}
+}
+
+func TestSpawnAgentPolicy(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/policy.json": `{"agents": {"enabled": true, "tool": "sudo"}}`,
+	})
+	t.Chdir(dir)
+	t.Cleanup(func() { claude.ConfigureAgents(t.TempDir()) })
+
+	if err := claude.ConfigureAgents(claudeDir); err == nil {
+		t.Error("policy with tool sudo accepted")
+	}
+	if err := claude.ConfigureAgents(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolAll)
+	if hasTool(claude.GetTools(opts), "spawn_agent") {
+		t.Error("spawn_agent offered without a policy")
+	}
+}
+
+func TestSpawnAgentMaxCost(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		"main.go":                     "package main\n",
+		".claude/fakes/explorer.json": explorerFake,
+		".claude/policy.json":         `{"agents": {"enabled": true, "model": "fake:explorer", "max_cost": 5}}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+	if err := claude.ConfigureAgents(claudeDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { claude.ConfigureAgents(t.TempDir()) })
+
+	// The call asking for the sub-agents spends the whole --max-cost
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SetTool(claude.ToolRead)
+	opts.MaxCost = claude.UsageCost(claude.DefaultModel, 100_000, 0)
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn := toolUseResponse("toolu_agent", "spawn_agent", map[string]interface{}{
+		"task": "explore the repo and report",
+	})
+	spawn.Usage = llm.Usage{InputTokens: 100_000}
+	mock := &scriptedLLM{responses: []*llm.Response{spawn, textResponse("done", "end_turn")}}
+	sess.SetLLM(mock)
+	if _, err := claude.ExecuteConversation(sess, "what is in this repo?"); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := lastContent(mock.requests[1]); !strings.Contains(result, "no budget left for a sub-agent") {
+		t.Errorf("spawn_agent result = %q, want it refused", result)
+	}
+	if summary := sess.Summary(nil); summary.ToolsExecuted["read_file"] != 0 {
+		t.Errorf("sub-agent ran over the turn's budget: %v", summary.ToolsExecuted)
+	}
+}
@@ -0,0 +1,121 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// amend.go - Edit and resend the last prompt (--amend)
+//
+// The prompt of the last turn is opened in $VISUAL or $EDITOR and the
+// edited text is sent as a new turn that continues from the turns before
+// it. Once the new turn is saved the amended one is dropped, so a failed
+// resend leaves the history as it was.
+
+// DefaultEditor edits prompts when neither $VISUAL nor $EDITOR is set
+const DefaultEditor = "vi"
+
+// AmendPrompt opens the prompt of the last turn in the editor and returns
+// the edited prompt and the timestamp of the turn it replaces
+func AmendPrompt(claudeDir string) (string, string, error) {
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+	if len(pairs) == 0 {
+		return "", "", fmt.Errorf("no previous prompt to amend")
+	}
+	ts := pairs[len(pairs)-1]
+	req, err := storage.LoadRequest(filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", ts)))
+	if err != nil {
+		return "", "", fmt.Errorf("turn %s: %w", ts, err)
+	}
+	prompt, err := GetLastUserMessage(req.Messages)
+	if err != nil {
+		return "", "", fmt.Errorf("turn %s: %w", ts, err)
+	}
+
+	edited, err := editText(prompt)
+	if err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(edited) == "" {
+		return "", "", fmt.Errorf("empty prompt, turn %s not amended", ts)
+	}
+	return strings.TrimRight(edited, "\n"), ts, nil
+}
+
+// editText lets the user edit text in their editor and returns the result
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "claude-amend-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = DefaultEditor
+	}
+	// Through the shell so EDITOR may carry arguments, like "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	// stdin is usually a pipe; the editor needs the terminal
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin, cmd.Stdout = tty, tty
+	}
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// loadHistory loads the conversation a turn with opts continues: every
+// saved turn, but for the one --amend replaces. With --read-only or
+// --no-save claudeDir may not exist: then there is none, as for a
+// sub-agent.
+func loadHistory(claudeDir string, opts *Options) ([]MessageContent, error) {
+	if opts.agentOf != "" {
+		return nil, nil // a sub-agent starts from its task alone
+	}
+	if _, err := os.Stat(claudeDir); storage.Ephemeral() && os.IsNotExist(err) {
+		return nil, nil
+	}
+	if opts.Amend != "" {
+		return storage.LoadConversationHistoryBefore(claudeDir, opts.Amend)
+	}
+	return storage.LoadConversationHistory(claudeDir)
+}
+
+// dropAmended drops the turn --amend replaced, now that the new one is
+// saved
+func dropAmended(claudeDir string, opts *Options) error {
+	if opts.Amend == "" {
+		return nil
+	}
+	if err := storage.DropTurn(claudeDir, opts.Amend); err != nil {
+		return fmt.Errorf("dropping amended turn %s: %w", opts.Amend, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestAmend(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("sed not installed")
+	}
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+	for ts, prompt := range map[string]string{
+		"20260101_100000": "first question",
+		"20260101_110000": "second qeustion",
+	} {
+		msg := storage.MessageContent{Role: "user",
+			Content: []storage.ContentBlock{{Type: "text", Text: prompt}}}
+		if err := storage.SaveRequest(claudeDir, ts, []storage.MessageContent{msg}); err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal([]storage.APIResponse{{Role: "assistant",
+			Content:    []storage.ContentBlock{{Type: "text", Text: "answer to " + prompt}},
+			StopReason: "end_turn"}})
+		if err := storage.SaveResponse(claudeDir, ts, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/qeustion/question/")
+	prompt, ts, err := claude.AmendPrompt(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt != "second question" || ts != "20260101_110000" {
+		t.Fatalf("AmendPrompt = %q, %q", prompt, ts)
+	}
+
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("better", "end_turn")}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Amend = ts
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.SetLLM(mock)
+	result, err := claude.ExecuteConversation(sess, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(string, bool, string, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The resend continues from the first turn only
+	if n := len(mock.requests[0].Messages); n != 3 {
+		t.Errorf("resend sent %d messages, want the first turn and the prompt", n)
+	}
+	pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+	if len(pairs) != 2 || pairs[0] != "20260101_100000" || pairs[1] == ts {
+		t.Errorf("turns after amend = %v, want the first and the new one", pairs)
+	}
+	history, err := storage.LoadConversationHistory(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history[len(history)-2]; last.Content[0].Text != "second question" {
+		t.Errorf("history prompt = %q, want the amended one", last.Content[0].Text)
+	}
+
+	// An empty edit cancels
+	t.Setenv("EDITOR", "truncate -s 0")
+	if _, _, err := claude.AmendPrompt(claudeDir); err == nil {
+		t.Errorf("empty amend succeeded")
+	}
+}
@@ -0,0 +1,335 @@
+package claude
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// fileChange is a single write_file operation collected into a write plan.
+type fileChange struct {
+	toolUse ContentBlock
+	path    string
+	content string
+	old     []byte
+	existed bool
+	mode    os.FileMode
+	errMsg  string // validation error, empty if the change is valid
+	note    string // of --format-writes, for the tool result
+
+	replacedBy *fileChange // a later write to the same path, applied instead
+}
+
+// writePlan groups consecutive write_file calls of one assistant turn so
+// they can be previewed together and applied all-or-nothing.
+type writePlan struct {
+	changes  []*fileChange
+	replaced []*fileChange // earlier writes to the paths of changes
+	created  []string      // directories apply made, deepest first
+}
+
+// newWritePlan collects and validates every write_file block in content.
+// Of several writes to one path only the last is kept, as if they ran one
+// after the other.
+func newWritePlan(content []ContentBlock, workingDir string) *writePlan {
+	plan := &writePlan{}
+	byPath := make(map[string]*fileChange)
+	for _, block := range content {
+		if block.Type != "tool_use" || block.Name != "write_file" {
+			continue
+		}
+
+		fc := &fileChange{toolUse: block, mode: 0o644}
+		path, ok := block.Input["path"].(string)
+		content, okContent := block.Input["content"].(string)
+		switch {
+		case !ok:
+			fc.errMsg = "path must be a string"
+		case !okContent:
+			fc.errMsg = "content must be a string"
+		default:
+			fc.errMsg = pathError(path, workingDir, true)
+		}
+		fc.path = path
+		fc.content = content
+
+		if fc.errMsg == "" {
+			if fi, err := os.Stat(path); err == nil {
+				if fi.IsDir() {
+					fc.errMsg = fmt.Sprintf("path is a directory: %s", path)
+				} else {
+					fc.existed = true
+					fc.mode = fi.Mode().Perm()
+					fc.old, err = os.ReadFile(path)
+					if err != nil {
+						fc.errMsg = err.Error()
+					}
+				}
+			}
+		}
+		if fc.errMsg == "" {
+			complete, _ := block.Input["complete"].(bool)
+			fc.errMsg = writeContentError(path, content, fc.old, complete)
+		}
+
+		if abs, err := filepath.Abs(path); ok && err == nil {
+			if prev := byPath[abs]; prev != nil && prev.errMsg == "" {
+				prev.replacedBy = fc
+				plan.replaced = append(plan.replaced, prev)
+				plan.changes = slices.DeleteFunc(plan.changes,
+					func(c *fileChange) bool { return c == prev })
+			}
+			byPath[abs] = fc
+		}
+		plan.changes = append(plan.changes, fc)
+	}
+	return plan
+}
+
+// size is the number of write_file calls in the plan
+func (p *writePlan) size() int {
+	return len(p.changes) + len(p.replaced)
+}
+
+// valid reports whether every change in the plan passed validation.
+func (p *writePlan) valid() bool {
+	for _, fc := range p.changes {
+		if fc.errMsg != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// show prints the aggregate diff of the plan.
+func (p *writePlan) show(dryRun bool) {
+	ToolHeader(fmt.Sprintf("write plan (%d files)", len(p.changes)), dryRun)
+	for _, fc := range p.changes {
+		if fc.errMsg != "" {
+			fmt.Fprintf(os.Stderr, "  ✗ %s: %s\n", fc.path, fc.errMsg)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n", fc.path)
+	}
+	for _, fc := range p.changes {
+		if fc.errMsg != "" {
+			continue
+		}
+		ToolHeader(fc.path, dryRun)
+		ShowDiff(string(fc.old), fc.content)
+	}
+}
+
+// apply writes every change atomically. All contents are first staged into
+// temp files next to their targets, then renamed into place. If any step
+// fails, files that were already replaced get their old contents back from
+// memory, and new files and the directories made for them are removed, so
+// the tree is never left half-edited.
+func (p *writePlan) apply(claudeDir, conversationID string) error {
+	// Back up originals before touching anything; --no-save keeps
+	// nothing, rollback restores from memory anyway
+	for _, fc := range p.changes {
+		if !fc.existed || storage.Ephemeral() {
+			continue
+		}
+		if _, err := storage.SaveBackup(claudeDir, conversationID, fc.path, fc.old); err != nil {
+			return fmt.Errorf("backup %s: %w", fc.path, err)
+		}
+	}
+
+	// Phase 1: stage all contents into temp files
+	staged := make([]string, len(p.changes))
+	cleanup := func() {
+		for _, tmp := range staged {
+			if tmp != "" {
+				storage.Remove(tmp)
+			}
+		}
+		p.removeCreated()
+	}
+	for i, fc := range p.changes {
+		if dir := filepath.Dir(fc.path); dir != "" {
+			missing := missingDirs(dir)
+			err := storage.MkdirAll(dir, 0o755)
+			p.created = append(missing, p.created...)
+			if err != nil {
+				cleanup()
+				return fmt.Errorf("create dir for %s: %w", fc.path, err)
+			}
+		}
+		tmp := fc.path + ".claude-tmp"
+		if err := storage.WriteFile(tmp, []byte(fc.content), fc.mode); err != nil {
+			cleanup()
+			return fmt.Errorf("stage %s: %w", fc.path, err)
+		}
+		staged[i] = tmp
+	}
+
+	// Phase 2: rename into place, rolling back on first failure
+	for i, fc := range p.changes {
+		if err := storage.Rename(staged[i], fc.path); err != nil {
+			p.rollback(i)
+			cleanup()
+			return fmt.Errorf("apply %s: %w", fc.path, err)
+		}
+		staged[i] = ""
+	}
+
+	return nil
+}
+
+// missingDirs returns dir and those of its parents that don't exist yet,
+// deepest first
+func missingDirs(dir string) []string {
+	var missing []string
+	for ; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil || !os.IsNotExist(err) {
+			return missing
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			return missing
+		}
+	}
+}
+
+// removeCreated removes the directories apply made. Those something else
+// was put in stay.
+func (p *writePlan) removeCreated() {
+	for _, dir := range p.created {
+		if err := storage.Remove(dir); err != nil && !os.IsNotExist(err) {
+			slog.Warn("rollback failed", "path", dir, "err", err)
+		}
+	}
+	p.created = nil
+}
+
+// rollback restores the first n changes to their pre-apply state.
+func (p *writePlan) rollback(n int) {
+	for _, fc := range p.changes[:n] {
+		var err error
+		if fc.existed {
+			err = storage.WriteFile(fc.path, fc.old, fc.mode)
+		} else {
+			err = storage.Remove(fc.path)
+		}
+		if err != nil {
+			slog.Warn("rollback failed", "path", fc.path, "err", err)
+		}
+	}
+}
+
+// executeWritePlan previews and applies all write_file calls in plan as a
+// single transaction. Returns tool results keyed by tool_use ID.
+func executeWritePlan(plan *writePlan, claudeDir string, opts *Options,
+	conversationID string,
+) map[string]ContentBlock {
+	startTime := time.Now()
+	results := make(map[string]ContentBlock, plan.size())
+	dryRun := !opts.CanExecuteWrite()
+
+	// A replaced write shares the outcome of the one replacing it
+	defer func() {
+		for _, fc := range plan.replaced {
+			last := fc.replacedBy
+			for last.replacedBy != nil {
+				last = last.replacedBy
+			}
+			failed := strings.HasPrefix(results[last.toolUse.ID].Content, "Error: ")
+			id := fc.toolUse.ID
+			if failed {
+				results[id], _ = makeToolError(id, fmt.Sprintf(
+					"not applied: replaced by a later write_file to %s, which failed", fc.path))
+			} else {
+				results[id] = ContentBlock{
+					Type:      "tool_result",
+					ToolUseID: id,
+					Content: fmt.Sprintf("Not applied: replaced by a later write_file "+
+						"to %s in this response", fc.path),
+				}
+			}
+			logAuditEntry(claudeDir, "write_file", fc.toolUse.Input, map[string]interface{}{
+				"replaced": true,
+				"path":     fc.path,
+			}, !failed, conversationID, startTime, dryRun)
+		}
+	}()
+
+	for _, fc := range plan.changes {
+		if fc.errMsg == "" {
+			fc.content, fc.note = formatWrite(fc.path, fc.content, opts)
+			opts.recordPreWrite(fc.toolUse.ID, fc.old, fc.existed)
+		}
+	}
+
+	if !opts.IsSilent() {
+		plan.show(dryRun)
+	}
+
+	if !plan.valid() {
+		for _, fc := range plan.changes {
+			errMsg := fc.errMsg
+			if errMsg == "" {
+				errMsg = "not applied: another file in this write plan is invalid"
+			}
+			logAuditEntry(claudeDir, "write_file", fc.toolUse.Input, map[string]interface{}{
+				"error": errMsg,
+			}, false, conversationID, startTime, false)
+			results[fc.toolUse.ID], _ = makeToolError(fc.toolUse.ID, errMsg)
+		}
+		return results
+	}
+
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "(dry-run: use --tool=write to apply)\n\n")
+		for _, fc := range plan.changes {
+			opts.recordPending(fc.path, fc.content)
+			logAuditEntry(claudeDir, "write_file", fc.toolUse.Input, map[string]interface{}{
+				"dry_run": true,
+				"path":    fc.path,
+				"size":    len(fc.content),
+			}, true, conversationID, startTime, true)
+			results[fc.toolUse.ID] = ContentBlock{
+				Type:      "tool_result",
+				ToolUseID: fc.toolUse.ID,
+				Content: "Dry-run: changes not applied. " +
+					"Use --tool=write flag.",
+			}
+		}
+		return results
+	}
+
+	slog.Info("tool", "name", "write_file", "plan_files", len(plan.changes))
+
+	if err := plan.apply(claudeDir, conversationID); err != nil {
+		errMsg := fmt.Sprintf("write plan rolled back, no files changed: %v", err)
+		for _, fc := range plan.changes {
+			logAuditEntry(claudeDir, "write_file", fc.toolUse.Input, map[string]interface{}{
+				"error": errMsg,
+			}, false, conversationID, startTime, false)
+			results[fc.toolUse.ID], _ = makeToolError(fc.toolUse.ID, errMsg)
+		}
+		return results
+	}
+
+	for _, fc := range plan.changes {
+		logAuditEntry(claudeDir, "write_file", fc.toolUse.Input, map[string]interface{}{
+			"success": true,
+			"path":    fc.path,
+			"size":    len(fc.content),
+		}, true, conversationID, startTime, false)
+		results[fc.toolUse.ID] = ContentBlock{
+			Type:      "tool_result",
+			ToolUseID: fc.toolUse.ID,
+			Content: fmt.Sprintf("Successfully wrote to %s (%d-file plan)",
+				fc.path, len(plan.changes)) + fc.note,
+		}
+	}
+	return results
+}
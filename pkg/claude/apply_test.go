@@ -0,0 +1,278 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func writeBlock(id, path, content string) claude.ContentBlock {
+	return claude.ContentBlock{
+		Type: "tool_use",
+		ID:   id,
+		Name: "write_file",
+		Input: map[string]interface{}{
+			"path":    path,
+			"content": content,
+		},
+	}
+}
+
+// TestWritePlanAppliesAllFiles verifies multiple writes in one turn are applied together
+func TestWritePlanAppliesAllFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	if err := os.WriteFile(a, []byte("old a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", a, "new a\n"),
+		writeBlock("t2", b, "new b\n"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for i, id := range []string{"t1", "t2"} {
+		if results[i].ToolUseID != id {
+			t.Errorf("result %d: expected tool_use_id %s, got %s", i, id, results[i].ToolUseID)
+		}
+		if strings.HasPrefix(results[i].Content, "Error") {
+			t.Errorf("result %d: unexpected error: %s", i, results[i].Content)
+		}
+	}
+
+	for path, want := range map[string]string{a: "new a\n", b: "new b\n"} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+
+	// Original of a.txt must be backed up
+	backups, err := filepath.Glob(filepath.Join(claudeDir, "backups", "20260105_120000", "*a.txt"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup of a.txt, got %v (%v)", backups, err)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "old a\n" {
+		t.Errorf("backup content wrong: %q", data)
+	}
+}
+
+// TestWritePlanAllOrNothing verifies a failing write leaves every file untouched
+func TestWritePlanAllOrNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+
+	a := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(a, []byte("old a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// "sub" is a file, so writing sub/b.txt must fail
+	if err := os.WriteFile(filepath.Join(tmpDir, "sub"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", a, "new a\n"),
+		writeBlock("t2", filepath.Join(tmpDir, "sub", "b.txt"), "new b\n"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	for i, r := range results {
+		if !strings.Contains(r.Content, "rolled back") {
+			t.Errorf("result %d: expected rollback error, got %q", i, r.Content)
+		}
+	}
+
+	got, _ := os.ReadFile(a)
+	if string(got) != "old a\n" {
+		t.Errorf("a.txt should be untouched, got %q", got)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(tmpDir, "*.claude-tmp")); len(leftovers) != 0 {
+		t.Errorf("staged temp files left behind: %v", leftovers)
+	}
+}
+
+// TestWritePlanInvalidPathRejectsPlan verifies one unsafe path blocks the whole plan
+func TestWritePlanInvalidPathRejectsPlan(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.txt")
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", a, "new a\n"),
+		writeBlock("t2", "/etc/evil", "boom"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	if !strings.Contains(results[0].Content, "not applied") {
+		t.Errorf("expected valid write to be held back, got %q", results[0].Content)
+	}
+	if !strings.Contains(results[1].Content, "outside project") {
+		t.Errorf("expected path error, got %q", results[1].Content)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Error("a.txt should not have been created")
+	}
+}
+
+// TestWritePlanDryRun verifies dry-run plans write nothing
+func TestWritePlanDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", filepath.Join(tmpDir, "a.txt"), "a"),
+		writeBlock("t2", filepath.Join(tmpDir, "b.txt"), "b"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	for i, r := range results {
+		if !strings.HasPrefix(r.Content, "Dry-run") {
+			t.Errorf("result %d: expected dry-run, got %q", i, r.Content)
+		}
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("dry-run should not create files, found %d", len(entries))
+	}
+}
+
+// TestWritePlanSamePath verifies the last of several writes to one path wins
+func TestWritePlanSamePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", a, "first\n"),
+		writeBlock("t2", b, "b\n"),
+		writeBlock("t3", filepath.Join(tmpDir, ".", "a.txt"), "second\n"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !strings.Contains(results[0].Content, "replaced by a later write_file") {
+		t.Errorf("expected first write to be replaced, got %q", results[0].Content)
+	}
+	for i, r := range results {
+		if strings.HasPrefix(r.Content, "Error") {
+			t.Errorf("result %d: unexpected error: %s", i, r.Content)
+		}
+	}
+	if got, _ := os.ReadFile(a); string(got) != "second\n" {
+		t.Errorf("a.txt: expected the last write, got %q", got)
+	}
+	if got, _ := os.ReadFile(b); string(got) != "b\n" {
+		t.Errorf("b.txt: expected %q, got %q", "b\n", got)
+	}
+}
+
+// TestWritePlanToolOrder verifies tools before the writes run first
+func TestWritePlanToolOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(a, []byte("old a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		{
+			Type:  "tool_use",
+			ID:    "t1",
+			Name:  "read_file",
+			Input: map[string]interface{}{"path": a},
+		},
+		writeBlock("t2", a, "new a\n"),
+		writeBlock("t3", filepath.Join(tmpDir, "b.txt"), "new b\n"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	if !strings.Contains(results[0].Content, "old a") {
+		t.Errorf("read_file should see the old content, got %q", results[0].Content)
+	}
+	if got, _ := os.ReadFile(a); string(got) != "new a\n" {
+		t.Errorf("a.txt: expected new content, got %q", got)
+	}
+}
+
+// TestWritePlanRollbackDirs verifies directories made for the plan are
+// removed when it rolls back
+func TestWritePlanRollbackDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	// "sub" is a file, so writing sub/b.txt must fail
+	if err := os.WriteFile(filepath.Join(tmpDir, "sub"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	content := []claude.ContentBlock{
+		writeBlock("t1", filepath.Join(tmpDir, "new", "deep", "a.txt"), "a\n"),
+		writeBlock("t2", filepath.Join(tmpDir, "sub", "b.txt"), "b\n"),
+	}
+	results, err := claude.ExecuteTools(content, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatalf("ExecuteTools failed: %v", err)
+	}
+	if !strings.Contains(results[0].Content, "rolled back") {
+		t.Errorf("expected rollback error, got %q", results[0].Content)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "new")); !os.IsNotExist(err) {
+		t.Errorf("directory made for the plan was left behind (%v)", err)
+	}
+}
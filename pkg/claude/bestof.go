@@ -0,0 +1,267 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/diff"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// bestof.go - Best-of-N sampling (--best-of)
+//
+// With --best-of=N the first response of a turn, where a one-shot answer
+// or edit is decided, is sampled N times and a judge keeps one; the turn
+// goes on from it as usual. The default judge, consensus, keeps the
+// candidate most like the others, for free: answers that several samples
+// agree on are less likely to be a fluke. --judge=MODEL asks a (cheap)
+// model to pick instead, falling back to consensus when it can't. Every
+// candidate is kept in the turn's metadata, and all of them and the
+// judge's call count toward the cost of the turn and --max-cost.
+// Candidates are sampled one after the other: Ollama serves one request
+// at a time by default.
+
+// JudgeConsensus is the --judge default: the candidate most like the others
+const JudgeConsensus = "consensus"
+
+// MaxBestOf is the largest --best-of
+const MaxBestOf = 10
+
+// judgeMaxTokens is the room of the judge's answer: a number and a reason
+const judgeMaxTokens = 1024
+
+// judgePrompt is the system prompt of --judge=MODEL
+const judgePrompt = `You judge candidate responses of a coding assistant to a task. ` +
+	`Pick the candidate that solves the task most correctly and completely, with the ` +
+	`fewest mistakes; tool calls show the files it would read or write. Reply with ` +
+	`the number of the best candidate on the first line, then one sentence on why.`
+
+// judgeChoice matches the candidate number the judge replied with
+var judgeChoice = regexp.MustCompile(`\d+`)
+
+// judge is the model of --judge
+type judge struct {
+	model    string
+	provider string
+	client   llm.LLM
+}
+
+// validateBestOf checks --best-of and its temperature
+func validateBestOf(opts *Options) error {
+	if opts.BestOf < 0 || opts.BestOf > MaxBestOf {
+		return fmt.Errorf("invalid --best-of %d (want 1 to %d)", opts.BestOf, MaxBestOf)
+	}
+	if opts.BestOf > 1 && opts.Temperature != nil && *opts.Temperature == 0 {
+		return fmt.Errorf("--best-of needs a temperature above 0 to sample different " +
+			"responses (--ci sets 0)")
+	}
+	return nil
+}
+
+// newJudge returns the --judge model, nil for consensus
+func newJudge(opts *Options, claudeDir, apiURL string) (*judge, error) {
+	if opts.BestOf <= 1 || opts.Judge == "" || opts.Judge == JudgeConsensus {
+		return nil, nil
+	}
+	model, err := ResolveModelAlias(opts.Judge, claudeDir)
+	if err != nil {
+		return nil, fmt.Errorf("--judge: %w", err)
+	}
+	client, provider, err := modelClient(model, opts, claudeDir, apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("--judge: %w", err)
+	}
+	return &judge{model: model, provider: provider, client: client}, nil
+}
+
+// sampleBestOf samples the response to req until there are --best-of
+// candidates, first being the one already received, and returns the one
+// the judge chose and what the other candidates and the judge cost
+func (s *session) sampleBestOf(ctx context.Context, client llm.LLM, req *llm.Request,
+	provider, model string, first *llm.Response, iteration int,
+) (*llm.Response, float64) {
+	responses := []*llm.Response{first}
+	rec := &storage.BestOf{Judge: JudgeConsensus}
+	rec.Candidates = append(rec.Candidates, newCandidate(model, first))
+	for len(responses) < s.opts.BestOf {
+		done := waitProgress(s.opts, provider, model, iteration)
+		resp, err := generate(ctx, client, req, provider, iteration)
+		done()
+		if err != nil {
+			slog.Warn("--best-of: sampling failed", "candidate", len(responses)+1, "err", err)
+			responses = append(responses, nil)
+			rec.Candidates = append(rec.Candidates, storage.Candidate{Error: err.Error()})
+			continue
+		}
+		responses = append(responses, resp)
+		rec.Candidates = append(rec.Candidates, newCandidate(model, resp))
+	}
+
+	scoreConsensus(rec.Candidates)
+	rec.Chosen = consensusChoice(rec.Candidates)
+	extra := 0.0
+	if s.judge != nil {
+		task, _ := GetLastUserMessage(req.Messages)
+		n, reason, usage, err := s.judge.choose(ctx, task, rec.Candidates)
+		if usage != nil {
+			extra += s.recordExtra(s.judge.provider, s.judge.model, *usage)
+		}
+		if err != nil {
+			slog.Warn("--best-of: judge failed, using consensus", "judge", s.judge.model, "err", err)
+			rec.Reason = fmt.Sprintf("judge %s failed: %v", s.judge.model, err)
+		} else {
+			rec.Judge, rec.Chosen, rec.Reason = s.judge.model, n, reason
+		}
+	}
+	for i, c := range rec.Candidates {
+		if i != rec.Chosen && c.Error == "" {
+			extra += s.recordExtra(provider, model,
+				Usage{InputTokens: c.InputTokens, OutputTokens: c.OutputTokens})
+		}
+	}
+	s.bestOf = rec
+	slog.Info("best-of", "chosen", rec.Chosen+1, "of", len(rec.Candidates),
+		"judge", rec.Judge, "reason", rec.Reason)
+	return responses[rec.Chosen], extra
+}
+
+// recordExtra adds a call that isn't an iteration of the turn, a
+// candidate not chosen or the judge, to the summary and the provider
+// stats, and returns its cost
+func (s *session) recordExtra(provider, model string, usage Usage) float64 {
+	cost := UsageCost(model, usage.InputTokens, usage.OutputTokens)
+	s.summary.InputTokens += usage.InputTokens
+	s.summary.OutputTokens += usage.OutputTokens
+	s.summary.Cost += cost
+	storage.UpdateProviderStats(s.config, provider, usage.InputTokens, usage.OutputTokens)
+	return cost
+}
+
+// newCandidate returns the candidate of resp
+func newCandidate(model string, resp *llm.Response) storage.Candidate {
+	return storage.Candidate{
+		Content:      resp.Content,
+		StopReason:   resp.StopReason,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+		Cost:         UsageCost(model, resp.Usage.InputTokens, resp.Usage.OutputTokens),
+	}
+}
+
+// candidateText renders a candidate as lines: its text, and its tool calls
+// with their inputs, file contents on lines of their own
+func candidateText(content []ContentBlock) string {
+	var b strings.Builder
+	for _, block := range content {
+		switch block.Type {
+		case "text":
+			b.WriteString(block.Text + "\n")
+		case "tool_use":
+			fmt.Fprintf(&b, "[%s]\n", block.Name)
+			keys := make([]string, 0, len(block.Input))
+			for k := range block.Input {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(&b, "%s: %v\n", k, block.Input[k])
+			}
+		}
+	}
+	return b.String()
+}
+
+// similarity returns the share of the lines of a and b they have in
+// common, from 0 to 1
+func similarity(a, b string) float64 {
+	la, lb := diff.Lines(a), diff.Lines(b)
+	if len(la)+len(lb) == 0 {
+		return 1
+	}
+	same := 0
+	for _, op := range diff.Edits(la, lb) {
+		if op.Kind == diff.Keep {
+			same++
+		}
+	}
+	return 2 * float64(same) / float64(len(la)+len(lb))
+}
+
+// scoreConsensus scores every candidate with its mean similarity to the
+// other candidates that didn't fail
+func scoreConsensus(candidates []storage.Candidate) {
+	texts := make([]string, len(candidates))
+	for i, c := range candidates {
+		texts[i] = candidateText(c.Content)
+	}
+	for i := range candidates {
+		if candidates[i].Error != "" {
+			continue
+		}
+		total, n := 0.0, 0
+		for j := range candidates {
+			if j != i && candidates[j].Error == "" {
+				total += similarity(texts[i], texts[j])
+				n++
+			}
+		}
+		if n > 0 {
+			candidates[i].Score = total / float64(n)
+		}
+	}
+}
+
+// consensusChoice returns the candidate with the best score, the first of
+// equals
+func consensusChoice(candidates []storage.Candidate) int {
+	best := 0
+	for i, c := range candidates {
+		if c.Error == "" && c.Score > candidates[best].Score {
+			best = i
+		}
+	}
+	return best
+}
+
+// choose asks the judge which candidate solves task best and returns its
+// index, the judge's reason and the usage of the call
+func (j *judge) choose(ctx context.Context, task string, candidates []storage.Candidate) (int, string, *Usage, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task:\n%s\n", task)
+	var valid []int
+	for i, c := range candidates {
+		if c.Error != "" {
+			continue
+		}
+		valid = append(valid, i)
+		fmt.Fprintf(&b, "\n=== Candidate %d ===\n%s", len(valid), candidateText(c.Content))
+	}
+
+	zero := 0.0
+	resp, err := generate(ctx, j.client, &llm.Request{
+		Model:       j.model,
+		MaxTokens:   judgeMaxTokens,
+		System:      judgePrompt,
+		Temperature: &zero,
+		Messages: []MessageContent{{
+			Role:    "user",
+			Content: []ContentBlock{{Type: "text", Text: b.String()}},
+		}},
+	}, j.provider, 1)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	answer := strings.TrimSpace(ExtractResponse(&APIResponse{Content: resp.Content}))
+	first, reason, _ := strings.Cut(answer, "\n")
+	n, err := strconv.Atoi(judgeChoice.FindString(first))
+	if err != nil || n < 1 || n > len(valid) {
+		return 0, "", &resp.Usage, fmt.Errorf("no candidate number in %q", firstLine(answer))
+	}
+	return valid[n-1], strings.TrimSpace(reason), &resp.Usage, nil
+}
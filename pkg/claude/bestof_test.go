@@ -0,0 +1,142 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func usageResponse(text string, in, out int) *llm.Response {
+	resp := textResponse(text, "end_turn")
+	resp.Usage = llm.Usage{InputTokens: in, OutputTokens: out}
+	return resp
+}
+
+// bestOfMeta returns the --best-of record of the only turn of claudeDir
+func bestOfMeta(t *testing.T, claudeDir string) *storage.BestOf {
+	t.Helper()
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil || len(pairs) != 1 {
+		t.Fatalf("turns = %v, %v", pairs, err)
+	}
+	meta, err := storage.LoadTurnMeta(claudeDir, pairs[0])
+	if err != nil || meta == nil || meta.BestOf == nil {
+		t.Fatalf("meta = %+v, %v, want best_of", meta, err)
+	}
+	return meta.BestOf
+}
+
+func TestBestOfConsensus(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		usageResponse("Sort the slice and compact it.", 100, 10),
+		usageResponse("Use a map keyed by ID.\nKeep the first entry.", 100, 20),
+		usageResponse("Use a map keyed by ID.\nKeep the first one.", 100, 20),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.BestOf = 3
+
+	text, claudeDir, summary, err := runScriptedSummary(t, opts, mock, "how do I dedupe?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Use a map keyed by ID.\nKeep the first entry." {
+		t.Errorf("answer = %q, want the second candidate", text)
+	}
+	if len(mock.requests) != 3 {
+		t.Errorf("%d calls, want 3", len(mock.requests))
+	}
+	// Every candidate is paid for, but it is one iteration
+	if summary.Iterations != 1 || summary.InputTokens != 300 || summary.OutputTokens != 50 {
+		t.Errorf("summary = %d iterations, %d/%d tokens", summary.Iterations,
+			summary.InputTokens, summary.OutputTokens)
+	}
+
+	rec := bestOfMeta(t, claudeDir)
+	if rec.Judge != claude.JudgeConsensus || rec.Chosen != 1 || len(rec.Candidates) != 3 {
+		t.Errorf("best_of = %+v", rec)
+	}
+	if rec.Candidates[0].Score >= rec.Candidates[1].Score {
+		t.Errorf("outlier scored %v, consensus %v", rec.Candidates[0].Score, rec.Candidates[1].Score)
+	}
+}
+
+func TestBestOfJudge(t *testing.T) {
+	for _, tc := range []struct {
+		name, judge string
+		want        string
+		wantJudge   string
+	}{
+		{"model", "fake:judge", "Sort the slice and compact it.", "fake:judge"},
+		// No number in the answer: consensus decides
+		{"fallback", "fake:hello", "Use a map keyed by ID.", claude.JudgeConsensus},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, claudeDir := writeProject(t, map[string]string{
+				".claude/fakes/judge.json": `{"responses": [{"text": "1\nIt handles sorted input."}]}`,
+			})
+			t.Chdir(dir)
+			t.Setenv("ANTHROPIC_API_KEY", "test-key")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+				Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+			})
+
+			opts := claude.NewOptions()
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.BestOf, opts.Judge = 3, tc.judge
+			sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sess.SetLLM(&scriptedLLM{responses: []*llm.Response{
+				textResponse("Sort the slice and compact it.", "end_turn"),
+				textResponse("Use a map keyed by ID.", "end_turn"),
+				textResponse("Use a map keyed by ID.", "end_turn"),
+			}})
+			result, err := claude.ExecuteConversation(sess, "how do I dedupe?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.AssistantText() != tc.want {
+				t.Errorf("answer = %q, want %q", result.AssistantText(), tc.want)
+			}
+			noOutput := func(string, bool, string, []byte) error { return nil }
+			if err := claude.FinalizeSession(sess, result, storage.SaveJSON, noOutput); err != nil {
+				t.Fatal(err)
+			}
+			rec := bestOfMeta(t, claudeDir)
+			if rec.Judge != tc.wantJudge {
+				t.Errorf("judge = %q, want %q", rec.Judge, tc.wantJudge)
+			}
+			if tc.wantJudge == claude.JudgeConsensus && !strings.Contains(rec.Reason, "fake:hello failed") {
+				t.Errorf("reason = %q, want the judge's failure", rec.Reason)
+			}
+		})
+	}
+}
+
+func TestBestOfValidation(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "fake:hello"
+	opts.BestOf = 3
+	zero := 0.0
+	opts.Temperature = &zero
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "system"); err == nil ||
+		!strings.Contains(err.Error(), "temperature") {
+		t.Errorf("err = %v, want the temperature refused", err)
+	}
+
+	opts.Temperature = nil
+	opts.BestOf = claude.MaxBestOf + 1
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "system"); err == nil {
+		t.Error("--best-of above the maximum accepted")
+	}
+}
@@ -0,0 +1,178 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// budget.go - Soft spend limits (--spend-alerts, --daily-budget)
+//
+// Spend crossing an alert threshold of --max-cost or of the project's
+// daily budget warns once on stderr, is noted in the run summary and the
+// footer, and runs the budget hooks of .claude/hooks.json (e.g. a curl to
+// a Slack webhook). Only --max-cost stops a run; the daily budget also
+// alerts at 100%.
+
+// Budgets spend alerts are about
+const (
+	BudgetMaxCost = "max_cost"
+	BudgetDaily   = "daily"
+)
+
+// DefaultSpendAlerts are the alert thresholds, in percent, when neither
+// --spend-alerts nor the project config gives any
+var DefaultSpendAlerts = []int{50, 80}
+
+// ParseSpendAlerts parses --spend-alerts: comma-separated percentages, or
+// "off" for none
+func ParseSpendAlerts(s string) ([]int, error) {
+	alerts := []int{}
+	if strings.TrimSpace(s) == "off" {
+		return alerts, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSuffix(strings.TrimSpace(part), "%")
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("spend alert %q: want a percentage from 1 to 100", part)
+		}
+		alerts = append(alerts, n)
+	}
+	sort.Ints(alerts)
+	return alerts, nil
+}
+
+// FormatSpendAlerts is the inverse of ParseSpendAlerts
+func FormatSpendAlerts(alerts []int) string {
+	if alerts != nil && len(alerts) == 0 {
+		return "off"
+	}
+	parts := make([]string, len(alerts))
+	for i, n := range alerts {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// applyBudgetConfig fills in the budget options not given from the
+// project config
+func applyBudgetConfig(opts *Options, cfg *storage.BudgetConfig) {
+	if cfg != nil {
+		if opts.SpendAlerts == nil {
+			opts.SpendAlerts = cfg.Alerts
+		}
+		if opts.DailyBudget == 0 {
+			opts.DailyBudget = cfg.Daily
+		}
+	}
+	if opts.SpendAlerts == nil {
+		opts.SpendAlerts = DefaultSpendAlerts
+	}
+}
+
+// spendTracker remembers the highest threshold crossed per budget, so
+// each alert fires once per run
+type spendTracker struct {
+	dailySpent float64        // by earlier turns today
+	crossed    map[string]int // budget -> percent
+}
+
+// startBudget sets up the spend alerts of the session
+func (s *session) startBudget(now time.Time) {
+	applyBudgetConfig(s.opts, s.config.Budget)
+	s.spend = &spendTracker{crossed: make(map[string]int)}
+	if s.opts.DailyBudget > 0 {
+		s.spend.dailySpent = dailySpend(s.claudeDir, now)
+	}
+}
+
+// dailySpend returns what the turns of claudeDir saved on the day of now
+// cost
+func dailySpend(claudeDir string, now time.Time) float64 {
+	entries, err := LoadHistory(claudeDir)
+	if err != nil {
+		slog.Warn("daily budget: loading history", "err", err)
+		return 0
+	}
+	today := now.Format("20060102")
+	spent := 0.0
+	for _, e := range entries {
+		if strings.HasPrefix(e.Timestamp, today) {
+			spent += e.Cost
+		}
+	}
+	return spent
+}
+
+// checkBudgets alerts on the thresholds the run's spend so far crossed
+func (s *session) checkBudgets(ctx context.Context, spent float64) {
+	if s.spend == nil {
+		return
+	}
+	s.alert(ctx, BudgetMaxCost, spent, s.opts.MaxCost, s.opts.SpendAlerts)
+	daily := s.opts.SpendAlerts
+	if !slices.Contains(daily, 100) {
+		daily = append(append([]int{}, daily...), 100)
+	}
+	s.alert(ctx, BudgetDaily, s.spend.dailySpent+spent, s.opts.DailyBudget, daily)
+}
+
+// alert fires the highest threshold of limit that spent crossed and that
+// didn't fire yet
+func (s *session) alert(ctx context.Context, budget string, spent, limit float64, thresholds []int) {
+	if limit <= 0 {
+		return
+	}
+	percent := 0
+	for _, t := range thresholds {
+		if spent >= limit*float64(t)/100 && t > s.spend.crossed[budget] {
+			percent = t
+		}
+	}
+	if percent == 0 {
+		return
+	}
+	s.spend.crossed[budget] = percent
+
+	msg := fmt.Sprintf("spent $%.4f, %d%% of the %s ($%.2f)", spent, percent, budgetName(budget), limit)
+	if budget == BudgetDaily && percent >= 100 {
+		msg = fmt.Sprintf("spent $%.4f today, over the daily budget ($%.2f)", spent, limit)
+	}
+	s.summary.Alerts = append(s.summary.Alerts, msg)
+	slog.Warn("spend alert", "budget", budget, "percent", percent, "spent", spent, "limit", limit)
+	if !s.opts.IsSilent() {
+		display.Warning("%s", msg)
+	}
+
+	if err := runHooks(ctx, hookEvent{
+		Event:          storage.HookBudget,
+		ConversationID: s.timestamp,
+		WorkingDir:     s.workingDir,
+		Budget: &hookBudget{
+			Budget:  budget,
+			Percent: percent,
+			Spent:   spent,
+			Limit:   limit,
+			Message: msg,
+		},
+	}); err != nil {
+		slog.Warn("budget hook failed", "err", err)
+	}
+}
+
+// budgetName is how alerts call budget
+func budgetName(budget string) string {
+	if budget == BudgetDaily {
+		return "daily budget"
+	}
+	return "--max-cost budget"
+}
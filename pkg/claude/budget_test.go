@@ -0,0 +1,110 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+func TestParseSpendAlerts(t *testing.T) {
+	alerts, err := claude.ParseSpendAlerts("80, 50%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := claude.FormatSpendAlerts(alerts); s != "50,80" {
+		t.Errorf("alerts = %s", s)
+	}
+	if alerts, err := claude.ParseSpendAlerts("off"); err != nil || alerts == nil || len(alerts) != 0 {
+		t.Errorf("off = %v, %v", alerts, err)
+	}
+	for _, bad := range []string{"0", "101", "half", ""} {
+		if _, err := claude.ParseSpendAlerts(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+func TestSpendAlerts(t *testing.T) {
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	withHooks(t, `{"budget": [{"command": "cat >> `+events+`; echo >> `+events+`"}]}`)
+
+	// $0.30 per call at Sonnet prices
+	call := func(r *llm.Response) *llm.Response {
+		r.Usage = llm.Usage{InputTokens: 100_000}
+		return r
+	}
+	readMissing := claude.ContentBlock{
+		Type: "tool_use", ID: "t1", Name: "read_file",
+		Input: map[string]interface{}{"path": "missing.txt"},
+	}
+	newMock := func() *scriptedLLM {
+		return &scriptedLLM{responses: []*llm.Response{
+			call(&llm.Response{Content: []claude.ContentBlock{readMissing}, StopReason: "tool_use"}),
+			call(&llm.Response{Content: []claude.ContentBlock{readMissing}, StopReason: "tool_use"}),
+			call(textResponse("done", "end_turn")),
+		}}
+	}
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.MaxCost = 1.0
+	_, _, summary, err := runScriptedSummary(t, opts, newMock(), "read it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each threshold fires once: 50% at $0.60, 80% at $0.90
+	if len(summary.Alerts) != 2 || !strings.Contains(summary.Alerts[0], "50% of the --max-cost budget") ||
+		!strings.Contains(summary.Alerts[1], "80%") {
+		t.Errorf("alerts = %q", summary.Alerts)
+	}
+	if footer := summary.Footer(0); !strings.HasSuffix(footer, "80% of the --max-cost budget ($1.00))") {
+		t.Errorf("footer = %q", footer)
+	}
+	data, _ := os.ReadFile(events)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("budget hook ran %d times:\n%s", len(lines), data)
+	}
+	var ev struct {
+		Event  string `json:"event"`
+		Budget struct {
+			Budget  string  `json:"budget"`
+			Percent int     `json:"percent"`
+			Limit   float64 `json:"limit"`
+		} `json:"budget"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Event != "budget" || ev.Budget.Budget != claude.BudgetMaxCost || ev.Budget.Percent != 80 || ev.Budget.Limit != 1.0 {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// Past the daily budget only the highest threshold crossed fires
+	os.Remove(events)
+	opts = claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.MaxCost = 0
+	opts.DailyBudget = 0.5
+	_, _, summary, err = runScriptedSummary(t, opts, newMock(), "read it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Alerts) != 2 || !strings.Contains(summary.Alerts[0], "50% of the daily budget") ||
+		!strings.Contains(summary.Alerts[1], "over the daily budget") {
+		t.Errorf("alerts = %q", summary.Alerts)
+	}
+
+	opts = claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SpendAlerts = []int{}
+	_, _, summary, err = runScriptedSummary(t, opts, newMock(), "read it")
+	if err != nil || len(summary.Alerts) != 0 {
+		t.Errorf("alerts off: %q, %v", summary.Alerts, err)
+	}
+}
@@ -0,0 +1,42 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// ExportSessionCommand handles --export-session: bundles claudeDir into
+// dest so the conversation can move to another machine or be attached to
+// a bug report
+func ExportSessionCommand(claudeDir, dest string) error {
+	manifest, err := storage.ExportSession(claudeDir, dest)
+	if err != nil {
+		return fmt.Errorf("exporting session: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d files from %s to %s\n",
+		len(manifest.Files), claudeDir, dest)
+	return nil
+}
+
+// ImportSessionCommand handles --import-session: verifies the bundle at
+// src and unpacks it as claudeDir. The hooks, formatters, plugins,
+// validators and workspace roots of the bundle are dropped unless trusted
+// (--import-trusted).
+func ImportSessionCommand(claudeDir, src string, trusted bool) error {
+	manifest, dropped, err := storage.ImportSession(claudeDir, src, trusted)
+	if err != nil {
+		return fmt.Errorf("importing session: %w", err)
+	}
+	from := manifest.Hostname
+	if from == "" {
+		from = "unknown host"
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d files into %s (exported from %s on %s)\n",
+		len(manifest.Files), claudeDir, from, manifest.Created.Local().Format("2006-01-02 15:04:05"))
+	for _, setting := range dropped {
+		fmt.Fprintf(os.Stderr, "  dropped %s (use --import-trusted to keep it)\n", setting)
+	}
+	return nil
+}
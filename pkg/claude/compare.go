@@ -0,0 +1,110 @@
+package claude
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/diff"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// compare.go - Comparing two saved turns (--compare)
+//
+// When iterating on a prompt the interesting part is how the outputs moved:
+// --compare diffs the final answers of two turns and, file by file, the
+// content their write_file calls proposed. Only what differs is printed,
+// as unified diffs that can go through a pager or colordiff.
+
+// turnOutput is what a turn produced: its final answer and the last
+// content it wrote to each file
+type turnOutput struct {
+	answer string
+	files  map[string]string
+}
+
+// loadTurnOutput collects the output of turn timestamp
+func loadTurnOutput(claudeDir, timestamp string) (*turnOutput, error) {
+	if _, err := loadHistoryEntry(claudeDir, timestamp); err != nil {
+		return nil, err
+	}
+	responses, err := storage.LoadResponses(claudeDir, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+	out := &turnOutput{files: make(map[string]string)}
+	for _, resp := range responses {
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" || block.Name != "write_file" {
+				continue
+			}
+			path, _ := block.Input["path"].(string)
+			content, ok := block.Input["content"].(string)
+			if path != "" && ok {
+				out.files[path] = content
+			}
+		}
+	}
+	if len(responses) > 0 {
+		out.answer = ExtractResponse(&responses[len(responses)-1])
+	}
+	return out, nil
+}
+
+// CompareCommand handles --compare: writes how the answer and the proposed
+// files of turn b differ from those of turn a to w
+func CompareCommand(w io.Writer, claudeDir, a, b string) error {
+	from, err := loadTurnOutput(claudeDir, a)
+	if err != nil {
+		return err
+	}
+	to, err := loadTurnOutput(claudeDir, b)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	writeCompareDiff(&out, from.answer, to.answer, a+" answer", b+" answer", true, true)
+
+	paths := make([]string, 0, len(from.files)+len(to.files))
+	for path := range from.files {
+		paths = append(paths, path)
+	}
+	for path := range to.files {
+		if _, ok := from.files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		old, inA := from.files[path]
+		new, inB := to.files[path]
+		writeCompareDiff(&out, old, new, a+"/"+path, b+"/"+path, inA, inB)
+	}
+
+	if out.Len() == 0 {
+		fmt.Fprintf(os.Stderr, "Turns %s and %s have the same answer and files\n", a, b)
+		return nil
+	}
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// writeCompareDiff writes the unified diff of old and new, named from and
+// to, to b when they differ. A side that doesn't exist is /dev/null.
+func writeCompareDiff(b *strings.Builder, old, new, from, to string, inOld, inNew bool) {
+	ops := diff.Edits(diff.Lines(old), diff.Lines(new))
+	if inOld == inNew && !diff.HasChange(ops) {
+		return
+	}
+	if !inOld {
+		from = "/dev/null"
+	}
+	if !inNew {
+		to = "/dev/null"
+	}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", from, to)
+	writeHunks(b, ops)
+}
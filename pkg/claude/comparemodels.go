@@ -0,0 +1,218 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// comparemodels.go - A/B comparison of models (--compare-models)
+//
+// claude --compare-models=M1,M2 sends the same prompt, with the saved
+// conversation and the system prompt, to every model at once and shows
+// the answers side by side, or writes each to a file of --compare-dir,
+// followed by what each cost and how long it took. The calls have no
+// tools, so the models can't get in each other's way, and the prompt
+// isn't saved as a turn: the run is recorded in
+// .claude/compare/compare_TIMESTAMP.json instead.
+
+// ModelAnswer is the answer of one model of a comparison
+type ModelAnswer struct {
+	Model        string  `json:"model"`
+	Provider     string  `json:"provider,omitempty"`
+	Answer       string  `json:"answer,omitempty"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	LatencyMs    int64   `json:"latency_ms"`
+	StopReason   string  `json:"stop_reason,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// ModelComparison is a --compare-models run
+type ModelComparison struct {
+	Timestamp string        `json:"timestamp"`
+	Prompt    string        `json:"prompt"`
+	Answers   []ModelAnswer `json:"answers"` // in the order of the models
+}
+
+// CompareModels sends prompt to every model concurrently. A model that
+// fails has its error in its answer; only setting up the calls fails the
+// comparison.
+func CompareModels(ctx context.Context, opts *Options, claudeDir, apiURL, defaultSystemPrompt, prompt string,
+	models []string,
+) (*ModelComparison, error) {
+	if len(models) < 2 {
+		return nil, fmt.Errorf("--compare-models needs two or more models, got %d", len(models))
+	}
+	models = append([]string(nil), models...) // resolved in place
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	sysPrompt, _ := ResolveSystemPrompt(opts.SystemPrompt, cfg.SystemPrompt, defaultSystemPrompt)
+	sysBlocks, err := ComposeSystemPrompt(sysPrompt, opts.SystemFiles)
+	if err != nil {
+		return nil, err
+	}
+	system := llm.JoinSystem(sysBlocks)
+	history, err := loadHistory(claudeDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	messages := append(truncateHistory(history, opts.Truncate), MessageContent{
+		Role:    "user",
+		Content: []ContentBlock{{Type: "text", Text: prompt}},
+	})
+
+	type call struct {
+		client    llm.LLM
+		provider  string
+		maxTokens int
+	}
+	calls := make([]call, len(models))
+	for i, model := range models {
+		if models[i], err = ResolveModelAlias(model, claudeDir); err != nil {
+			return nil, err
+		}
+		c := &calls[i]
+		if c.client, c.provider, err = modelClient(models[i], opts, claudeDir, apiURL); err != nil {
+			return nil, fmt.Errorf("%s: %w", models[i], err)
+		}
+		if c.maxTokens = opts.MaxTokens; c.maxTokens == 0 {
+			c.maxTokens = defaultMaxTokens(models[i], claudeDir, opts.Timeout)
+		}
+	}
+
+	cmp := &ModelComparison{
+		Timestamp: storage.CurrentTimestamp(),
+		Prompt:    prompt,
+		Answers:   make([]ModelAnswer, len(models)),
+	}
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := &cmp.Answers[i]
+			a.Model, a.Provider = models[i], c.provider
+			start := time.Now()
+			resp, err := generate(ctx, c.client, &llm.Request{
+				Model:       models[i],
+				MaxTokens:   c.maxTokens,
+				System:      system,
+				Temperature: opts.Temperature,
+				Messages:    messages,
+			}, c.provider, 1)
+			a.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				a.Error = err.Error()
+				return
+			}
+			a.Answer = ExtractResponse(&APIResponse{Content: resp.Content})
+			a.InputTokens, a.OutputTokens = resp.Usage.InputTokens, resp.Usage.OutputTokens
+			a.Cost = UsageCost(models[i], a.InputTokens, a.OutputTokens)
+			a.StopReason = resp.StopReason
+		}()
+	}
+	wg.Wait()
+
+	if storage.Ephemeral() {
+		return cmp, nil
+	}
+	for _, a := range cmp.Answers {
+		if a.Error == "" {
+			storage.UpdateProviderStats(cfg, a.Provider, a.InputTokens, a.OutputTokens)
+		}
+	}
+	if err := storage.SaveJSON(filepath.Join(claudeDir, "config.json"), cfg); err != nil {
+		slog.Warn("--compare-models: saving stats", "err", err)
+	}
+	return cmp, nil
+}
+
+// CompareModelsCommand handles --compare-models: it compares the answers
+// of models to prompt and prints them side by side, or writes them to
+// outDir, and then prints the cost and latency of each. It fails when
+// every model did.
+func CompareModelsCommand(w io.Writer, opts *Options, claudeDir, apiURL, defaultSystemPrompt, prompt string,
+	models []string, outDir string,
+) error {
+	cmp, err := CompareModels(context.Background(), opts, claudeDir, apiURL,
+		defaultSystemPrompt, prompt, models)
+	if err != nil {
+		return err
+	}
+	if !storage.Ephemeral() {
+		path, err := storage.SaveComparison(claudeDir, cmp.Timestamp, cmp)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Recorded in %s\n", path)
+	}
+
+	titles := make([]string, len(cmp.Answers))
+	texts := make([]string, len(cmp.Answers))
+	failed := 0
+	for i, a := range cmp.Answers {
+		titles[i], texts[i] = a.Model, a.Answer
+		if a.Error != "" {
+			texts[i] = "error: " + a.Error
+			failed++
+		}
+	}
+	if outDir != "" {
+		if err := storage.MkdirAll(outDir, 0o755); err != nil {
+			return err
+		}
+		for i, a := range cmp.Answers {
+			path := filepath.Join(outDir, modelFileName(a.Model)+".md")
+			if err := storage.WriteFile(path, []byte(strings.TrimRight(texts[i], "\n")+"\n"), 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+		}
+	} else if err := display.Columns(w, titles, texts, 0); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	writeComparisonTable(w, cmp)
+	if failed == len(cmp.Answers) {
+		return fmt.Errorf("--compare-models: every model failed")
+	}
+	return nil
+}
+
+// writeComparisonTable writes the tokens, cost and latency of each answer
+func writeComparisonTable(w io.Writer, cmp *ModelComparison) {
+	width := len("Model")
+	for _, a := range cmp.Answers {
+		width = max(width, len(a.Model))
+	}
+	fmt.Fprintf(w, "%-*s  %8s  %8s  %10s  %9s\n", width, "Model", "Input", "Output",
+		"Cost", "Latency")
+	for _, a := range cmp.Answers {
+		latency := (time.Duration(a.LatencyMs) * time.Millisecond).Round(10 * time.Millisecond)
+		if a.Error != "" {
+			fmt.Fprintf(w, "%-*s  %8s  %8s  %10s  %9s  failed\n", width, a.Model, "-", "-",
+				"-", latency)
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  %8d  %8d  %10s  %9s\n", width, a.Model, a.InputTokens,
+			a.OutputTokens, fmt.Sprintf("$%.4f", a.Cost), latency)
+	}
+}
+
+// modelFileName returns model as a file name: Ollama tags and fake
+// scenarios have ':' and '/' in them
+func modelFileName(model string) string {
+	return strings.NewReplacer(":", "_", "/", "_").Replace(model)
+}
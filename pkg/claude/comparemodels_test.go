@@ -0,0 +1,99 @@
+package claude_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestCompareModels(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/fakes/terse.json":   `{"responses": [{"text": "Use a map.", "usage": {"input_tokens": 10, "output_tokens": 3}}]}`,
+		".claude/fakes/verbose.json": `{"responses": [{"text": "A map keyed by ID works best here."}]}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	// Ollama that is down: its model fails, the others still answer
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.OllamaURL = down.URL
+	models := []string{"fake:terse", "fake:verbose", "llama3.1:8b"}
+	var out bytes.Buffer
+	if err := claude.CompareModelsCommand(&out, opts, claudeDir, "http://unused", "system",
+		"how do I dedupe?", models, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"fake:terse", "Use a map.", "fake:verbose", "llama3.1:8b", "failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(claudeDir, "compare", "compare_*.json"))
+	if len(matches) != 1 {
+		t.Fatalf("saved comparisons = %v, want one", matches)
+	}
+	var cmp claude.ModelComparison
+	if err := storage.LoadComparison(matches[0], &cmp); err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Prompt != "how do I dedupe?" || len(cmp.Answers) != 3 {
+		t.Fatalf("comparison = %+v", cmp)
+	}
+	if a := cmp.Answers[0]; a.Model != "fake:terse" || a.Answer != "Use a map." || a.InputTokens != 10 {
+		t.Errorf("first answer = %+v", a)
+	}
+	if a := cmp.Answers[2]; a.Error == "" || a.Answer != "" {
+		t.Errorf("answer of the unreachable model = %+v, want an error", a)
+	}
+	if models[2] != "llama3.1:8b" {
+		t.Errorf("models changed to %v", models)
+	}
+
+	// No turn is saved
+	if turns, _ := filepath.Glob(filepath.Join(claudeDir, "request_*.json")); len(turns) != 0 {
+		t.Errorf("turns saved: %v", turns)
+	}
+}
+
+func TestCompareModelsDir(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/fakes/a.json": `{"responses": [{"text": "first"}]}`,
+		".claude/fakes/b.json": `{"responses": [{"text": "second"}]}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	outDir := filepath.Join(dir, "answers")
+	var out bytes.Buffer
+	if err := claude.CompareModelsCommand(&out, opts, claudeDir, "http://unused", "system",
+		"hi", []string{"fake:a", "fake:b"}, outDir); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"fake_a.md": "first\n", "fake_b.md": "second\n"} {
+		data, err := os.ReadFile(filepath.Join(outDir, file))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", file, data, err, want)
+		}
+	}
+	if strings.Contains(out.String(), "first") {
+		t.Errorf("answers printed with --compare-dir:\n%s", out.String())
+	}
+
+	if err := claude.CompareModelsCommand(&out, opts, claudeDir, "http://unused", "system",
+		"hi", []string{"fake:a"}, ""); err == nil {
+		t.Error("comparing one model succeeded")
+	}
+}
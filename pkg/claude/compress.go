@@ -0,0 +1,212 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// compress.go - Tool result compression (--compress-results)
+//
+// Tool results above a token threshold are replaced before they reach the
+// conversation: summarized by a local Ollama model (--compress-model) or
+// cut down to their head and tail. The full output is saved to
+// .claude/results/ and the model can page through it with get_tool_result.
+
+// summarizePrompt instructs the model compressing tool output
+const summarizePrompt = "You compress tool output for a coding agent. " +
+	"Summarize the output below in at most %d tokens. Keep every error " +
+	"message, failing test, file path and line number verbatim; drop " +
+	"repetitive or passing output. Reply with the summary only."
+
+// getToolResultTool pages through results saved by compressResults
+var getToolResultTool = &builtinTool{
+	schema: Tool{
+		Name: "get_tool_result",
+		Description: "Read the full output of an earlier tool call whose " +
+			"result was compressed, a range of lines at a time.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]string{
+					"type":        "string",
+					"description": "tool_use id of the compressed result",
+				},
+				"offset": map[string]string{
+					"type":        "integer",
+					"description": "first line to return (0-based, default 0)",
+				},
+				"limit": map[string]string{
+					"type":        "integer",
+					"description": "number of lines (default 200)",
+				},
+			},
+			"required": []string{"id"},
+		},
+	},
+	run: ExecuteGetToolResult,
+	available: func(opts *Options) bool {
+		return opts.CompressResults > 0
+	},
+}
+
+// ExecuteGetToolResult returns lines of a saved tool result
+func ExecuteGetToolResult(toolUse ContentBlock, workingDir string, claudeDir string,
+	opts *Options, conversationID string,
+) (ContentBlock, error) {
+	startTime := time.Now()
+
+	id, ok := toolUse.Input["id"].(string)
+	if !ok {
+		return logAndReturnError(toolUse.ID, claudeDir, "get_tool_result",
+			toolUse.Input, "id must be a string", conversationID, startTime)
+	}
+	offset := intInput(toolUse.Input, "offset", 0)
+	limit := intInput(toolUse.Input, "limit", 200)
+	if offset < 0 || limit <= 0 {
+		return logAndReturnError(toolUse.ID, claudeDir, "get_tool_result",
+			toolUse.Input, "offset must be >= 0 and limit > 0",
+			conversationID, startTime)
+	}
+
+	content, err := storage.LoadToolResult(claudeDir, id)
+	if err != nil {
+		return logAndReturnError(toolUse.ID, claudeDir, "get_tool_result",
+			toolUse.Input, fmt.Sprintf("no saved result %q", id),
+			conversationID, startTime)
+	}
+
+	lines := strings.Split(content, "\n")
+	end := min(offset+limit, len(lines))
+	offset = min(offset, end)
+
+	logAuditEntry(claudeDir, "get_tool_result", toolUse.Input, map[string]interface{}{
+		"success": true,
+		"lines":   end - offset,
+	}, true, conversationID, startTime, false)
+
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUse.ID,
+		Content: fmt.Sprintf("Lines %d-%d of %d:\n%s", offset, end,
+			len(lines), strings.Join(lines[offset:end], "\n")),
+	}, nil
+}
+
+// intInput reads an integer tool input (JSON numbers decode as float64)
+func intInput(input map[string]interface{}, key string, def int) int {
+	if v, ok := input[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// estimateTextTokens is a rough token count (~4 chars per token)
+func estimateTextTokens(s string) int {
+	return len(s) / 4
+}
+
+// compressResults shrinks results over the --compress-results threshold in
+// place. get_tool_result output is never compressed again.
+func compressResults(ctx context.Context, sess *session, content,
+	results []ContentBlock,
+) {
+	threshold := sess.opts.CompressResults
+	if threshold <= 0 || storage.Ephemeral() {
+		return // --read-only, --no-save: the full copies would have to be saved
+	}
+	names := make(map[string]string)
+	for _, block := range content {
+		names[block.ID] = block.Name
+	}
+
+	for i := range results {
+		r := &results[i]
+		tokens := estimateTextTokens(r.Content)
+		if tokens <= threshold || names[r.ToolUseID] == "get_tool_result" {
+			continue
+		}
+
+		if err := storage.SaveToolResult(sess.claudeDir, r.ToolUseID, r.Content); err != nil {
+			// Without the full copy the model would lose data for good
+			slog.Warn("not compressing tool result", "id", r.ToolUseID, "err", err)
+			continue
+		}
+
+		body, method := "", "head/tail"
+		if sess.opts.CompressModel != "" {
+			var err error
+			body, err = summarizeResult(ctx, sess.opts, r.Content, threshold)
+			if err != nil {
+				slog.Warn("summarizing tool result failed, truncating", "err", err)
+			} else {
+				method = "summary"
+			}
+		}
+		if method != "summary" {
+			body = headTail(r.Content, threshold)
+		}
+
+		// Keep the error marker so failures still read as failures
+		prefix := ""
+		if strings.HasPrefix(r.Content, "Error: ") {
+			prefix = "Error: "
+		}
+		slog.Info("compressed tool result", "id", r.ToolUseID,
+			"tokens", tokens, "method", method)
+		r.Content = fmt.Sprintf("%s[Output of ~%d tokens compressed (%s). "+
+			"Full output: get_tool_result with id %q]\n%s",
+			prefix, tokens, method, r.ToolUseID, body)
+	}
+}
+
+// summarizeResult asks the local compress model for a summary of output
+func summarizeResult(ctx context.Context, opts *Options, output string,
+	maxTokens int,
+) (string, error) {
+	client := llm.NewOllama(opts.CompressModel, opts.OllamaURL)
+	resp, err := client.Generate(ctx, &llm.Request{
+		Model:     opts.CompressModel,
+		MaxTokens: maxTokens,
+		System:    fmt.Sprintf(summarizePrompt, maxTokens),
+		Messages: []MessageContent{{
+			Role:    "user",
+			Content: []ContentBlock{{Type: "text", Text: output}},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(ExtractResponse(&APIResponse{Content: resp.Content}))
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
+
+// headTail keeps about maxTokens of s, half from each end, cut at line
+// boundaries
+func headTail(s string, maxTokens int) string {
+	keep := maxTokens * 4 / 2
+	if len(s) <= 2*keep {
+		return s
+	}
+
+	head := s[:keep]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+	tail := s[len(s)-keep:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	omitted := strings.Count(s[len(head):len(s)-len(tail)], "\n")
+
+	return fmt.Sprintf("%s\n... [%d lines omitted] ...\n%s",
+		strings.ToValidUTF8(head, ""), omitted, strings.ToValidUTF8(tail, ""))
+}
@@ -0,0 +1,134 @@
+package claude_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// bigOutputTool returns 10000 numbered lines
+type bigOutputTool struct{}
+
+func (bigOutputTool) Name() string { return "test_big_output" }
+
+func (bigOutputTool) Schema() claude.Tool {
+	return claude.Tool{Name: "test_big_output", InputSchema: map[string]interface{}{"type": "object"}}
+}
+
+func (bigOutputTool) Execute(ctx context.Context, call claude.ToolCall) (claude.ContentBlock, error) {
+	var b strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return claude.ContentBlock{Type: "tool_result", ToolUseID: call.Use.ID, Content: b.String()}, nil
+}
+
+var registerBigOutput sync.Once
+
+func toolUseResponse(id, name string, input map[string]interface{}) *llm.Response {
+	return &llm.Response{
+		Content:    []claude.ContentBlock{{Type: "tool_use", ID: id, Name: name, Input: input}},
+		StopReason: "tool_use",
+	}
+}
+
+func TestCompressResultsHeadTail(t *testing.T) {
+	registerBigOutput.Do(func() { claude.RegisterTool(bigOutputTool{}) })
+
+	mock := &scriptedLLM{responses: []*llm.Response{
+		toolUseResponse("toolu_big", "test_big_output", nil),
+		toolUseResponse("toolu_get", "get_tool_result", map[string]interface{}{
+			"id": "toolu_big", "offset": 5000.0, "limit": 2.0,
+		}),
+		textResponse("done", "end_turn"),
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.CompressResults = 100
+	if _, _, err := runScripted(t, opts, mock, "run it"); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := lastContent(mock.requests[1])
+	for _, want := range []string{
+		"compressed (head/tail)", `get_tool_result with id "toolu_big"`,
+		"line 0\n", "line 9999", "lines omitted",
+	} {
+		if !strings.Contains(compressed, want) {
+			t.Errorf("compressed result missing %q", want)
+		}
+	}
+	if len(compressed) > 1000 {
+		t.Errorf("compressed result is %d bytes", len(compressed))
+	}
+
+	page := lastContent(mock.requests[2])
+	if want := "Lines 5000-5002 of 10001:\nline 5000\nline 5001"; page != want {
+		t.Errorf("get_tool_result = %q, want %q", page, want)
+	}
+}
+
+func TestCompressResultsSummary(t *testing.T) {
+	registerBigOutput.Do(func() { claude.RegisterTool(bigOutputTool{}) })
+
+	var summarizeReq map[string]interface{}
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&summarizeReq)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": map[string]string{"role": "assistant", "content": "10000 numbered lines"},
+			"done":    true,
+		})
+	}))
+	defer ollama.Close()
+
+	mock := &scriptedLLM{responses: []*llm.Response{
+		toolUseResponse("toolu_big", "test_big_output", nil),
+		textResponse("done", "end_turn"),
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.CompressResults = 100
+	opts.CompressModel = "qwen2.5:3b"
+	opts.OllamaURL = ollama.URL
+	if _, _, err := runScripted(t, opts, mock, "run it"); err != nil {
+		t.Fatal(err)
+	}
+
+	if summarizeReq["model"] != "qwen2.5:3b" {
+		t.Errorf("summarized with %v", summarizeReq["model"])
+	}
+	got := lastContent(mock.requests[1])
+	if !strings.Contains(got, "compressed (summary)") || !strings.HasSuffix(got, "10000 numbered lines") {
+		t.Errorf("summarized result = %q", got)
+	}
+}
+
+func TestGetToolResultOffered(t *testing.T) {
+	has := func(opts *claude.Options) bool {
+		for _, tool := range claude.GetTools(opts) {
+			if tool.Name == "get_tool_result" {
+				return true
+			}
+		}
+		return false
+	}
+
+	opts := claude.NewOptions()
+	if has(opts) {
+		t.Error("get_tool_result offered without compression")
+	}
+	opts.CompressResults = 1000
+	if !has(opts) {
+		t.Error("get_tool_result not offered with compression")
+	}
+}
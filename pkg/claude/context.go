@@ -0,0 +1,99 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// context.go - Context window guard
+//
+// Each call sends the system prompt, the tool schemas and the whole
+// conversation. Before a call is made its size is estimated and checked
+// against the context window of the model that gets it, minus the room
+// the answer needs, so an oversized conversation fails with advice instead
+// of an API error, and a small local model isn't held to Claude's limit.
+
+// ResolveContextWindow returns the context window of model in tokens: the
+// models cache value when known, otherwise what the provider reports for
+// the model family
+func ResolveContextWindow(model, provider, claudeDir string) int {
+	for _, m := range knownModels(claudeDir) {
+		if m.Name == model && m.ContextWindow > 0 {
+			return m.ContextWindow
+		}
+	}
+	if isClaudeModel(model, provider) {
+		return llm.NewClaude("", "").GetCapabilities().MaxContextTokens
+	}
+	if isFakeModel(model) {
+		return (&llm.FakeClient{}).GetCapabilities().MaxContextTokens
+	}
+	return llm.NewOllama(model, "").GetCapabilities().MaxContextTokens
+}
+
+// inputBudget is the part of window requests may fill. max_tokens of it
+// are kept for the answer, but at most half: Ollama models get
+// DefaultMaxTokens, which can be their whole window.
+func inputBudget(window, maxTokens int) int {
+	return window - min(maxTokens, window/2)
+}
+
+// truncateHistory keeps at most the last n messages of the history (n <= 0
+// keeps all). Whole turns are dropped from the oldest end, so the kept
+// history starts at a prompt and no tool_result loses its tool_use.
+func truncateHistory(messages []MessageContent, n int) []MessageContent {
+	if n <= 0 || len(messages) <= n {
+		return messages
+	}
+	keep := len(messages)
+	for i := range messages {
+		if len(messages)-i <= n && isPrompt(messages[i]) {
+			keep = i
+			break
+		}
+	}
+	slog.Info("truncating history", "from", len(messages), "to", len(messages)-keep)
+	return messages[keep:]
+}
+
+// isPrompt reports whether m starts a turn: a user message that doesn't
+// answer tool calls
+func isPrompt(m MessageContent) bool {
+	if m.Role != "user" {
+		return false
+	}
+	for _, block := range m.Content {
+		if block.Type == "tool_result" {
+			return false
+		}
+	}
+	return true
+}
+
+// requestTokens estimates the input tokens of a call
+func requestTokens(system string, tools []Tool, messages []MessageContent) int {
+	tokens := estimateTokens(system) + historyTokens(messages)
+	if len(tools) > 0 {
+		if schemas, err := json.Marshal(tools); err == nil {
+			tokens += len(schemas) / 4
+		}
+	}
+	return tokens
+}
+
+// contextError returns why a call of model with messages doesn't fit its
+// context window, or nil when it does
+func (s *session) contextError(model string, messages []MessageContent) error {
+	window := ResolveContextWindow(model, s.opts.Provider, s.claudeDir)
+	budget := inputBudget(window, s.opts.MaxTokens)
+	tokens := requestTokens(s.sysPrompt, GetTools(s.opts), messages)
+	if tokens <= budget {
+		return nil
+	}
+	return fmt.Errorf("conversation too large for %s (~%d tokens, max %d: "+
+		"a %d token context window less %d for the answer)",
+		model, tokens, budget, window, window-budget)
+}
@@ -0,0 +1,179 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestResolveContextWindow(t *testing.T) {
+	claudeDir := t.TempDir()
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: "qwen2.5-coder:7b", Provider: "ollama", ContextWindow: 16384}},
+	})
+	for model, want := range map[string]int{
+		claude.DefaultModel: 200000,
+		"qwen2.5-coder:7b":  16384, // the cache wins
+		"llama3.1:8b":       128000,
+		"phi3:mini":         8192,
+	} {
+		if got := claude.ResolveContextWindow(model, "", claudeDir); got != want {
+			t.Errorf("ResolveContextWindow(%s) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestResolveContextWindowProvider(t *testing.T) {
+	claudeDir := t.TempDir()
+	for _, tc := range []struct {
+		model, provider string
+		want            int
+	}{
+		{"us.anthropic.claude-sonnet-4-20250514-v1:0", claude.ProviderBedrock, 200000},
+		{"arn:aws:bedrock:us-east-1:123:application-inference-profile/x", claude.ProviderBedrock, 200000},
+		{"claude-sonnet-4@20250514", claude.ProviderVertex, 200000},
+		// --provider picks the transport of Claude, not what is Claude
+		{"llama3.1:8b", claude.ProviderBedrock, 128000},
+		{"llama3.1:8b", claude.ProviderVertex, 128000},
+		{"us.anthropic.claude-sonnet-4-20250514-v1:0", "", 8192},
+	} {
+		if got := claude.ResolveContextWindow(tc.model, tc.provider, claudeDir); got != tc.want {
+			t.Errorf("ResolveContextWindow(%s, %s) = %d, want %d", tc.model, tc.provider, got, tc.want)
+		}
+	}
+}
+
+func TestContextWindowGuard(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		"big.txt": strings.Repeat("0123456789abcdef\n", 6000), // ~25k tokens
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude", ContextWindow: 20000}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SetTool(claude.ToolRead)
+	opts.MaxTokens = 4000
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{Content: []claude.ContentBlock{{Type: "tool_use", ID: "r1", Name: "read_file",
+			Input: map[string]interface{}{"path": "big.txt"}}}, StopReason: "tool_use"},
+		textResponse("never asked", "end_turn"),
+	}}
+	sess.SetLLM(mock)
+
+	// The file fits Claude's usual 200k window but not this one
+	_, err = claude.ExecuteConversation(sess, "summarize big.txt")
+	if err == nil || !strings.Contains(err.Error(), "conversation too large") ||
+		!strings.Contains(err.Error(), "max 16000") {
+		t.Fatalf("err = %v, want the 16000 token budget exceeded", err)
+	}
+	if len(mock.requests) != 1 {
+		t.Errorf("%d calls made, want the oversized one held back", len(mock.requests))
+	}
+}
+
+func TestTruncateHistory(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	turn := func(prompt string) *scriptedLLM {
+		t.Helper()
+		sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok "+prompt, "end_turn")}}
+		sess.SetLLM(mock)
+		if _, err := claude.ExecuteConversation(sess, prompt); err != nil {
+			t.Fatal(err)
+		}
+		return mock
+	}
+	turn("first")
+	turn("second")
+
+	// The last 3 messages start with an answer, which is dropped too
+	opts.Truncate = 3
+	mock := turn("third")
+	sent := mock.requests[0].Messages
+	if len(sent) != 3 || sent[0].Role != "user" || sent[0].Content[0].Text != "second" {
+		t.Errorf("sent %d messages starting with %+v, want second, its answer and third",
+			len(sent), sent[0])
+	}
+}
+
+func TestTruncateHistoryKeepsToolPairs(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	// A recovered turn ending in a tool call, answered by the next turn
+	saveTurn := func(ts string, user claude.ContentBlock, answer claude.ContentBlock) {
+		t.Helper()
+		err := storage.SaveRequest(claudeDir, ts, []claude.MessageContent{
+			{Role: "user", Content: []claude.ContentBlock{user}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal([]storage.APIResponse{{Content: []claude.ContentBlock{answer}}})
+		if err := storage.SaveResponse(claudeDir, ts, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveTurn("20250101_000000", claude.ContentBlock{Type: "text", Text: "first"},
+		claude.ContentBlock{Type: "text", Text: "ok first"})
+	saveTurn("20250101_000001", claude.ContentBlock{Type: "text", Text: "second"},
+		claude.ContentBlock{Type: "tool_use", ID: "t1", Name: "read_file",
+			Input: map[string]interface{}{"path": "a.go"}})
+	saveTurn("20250101_000002", claude.ContentBlock{Type: "tool_result", ToolUseID: "t1",
+		Content: "package a"}, claude.ContentBlock{Type: "text", Text: "done"})
+
+	send := func(n int) []claude.MessageContent {
+		t.Helper()
+		opts := claude.NewOptions()
+		opts.SetVerbosity(claude.VerbositySilent)
+		opts.Truncate = n
+		sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+		sess.SetLLM(mock)
+		if _, err := claude.ExecuteConversation(sess, "next"); err != nil {
+			t.Fatal(err)
+		}
+		return mock.requests[0].Messages
+	}
+
+	// The last 3 messages start with the tool result: its turn goes whole
+	if sent := send(3); len(sent) != 1 || sent[0].Content[0].Text != "next" {
+		t.Errorf("sent %+v, want only the prompt", sent)
+	}
+	// The last 5 of first, ok first, second, tool_use, tool_result, done,
+	// next, ok start at the tool call
+	if sent := send(5); len(sent) != 3 || sent[0].Content[0].Text != "next" {
+		t.Errorf("sent %d messages starting with %+v, want the previous turn and the prompt",
+			len(sent), sent[0])
+	}
+}
@@ -1,12 +1,14 @@
 package claude
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 )
 
-// CostEstimate represents estimated token usage and cost
+// CostEstimate represents estimated token usage and cost. The top level
+// fields are the likely case; Low and High bound it.
 type CostEstimate struct {
 	InputTokens  int
 	OutputTokens int
@@ -15,6 +17,24 @@ type CostEstimate struct {
 	OutputCost   float64
 	TotalCost    float64
 	Model        string
+
+	// Input sent with every call
+	SystemTokens  int
+	ToolTokens    int
+	HistoryTokens int
+	PromptTokens  int
+
+	Iterations int
+	Low        EstimateScenario
+	High       EstimateScenario
+}
+
+// EstimateScenario is the usage of a turn that takes Iterations round trips
+type EstimateScenario struct {
+	Iterations   int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
 }
 
 // ModelPricing holds per-million-token pricing for a model
@@ -23,50 +43,99 @@ type ModelPricing struct {
 	OutputPerMillion float64
 }
 
-// EstimateCost calculates rough cost estimate based on conversation size
-func EstimateCost(userMsg string, history []MessageContent, model string) *CostEstimate {
-	// Count tokens in conversation history
-	historyTokens := 0
-	for _, msg := range history {
-		for _, block := range msg.Content {
-			if block.Type == "text" {
-				historyTokens += len(block.Text) / 4
-			}
-		}
-	}
-
-	// Count tokens in user message
-	userTokens := len(userMsg) / 4
+const (
+	// estimateLikelyIterations is a typical tool turn: look, change, answer
+	estimateLikelyIterations = 3
 
-	// System prompt tokens (roughly) - using embedded default
-	systemTokens := 500 // rough estimate
+	// estimateToolResultTokens is the assumed size of the tool results
+	// each iteration adds to the context
+	estimateToolResultTokens = 1000
+)
 
-	// Total input
-	inputTokens := historyTokens + userTokens + systemTokens
+// EstimateCost calculates a rough cost estimate for sending userMsg. Every
+// call resends the system prompt, the tool schemas and the history, and
+// each agentic iteration grows the context by its output and tool
+// results. Turns without tools take one iteration; with tools the range
+// runs from one to maxIterations.
+func EstimateCost(userMsg, systemPrompt string, history []MessageContent,
+	tools []Tool, maxIterations int, model string,
+) *CostEstimate {
+	est := &CostEstimate{
+		Model:         model,
+		SystemTokens:  estimateTokens(systemPrompt),
+		HistoryTokens: historyTokens(history),
+		PromptTokens:  estimateTokens(userMsg),
+	}
+	if len(tools) > 0 {
+		if schemas, err := json.Marshal(tools); err == nil {
+			est.ToolTokens = len(schemas) / 4
+		}
+	}
+	baseInput := est.SystemTokens + est.ToolTokens + est.HistoryTokens + est.PromptTokens
 
-	// Estimate output (heuristic: 30% of input, min 500)
-	outputTokens := inputTokens / 3
+	// Estimate output (heuristic: 30% of the conversation, min 500)
+	outputTokens := (est.HistoryTokens + est.PromptTokens) / 3
 	if outputTokens < 500 {
 		outputTokens = 500
 	}
 
-	// Get pricing for model
+	likely, high := 1, 1
+	if len(tools) > 0 {
+		high = max(maxIterations, 1)
+		likely = min(estimateLikelyIterations, high)
+	}
+
 	pricing := GetModelPricing(model)
+	est.Low = estimateScenario(1, baseInput, outputTokens, pricing)
+	est.High = estimateScenario(high, baseInput, outputTokens, pricing)
+	mid := estimateScenario(likely, baseInput, outputTokens, pricing)
+
+	est.Iterations = mid.Iterations
+	est.InputTokens = mid.InputTokens
+	est.OutputTokens = mid.OutputTokens
+	est.TotalTokens = mid.InputTokens + mid.OutputTokens
+	est.InputCost = float64(mid.InputTokens) * pricing.InputPerMillion / 1_000_000
+	est.OutputCost = float64(mid.OutputTokens) * pricing.OutputPerMillion / 1_000_000
+	est.TotalCost = mid.Cost
+	return est
+}
 
-	// Calculate cost
-	inputCost := float64(inputTokens) * pricing.InputPerMillion / 1_000_000
-	outputCost := float64(outputTokens) * pricing.OutputPerMillion / 1_000_000
-	totalCost := inputCost + outputCost
-
-	return &CostEstimate{
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		TotalTokens:  inputTokens + outputTokens,
-		InputCost:    inputCost,
-		OutputCost:   outputCost,
-		TotalCost:    totalCost,
-		Model:        model,
+// estimateScenario sums the usage of a turn of iterations round trips
+func estimateScenario(iterations, baseInput, outputTokens int, pricing ModelPricing) EstimateScenario {
+	sc := EstimateScenario{Iterations: iterations}
+	for i := 0; i < iterations; i++ {
+		sc.InputTokens += baseInput + i*(outputTokens+estimateToolResultTokens)
+		sc.OutputTokens += outputTokens
 	}
+	sc.Cost = float64(sc.InputTokens)*pricing.InputPerMillion/1_000_000 +
+		float64(sc.OutputTokens)*pricing.OutputPerMillion/1_000_000
+	return sc
+}
+
+// estimateTokens approximates the token count of s (~4 bytes per token)
+func estimateTokens(s string) int {
+	return len(s) / 4
+}
+
+// historyTokens approximates the tokens of the history, tool calls and
+// results included
+func historyTokens(history []MessageContent) int {
+	tokens := 0
+	for _, msg := range history {
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				tokens += estimateTokens(block.Text)
+			case "tool_use":
+				if input, err := json.Marshal(block.Input); err == nil {
+					tokens += len(input) / 4
+				}
+			case "tool_result":
+				tokens += estimateTokens(block.Content)
+			}
+		}
+	}
+	return tokens
 }
 
 // GetModelPricing returns pricing per million tokens for a model (exported for tests)
@@ -102,14 +171,28 @@ func GetModelPricing(model string) ModelPricing {
 	}
 }
 
-// GetLastUserMessage extracts the most recent user message from conversation
+// UsageCost returns the dollar cost of actual token usage for model.
+// Local (non-Claude) models are free.
+func UsageCost(model string, inputTokens, outputTokens int) float64 {
+	if !strings.HasPrefix(model, "claude-") {
+		return 0
+	}
+	pricing := GetModelPricing(model)
+	return float64(inputTokens)*pricing.InputPerMillion/1_000_000 +
+		float64(outputTokens)*pricing.OutputPerMillion/1_000_000
+}
+
+// GetLastUserMessage extracts the most recent user message from
+// conversation. The prompt is the last text block; --rag context comes
+// before it.
 func GetLastUserMessage(messages []MessageContent) (string, error) {
 	// Search backwards for last user message
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
-			for _, block := range messages[i].Content {
-				if block.Type == "text" {
-					return block.Text, nil
+			content := messages[i].Content
+			for j := len(content) - 1; j >= 0; j-- {
+				if content[j].Type == "text" {
+					return content[j].Text, nil
 				}
 			}
 		}
@@ -117,20 +200,46 @@ func GetLastUserMessage(messages []MessageContent) (string, error) {
 	return "", fmt.Errorf("no user message found in conversation")
 }
 
-// DisplayEstimate shows cost estimation to user
-func DisplayEstimate(estimate *CostEstimate) {
+// DisplayEstimate shows cost estimation to user. staged tells whether the
+// message was saved for --execute.
+func DisplayEstimate(estimate *CostEstimate, staged bool) {
 	fmt.Fprintln(os.Stderr, "\nAnalyzing task...")
+	fmt.Fprintln(os.Stderr, "\nInput per call:")
+	fmt.Fprintf(os.Stderr, "  System prompt: ~%d tokens\n", estimate.SystemTokens)
+	fmt.Fprintf(os.Stderr, "  Tool schemas:  ~%d tokens\n", estimate.ToolTokens)
+	fmt.Fprintf(os.Stderr, "  History:       ~%d tokens\n", estimate.HistoryTokens)
+	fmt.Fprintf(os.Stderr, "  Prompt:        ~%d tokens\n", estimate.PromptTokens)
+
 	fmt.Fprintln(os.Stderr, "\nEstimated Execution:")
-	fmt.Fprintf(os.Stderr, "  Input tokens:  ~%d\n", estimate.InputTokens)
-	fmt.Fprintf(os.Stderr, "  Output tokens: ~%d\n", estimate.OutputTokens)
-	fmt.Fprintf(os.Stderr, "  Total cost:    ~$%.3f\n\n", estimate.TotalCost)
-	fmt.Fprintf(os.Stderr, "  Model: %s\n", estimate.Model)
-	
+	fmt.Fprintf(os.Stderr, "  %-8s %10s %10s %10s %9s\n",
+		"", "Iterations", "Input", "Output", "Cost")
+	likely := EstimateScenario{
+		Iterations:   estimate.Iterations,
+		InputTokens:  estimate.InputTokens,
+		OutputTokens: estimate.OutputTokens,
+		Cost:         estimate.TotalCost,
+	}
+	for _, row := range []struct {
+		name string
+		sc   EstimateScenario
+	}{{"Low", estimate.Low}, {"Likely", likely}, {"High", estimate.High}} {
+		fmt.Fprintf(os.Stderr, "  %-8s %10d %10d %10d %9s\n", row.name,
+			row.sc.Iterations, row.sc.InputTokens, row.sc.OutputTokens,
+			fmt.Sprintf("~$%.3f", row.sc.Cost))
+	}
+
+	fmt.Fprintf(os.Stderr, "\n  Model: %s\n", estimate.Model)
 	pricing := GetModelPricing(estimate.Model)
 	fmt.Fprintf(os.Stderr, "  Pricing: $%.2f/million input, $%.2f/million output\n\n",
 		pricing.InputPerMillion, pricing.OutputPerMillion)
 
-	// Suggest execution command with 50% buffer
-	suggestedCost := estimate.TotalCost * 1.5
-	fmt.Fprintf(os.Stderr, "To execute: claude --execute --max-cost-override=%.2f\n", suggestedCost)
+	// Suggest a budget that covers the likely case with a 50% buffer, up
+	// to the high end
+	suggestedCost := max(estimate.TotalCost*1.5, estimate.High.Cost)
+	if staged {
+		fmt.Fprintf(os.Stderr, "To execute: claude --execute --max-cost-override=%.2f\n", suggestedCost)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "To run: pipe the prompt again with --max-cost=%.2f, or use --stage to save it for --execute\n",
+		suggestedCost)
 }
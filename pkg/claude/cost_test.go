@@ -20,7 +20,7 @@ func TestEstimateCost(t *testing.T) {
 	userMsg := strings.Repeat("b", 2000)
 	model := "claude-sonnet-4-5-20250929"
 
-	estimate := claude.EstimateCost(userMsg, messages, model)
+	estimate := claude.EstimateCost(userMsg, "", messages, nil, 0, model)
 
 	// Check ballpark (rough heuristic)
 	if estimate.InputTokens < 1000 || estimate.InputTokens > 3000 {
@@ -97,3 +97,39 @@ func TestGetModelPricing(t *testing.T) {
 		})
 	}
 }
+
+func TestEstimateCostRange(t *testing.T) {
+	model := "claude-sonnet-4-5-20250929"
+	userMsg := strings.Repeat("b", 2000)
+	history := []claude.MessageContent{{
+		Role: "user",
+		Content: []claude.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t1", Content: strings.Repeat("c", 4000)},
+		},
+	}}
+
+	bare := claude.EstimateCost(userMsg, "", nil, nil, 10, model)
+	if bare.Low != bare.High || bare.Iterations != 1 {
+		t.Errorf("turn without tools should take one iteration: %+v", bare)
+	}
+
+	opts := claude.NewOptions()
+	opts.Tool = claude.ToolAll
+	tools := claude.GetTools(opts)
+	est := claude.EstimateCost(userMsg, strings.Repeat("s", 8000), history, tools, 10, model)
+
+	if est.SystemTokens != 2000 || est.HistoryTokens != 1000 || est.ToolTokens == 0 {
+		t.Errorf("input breakdown = system %d, history %d, tools %d",
+			est.SystemTokens, est.HistoryTokens, est.ToolTokens)
+	}
+	if est.Low.InputTokens != est.SystemTokens+est.ToolTokens+est.HistoryTokens+est.PromptTokens {
+		t.Errorf("low input %d isn't one call's worth", est.Low.InputTokens)
+	}
+	if est.High.Iterations != 10 || est.Iterations != 3 {
+		t.Errorf("iterations = %d/%d/%d, want 1/3/10",
+			est.Low.Iterations, est.Iterations, est.High.Iterations)
+	}
+	if !(est.Low.Cost < est.TotalCost && est.TotalCost < est.High.Cost) {
+		t.Errorf("costs not ordered: %.3f %.3f %.3f", est.Low.Cost, est.TotalCost, est.High.Cost)
+	}
+}
@@ -0,0 +1,317 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// cron.go - Scheduled runs (claude cron)
+//
+// claude cron turns a playbook or a saved prompt into a crontab line or a
+// pair of systemd user units that run it on a schedule, and with
+// --install puts them in place. The command it schedules has its budgets
+// and output files spelled out, so a run never depends on the defaults of
+// the day. Overlapping runs don't collide: every run takes .claude/lock,
+// and one that finds it taken fails at once (or waits --wait).
+
+// Cron formats (--cron-format)
+const (
+	CronCrontab = "crontab"
+	CronSystemd = "systemd"
+)
+
+// DefaultCronSchedule is the --schedule default: daily at 03:00
+const DefaultCronSchedule = "0 3 * * *"
+
+// cronMarker starts the comment that tags the crontab lines of a job, so
+// installing it again replaces them
+const cronMarker = "# claude cron: "
+
+// CronJob is a scheduled run of claude
+type CronJob struct {
+	Name     string   // of the job: the playbook or prompt name
+	Schedule string   // crontab spec or @daily style shorthand
+	Dir      string   // project directory the run starts in
+	Command  []string // claude and its arguments
+}
+
+// UnitName returns the name of the systemd units of the job
+func (j *CronJob) UnitName() string {
+	return "claude-" + j.Name
+}
+
+// commandLine returns the command of the job quoted for a shell
+func (j *CronJob) commandLine() string {
+	quoted := make([]string, len(j.Command))
+	for i, arg := range j.Command {
+		quoted[i] = shellWord(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellWord quotes s for sh unless it needs none
+func shellWord(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./,:@+") == "" {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// CrontabEntry returns the crontab lines of the job: the marker comment
+// and the entry
+func CrontabEntry(j *CronJob) (string, error) {
+	if _, err := cronFields(j.Schedule); err != nil {
+		return "", err
+	}
+	// % ends the command in crontab lines
+	line := fmt.Sprintf("cd %s && %s", shellWord(j.Dir), j.commandLine())
+	line = strings.ReplaceAll(line, "%", `\%`)
+	return fmt.Sprintf("%s%s %s\n%s %s\n", cronMarker, j.Name, j.Dir, j.Schedule, line), nil
+}
+
+// SystemdUnits returns the service and timer units of the job
+func SystemdUnits(j *CronJob) (service, timer string, err error) {
+	calendar, err := OnCalendar(j.Schedule)
+	if err != nil {
+		return "", "", err
+	}
+	args := make([]string, len(j.Command))
+	for i, arg := range j.Command {
+		// systemd quotes like sh but expands % and $
+		arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+		args[i] = strconv.Quote(arg)
+	}
+	service = fmt.Sprintf(`[Unit]
+Description=claude %s in %s
+
+[Service]
+Type=oneshot
+WorkingDirectory=%s
+ExecStart=%s
+`, j.Name, j.Dir, j.Dir, strings.Join(args, " "))
+	timer = fmt.Sprintf(`[Unit]
+Description=Run claude %s on a schedule
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, j.Name, calendar)
+	return service, timer, nil
+}
+
+// cronShorthands are the @ schedules and their systemd calendar events
+var cronShorthands = map[string]string{
+	"@hourly":   "hourly",
+	"@daily":    "daily",
+	"@midnight": "daily",
+	"@weekly":   "weekly",
+	"@monthly":  "monthly",
+	"@yearly":   "yearly",
+	"@annually": "yearly",
+}
+
+// cronFields splits a crontab schedule into its five fields; a shorthand
+// is returned as the only one
+func cronFields(schedule string) ([]string, error) {
+	if _, ok := cronShorthands[schedule]; ok {
+		return []string{schedule}, nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid --schedule %q: want 5 crontab fields "+
+			"(minute hour day month weekday) or @hourly, @daily, @weekly, @monthly", schedule)
+	}
+	return fields, nil
+}
+
+// OnCalendar converts a crontab schedule to a systemd OnCalendar event.
+// Fields may be *, numbers, lists, ranges and */N steps.
+func OnCalendar(schedule string) (string, error) {
+	fields, err := cronFields(schedule)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 1 {
+		return cronShorthands[fields[0]], nil
+	}
+	minute, hour, day, month, weekday := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	var parts []string
+	for i, f := range []struct {
+		value    string
+		min, max int
+		width    int
+	}{
+		{minute, 0, 59, 2}, {hour, 0, 23, 2}, {day, 1, 31, 2}, {month, 1, 12, 2},
+	} {
+		part, err := calendarField(f.value, f.min, f.max, f.width)
+		if err != nil {
+			return "", fmt.Errorf("invalid --schedule %q, field %d: %w", schedule, i+1, err)
+		}
+		parts = append(parts, part)
+	}
+	event := fmt.Sprintf("*-%s-%s %s:%s:00", parts[3], parts[2], parts[1], parts[0])
+	if weekday != "*" {
+		days, err := calendarWeekdays(weekday)
+		if err != nil {
+			return "", fmt.Errorf("invalid --schedule %q, field 5: %w", schedule, err)
+		}
+		event = days + " " + event
+	}
+	return event, nil
+}
+
+// calendarField converts a crontab field to systemd syntax
+func calendarField(value string, min, max, width int) (string, error) {
+	if value == "*" {
+		return "*", nil
+	}
+	if step, ok := strings.CutPrefix(value, "*/"); ok {
+		n, err := strconv.Atoi(step)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("invalid step %q", value)
+		}
+		return fmt.Sprintf("%0*d/%d", width, min, n), nil
+	}
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		lo, hi, isRange := strings.Cut(item, "-")
+		a, err := cronNumber(lo, min, max)
+		if err != nil {
+			return "", err
+		}
+		if !isRange {
+			out = append(out, fmt.Sprintf("%0*d", width, a))
+			continue
+		}
+		b, err := cronNumber(hi, min, max)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, fmt.Sprintf("%0*d..%0*d", width, a, width, b))
+	}
+	return strings.Join(out, ","), nil
+}
+
+// cronWeekdays are the systemd names of the crontab weekdays, 0 and 7
+// being Sunday
+var cronWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// calendarWeekdays converts a crontab weekday field to systemd names
+func calendarWeekdays(value string) (string, error) {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		lo, hi, isRange := strings.Cut(item, "-")
+		a, err := cronNumber(lo, 0, 7)
+		if err != nil {
+			return "", err
+		}
+		if !isRange {
+			out = append(out, cronWeekdays[a])
+			continue
+		}
+		b, err := cronNumber(hi, 0, 7)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, cronWeekdays[a]+".."+cronWeekdays[b])
+	}
+	return strings.Join(out, ","), nil
+}
+
+// cronNumber parses a number of a crontab field within min and max
+func cronNumber(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%q is not a number from %d to %d", s, min, max)
+	}
+	return n, nil
+}
+
+// CronCommand handles claude cron: prints the crontab entry or systemd
+// units of job, or with install puts them in place
+func CronCommand(job *CronJob, format string, install bool) error {
+	switch format {
+	case CronCrontab:
+		entry, err := CrontabEntry(job)
+		if err != nil {
+			return err
+		}
+		if !install {
+			fmt.Print(entry)
+			return nil
+		}
+		return installCrontab(job, entry)
+	case CronSystemd:
+		service, timer, err := SystemdUnits(job)
+		if err != nil {
+			return err
+		}
+		if !install {
+			fmt.Printf("# %s.service\n%s\n# %s.timer\n%s", job.UnitName(), service,
+				job.UnitName(), timer)
+			return nil
+		}
+		return installSystemd(job, service, timer)
+	}
+	return fmt.Errorf("invalid --cron-format %q (want %s or %s)", format, CronCrontab, CronSystemd)
+}
+
+// installCrontab adds entry to the user's crontab, replacing the lines of
+// an earlier install of the job
+func installCrontab(job *CronJob, entry string) error {
+	// crontab -l fails when there is no crontab yet
+	current, _ := exec.Command("crontab", "-l").Output()
+	var lines []string
+	skip := false
+	for _, line := range strings.Split(strings.TrimRight(string(current), "\n"), "\n") {
+		switch {
+		case skip:
+			skip = false
+		case line == strings.SplitN(entry, "\n", 2)[0]:
+			skip = true // the marker and the entry after it
+		case line != "":
+			lines = append(lines, line)
+		}
+	}
+	table := strings.Join(append(lines, strings.TrimRight(entry, "\n")), "\n") + "\n"
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(table)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	fmt.Fprintf(os.Stderr, "Installed crontab entry for %s: %s\n", job.Name, job.Schedule)
+	return nil
+}
+
+// installSystemd writes the units of job to the systemd user directory
+func installSystemd(job *CronJob, service, timer string) error {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(config, "systemd", "user")
+	if err := storage.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, unit := range map[string]string{".service": service, ".timer": timer} {
+		path := filepath.Join(dir, job.UnitName()+name)
+		if err := storage.WriteFile(path, []byte(unit), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "Enable it with: systemctl --user daemon-reload && "+
+		"systemctl --user enable --now %s.timer\n", job.UnitName())
+	return nil
+}
@@ -0,0 +1,65 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestOnCalendar(t *testing.T) {
+	tests := []struct {
+		schedule, want string
+	}{
+		{"0 3 * * *", "*-*-* 03:00:00"},
+		{"@weekly", "weekly"},
+		{"*/15 9-17 * * 1-5", "Mon..Fri *-*-* 09..17:00/15:00"},
+		{"30 6 1,15 * 0,6", "Sun,Sat *-*-01,15 06:30:00"},
+		{"0 0 * */3 7", "Sun *-01/3-* 00:00:00"},
+		{"60 * * * *", ""},
+		{"0 3 * *", ""},
+		{"@sometimes", ""},
+	}
+	for _, tt := range tests {
+		got, err := claude.OnCalendar(tt.schedule)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("OnCalendar(%q) = %q, want error", tt.schedule, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("OnCalendar(%q) = %q, %v, want %q", tt.schedule, got, err, tt.want)
+		}
+	}
+}
+
+func TestCronEntries(t *testing.T) {
+	job := &claude.CronJob{
+		Name:     "report",
+		Schedule: "@daily",
+		Dir:      "/srv/my project",
+		Command:  []string{"/usr/bin/claude", "prompt", "run", "report", "goal=100% done", "--max-cost=1"},
+	}
+	entry, err := claude.CrontabEntry(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# claude cron: report /srv/my project\n" +
+		`@daily cd '/srv/my project' && /usr/bin/claude prompt run report 'goal=100\% done' --max-cost=1` + "\n"
+	if entry != want {
+		t.Errorf("crontab entry:\n%s\nwant:\n%s", entry, want)
+	}
+
+	service, timer, err := claude.SystemdUnits(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(service, "WorkingDirectory=/srv/my project\n") ||
+		!strings.Contains(service, `ExecStart="/usr/bin/claude" "prompt" "run" "report" "goal=100%% done" "--max-cost=1"`) {
+		t.Errorf("service unit:\n%s", service)
+	}
+	if !strings.Contains(timer, "OnCalendar=daily\n") {
+		t.Errorf("timer unit:\n%s", timer)
+	}
+}
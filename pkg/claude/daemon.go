@@ -0,0 +1,356 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// daemon.go - Daemon mode (--serve) and its thin client (--connect)
+//
+// claude --serve keeps one process running with a warm HTTP client and
+// answers a JSON API on a Unix socket:
+//
+//	POST /v1/turns     run a TurnRequest, streaming TurnEvents as JSON lines
+//	GET  /v1/sessions  list the sessions served so far
+//
+// Turns run one at a time: each one changes to its project directory and
+// loads that project's encryption key, policy and hooks, which are process
+// wide. Tool plugins aren't loaded; sessions that need them run without
+// the daemon. claude --connect sends the prompt of a normal invocation to
+// the daemon and prints what comes back.
+
+// TurnRequest is the body of POST /v1/turns. Unset fields take the value
+// the daemon was started with.
+type TurnRequest struct {
+	Dir           string  `json:"dir"` // absolute project directory
+	Prompt        string  `json:"prompt"`
+	Model         string  `json:"model,omitempty"`
+	Tool          *string `json:"tool,omitempty"` // "" is dry-run
+	MaxCost       float64 `json:"max_cost,omitempty"`
+	MaxIterations int     `json:"max_iterations,omitempty"`
+}
+
+// TurnEvent is one line of the POST /v1/turns response
+type TurnEvent struct {
+	Type    string         `json:"type"` // a TurnEvent* constant
+	Call    *EnvelopeCall  `json:"call,omitempty"`
+	Tools   []EnvelopeTool `json:"tools,omitempty"`
+	Answer  string         `json:"answer,omitempty"`
+	Turn    string         `json:"turn,omitempty"` // timestamp of the saved turn
+	Summary *RunSummary    `json:"summary,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// TurnEvent types
+const (
+	TurnEventCall  = "call"  // a response of the model
+	TurnEventTools = "tools" // the results of its tool calls
+	TurnEventDone  = "done"  // the answer, last event of a turn
+	TurnEventError = "error" // the turn failed, last event
+)
+
+// DaemonSession is an entry of GET /v1/sessions
+type DaemonSession struct {
+	Dir     string    `json:"dir"`
+	Turns   int       `json:"turns"`  // saved in its .claude directory
+	Served  int       `json:"served"` // by this daemon
+	LastRun time.Time `json:"last_run"`
+}
+
+// DefaultSocketPath is where --serve listens and --connect connects
+// without --socket
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "claude.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("claude-%d.sock", os.Getuid()))
+}
+
+// daemon serves the API
+type daemon struct {
+	base                  Options // from the command line of --serve
+	apiURL, defaultPrompt string
+
+	mu       sync.Mutex // one turn at a time
+	sessions map[string]*DaemonSession
+}
+
+// Serve runs the daemon on the Unix socket at socket until ctx is done.
+// base holds the options of every turn; its SemanticSearch allows the tool
+// for projects with an index.
+func Serve(ctx context.Context, socket string, base *Options, apiURL, defaultSystemPrompt string) error {
+	// A socket nobody answers on is left over from a daemon that died
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", socket)
+	}
+	os.Remove(socket)
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", socket, err)
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	d := &daemon{
+		base:          *base,
+		apiURL:        apiURL,
+		defaultPrompt: defaultSystemPrompt,
+		sessions:      make(map[string]*DaemonSession),
+	}
+	d.base.Verbosity = VerbositySilent
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/turns", d.handleTurn)
+	mux.HandleFunc("GET /v1/sessions", d.handleSessions)
+	srv := &http.Server{Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Fprintf(os.Stderr, "Serving on %s\n", socket)
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (d *daemon) handleTurn(w http.ResponseWriter, r *http.Request) {
+	var req TurnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(req.Dir) || strings.TrimSpace(req.Prompt) == "" {
+		http.Error(w, "dir must be absolute and prompt not empty", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(ev TurnEvent) {
+		if err := enc.Encode(ev); err != nil {
+			slog.Warn("daemon: client gone", "err", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	answer, turn, summary, err := d.runTurn(&req, send)
+	d.record(req.Dir, err == nil)
+	if err != nil {
+		send(TurnEvent{Type: TurnEventError, Error: err.Error(), Summary: summary})
+		return
+	}
+	send(TurnEvent{Type: TurnEventDone, Answer: answer, Turn: turn, Summary: summary})
+}
+
+// runTurn runs req in its project directory, sending its progress to send
+func (d *daemon) runTurn(req *TurnRequest, send func(TurnEvent)) (string, string, *RunSummary, error) {
+	prev, err := os.Getwd()
+	if err != nil {
+		return "", "", nil, err
+	}
+	if err := os.Chdir(req.Dir); err != nil {
+		return "", "", nil, err
+	}
+	defer os.Chdir(prev)
+
+	claudeDir := filepath.Join(req.Dir, ".claude")
+	for _, configure := range []func(string) error{
+		ConfigureEncryption, ConfigureRedaction, ConfigureWorkspace,
+		ConfigureCommandLimits, ConfigureHooks,
+	} {
+		if err := configure(claudeDir); err != nil {
+			return "", "", nil, err
+		}
+	}
+	lock, err := storage.AcquireLock(claudeDir, 0)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			slog.Warn("releasing lock", "err", err)
+		}
+	}()
+
+	opts := d.base
+	if req.Model != "" {
+		opts.Model = req.Model
+	}
+	if req.Tool != nil {
+		opts.Tool = *req.Tool
+	}
+	if req.MaxCost > 0 {
+		opts.MaxCost = req.MaxCost
+	}
+	if req.MaxIterations > 0 {
+		opts.MaxIterations = req.MaxIterations
+	}
+	opts.SemanticSearch = d.base.SemanticSearch && storage.HasEmbeddingIndex(claudeDir)
+	opts.TurnModel, req.Prompt = ParseDirective(req.Prompt)
+
+	sess, err := InitSession(&opts, claudeDir, d.apiURL, d.defaultPrompt)
+	if err != nil {
+		return "", "", nil, err
+	}
+	sess.stream = send
+	result, err := ExecuteConversation(sess, req.Prompt)
+	if err != nil {
+		return "", "", sess.Summary(err), err
+	}
+	noOutput := func(string, bool, string, []byte) error { return nil }
+	if err := FinalizeSession(sess, result, storage.SaveJSON, noOutput); err != nil {
+		return "", "", sess.Summary(err), err
+	}
+	return result.assistantText, sess.timestamp, sess.Summary(nil), nil
+}
+
+// record counts a turn served for dir
+func (d *daemon) record(dir string, saved bool) {
+	s, ok := d.sessions[dir]
+	if !ok {
+		s = &DaemonSession{Dir: dir}
+		d.sessions[dir] = s
+	}
+	if saved {
+		s.Served++
+	}
+	s.LastRun = time.Now().UTC()
+}
+
+func (d *daemon) handleSessions(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	list := make([]DaemonSession, 0, len(d.sessions))
+	for _, s := range d.sessions {
+		list = append(list, *s)
+	}
+	d.mu.Unlock()
+
+	for i := range list {
+		pairs, _ := storage.ListRequestResponsePairs(filepath.Join(list[i].Dir, ".claude"))
+		list[i].Turns = len(pairs)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastRun.After(list[j].LastRun) })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// NewDaemonClient returns an HTTP client talking to the daemon on socket.
+// Request URLs need a host, any will do: http://claude/v1/sessions.
+func NewDaemonClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+// ConnectCommand handles --connect: runs req on the daemon at socket,
+// showing the tool calls on stderr and the answer on stdout
+func ConnectCommand(socket string, req *TurnRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := NewDaemonClient(socket).Post("http://claude/v1/turns",
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("connecting to the daemon (claude --serve): %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		return fmt.Errorf("daemon: %s: %s", resp.Status, strings.TrimSpace(msg))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev TurnEvent
+		if err := dec.Decode(&ev); err != nil {
+			return fmt.Errorf("daemon: turn ended without an answer: %w", err)
+		}
+		if done, err := showTurnEvent(&ev); done {
+			if err != nil {
+				return fmt.Errorf("daemon: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// showTurnEvent shows an event of a turn run elsewhere, by the daemon or a
+// job: the tool calls on stderr, the answer on stdout. done is set by the
+// last event of the turn, with the error of a failed turn.
+func showTurnEvent(ev *TurnEvent) (done bool, err error) {
+	switch ev.Type {
+	case TurnEventCall:
+		for _, block := range ev.Call.Content {
+			if block.Type == "tool_use" {
+				ToolHeader(describeToolUse(block), false)
+			}
+		}
+	case TurnEventError:
+		return true, errors.New(ev.Error)
+	case TurnEventDone:
+		FormatResponse(os.Stdout, ev.Answer)
+		if !display.UseColor(os.Stdout) && !strings.HasSuffix(ev.Answer, "\n") {
+			fmt.Println()
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// SessionsCommand handles --connect --sessions: lists the sessions of the
+// daemon at socket
+func SessionsCommand(socket string) error {
+	resp, err := NewDaemonClient(socket).Get("http://claude/v1/sessions")
+	if err != nil {
+		return fmt.Errorf("connecting to the daemon (claude --serve): %w", err)
+	}
+	defer resp.Body.Close()
+	var list []DaemonSession
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(os.Stderr, "No sessions served yet")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%-16s  %6s  %6s  %s\n", "LAST RUN", "TURNS", "SERVED", "DIR")
+	for _, s := range list {
+		fmt.Fprintf(os.Stderr, "%-16s  %6d  %6d  %s\n",
+			s.LastRun.Local().Format("2006-01-02 15:04"), s.Turns, s.Served, s.Dir)
+	}
+	return nil
+}
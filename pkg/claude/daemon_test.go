@@ -0,0 +1,107 @@
+package claude_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestServe(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama9:1b"}]}`))
+		case "/api/chat":
+			w.Write([]byte(`{"message":{"role":"assistant","content":"served"},"done":true}`))
+		}
+	}))
+	defer ollama.Close()
+
+	project := t.TempDir()
+	storage.SaveModelsCache(filepath.Join(project, ".claude"), &storage.ModelsCache{})
+	t.Chdir(t.TempDir()) // the daemon runs elsewhere
+
+	opts := claude.NewOptions()
+	opts.Model = "llama9:1b"
+	opts.OllamaURL = ollama.URL
+	socket := filepath.Join(t.TempDir(), "claude.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- claude.Serve(ctx, socket, opts, "http://unused", "system") }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := claude.NewDaemonClient(socket)
+	body, _ := json.Marshal(claude.TurnRequest{Dir: project, Prompt: "hi"})
+	resp, err := client.Post("http://claude/v1/turns", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []claude.TurnEvent
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev claude.TurnEvent
+		if err := dec.Decode(&ev); err != nil {
+			break
+		}
+		events = append(events, ev)
+	}
+	resp.Body.Close()
+	if len(events) != 2 || events[0].Type != claude.TurnEventCall ||
+		events[1].Type != claude.TurnEventDone {
+		t.Fatalf("events = %+v, want a call and done", events)
+	}
+	if done := events[1]; done.Answer != "served" || done.Turn == "" || done.Summary == nil {
+		t.Errorf("done = %+v", done)
+	}
+
+	// The turn is saved in the project, not where the daemon runs
+	pairs, _ := storage.ListRequestResponsePairs(filepath.Join(project, ".claude"))
+	if len(pairs) != 1 || pairs[0] != events[1].Turn {
+		t.Errorf("project turns = %v", pairs)
+	}
+
+	resp, err = client.Get("http://claude/v1/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var sessions []claude.DaemonSession
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Dir != project || sessions[0].Turns != 1 ||
+		sessions[0].Served != 1 {
+		t.Errorf("sessions = %+v", sessions)
+	}
+
+	// A relative directory is refused
+	body, _ = json.Marshal(claude.TurnRequest{Dir: "rel", Prompt: "hi"})
+	resp, err = client.Post("http://claude/v1/turns", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("relative dir: status %s", resp.Status)
+	}
+}
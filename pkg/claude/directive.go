@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Prompt directives
+//
+// A first prompt line of the form @MODEL runs that turn on another model
+// without touching the one remembered in config.json: a model name or
+// alias (@haiku, @llama3.1:8b), @claude-FAMILY (@claude-opus), or one of
+// the directives below. The line is stripped before the prompt is sent.
+
+const (
+	DirectiveClaude = "claude" // the default Claude model
+	DirectiveLocal  = "local"  // the project's Ollama model
+)
+
+// directiveLine matches a whole first line that is a directive
+var directiveLine = regexp.MustCompile(`^@([A-Za-z0-9][A-Za-z0-9._:/-]*)$`)
+
+// ParseDirective splits a leading @MODEL line off prompt. model is "" and
+// rest the prompt unchanged when the first line is no directive.
+func ParseDirective(prompt string) (model, rest string) {
+	first, rest, _ := strings.Cut(prompt, "\n")
+	m := directiveLine.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return "", prompt
+	}
+	return m[1], strings.TrimLeft(rest, "\r\n")
+}
+
+// ResolveDirective returns the model a directive selects. @local is the
+// --model or remembered model (cfgModel) when that is an Ollama model,
+// otherwise the first Ollama model in the models cache.
+func ResolveDirective(directive string, opts *Options, cfgModel, claudeDir string) (string, error) {
+	switch family := strings.TrimPrefix(directive, "claude-"); {
+	case directive == DirectiveClaude:
+		return DefaultModel, nil
+	case directive == DirectiveLocal:
+		return localModel(opts, cfgModel, claudeDir)
+	case family != directive && slices.Contains(modelFamilies, family):
+		return ResolveModelAlias(family, claudeDir)
+	default:
+		return ResolveModelAlias(directive, claudeDir)
+	}
+}
+
+// localModel returns the Ollama model @local selects
+func localModel(opts *Options, cfgModel, claudeDir string) (string, error) {
+	for _, model := range []string{opts.Model, cfgModel} {
+		if model != "" && !isClaudeModel(model, opts.Provider) && !isFakeModel(model) &&
+			!slices.Contains(modelFamilies, model) && model != ModelAliasLatest {
+			return model, nil
+		}
+	}
+	for _, m := range knownModels(claudeDir) {
+		if m.Provider == ProviderOllama {
+			return m.Name, nil
+		}
+	}
+	return "", fmt.Errorf("@%s: no Ollama model (use --model once or run --models-refresh)",
+		DirectiveLocal)
+}
@@ -0,0 +1,95 @@
+package claude_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestParseDirective(t *testing.T) {
+	tests := []struct {
+		prompt, model, rest string
+	}{
+		{"@claude-opus\nrefactor this", "claude-opus", "refactor this"},
+		{"@local\r\n\nexplain", "local", "explain"},
+		{"  @llama3.1:8b  \nhi", "llama3.1:8b", "hi"},
+		{"@local", "local", ""},
+		{"@alice please review", "", "@alice please review"},
+		{"email me @ noon\nok", "", "email me @ noon\nok"},
+		{"no directive", "", "no directive"},
+	}
+	for _, tt := range tests {
+		model, rest := claude.ParseDirective(tt.prompt)
+		if model != tt.model || rest != tt.rest {
+			t.Errorf("ParseDirective(%q) = %q, %q; want %q, %q",
+				tt.prompt, model, rest, tt.model, tt.rest)
+		}
+	}
+}
+
+func TestResolveDirective(t *testing.T) {
+	claudeDir := t.TempDir()
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{Models: []llm.ModelInfo{
+		{Name: "claude-opus-4-20250514", Provider: "claude"},
+		{Name: "qwen2.5:7b", Provider: "ollama"},
+	}})
+	opts := claude.NewOptions()
+
+	tests := []struct {
+		directive, cfgModel, want string
+	}{
+		{"claude", "", claude.DefaultModel},
+		{"claude-opus", "", "claude-opus-4-20250514"},
+		{"opus", "", "claude-opus-4-20250514"},
+		{"local", "llama3.1:8b", "llama3.1:8b"},
+		{"local", claude.DefaultModel, "qwen2.5:7b"},
+		{"mistral:7b", "", "mistral:7b"},
+	}
+	for _, tt := range tests {
+		got, err := claude.ResolveDirective(tt.directive, opts, tt.cfgModel, claudeDir)
+		if err != nil || got != tt.want {
+			t.Errorf("@%s (config %q) = %q, %v; want %q", tt.directive, tt.cfgModel, got, err, tt.want)
+		}
+	}
+
+	if _, err := claude.ResolveDirective("local", opts, "", t.TempDir()); err == nil {
+		t.Error("@local without an Ollama model: no error")
+	}
+}
+
+func TestTurnModel(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.TurnModel = "fake:hello"
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := claude.ExecuteConversation(sess, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(string, bool, string, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model := sess.Summary(nil).Model; model != "fake:hello" {
+		t.Errorf("turn ran on %s", model)
+	}
+	// The next turn is back on the remembered model
+	if cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json")); cfg.Model != claude.DefaultModel {
+		t.Errorf("config model = %s, want %s", cfg.Model, claude.DefaultModel)
+	}
+}
@@ -1,10 +1,9 @@
 package claude
 
 import (
-	"os"
+	"fmt"
 
 	"github.com/marcopeereboom/go-claude/pkg/display"
-	"golang.org/x/term"
 )
 
 // Re-export display functions for backward compatibility
@@ -17,7 +16,13 @@ var (
 	Info           = display.Info
 )
 
-// IsTTY detects if output is going to a terminal (not a file/pipe)
-func IsTTY(f *os.File) bool {
-	return term.IsTerminal(int(f.Fd()))
+// waitProgress shows a status line while an LLM call is in flight, unless
+// output is silenced or the --watch panel shows it. Call the returned func
+// when the call returns.
+func waitProgress(opts *Options, provider, model string, iteration int) func() {
+	if opts.IsSilent() || opts.Watch {
+		return func() {}
+	}
+	return display.StartSpinner(fmt.Sprintf("Waiting for %s (%s, iteration %d)",
+		provider, model, iteration)).Stop
 }
@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+const (
+	// EnvEncryptionKey holds a base64 encoded 32-byte key (key_source "env")
+	EnvEncryptionKey = "CLAUDE_ENCRYPTION_KEY"
+	// EnvPassphrase holds the passphrase keys are derived from
+	EnvPassphrase = "CLAUDE_PASSPHRASE"
+
+	// Encryption key sources
+	KeySourcePassphrase = "passphrase"
+	KeySourceEnv        = "env"
+	KeySourceKeyring    = "keyring"
+
+	// keyringService is the service name used in the OS keyring
+	keyringService = "go-claude"
+)
+
+// ConfigureEncryption enables encryption at rest for claudeDir when
+// config.json has {"encryption": {"enabled": true}}. The key comes from
+// key_source: a passphrase in CLAUDE_PASSPHRASE (default), a raw key in
+// CLAUDE_ENCRYPTION_KEY, or a passphrase stored in the OS keyring.
+// A salt is generated and saved to config on first use.
+func ConfigureEncryption(claudeDir string) error {
+	configPath := filepath.Join(claudeDir, "config.json")
+	cfg := storage.LoadOrCreateConfig(configPath)
+	enc := cfg.Encryption
+	if enc == nil || !enc.Enabled {
+		return storage.SetEncryptionKey(nil)
+	}
+
+	var key []byte
+	switch enc.KeySource {
+	case KeySourceEnv:
+		raw := os.Getenv(EnvEncryptionKey)
+		if raw == "" {
+			return fmt.Errorf("encryption enabled but %s not set", EnvEncryptionKey)
+		}
+		var err error
+		key, err = base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", EnvEncryptionKey, err)
+		}
+
+	case "", KeySourcePassphrase, KeySourceKeyring:
+		var passphrase string
+		if enc.KeySource == KeySourceKeyring {
+			var err error
+			passphrase, err = keyringPassphrase()
+			if err != nil {
+				return err
+			}
+		} else {
+			passphrase = os.Getenv(EnvPassphrase)
+			if passphrase == "" {
+				return fmt.Errorf("encryption enabled but %s not set", EnvPassphrase)
+			}
+		}
+
+		if enc.Salt == "" {
+			salt, err := storage.NewSalt()
+			if err != nil {
+				return err
+			}
+			enc.Salt = base64.StdEncoding.EncodeToString(salt)
+			if err := storage.SaveJSON(configPath, cfg); err != nil {
+				return fmt.Errorf("saving encryption salt: %w", err)
+			}
+		}
+		salt, err := base64.StdEncoding.DecodeString(enc.Salt)
+		if err != nil {
+			return fmt.Errorf("decoding encryption salt: %w", err)
+		}
+		key, err = storage.DeriveKey(passphrase, salt)
+		if err != nil {
+			return fmt.Errorf("deriving key: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("unknown encryption key_source: %s", enc.KeySource)
+	}
+
+	return storage.SetEncryptionKey(key)
+}
+
+// keyringPassphrase looks up the passphrase in the OS keyring: the macOS
+// keychain or the freedesktop secret service (secret-tool) elsewhere.
+func keyringPassphrase() (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password",
+			"-s", keyringService, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase from keyring (%s): %w",
+			cmd.Path, err)
+	}
+	passphrase := strings.TrimRight(string(out), "\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("no %s passphrase in keyring", keyringService)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,67 @@
+package claude_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestConfigureEncryptionPassphrase(t *testing.T) {
+	claudeDir := t.TempDir()
+	configPath := filepath.Join(claudeDir, "config.json")
+	cfg := &storage.Config{Encryption: &storage.EncryptionConfig{Enabled: true}}
+	if err := storage.SaveJSON(configPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.SetEncryptionKey(nil) })
+
+	t.Setenv(claude.EnvPassphrase, "")
+	if err := claude.ConfigureEncryption(claudeDir); err == nil {
+		t.Fatal("expected error when passphrase missing")
+	}
+
+	t.Setenv(claude.EnvPassphrase, "correct horse")
+	if err := claude.ConfigureEncryption(claudeDir); err != nil {
+		t.Fatalf("ConfigureEncryption failed: %v", err)
+	}
+	if !storage.EncryptionEnabled() {
+		t.Fatal("expected encryption to be enabled")
+	}
+
+	// Salt persisted so the same key is derived next time
+	saved := storage.LoadOrCreateConfig(configPath)
+	if saved.Encryption == nil || saved.Encryption.Salt == "" {
+		t.Fatal("expected salt to be saved in config")
+	}
+
+	if err := storage.SaveRequest(claudeDir, "20260105_120000", nil); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(claudeDir, "request_20260105_120000.json"))
+	if !bytes.HasPrefix(raw, []byte("GOCLAUDE-ENC1")) {
+		t.Error("request not encrypted")
+	}
+
+	// Re-derive from the saved salt and read it back
+	storage.SetEncryptionKey(nil)
+	if err := claude.ConfigureEncryption(claudeDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.LoadRequest(filepath.Join(claudeDir, "request_20260105_120000.json")); err != nil {
+		t.Errorf("reading with re-derived key failed: %v", err)
+	}
+}
+
+func TestConfigureEncryptionDisabled(t *testing.T) {
+	claudeDir := t.TempDir()
+	if err := claude.ConfigureEncryption(claudeDir); err != nil {
+		t.Fatalf("ConfigureEncryption failed: %v", err)
+	}
+	if storage.EncryptionEnabled() {
+		t.Error("encryption should be off without config")
+	}
+}
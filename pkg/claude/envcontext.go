@@ -0,0 +1,40 @@
+package claude
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// envcontext.go - Environment header for the system prompt (--env-context)
+//
+// Without it models take today's date from their training data and write
+// commands for whatever OS they guess. The header is a few facts about the
+// run; the git branch is left out outside a repository.
+
+// EnvContext returns the environment header of a run in workingDir at now
+func EnvContext(workingDir string, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("\n\nEnvironment:\n")
+	fmt.Fprintf(&b, "- Date: %s\n", now.Format("Monday, 2006-01-02 15:04 MST (-07:00)"))
+	fmt.Fprintf(&b, "- OS: %s/%s (bash_command runs bash)\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "- Working directory: %s\n", workingDir)
+	if branch := gitBranch(workingDir); branch != "" {
+		fmt.Fprintf(&b, "- Git branch: %s\n", branch)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// gitBranch returns the checked out branch of the repository at dir, the
+// commit when detached, or "" outside a repository
+func gitBranch(dir string) string {
+	if branch, err := git(dir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		return branch
+	}
+	commit, err := git(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return "detached at " + commit
+}
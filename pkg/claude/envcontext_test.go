@@ -0,0 +1,41 @@
+package claude_test
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestEnvContext(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+
+	header := claude.EnvContext(dir, now)
+	for _, want := range []string{
+		"Environment:",
+		"- Date: Monday, 2026-03-02 09:30 UTC (+00:00)",
+		"- OS: " + runtime.GOOS + "/" + runtime.GOARCH,
+		"- Working directory: " + dir,
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header lacks %q:\n%s", want, header)
+		}
+	}
+	if strings.Contains(header, "Git branch") {
+		t.Errorf("git branch outside a repository:\n%s", header)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if out, err := exec.Command("git", "-C", dir, "init", "-q", "-b", "feature-x").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if header := claude.EnvContext(dir, now); !strings.HasSuffix(header, "- Git branch: feature-x") {
+		t.Errorf("header =\n%s", header)
+	}
+}
@@ -0,0 +1,129 @@
+package claude
+
+import (
+	"strings"
+	"time"
+)
+
+// envelope.go - Combined JSON output (--output=json-full)
+//
+// --output=json prints the final API response only. --output=json-full
+// prints one document with everything about the turn: the prompt, every
+// call with its usage and cost, the tool calls with their results, the
+// totals and timing, so tooling gets it all from a single parse of stdout.
+
+// Envelope is the --output=json-full document of a turn
+type Envelope struct {
+	ConversationID string         `json:"conversation_id"`
+	Prompt         string         `json:"prompt"`
+	Answer         string         `json:"answer"`
+	Model          string         `json:"model"`
+	Provider       string         `json:"provider"`
+	Iterations     []EnvelopeCall `json:"iterations"`
+	Usage          Usage          `json:"usage"`
+	Cost           float64        `json:"cost"`
+	ToolsExecuted  map[string]int `json:"tools_executed"`
+	FilesChanged   []string       `json:"files_changed"`
+	Started        time.Time      `json:"started"`
+	DurationMs     int64          `json:"duration_ms"`
+
+	stream func(TurnEvent) // sent each call and its tools as they happen
+}
+
+// EnvelopeCall is one LLM call of the turn and the tools it ran
+type EnvelopeCall struct {
+	Model      string         `json:"model"`
+	StopReason string         `json:"stop_reason"`
+	Content    []ContentBlock `json:"content"`
+	Usage      Usage          `json:"usage"`
+	Cost       float64        `json:"cost"`
+	DurationMs int64          `json:"duration_ms"`
+	ToolCalls  []EnvelopeTool `json:"tool_calls,omitempty"`
+}
+
+// EnvelopeTool is a tool call with the result the model got back, before
+// --compress-results shortened it
+type EnvelopeTool struct {
+	ID     string                 `json:"id"`
+	Name   string                 `json:"name"`
+	Input  map[string]interface{} `json:"input"`
+	Result string                 `json:"result"`
+	Error  bool                   `json:"error,omitempty"`
+}
+
+// startEnvelope begins the envelope of an --output=json-full turn, or of
+// a turn streamed to a --serve client
+func (s *session) startEnvelope(prompt string) {
+	if s.opts.Output != OutputJSONFull && s.stream == nil {
+		return
+	}
+	s.envelope = &Envelope{
+		ConversationID: s.timestamp,
+		Prompt:         prompt,
+		Started:        time.Now().UTC(),
+		stream:         s.stream,
+	}
+}
+
+// addCall records a response that took elapsed; a nil envelope records
+// nothing
+func (e *Envelope) addCall(resp *APIResponse, cost float64, elapsed time.Duration) {
+	if e == nil {
+		return
+	}
+	e.Iterations = append(e.Iterations, EnvelopeCall{
+		Model:      resp.Model,
+		StopReason: resp.StopReason,
+		Content:    resp.Content,
+		Usage:      resp.Usage,
+		Cost:       cost,
+		DurationMs: elapsed.Milliseconds(),
+	})
+	if e.stream != nil {
+		call := e.Iterations[len(e.Iterations)-1]
+		e.stream(TurnEvent{Type: TurnEventCall, Call: &call})
+	}
+}
+
+// addTools records the results of the tool calls of the last response
+func (e *Envelope) addTools(calls, results []ContentBlock) {
+	if e == nil || len(e.Iterations) == 0 {
+		return
+	}
+	byID := make(map[string]ContentBlock, len(results))
+	for _, res := range results {
+		byID[res.ToolUseID] = res
+	}
+	last := &e.Iterations[len(e.Iterations)-1]
+	for _, call := range calls {
+		if call.Type != "tool_use" {
+			continue
+		}
+		res := byID[call.ID]
+		last.ToolCalls = append(last.ToolCalls, EnvelopeTool{
+			ID:     call.ID,
+			Name:   call.Name,
+			Input:  call.Input,
+			Result: res.Content,
+			Error:  strings.HasPrefix(res.Content, "Error: "),
+		})
+	}
+	if e.stream != nil && len(last.ToolCalls) > 0 {
+		e.stream(TurnEvent{Type: TurnEventTools, Tools: last.ToolCalls})
+	}
+}
+
+// finish completes the envelope with the answer and the run totals
+func (e *Envelope) finish(answer string, summary *RunSummary) {
+	if e == nil {
+		return
+	}
+	e.Answer = answer
+	e.Model = summary.Model
+	e.Provider = summary.Provider
+	e.Usage = Usage{InputTokens: summary.InputTokens, OutputTokens: summary.OutputTokens}
+	e.Cost = summary.Cost
+	e.ToolsExecuted = summary.ToolsExecuted
+	e.FilesChanged = summary.FilesChanged
+	e.DurationMs = time.Since(e.Started).Milliseconds()
+}
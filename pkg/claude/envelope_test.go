@@ -0,0 +1,79 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestJSONFullOutput(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{"main.go": "package main\n"})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	answer := textResponse("it is package main", "end_turn")
+	answer.Usage = llm.Usage{InputTokens: 200, OutputTokens: 20}
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{Content: []claude.ContentBlock{
+			{Type: "text", Text: "Let me look."},
+			{Type: "tool_use", ID: "r1", Name: "read_file",
+				Input: map[string]interface{}{"path": "main.go"}},
+			{Type: "tool_use", ID: "r2", Name: "read_file",
+				Input: map[string]interface{}{"path": "missing.go"}},
+		}, StopReason: "tool_use", Usage: llm.Usage{InputTokens: 100, OutputTokens: 10}},
+		answer,
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Output = claude.OutputJSONFull
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.SetLLM(mock)
+	result, err := claude.ExecuteConversation(sess, "which package?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []byte
+	var asJSON bool
+	err = claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(_ string, jsonOutput bool, _ string, body []byte) error {
+			out, asJSON = body, jsonOutput
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var env claude.Envelope
+	if err := json.Unmarshal(out, &env); err != nil || !asJSON {
+		t.Fatalf("output is not a JSON envelope (%v):\n%s", err, out)
+	}
+	if env.Prompt != "which package?" || env.Answer != "it is package main" ||
+		env.ConversationID == "" || env.Started.IsZero() {
+		t.Errorf("envelope = %+v", env)
+	}
+	if env.Usage.InputTokens != 300 || env.Usage.OutputTokens != 30 || env.Cost <= 0 {
+		t.Errorf("totals = %+v $%f, want 300/30 tokens", env.Usage, env.Cost)
+	}
+	if len(env.Iterations) != 2 || env.Iterations[0].StopReason != "tool_use" ||
+		env.Iterations[1].StopReason != "end_turn" {
+		t.Fatalf("iterations = %+v", env.Iterations)
+	}
+	calls := env.Iterations[0].ToolCalls
+	if len(calls) != 2 || calls[0].Name != "read_file" || calls[0].Input["path"] != "main.go" ||
+		calls[0].Result != "package main\n" || calls[0].Error || !calls[1].Error {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if env.ToolsExecuted["read_file"] != 2 {
+		t.Errorf("tools_executed = %v", env.ToolsExecuted)
+	}
+}
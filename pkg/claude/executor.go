@@ -0,0 +1,146 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// executor.go - Tool registry
+//
+// Every tool the LLM can call is a ToolExecutor in a registry. The
+// built-ins (read_file, write_file, bash_command) are registered at init;
+// programs embedding this package add their own with RegisterTool and
+// external executables are added from config (see plugin.go).
+
+// ToolCall is one tool_use request together with the run state needed to
+// execute it
+type ToolCall struct {
+	Use            ContentBlock // the tool_use block
+	WorkingDir     string
+	ClaudeDir      string
+	Opts           *Options
+	ConversationID string
+}
+
+// ToolExecutor implements a tool. Execute returns the tool_result block;
+// tool failures the LLM should see are results (see makeToolError), while
+// a returned error aborts the conversation.
+type ToolExecutor interface {
+	Name() string
+	Schema() Tool
+	Execute(ctx context.Context, call ToolCall) (ContentBlock, error)
+}
+
+// optionalTool is implemented by tools only offered under some options
+type optionalTool interface {
+	Available(opts *Options) bool
+}
+
+// ToolRegistry holds tools by name in registration order
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]ToolExecutor
+	order []string
+}
+
+// NewToolRegistry returns an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]ToolExecutor)}
+}
+
+// Register adds t. Names must be unique.
+func (r *ToolRegistry) Register(t ToolExecutor) error {
+	name := t.Name()
+	if name == "" {
+		return fmt.Errorf("tool has no name")
+	}
+	if t.Schema().Name != name {
+		return fmt.Errorf("tool %s: schema name %q doesn't match",
+			name, t.Schema().Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; ok {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	r.tools[name] = t
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Lookup returns the tool registered as name
+func (r *ToolRegistry) Lookup(name string) (ToolExecutor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Tools returns the schemas of all registered tools
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemas := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		schemas = append(schemas, r.tools[name].Schema())
+	}
+	return schemas
+}
+
+// Available returns the schemas of the tools offered under opts
+func (r *ToolRegistry) Available(opts *Options) []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemas := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		if o, ok := t.(optionalTool); ok && !o.Available(opts) {
+			continue
+		}
+		schemas = append(schemas, t.Schema())
+	}
+	return schemas
+}
+
+// tools is the registry used by GetTools and ExecuteTools
+var tools = newBuiltinRegistry()
+
+func newBuiltinRegistry() *ToolRegistry {
+	r := NewToolRegistry()
+	for _, t := range builtinTools() {
+		if err := r.Register(t); err != nil {
+			panic(err) // duplicate built-in: programming error
+		}
+	}
+	return r
+}
+
+// RegisterTool makes t available to the LLM in addition to the built-ins
+func RegisterTool(t ToolExecutor) error {
+	return tools.Register(t)
+}
+
+// builtinFunc is the signature of the built-in Execute* tool functions
+type builtinFunc func(toolUse ContentBlock, workingDir, claudeDir string,
+	opts *Options, conversationID string) (ContentBlock, error)
+
+// builtinTool adapts an Execute* function to ToolExecutor
+type builtinTool struct {
+	schema    Tool
+	run       builtinFunc
+	available func(opts *Options) bool // nil = always offered
+}
+
+func (b *builtinTool) Name() string { return b.schema.Name }
+func (b *builtinTool) Schema() Tool { return b.schema }
+
+func (b *builtinTool) Available(opts *Options) bool {
+	return b.available == nil || b.available(opts)
+}
+
+func (b *builtinTool) Execute(ctx context.Context, call ToolCall) (ContentBlock, error) {
+	return b.run(call.Use, call.WorkingDir, call.ClaudeDir, call.Opts,
+		call.ConversationID)
+}
@@ -0,0 +1,111 @@
+package claude_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+// echoTool returns its "text" input
+type echoTool struct{ name string }
+
+func (e *echoTool) Name() string { return e.name }
+
+func (e *echoTool) Schema() claude.Tool {
+	return claude.Tool{
+		Name:        e.name,
+		Description: "Echo text back",
+		InputSchema: map[string]interface{}{"type": "object"},
+	}
+}
+
+func (e *echoTool) Execute(ctx context.Context, call claude.ToolCall) (claude.ContentBlock, error) {
+	text, _ := call.Use.Input["text"].(string)
+	return claude.ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: call.Use.ID,
+		Content:   "echo: " + text,
+	}, nil
+}
+
+var registerEcho sync.Once
+
+func TestToolRegistry(t *testing.T) {
+	r := claude.NewToolRegistry()
+	if err := r.Register(&echoTool{name: "echo"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := r.Register(&echoTool{name: "echo"}); err == nil {
+		t.Error("expected error for duplicate tool")
+	}
+	if err := r.Register(&echoTool{name: ""}); err == nil {
+		t.Error("expected error for unnamed tool")
+	}
+
+	if _, ok := r.Lookup("echo"); !ok {
+		t.Error("echo not found")
+	}
+	if _, ok := r.Lookup("missing"); ok {
+		t.Error("unexpected tool found")
+	}
+	if tools := r.Tools(); len(tools) != 1 || tools[0].Name != "echo" {
+		t.Errorf("Tools() = %+v", tools)
+	}
+}
+
+func TestRegisterTool(t *testing.T) {
+	if err := claude.RegisterTool(&echoTool{name: "read_file"}); err == nil {
+		t.Error("expected error shadowing a built-in tool")
+	}
+
+	// The registry is global: register once however often tests run
+	registerEcho.Do(func() {
+		if err := claude.RegisterTool(&echoTool{name: "test_echo"}); err != nil {
+			t.Fatalf("RegisterTool: %v", err)
+		}
+	})
+
+	var names []string
+	for _, tool := range claude.GetTools(claude.NewOptions()) {
+		names = append(names, tool.Name)
+	}
+	// Built-ins first; other tests register more tools
+	want := []string{"read_file", "write_file", "bash_command"}
+	if len(names) < len(want) {
+		t.Fatalf("GetTools() = %v, want %v first", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("GetTools()[%d] = %s, want %s", i, names[i], want[i])
+		}
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == "test_echo"
+	}
+	if !found {
+		t.Errorf("GetTools() = %v, missing test_echo", names)
+	}
+
+	results, err := claude.ExecuteTools([]claude.ContentBlock{{
+		Type:  "tool_use",
+		ID:    "toolu_1",
+		Name:  "test_echo",
+		Input: map[string]interface{}{"text": "hi"},
+	}}, t.TempDir(), t.TempDir(), claude.NewOptions(), "test")
+	if err != nil {
+		t.Fatalf("ExecuteTools: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "echo: hi" {
+		t.Errorf("results = %+v", results)
+	}
+
+	_, err = claude.ExecuteTools([]claude.ContentBlock{{
+		Type: "tool_use", ID: "toolu_2", Name: "no_such_tool",
+	}}, t.TempDir(), t.TempDir(), claude.NewOptions(), "test")
+	if err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
@@ -0,0 +1,64 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestFakeModel(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "") // not needed
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "fake:edit"
+	opts.Tool = claude.ToolWrite
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := claude.ExecuteConversation(sess, "add a readme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AssistantText() != "Created HELLO.md." {
+		t.Errorf("answer = %q", result.AssistantText())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "HELLO.md"))
+	if err != nil || !strings.HasPrefix(string(data), "# Hello") {
+		t.Errorf("HELLO.md = %q, %v", data, err)
+	}
+}
+
+func TestFakeModelLocalScenario(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/fakes/short.json": `{"responses": [{"text": "local"}]}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "fake:short"
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := claude.ExecuteConversation(sess, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AssistantText() != "local" {
+		t.Errorf("answer = %q", result.AssistantText())
+	}
+
+	opts.Model = "fake:missing"
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "system"); err == nil {
+		t.Error("unknown scenario accepted")
+	}
+}
@@ -0,0 +1,191 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// fallback.go - Fallback chain (--fallback-chain, --fallback-on,
+// --fallback-retries)
+//
+// A failed model call is retried on the same model, then the next model
+// of the chain takes over for the rest of the session, e.g.
+// llama3.2:3b -> claude-3-5-haiku -> claude-sonnet-4. Which failures do
+// so is chosen by error class: a timeout of an overloaded local model is
+// worth falling back on, an invalid request isn't.
+
+// Error classes of failed model calls
+const (
+	FallbackOnTimeout   = "timeout" // the request timed out
+	FallbackOnConnect   = "connect" // the provider is unreachable
+	FallbackOnServer    = "5xx"     // server errors, overloaded
+	FallbackOnRateLimit = "429"     // rate limited
+	FallbackOnTools     = "tools"   // the model can't call tools
+	FallbackOnOther     = "other"   // anything else
+)
+
+// fallbackClasses are the valid --fallback-on values
+var fallbackClasses = []string{
+	FallbackOnTimeout, FallbackOnConnect, FallbackOnServer,
+	FallbackOnRateLimit, FallbackOnTools, FallbackOnOther,
+}
+
+// fallbackRetryDelay is the wait before the first retry of a model; each
+// further retry waits twice as long
+var fallbackRetryDelay = 250 * time.Millisecond
+
+// fallbackTier is a model of the fallback chain
+type fallbackTier struct {
+	model    string
+	provider string // ollama or claude, as in telemetry
+	client   llm.LLM
+}
+
+// errorClass returns the class of a failed model call
+func errorClass(err error) string {
+	var apiErr *llm.APIError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, llm.ErrToolsUnsupported):
+		return FallbackOnTools
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FallbackOnTimeout
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return FallbackOnRateLimit
+		case apiErr.StatusCode >= 500:
+			return FallbackOnServer
+		}
+	case errors.As(err, &opErr):
+		return FallbackOnConnect
+	}
+	return FallbackOnOther
+}
+
+// applyFallbackConfig fills in the fallback options not given from the
+// project config
+func applyFallbackConfig(opts *Options, cfg *storage.FallbackConfig) {
+	if cfg == nil {
+		return
+	}
+	if len(opts.FallbackChain) == 0 {
+		opts.FallbackChain = cfg.Chain
+	}
+	if len(opts.FallbackOn) == 0 {
+		opts.FallbackOn = cfg.On
+	}
+	if opts.FallbackRetries == 0 {
+		opts.FallbackRetries = cfg.Retries
+	}
+}
+
+// validateFallback rejects unknown error classes and negative retries
+func validateFallback(opts *Options) error {
+	for _, class := range opts.FallbackOn {
+		if !containsString(fallbackClasses, class) {
+			return fmt.Errorf("--fallback-on: unknown error class %q (want %s)",
+				class, strings.Join(fallbackClasses, ", "))
+		}
+	}
+	if opts.FallbackRetries < 0 {
+		return fmt.Errorf("--fallback-retries must be at least 0")
+	}
+	return nil
+}
+
+// fallbackModels returns the models primary falls back to, in order. A
+// chain naming primary continues after it; without a chain Ollama falls
+// back to FallbackModel.
+func fallbackModels(primary string, opts *Options, claudeDir string) ([]string, error) {
+	if !opts.AllowFallback {
+		return nil, nil
+	}
+	if len(opts.FallbackChain) == 0 {
+		if isClaudeModel(primary, opts.Provider) || isFakeModel(primary) {
+			return nil, nil
+		}
+		if opts.FallbackModel != "" {
+			return []string{opts.FallbackModel}, nil
+		}
+		return []string{DefaultModel}, nil
+	}
+
+	var models []string
+	for _, name := range opts.FallbackChain {
+		model, err := ResolveModelAlias(name, claudeDir)
+		if err != nil {
+			return nil, fmt.Errorf("--fallback-chain: %w", err)
+		}
+		if model == primary {
+			models = nil // start after the primary
+			continue
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// fallbackTiers creates the clients of the chain models
+func fallbackTiers(models []string, opts *Options, claudeDir, apiKey, apiURL string) ([]fallbackTier, error) {
+	tiers := make([]fallbackTier, 0, len(models))
+	for _, model := range models {
+		tier := fallbackTier{model: model, provider: "ollama"}
+		switch {
+		case isFakeModel(model):
+			client, err := newFakeLLM(model, claudeDir)
+			if err != nil {
+				return nil, err
+			}
+			tier.client, tier.provider = client, ProviderFake
+		case isClaudeModel(model, opts.Provider):
+			client, err := newClaudeLLM(opts.Provider, apiKey, apiURL)
+			if err != nil {
+				return nil, err
+			}
+			tier.client, tier.provider = client, "claude"
+		default:
+			tier.client = llm.NewOllama(model, opts.OllamaURL)
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// fallsBackOn reports whether errors of class are retried and fall back
+func (o *Options) fallsBackOn(class string) bool {
+	return len(o.FallbackOn) == 0 || containsString(o.FallbackOn, class)
+}
+
+// nextFallback removes and returns the next model of the chain
+func (s *session) nextFallback() (fallbackTier, bool) {
+	if len(s.fallbacks) == 0 {
+		return fallbackTier{}, false
+	}
+	tier := s.fallbacks[0]
+	s.fallbacks = s.fallbacks[1:]
+	s.fellBack = append(s.fellBack, tier.model)
+	return tier, true
+}
+
+// retryWait waits before retry n of a failed call; false when ctx ends
+func retryWait(ctx context.Context, n int) bool {
+	t := time.NewTimer(fallbackRetryDelay << (n - 1))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
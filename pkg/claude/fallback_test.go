@@ -2,9 +2,15 @@ package claude_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/marcopeereboom/go-claude/pkg/claude"
@@ -164,3 +170,243 @@ func TestFallbackLogging(t *testing.T) {
 		t.Error("Expected verbose mode to be enabled")
 	}
 }
+
+func TestOllamaAutoPull(t *testing.T) {
+	pulled := false
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/pull":
+			pulled = true
+			w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"status":"success"}` + "\n"))
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama9:1b"}]}`))
+		case "/api/chat":
+			if !pulled {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"model \"llama9:1b\" not found, try pulling it first"}`))
+				return
+			}
+			w.Write([]byte(`{"message":{"role":"assistant","content":"pulled and answered"},"done":true}`))
+		}
+	}))
+	defer ollama.Close()
+
+	for _, autoPull := range []bool{false, true} {
+		t.Run(fmt.Sprint("auto-pull=", autoPull), func(t *testing.T) {
+			pulled = false
+			workDir := t.TempDir()
+			claudeDir := filepath.Join(workDir, ".claude")
+			t.Chdir(workDir)
+			t.Setenv("ANTHROPIC_API_KEY", "test-key")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+
+			opts := claude.NewOptions()
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Model = "llama9:1b"
+			opts.OllamaURL = ollama.URL
+			opts.AllowFallback = false
+			opts.OllamaAutoPull = autoPull
+
+			sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+			if err != nil {
+				t.Fatalf("InitSession: %v", err)
+			}
+			result, err := claude.ExecuteConversation(sess, "hi")
+			if !autoPull {
+				if !errors.Is(err, llm.ErrModelNotFound) || pulled {
+					t.Fatalf("without auto-pull: err = %v, pulled = %v", err, pulled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExecuteConversation: %v", err)
+			}
+			if result.AssistantText() != "pulled and answered" {
+				t.Errorf("answer = %q", result.AssistantText())
+			}
+		})
+	}
+}
+
+func TestOllamaUnreachableAtInit(t *testing.T) {
+	// A closed server: connection refused
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	for _, fallback := range []bool{false, true} {
+		t.Run(fmt.Sprint("fallback=", fallback), func(t *testing.T) {
+			workDir := t.TempDir()
+			claudeDir := filepath.Join(workDir, ".claude")
+			t.Chdir(workDir)
+			t.Setenv("ANTHROPIC_API_KEY", "test-key")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+
+			opts := claude.NewOptions()
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Model = "llama9:1b"
+			opts.OllamaURL = down.URL
+			opts.AllowFallback = fallback
+
+			sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+			if !fallback {
+				if err == nil || !strings.Contains(err.Error(), "ollama serve") ||
+					!strings.Contains(err.Error(), down.URL) {
+					t.Fatalf("InitSession error = %v, want actionable Ollama error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InitSession: %v", err)
+			}
+			// The run goes straight to Claude
+			summary := sess.Summary(nil)
+			if summary.Model != claude.DefaultModel || summary.Provider != claude.ProviderClaude {
+				t.Errorf("session uses %s/%s, want fallback %s", summary.Provider,
+					summary.Model, claude.DefaultModel)
+			}
+		})
+	}
+}
+
+// fallbackServers starts an Ollama whose chats fail with ollamaStatus and
+// an Anthropic API that overloads haiku and answers for other models
+func fallbackServers(t *testing.T, ollamaStatus int) (ollama, anthropic *httptest.Server, calls map[string]*atomic.Int32) {
+	t.Helper()
+	calls = map[string]*atomic.Int32{"ollama": {}, "haiku": {}, "sonnet": {}}
+	ollama = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			w.Write([]byte(`{"models":[]}`))
+			return
+		}
+		calls["ollama"].Add(1)
+		http.Error(w, `{"error":"boom"}`, ollamaStatus)
+	}))
+	anthropic = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Model, "haiku") {
+			calls["haiku"].Add(1)
+			w.WriteHeader(529)
+			w.Write([]byte(`{"error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		calls["sonnet"].Add(1)
+		w.Write([]byte(`{"content":[{"type":"text","text":"from ` + req.Model + `"}],` +
+			`"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(ollama.Close)
+	t.Cleanup(anthropic.Close)
+	return ollama, anthropic, calls
+}
+
+func runFallback(t *testing.T, opts *claude.Options, apiURL string) (string, error) {
+	t.Helper()
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	t.Chdir(workDir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+
+	sess, err := claude.InitSession(opts, claudeDir, apiURL, "system")
+	if err != nil {
+		return "", err
+	}
+	result, err := claude.ExecuteConversation(sess, "hi")
+	if err != nil {
+		return "", err
+	}
+	return result.AssistantText(), nil
+}
+
+func TestFallbackChain(t *testing.T) {
+	ollama, anthropic, calls := fallbackServers(t, http.StatusInternalServerError)
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "llama9:1b"
+	opts.OllamaURL = ollama.URL
+	opts.FallbackChain = []string{"llama9:1b", "claude-3-5-haiku-20241022", "claude-sonnet-4-20250514"}
+	opts.FallbackOn = []string{claude.FallbackOnServer}
+	opts.FallbackRetries = 1
+
+	answer, err := runFallback(t, opts, anthropic.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "from claude-sonnet-4-20250514" {
+		t.Errorf("answer = %q", answer)
+	}
+	// Each model is retried once before the next takes over
+	if n, h, s := calls["ollama"].Load(), calls["haiku"].Load(), calls["sonnet"].Load(); n != 2 || h != 2 || s != 1 {
+		t.Errorf("calls: ollama %d, haiku %d, sonnet %d; want 2, 2, 1", n, h, s)
+	}
+}
+
+func TestFallbackOnClass(t *testing.T) {
+	ollama, anthropic, calls := fallbackServers(t, http.StatusBadRequest)
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "llama9:1b"
+	opts.OllamaURL = ollama.URL
+	opts.FallbackOn = []string{claude.FallbackOnTimeout, claude.FallbackOnServer}
+
+	// An invalid request isn't worth another model
+	if _, err := runFallback(t, opts, anthropic.URL); err == nil {
+		t.Fatal("no error")
+	}
+	if n := calls["sonnet"].Load(); n != 0 {
+		t.Errorf("fell back %d times", n)
+	}
+
+	// Without --fallback-on every failure falls back, as before
+	opts.FallbackOn = nil
+	answer, err := runFallback(t, opts, anthropic.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "from "+claude.DefaultModel {
+		t.Errorf("answer = %q", answer)
+	}
+
+	opts.FallbackOn = []string{"5XX"}
+	if _, err := runFallback(t, opts, anthropic.URL); err == nil ||
+		!strings.Contains(err.Error(), "unknown error class") {
+		t.Errorf("err = %v, want unknown class", err)
+	}
+}
+
+func TestFallbackTeachesRouter(t *testing.T) {
+	ollama, anthropic, _ := fallbackServers(t, http.StatusInternalServerError)
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "llama9:1b"
+	opts.OllamaURL = ollama.URL
+
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	t.Chdir(workDir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+
+	sess, err := claude.InitSession(opts, claudeDir, anthropic.URL, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := claude.ExecuteConversation(sess, "Write a function to calculate fibonacci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(string, bool, string, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	if o := cfg.RoutingOutcomes["moderate"]; o.Runs != 1 || o.Fallbacks != 1 || o.Iterations != 1 {
+		t.Errorf("moderate outcome = %+v", o)
+	}
+}
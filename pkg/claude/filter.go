@@ -0,0 +1,154 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// filter.go - Editor filter mode (--instruction)
+//
+// claude --instruction=TEXT reads a region of code on stdin and prints it
+// transformed by TEXT, and nothing else, so editors can filter a selection
+// through it:
+//
+//	:'<,'>!claude --instruction="add error handling"
+//
+// The call has no tools and isn't saved as a turn. Models add prose and
+// fences despite being told not to, so the answer is cleaned up before it
+// is printed. On any failure the region is printed unchanged: an editor
+// replaces the selection with whatever the filter prints.
+
+// filterPrompt is the system prompt of --instruction
+const filterPrompt = `You are a code transformation filter inside a text editor. ` +
+	`The user message holds an instruction and a region of code. Reply with the ` +
+	`complete transformed region and nothing else: no explanation, no markdown ` +
+	`fences, no comments about the change. Keep the indentation and style of ` +
+	`the region. If nothing needs to change, reply with the region unchanged.`
+
+var (
+	// fencedBlock matches a markdown code block
+	fencedBlock = regexp.MustCompile("(?ms)^[ \t]*```[^\n]*\n(.*?)^[ \t]*```[ \t]*$")
+
+	// proseIntro and proseOutro match what models say before and after
+	// the code
+	proseIntro = regexp.MustCompile(`(?i)^(here('s| is| are)|sure|certainly|of course|okay|ok|below|i('ve| have)|the (updated|transformed|modified))\b.*$`)
+	proseOutro = regexp.MustCompile(`(?i)^(note|this (code|version|change)|i('ve| have)|the (changes|code above)|explanation|changes( made)?:)\b`)
+)
+
+// FilterCommand handles --instruction: transforms the region read from in
+// and writes the result, or the region itself on failure, to out
+func FilterCommand(ctx context.Context, opts *Options, claudeDir, apiURL, instruction string,
+	in io.Reader, out io.Writer,
+) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("reading stdin: %w", err)
+	}
+	region := string(data)
+	if strings.TrimSpace(region) == "" {
+		_, err := io.WriteString(out, region)
+		return err
+	}
+
+	code, err := transformRegion(ctx, opts, claudeDir, apiURL, instruction, region)
+	if err != nil {
+		// Leave the selection as it was
+		if _, werr := io.WriteString(out, region); werr != nil {
+			slog.Warn("writing the region back", "err", werr)
+		}
+		return fmt.Errorf("--instruction: %w", err)
+	}
+	_, err = io.WriteString(out, code)
+	return err
+}
+
+// transformRegion asks the model to apply instruction to region and
+// returns the cleaned answer
+func transformRegion(ctx context.Context, opts *Options, claudeDir, apiURL, instruction, region string) (string, error) {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	model, err := ResolveModelAlias(SelectModel(opts.Model, cfg.Model), claudeDir)
+	if err != nil {
+		return "", err
+	}
+	client, provider, err := modelClient(model, opts, claudeDir, apiURL)
+	if err != nil {
+		return "", err
+	}
+	maxTokens := opts.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxTokens(model, claudeDir, opts.Timeout)
+	}
+
+	resp, err := generate(ctx, client, &llm.Request{
+		Model:       model,
+		MaxTokens:   maxTokens,
+		System:      filterPrompt,
+		Temperature: opts.Temperature,
+		Messages: []MessageContent{{
+			Role: "user",
+			Content: []ContentBlock{{
+				Type: "text",
+				Text: fmt.Sprintf("Instruction: %s\n\nRegion:\n%s", instruction, region),
+			}},
+		}},
+	}, provider, 1)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("instruction", "model", model, "input_tokens", resp.Usage.InputTokens,
+		"output_tokens", resp.Usage.OutputTokens)
+	if resp.StopReason == "max_tokens" {
+		return "", fmt.Errorf("answer cut off at %d tokens", maxTokens)
+	}
+
+	code := cleanFilterOutput(ExtractResponse(&APIResponse{Content: resp.Content}), region)
+	if strings.TrimSpace(code) == "" {
+		return "", fmt.Errorf("the model returned no code")
+	}
+	return code, nil
+}
+
+// cleanFilterOutput strips what isn't code from answer: the prose around
+// a fenced block, or intro and closing remarks without one. The result
+// ends in a newline when region does.
+func cleanFilterOutput(answer, region string) string {
+	if blocks := fencedBlock.FindAllStringSubmatch(answer, -1); len(blocks) > 0 {
+		// The longest block is the code; short ones are usage examples
+		code := blocks[0][1]
+		for _, b := range blocks[1:] {
+			if len(b[1]) > len(code) {
+				code = b[1]
+			}
+		}
+		answer = code
+	} else {
+		lines := strings.Split(answer, "\n")
+		// An intro is a line of prose followed by a blank line
+		for len(lines) > 1 && proseIntro.MatchString(strings.TrimSpace(lines[0])) &&
+			strings.TrimSpace(lines[1]) == "" {
+			lines = lines[2:]
+		}
+		// Closing remarks are paragraphs after the last blank line
+		for i := len(lines) - 1; i > 0; i-- {
+			if strings.TrimSpace(lines[i-1]) == "" && proseOutro.MatchString(strings.TrimSpace(lines[i])) {
+				lines = lines[:i-1]
+			}
+		}
+		answer = strings.Join(lines, "\n")
+	}
+
+	answer = strings.TrimRight(answer, " \t\n")
+	answer = strings.TrimLeft(answer, "\n")
+	if strings.HasSuffix(region, "\n") {
+		answer += "\n"
+	}
+	return answer
+}
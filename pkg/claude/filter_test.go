@@ -0,0 +1,77 @@
+package claude_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestFilterCommand(t *testing.T) {
+	region := "\tx := f()\n\tuse(x)\n"
+	tests := []struct {
+		name   string
+		answer string // empty: Ollama fails
+		want   string
+		err    bool
+	}{
+		{
+			name:   "plain",
+			answer: "\tx, err := f()\n\tuse(x)",
+			want:   "\tx, err := f()\n\tuse(x)\n",
+		},
+		{
+			name: "fenced with prose",
+			answer: "Here is the updated code:\n\n```go\n\tx, err := f()\n\tif err != nil {\n" +
+				"\t\treturn err\n\t}\n\tuse(x)\n```\n\nThis version returns the error.",
+			want: "\tx, err := f()\n\tif err != nil {\n\t\treturn err\n\t}\n\tuse(x)\n",
+		},
+		{
+			name:   "unfenced with prose",
+			answer: "Sure, here it is:\n\n\tx, err := f()\n\tuse(x)\n\nNote: err is unchecked.",
+			want:   "\tx, err := f()\n\tuse(x)\n",
+		},
+		{
+			name: "failure keeps the region",
+			want: region,
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.answer == "" {
+					http.Error(w, "boom", http.StatusInternalServerError)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"message": map[string]string{"role": "assistant", "content": tt.answer},
+					"done":    true,
+				})
+			}))
+			defer ollama.Close()
+
+			claudeDir := filepath.Join(t.TempDir(), ".claude")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+			opts := claude.NewOptions()
+			opts.Model = "llama9:1b"
+			opts.OllamaURL = ollama.URL
+
+			var out strings.Builder
+			err := claude.FilterCommand(context.Background(), opts, claudeDir, "http://unused",
+				"add error handling", strings.NewReader(region), &out)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// ForkCommand handles --fork: starts a new session in the project
+// directory dir with the history of claudeDir up to and including turn
+// timestamp. The original conversation is left as it is.
+func ForkCommand(claudeDir, timestamp, dir string) error {
+	if dir == "" {
+		return fmt.Errorf("--fork needs --fork-dir, the project directory of the new session")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	forkDir := filepath.Join(abs, ".claude")
+	n, err := storage.ForkSession(claudeDir, forkDir, timestamp)
+	if err != nil {
+		return fmt.Errorf("forking session: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Forked %d turns up to %s into %s\n", n, timestamp, forkDir)
+	fmt.Fprintf(os.Stderr, "Continue the fork: cd %s && claude -c PROMPT\n", abs)
+	return nil
+}
+
+// forkLines returns the --history lines about the forks of cfg made after
+// turn ts
+func forkLines(cfg *storage.Config, ts string) []string {
+	var lines []string
+	for _, fork := range cfg.Forks {
+		if fork.Turn == ts {
+			lines = append(lines, fmt.Sprintf("%-15s  └ forked into %s (%s)", "",
+				fork.Dir, fork.Created.Local().Format("2006-01-02 15:04")))
+		}
+	}
+	return lines
+}
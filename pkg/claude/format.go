@@ -0,0 +1,96 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// format.go - Formatting of written files (--format-writes)
+//
+// Models rarely get gofmt's alignment exactly right, so every rewrite of a
+// file churns its formatting. With --format-writes the content of a
+// write_file call goes through the formatter of its extension before it is
+// written: goimports (gofmt when it isn't installed) for Go, and whatever
+// the "format" section of policy.json sets, e.g. prettier. Formatters read
+// the content on stdin and write it formatted to stdout. When one fails the
+// content is written as given and the error goes back to the model.
+
+// FormatTimeout bounds a formatter run
+const FormatTimeout = 30 * time.Second
+
+// formatters are the formatters of the policy by extension
+var formatters map[string]string
+
+// ConfigureFormatters loads the formatters of .claude/policy.json
+func ConfigureFormatters(claudeDir string) error {
+	policy, err := storage.LoadPolicy(claudeDir)
+	if err != nil {
+		return err
+	}
+	formatters = policy.Format
+	return nil
+}
+
+// formatterFor returns the formatter command of path, "" for none
+func formatterFor(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if command, ok := formatters[ext]; ok {
+		return command // "" turns the default off
+	}
+	if ext == ".go" {
+		if _, err := exec.LookPath("goimports"); err == nil {
+			return "goimports"
+		}
+		return "gofmt"
+	}
+	return ""
+}
+
+// formatWrite returns content formatted for path with --format-writes, and
+// a note for the tool result: which formatter ran, or how it failed
+func formatWrite(path, content string, opts *Options) (string, string) {
+	if !opts.FormatWrites {
+		return content, ""
+	}
+	command := formatterFor(path)
+	if command == "" {
+		return content, ""
+	}
+	command = strings.ReplaceAll(command, "{file}", shellQuote(path))
+
+	ctx, cancel := context.WithTimeout(context.Background(), FormatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil && stdout.Len() == 0 && content != "" {
+		err = fmt.Errorf("no output")
+	}
+	if err != nil {
+		slog.Warn("formatting failed", "path", path, "formatter", command, "err", err)
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return content, fmt.Sprintf("\nFormatting with %s failed, written as given:\n%s",
+			command, tailOutput(msg, MaxVerifyOutput))
+	}
+
+	formatted := stdout.String()
+	if formatted == content {
+		return content, ""
+	}
+	slog.Info("formatted", "path", path, "formatter", command)
+	return formatted, fmt.Sprintf(" (formatted with %s)", command)
+}
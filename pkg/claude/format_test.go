@@ -0,0 +1,75 @@
+package claude_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestFormatWrites(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/policy.json": `{"format": {
+			".txt": "tr a-z A-Z",
+			".bad": "echo cannot parse {file} >&2; exit 1",
+			".go": ""
+		}}`,
+	})
+	t.Chdir(dir)
+	if err := claude.ConfigureFormatters(claudeDir); err != nil {
+		t.Fatal(err)
+	}
+	defer claude.ConfigureFormatters(t.TempDir())
+
+	tests := []struct {
+		path, content string
+		format        bool
+		want          string
+		wantNote      string
+	}{
+		{"a.txt", "hello\n", true, "HELLO\n", "(formatted with tr a-z A-Z)"},
+		{"b.txt", "hello\n", false, "hello\n", ""},
+		{"c.bad", "hello\n", true, "hello\n", "cannot parse 'c.bad'"},
+		{"d.go", "package d\nfunc  D() {}\n", true, "package d\nfunc  D() {}\n", ""},
+		{"e.md", "hello\n", true, "hello\n", ""},
+	}
+	for _, tt := range tests {
+		opts := &claude.Options{Tool: claude.ToolWrite, Verbosity: claude.VerbositySilent,
+			FormatWrites: tt.format}
+		toolUse := claude.ContentBlock{
+			Type: "tool_use", ID: "toolu_1", Name: "write_file",
+			Input: map[string]interface{}{"path": tt.path, "content": tt.content},
+		}
+		result, err := claude.ExecuteWriteFile(toolUse, dir, claudeDir, opts, "test-conv")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if got, _ := os.ReadFile(tt.path); string(got) != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.path, got, tt.want)
+		}
+		if tt.wantNote != "" && !strings.Contains(result.Content, tt.wantNote) {
+			t.Errorf("%s: result %q, want %q", tt.path, result.Content, tt.wantNote)
+		}
+		if tt.wantNote == "" && result.Content != "Successfully wrote to "+tt.path {
+			t.Errorf("%s: result %q", tt.path, result.Content)
+		}
+	}
+
+	// Without a policy Go files get gofmt (or goimports)
+	if err := claude.ConfigureFormatters(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	opts := &claude.Options{Tool: claude.ToolWrite, Verbosity: claude.VerbositySilent,
+		FormatWrites: true}
+	toolUse := claude.ContentBlock{
+		Type: "tool_use", ID: "toolu_2", Name: "write_file",
+		Input: map[string]interface{}{"path": "g.go", "content": "package g\nfunc  G() {}\n"},
+	}
+	if _, err := claude.ExecuteWriteFile(toolUse, dir, claudeDir, opts, "test-conv"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile("g.go"); string(got) != "package g\n\nfunc G() {}\n" {
+		t.Errorf("g.go: wrote %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// FsckCommand handles --fsck: reports turns that can't be read or changed
+// since they were saved, and with quarantine moves them out of the history
+func FsckCommand(claudeDir string, quarantine bool) error {
+	problems, err := storage.Fsck(claudeDir)
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", claudeDir, err)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(os.Stderr, "No problems found\n")
+		return nil
+	}
+
+	bad := make(map[string]bool)
+	var turns []string
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s  %s: %s\n", p.Timestamp, p.File, p.Problem)
+		if !bad[p.Timestamp] {
+			bad[p.Timestamp] = true
+			turns = append(turns, p.Timestamp)
+		}
+	}
+
+	if !quarantine {
+		fmt.Fprintf(os.Stderr, "%d problems in %d turns (use --fsck --quarantine to set them aside)\n",
+			len(problems), len(turns))
+		return fmt.Errorf("%d corrupt turns", len(turns))
+	}
+	for _, ts := range turns {
+		dir, err := storage.Quarantine(claudeDir, ts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "  quarantined: %s -> %s\n", ts, dir)
+	}
+	fmt.Fprintf(os.Stderr, "Quarantined %d turns\n", len(turns))
+	return nil
+}
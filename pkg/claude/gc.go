@@ -0,0 +1,28 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// GCCommand handles --gc: compresses and deletes old turns to keep
+// claudeDir within opts
+func GCCommand(claudeDir string, opts storage.GCOptions) error {
+	res, err := storage.GarbageCollect(claudeDir, opts)
+	if err != nil {
+		return fmt.Errorf("garbage collecting: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Compressed %d turns, deleted %d\n", res.Compressed, len(res.Deleted))
+	for _, ts := range res.Deleted {
+		fmt.Fprintf(os.Stderr, "  deleted: %s\n", ts)
+	}
+	fmt.Fprintf(os.Stderr, "Size: %s -> %s\n", formatBytes(res.SizeBefore), formatBytes(res.SizeAfter))
+	if opts.MaxSize > 0 && res.SizeAfter > opts.MaxSize {
+		fmt.Fprintf(os.Stderr, "Still over the %s limit: only the newest turn is left "+
+			"(use --reset to drop it)\n", formatBytes(opts.MaxSize))
+	}
+	return nil
+}
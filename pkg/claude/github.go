@@ -0,0 +1,194 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// github.go - Reporting to a GitHub pull request (--github-pr)
+//
+// With --github-pr=N the outcome of a turn is posted as a comment on pull
+// request N: the answer (a review, say), the --output=patch patch when
+// there is one, and the run summary. The token comes from GITHUB_TOKEN,
+// the repository from GITHUB_REPOSITORY as GitHub Actions sets it or else
+// from the origin remote, and GITHUB_API_URL points at GitHub Enterprise.
+
+// DefaultGitHubAPIURL is the API of github.com
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// maxCommentLength is the longest comment GitHub takes, in characters
+const maxCommentLength = 65536
+
+// GitHubPR is a pull request to comment on
+type GitHubPR struct {
+	Repo   string // owner/name
+	Number int
+	Token  string
+	APIURL string
+	Client *http.Client // proxy and TLS settings of the providers by default
+}
+
+// NewGitHubPR returns pull request number of the repository of workingDir
+func NewGitHubPR(number int, workingDir string) (*GitHubPR, error) {
+	pr := &GitHubPR{
+		Repo:   os.Getenv("GITHUB_REPOSITORY"),
+		Number: number,
+		Token:  os.Getenv("GITHUB_TOKEN"),
+		APIURL: strings.TrimRight(os.Getenv("GITHUB_API_URL"), "/"),
+		Client: llm.HTTPClient(),
+	}
+	if pr.Token == "" {
+		return nil, fmt.Errorf("--github-pr needs GITHUB_TOKEN")
+	}
+	if pr.APIURL == "" {
+		pr.APIURL = DefaultGitHubAPIURL
+	}
+	if pr.Repo == "" {
+		remote, err := git(workingDir, "remote", "get-url", "origin")
+		if err != nil {
+			return nil, fmt.Errorf("--github-pr: no GITHUB_REPOSITORY and %w", err)
+		}
+		if pr.Repo = githubRepo(remote); pr.Repo == "" {
+			return nil, fmt.Errorf("--github-pr: origin %s isn't a GitHub repository "+
+				"(set GITHUB_REPOSITORY=owner/name)", remote)
+		}
+	}
+	return pr, nil
+}
+
+// githubRemote matches the owner/name of GitHub remote URLs: https, ssh
+// and scp-like git@host:owner/name
+var githubRemote = regexp.MustCompile(`^(?:https?://|ssh://)?(?:[^@/]+@)?[^/:]+[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// githubRepo returns the owner/name of remote, "" when it has none
+func githubRepo(remote string) string {
+	if m := githubRemote.FindStringSubmatch(remote); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Comment posts body as a comment on the pull request and returns its URL
+func (pr *GitHubPR) Comment(body string) (string, error) {
+	data, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", pr.APIURL, pr.Repo, pr.Number)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+pr.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pr.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("commenting on %s#%d: %w", pr.Repo, pr.Number, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("commenting on %s#%d: %s: %s", pr.Repo, pr.Number,
+			resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	json.Unmarshal(respBody, &created)
+	return created.HTMLURL, nil
+}
+
+// PRComment returns the markdown of the comment reporting a turn:
+// the answer, the patch and the run summary. Long answers and patches are
+// cut to fit a comment.
+func PRComment(summary *RunSummary, answer, patch string) string {
+	var b strings.Builder
+	status := "✅ claude finished"
+	if summary.ExitStatus != 0 {
+		status = "❌ claude failed"
+	}
+	fmt.Fprintf(&b, "### %s\n\n", status)
+	if summary.Error != "" {
+		fmt.Fprintf(&b, "```\n%s\n```\n\n", summary.Error)
+	}
+
+	tail := prSummary(summary)
+	room := maxCommentLength - b.Len() - len(tail) - 200
+	if patch != "" {
+		// The patch gets what the answer leaves, but at least half
+		patchRoom := max(room-len(answer), room/2)
+		patch = cutText(patch, patchRoom, "a GitHub comment")
+		room -= len(patch)
+	}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		fmt.Fprintf(&b, "%s\n\n", cutText(answer, room, "a GitHub comment"))
+	}
+	if patch != "" {
+		fmt.Fprintf(&b, "<details><summary>Patch</summary>\n\n```diff\n%s\n```\n\n</details>\n\n",
+			strings.TrimRight(patch, "\n"))
+	}
+	b.WriteString(tail)
+	return b.String()
+}
+
+// prSummary renders the run summary as a table
+func prSummary(s *RunSummary) string {
+	var b strings.Builder
+	b.WriteString("| Model | Tokens in/out | Cost | Iterations |")
+	if s.Verify != "" {
+		b.WriteString(" Verify |")
+	}
+	b.WriteString("\n|---|---|---|---|")
+	if s.Verify != "" {
+		b.WriteString("---|")
+	}
+	fmt.Fprintf(&b, "\n| %s | %d/%d | $%.4f | %d |", s.Model, s.InputTokens,
+		s.OutputTokens, s.Cost, s.Iterations)
+	if s.Verify != "" {
+		fmt.Fprintf(&b, " %s |", s.Verify)
+	}
+	b.WriteString("\n")
+
+	if len(s.ToolsExecuted) > 0 {
+		names := make([]string, 0, len(s.ToolsExecuted))
+		for name := range s.ToolsExecuted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = name + " ×" + strconv.Itoa(s.ToolsExecuted[name])
+		}
+		fmt.Fprintf(&b, "\nTools: %s\n", strings.Join(names, ", "))
+	}
+	if len(s.FilesChanged) > 0 {
+		fmt.Fprintf(&b, "\nFiles changed: `%s`\n", strings.Join(s.FilesChanged, "`, `"))
+	}
+	return b.String()
+}
+
+// cutText cuts s to at most n bytes at a line end, saying it was cut to
+// fit where
+func cutText(s string, n int, where string) string {
+	if len(s) <= n {
+		return s
+	}
+	note := "\n... (cut to fit " + where + ")"
+	cut := max(n-len(note), 0)
+	if i := strings.LastIndex(s[:cut], "\n"); i > 0 {
+		cut = i
+	}
+	return s[:cut] + note
+}
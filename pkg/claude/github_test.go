@@ -0,0 +1,127 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+func TestGitHubPRComment(t *testing.T) {
+	var got struct {
+		path, auth string
+		body       map[string]string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.Method + " " + r.URL.Path
+		got.auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got.body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/o/r/pull/7#issuecomment-1"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GITHUB_TOKEN", "ghs_test")
+	t.Setenv("GITHUB_REPOSITORY", "o/r")
+	t.Setenv("GITHUB_API_URL", srv.URL+"/")
+	pr, err := claude.NewGitHubPR(7, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &claude.RunSummary{
+		Model: "claude-test", InputTokens: 10, OutputTokens: 5, Cost: 0.01, Iterations: 2,
+		ToolsExecuted: map[string]int{"write_file": 1}, FilesChanged: []string{"a.go"},
+	}
+	body := claude.PRComment(summary, "Looks good.\n", "diff --git a/a.go b/a.go\n")
+	url, err := pr.Comment(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/o/r/pull/7#issuecomment-1" {
+		t.Errorf("url = %q", url)
+	}
+	if got.path != "POST /repos/o/r/issues/7/comments" || got.auth != "Bearer ghs_test" {
+		t.Errorf("request %s, auth %q", got.path, got.auth)
+	}
+	for _, want := range []string{"claude finished", "Looks good.", "```diff\ndiff --git a/a.go b/a.go\n```",
+		"| claude-test | 10/5 | $0.0100 | 2 |", "write_file ×1", "`a.go`"} {
+		if !strings.Contains(got.body["body"], want) {
+			t.Errorf("comment lacks %q:\n%s", want, got.body["body"])
+		}
+	}
+
+	failed := claude.PRComment(&claude.RunSummary{ExitStatus: 1, Error: "max cost exceeded"},
+		strings.Repeat("x\n", 40000), "")
+	if !strings.Contains(failed, "claude failed") || !strings.Contains(failed, "max cost exceeded") ||
+		!strings.Contains(failed, "cut to fit") || len(failed) > 65536 {
+		t.Errorf("failed run comment (%d bytes) lacks the error or wasn't cut", len(failed))
+	}
+}
+
+func TestGitHubPRProxy(t *testing.T) {
+	var host string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.example.com/o/r/pull/7#issuecomment-1"}`))
+	}))
+	defer proxy.Close()
+
+	t.Setenv("NO_PROXY", "")
+	if err := llm.ConfigureTransport(llm.TransportConfig{ProxyURL: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { llm.ConfigureTransport(llm.TransportConfig{}) })
+
+	t.Setenv("GITHUB_TOKEN", "ghs_test")
+	t.Setenv("GITHUB_REPOSITORY", "o/r")
+	t.Setenv("GITHUB_API_URL", "http://github.example.com/api/v3")
+	pr, err := claude.NewGitHubPR(7, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Comments go through --proxy like the provider calls
+	if _, err := pr.Comment("hi"); err != nil || host != "github.example.com" {
+		t.Errorf("Comment = %v, proxy saw host %q", err, host)
+	}
+}
+
+func TestGitHubPRRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GITHUB_TOKEN", "ghs_test")
+	t.Setenv("GITHUB_REPOSITORY", "")
+	for remote, want := range map[string]string{
+		"git@github.com:owner/name.git":        "owner/name",
+		"https://github.com/owner/name":        "owner/name",
+		"ssh://git@ghe.example.com/owner/name": "owner/name",
+		"/srv/git/name":                        "",
+	} {
+		dir := t.TempDir()
+		for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", remote}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v: %s", args, err, out)
+			}
+		}
+		pr, err := claude.NewGitHubPR(1, dir)
+		switch {
+		case want == "" && err == nil:
+			t.Errorf("%s: repo %q, want error", remote, pr.Repo)
+		case want != "" && (err != nil || pr.Repo != want):
+			t.Errorf("%s: %v, %v, want %s", remote, pr, err, want)
+		}
+	}
+
+	t.Setenv("GITHUB_TOKEN", "")
+	if _, err := claude.NewGitHubPR(1, t.TempDir()); err == nil {
+		t.Error("no error without GITHUB_TOKEN")
+	}
+}
@@ -0,0 +1,417 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// HistoryEntry summarizes one saved request/response pair.
+type HistoryEntry struct {
+	Timestamp    string
+	Prompt       string // first line of the user message
+	Title        string // from the metadata, else the prompt
+	Tags         []string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	Iterations   int
+	ToolCalls    int
+	Cost         float64
+
+	// From the turn metadata; nil for turns saved without it
+	Meta *storage.TurnMeta
+}
+
+// LoadHistory summarizes every complete request/response pair, oldest first.
+func LoadHistory(claudeDir string) ([]HistoryEntry, error) {
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]HistoryEntry, 0, len(pairs))
+	for _, ts := range pairs {
+		entry, err := loadHistoryEntry(claudeDir, ts)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+func loadHistoryEntry(claudeDir, timestamp string) (*HistoryEntry, error) {
+	reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", timestamp))
+	req, err := storage.LoadRequest(reqPath)
+	if err != nil {
+		return nil, fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+	responses, err := storage.LoadResponses(claudeDir, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+
+	entry := &HistoryEntry{
+		Timestamp:  timestamp,
+		Iterations: len(responses),
+	}
+	if msg, err := GetLastUserMessage(req.Messages); err == nil {
+		entry.Prompt = firstLine(msg)
+	}
+	for _, resp := range responses {
+		if resp.Model != "" {
+			entry.Model = resp.Model
+		}
+		entry.InputTokens += resp.Usage.InputTokens
+		entry.OutputTokens += resp.Usage.OutputTokens
+		entry.Cost += UsageCost(resp.Model, resp.Usage.InputTokens,
+			resp.Usage.OutputTokens)
+		for _, block := range resp.Content {
+			if block.Type == "tool_use" {
+				entry.ToolCalls++
+			}
+		}
+	}
+
+	// The metadata knows the cost as charged and who answered
+	if entry.Meta, err = storage.LoadTurnMeta(claudeDir, timestamp); err != nil {
+		return nil, fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+	entry.Title = entry.Prompt
+	if entry.Meta != nil {
+		entry.Model = entry.Meta.Model
+		entry.Cost = entry.Meta.Cost
+		entry.Tags = entry.Meta.Tags
+		if entry.Meta.Title != "" {
+			entry.Title = entry.Meta.Title
+		}
+	}
+	return entry, nil
+}
+
+// hasTag reports whether the turn is labeled tag
+func (e *HistoryEntry) hasTag(tag string) bool {
+	return slices.Contains(e.Tags, tag)
+}
+
+// provider returns who answered the turn, or "-" when unknown
+func (e *HistoryEntry) provider() string {
+	if e.Meta == nil || e.Meta.Provider == "" {
+		return "-"
+	}
+	return e.Meta.Provider
+}
+
+// duration returns how long the turn took, or "-" when unknown
+func (e *HistoryEntry) duration() string {
+	if e.Meta == nil {
+		return "-"
+	}
+	return e.Meta.Duration().Round(100 * time.Millisecond).String()
+}
+
+// MaxTitleLength is the length of generated turn titles
+const MaxTitleLength = 60
+
+// turnTitle makes a title from the first line of prompt: markdown markers
+// are dropped and long lines are cut at a word
+func turnTitle(prompt string) string {
+	for _, line := range strings.Split(prompt, "\n") {
+		line = strings.Join(strings.Fields(strings.TrimLeft(line, "#>*- \t")), " ")
+		if line == "" {
+			continue
+		}
+		if len(line) > MaxTitleLength {
+			cut := strings.LastIndex(line[:MaxTitleLength-3], " ")
+			if cut < MaxTitleLength/2 {
+				cut = MaxTitleLength - 3
+			}
+			line = strings.TrimRight(line[:cut], " ,.;:") + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+// validTag is a --tag label: lowercase letters, digits, '.', '_' and '-'
+var validTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if !validTag.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q: use lowercase letters, digits, '.', '_' and '-'", tag)
+		}
+	}
+	return nil
+}
+
+// firstLine returns the first non-empty line of s, shortened for listings.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if r := []rune(line); len(r) > 60 {
+				line = string(r[:57]) + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// HistoryCommand handles --history: lists saved turns with usage
+// summaries, only those labeled tag unless it is empty
+func HistoryCommand(claudeDir, tag string) error {
+	entries, err := LoadHistory(claudeDir)
+	if err != nil {
+		return err
+	}
+	if tag != "" {
+		entries = slices.DeleteFunc(entries, func(e HistoryEntry) bool {
+			return !e.hasTag(tag)
+		})
+	}
+	if len(entries) == 0 {
+		if tag != "" {
+			fmt.Fprintf(os.Stderr, "No turns tagged %s\n", tag)
+			return nil
+		}
+		fmt.Fprintln(os.Stderr, "No conversation history")
+		return nil
+	}
+
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	if from := cfg.ForkedFrom; from != nil {
+		fmt.Fprintf(os.Stderr, "Forked from %s after turn %s\n\n", from.Dir, from.Turn)
+	}
+
+	var totalCost float64
+	fmt.Fprintf(os.Stderr, "%-15s  %-26s  %-8s  %13s  %5s  %8s  %7s  %s\n",
+		"TIMESTAMP", "MODEL", "PROVIDER", "TOKENS IN/OUT", "TOOLS", "COST", "TIME", "TITLE")
+	for _, e := range entries {
+		model := e.Model
+		if model == "" {
+			model = "unknown"
+		}
+		title := e.Title
+		if len(e.Tags) > 0 {
+			title += " [" + strings.Join(e.Tags, ", ") + "]"
+		}
+		fmt.Fprintf(os.Stderr, "%-15s  %-26s  %-8s  %6d/%-6d  %5d  $%7.4f  %7s  %s\n",
+			e.Timestamp, model, e.provider(), e.InputTokens, e.OutputTokens,
+			e.ToolCalls, e.Cost, e.duration(), title)
+		for _, line := range forkLines(cfg, e.Timestamp) {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		totalCost += e.Cost
+	}
+	fmt.Fprintf(os.Stderr, "\n%d turns, $%.4f total\n", len(entries), totalCost)
+	fmt.Fprintf(os.Stderr, "Show a turn: claude --show-turn=TIMESTAMP\n")
+
+	return nil
+}
+
+// ShowTurnCommand handles --show-turn: renders one saved turn in full.
+// Metadata goes to stderr; the assistant's answer is rendered to stdout.
+func ShowTurnCommand(claudeDir, timestamp string) error {
+	entry, err := loadHistoryEntry(claudeDir, timestamp)
+	if err != nil {
+		return err
+	}
+	reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", timestamp))
+	req, err := storage.LoadRequest(reqPath)
+	if err != nil {
+		return err
+	}
+	responses, err := storage.LoadResponses(claudeDir, timestamp)
+	if err != nil {
+		return err
+	}
+
+	ToolHeader("turn "+timestamp, false)
+	if entry.Title != "" {
+		fmt.Fprintf(os.Stderr, "Title: %s\n", entry.Title)
+	}
+	if len(entry.Tags) > 0 {
+		fmt.Fprintf(os.Stderr, "Tags: %s\n", strings.Join(entry.Tags, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "Model: %s\n", entry.Model)
+	fmt.Fprintf(os.Stderr, "Tokens: %d in, %d out ($%.4f)\n",
+		entry.InputTokens, entry.OutputTokens, entry.Cost)
+	fmt.Fprintf(os.Stderr, "Iterations: %d\n", entry.Iterations)
+	if meta := entry.Meta; meta != nil {
+		provider := meta.Provider
+		if meta.Fallback {
+			provider += " (fallback)"
+		}
+		fmt.Fprintf(os.Stderr, "Provider: %s\n", provider)
+		fmt.Fprintf(os.Stderr, "Started: %s, took %s\n",
+			meta.Started.Local().Format(time.DateTime), entry.duration())
+		if meta.Tool != "" {
+			fmt.Fprintf(os.Stderr, "Tool mode: %s\n", meta.Tool)
+		}
+		if len(meta.APIKeys) > 0 {
+			fmt.Fprintf(os.Stderr, "API keys: %s\n", strings.Join(meta.APIKeys, ", "))
+		}
+		if meta.Prefill != "" {
+			fmt.Fprintf(os.Stderr, "Prefill: %q\n", meta.Prefill)
+		}
+		if len(meta.Context) > 0 {
+			fmt.Fprintf(os.Stderr, "Context: %s\n", strings.Join(meta.Context, ", "))
+		}
+		if b := meta.BestOf; b != nil {
+			fmt.Fprintf(os.Stderr, "Best of %d: candidate %d, chosen by %s\n",
+				len(b.Candidates), b.Chosen+1, b.Judge)
+			if b.Reason != "" {
+				fmt.Fprintf(os.Stderr, "  %s\n", b.Reason)
+			}
+		}
+	}
+
+	if prompt, err := GetLastUserMessage(req.Messages); err == nil {
+		fmt.Fprintf(os.Stderr, "\nPrompt:\n%s\n", strings.TrimRight(prompt, "\n"))
+	}
+
+	if entry.ToolCalls > 0 {
+		// Turns with a transcript show what each call returned
+		results := make(map[string]string)
+		if t, err := storage.LoadTranscript(claudeDir, timestamp); err == nil && t != nil {
+			for _, msg := range t.Messages {
+				for _, block := range msg.Content {
+					if block.Type == "tool_result" {
+						results[block.ToolUseID] = block.Content
+					}
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "\nTool calls:\n")
+		for i, resp := range responses {
+			for _, block := range resp.Content {
+				if block.Type != "tool_use" {
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "  [%d] %s\n", i+1, describeToolUse(block))
+				if result, ok := results[block.ID]; ok {
+					fmt.Fprintf(os.Stderr, "      -> %s\n", firstLine(result))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if len(responses) > 0 {
+		answer := ExtractResponse(&responses[len(responses)-1])
+		FormatResponse(os.Stdout, answer)
+		if !display.UseColor(os.Stdout) && !strings.HasSuffix(answer, "\n") {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// TranscriptCommand handles --transcript: writes the transcript of turn
+// timestamp, tool results included, to w as JSON
+func TranscriptCommand(w io.Writer, claudeDir, timestamp string) error {
+	if _, err := loadHistoryEntry(claudeDir, timestamp); err != nil {
+		return err
+	}
+	t, err := storage.LoadTranscript(claudeDir, timestamp)
+	if err != nil {
+		return fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+	if t == nil {
+		return fmt.Errorf("turn %s has no transcript (saved before transcripts "+
+			"existed or finalized by --recover)", timestamp)
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling transcript: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// LastResponse returns the final response of the most recent saved turn
+// (--last)
+func LastResponse(claudeDir string) (*APIResponse, error) {
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no previous answer")
+	}
+	timestamp := pairs[len(pairs)-1]
+	responses, err := storage.LoadResponses(claudeDir, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("turn %s: %w", timestamp, err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("turn %s: no responses", timestamp)
+	}
+	return &responses[len(responses)-1], nil
+}
+
+// DropLastCommand handles --drop-last: deletes the last n turns from the
+// history after showing them and asking, unless force is set
+func DropLastCommand(claudeDir string, n int, force bool) error {
+	entries, err := LoadHistory(claudeDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "No conversation history")
+		return nil
+	}
+	drop := entries[max(0, len(entries)-n):]
+
+	fmt.Fprintf(os.Stderr, "Dropping %d of %d turns:\n", len(drop), len(entries))
+	for _, e := range drop {
+		fmt.Fprintf(os.Stderr, "  %s  %s\n", e.Timestamp, e.Title)
+	}
+	if !force {
+		ok, err := confirmDrop()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Nothing dropped")
+			return nil
+		}
+	}
+
+	dropped, err := storage.DropTurns(claudeDir, len(drop))
+	if err != nil {
+		return fmt.Errorf("dropping turns: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Dropped %d turns; files they changed stay changed "+
+		"(originals in .claude/backups/)\n", len(dropped))
+	return nil
+}
+
+// confirmDrop asks on the terminal whether to drop the listed turns
+func confirmDrop() (bool, error) {
+	f, err := openPromptInput()
+	if err != nil {
+		return false, fmt.Errorf("dropping turns needs a terminal to confirm (or --force): %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(os.Stderr, "Drop them? [y/N]: ")
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
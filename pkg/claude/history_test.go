@@ -0,0 +1,328 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestLoadHistory(t *testing.T) {
+	claudeDir := t.TempDir()
+
+	userMsg := []storage.MessageContent{{
+		Role:    "user",
+		Content: []storage.ContentBlock{{Type: "text", Text: "\nfix the bug\nin users.go"}},
+	}}
+	if err := storage.SaveRequest(claudeDir, "20260105_120000", userMsg); err != nil {
+		t.Fatal(err)
+	}
+	resp := []storage.APIResponse{
+		{
+			Model:      "claude-sonnet-4-20250514",
+			Content:    []storage.ContentBlock{{Type: "tool_use", ID: "t1", Name: "read_file"}},
+			Usage:      claude.Usage{InputTokens: 1000, OutputTokens: 100},
+			StopReason: "tool_use",
+		},
+		{
+			Model:      "claude-sonnet-4-20250514",
+			Content:    []storage.ContentBlock{{Type: "text", Text: "done"}},
+			Usage:      claude.Usage{InputTokens: 2000, OutputTokens: 200},
+			StopReason: "end_turn",
+		},
+	}
+	body, _ := json.Marshal(resp)
+	if err := storage.SaveResponse(claudeDir, "20260105_120000", body); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := claude.LoadHistory(claudeDir)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Prompt != "fix the bug" {
+		t.Errorf("expected first line prompt, got %q", e.Prompt)
+	}
+	if e.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("unexpected model %q", e.Model)
+	}
+	if e.InputTokens != 3000 || e.OutputTokens != 300 {
+		t.Errorf("expected 3000/300 tokens, got %d/%d", e.InputTokens, e.OutputTokens)
+	}
+	if e.Iterations != 2 || e.ToolCalls != 1 {
+		t.Errorf("expected 2 iterations and 1 tool call, got %d/%d", e.Iterations, e.ToolCalls)
+	}
+	// 3000*3/1M + 300*15/1M
+	if math.Abs(e.Cost-0.0135) > 1e-9 {
+		t.Errorf("expected cost 0.0135, got %f", e.Cost)
+	}
+}
+
+func TestHistoryLongPrompt(t *testing.T) {
+	claudeDir := t.TempDir()
+	prompt := strings.Repeat("é", 70)
+	userMsg := []storage.MessageContent{{
+		Role:    "user",
+		Content: []storage.ContentBlock{{Type: "text", Text: prompt}},
+	}}
+	if err := storage.SaveRequest(claudeDir, "20260105_120000", userMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveResponse(claudeDir, "20260105_120000", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := claude.LoadHistory(claudeDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("LoadHistory = %v, %v", entries, err)
+	}
+	// Shortened by runes, not cut inside one
+	if want := strings.Repeat("é", 57) + "..."; entries[0].Prompt != want {
+		t.Errorf("prompt = %q, want %q", entries[0].Prompt, want)
+	}
+}
+
+func TestUsageCostLocalModelsFree(t *testing.T) {
+	if cost := claude.UsageCost("llama3.1:8b", 1_000_000, 1_000_000); cost != 0 {
+		t.Errorf("expected local model to be free, got %f", cost)
+	}
+	if cost := claude.UsageCost("claude-opus-4-20250514", 1_000_000, 0); cost != 15.0 {
+		t.Errorf("expected $15 for 1M opus input tokens, got %f", cost)
+	}
+}
+
+func TestLastResponse(t *testing.T) {
+	claudeDir := t.TempDir()
+	if _, err := claude.LastResponse(claudeDir); err == nil {
+		t.Error("expected error without history")
+	}
+
+	userMsg := []storage.MessageContent{{
+		Role:    "user",
+		Content: []storage.ContentBlock{{Type: "text", Text: "question"}},
+	}}
+	for i, ts := range []string{"20260105_120000", "20260105_130000"} {
+		if err := storage.SaveRequest(claudeDir, ts, userMsg); err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal([]storage.APIResponse{{
+			Content:    []storage.ContentBlock{{Type: "text", Text: []string{"old", "new"}[i]}},
+			StopReason: "end_turn",
+		}})
+		if err := storage.SaveResponse(claudeDir, ts, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := claude.LastResponse(claudeDir)
+	if err != nil {
+		t.Fatalf("LastResponse failed: %v", err)
+	}
+	if got := claude.ExtractResponse(resp); got != "new" {
+		t.Errorf("expected latest answer, got %q", got)
+	}
+}
+
+func TestHistoryTurnMeta(t *testing.T) {
+	claudeDir, _, err := runPlaybook(t, &messagesAPI{}, "steps:\n  - prompt: hi\n")
+	if err != nil {
+		t.Fatalf("RunPlaybook: %v", err)
+	}
+
+	entries, err := claude.LoadHistory(claudeDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("LoadHistory = %d entries, %v", len(entries), err)
+	}
+	meta := entries[0].Meta
+	if meta == nil {
+		t.Fatal("turn saved without metadata")
+	}
+	if meta.Model != claude.DefaultModel || meta.Provider != claude.ProviderClaude ||
+		meta.Iterations != 1 || meta.Started.IsZero() || meta.WorkingDir == "" {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+
+	// Pruning takes the metadata along
+	if err := storage.PruneResponses(claudeDir, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if meta, err := storage.LoadTurnMeta(claudeDir, entries[0].Timestamp); meta != nil || err != nil {
+		t.Errorf("metadata left after prune: %+v, %v", meta, err)
+	}
+}
+
+func TestHistoryTitleAndTags(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Tags = []string{"bug", "api"}
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("fixed", "end_turn")}}
+	prompt := "\n## Fix the retry loop in the provider client so that it backs off exponentially\nmore details"
+	_, claudeDir, err := runScripted(t, opts, mock, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := claude.LoadHistory(claudeDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("LoadHistory = %d entries, %v", len(entries), err)
+	}
+	e := entries[0]
+	if want := "Fix the retry loop in the provider client so that it..."; e.Title != want {
+		t.Errorf("title = %q, want %q", e.Title, want)
+	}
+	if len(e.Title) > claude.MaxTitleLength {
+		t.Errorf("title is %d long", len(e.Title))
+	}
+	if len(e.Tags) != 2 || e.Tags[0] != "bug" || e.Tags[1] != "api" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+
+	opts.Tags = []string{"Not OK"}
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "system"); err == nil ||
+		!strings.Contains(err.Error(), "invalid tag") {
+		t.Errorf("InitSession with a bad tag = %v", err)
+	}
+}
+
+func TestHistoryTitleWithoutMeta(t *testing.T) {
+	claudeDir := t.TempDir()
+	userMsg := []storage.MessageContent{{
+		Role:    "user",
+		Content: []storage.ContentBlock{{Type: "text", Text: "explain main.go"}},
+	}}
+	if err := storage.SaveRequest(claudeDir, "20260105_120000", userMsg); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal([]storage.APIResponse{{StopReason: "end_turn"}})
+	if err := storage.SaveResponse(claudeDir, "20260105_120000", body); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := claude.LoadHistory(claudeDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("LoadHistory = %d entries, %v", len(entries), err)
+	}
+	if entries[0].Title != "explain main.go" || entries[0].Tags != nil {
+		t.Errorf("entry = %+v, want the prompt as title and no tags", entries[0])
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_1", "hello"),
+		textResponse("done", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolWrite)
+	opts.SetVerbosity(claude.VerbositySilent)
+	_, claudeDir, err := runScripted(t, opts, mock, "write a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+	if len(pairs) != 1 {
+		t.Fatalf("pairs = %v", pairs)
+	}
+
+	var out strings.Builder
+	if err := claude.TranscriptCommand(&out, claudeDir, pairs[0]); err != nil {
+		t.Fatalf("TranscriptCommand: %v", err)
+	}
+	var tr storage.Transcript
+	if err := json.Unmarshal([]byte(out.String()), &tr); err != nil {
+		t.Fatalf("transcript: %v\n%s", err, out.String())
+	}
+	var roles []string
+	for _, msg := range tr.Messages {
+		roles = append(roles, msg.Role+":"+msg.Content[0].Type)
+	}
+	want := "user:text assistant:tool_use user:tool_result assistant:text"
+	if got := strings.Join(roles, " "); got != want {
+		t.Errorf("transcript messages %s, want %s", got, want)
+	}
+	if result := tr.Messages[2].Content[0]; result.ToolUseID != "toolu_1" ||
+		!strings.Contains(result.Content, "Successfully wrote to a.txt") {
+		t.Errorf("tool result = %+v", result)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	save := func(ts, answer string, files map[string]string) {
+		t.Helper()
+		var content []storage.ContentBlock
+		for path, data := range files {
+			content = append(content, storage.ContentBlock{
+				Type: "tool_use", ID: "toolu_" + path, Name: "write_file",
+				Input: map[string]interface{}{"path": path, "content": data},
+			})
+		}
+		body, _ := json.Marshal([]storage.APIResponse{
+			{Content: content, StopReason: "tool_use"},
+			{Content: []storage.ContentBlock{{Type: "text", Text: answer}}, StopReason: "end_turn"},
+		})
+		if err := storage.SaveRequest(claudeDir, ts, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.SaveResponse(claudeDir, ts, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("20260101_100000", "first answer\n", map[string]string{
+		"same.go": "package same\n", "old.go": "package old\n", "changed.go": "a\nb\n",
+	})
+	save("20260101_110000", "second answer\n", map[string]string{
+		"same.go": "package same\n", "new.go": "package new\n", "changed.go": "a\nc\n",
+	})
+
+	var out strings.Builder
+	if err := claude.CompareCommand(&out, claudeDir, "20260101_100000", "20260101_110000"); err != nil {
+		t.Fatalf("CompareCommand: %v", err)
+	}
+	want := `--- 20260101_100000 answer
++++ 20260101_110000 answer
+@@ -1,1 +1,1 @@
+-first answer
++second answer
+--- 20260101_100000/changed.go
++++ 20260101_110000/changed.go
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+--- /dev/null
++++ 20260101_110000/new.go
+@@ -0,0 +1,1 @@
++package new
+--- 20260101_100000/old.go
++++ /dev/null
+@@ -1,1 +0,0 @@
+-package old
+`
+	if out.String() != want {
+		t.Errorf("compare output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := claude.CompareCommand(&out, claudeDir, "20260101_100000", "20260101_100000"); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("turn compared to itself:\n%s", out.String())
+	}
+	if err := claude.CompareCommand(&out, claudeDir, "20260101_100000", "20260101_120000"); err == nil {
+		t.Error("comparing with a missing turn succeeded")
+	}
+}
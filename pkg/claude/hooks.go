@@ -0,0 +1,161 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// hooks.go - Running lifecycle hooks from .claude/hooks.json
+//
+// Each hook gets a hookEvent as JSON on stdin and runs in the working
+// directory. A failing pre_tool hook vetoes the tool (the LLM sees the
+// hook's output as the tool error); a failing pre_request hook aborts the
+// run. Failures of post hooks are only logged. With --read-only no hook
+// runs: a hook is a shell command, and nothing checks what it writes.
+
+// hooks is the active configuration (nil = no hooks)
+var hooks *storage.Hooks
+
+// ConfigureHooks loads the hooks for claudeDir
+func ConfigureHooks(claudeDir string) error {
+	h, err := storage.LoadHooks(claudeDir)
+	if err != nil {
+		return err
+	}
+	hooks = h
+	return nil
+}
+
+// hookEvent is written to a hook's stdin
+type hookEvent struct {
+	Event          string       `json:"event"`
+	ConversationID string       `json:"conversation_id"`
+	WorkingDir     string       `json:"working_dir"`
+	Tool           *hookTool    `json:"tool,omitempty"`
+	Request        *hookRequest `json:"request,omitempty"`
+	Turn           *hookTurn    `json:"turn,omitempty"`
+	Budget         *hookBudget  `json:"budget,omitempty"`
+}
+
+type hookTool struct {
+	Name    string                 `json:"name"`
+	ID      string                 `json:"id"`
+	Input   map[string]interface{} `json:"input"`
+	DryRun  bool                   `json:"dry_run"`
+	Result  string                 `json:"result,omitempty"` // post_tool
+	IsError bool                   `json:"is_error,omitempty"`
+}
+
+type hookRequest struct {
+	Model     string `json:"model"`
+	Iteration int    `json:"iteration"`
+	Messages  int    `json:"messages"`
+}
+
+type hookBudget struct {
+	Budget  string  `json:"budget"` // BudgetMaxCost or BudgetDaily
+	Percent int     `json:"percent"`
+	Spent   float64 `json:"spent"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
+
+type hookTurn struct {
+	Answer  string      `json:"answer"`
+	Summary *RunSummary `json:"summary"`
+}
+
+// toolEvent builds the pre_tool (result nil) or post_tool event for block
+func toolEvent(event string, block ContentBlock, result *ContentBlock,
+	workingDir string, opts *Options, conversationID string,
+) hookEvent {
+	t := &hookTool{
+		Name:   block.Name,
+		ID:     block.ID,
+		Input:  block.Input,
+		DryRun: toolDryRun(block.Name, opts),
+	}
+	if result != nil {
+		t.Result = result.Content
+		t.IsError = strings.HasPrefix(result.Content, "Error: ")
+	}
+	return hookEvent{
+		Event:          event,
+		ConversationID: conversationID,
+		WorkingDir:     workingDir,
+		Tool:           t,
+	}
+}
+
+// toolDryRun reports whether tool only pretends to run under opts
+func toolDryRun(tool string, opts *Options) bool {
+	switch tool {
+	case "read_file", "semantic_search":
+		return false
+	case "write_file":
+		return !opts.CanExecuteWrite()
+	default: // bash_command and plugins
+		return !opts.CanExecuteCommand()
+	}
+}
+
+// runHooks runs the hooks for ev.Event in order and stops at the first
+// failure
+func runHooks(ctx context.Context, ev hookEvent) error {
+	if storage.ReadOnly() {
+		return nil
+	}
+	for _, h := range hooks.For(ev.Event) {
+		if ev.Tool != nil && len(h.Tools) > 0 && !containsString(h.Tools, ev.Tool.Name) {
+			continue
+		}
+		if err := runHook(ctx, h, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, h storage.Hook, ev hookEvent) error {
+	timeout := HookTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	in, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshaling hook event: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Dir = ev.WorkingDir
+	cmd.Stdin = bytes.NewReader(in)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	slog.Debug("hook", "event", ev.Event, "command", h.Command, "output", output)
+
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("hook %q timed out after %v", h.Command, timeout)
+		}
+		if output == "" {
+			output = err.Error()
+		}
+		return fmt.Errorf("hook %q: %s", h.Command, output)
+	}
+	if output != "" {
+		slog.Info("hook output", "event", ev.Event, "command", h.Command,
+			"output", output)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// withHooks installs hooks.json content for the duration of the test
+func withHooks(t *testing.T, hooksJSON string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hooks.json"), []byte(hooksJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := claude.ConfigureHooks(dir); err != nil {
+		t.Fatalf("ConfigureHooks: %v", err)
+	}
+	t.Cleanup(func() { claude.ConfigureHooks(t.TempDir()) })
+}
+
+// readEvent decodes a hook event saved by `cat > path`
+func readEvent(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("hook didn't run: %v", err)
+	}
+	var ev map[string]interface{}
+	if err := json.Unmarshal(data, &ev); err != nil {
+		t.Fatalf("invalid event %s: %v", data, err)
+	}
+	return ev
+}
+
+func TestPreToolHookVeto(t *testing.T) {
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("hello"), 0o644)
+	t.Chdir(workDir)
+
+	withHooks(t, `{"pre_tool": [
+		{"command": "echo no shell access; exit 1", "tools": ["bash_command"]}
+	]}`)
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	results, err := claude.ExecuteTools([]claude.ContentBlock{{
+		Type: "tool_use", ID: "toolu_1", Name: "read_file",
+		Input: map[string]interface{}{"path": "a.txt"},
+	}, {
+		Type: "tool_use", ID: "toolu_2", Name: "bash_command",
+		Input: map[string]interface{}{"command": "ls", "reason": "test"},
+	}}, workDir, claudeDir, opts, "test")
+	if err != nil {
+		t.Fatalf("ExecuteTools: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Content != "hello" {
+		t.Errorf("read_file result = %q, want hello", results[0].Content)
+	}
+	if !strings.HasPrefix(results[1].Content, "Error: blocked by hook") ||
+		!strings.Contains(results[1].Content, "no shell access") {
+		t.Errorf("bash_command result = %q, want hook veto", results[1].Content)
+	}
+	if results[1].ToolUseID != "toolu_2" {
+		t.Errorf("veto ToolUseID = %q", results[1].ToolUseID)
+	}
+}
+
+func TestPostToolHook(t *testing.T) {
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("hello"), 0o644)
+	t.Chdir(workDir)
+
+	eventFile := filepath.Join(t.TempDir(), "event.json")
+	withHooks(t, `{"post_tool": [{"command": "cat > `+eventFile+`"}]}`)
+
+	_, err := claude.ExecuteTools([]claude.ContentBlock{{
+		Type: "tool_use", ID: "toolu_1", Name: "read_file",
+		Input: map[string]interface{}{"path": "a.txt"},
+	}}, workDir, claudeDir, claude.NewOptions(), "conv1")
+	if err != nil {
+		t.Fatalf("ExecuteTools: %v", err)
+	}
+
+	ev := readEvent(t, eventFile)
+	if ev["event"] != "post_tool" || ev["conversation_id"] != "conv1" {
+		t.Errorf("event = %v", ev)
+	}
+	tool, _ := ev["tool"].(map[string]interface{})
+	if tool["name"] != "read_file" || tool["result"] != "hello" {
+		t.Errorf("tool = %v", tool)
+	}
+}
+
+func TestRequestHooks(t *testing.T) {
+	turnFile := filepath.Join(t.TempDir(), "turn.json")
+	withHooks(t, `{
+		"pre_request": [{"command": "exit 0"}],
+		"post_turn": [{"command": "cat > `+turnFile+`"}]
+	}`)
+
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("all done", "end_turn")}}
+	if _, _, err := runScripted(t, claude.NewOptions(), mock, "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := readEvent(t, turnFile)
+	turn, _ := ev["turn"].(map[string]interface{})
+	if ev["event"] != "post_turn" || turn["answer"] != "all done" {
+		t.Errorf("event = %v", ev)
+	}
+	summary, _ := turn["summary"].(map[string]interface{})
+	if summary["iterations"] != 1.0 {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestPreRequestHookVeto(t *testing.T) {
+	withHooks(t, `{"pre_request": [{"command": "echo over budget >&2; exit 1"}]}`)
+
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("hi", "end_turn")}}
+	_, _, err := runScripted(t, claude.NewOptions(), mock, "hi")
+	if err == nil || !strings.Contains(err.Error(), "over budget") {
+		t.Fatalf("err = %v, want hook veto", err)
+	}
+	if len(mock.requests) != 0 {
+		t.Errorf("LLM called %d times after veto", len(mock.requests))
+	}
+}
+
+func TestHooksReadOnly(t *testing.T) {
+	turnFile := filepath.Join(t.TempDir(), "turn.json")
+	withHooks(t, `{"post_turn": [{"command": "cat > `+turnFile+`"}]}`)
+	storage.SetReadOnly(true)
+	t.Cleanup(func() { storage.SetReadOnly(false) })
+
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("all done", "end_turn")}}
+	if _, _, err := runScripted(t, claude.NewOptions(), mock, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(turnFile); !os.IsNotExist(err) {
+		t.Errorf("hook ran in read-only mode: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package claude
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// imageTypes are the media types the Messages API and Ollama accept
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageBlocks reads the --image files into image content blocks
+func imageBlocks(paths []string) ([]ContentBlock, error) {
+	var blocks []ContentBlock
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading image: %w", err)
+		}
+		if len(data) > MaxImageSize {
+			return nil, fmt.Errorf("image %s: %s is over the %s limit", path,
+				formatBytes(int64(len(data))), formatBytes(MaxImageSize))
+		}
+		mediaType := http.DetectContentType(data)
+		if !imageTypes[mediaType] {
+			return nil, fmt.Errorf("image %s: unsupported type %s (want PNG, JPEG, GIF or WebP)",
+				path, mediaType)
+		}
+		blocks = append(blocks, ContentBlock{
+			Type: "image",
+			Source: &llm.ImageSource{
+				Type:      "base64",
+				MediaType: mediaType,
+				Data:      base64.StdEncoding.EncodeToString(data),
+			},
+		})
+	}
+	return blocks, nil
+}
@@ -0,0 +1,207 @@
+package claude
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// isolate.go - Work on a branch of its own (--isolate)
+//
+// --isolate checks HEAD out into a temporary git worktree on a new branch
+// and runs the turn there, so the tools never see or touch the user's
+// working tree and its uncommitted changes. Afterwards the changes are
+// committed to the branch, the worktree is removed, and the user gets the
+// commands to review, merge or drop the branch, or a pull request.
+
+// IsolateBranchPrefix prefixes the branches --isolate creates
+const IsolateBranchPrefix = "claude/"
+
+// Isolation is a worktree created by Isolate
+type Isolation struct {
+	Branch string // branch holding the changes
+	Dir    string // working directory inside the worktree
+
+	repo     string // top level of the user's checkout
+	worktree string // top level of the worktree
+	base     string // commit the branch starts from
+	baseName string // branch checked out in repo, "" when detached
+}
+
+// Isolate creates a worktree of the checkout holding workingDir, on a new
+// branch at HEAD. Dir mirrors workingDir's place in the checkout.
+func Isolate(workingDir string) (*Isolation, error) {
+	repo, err := git(workingDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("--isolate: not a git checkout: %w", err)
+	}
+	base, err := git(repo, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("--isolate: no commit to branch from: %w", err)
+	}
+	baseName, _ := git(repo, "symbolic-ref", "--quiet", "--short", "HEAD")
+
+	// Paths below are compared to the git output, which resolves symlinks
+	realWorkingDir, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(repo, realWorkingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := os.MkdirTemp("", "claude-isolate-")
+	if err != nil {
+		return nil, fmt.Errorf("--isolate: %w", err)
+	}
+	branch := freeBranch(repo, IsolateBranchPrefix+storage.CurrentTimestamp())
+	if _, err := git(repo, "worktree", "add", "--quiet", "-b", branch,
+		worktree, base); err != nil {
+		os.RemoveAll(worktree)
+		return nil, fmt.Errorf("--isolate: %w", err)
+	}
+
+	iso := &Isolation{
+		Branch:   branch,
+		Dir:      filepath.Join(worktree, rel),
+		repo:     repo,
+		worktree: worktree,
+		base:     base,
+		baseName: baseName,
+	}
+	// Directories without tracked files aren't checked out
+	if err := os.MkdirAll(iso.Dir, 0o755); err != nil {
+		iso.remove()
+		return nil, fmt.Errorf("--isolate: %w", err)
+	}
+	slog.Info("isolated", "branch", branch, "worktree", worktree, "base", base)
+	return iso, nil
+}
+
+// Finish commits the changes made in the worktree to the branch, titled
+// after prompt, and removes the worktree. With openPR the branch is pushed
+// to origin and gh opens a pull request; otherwise, or when that fails,
+// the commands to review and merge are printed. A branch without changes
+// is deleted.
+func (iso *Isolation) Finish(prompt string, openPR bool) error {
+	// The worktree is left in place when committing fails so nothing is lost
+	committed, err := iso.commit(turnTitle(prompt))
+	if err != nil {
+		return fmt.Errorf("--isolate: %w (changes are in %s)", err, iso.worktree)
+	}
+	if err := iso.remove(); err != nil {
+		return fmt.Errorf("--isolate: %w", err)
+	}
+	if !committed {
+		if _, err := git(iso.repo, "branch", "-D", iso.Branch); err != nil {
+			return fmt.Errorf("--isolate: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "\n--isolate: no changes, branch %s deleted\n", iso.Branch)
+		return nil
+	}
+
+	if openPR {
+		url, err := iso.pullRequest(prompt)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "\n--isolate: opened %s\n", url)
+			return nil
+		}
+		slog.Warn("--isolate: no pull request", "err", err)
+	}
+
+	short := iso.base
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	fmt.Fprintf(os.Stderr, "\n--isolate: changes committed to branch %s\n"+
+		"  review:  git diff %s %s\n"+
+		"  merge:   git merge %s\n"+
+		"  discard: git branch -D %s\n",
+		iso.Branch, short, iso.Branch, iso.Branch, iso.Branch)
+	return nil
+}
+
+// commit commits everything in the worktree and reports whether there
+// was anything to commit
+func (iso *Isolation) commit(message string) (bool, error) {
+	if _, err := git(iso.worktree, "add", "--all"); err != nil {
+		return false, err
+	}
+	status, err := git(iso.worktree, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if status == "" {
+		return false, nil
+	}
+	if message == "" {
+		message = "claude: isolated changes"
+	}
+	if _, err := git(iso.worktree, "commit", "--quiet", "--no-verify",
+		"-m", message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// remove deletes the worktree, keeping its branch
+func (iso *Isolation) remove() error {
+	_, err := git(iso.repo, "worktree", "remove", "--force", iso.worktree)
+	return err
+}
+
+// pullRequest pushes the branch and opens a pull request against the
+// branch it started from, returning its URL
+func (iso *Isolation) pullRequest(prompt string) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("gh not installed")
+	}
+	if _, err := git(iso.repo, "push", "--quiet", "--set-upstream", "origin",
+		iso.Branch); err != nil {
+		return "", err
+	}
+	args := []string{"pr", "create", "--head", iso.Branch,
+		"--title", turnTitle(prompt), "--body", prompt}
+	if iso.baseName != "" {
+		args = append(args, "--base", iso.baseName)
+	}
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = iso.repo
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("gh pr create: %w: %s", err,
+			strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// freeBranch returns name, or name with a -N suffix when a branch of that
+// name exists, e.g. of an --isolate run in the same second
+func freeBranch(repo, name string) string {
+	branch := name
+	for n := 2; ; n++ {
+		if _, err := git(repo, "rev-parse", "--verify", "--quiet",
+			"refs/heads/"+branch); err != nil {
+			return branch
+		}
+		branch = fmt.Sprintf("%s-%d", name, n)
+	}
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err,
+			strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,84 @@
+package claude_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestIsolate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	os.MkdirAll(filepath.Join(repo, "pkg"), 0o755)
+	os.WriteFile(filepath.Join(repo, "pkg", "a.go"), []byte("package pkg\n"), 0o644)
+	git("add", "-A")
+	git("commit", "-qm", "initial")
+
+	// Uncommitted work the turn must not see or touch
+	os.WriteFile(filepath.Join(repo, "pkg", "a.go"), []byte("package pkg // wip\n"), 0o644)
+
+	iso, err := claude.Isolate(filepath.Join(repo, "pkg"))
+	if err != nil {
+		t.Fatalf("Isolate failed: %v", err)
+	}
+	if !strings.HasPrefix(iso.Branch, claude.IsolateBranchPrefix) ||
+		filepath.Base(iso.Dir) != "pkg" {
+		t.Errorf("branch %s, dir %s", iso.Branch, iso.Dir)
+	}
+	data, err := os.ReadFile(filepath.Join(iso.Dir, "a.go"))
+	if err != nil || string(data) != "package pkg\n" {
+		t.Fatalf("worktree a.go = %q, %v, want the committed version", data, err)
+	}
+	os.WriteFile(filepath.Join(iso.Dir, "b.go"), []byte("package pkg\n\nfunc B() {}\n"), 0o644)
+
+	if err := iso.Finish("add function B", false); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if _, err := os.Stat(iso.Dir); !os.IsNotExist(err) {
+		t.Errorf("worktree still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "pkg", "b.go")); !os.IsNotExist(err) {
+		t.Error("b.go written to the working tree")
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "pkg", "a.go")); string(data) != "package pkg // wip\n" {
+		t.Errorf("uncommitted change lost: %q", data)
+	}
+	if got := git("log", "-1", "--format=%s", iso.Branch); got != "add function B" {
+		t.Errorf("branch commit = %q", got)
+	}
+	if got := git("show", iso.Branch+":pkg/b.go"); !strings.Contains(got, "func B()") {
+		t.Errorf("b.go on branch = %q", got)
+	}
+
+	// A turn without changes leaves no branch behind
+	iso, err = claude.Isolate(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iso.Finish("just looking", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := git("branch", "--list", iso.Branch); got != "" {
+		t.Errorf("branch %s kept without changes", iso.Branch)
+	}
+}
@@ -0,0 +1,256 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// job.go - Background jobs (claude job)
+//
+// claude job submit runs a turn in a claude process detached from the
+// terminal, so a long agentic run survives the terminal closing or the
+// connection dropping. The job records its progress in .claude/jobs/<id>/
+// as it goes (see storage/jobs.go): claude job attach shows it from the
+// start and follows it to the answer, claude job cancel stops it. Jobs of
+// a project run one at a time: a job waits for the session lock, so jobs
+// submitted together form a queue.
+
+// Job subcommands
+const (
+	JobSubmit = "submit"
+	JobList   = "list"
+	JobAttach = "attach"
+	JobCancel = "cancel"
+	JobRun    = "run" // the detached process, started by submit
+)
+
+// JobLockWait is how long a queued job waits for the session lock
+const JobLockWait = 24 * time.Hour
+
+// jobPollInterval is how often claude job attach looks for progress
+const jobPollInterval = 250 * time.Millisecond
+
+// SubmitJob saves a job running prompt with the flags args and starts
+// exe job run ID args... in the background. The stderr of the process goes
+// to the job's output.log; its answer is in the events, not on stdout.
+func SubmitJob(claudeDir, exe, prompt string, args []string) (*storage.Job, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("claude job submit needs a prompt (-c or stdin)")
+	}
+	j := &storage.Job{
+		Prompt:    prompt,
+		Args:      args,
+		Status:    storage.JobQueued,
+		Submitted: time.Now().UTC(),
+	}
+	if err := storage.CreateJob(claudeDir, j); err != nil {
+		return nil, err
+	}
+	log, err := storage.CreateJobLog(claudeDir, j.ID)
+	if err != nil {
+		return nil, fmt.Errorf("creating job output: %w", err)
+	}
+	defer log.Close()
+
+	// The process records its own PID: saving it here would race it
+	cmd := exec.Command(exe, append([]string{"job", JobRun, j.ID}, args...)...)
+	cmd.Stderr = log
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		now := time.Now().UTC()
+		j.Status, j.Finished, j.Error = storage.JobFailed, &now, err.Error()
+		saveJob(claudeDir, j)
+		return nil, fmt.Errorf("starting job: %w", err)
+	}
+	return j, cmd.Process.Release()
+}
+
+// RunJob runs the turn of job id: claude job run, the process SubmitJob
+// starts. The calls and tool results of the turn are appended to the
+// job's events as they happen, the answer last, so the turn runs silent.
+func RunJob(opts *Options, claudeDir, apiURL, defaultSystemPrompt, id string,
+	writeOutputFunc func(string, bool, string, []byte) error,
+) error {
+	j, err := storage.LoadJob(claudeDir, id)
+	if err != nil {
+		return err
+	}
+	if j.Status != storage.JobQueued {
+		return fmt.Errorf("job %s is %s", id, j.Status)
+	}
+	j.PID = os.Getpid()
+	saveJob(claudeDir, j)
+
+	lock, err := storage.AcquireLock(claudeDir, JobLockWait)
+	if err != nil {
+		return finishJob(claudeDir, j, nil, "", err)
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			slog.Warn("releasing lock", "err", err)
+		}
+	}()
+	now := time.Now().UTC()
+	j.Status, j.Started = storage.JobRunning, &now
+	saveJob(claudeDir, j)
+
+	var prompt string
+	opts.Verbosity = VerbositySilent
+	opts.TurnModel, prompt = ParseDirective(j.Prompt)
+	sess, err := InitSession(opts, claudeDir, apiURL, defaultSystemPrompt)
+	if err != nil {
+		return finishJob(claudeDir, j, nil, "", err)
+	}
+	sess.stream = func(ev TurnEvent) { appendJobEvent(claudeDir, id, ev) }
+	result, err := ExecuteConversation(sess, prompt)
+	if err == nil {
+		err = FinalizeSession(sess, result, storage.SaveJSON, writeOutputFunc)
+	}
+	if err != nil {
+		return finishJob(claudeDir, j, sess, "", err)
+	}
+	return finishJob(claudeDir, j, sess, result.assistantText, nil)
+}
+
+// finishJob records how the job ended, its last event first, and returns
+// err
+func finishJob(claudeDir string, j *storage.Job, sess *session, answer string, err error) error {
+	summary := sess.Summary(err)
+	ev := TurnEvent{Type: TurnEventDone, Answer: answer, Summary: summary}
+	now := time.Now().UTC()
+	j.Status, j.Finished = storage.JobDone, &now
+	j.Iterations, j.Cost = summary.Iterations, summary.Cost
+	if sess != nil && !sess.ephemeral() {
+		j.Turn = sess.timestamp
+	}
+	if err != nil {
+		ev = TurnEvent{Type: TurnEventError, Error: err.Error(), Summary: summary}
+		j.Status, j.Error, j.Turn = storage.JobFailed, err.Error(), ""
+	}
+	ev.Turn = j.Turn
+	appendJobEvent(claudeDir, j.ID, ev)
+	saveJob(claudeDir, j)
+	return err
+}
+
+// saveJob saves j. It is best effort: the turn matters more than its
+// record.
+func saveJob(claudeDir string, j *storage.Job) {
+	if err := storage.SaveJob(claudeDir, j); err != nil {
+		slog.Warn("saving job", "job", j.ID, "err", err)
+	}
+}
+
+// appendJobEvent adds ev to the events of job id, best effort
+func appendJobEvent(claudeDir, id string, ev TurnEvent) {
+	data, err := json.Marshal(ev)
+	if err == nil {
+		err = storage.AppendJobEvent(claudeDir, id, data)
+	}
+	if err != nil {
+		slog.Warn("recording job progress", "job", id, "err", err)
+	}
+}
+
+// JobListCommand handles claude job list: the jobs of claudeDir with their
+// state
+func JobListCommand(w io.Writer, claudeDir string) error {
+	jobs, err := storage.ListJobs(claudeDir)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintln(os.Stderr, "No jobs (submit one with claude job submit)")
+		return nil
+	}
+	fmt.Fprintf(w, "%-18s  %-8s  %-16s  %5s  %9s  %s\n",
+		"ID", "STATUS", "SUBMITTED", "ITER", "COST", "PROMPT")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%-18s  %-8s  %-16s  %5d  %9s  %s\n", j.ID, j.State(),
+			j.Submitted.Local().Format("2006-01-02 15:04"), j.Iterations,
+			fmt.Sprintf("$%.4f", j.Cost), firstLine(j.Prompt))
+	}
+	return nil
+}
+
+// JobAttachCommand handles claude job attach: shows the progress of job
+// id from the start, tool calls on stderr, and follows it until the job
+// ends, printing the answer. When ctx is done it detaches and the job goes
+// on.
+func JobAttachCommand(ctx context.Context, claudeDir, id string) error {
+	var offset int64
+	for {
+		// The job before its events: the last event is written before the
+		// job is marked finished
+		j, err := storage.LoadJob(claudeDir, id)
+		if err != nil {
+			return err
+		}
+		var events [][]byte
+		events, offset, err = storage.ReadJobEvents(claudeDir, id, offset)
+		if err != nil {
+			return err
+		}
+		for _, data := range events {
+			var ev TurnEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				continue
+			}
+			if done, err := showTurnEvent(&ev); done {
+				if err != nil {
+					return fmt.Errorf("job %s: %w", id, err)
+				}
+				return nil
+			}
+		}
+		if state := j.State(); !j.Active() || state == storage.JobLost {
+			return fmt.Errorf("job %s is %s without an answer (see %s)", id, state,
+				filepath.Join(storage.JobDir(claudeDir, id), "output.log"))
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "Detached, the job goes on: claude job attach %s\n", id)
+			return nil
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// JobCancelCommand handles claude job cancel: stops job id and what it
+// started
+func JobCancelCommand(claudeDir, id string) error {
+	j, err := storage.LoadJob(claudeDir, id)
+	if err != nil {
+		return err
+	}
+	state := j.State()
+	if !j.Active() {
+		return fmt.Errorf("job %s is already %s", id, state)
+	}
+	if state != storage.JobLost && j.PID > 0 {
+		if err := terminate(j.PID); err != nil {
+			return fmt.Errorf("stopping job %s: %w", id, err)
+		}
+	}
+	now := time.Now().UTC()
+	j.Status, j.Finished = storage.JobCanceled, &now
+	if err := storage.SaveJob(claudeDir, j); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Canceled job %s\n", id)
+	if j.Started != nil {
+		fmt.Fprintln(os.Stderr, "Its turn was cut short: claude --recover shows what it did")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package claude_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestRunJob(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		".claude/fakes/worker.json": `{"responses": [{"text": "All done."}]}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	j := &storage.Job{Prompt: "do the work", Status: storage.JobQueued, Submitted: time.Now()}
+	if err := storage.CreateJob(claudeDir, j); err != nil {
+		t.Fatal(err)
+	}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = "fake:worker"
+	noOutput := func(string, bool, string, []byte) error { return nil }
+	if err := claude.RunJob(opts, claudeDir, "http://unused", "system", j.ID, noOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := storage.LoadJob(claudeDir, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != storage.JobDone || j.Turn == "" || j.Iterations != 1 || j.Finished == nil {
+		t.Errorf("job = %+v, want done with its turn", j)
+	}
+	events, _, err := storage.ReadJobEvents(claudeDir, j.ID, 0)
+	if err != nil || len(events) == 0 || !strings.Contains(string(events[len(events)-1]), "All done.") {
+		t.Errorf("events = %q, %v, want the answer last", events, err)
+	}
+
+	// Attaching to a finished job replays it
+	if err := claude.JobAttachCommand(context.Background(), claudeDir, j.ID); err != nil {
+		t.Errorf("attach: %v", err)
+	}
+	if err := claude.JobCancelCommand(claudeDir, j.ID); err == nil {
+		t.Error("canceled a finished job")
+	}
+	if err := claude.RunJob(opts, claudeDir, "http://unused", "system", j.ID, noOutput); err == nil {
+		t.Error("ran a finished job again")
+	}
+}
+
+func TestSubmitJob(t *testing.T) {
+	claudeDir := t.TempDir()
+	exe := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\necho answer\necho \"$@\" >&2\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := claude.SubmitJob(claudeDir, exe, " ", nil); err == nil {
+		t.Error("submitted an empty prompt")
+	}
+	j, err := claude.SubmitJob(claudeDir, exe, "do the work", []string{"--model=x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "job run " + j.ID + " --model=x"
+	log := filepath.Join(storage.JobDir(claudeDir, j.ID), "output.log")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		data, _ := os.ReadFile(log)
+		if strings.Contains(string(data), want) {
+			if strings.Contains(string(data), "answer") {
+				t.Errorf("output.log = %q, has the answer", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("output.log = %q, want %q", data, want)
+		}
+	}
+	if j, err := storage.LoadJob(claudeDir, j.ID); err != nil || j.Status != storage.JobQueued {
+		t.Errorf("job = %+v, %v, want queued", j, err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package claude
+
+import "syscall"
+
+// detached returns the process attributes of a job: a session of its own,
+// so the terminal closing doesn't hang it up and cancel can stop the
+// commands it started with it
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate stops the job process pid and its process group
+func terminate(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGTERM)
+}
@@ -0,0 +1,24 @@
+package claude
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedProcess is DETACHED_PROCESS: no console of the parent
+const detachedProcess = 0x00000008
+
+// detached returns the process attributes of a job: no console, so closing
+// the one it was submitted from doesn't end it
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
+
+// terminate stops the job process pid
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -0,0 +1,308 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// journal.go - Crash-safe turn journal and --recover
+//
+// Every iteration rewrites .claude/journal_<timestamp>.json with the
+// responses so far and the tools executed; a turn that ends normally
+// removes it. A journal left behind marks an interrupted turn, which
+// --recover shows and --recover --finalize or --recover --discard resolves.
+
+// Recover actions
+const (
+	RecoverShow     = "show"
+	RecoverFinalize = "finalize"
+	RecoverDiscard  = "discard"
+)
+
+// startJournal records the start of the turn
+func (s *session) startJournal() {
+	s.journal = &storage.Journal{
+		ConversationID: s.timestamp,
+		Model:          s.model,
+		Started:        time.Now().UTC(),
+	}
+	s.saveJournal()
+}
+
+// journalResponses records the responses received so far
+func (s *session) journalResponses(responses []json.RawMessage) {
+	s.journal.Responses = responses
+	s.journal.Iterations = len(responses)
+	s.saveJournal()
+}
+
+// journalTools records the tool calls in content and their outcome
+func (s *session) journalTools(content, results []ContentBlock) {
+	failed := make(map[string]bool)
+	for _, res := range results {
+		failed[res.ToolUseID] = strings.HasPrefix(res.Content, "Error: ")
+	}
+	for _, block := range content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		path, _ := block.Input["path"].(string)
+		s.journal.Tools = append(s.journal.Tools, storage.JournalTool{
+			Iteration: s.journal.Iterations,
+			ID:        block.ID,
+			Name:      block.Name,
+			Path:      path,
+			Failed:    failed[block.ID],
+		})
+	}
+	s.saveJournal()
+}
+
+// saveJournal writes the journal. It is best effort: a failure loses
+// recoverability, not the turn.
+func (s *session) saveJournal() {
+	if s.ephemeral() {
+		return
+	}
+	if err := storage.SaveJournal(s.claudeDir, s.journal); err != nil {
+		slog.Warn("writing turn journal", "err", err)
+	}
+}
+
+// endJournal removes the journal of a turn that ended normally
+func (s *session) endJournal() {
+	if s.ephemeral() {
+		return
+	}
+	if err := storage.RemoveJournal(s.claudeDir, s.timestamp); err != nil {
+		slog.Warn("removing turn journal", "err", err)
+	}
+}
+
+// warnInterrupted points at turns a previous run left unfinished
+func warnInterrupted(claudeDir string) {
+	ids, _ := storage.ListJournals(claudeDir)
+	if len(ids) > 0 {
+		slog.Warn("interrupted turns found, inspect with claude --recover",
+			"turns", strings.Join(ids, ","))
+	}
+}
+
+// RecoverCommand handles --recover: shows interrupted turns, or finalizes
+// (saves what they did into history) or discards them
+func RecoverCommand(claudeDir, action string) error {
+	ids, err := storage.ListJournals(claudeDir)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintln(os.Stderr, "No interrupted turns")
+		return nil
+	}
+
+	for _, id := range ids {
+		j, err := storage.LoadJournal(claudeDir, id)
+		if err != nil {
+			return fmt.Errorf("turn %s: %w", id, err)
+		}
+		switch action {
+		case RecoverShow:
+			showJournal(claudeDir, j)
+		case RecoverFinalize:
+			err = finalizeJournal(claudeDir, j)
+		case RecoverDiscard:
+			err = discardJournal(claudeDir, j)
+		default:
+			return fmt.Errorf("unknown recover action %q", action)
+		}
+		if err != nil {
+			return fmt.Errorf("turn %s: %w", id, err)
+		}
+	}
+
+	if action == RecoverShow {
+		fmt.Fprintf(os.Stderr, "Keep what was done: claude --recover --finalize\n")
+		fmt.Fprintf(os.Stderr, "Drop the turn:      claude --recover --discard\n")
+	}
+	return nil
+}
+
+// showJournal prints one interrupted turn
+func showJournal(claudeDir string, j *storage.Journal) {
+	fmt.Fprintf(os.Stderr, "Turn %s (%s)\n", j.ConversationID, j.Model)
+	reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", j.ConversationID))
+	if req, err := storage.LoadRequest(reqPath); err == nil {
+		if msg, err := GetLastUserMessage(req.Messages); err == nil {
+			fmt.Fprintf(os.Stderr, "  Prompt:     %s\n", firstLine(msg))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "  Started:    %s\n", j.Started.Local().Format(time.DateTime))
+	fmt.Fprintf(os.Stderr, "  Last seen:  %s\n", j.Updated.Local().Format(time.DateTime))
+	fmt.Fprintf(os.Stderr, "  Iterations: %d\n", j.Iterations)
+
+	done := make(map[string]bool)
+	for _, tool := range j.Tools {
+		done[tool.ID] = true
+		status := "ok"
+		if tool.Failed {
+			status = "failed"
+		}
+		fmt.Fprintf(os.Stderr, "  [%d] %-16s %-6s %s\n", tool.Iteration,
+			tool.Name, status, tool.Path)
+	}
+	// Requested in the last response but never reported back: the crash
+	// happened while these ran, so their effects are unknown
+	for _, block := range lastResponse(j).Content {
+		if block.Type == "tool_use" && !done[block.ID] {
+			path, _ := block.Input["path"].(string)
+			fmt.Fprintf(os.Stderr, "  [%d] %-16s %-6s %s\n", j.Iterations,
+				block.Name, "unknown", path)
+		}
+	}
+	if dir := filepath.Join(claudeDir, "backups", j.ConversationID); dirExists(dir) {
+		fmt.Fprintf(os.Stderr, "  Backups:    %s\n", dir)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// finalizeJournal saves the journaled responses as the turn's response so
+// it joins the history. A turn cut off mid tool loop gets a closing
+// assistant message, otherwise history would end in an unanswered
+// tool_use.
+func finalizeJournal(claudeDir string, j *storage.Journal) error {
+	// Crashed after the response was saved: nothing left to do
+	if _, err := storage.LoadResponses(claudeDir, j.ConversationID); err == nil {
+		return storage.RemoveJournal(claudeDir, j.ConversationID)
+	}
+
+	responses := append([]json.RawMessage{}, j.Responses...)
+	if last := lastResponse(j); last.StopReason != "end_turn" {
+		closing, err := json.Marshal(APIResponse{
+			Type:       "message",
+			Role:       "assistant",
+			Model:      j.Model,
+			StopReason: "end_turn",
+			Content: []ContentBlock{{
+				Type: "text",
+				Text: interruptedText(j),
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("marshaling response: %w", err)
+		}
+		responses = append(responses, closing)
+	}
+
+	data, err := json.MarshalIndent(responses, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshaling responses: %w", err)
+	}
+	if err := storage.SaveTurnMeta(claudeDir, j.ConversationID, journalMeta(j)); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	if err := storage.SaveResponse(claudeDir, j.ConversationID, data); err != nil {
+		return fmt.Errorf("saving responses: %w", err)
+	}
+
+	// The usage of the interrupted turn never made it into config.json
+	configPath := filepath.Join(claudeDir, "config.json")
+	cfg := storage.LoadOrCreateConfig(configPath)
+	for _, raw := range j.Responses {
+		var resp APIResponse
+		if json.Unmarshal(raw, &resp) == nil {
+			cfg.TotalInput += resp.Usage.InputTokens
+			cfg.TotalOutput += resp.Usage.OutputTokens
+		}
+	}
+	if err := storage.SaveJSON(configPath, cfg); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Finalized turn %s (%d iterations)\n",
+		j.ConversationID, j.Iterations)
+	return storage.RemoveJournal(claudeDir, j.ConversationID)
+}
+
+// journalMeta reconstructs the metadata of an interrupted turn; the time it
+// took runs until the last journal update
+func journalMeta(j *storage.Journal) *storage.TurnMeta {
+	meta := &storage.TurnMeta{
+		Model:      j.Model,
+		Provider:   ProviderOllama,
+		Iterations: len(j.Responses),
+		Started:    j.Started,
+		DurationMs: j.Updated.Sub(j.Started).Milliseconds(),
+	}
+	if isClaudeModel(j.Model, "") {
+		meta.Provider = ProviderClaude
+	} else if isFakeModel(j.Model) {
+		meta.Provider = ProviderFake
+	}
+	for _, raw := range j.Responses {
+		var resp APIResponse
+		if json.Unmarshal(raw, &resp) == nil {
+			meta.InputTokens += resp.Usage.InputTokens
+			meta.OutputTokens += resp.Usage.OutputTokens
+			meta.Cost += UsageCost(resp.Model, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+		}
+	}
+	return meta
+}
+
+// discardJournal drops the turn: its request and journal are removed.
+// Files its tools changed stay changed.
+func discardJournal(claudeDir string, j *storage.Journal) error {
+	reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", j.ConversationID))
+	if err := storage.Remove(reqPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing request: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Discarded turn %s\n", j.ConversationID)
+	for _, tool := range j.Tools {
+		if tool.Name == "write_file" && !tool.Failed {
+			fmt.Fprintf(os.Stderr, "  still modified: %s\n", tool.Path)
+		}
+	}
+	return storage.RemoveJournal(claudeDir, j.ConversationID)
+}
+
+// interruptedText is the closing message of a finalized turn
+func interruptedText(j *storage.Journal) string {
+	text := fmt.Sprintf("[This turn was interrupted after %d iterations "+
+		"and recovered.", j.Iterations)
+	var tools []string
+	for _, tool := range j.Tools {
+		t := tool.Name
+		if tool.Path != "" {
+			t += " " + tool.Path
+		}
+		if tool.Failed {
+			t += " (failed)"
+		}
+		tools = append(tools, t)
+	}
+	if len(tools) > 0 {
+		text += " Tools executed: " + strings.Join(tools, ", ") + "."
+	}
+	return text + "]"
+}
+
+// lastResponse decodes the most recent journaled response
+func lastResponse(j *storage.Journal) APIResponse {
+	var resp APIResponse
+	if n := len(j.Responses); n > 0 {
+		json.Unmarshal(j.Responses[n-1], &resp)
+	}
+	return resp
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,118 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestJournalRemovedOnSuccess(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		textResponse("done", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	if _, claudeDir, err := runScripted(t, opts, mock, "hi"); err != nil {
+		t.Fatalf("ExecuteConversation: %v", err)
+	} else if ids, _ := storage.ListJournals(claudeDir); len(ids) != 0 {
+		t.Errorf("journals left after a normal turn: %v", ids)
+	}
+}
+
+// interruptedTurn runs a turn that writes a file and then loses the LLM,
+// leaving a journal behind like a crash would
+func interruptedTurn(t *testing.T) (claudeDir, id string) {
+	t.Helper()
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_w", "hello\n"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Tool = "write"
+
+	_, claudeDir, err := runScripted(t, opts, mock, "write a.txt")
+	if err == nil {
+		t.Fatal("turn without a final answer succeeded")
+	}
+	ids, err := storage.ListJournals(claudeDir)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("ListJournals = %v, %v; want one journal", ids, err)
+	}
+	j, err := storage.LoadJournal(claudeDir, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Iterations != 1 || len(j.Tools) != 1 || j.Tools[0].Name != "write_file" || j.Tools[0].Failed {
+		t.Fatalf("journal = %+v, want one successful write_file", j)
+	}
+	return claudeDir, ids[0]
+}
+
+func TestRecoverFinalize(t *testing.T) {
+	claudeDir, id := interruptedTurn(t)
+
+	if err := claude.RecoverCommand(claudeDir, claude.RecoverFinalize); err != nil {
+		t.Fatalf("RecoverCommand: %v", err)
+	}
+	if ids, _ := storage.ListJournals(claudeDir); len(ids) != 0 {
+		t.Errorf("journal not removed: %v", ids)
+	}
+
+	responses, err := storage.LoadResponses(claudeDir, id)
+	if err != nil {
+		t.Fatalf("finalized turn has no response: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want journaled one plus closing", len(responses))
+	}
+
+	// History must end in plain text, not an unanswered tool_use
+	messages, err := storage.LoadConversationHistory(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "assistant" || len(last.Content) != 1 || last.Content[0].Type != "text" ||
+		!strings.Contains(last.Content[0].Text, "write_file a.txt") {
+		t.Errorf("last history message = %+v", last)
+	}
+}
+
+func TestRecoverDiscard(t *testing.T) {
+	claudeDir, id := interruptedTurn(t)
+
+	if err := claude.RecoverCommand(claudeDir, claude.RecoverDiscard); err != nil {
+		t.Fatalf("RecoverCommand: %v", err)
+	}
+	if ids, _ := storage.ListJournals(claudeDir); len(ids) != 0 {
+		t.Errorf("journal not removed: %v", ids)
+	}
+	reqPath := filepath.Join(claudeDir, "request_"+id+".json")
+	if _, err := os.Stat(reqPath); !os.IsNotExist(err) {
+		t.Errorf("request of discarded turn still present: %v", err)
+	}
+	// Discarding doesn't undo tool effects
+	if _, err := os.Stat("a.txt"); err != nil {
+		t.Errorf("written file gone: %v", err)
+	}
+}
+
+func TestRecoverShow(t *testing.T) {
+	claudeDir, _ := interruptedTurn(t)
+
+	if err := claude.RecoverCommand(claudeDir, claude.RecoverShow); err != nil {
+		t.Fatalf("RecoverCommand: %v", err)
+	}
+	if ids, _ := storage.ListJournals(claudeDir); len(ids) != 1 {
+		t.Errorf("showing changed the journals: %v", ids)
+	}
+	if err := claude.RecoverCommand(claudeDir, "bogus"); err == nil {
+		t.Error("unknown action accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package claude
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// limits.go - Resource limits of bash_command (policy.json limits)
+//
+// A model-invoked go test or an accidental infinite loop shouldn't take
+// the host down. bash_command runs its command with the ulimits of the
+// policy set first, under nice when asked, and keeps at most
+// MaxOutputBytes of each output stream. The limits hold for everything
+// the command starts.
+
+// DefaultMaxOutputBytes is the per stream output cap without a policy
+const DefaultMaxOutputBytes = 1 << 20
+
+// commandLimits are the limits of the policy
+var commandLimits = storage.CommandLimits{MaxOutputBytes: DefaultMaxOutputBytes}
+
+// ConfigureCommandLimits loads the command limits of .claude/policy.json
+func ConfigureCommandLimits(claudeDir string) error {
+	policy, err := storage.LoadPolicy(claudeDir)
+	if err != nil {
+		return err
+	}
+	l := policy.Limits
+	if l.CPUSeconds < 0 || l.MemoryMB < 0 || l.MaxOutputBytes < 0 {
+		return fmt.Errorf("policy limits must not be negative")
+	}
+	if l.Nice < 0 || l.Nice > 19 {
+		return fmt.Errorf("policy limits: nice must be 0-19, got %d", l.Nice)
+	}
+	if l.MaxOutputBytes == 0 {
+		l.MaxOutputBytes = DefaultMaxOutputBytes
+	}
+	commandLimits = l
+	return nil
+}
+
+// limitedCommand returns the argv running command under l
+func limitedCommand(command string, l storage.CommandLimits) []string {
+	var ulimits []string
+	if l.CPUSeconds > 0 {
+		ulimits = append(ulimits, "ulimit -t "+strconv.Itoa(l.CPUSeconds))
+	}
+	if l.MemoryMB > 0 {
+		ulimits = append(ulimits, "ulimit -v "+strconv.Itoa(l.MemoryMB*1024))
+	}
+	script := command
+	if len(ulimits) > 0 {
+		// A limit that can't be set fails the command rather than
+		// running it unconstrained
+		script = strings.Join(ulimits, " && ") + " && " + command
+	}
+
+	argv := []string{"bash", "-c", script}
+	if l.Nice > 0 {
+		argv = append([]string{"nice", "-n", strconv.Itoa(l.Nice)}, argv...)
+	}
+	return argv
+}
+
+// cappedBuffer keeps the first max bytes written to it and counts the rest
+type cappedBuffer struct {
+	b       strings.Builder
+	max     int
+	dropped int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.max - c.b.Len(); room < len(p) {
+		c.b.Write(p[:max(room, 0)])
+		c.dropped += len(p) - max(room, 0)
+		return len(p), nil
+	}
+	return c.b.Write(p)
+}
+
+// String returns the output kept, noting how much was cut
+func (c *cappedBuffer) String() string {
+	if c.dropped == 0 {
+		return c.b.String()
+	}
+	return fmt.Sprintf("%s\n[output truncated: %d more bytes over the %d byte limit]",
+		c.b.String(), c.dropped, c.max)
+}
@@ -0,0 +1,56 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestCommandLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := filepath.Join(tmpDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	os.WriteFile(filepath.Join(tmpDir, "big.txt"), []byte(strings.Repeat("x", 1000)), 0o644)
+	t.Cleanup(func() { claude.ConfigureCommandLimits(t.TempDir()) })
+
+	policy := filepath.Join(claudeDir, "policy.json")
+	os.WriteFile(policy, []byte(`{"limits": {"cpu_seconds": 10, "memory_mb": 1024,
+		"max_output_bytes": 100, "nice": 5}}`), 0o644)
+	if err := claude.ConfigureCommandLimits(claudeDir); err != nil {
+		t.Fatalf("ConfigureCommandLimits failed: %v", err)
+	}
+
+	opts := claude.NewOptions()
+	opts.SetTool("command")
+	opts.SetVerbosity(claude.VerbositySilent)
+	run := func(command string) string {
+		t.Helper()
+		block := claude.ContentBlock{Type: "tool_use", ID: "b", Name: "bash_command",
+			Input: map[string]interface{}{"command": command, "reason": "test"}}
+		result, err := claude.ExecuteTool(block, tmpDir, claudeDir, opts, "20260105_120000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content
+	}
+
+	// The limits don't get in the way of ordinary commands
+	if got := run("echo hello"); !strings.Contains(got, "Exit code: 0") ||
+		!strings.Contains(got, "hello") {
+		t.Errorf("echo under limits: %q", got)
+	}
+
+	got := run("cat big.txt")
+	if !strings.Contains(got, strings.Repeat("x", 100)+"\n[output truncated: 900 more bytes") ||
+		strings.Contains(got, strings.Repeat("x", 101)) {
+		t.Errorf("output not capped at 100 bytes: %q", got)
+	}
+
+	os.WriteFile(policy, []byte(`{"limits": {"nice": 40}}`), 0o644)
+	if err := claude.ConfigureCommandLimits(claudeDir); err == nil {
+		t.Error("ConfigureCommandLimits accepted nice 40")
+	}
+}
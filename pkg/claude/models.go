@@ -2,18 +2,25 @@ package claude
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/marcopeereboom/go-claude/pkg/display"
 	"github.com/marcopeereboom/go-claude/pkg/llm"
 	"github.com/marcopeereboom/go-claude/pkg/storage"
 )
 
-// ListModelsCommand handles --models-list flag
-func ListModelsCommand(claudeDir, ollamaURL string) error {
+// ListModelsCommand handles --models-list flag. With jsonOutput the models
+// are written to stdout as a JSON array for scripts.
+func ListModelsCommand(claudeDir, ollamaURL string, jsonOutput bool) error {
 	cache, err := storage.LoadModelsCache(claudeDir)
 	if err != nil || cache == nil {
 		// No cache exists - fetch and create
@@ -23,9 +30,32 @@ func ListModelsCommand(claudeDir, ollamaURL string) error {
 		}
 	}
 
+	models := make([]llm.ModelInfo, len(cache.Models))
+	for i, model := range cache.Models {
+		models[i] = withCapabilities(model)
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(models, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling models: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	fmt.Fprintln(os.Stderr, "Available models:")
-	for _, model := range cache.Models {
-		fmt.Fprintf(os.Stderr, "  %s (%s)\n", model.Name, model.Provider)
+	fmt.Fprintf(os.Stderr, "  %-36s %-8s %-7s %-8s %-8s %-5s %-6s %s\n",
+		"NAME", "PROVIDER", "PARAMS", "QUANT", "CONTEXT", "TOOLS", "VISION", "SIZE")
+	for _, model := range models {
+		size := "-"
+		if model.Size > 0 {
+			size = formatBytes(model.Size)
+		}
+		fmt.Fprintf(os.Stderr, "  %-36s %-8s %-7s %-8s %-8s %-5s %-6s %s\n",
+			model.Name, model.Provider, orDash(model.ParameterSize),
+			orDash(model.Quantization), formatContext(model.ContextWindow),
+			yesNo(model.SupportsTools), yesNo(model.SupportsVision), size)
 	}
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintf(os.Stderr, "Last updated: %s\n", cache.LastUpdated.Format("2006-01-02 15:04:05"))
@@ -33,6 +63,43 @@ func ListModelsCommand(claudeDir, ollamaURL string) error {
 	return nil
 }
 
+// withCapabilities fills in the context window and tool/vision support of
+// m from the provider's capability detection
+func withCapabilities(m llm.ModelInfo) llm.ModelInfo {
+	var caps llm.ModelCapabilities
+	if m.Provider == "ollama" {
+		caps = llm.NewOllama(m.Name, "").GetCapabilities()
+	} else {
+		caps = llm.NewClaude("", "").GetCapabilities()
+	}
+	m.ContextWindow = caps.MaxContextTokens
+	m.SupportsTools = caps.SupportsTools
+	m.SupportsVision = caps.SupportsVision
+	return m
+}
+
+// formatContext renders a context window in thousands of tokens
+func formatContext(tokens int) string {
+	if tokens <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dk", tokens/1000)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 // RefreshModelsCommand handles --models-refresh flag
 func RefreshModelsCommand(claudeDir, ollamaURL string) error {
 	cache, err := RefreshModelsCache(claudeDir, ollamaURL)
@@ -61,8 +128,7 @@ func RefreshModelsCache(claudeDir, ollamaURL string) (*storage.ModelsCache, erro
 		models, err := client.ListModels(ctx)
 		if err != nil {
 			// Non-fatal: continue with hardcoded list
-			fmt.Fprintf(os.Stderr,
-				"Warning: couldn't fetch Claude models: %v\n", err)
+			slog.Warn("couldn't fetch Claude models", "err", err)
 			allModels = append(allModels, getDefaultClaudeModels()...)
 		} else {
 			allModels = append(allModels, models...)
@@ -77,8 +143,7 @@ func RefreshModelsCache(claudeDir, ollamaURL string) (*storage.ModelsCache, erro
 	ollamaModels, err := ollamaClient.ListModels(ctx)
 	if err != nil {
 		// Non-fatal: Ollama might not be running
-		fmt.Fprintf(os.Stderr, "Warning: couldn't fetch Ollama models: %v\n",
-			err)
+		slog.Warn("couldn't fetch Ollama models", "err", err)
 	} else {
 		allModels = append(allModels, ollamaModels...)
 	}
@@ -96,7 +161,10 @@ func RefreshModelsCache(claudeDir, ollamaURL string) (*storage.ModelsCache, erro
 		Models:      allModels,
 	}
 
-	// Save cache
+	// Save cache, unless --read-only or --no-save
+	if storage.Ephemeral() {
+		return cache, nil
+	}
 	if err := storage.SaveModelsCache(claudeDir, cache); err != nil {
 		return nil, fmt.Errorf("saving models cache: %w", err)
 	}
@@ -108,18 +176,124 @@ func RefreshModelsCache(claudeDir, ollamaURL string) (*storage.ModelsCache, erro
 // Used when API query fails or no API key available
 func getDefaultClaudeModels() []llm.ModelInfo {
 	return []llm.ModelInfo{
-		{Name: "claude-opus-4-20250514", Provider: "claude"},
-		{Name: "claude-sonnet-4-20250514", Provider: "claude"},
-		{Name: "claude-sonnet-4-5-20250929", Provider: "claude"},
-		{Name: "claude-haiku-4-5-20251001", Provider: "claude"},
-		{Name: "claude-3-5-sonnet-20241022", Provider: "claude"},
-		{Name: "claude-3-5-haiku-20241022", Provider: "claude"},
+		{Name: "claude-opus-4-20250514", Provider: "claude", MaxOutputTokens: 32000},
+		{Name: "claude-sonnet-4-20250514", Provider: "claude", MaxOutputTokens: 64000},
+		{Name: "claude-sonnet-4-5-20250929", Provider: "claude", MaxOutputTokens: 64000},
+		{Name: "claude-haiku-4-5-20251001", Provider: "claude", MaxOutputTokens: 64000},
+		{Name: "claude-3-5-sonnet-20241022", Provider: "claude", MaxOutputTokens: 8192},
+		{Name: "claude-3-5-haiku-20241022", Provider: "claude", MaxOutputTokens: 8192},
+	}
+}
+
+// knownModels returns the models cache plus the built-in Claude list
+func knownModels(claudeDir string) []llm.ModelInfo {
+	var models []llm.ModelInfo
+	if cache, err := storage.LoadModelsCache(claudeDir); err == nil && cache != nil {
+		models = cache.Models
+	}
+	return append(models, getDefaultClaudeModels()...)
+}
+
+// ResolveMaxTokens returns the max_tokens ceiling for model from the
+// models cache, falling back to the built-in Claude list and finally
+// DefaultMaxTokens when the limit is unknown (e.g. Ollama models)
+func ResolveMaxTokens(model, claudeDir string) int {
+	for _, m := range knownModels(claudeDir) {
+		if m.Name == model && m.MaxOutputTokens > 0 {
+			return m.MaxOutputTokens
+		}
+	}
+	return DefaultMaxTokens
+}
+
+// timeoutTokensPerSecond is a slow output rate of Claude. Calls aren't
+// streamed, so the whole answer must arrive within --timeout.
+const timeoutTokensPerSecond = 30
+
+// defaultMaxTokens returns max_tokens of a call to model without
+// --max-tokens: the model's limit, but no more than it writes in timeout
+// seconds (0 = no timeout). Longer answers are continued in another call.
+func defaultMaxTokens(model, claudeDir string, timeout int) int {
+	limit := ResolveMaxTokens(model, claudeDir)
+	if timeout > 0 {
+		limit = min(limit, timeout*timeoutTokensPerSecond)
+	}
+	return limit
+}
+
+// Model families usable as --model aliases. ModelAliasLatest picks the
+// newest Claude model of any family.
+var modelFamilies = []string{"opus", "sonnet", "haiku"}
+
+const ModelAliasLatest = "latest"
+
+// modelDate matches the release date in dated model IDs
+var modelDate = regexp.MustCompile(`\d{8}`)
+
+// ResolveModelAlias maps an alias ("sonnet", "haiku", "opus" or "latest")
+// to the newest matching dated model ID in the models cache. Other names
+// are returned unchanged.
+func ResolveModelAlias(model, claudeDir string) (string, error) {
+	family := strings.ToLower(model)
+	if family == ModelAliasLatest {
+		family = ""
+	} else if !slices.Contains(modelFamilies, family) {
+		return model, nil
+	}
+
+	resolved := newestModel(knownModels(claudeDir), family)
+	if resolved == "" {
+		return "", fmt.Errorf("no model matches alias %q (run --models-refresh to update)", model)
+	}
+	slog.Info("model alias", "alias", model, "model", resolved)
+	return resolved, nil
+}
+
+// newestModel returns the dated Claude model with the latest release date
+// whose name contains family ("" matches any)
+func newestModel(models []llm.ModelInfo, family string) string {
+	var newest, newestDate string
+	for _, m := range models {
+		if m.Provider == "ollama" || !strings.Contains(m.Name, family) {
+			continue
+		}
+		date := modelDate.FindString(m.Name)
+		if date == "" {
+			continue
+		}
+		if date > newestDate || (date == newestDate && m.Name > newest) {
+			newest, newestDate = m.Name, date
+		}
+	}
+	return newest
+}
+
+// warnSuperseded warns when model, pinned in config.json, has a newer
+// release of the same family
+func warnSuperseded(model, claudeDir string) {
+	date := modelDate.FindString(model)
+	if date == "" {
+		return
+	}
+	for _, family := range modelFamilies {
+		if !strings.Contains(model, family) {
+			continue
+		}
+		newest := newestModel(knownModels(claudeDir), family)
+		if newest != "" && modelDate.FindString(newest) > date {
+			slog.Warn("pinned model superseded (use --model "+family+" for the newest)",
+				"model", model, "newest", newest)
+		}
+		return
 	}
 }
 
 // ValidateModel checks if model exists in cache
 // If no cache, creates one and validates
 func ValidateModel(model, claudeDir, ollamaURL string) error {
+	if isFakeModel(model) {
+		return nil // scripted, never listed
+	}
 	cache, err := storage.LoadModelsCache(claudeDir)
 	if err != nil || cache == nil {
 		// Try to create cache
@@ -139,8 +313,55 @@ func ValidateModel(model, claudeDir, ollamaURL string) error {
 
 	// Model not found - but this might be okay if cache is stale
 	// Just warn, don't error
-	fmt.Fprintf(os.Stderr,
-		"Warning: model %s not in cache (run --models-refresh to update)\n",
-		model)
+	slog.Warn("model not in cache (run --models-refresh to update)", "model", model)
 	return nil
 }
+
+// PullModel downloads an Ollama model (--ollama-auto-pull), showing
+// progress on stderr, and refreshes the models cache
+func PullModel(ctx context.Context, claudeDir, ollamaURL, model string) error {
+	slog.Info("pulling Ollama model", "model", model)
+	tty := display.IsTTY(os.Stderr)
+	status := ""
+	err := llm.NewOllama(model, ollamaURL).Pull(ctx, model, func(p llm.PullProgress) {
+		line := p.Status
+		if p.Total > 0 {
+			line = fmt.Sprintf("%s %3d%% (%s/%s)", p.Status,
+				p.Completed*100/p.Total, formatBytes(p.Completed),
+				formatBytes(p.Total))
+		}
+		switch {
+		case tty:
+			fmt.Fprintf(os.Stderr, "\rPulling %s: %s\033[K", model, line)
+		case p.Status != status:
+			// Without a terminal only print each phase once
+			fmt.Fprintf(os.Stderr, "Pulling %s: %s\n", model, p.Status)
+		}
+		status = p.Status
+	})
+	if tty {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := RefreshModelsCache(claudeDir, ollamaURL); err != nil {
+		slog.Warn("refreshing models cache", "err", err)
+	}
+	return nil
+}
+
+// formatBytes renders n as a human readable size
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
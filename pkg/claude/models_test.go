@@ -0,0 +1,95 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestListModelsJSON(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{
+			{Name: claude.DefaultModel, Provider: "claude"},
+			{Name: "llama3.1:8b", Provider: "ollama", ParameterSize: "8.0B",
+				Quantization: "Q4_K_M", Size: 4920753328},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = claude.ListModelsCommand(claudeDir, "http://127.0.0.1:1", true)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatalf("ListModelsCommand: %v", err)
+	}
+	out, _ := io.ReadAll(r)
+
+	var models []llm.ModelInfo
+	if err := json.Unmarshal(out, &models); err != nil {
+		t.Fatalf("output is not a JSON model list: %v\n%s", err, out)
+	}
+	if len(models) != 2 {
+		t.Fatalf("got %d models, want 2", len(models))
+	}
+	if m := models[0]; !m.SupportsTools || !m.SupportsVision || m.ContextWindow != 200000 {
+		t.Errorf("claude model capabilities missing: %+v", m)
+	}
+	if m := models[1]; !m.SupportsTools || m.SupportsVision || m.ContextWindow != 128000 ||
+		m.ParameterSize != "8.0B" || m.Size != 4920753328 {
+		t.Errorf("ollama model details missing: %+v", m)
+	}
+}
+
+func TestResolveModelAlias(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{
+			{Name: "claude-sonnet-4-20250514", Provider: "claude"},
+			{Name: "claude-sonnet-4-7-20270101", Provider: "claude"},
+			{Name: "claude-opus-4-20250514", Provider: "claude"},
+			{Name: "sonnet-finetune:latest", Provider: "ollama"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"sonnet", "claude-sonnet-4-7-20270101"},
+		{"Sonnet", "claude-sonnet-4-7-20270101"},
+		{"latest", "claude-sonnet-4-7-20270101"},
+		// The built-in list backs up the cache
+		{"haiku", "claude-haiku-4-5-20251001"},
+		{"opus", "claude-opus-4-20250514"},
+		{"llama3.1:8b", "llama3.1:8b"},
+		{"claude-sonnet-4-20250514", "claude-sonnet-4-20250514"},
+	}
+	for _, tt := range tests {
+		got, err := claude.ResolveModelAlias(tt.model, claudeDir)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveModelAlias(%q) = %q, %v; want %q", tt.model, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,648 @@
+package claude
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// openai_proxy.go - OpenAI-compatible proxy (--openai-proxy)
+//
+// claude --openai-proxy=ADDR answers the OpenAI chat API on a loopback
+// address, so editors and tools that only speak it run on the configured
+// providers:
+//
+//	POST /v1/chat/completions  one model call, OpenAI request and response
+//	GET  /v1/models            the cached models, and auto
+//
+// The model of a request picks the provider like --model. The model auto
+// lets the router choose between the --model Ollama model and Claude, and
+// no model is the model of the project. Clients send the whole
+// conversation with each request, so nothing is saved as a turn; each call
+// updates the provider stats in config.json and the audit log in
+// tool_log.jsonl. --max-cost bounds the Claude spend while the proxy runs.
+// Sessions rewrite config.json too, so the proxy reads it anew for every
+// call and updates it under the session lock, in the background: a turn
+// running in the project holds the lock and mustn't hold up the answers.
+//
+// Listening on loopback doesn't keep browsers out: a page can post to it,
+// or rebind its own name to 127.0.0.1. So requests must name a loopback
+// host and completions must be sent as JSON, which pages can't do without
+// asking first. With CLAUDE_PROXY_TOKEN set, requests need it as a bearer
+// token too, for hosts where other users reach the loopback address.
+
+// DefaultOpenAIProxyAddr is the address of --openai-proxy without one
+const DefaultOpenAIProxyAddr = "127.0.0.1:8089"
+
+// ModelAuto lets the router pick the model of an --openai-proxy request
+const ModelAuto = "auto"
+
+// EnvProxyToken holds the bearer token --openai-proxy requests must send
+const EnvProxyToken = "CLAUDE_PROXY_TOKEN"
+
+// ProxyLockWait is how long a stats update of --openai-proxy waits for the
+// session lock
+const ProxyLockWait = time.Hour
+
+// openAIRequest is the body of POST /v1/chat/completions
+type openAIRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	Tools               []openAITool    `json:"tools,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	Stream              bool            `json:"stream,omitempty"`
+}
+
+// openAIMessage is a chat message. Content is a string or, from newer
+// clients, an array of parts.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"` // function
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    *int   `json:"index,omitempty"` // stream chunks only
+	ID       string `json:"id"`
+	Type     string `json:"type"` // function
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON encoded
+	} `json:"function"`
+}
+
+// openAIResponse is a chat.completion, or a chat.completion.chunk of a
+// streamed one
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIReply `json:"message,omitempty"`
+	Delta        *openAIReply `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type openAIReply struct {
+	Role      string           `json:"role,omitempty"`
+	Content   *string          `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openAIError is the error body OpenAI clients understand
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// OpenAIProxy serves the OpenAI API
+type OpenAIProxy struct {
+	base      Options // from the command line of --openai-proxy
+	claudeDir string
+	apiURL    string
+	token     string       // requests must send, when set
+	handler   http.Handler // the API behind guard
+
+	mu    sync.Mutex // spent
+	spent float64    // on Claude models
+
+	stats sync.WaitGroup // stats updates under way
+}
+
+// ServeOpenAI runs the OpenAI-compatible proxy on addr, which must be a
+// loopback address, until ctx is done
+func ServeOpenAI(ctx context.Context, addr string, base *Options, claudeDir, apiURL string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	p, err := NewOpenAIProxy(base, claudeDir, apiURL)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: p}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Fprintf(os.Stderr, "OpenAI-compatible API on http://%s/v1\n", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NewOpenAIProxy returns the handler of the OpenAI API. base holds the
+// options of every call; stats and the audit log go to claudeDir. Close
+// it to wait for the stats of the calls it answered.
+func NewOpenAIProxy(base *Options, claudeDir, apiURL string) (*OpenAIProxy, error) {
+	if err := storage.MkdirAll(claudeDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating .claude dir: %w", err)
+	}
+	p := &OpenAIProxy{
+		base:      *base,
+		claudeDir: claudeDir,
+		apiURL:    apiURL,
+		token:     os.Getenv(EnvProxyToken),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", p.handleCompletions)
+	mux.HandleFunc("GET /v1/models", p.handleModels)
+	p.handler = p.guard(mux)
+	return p, nil
+}
+
+// ServeHTTP answers a request of the OpenAI API
+func (p *OpenAIProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.handler.ServeHTTP(w, r)
+}
+
+// Close waits for the stats updates of the calls answered so far
+func (p *OpenAIProxy) Close() error {
+	p.stats.Wait()
+	return nil
+}
+
+// guard refuses requests that don't come from a local client: those for
+// another host and, with a token, those without it
+func (p *OpenAIProxy) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if checkLoopback(net.JoinHostPort(host, "0")) != nil {
+			writeOpenAIError(w, http.StatusForbidden, "permission_error",
+				fmt.Sprintf("host %q is not a loopback address", r.Host))
+			return
+		}
+		if p.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(p.token)) != 1 {
+				writeOpenAIError(w, http.StatusUnauthorized, "invalid_request_error",
+					"missing or wrong bearer token ("+EnvProxyToken+")")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkLoopback refuses addresses reachable from other hosts: the proxy
+// spends the user's credentials on whoever connects
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("--openai-proxy: %w", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("--openai-proxy: %s is not a loopback address", addr)
+}
+
+// writeOpenAIError sends an error the way the OpenAI API does
+func writeOpenAIError(w http.ResponseWriter, status int, typ, msg string) {
+	var e openAIError
+	e.Error.Message, e.Error.Type = msg, typ
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+func (p *OpenAIProxy) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		writeOpenAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error",
+			"requests must be sent as application/json")
+		return
+	}
+	var req openAIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error",
+			"invalid request: "+err.Error())
+		return
+	}
+	llmReq, err := fromOpenAI(&req)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	p.mu.Lock()
+	overBudget := p.base.MaxCost > 0 && p.spent >= p.base.MaxCost
+	spent := p.spent
+	p.mu.Unlock()
+	if overBudget {
+		writeOpenAIError(w, http.StatusTooManyRequests, "insufficient_quota",
+			fmt.Sprintf("--max-cost budget of the proxy spent ($%.4f of $%.4f)",
+				spent, p.base.MaxCost))
+		return
+	}
+
+	client, provider, err := p.route(&req, llmReq)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if llmReq.MaxTokens == 0 {
+		llmReq.MaxTokens = defaultMaxTokens(llmReq.Model, p.claudeDir, p.base.Timeout)
+	}
+
+	resp, err := generate(r.Context(), client, llmReq, provider, 1)
+	if err != nil && provider == ProviderOllama && p.base.AllowFallback {
+		slog.Warn("openai proxy: Ollama failed, falling back to Claude", "err", err)
+		llmReq.Model = p.fallbackModel()
+		if client, provider, err = modelClient(llmReq.Model, &p.base, p.claudeDir, p.apiURL); err == nil {
+			resp, err = generate(r.Context(), client, llmReq, provider, 1)
+		}
+	}
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	if err != nil {
+		p.audit(id, &req, llmReq.Model, nil, 0, err, start)
+		writeOpenAIError(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+
+	cost := UsageCost(llmReq.Model, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+	p.record(provider, resp.Usage, cost)
+	p.audit(id, &req, llmReq.Model, resp, cost, nil, start)
+	slog.Info("openai proxy", "model", llmReq.Model, "provider", provider,
+		"input_tokens", resp.Usage.InputTokens, "output_tokens", resp.Usage.OutputTokens,
+		"cost", fmt.Sprintf("$%.4f", cost))
+
+	out := toOpenAI(id, llmReq.Model, resp)
+	if req.Stream {
+		writeOpenAIStream(w, out)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// route picks the model and client of req, setting llmReq.Model
+func (p *OpenAIProxy) route(req *openAIRequest, llmReq *llm.Request) (llm.LLM, string, error) {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(p.claudeDir, "config.json"))
+	model := SelectModel(p.base.Model, cfg.Model)
+
+	switch req.Model {
+	case "":
+	case ModelAuto:
+		decision, err := routeModel(&p.base, cfg, model,
+			lastUserText(llmReq.Messages), len(llmReq.Tools) > 0)
+		if err != nil {
+			return nil, "", err
+		}
+		slog.Info("openai proxy: routed", "decision", decision.String(), "rule", decision.Rule)
+		if p.base.ExplainRouting {
+			display.Info("%s", decision.Explain())
+		}
+		model = decision.ModelName
+	default:
+		resolved, err := ResolveModelAlias(req.Model, p.claudeDir)
+		if err != nil {
+			return nil, "", err
+		}
+		model = resolved
+	}
+
+	llmReq.Model = model
+	return modelClient(model, &p.base, p.claudeDir, p.apiURL)
+}
+
+// fallbackModel is the Claude model of auto and of --allow-fallback
+func (p *OpenAIProxy) fallbackModel() string {
+	if p.base.FallbackModel != "" {
+		return p.base.FallbackModel
+	}
+	return DefaultModel
+}
+
+// record adds a call to the spend of the proxy and, in the background, to
+// the provider stats of the project
+func (p *OpenAIProxy) record(provider string, usage llm.Usage, cost float64) {
+	p.mu.Lock()
+	p.spent += cost
+	p.mu.Unlock()
+
+	p.stats.Add(1)
+	go func() {
+		defer p.stats.Done()
+		if err := p.saveStats(provider, usage); err != nil {
+			slog.Warn("openai proxy: saving stats", "err", err)
+		}
+	}()
+}
+
+// saveStats adds a call to the provider stats in config.json, holding the
+// session lock from reading the file to writing it
+func (p *OpenAIProxy) saveStats(provider string, usage llm.Usage) error {
+	lock, err := storage.AcquireLock(p.claudeDir, ProxyLockWait)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			slog.Warn("openai proxy: releasing lock", "err", err)
+		}
+	}()
+
+	path := filepath.Join(p.claudeDir, "config.json")
+	cfg := storage.LoadOrCreateConfig(path)
+	storage.UpdateProviderStats(cfg, provider, usage.InputTokens, usage.OutputTokens)
+	return storage.SaveJSON(path, cfg)
+}
+
+// audit logs a call in the audit log, without the conversation itself
+func (p *OpenAIProxy) audit(id string, req *openAIRequest, model string,
+	resp *llm.Response, cost float64, callErr error, start time.Time,
+) {
+	input := map[string]interface{}{
+		"model":    req.Model,
+		"messages": len(req.Messages),
+		"tools":    len(req.Tools),
+		"stream":   req.Stream,
+	}
+	result := map[string]interface{}{"model": model}
+	if callErr != nil {
+		result["error"] = callErr.Error()
+	} else {
+		result["stop_reason"] = resp.StopReason
+		result["input_tokens"] = resp.Usage.InputTokens
+		result["output_tokens"] = resp.Usage.OutputTokens
+		result["cost"] = cost
+	}
+	logAuditEntry(p.claudeDir, "openai_proxy", input, result, callErr == nil,
+		id, start, false)
+}
+
+func (p *OpenAIProxy) handleModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	list := []model{{ID: ModelAuto, Object: "model", OwnedBy: "router"}}
+	if cache, err := storage.LoadModelsCache(p.claudeDir); err == nil && cache != nil {
+		for _, m := range cache.Models {
+			list = append(list, model{ID: m.ID, Object: "model", OwnedBy: m.Provider})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": list})
+}
+
+// fromOpenAI converts an OpenAI chat request to a model request. System
+// messages become the system prompt, tool messages tool results.
+func fromOpenAI(req *openAIRequest) (*llm.Request, error) {
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("messages must not be empty")
+	}
+	out := &llm.Request{
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if req.MaxCompletionTokens > 0 {
+		out.MaxTokens = req.MaxCompletionTokens
+	}
+	for _, t := range req.Tools {
+		if t.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type %q", t.Type)
+		}
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		out.Tools = append(out.Tools, llm.Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	var system []string
+	for i, m := range req.Messages {
+		text, err := openAIText(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		var blocks []ContentBlock
+		role := m.Role
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, text)
+			continue
+		case "user":
+			blocks = append(blocks, ContentBlock{Type: "text", Text: text})
+		case "assistant":
+			if text != "" {
+				blocks = append(blocks, ContentBlock{Type: "text", Text: text})
+			}
+			for _, call := range m.ToolCalls {
+				input := map[string]interface{}{}
+				if call.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(call.Function.Arguments), &input); err != nil {
+						return nil, fmt.Errorf("messages[%d]: arguments of %s: %w",
+							i, call.Function.Name, err)
+					}
+				}
+				blocks = append(blocks, ContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+		case "tool":
+			role = "user"
+			blocks = append(blocks, ContentBlock{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   text,
+			})
+		default:
+			return nil, fmt.Errorf("messages[%d]: unsupported role %q", i, m.Role)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// The Messages API alternates roles; OpenAI sends one message per
+		// tool result
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, MessageContent{Role: role, Content: blocks})
+	}
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("no user message")
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out, nil
+}
+
+// openAIText returns the text of message content, a string or an array
+// of text parts
+func openAIText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of parts")
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("unsupported content part %q", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// toOpenAI converts a model response to a chat.completion
+func toOpenAI(id, model string, resp *llm.Response) *openAIResponse {
+	reply := &openAIReply{Role: "assistant"}
+	var texts []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			args, err := json.Marshal(block.Input)
+			if err != nil {
+				args = []byte("{}")
+			}
+			call := openAIToolCall{ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			call.Function.Arguments = string(args)
+			reply.ToolCalls = append(reply.ToolCalls, call)
+		}
+	}
+	if len(texts) > 0 || len(reply.ToolCalls) == 0 {
+		text := strings.Join(texts, "")
+		reply.Content = &text
+	}
+
+	finish := "stop"
+	switch {
+	case len(reply.ToolCalls) > 0:
+		finish = "tool_calls"
+	case resp.StopReason == "max_tokens":
+		finish = "length"
+	case resp.StopReason == "refusal":
+		finish = "content_filter"
+	}
+	return &openAIResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []openAIChoice{{Message: reply, FinishReason: &finish}},
+		Usage: &openAIUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+}
+
+// writeOpenAIStream sends a completion as server-sent events: the whole
+// reply in one chunk, then the finish reason. Calls aren't streamed from
+// the providers, but clients that always ask for a stream still work.
+func writeOpenAIStream(w http.ResponseWriter, out *openAIResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	choice := out.Choices[0]
+	delta := *choice.Message
+	for i := range delta.ToolCalls {
+		index := i
+		delta.ToolCalls[i].Index = &index
+	}
+
+	send := func(c openAIChoice, usage *openAIUsage) {
+		chunk := *out
+		chunk.Object = "chat.completion.chunk"
+		chunk.Choices = []openAIChoice{c}
+		chunk.Usage = usage
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	send(openAIChoice{Delta: &delta}, nil)
+	send(openAIChoice{Delta: &openAIReply{}, FinishReason: choice.FinishReason}, out.Usage)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// lastUserText is the text of the last user message, what the router
+// judges the request by
+func lastUserText(messages []MessageContent) string {
+	text, _ := GetLastUserMessage(messages)
+	return text
+}
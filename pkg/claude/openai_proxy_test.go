@@ -0,0 +1,206 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestOpenAIProxy(t *testing.T) {
+	var ollamaBody []byte
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ollamaBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"local answer"},"done":true}`))
+	}))
+	defer ollama.Close()
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"tool_use","id":"call_1","name":"lookup",` +
+			`"input":{"q":"go"}}],"stop_reason":"tool_use",` +
+			`"usage":{"input_tokens":1000000,"output_tokens":0}}`))
+	}))
+	defer anthropic.Close()
+
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+	opts := claude.NewOptions()
+	opts.Model = "llama9:1b"
+	opts.OllamaURL = ollama.URL
+	opts.MaxCost = 1
+	handler, err := claude.NewOpenAIProxy(opts, claudeDir, anthropic.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// Changes made by sessions while the proxy runs are kept
+	if err := storage.SaveJSON(filepath.Join(claudeDir, "config.json"),
+		&storage.Config{LastRun: "20260101_120000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, data
+	}
+
+	// No model is the project model; system messages are the system prompt
+	resp, data := post(`{"messages":[{"role":"system","content":"be brief"},` +
+		`{"role":"user","content":[{"type":"text","text":"hi"}]}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var completion struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content   *string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &completion); err != nil {
+		t.Fatal(err)
+	}
+	if completion.Model != "llama9:1b" || completion.Choices[0].FinishReason != "stop" ||
+		*completion.Choices[0].Message.Content != "local answer" {
+		t.Errorf("completion = %s", data)
+	}
+	if !strings.Contains(string(ollamaBody), "be brief") {
+		t.Errorf("system prompt not sent: %s", ollamaBody)
+	}
+
+	// Streams are sent as events ending in [DONE]
+	resp, data = post(`{"messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("stream content type %q", ct)
+	}
+	if s := string(data); !strings.Contains(s, `"content":"local answer"`) ||
+		!strings.HasSuffix(s, "data: [DONE]\n\n") {
+		t.Errorf("stream = %s", s)
+	}
+
+	// Tool calls come back in OpenAI form; this call spends the budget
+	resp, data = post(`{"model":"claude-sonnet-4-20250514","messages":[` +
+		`{"role":"user","content":"look it up"}],"tools":[{"type":"function",` +
+		`"function":{"name":"lookup","parameters":{"type":"object"}}}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	completion.Choices = nil
+	json.Unmarshal(data, &completion)
+	if c := completion.Choices[0]; c.FinishReason != "tool_calls" || len(c.Message.ToolCalls) != 1 ||
+		c.Message.ToolCalls[0].Function.Arguments != `{"q":"go"}` {
+		t.Errorf("tool call completion = %s", data)
+	}
+	resp, data = post(`{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"again"}]}`)
+	if resp.StatusCode != http.StatusTooManyRequests || !strings.Contains(string(data), "insufficient_quota") {
+		t.Errorf("over budget: status %d: %s", resp.StatusCode, data)
+	}
+
+	resp, _ = post(`{"messages":[]}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty messages: status %d", resp.StatusCode)
+	}
+
+	// Every call is audited and counted in the project stats
+	log, err := os.ReadFile(filepath.Join(claudeDir, "tool_log.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(log), `"tool":"openai_proxy"`); n != 3 {
+		t.Errorf("%d audit entries, want 3:\n%s", n, log)
+	}
+	handler.Close()
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	if cfg.OllamaStats.RequestCount != 2 || cfg.ClaudeStats.RequestCount != 1 {
+		t.Errorf("stats: ollama %d, claude %d requests", cfg.OllamaStats.RequestCount,
+			cfg.ClaudeStats.RequestCount)
+	}
+	if cfg.LastRun != "20260101_120000" {
+		t.Errorf("last_run = %q, overwritten by the proxy", cfg.LastRun)
+	}
+	if _, err := os.Stat(filepath.Join(claudeDir, "lock")); !os.IsNotExist(err) {
+		t.Errorf("session lock left behind: %v", err)
+	}
+}
+
+func TestOpenAIProxyGuard(t *testing.T) {
+	t.Setenv(claude.EnvProxyToken, "s3cret")
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{})
+	handler, err := claude.NewOpenAIProxy(claude.NewOptions(), claudeDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		host        string
+		contentType string
+		token       string
+		want        int
+	}{
+		{"token", "GET", "/v1/models", "", "", "s3cret", http.StatusOK},
+		{"localhost", "GET", "/v1/models", "localhost:8089", "", "s3cret", http.StatusOK},
+		{"no token", "GET", "/v1/models", "", "", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "/v1/models", "", "", "guess", http.StatusUnauthorized},
+		// A page on a rebound name reaches the loopback address
+		{"other host", "GET", "/v1/models", "evil.example:8089", "", "s3cret", http.StatusForbidden},
+		// Pages post text/plain without asking first
+		{"not json", "POST", "/v1/chat/completions", "", "text/plain", "s3cret", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path,
+				strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package claude
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/diff"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// patch.go - Patch output mode (--output=patch)
+//
+// With --output=patch write_file never touches the tree. Each write is
+// recorded instead, read_file sees the recorded content so the model can
+// build on its own edits, and at the end of the turn all changes are
+// written as one unified diff for review and `git apply`.
+
+// patchContext is the number of unchanged lines around each hunk
+const patchContext = 3
+
+// patchSet holds the files written during an --output=patch run
+type patchSet struct {
+	workingDir string
+	prefix     string                  // of the working directory in its git checkout
+	files      map[string]*patchedFile // by slash separated relative path
+}
+
+// patchedFile is the original and the latest written content of a file
+type patchedFile struct {
+	old     string
+	new     string
+	existed bool
+}
+
+// newPatchSet returns an empty patch of the files in workingDir
+func newPatchSet(workingDir string) *patchSet {
+	p := &patchSet{
+		workingDir: workingDir,
+		files:      make(map[string]*patchedFile),
+	}
+	// git apply takes paths from the top of the checkout, wherever it runs
+	if top, err := git(workingDir, "rev-parse", "--show-toplevel"); err == nil {
+		if resolved, err := filepath.EvalSymlinks(workingDir); err == nil {
+			if rel, err := filepath.Rel(top, resolved); err == nil && rel != "." {
+				p.prefix = filepath.ToSlash(rel) + "/"
+			}
+		}
+	}
+	return p
+}
+
+// startPatch sets up the patch of an --output=patch run. Copies of opts
+// made afterwards share it.
+func (o *Options) startPatch(workingDir string) {
+	if o.Output != OutputPatch || o.patch != nil {
+		return
+	}
+	o.patch = newPatchSet(workingDir)
+}
+
+// startPending sets up the --pending-patch record of the writes a dry-run
+// turn proposes. Unlike --output=patch it changes nothing the model sees.
+func (o *Options) startPending(workingDir string) {
+	if !o.PendingPatch || o.CanExecuteWrite() || o.patch != nil || o.pending != nil {
+		return
+	}
+	o.pending = newPatchSet(workingDir)
+}
+
+// recordPending adds a dry-run write to the --pending-patch record
+func (o *Options) recordPending(path, content string) {
+	if o.pending == nil {
+		return
+	}
+	if _, err := o.pending.write(path, content); err != nil {
+		slog.Warn("not in the pending patch", "path", path, "err", err)
+	}
+}
+
+// patchWriteFile records a write_file call in the patch
+func patchWriteFile(toolUse ContentBlock, path, content, claudeDir string,
+	opts *Options, conversationID string, startTime time.Time,
+) (ContentBlock, error) {
+	old, err := opts.patch.write(path, content)
+	if err != nil {
+		return logAndReturnError(toolUse.ID, claudeDir, "write_file",
+			toolUse.Input, err.Error(), conversationID, startTime)
+	}
+	if !opts.IsSilent() {
+		ToolHeader(path+" (patch)", false)
+		ShowDiff(old, content)
+	}
+	slog.Info("tool", "name", "write_file", "path", path, "patch", true)
+
+	logAuditEntry(claudeDir, "write_file", toolUse.Input, map[string]interface{}{
+		"patch": true,
+		"path":  path,
+		"size":  len(content),
+	}, true, conversationID, startTime, true)
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUse.ID,
+		Content: fmt.Sprintf("Recorded the change to %s in the patch; the file "+
+			"itself is unchanged, read_file shows the new content", path),
+	}, nil
+}
+
+// writePatch outputs the patch of opts, unless it is empty
+func writePatch(opts *Options, writeOutputFunc func(string, bool, string, []byte) error) error {
+	patch := opts.patch.String()
+	if patch == "" {
+		fmt.Fprintln(os.Stderr, "--output=patch: no changes")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "--output=patch: %d files changed\n", len(opts.patch.changed()))
+	return writeOutputFunc(opts.OutputFile, false, patch, nil)
+}
+
+// Patch returns the --output=patch diff of the turn, "" when there is none
+func (s *session) Patch() string {
+	if s == nil || s.opts.patch == nil {
+		return ""
+	}
+	return s.opts.patch.String()
+}
+
+// writePending saves the writes proposed in the dry-run turn timestamp as
+// claudeDir/pending_<timestamp>.patch, unless there are none. The patch is
+// for git apply, so it can be neither encrypted nor redacted: it isn't
+// saved when encryption is on or when it holds secrets the redactor would
+// replace.
+func writePending(opts *Options, claudeDir, timestamp string) error {
+	patch := opts.pending.String()
+	if patch == "" {
+		return nil
+	}
+	reason := ""
+	if storage.EncryptionEnabled() {
+		reason = "encryption is on and the patch would be plaintext"
+	} else if r := storage.Redactor(); r != nil && r.String(patch) != patch {
+		reason = "they contain secrets the redaction policy replaces"
+	}
+	if reason != "" {
+		fmt.Fprintf(os.Stderr, "Proposed changes to %d files not saved as a pending patch: %s\n",
+			len(opts.pending.changed()), reason)
+		return nil
+	}
+	path := filepath.Join(claudeDir, "pending_"+timestamp+".patch")
+	if err := storage.WriteFile(path, []byte(patch), 0o644); err != nil {
+		return fmt.Errorf("writing pending patch: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Proposed changes to %d files: %s\n"+
+		"(review, then apply with: git apply %s)\n",
+		len(opts.pending.changed()), path, path)
+	return nil
+}
+
+// printPatch writes a patch to outputFile, or stdout without one
+func printPatch(outputFile string, _ bool, patch string, _ []byte) error {
+	if outputFile == "" {
+		_, err := fmt.Print(patch)
+		return err
+	}
+	if err := storage.WriteFile(outputFile, []byte(patch), 0o644); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	return nil
+}
+
+// relPath returns path relative to the working directory, slash separated
+func (p *patchSet) relPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(p.workingDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("--output=patch only covers the working directory: %s", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// content returns the recorded content of path, if it was written
+func (p *patchSet) content(path string) (string, bool) {
+	rel, err := p.relPath(path)
+	if err != nil {
+		return "", false
+	}
+	f, ok := p.files[rel]
+	if !ok {
+		return "", false
+	}
+	return f.new, true
+}
+
+// write records content as the new content of path and returns what it
+// replaces
+func (p *patchSet) write(path, content string) (string, error) {
+	rel, err := p.relPath(path)
+	if err != nil {
+		return "", err
+	}
+	f, ok := p.files[rel]
+	if !ok {
+		f = &patchedFile{}
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			f.old, f.existed = string(data), true
+		case !os.IsNotExist(err):
+			return "", err
+		}
+		f.new = f.old
+		p.files[rel] = f
+	}
+	previous := f.new
+	f.new = content
+	return previous, nil
+}
+
+// changed returns the paths whose content differs from the tree in order
+func (p *patchSet) changed() []string {
+	var paths []string
+	for rel, f := range p.files {
+		if f.new != f.old || !f.existed {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// String returns the git style unified diff of all recorded changes
+func (p *patchSet) String() string {
+	var b strings.Builder
+	for _, rel := range p.changed() {
+		f := p.files[rel]
+		name := p.prefix + rel
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", name, name)
+		from := "a/" + name
+		if !f.existed {
+			b.WriteString("new file mode 100644\n")
+			from = "/dev/null"
+		}
+		ops := diff.Edits(diff.Lines(f.old), diff.Lines(f.new))
+		if !diff.HasChange(ops) {
+			continue // empty new file, the header says it all
+		}
+		fmt.Fprintf(&b, "--- %s\n+++ b/%s\n", from, name)
+		writeHunks(&b, ops)
+	}
+	return b.String()
+}
+
+// writeHunks writes the changes in ops as unified diff hunks
+func writeHunks(b *strings.Builder, ops []diff.Op) {
+	for _, h := range diff.Hunks(ops, patchContext) {
+		b.WriteString(h.Header() + "\n")
+		for _, op := range h.Ops {
+			b.WriteByte(op.Kind)
+			b.WriteString(op.Line)
+			if !strings.HasSuffix(op.Line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+}
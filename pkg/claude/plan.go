@@ -0,0 +1,128 @@
+package claude
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// plan.go - Plan first, then execute (--plan)
+//
+// The model is first asked, without tools, for a checklist of the tool
+// calls and edits it intends to make. The checklist is shown and, once
+// approved, fed back in as the prompt of a second turn that runs with the
+// normal tool permissions. Both turns are saved to the history.
+
+// planItem matches checklist and list items: "- [ ] x", "* x", "1. x", "2) x"
+var planItem = regexp.MustCompile(`^\s*(?:[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)(.+)$`)
+
+// planRequest wraps prompt in the instructions for the planning turn
+func planRequest(prompt string) string {
+	return "Before doing anything, write a plan for the task below. Do not call " +
+		"any tools and do not make changes yet. List every tool call and " +
+		"file edit you intend to make, in order, as a markdown checklist " +
+		"(one \"- [ ] \" item per line). Reply with the checklist only.\n\n" +
+		"Task:\n" + prompt
+}
+
+// executeRequest is the prompt that runs an approved plan
+func executeRequest(steps []string) string {
+	var b strings.Builder
+	b.WriteString("The plan is approved. Carry it out now:\n")
+	for _, step := range steps {
+		fmt.Fprintf(&b, "- [ ] %s\n", step)
+	}
+	return b.String()
+}
+
+// parsePlan extracts the steps of a plan. Text without list items is taken
+// line by line.
+func parsePlan(text string) []string {
+	var items, lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := planItem.FindStringSubmatch(line); m != nil {
+			items = append(items, strings.TrimSpace(m[1]))
+		} else if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(items) > 0 {
+		return items
+	}
+	return lines
+}
+
+// displayPlan prints the plan as a checklist
+func displayPlan(steps []string) {
+	ToolHeader("Plan", false)
+	for i, step := range steps {
+		fmt.Fprintf(os.Stderr, "  [ ] %d. %s\n", i+1, step)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// approvePlan asks on the terminal whether to run the plan
+func approvePlan(opts *Options) (bool, error) {
+	if opts.PlanApprove {
+		return true, nil
+	}
+	f, err := openPromptInput()
+	if err != nil {
+		return false, fmt.Errorf("approving a plan needs a terminal (or --plan-approve): %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(os.Stderr, "Run this plan? [y/N]: ")
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// RunPlan handles --plan: it asks for a plan of prompt, shows it and, when
+// approved, runs it with opts
+func RunPlan(opts *Options, claudeDir, apiURL, defaultSystemPrompt, prompt string,
+	writeOutputFunc func(string, bool, string, []byte) error,
+) error {
+	planOpts := *opts
+	planOpts.Tool = ToolNone
+	sess, err := InitSession(&planOpts, claudeDir, apiURL, defaultSystemPrompt)
+	if err != nil {
+		return err
+	}
+	result, err := ExecuteConversation(sess, planRequest(prompt))
+	if err != nil {
+		return fmt.Errorf("planning: %w", err)
+	}
+	// The plan joins the history; it is shown as a checklist instead of
+	// being written as output
+	noOutput := func(string, bool, string, []byte) error { return nil }
+	if err := FinalizeSession(sess, result, storage.SaveJSON, noOutput); err != nil {
+		return err
+	}
+
+	steps := parsePlan(result.assistantText)
+	if len(steps) == 0 {
+		return fmt.Errorf("planning: the model returned no plan")
+	}
+	displayPlan(steps)
+
+	ok, err := approvePlan(opts)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Plan not approved, nothing executed")
+		return nil
+	}
+	slog.Info("plan approved", "steps", len(steps))
+	return runStep(opts, claudeDir, apiURL, defaultSystemPrompt,
+		executeRequest(steps), writeOutputFunc)
+}
@@ -0,0 +1,70 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// lastUserText returns the text of the last message of a recorded request
+func lastUserText(req map[string]interface{}) string {
+	messages := req["messages"].([]interface{})
+	last := messages[len(messages)-1].(map[string]interface{})
+	var text []string
+	for _, block := range last["content"].([]interface{}) {
+		if t, ok := block.(map[string]interface{})["text"].(string); ok {
+			text = append(text, t)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+func TestRunPlan(t *testing.T) {
+	api := &messagesAPI{text: "Here is the plan:\n- [ ] read main.go\n2. write_file main.go with the fix\n"}
+	apiURL, claudeDir := serveAPI(t, api)
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Tool = claude.ToolWrite
+	opts.PlanApprove = true
+
+	var outputs int
+	err := claude.RunPlan(opts, claudeDir, apiURL, "system", "fix the bug",
+		func(string, bool, string, []byte) error {
+			outputs++
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("RunPlan: %v", err)
+	}
+	if len(api.requests) != 2 {
+		t.Fatalf("got %d API calls, want plan and execution", len(api.requests))
+	}
+
+	// Planning runs without tools
+	if _, ok := api.requests[0]["tools"]; ok {
+		t.Error("planning turn was offered tools")
+	}
+	if text := lastUserText(api.requests[0]); !strings.Contains(text, "fix the bug") ||
+		!strings.Contains(text, "checklist") {
+		t.Errorf("planning prompt = %q", text)
+	}
+
+	// The approved plan is fed back with the tools enabled
+	if _, ok := api.requests[1]["tools"]; !ok {
+		t.Error("execution turn has no tools")
+	}
+	want := "- [ ] read main.go\n- [ ] write_file main.go with the fix\n"
+	if text := lastUserText(api.requests[1]); !strings.Contains(text, want) {
+		t.Errorf("execution prompt = %q, want plan %q", text, want)
+	}
+
+	if outputs != 1 {
+		t.Errorf("output written %d times, want once for the execution turn", outputs)
+	}
+	if pairs, _ := storage.ListRequestResponsePairs(claudeDir); len(pairs) != 2 {
+		t.Errorf("got %d saved turns, want 2", len(pairs))
+	}
+}
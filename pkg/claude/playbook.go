@@ -0,0 +1,145 @@
+package claude
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// playbook.go - Multi-step scripted runs (--playbook)
+//
+// A playbook is a YAML (or JSON) list of prompts run one after the other
+// in the same conversation. Each step may override the model, tool
+// permissions and budget; the first failing step stops the run.
+
+// PlaybookSettings are the per-step overrides. Set at the top level of a
+// playbook they apply to every step. Pointers distinguish "unset" from
+// meaningful zeros, like storage.Profile.
+type PlaybookSettings struct {
+	Model         string   `yaml:"model,omitempty"`
+	Tool          *string  `yaml:"tool,omitempty"`
+	MaxCost       *float64 `yaml:"max_cost,omitempty"`
+	MaxIterations *int     `yaml:"max_iterations,omitempty"`
+	Verify        string   `yaml:"verify,omitempty"`
+	Prefill       string   `yaml:"prefill,omitempty"`
+}
+
+// PlaybookStep is one prompt of a playbook
+type PlaybookStep struct {
+	Name             string `yaml:"name,omitempty"`
+	Prompt           string `yaml:"prompt"`
+	PlaybookSettings `yaml:",inline"`
+}
+
+// Playbook is the contents of a --playbook file
+type Playbook struct {
+	PlaybookSettings `yaml:",inline"`
+	Steps            []PlaybookStep `yaml:"steps"`
+}
+
+// LoadPlaybook reads and validates a playbook. JSON files parse as YAML.
+func LoadPlaybook(path string) (*Playbook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read playbook: %w", err)
+	}
+	var pb Playbook
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&pb); err != nil {
+		return nil, fmt.Errorf("parse playbook %s: %w", path, err)
+	}
+
+	if len(pb.Steps) == 0 {
+		return nil, fmt.Errorf("playbook %s: no steps", path)
+	}
+	for i, step := range pb.Steps {
+		if strings.TrimSpace(step.Prompt) == "" {
+			return nil, fmt.Errorf("playbook %s: step %d has no prompt", path, i+1)
+		}
+	}
+	return &pb, nil
+}
+
+// apply overrides the options set in s
+func (s *PlaybookSettings) apply(opts *Options) {
+	if s.Model != "" {
+		opts.Model = s.Model
+	}
+	if s.Tool != nil {
+		opts.Tool = *s.Tool
+	}
+	if s.MaxCost != nil {
+		opts.MaxCost = *s.MaxCost
+	}
+	if s.MaxIterations != nil {
+		opts.MaxIterations = *s.MaxIterations
+	}
+	if s.Verify != "" {
+		opts.Verify = s.Verify
+	}
+	if s.Prefill != "" {
+		opts.Prefill = s.Prefill
+	}
+}
+
+// stepName labels step i for output and errors
+func (pb *Playbook) stepName(i int) string {
+	if name := pb.Steps[i].Name; name != "" {
+		return fmt.Sprintf("step %d/%d (%s)", i+1, len(pb.Steps), name)
+	}
+	return fmt.Sprintf("step %d/%d", i+1, len(pb.Steps))
+}
+
+// RunPlaybook runs the steps of pb in order, each as one conversation turn
+// with opts plus the playbook and step overrides. It stops at the first
+// failing step.
+func RunPlaybook(pb *Playbook, opts *Options, claudeDir, apiURL,
+	defaultSystemPrompt string,
+	writeOutputFunc func(string, bool, string, []byte) error,
+) error {
+	// With --output=patch the steps share one patch, written after the last
+	if workingDir, err := os.Getwd(); err == nil {
+		opts.startPatch(workingDir)
+	}
+	for i, step := range pb.Steps {
+		stepOpts := *opts
+		pb.PlaybookSettings.apply(&stepOpts)
+		step.PlaybookSettings.apply(&stepOpts)
+
+		name := pb.stepName(i)
+		ToolHeader(name, false)
+		slog.Info("playbook", "step", i+1, "name", step.Name,
+			"model", stepOpts.Model, "tool", stepOpts.Tool)
+
+		output := writeOutputFunc
+		if opts.patch != nil && i < len(pb.Steps)-1 {
+			output = func(string, bool, string, []byte) error { return nil }
+		}
+		if err := runStep(&stepOpts, claudeDir, apiURL, defaultSystemPrompt,
+			step.Prompt, output); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runStep runs a single playbook step
+func runStep(opts *Options, claudeDir, apiURL, defaultSystemPrompt,
+	prompt string, writeOutputFunc func(string, bool, string, []byte) error,
+) error {
+	sess, err := InitSession(opts, claudeDir, apiURL, defaultSystemPrompt)
+	if err != nil {
+		return err
+	}
+	result, err := ExecuteConversation(sess, prompt)
+	if err != nil {
+		return err
+	}
+	return FinalizeSession(sess, result, storage.SaveJSON, writeOutputFunc)
+}
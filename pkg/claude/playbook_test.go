@@ -0,0 +1,184 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// messagesAPI fakes the Claude Messages API: it answers with the model name
+// (or text) and records the requests
+type messagesAPI struct {
+	requests []map[string]interface{}
+	fail     bool
+	text     string
+}
+
+func (m *messagesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&req)
+	m.requests = append(m.requests, req)
+	if m.fail && len(m.requests) > 1 {
+		http.Error(w, `{"type":"error","error":{"type":"overloaded_error","message":"down"}}`,
+			http.StatusBadRequest)
+		return
+	}
+	text := "answer from " + req["model"].(string)
+	if m.text != "" {
+		text = m.text
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":        "message",
+		"role":        "assistant",
+		"model":       req["model"],
+		"stop_reason": "end_turn",
+		"content": []map[string]string{{
+			"type": "text", "text": text,
+		}},
+	})
+}
+
+func writePlaybook(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runPlaybook(t *testing.T, api *messagesAPI, playbook string) (string, []string, error) {
+	t.Helper()
+	pb, err := claude.LoadPlaybook(writePlaybook(t, playbook))
+	if err != nil {
+		t.Fatalf("LoadPlaybook: %v", err)
+	}
+
+	apiURL, claudeDir := serveAPI(t, api)
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	var answers []string
+	err = claude.RunPlaybook(pb, opts, claudeDir, apiURL, "system",
+		func(_ string, _ bool, text string, _ []byte) error {
+			answers = append(answers, text)
+			return nil
+		})
+	return claudeDir, answers, err
+}
+
+// serveAPI starts api and a working directory whose models cache knows the
+// models it is asked for
+func serveAPI(t *testing.T, api *messagesAPI) (apiURL, claudeDir string) {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	workDir := t.TempDir()
+	claudeDir = filepath.Join(workDir, ".claude")
+	t.Chdir(workDir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{
+			{Name: claude.DefaultModel, Provider: "claude"},
+			{Name: "claude-opus-4-20250514", Provider: "claude"},
+		},
+	})
+	return server.URL, claudeDir
+}
+
+func TestRunPlaybook(t *testing.T) {
+	api := &messagesAPI{}
+	claudeDir, answers, err := runPlaybook(t, api, `
+tool: none
+max_iterations: 3
+steps:
+  - name: plan
+    prompt: make a plan
+  - prompt: do it
+    model: claude-opus-4-20250514
+    tool: write
+`)
+	if err != nil {
+		t.Fatalf("RunPlaybook: %v", err)
+	}
+
+	want := []string{
+		"answer from " + claude.DefaultModel,
+		"answer from claude-opus-4-20250514",
+	}
+	if strings.Join(answers, "|") != strings.Join(want, "|") {
+		t.Errorf("answers = %q, want %q", answers, want)
+	}
+
+	// The second step continues the conversation of the first
+	if n := len(api.requests[1]["messages"].([]interface{})); n != 3 {
+		t.Errorf("second step sent %d messages, want 3", n)
+	}
+	// The playbook disables tools, the second step enables them again
+	_, tools0 := api.requests[0]["tools"]
+	_, tools1 := api.requests[1]["tools"]
+	if tools0 || !tools1 {
+		t.Errorf("tool overrides not applied per step: tools sent %v, %v", tools0, tools1)
+	}
+
+	pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+	if len(pairs) != 2 {
+		t.Errorf("got %d saved turns, want 2", len(pairs))
+	}
+}
+
+func TestRunPlaybookStopsOnFailure(t *testing.T) {
+	api := &messagesAPI{fail: true}
+	_, answers, err := runPlaybook(t, api, `
+steps:
+  - prompt: one
+  - name: second
+    prompt: two
+  - prompt: three
+`)
+	if err == nil || !strings.Contains(err.Error(), "step 2/3 (second)") {
+		t.Fatalf("RunPlaybook = %v, want step 2 failure", err)
+	}
+	if len(answers) != 1 || len(api.requests) != 2 {
+		t.Errorf("ran past the failing step: %d answers, %d requests",
+			len(answers), len(api.requests))
+	}
+}
+
+func TestLoadPlaybookErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		playbook string
+		want     string
+	}{
+		{"no steps", "model: x\n", "no steps"},
+		{"empty prompt", "steps:\n  - name: a\n", "no prompt"},
+		{"unknown field", "steps:\n  - prompt: a\n    budget: 1\n", "budget"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := claude.LoadPlaybook(writePlaybook(t, tt.playbook))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadPlaybook = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// JSON is accepted too
+	pb, err := claude.LoadPlaybook(writePlaybook(t, `{"steps": [{"prompt": "hi", "max_cost": 0.5}]}`))
+	if err != nil || len(pb.Steps) != 1 || *pb.Steps[0].MaxCost != 0.5 {
+		t.Errorf("JSON playbook: %+v, %v", pb, err)
+	}
+}
@@ -0,0 +1,219 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// plugin.go - External tool executables
+//
+// An external tool is any executable speaking JSON over stdio. Each
+// invocation writes one request object to stdin and reads one response
+// object from stdout; stderr is passed through for diagnostics.
+//
+//	{"method": "describe"}
+//	-> {"name": "...", "description": "...", "input_schema": {...}}
+//
+//	{"method": "execute", "id": "toolu_...", "input": {...}, "working_dir": "..."}
+//	-> {"content": "..."} or {"error": "..."}
+//
+// External tools can do anything, so they run only with --tool=command or
+// --tool=all, like bash_command. That includes describe: plugins aren't
+// even offered to the LLM in other runs.
+
+// pluginRequest is written to the plugin's stdin
+type pluginRequest struct {
+	Method     string                 `json:"method"` // describe or execute
+	ID         string                 `json:"id,omitempty"`
+	Input      map[string]interface{} `json:"input,omitempty"`
+	WorkingDir string                 `json:"working_dir,omitempty"`
+}
+
+// pluginResponse is read from the plugin's stdout
+type pluginResponse struct {
+	// describe
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema,omitempty"`
+
+	// execute
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ExternalTool is a ToolExecutor backed by an executable
+type ExternalTool struct {
+	command string
+	args    []string
+	schema  Tool
+}
+
+// NewExternalTool asks command for its schema and returns the tool
+func NewExternalTool(ctx context.Context, command string, args ...string) (*ExternalTool, error) {
+	t := &ExternalTool{command: command, args: args}
+	resp, err := t.call(ctx, "", pluginRequest{Method: "describe"})
+	if err != nil {
+		return nil, fmt.Errorf("describing %s: %w", command, err)
+	}
+	if resp.Name == "" {
+		return nil, fmt.Errorf("describing %s: no tool name", command)
+	}
+	if resp.InputSchema == nil {
+		resp.InputSchema = map[string]interface{}{"type": "object"}
+	}
+	t.schema = Tool{
+		Name:        resp.Name,
+		Description: resp.Description,
+		InputSchema: resp.InputSchema,
+	}
+	return t, nil
+}
+
+func (t *ExternalTool) Name() string { return t.schema.Name }
+func (t *ExternalTool) Schema() Tool { return t.schema }
+
+// Execute runs the plugin for one tool_use block
+func (t *ExternalTool) Execute(ctx context.Context, call ToolCall) (ContentBlock, error) {
+	startTime := time.Now()
+	name := t.schema.Name
+
+	if !call.Opts.CanExecuteCommand() {
+		msg := fmt.Sprintf("Dry-run: would run external tool %s\n"+
+			"Use --tool=command or --tool=all to execute", name)
+		if !call.Opts.IsSilent() {
+			ToolHeader(name, true)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n\n", msg)
+		logAuditEntry(call.ClaudeDir, name, call.Use.Input, map[string]interface{}{
+			"dry_run": true,
+		}, true, call.ConversationID, startTime, true)
+		return ContentBlock{
+			Type:      "tool_result",
+			ToolUseID: call.Use.ID,
+			Content:   msg,
+		}, nil
+	}
+
+	slog.Info("tool", "name", name, "plugin", t.command)
+
+	resp, err := t.call(ctx, call.WorkingDir, pluginRequest{
+		Method:     "execute",
+		ID:         call.Use.ID,
+		Input:      call.Use.Input,
+		WorkingDir: call.WorkingDir,
+	})
+	if err == nil && resp.Error != "" {
+		err = fmt.Errorf("%s", resp.Error)
+	}
+	if err != nil {
+		return logAndReturnError(call.Use.ID, call.ClaudeDir, name,
+			call.Use.Input, err.Error(), call.ConversationID, startTime)
+	}
+
+	logAuditEntry(call.ClaudeDir, name, call.Use.Input, map[string]interface{}{
+		"success": true,
+		"size":    len(resp.Content),
+	}, true, call.ConversationID, startTime, false)
+
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: call.Use.ID,
+		Content:   resp.Content,
+	}, nil
+}
+
+// call runs the plugin once with req on stdin
+func (t *ExternalTool) call(ctx context.Context, dir string, req pluginRequest) (*pluginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ToolPluginTimeout)
+	defer cancel()
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, t.command, t.args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timeout after %v", ToolPluginTimeout)
+		}
+		return nil, err
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &resp, nil
+}
+
+// pendingPlugins are the plugins of config.json not registered yet.
+// Describing a plugin runs it, so they are registered only once a run may
+// call them (see registerToolPlugins).
+var (
+	pendingMu      sync.Mutex
+	pendingPlugins []storage.ToolPlugin
+)
+
+// ConfigureToolPlugins reads the external tools listed under
+// "tool_plugins" in config.json. Relative commands are resolved against
+// the project directory (the parent of claudeDir). Nothing is run until
+// registerToolPlugins.
+func ConfigureToolPlugins(claudeDir string) error {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	plugins := make([]storage.ToolPlugin, 0, len(cfg.ToolPlugins))
+	for _, p := range cfg.ToolPlugins {
+		if strings.ContainsRune(p.Command, filepath.Separator) && !filepath.IsAbs(p.Command) {
+			p.Command = filepath.Join(filepath.Dir(claudeDir), p.Command)
+		}
+		plugins = append(plugins, p)
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pendingPlugins = plugins
+	return nil
+}
+
+// registerToolPlugins describes and registers the configured plugins when
+// opts may execute them: with --tool=command or --tool=all, never in
+// read-only mode. Other runs leave the plugin binaries alone.
+func registerToolPlugins(opts *Options) error {
+	if !opts.CanExecuteCommand() || storage.ReadOnly() {
+		return nil
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	for len(pendingPlugins) > 0 {
+		p := pendingPlugins[0]
+		pendingPlugins = pendingPlugins[1:]
+
+		t, err := NewExternalTool(context.Background(), p.Command, p.Args...)
+		if err != nil {
+			return fmt.Errorf("tool plugin: %w", err)
+		}
+		if err := RegisterTool(t); err != nil {
+			return fmt.Errorf("tool plugin %s: %w", p.Command, err)
+		}
+		slog.Info("registered tool plugin", "name", t.Name(), "command", p.Command)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package claude_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// TestHelperToolPlugin is not a real test: with CLAUDE_TEST_PLUGIN set the
+// test binary acts as an external tool named $CLAUDE_TEST_PLUGIN
+func TestHelperToolPlugin(t *testing.T) {
+	name := os.Getenv("CLAUDE_TEST_PLUGIN")
+	if name == "" {
+		return
+	}
+
+	var req map[string]interface{}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(2)
+	}
+	var resp map[string]interface{}
+	switch req["method"] {
+	case "describe":
+		resp = map[string]interface{}{
+			"name":        name,
+			"description": "Uppercase text",
+			"input_schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]string{"type": "string"},
+				},
+			},
+		}
+	case "execute":
+		input, _ := req["input"].(map[string]interface{})
+		text, _ := input["text"].(string)
+		if text == "" {
+			resp = map[string]interface{}{"error": "text is required"}
+		} else {
+			wd, _ := os.Getwd()
+			resp = map[string]interface{}{
+				"content": fmt.Sprintf("%s in %s", strings.ToUpper(text),
+					filepath.Base(wd)),
+			}
+		}
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+// helperPlugin returns a command line running TestHelperToolPlugin as tool
+func helperPlugin(t *testing.T, name string) (string, []string) {
+	t.Setenv("CLAUDE_TEST_PLUGIN", name)
+	return os.Args[0], []string{"-test.run=^TestHelperToolPlugin$"}
+}
+
+func TestExternalTool(t *testing.T) {
+	command, args := helperPlugin(t, "upper")
+	tool, err := claude.NewExternalTool(context.Background(), command, args...)
+	if err != nil {
+		t.Fatalf("NewExternalTool: %v", err)
+	}
+	if tool.Name() != "upper" || tool.Schema().Description != "Uppercase text" {
+		t.Errorf("schema = %+v", tool.Schema())
+	}
+
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+
+	tests := []struct {
+		name    string
+		tool    string
+		input   map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{"dry-run", claude.DefaultTool, map[string]interface{}{"text": "hi"}, "Dry-run", false},
+		{"execute", claude.ToolAll, map[string]interface{}{"text": "hi"},
+			"HI in " + filepath.Base(workDir), false},
+		{"tool error", claude.ToolAll, map[string]interface{}{}, "Error: text is required", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := claude.NewOptions()
+			opts.SetTool(tt.tool)
+			opts.SetVerbosity(claude.VerbositySilent)
+
+			result, err := tool.Execute(context.Background(), claude.ToolCall{
+				Use: claude.ContentBlock{
+					Type: "tool_use", ID: "toolu_1", Name: "upper", Input: tt.input,
+				},
+				WorkingDir:     workDir,
+				ClaudeDir:      claudeDir,
+				Opts:           opts,
+				ConversationID: "test",
+			})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !strings.HasPrefix(result.Content, tt.want) {
+				t.Errorf("content = %q, want prefix %q", result.Content, tt.want)
+			}
+			if result.ToolUseID != "toolu_1" {
+				t.Errorf("ToolUseID = %q", result.ToolUseID)
+			}
+		})
+	}
+}
+
+func TestExternalToolBadPlugin(t *testing.T) {
+	if _, err := claude.NewExternalTool(context.Background(), "false"); err == nil {
+		t.Error("expected error for failing plugin")
+	}
+	if _, err := claude.NewExternalTool(context.Background(),
+		filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing plugin")
+	}
+}
+
+// configurePlugins keeps plugin names unique in the global registry
+var configurePlugins int
+
+func TestConfigureToolPlugins(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+
+	configurePlugins++
+	name := fmt.Sprintf("test_plugin_upper_%d", configurePlugins)
+	command, args := helperPlugin(t, name)
+	cfg := &storage.Config{ToolPlugins: []storage.ToolPlugin{{
+		Command: command, Args: args,
+	}}}
+	if err := storage.SaveJSON(filepath.Join(claudeDir, "config.json"), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := claude.ConfigureToolPlugins(claudeDir); err != nil {
+		t.Fatalf("ConfigureToolPlugins: %v", err)
+	}
+	offered := func() bool {
+		for _, tool := range claude.GetTools(claude.NewOptions()) {
+			if tool.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	if offered() {
+		t.Fatal("plugin registered before a run may call it")
+	}
+
+	// Registered by the first run that may execute commands
+	opts := claude.NewOptions()
+	opts.Tool = claude.ToolAll
+	if _, err := claude.ExecuteTools(nil, t.TempDir(), claudeDir, opts, "test"); err != nil {
+		t.Fatalf("ExecuteTools: %v", err)
+	}
+	if !offered() {
+		t.Error("plugin tool not offered to the LLM")
+	}
+}
+
+func TestToolPluginsNotRun(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	marker := filepath.Join(t.TempDir(), "ran")
+	cfg := &storage.Config{ToolPlugins: []storage.ToolPlugin{{
+		Command: "sh", Args: []string{"-c", "touch " + marker},
+	}}}
+	if err := storage.SaveJSON(filepath.Join(claudeDir, "config.json"), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := claude.ConfigureToolPlugins(claudeDir); err != nil {
+		t.Fatalf("ConfigureToolPlugins: %v", err)
+	}
+	t.Cleanup(func() { claude.ConfigureToolPlugins(t.TempDir()) })
+
+	readOnly := claude.NewOptions()
+	readOnly.Tool = claude.ToolAll
+	for name, opts := range map[string]*claude.Options{
+		"dry-run":   claude.NewOptions(),
+		"read":      {Tool: claude.ToolRead},
+		"read-only": readOnly,
+	} {
+		storage.SetReadOnly(name == "read-only")
+		if _, err := claude.ExecuteTools(nil, t.TempDir(), claudeDir, opts, "test"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	storage.SetReadOnly(false)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("plugin ran: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package claude
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// LoadProfile returns the named profile from config.json
+func LoadProfile(claudeDir, name string) (*storage.Profile, error) {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(cfg.Profiles))
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown profile %q (no profiles in %s)",
+				name, filepath.Join(claudeDir, "config.json"))
+		}
+		return nil, fmt.Errorf("unknown profile %q (available: %s)",
+			name, strings.Join(names, ", "))
+	}
+
+	switch p.Provider {
+	case "", ProviderClaude, ProviderVertex, ProviderBedrock, ProviderOllama:
+	default:
+		return nil, fmt.Errorf("profile %q: unknown provider %q (want %s, %s, %s or %s)",
+			name, p.Provider, ProviderClaude, ProviderVertex, ProviderBedrock, ProviderOllama)
+	}
+	return &p, nil
+}
@@ -0,0 +1,57 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestLoadProfile(t *testing.T) {
+	claudeDir := t.TempDir()
+	config := `{
+  "model": "claude-sonnet-4-20250514",
+  "profiles": {
+    "local-only": {"model": "qwen2.5-coder:7b", "provider": "ollama", "max_cost": 0, "tool": ""},
+    "work": {"model": "claude-opus-4-20250514", "max_iterations": 30, "tool": "write"},
+    "broken": {"provider": "openai"}
+  }
+}`
+	if err := os.WriteFile(filepath.Join(claudeDir, "config.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := claude.LoadProfile(claudeDir, "local-only")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if p.Model != "qwen2.5-coder:7b" || p.Provider != claude.ProviderOllama {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+	// Explicit zero values must survive so they can override flag defaults
+	if p.MaxCost == nil || *p.MaxCost != 0 || p.Tool == nil || *p.Tool != "" {
+		t.Errorf("explicit zero values lost: %+v", p)
+	}
+	if p.MaxIterations != nil {
+		t.Errorf("unset max_iterations should be nil")
+	}
+
+	p, err = claude.LoadProfile(claudeDir, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxIterations == nil || *p.MaxIterations != 30 || *p.Tool != "write" {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	_, err = claude.LoadProfile(claudeDir, "missing")
+	if err == nil || !strings.Contains(err.Error(), "local-only") {
+		t.Errorf("expected error listing available profiles, got %v", err)
+	}
+
+	if _, err := claude.LoadProfile(claudeDir, "broken"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
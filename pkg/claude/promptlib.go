@@ -0,0 +1,153 @@
+package claude
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// promptlib.go - Prompt library (claude prompt save|list|run)
+//
+// Prompts used again and again are saved by name and sent with
+// `claude prompt run NAME`. A prompt may have parameters, {{name}}, and
+// defaults, {{name=value}}; run fills them in from NAME=VALUE arguments.
+// Storage is in pkg/storage/prompts.go.
+
+// Prompt subcommands
+const (
+	PromptSave = "save"
+	PromptList = "list"
+	PromptRun  = "run"
+)
+
+var (
+	// promptParam matches a {{name}} or {{name=default}} parameter
+	promptParam     = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:=([^}]*))?\}\}`)
+	promptParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// PromptParams returns the parameters of text in order of appearance
+func PromptParams(text string) []string {
+	var params []string
+	seen := make(map[string]bool)
+	for _, m := range promptParam.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			params = append(params, m[1])
+		}
+	}
+	return params
+}
+
+// ParsePromptArgs parses the NAME=VALUE arguments of claude prompt run
+func ParsePromptArgs(args []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || !promptParamName.MatchString(name) {
+			return nil, fmt.Errorf("invalid prompt argument %q (want NAME=VALUE)", arg)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// RenderPrompt fills in the parameters of text from values. Parameters
+// without a value or default, and values of no parameter, are errors.
+func RenderPrompt(text string, values map[string]string) (string, error) {
+	known := make(map[string]bool)
+	var missing []string
+	out := promptParam.ReplaceAllStringFunc(text, func(s string) string {
+		m := promptParam.FindStringSubmatch(s)
+		known[m[1]] = true
+		if value, ok := values[m[1]]; ok {
+			return value
+		}
+		if strings.Contains(s, "=") {
+			return strings.TrimSpace(m[2])
+		}
+		if !containsString(missing, m[1]) {
+			missing = append(missing, m[1])
+		}
+		return s
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing prompt parameters: %s (give them as NAME=VALUE)",
+			strings.Join(missing, ", "))
+	}
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("unknown prompt parameters: %s", strings.Join(unknown, ", "))
+	}
+	return out, nil
+}
+
+// PromptSaveCommand handles claude prompt save: saves text as prompt name
+// of the project
+func PromptSaveCommand(claudeDir, name, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("prompt %s is empty", name)
+	}
+	path, err := storage.SavePrompt(claudeDir, name, text)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved prompt %s to %s\n", name, path)
+	if params := PromptParams(text); len(params) > 0 {
+		fmt.Fprintf(os.Stderr, "Parameters: %s\n", strings.Join(params, ", "))
+	}
+	return nil
+}
+
+// PromptListCommand handles claude prompt list: one line per prompt with
+// its parameters and the start of its text
+func PromptListCommand(w io.Writer, claudeDir string) error {
+	prompts, err := storage.ListPrompts(claudeDir)
+	if err != nil {
+		return err
+	}
+	if len(prompts) == 0 {
+		fmt.Fprintf(os.Stderr, "No saved prompts (claude prompt save NAME)\n")
+		return nil
+	}
+	for _, p := range prompts {
+		line := p.Name
+		if p.Global {
+			line += " (global)"
+		}
+		if params := PromptParams(p.Text); len(params) > 0 {
+			line += " [" + strings.Join(params, " ") + "]"
+		}
+		fmt.Fprintf(w, "%-40s %s\n", line, turnTitle(p.Text))
+	}
+	return nil
+}
+
+// PromptRunText returns the text claude prompt run sends: prompt name with
+// its parameters filled in from args
+func PromptRunText(claudeDir, name string, args []string) (string, error) {
+	p, err := storage.LoadPrompt(claudeDir, name)
+	if err != nil {
+		return "", err
+	}
+	values, err := ParsePromptArgs(args)
+	if err != nil {
+		return "", err
+	}
+	text, err := RenderPrompt(p.Text, values)
+	if err != nil {
+		return "", fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return text, nil
+}
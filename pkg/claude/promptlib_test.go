@@ -0,0 +1,44 @@
+package claude_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	const text = "review {{file}} for {{ what = bugs }}, then {{file}} again"
+	if got := claude.PromptParams(text); !reflect.DeepEqual(got, []string{"file", "what"}) {
+		t.Errorf("PromptParams = %v", got)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"defaults", []string{"file=a.go"}, "review a.go for bugs, then a.go again", false},
+		{"all given", []string{"file=a.go", "what=races"}, "review a.go for races, then a.go again", false},
+		{"value with =", []string{"file=a=b"}, "review a=b for bugs, then a=b again", false},
+		{"missing", nil, "", true},
+		{"unknown", []string{"file=a.go", "typo=x"}, "", true},
+		{"not NAME=VALUE", []string{"file"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := claude.ParsePromptArgs(tt.args)
+			var got string
+			if err == nil {
+				got, err = claude.RenderPrompt(text, values)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rendered %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// rag.go - Retrieval-augmented prompts (--rag)
+//
+// --rag=K embeds the prompt, takes the K chunks of the claude index
+// closest to it and adds them to the user message ahead of the prompt, as
+// many as fit the token budget. Each chunk is followed by a footer naming
+// its file and lines so answers can point at their source, and the turn
+// metadata records which chunks were sent.
+
+// DefaultRAGTokens is the default --rag-tokens budget
+const DefaultRAGTokens = 4000
+
+// ragContext returns the context block for prompt and the sources it
+// holds, or nil when no chunk fits the budget
+func ragContext(ctx context.Context, claudeDir, prompt string, k, budget int) (*ContentBlock, []string, error) {
+	idx, err := storage.LoadEmbeddingIndex(claudeDir)
+	if err == nil && idx == nil {
+		err = fmt.Errorf("no index, run claude index")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("--rag: %w", err)
+	}
+	emb, err := NewEmbedder(EmbedOptions{
+		Provider: idx.Provider,
+		Model:    idx.Model,
+		URL:      idx.URL,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("--rag: %w", err)
+	}
+	vectors, err := emb.Embed(ctx, []string{prompt})
+	if err != nil {
+		return nil, nil, fmt.Errorf("--rag: embedding prompt: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Context retrieved from the project index of %s for the "+
+		"request below. It may be out of date: read a file for its current "+
+		"content before changing it.\n\n", idx.Updated.Format(time.DateTime))
+
+	var sources []string
+	tokens := estimateTextTokens(b.String())
+	for _, r := range SearchIndex(idx, vectors[0], k) {
+		source := fmt.Sprintf("%s:%d-%d", r.Chunk.Path, r.Chunk.StartLine,
+			r.Chunk.EndLine)
+		section := fmt.Sprintf("```\n%s\n```\n-- source: %s (similarity %.2f)\n\n",
+			r.Chunk.Text, source, r.Score)
+		// A chunk over the budget is skipped, a smaller one may still fit
+		if n := estimateTextTokens(section); tokens+n <= budget {
+			b.WriteString(section)
+			sources = append(sources, source)
+			tokens += n
+		}
+	}
+	if len(sources) == 0 {
+		slog.Warn("--rag: no chunk fits the token budget", "budget", budget)
+		return nil, nil, nil
+	}
+
+	slog.Info("rag context", "chunks", len(sources), "tokens", tokens,
+		"sources", strings.Join(sources, ", "))
+	return &ContentBlock{Type: "text", Text: b.String()}, sources, nil
+}
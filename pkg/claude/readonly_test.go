@@ -0,0 +1,118 @@
+package claude_test
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// treeState returns the size and modification time of every file under dir
+func treeState(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	state := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		state[path] = fmt.Sprintf("%s %d %v", info.Mode(), info.Size(), info.ModTime())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestReadOnlyTurn(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{"main.go": "package main\n"})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+	before := treeState(t, dir)
+
+	storage.SetReadOnly(true)
+	t.Cleanup(func() { storage.SetReadOnly(false) })
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Tool = claude.ToolRead
+	mock := &scriptedLLM{responses: []*llm.Response{
+		toolUseResponse("toolu_read", "read_file", map[string]interface{}{"path": "main.go"}),
+		textResponse("a Go program", "end_turn"),
+	}}
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.SetLLM(mock)
+	result, err := claude.ExecuteConversation(sess, "what is this?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(string, bool, string, []byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if last := mock.requests[len(mock.requests)-1]; !strings.Contains(
+		last.Messages[len(last.Messages)-1].Content[0].Content, "package main") {
+		t.Errorf("the model didn't get the file: %+v", last.Messages[len(last.Messages)-1])
+	}
+	if after := treeState(t, dir); !reflect.DeepEqual(before, after) {
+		t.Errorf("read-only turn changed the tree:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestNoSaveTurn(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{"a.txt": "old notes\n"})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+	before := treeState(t, claudeDir)
+
+	storage.SetEphemeral(true)
+	t.Cleanup(func() { storage.SetEphemeral(false) })
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Tool = claude.ToolWrite
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_write", "secret notes\n"),
+		textResponse("written", "end_turn"),
+	}}
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.SetLLM(mock)
+	result, err := claude.ExecuteConversation(sess, "write the notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := claude.FinalizeSession(sess, result, storage.SaveJSON,
+		func(string, bool, string, []byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tools still write, the conversation leaves nothing behind
+	if data, err := os.ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(data) != "secret notes\n" {
+		t.Errorf("a.txt = %q, %v", data, err)
+	}
+	if after := treeState(t, claudeDir); !reflect.DeepEqual(before, after) {
+		t.Errorf("--no-save turn changed %s:\nbefore %v\nafter  %v", claudeDir, before, after)
+	}
+}
@@ -0,0 +1,45 @@
+package claude
+
+import (
+	"github.com/marcopeereboom/go-claude/pkg/redact"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// ConfigureRedaction loads .claude/policy.json and installs the secret
+// redactor used for outgoing messages and saved conversation files.
+// Redaction is on by default; policy can disable it or add patterns.
+func ConfigureRedaction(claudeDir string) error {
+	policy, err := storage.LoadPolicy(claudeDir)
+	if err != nil {
+		return err
+	}
+	if policy.Redaction.Disabled {
+		storage.SetRedactor(nil)
+		return nil
+	}
+	r, err := redact.New(policy.Redaction.Patterns)
+	if err != nil {
+		return err
+	}
+	storage.SetRedactor(r)
+	return nil
+}
+
+// redactMessages scrubs secrets from text and tool results in place so
+// they are never sent to the LLM
+func redactMessages(messages []MessageContent) {
+	r := storage.Redactor()
+	if r == nil {
+		return
+	}
+	for i := range messages {
+		redactBlocks(r, messages[i].Content)
+	}
+}
+
+func redactBlocks(r *redact.Redactor, blocks []ContentBlock) {
+	for i := range blocks {
+		blocks[i].Text = r.String(blocks[i].Text)
+		blocks[i].Content = r.String(blocks[i].Content)
+	}
+}
@@ -0,0 +1,243 @@
+package claude_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// saveReplayFixture writes a response with a write_file and a bash_command
+func saveReplayFixture(t *testing.T, claudeDir, target string) {
+	t.Helper()
+	resp := []storage.APIResponse{{
+		Content: []storage.ContentBlock{
+			{
+				Type: "tool_use",
+				ID:   "toolu_write",
+				Name: "write_file",
+				Input: map[string]interface{}{
+					"path":    target,
+					"content": "replayed\n",
+				},
+			},
+			{
+				Type: "tool_use",
+				ID:   "toolu_bash",
+				Name: "bash_command",
+				Input: map[string]interface{}{
+					"command": "echo hi > bash.txt",
+					"reason":  "test",
+				},
+			},
+		},
+		StopReason: "tool_use",
+	}}
+	body, _ := json.Marshal(resp)
+	if err := storage.SaveRequest(claudeDir, "20260105_120000", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveResponse(claudeDir, "20260105_120000", body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		only        []string
+		ids         []string
+		expectWrite bool
+		expectBash  bool
+	}{
+		{"everything", nil, nil, true, true},
+		{"only write_file", []string{"write_file"}, nil, true, false},
+		{"only bash_command", []string{"bash_command"}, nil, false, true},
+		{"by tool id", nil, []string{"toolu_bash"}, false, true},
+		{"filters combine", []string{"write_file"}, []string{"toolu_bash"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			claudeDir := filepath.Join(workDir, ".claude")
+			os.MkdirAll(claudeDir, 0o755)
+			t.Chdir(workDir)
+
+			target := filepath.Join(workDir, "out.txt")
+			saveReplayFixture(t, claudeDir, target)
+
+			opts := claude.NewOptions()
+			opts.SetTool("all")
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Replay = ""
+			opts.ReplayOnly = tt.only
+			opts.ReplayToolIDs = tt.ids
+
+			if err := claude.ReplayResponse(claudeDir, opts); err != nil {
+				t.Fatalf("ReplayResponse failed: %v", err)
+			}
+
+			_, err := os.Stat(target)
+			if gotWrite := err == nil; gotWrite != tt.expectWrite {
+				t.Errorf("write_file executed = %v, want %v", gotWrite, tt.expectWrite)
+			}
+			_, err = os.Stat(filepath.Join(workDir, "bash.txt"))
+			if gotBash := err == nil; gotBash != tt.expectBash {
+				t.Errorf("bash_command executed = %v, want %v", gotBash, tt.expectBash)
+			}
+		})
+	}
+}
+
+func TestReplayInteractive(t *testing.T) {
+	tests := []struct {
+		name        string
+		answers     string
+		expectWrite bool
+		expectBash  bool
+	}{
+		{"yes to both", "y\ny\n", true, true},
+		{"no, then yes", "n\nyes\n", false, true},
+		{"all", "a\n", true, true},
+		{"unknown answers ask again", "maybe\ny\nn\n", true, false},
+		{"quit runs what was approved", "y\nq\n", true, false},
+		{"end of input quits", "y\n", true, false},
+		{"quit first", "q\n", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			claudeDir := filepath.Join(workDir, ".claude")
+			os.MkdirAll(claudeDir, 0o755)
+			t.Chdir(workDir)
+
+			target := filepath.Join(workDir, "out.txt")
+			saveReplayFixture(t, claudeDir, target)
+			claude.SetPromptInput(func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(tt.answers)), nil
+			})
+			t.Cleanup(func() { claude.SetPromptInput(nil) })
+
+			opts := claude.NewOptions()
+			opts.SetTool("all")
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Replay = ""
+			opts.ReplayInteractive = true
+
+			if err := claude.ReplayResponse(claudeDir, opts); err != nil {
+				t.Fatalf("ReplayResponse failed: %v", err)
+			}
+
+			_, err := os.Stat(target)
+			if gotWrite := err == nil; gotWrite != tt.expectWrite {
+				t.Errorf("write_file executed = %v, want %v", gotWrite, tt.expectWrite)
+			}
+			_, err = os.Stat(filepath.Join(workDir, "bash.txt"))
+			if gotBash := err == nil; gotBash != tt.expectBash {
+				t.Errorf("bash_command executed = %v, want %v", gotBash, tt.expectBash)
+			}
+		})
+	}
+}
+
+func TestReplayChangedFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    string // content when the turn ran, "" for none
+		now       string // content at replay, "" for none
+		tool      string
+		force     bool
+		wantErr   bool
+		wantAfter string
+	}{
+		{"unchanged", "original\n", "original\n", claude.ToolWrite, false, false, "replayed\n"},
+		{"new file", "", "", claude.ToolWrite, false, false, "replayed\n"},
+		{"changed", "original\n", "edited\n", claude.ToolWrite, false, true, "edited\n"},
+		{"created since", "", "edited\n", claude.ToolWrite, false, true, "edited\n"},
+		{"force", "original\n", "edited\n", claude.ToolWrite, true, false, "replayed\n"},
+		{"dry-run warns", "original\n", "edited\n", claude.DefaultTool, false, false, "edited\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			claudeDir := filepath.Join(workDir, ".claude")
+			os.MkdirAll(claudeDir, 0o755)
+			t.Chdir(workDir)
+
+			target := filepath.Join(workDir, "out.txt")
+			saveReplayFixture(t, claudeDir, target)
+			hash := ""
+			if tt.before != "" {
+				sum := sha256.Sum256([]byte(tt.before))
+				hash = hex.EncodeToString(sum[:])
+			}
+			err := storage.SaveTurnMeta(claudeDir, "20260105_120000", &storage.TurnMeta{
+				Model:       claude.DefaultModel,
+				WriteHashes: map[string]string{"toolu_write": hash},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.now != "" {
+				os.WriteFile(target, []byte(tt.now), 0o644)
+			}
+
+			opts := claude.NewOptions()
+			opts.SetTool(tt.tool)
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Replay = ""
+			opts.ReplayOnly = []string{"write_file"}
+			opts.ReplayForce = tt.force
+
+			err = claude.ReplayResponse(claudeDir, opts)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("ReplayResponse error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), target) {
+				t.Errorf("error doesn't name the file: %v", err)
+			}
+			got, _ := os.ReadFile(target)
+			if string(got) != tt.wantAfter {
+				t.Errorf("out.txt = %q, want %q", got, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestWriteHashesRecorded(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent) // dry-run still records
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_1", "first"),
+		writeResponse("toolu_2", "second"),
+		textResponse("done", "end_turn"),
+	}}
+	_, claudeDir, err := runScripted(t, opts, mock, "write a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil || len(pairs) != 1 {
+		t.Fatalf("pairs = %v, %v", pairs, err)
+	}
+	meta, err := storage.LoadTurnMeta(claudeDir, pairs[0])
+	if err != nil || meta == nil {
+		t.Fatalf("meta = %v, %v", meta, err)
+	}
+	want := map[string]string{"toolu_1": "", "toolu_2": ""}
+	if !reflect.DeepEqual(meta.WriteHashes, want) {
+		t.Errorf("write hashes = %v, want %v", meta.WriteHashes, want)
+	}
+}
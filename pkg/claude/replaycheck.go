@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// replaycheck.go - Replay safety
+//
+// A turn records the hash of every file a write_file call is about to
+// write, as it was before the call, in its metadata. --replay compares the
+// files with what the model saw before writing anything: when one has
+// changed since, the saved content would overwrite work done in between,
+// so a replay that writes stops unless --force is given. Dry-run and
+// --output=patch replays only warn.
+
+// startWriteHashes starts recording the files the writes of a turn
+// change. Copies of o made afterwards share the record.
+func (o *Options) startWriteHashes() {
+	o.writeHashes = make(map[string]string)
+}
+
+// recordPreWrite records the content of a file before the write_file call
+// id changes it. A file that didn't exist is recorded as "".
+func (o *Options) recordPreWrite(id string, old []byte, existed bool) {
+	if o.writeHashes == nil {
+		return
+	}
+	o.writeHashes[id] = ""
+	if existed {
+		o.writeHashes[id] = contentHash(old)
+	}
+}
+
+// contentHash returns the hex SHA-256 of data
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileHash returns the hash of the file at path like recordPreWrite: ""
+// when it doesn't exist
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return contentHash(data), nil
+}
+
+// changedSinceTurn returns the files the selected write_file calls of
+// responses would write that differ from what the original turn saw
+// before writing them. Only the first selected write of a file counts:
+// the later ones see what the replay wrote.
+func changedSinceTurn(responses []storage.APIResponse, hashes map[string]string,
+	sel *replaySelector,
+) ([]string, error) {
+	seen := make(map[string]bool)
+	var changed []string
+	for _, resp := range responses {
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" || block.Name != "write_file" || !sel.match(block) {
+				continue
+			}
+			path, _ := block.Input["path"].(string)
+			if path == "" || seen[path] {
+				continue
+			}
+			seen[path] = true
+			want, ok := hashes[block.ID]
+			if !ok {
+				continue // written by a turn saved before hashes were recorded
+			}
+			got, err := fileHash(path)
+			if err != nil {
+				return nil, fmt.Errorf("checking %s: %w", path, err)
+			}
+			if got != want {
+				changed = append(changed, path)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// checkReplayState refuses a replay that would overwrite files changed
+// since the original turn, unless opts.ReplayForce is set
+func checkReplayState(responses []storage.APIResponse, meta *storage.TurnMeta,
+	sel *replaySelector, opts *Options,
+) error {
+	if meta == nil || len(meta.WriteHashes) == 0 {
+		return nil
+	}
+	changed, err := changedSinceTurn(responses, meta.WriteHashes, sel)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+	for _, path := range changed {
+		slog.Warn("file changed since the original run", "path", path)
+	}
+	if !opts.CanExecuteWrite() || opts.patch != nil || opts.ReplayForce {
+		return nil
+	}
+	return fmt.Errorf("%d files changed since the original run: %s "+
+		"(use --force to overwrite them)", len(changed), strings.Join(changed, ", "))
+}
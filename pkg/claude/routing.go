@@ -0,0 +1,45 @@
+package claude
+
+import (
+	"log/slog"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/router"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// routeModel runs the router over prompt. model is the local candidate;
+// a Claude model leaves Ollama out. The Claude side is the fallback model.
+func routeModel(opts *Options, config *storage.Config, model, prompt string, tools bool) (*router.Decision, error) {
+	var ollama llm.LLM
+	if !isClaudeModel(model, opts.Provider) && !isFakeModel(model) {
+		ollama = llm.NewOllama(model, opts.OllamaURL)
+	}
+	claudeModel := opts.FallbackModel
+	if claudeModel == "" {
+		claudeModel = DefaultModel
+	}
+	return router.NewRouter(ollama, nil, config, router.Options{
+		PreferLocal:    opts.PreferLocal,
+		AllowFallback:  opts.AllowFallback,
+		MaxClaudeRatio: opts.MaxClaudeRatio,
+		OllamaModel:    model,
+		ClaudeModel:    claudeModel,
+		RequireTools:   tools,
+	}).Route(prompt)
+}
+
+// explainRouting puts the routing decision of the turn's prompt in the run
+// summary and shows it, for --explain-routing
+func (s *session) explainRouting(prompt string) {
+	decision, err := routeModel(s.opts, s.config, s.model, prompt, len(GetTools(s.opts)) > 0)
+	if err != nil {
+		slog.Warn("explaining routing", "err", err)
+		return
+	}
+	s.summary.Routing = decision
+	if !s.opts.IsSilent() {
+		display.Info("%s", decision.Explain())
+	}
+}
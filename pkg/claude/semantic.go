@@ -0,0 +1,353 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// semantic.go - claude index and the semantic_search tool
+//
+// claude index embeds the project files, a chunk of lines at a time, into
+// .claude/index. Files whose content didn't change keep their vectors, so
+// re-running it only embeds what was edited. semantic_search embeds the
+// query with the same model and returns the closest chunks.
+
+const (
+	EmbedProviderOllama = "ollama"
+	EmbedProviderOpenAI = "openai" // OpenAI-compatible: OpenAI, Voyage
+
+	DefaultEmbedModel = "nomic-embed-text"
+
+	// IndexChunkLines is the number of lines embedded together
+	IndexChunkLines = 60
+	// MaxIndexFileSize skips larger files (generated code, data)
+	MaxIndexFileSize = 256 << 10
+	// MaxSearchResults caps the limit of semantic_search
+	MaxSearchResults = 20
+
+	embedBatch = 32 // chunks per Embed call
+)
+
+// EmbedOptions selects the embedder of claude index
+type EmbedOptions struct {
+	Provider string // EmbedProviderOllama (default) or EmbedProviderOpenAI
+	Model    string
+	URL      string // Ollama or OpenAI-compatible base URL
+}
+
+// NewEmbedder returns the embedder of eo. The OpenAI-compatible API key is
+// read from EMBEDDINGS_API_KEY.
+func NewEmbedder(eo EmbedOptions) (llm.Embedder, error) {
+	switch eo.Provider {
+	case "", EmbedProviderOllama:
+		return llm.NewOllama(eo.Model, eo.URL), nil
+	case EmbedProviderOpenAI:
+		key := os.Getenv("EMBEDDINGS_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("EMBEDDINGS_API_KEY not set")
+		}
+		return llm.NewOpenAIEmbeddings(eo.Model, eo.URL, key), nil
+	}
+	return nil, fmt.Errorf("unknown embeddings provider %q (use %s or %s)",
+		eo.Provider, EmbedProviderOllama, EmbedProviderOpenAI)
+}
+
+// IndexStats reports what BuildIndex did
+type IndexStats struct {
+	Files    int // indexed files
+	Embedded int // files embedded this run
+	Reused   int // unchanged files that kept their vectors
+	Skipped  int // binary or too large
+	Chunks   int
+}
+
+// BuildIndex embeds the files of dir and saves the index in claudeDir.
+// Chunk text is redacted before it is embedded or saved.
+func BuildIndex(ctx context.Context, dir, claudeDir string, emb llm.Embedder,
+	eo EmbedOptions,
+) (*IndexStats, error) {
+	if eo.Provider == "" {
+		eo.Provider = EmbedProviderOllama
+	}
+	old, err := storage.LoadEmbeddingIndex(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	if old != nil && (old.Provider != eo.Provider || old.Model != eo.Model) {
+		old = nil // vectors of another model can't be mixed in
+	}
+	reuse := make(map[string][]storage.EmbeddedChunk)
+	if old != nil {
+		for _, c := range old.Chunks {
+			reuse[c.Path] = append(reuse[c.Path], c)
+		}
+	}
+
+	files, err := projectFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &storage.EmbeddingIndex{
+		Provider: eo.Provider,
+		Model:    eo.Model,
+		URL:      eo.URL,
+		Files:    make(map[string]string),
+	}
+	if old != nil {
+		idx.Dimensions = old.Dimensions
+	}
+	stats := &IndexStats{}
+	var pending []storage.EmbeddedChunk
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			continue // deleted since listing, or a directory submodule
+		}
+		if len(data) > MaxIndexFileSize || !utf8.Valid(data) ||
+			bytes.IndexByte(data, 0) >= 0 {
+			stats.Skipped++
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		idx.Files[f] = hash
+		stats.Files++
+
+		if old != nil && old.Files[f] == hash {
+			idx.Chunks = append(idx.Chunks, reuse[f]...)
+			stats.Reused++
+			continue
+		}
+		pending = append(pending, chunkFile(f, string(data))...)
+		stats.Embedded++
+	}
+
+	for start := 0; start < len(pending); start += embedBatch {
+		batch := pending[start:min(start+embedBatch, len(pending))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = fmt.Sprintf("%s\n%s", c.Path, c.Text)
+		}
+		vectors, err := emb.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("embedding %s: %w", batch[0].Path, err)
+		}
+		for i := range batch {
+			if idx.Dimensions == 0 {
+				idx.Dimensions = len(vectors[i])
+			}
+			if len(vectors[i]) != idx.Dimensions {
+				return nil, fmt.Errorf("embedding %s: %d dimensions, want %d",
+					batch[i].Path, len(vectors[i]), idx.Dimensions)
+			}
+			batch[i].Vector = vectors[i]
+		}
+		idx.Chunks = append(idx.Chunks, batch...)
+		slog.Debug("embedded", "chunks", start+len(batch), "of", len(pending))
+	}
+
+	sort.SliceStable(idx.Chunks, func(i, j int) bool {
+		if idx.Chunks[i].Path != idx.Chunks[j].Path {
+			return idx.Chunks[i].Path < idx.Chunks[j].Path
+		}
+		return idx.Chunks[i].StartLine < idx.Chunks[j].StartLine
+	})
+	stats.Chunks = len(idx.Chunks)
+	idx.Updated = time.Now().UTC()
+	if err := storage.SaveEmbeddingIndex(claudeDir, idx); err != nil {
+		return nil, fmt.Errorf("saving index: %w", err)
+	}
+	return stats, nil
+}
+
+// chunkFile splits text into chunks of IndexChunkLines lines, dropping
+// blank ones
+func chunkFile(path, text string) []storage.EmbeddedChunk {
+	r := storage.Redactor()
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var chunks []storage.EmbeddedChunk
+	for start := 0; start < len(lines); start += IndexChunkLines {
+		end := min(start+IndexChunkLines, len(lines))
+		chunk := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		if r != nil {
+			chunk = r.String(chunk)
+		}
+		chunks = append(chunks, storage.EmbeddedChunk{
+			Path:      path,
+			StartLine: start + 1,
+			EndLine:   end,
+			Text:      chunk,
+		})
+	}
+	return chunks
+}
+
+// SearchResult is a chunk matching a query
+type SearchResult struct {
+	Chunk *storage.EmbeddedChunk
+	Score float64 // cosine similarity
+}
+
+// SearchIndex returns the limit chunks of idx closest to query, best first
+func SearchIndex(idx *storage.EmbeddingIndex, query []float32, limit int) []SearchResult {
+	results := make([]SearchResult, 0, len(idx.Chunks))
+	for i := range idx.Chunks {
+		c := &idx.Chunks[i]
+		if len(c.Vector) != len(query) {
+			continue
+		}
+		results = append(results, SearchResult{Chunk: c, Score: cosine(query, c.Vector)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// IndexCommand handles claude index: embeds the files of workingDir
+func IndexCommand(workingDir, claudeDir string, eo EmbedOptions) error {
+	emb, err := NewEmbedder(eo)
+	if err != nil {
+		return err
+	}
+	if err := storage.MkdirAll(claudeDir, 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", claudeDir, err)
+	}
+	start := time.Now()
+	stats, err := BuildIndex(context.Background(), workingDir, claudeDir, emb, eo)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Indexed %d files in %d chunks with %s (%d embedded, %d unchanged, %d skipped) in %s\n",
+		stats.Files, stats.Chunks, eo.Model, stats.Embedded, stats.Reused,
+		stats.Skipped, time.Since(start).Round(100*time.Millisecond))
+	return nil
+}
+
+// semanticSearchTool finds project files by meaning (semantic_search)
+type semanticSearchTool struct{}
+
+func (semanticSearchTool) Name() string { return "semantic_search" }
+
+func (semanticSearchTool) Schema() Tool {
+	return Tool{
+		Name: "semantic_search",
+		Description: "Find the parts of project files most related in meaning " +
+			"to a natural-language query, e.g. \"where are retries handled\". " +
+			"Returns file line ranges with their text as of the last index; " +
+			"use read_file for the current content.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "What to look for",
+				},
+				"limit": map[string]string{
+					"type": "integer",
+					"description": fmt.Sprintf("number of results (default 5, max %d)",
+						MaxSearchResults),
+				},
+			},
+			"required": []string{"query"},
+		},
+	}
+}
+
+func (semanticSearchTool) Available(opts *Options) bool {
+	return opts.SemanticSearch
+}
+
+func (semanticSearchTool) Execute(ctx context.Context, call ToolCall) (ContentBlock, error) {
+	startTime := time.Now()
+	toolUse := call.Use
+
+	query, ok := toolUse.Input["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "semantic_search",
+			toolUse.Input, "query must be a non-empty string",
+			call.ConversationID, startTime)
+	}
+	limit := min(intInput(toolUse.Input, "limit", 5), MaxSearchResults)
+	if limit <= 0 {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "semantic_search",
+			toolUse.Input, "limit must be > 0", call.ConversationID, startTime)
+	}
+
+	idx, err := storage.LoadEmbeddingIndex(call.ClaudeDir)
+	if err == nil && idx == nil {
+		err = fmt.Errorf("no index, run claude index")
+	}
+	if err != nil {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "semantic_search",
+			toolUse.Input, err.Error(), call.ConversationID, startTime)
+	}
+	emb, err := NewEmbedder(EmbedOptions{
+		Provider: idx.Provider,
+		Model:    idx.Model,
+		URL:      idx.URL,
+	})
+	if err != nil {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "semantic_search",
+			toolUse.Input, err.Error(), call.ConversationID, startTime)
+	}
+	vectors, err := emb.Embed(ctx, []string{query})
+	if err != nil {
+		return logAndReturnError(toolUse.ID, call.ClaudeDir, "semantic_search",
+			toolUse.Input, fmt.Sprintf("embedding query: %v", err),
+			call.ConversationID, startTime)
+	}
+	results := SearchIndex(idx, vectors[0], limit)
+
+	logAuditEntry(call.ClaudeDir, "semantic_search", toolUse.Input, map[string]interface{}{
+		"success": true,
+		"matches": len(results),
+	}, true, call.ConversationID, startTime, false)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d matches (index of %s):\n", len(results),
+		idx.Updated.Format(time.DateTime))
+	for i, r := range results {
+		fmt.Fprintf(&b, "\n[%d] %s:%d-%d (score %.2f)\n%s\n", i+1, r.Chunk.Path,
+			r.Chunk.StartLine, r.Chunk.EndLine, r.Score, r.Chunk.Text)
+	}
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUse.ID,
+		Content:   b.String(),
+	}, nil
+}
@@ -0,0 +1,268 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// serveEmbeddings fakes Ollama /api/embeddings with one dimension per
+// keyword, counting the calls
+func serveEmbeddings(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	keywords := []string{"retry", "parse", "render"}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Prompt string `json:"prompt"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			calls.Add(1)
+			vector := make([]float32, len(keywords))
+			for i, k := range keywords {
+				vector[i] = float32(strings.Count(req.Prompt, k))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"embedding": vector})
+		}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeProject(t *testing.T, files map[string]string) (dir, claudeDir string) {
+	t.Helper()
+	dir = t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claudeDir = filepath.Join(dir, ".claude")
+	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, claudeDir
+}
+
+func TestBuildIndex(t *testing.T) {
+	var calls atomic.Int32
+	server := serveEmbeddings(t, &calls)
+	dir, claudeDir := writeProject(t, map[string]string{
+		"net/retry.go": "package net\n\n// retry with backoff\nfunc retry() {}\n",
+		"parse.go":     "package main\n\n// parse the config\nfunc parse() {}\n",
+		"logo.png":     "\x89PNG\x00\x00",
+	})
+	eo := claude.EmbedOptions{Model: "nomic-embed-text", URL: server.URL}
+	emb, err := claude.NewEmbedder(eo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := claude.BuildIndex(t.Context(), dir, claudeDir, emb, eo)
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	if stats.Files != 2 || stats.Embedded != 2 || stats.Skipped != 1 {
+		t.Errorf("stats = %+v, want 2 files embedded and the binary skipped", stats)
+	}
+	idx, err := storage.LoadEmbeddingIndex(claudeDir)
+	if err != nil || idx == nil {
+		t.Fatalf("LoadEmbeddingIndex = %v, %v", idx, err)
+	}
+	if idx.Provider != claude.EmbedProviderOllama || idx.Dimensions != 3 ||
+		len(idx.Chunks) != 2 {
+		t.Errorf("index = %s/%d dims/%d chunks", idx.Provider, idx.Dimensions,
+			len(idx.Chunks))
+	}
+	if c := idx.Chunks[0]; c.Path != "net/retry.go" || c.StartLine != 1 || c.EndLine != 4 {
+		t.Errorf("first chunk = %s:%d-%d", c.Path, c.StartLine, c.EndLine)
+	}
+
+	// Unchanged files keep their vectors
+	calls.Store(0)
+	if stats, err = claude.BuildIndex(t.Context(), dir, claudeDir, emb, eo); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Reused != 2 || calls.Load() != 0 {
+		t.Errorf("rerun: stats = %+v with %d calls, want everything reused",
+			stats, calls.Load())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "parse.go"),
+		[]byte("package main\n\nfunc parse() { render() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = claude.BuildIndex(t.Context(), dir, claudeDir, emb, eo); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Embedded != 1 || stats.Reused != 1 || calls.Load() != 1 {
+		t.Errorf("after edit: stats = %+v with %d calls, want only parse.go embedded",
+			stats, calls.Load())
+	}
+}
+
+func TestSemanticSearchTool(t *testing.T) {
+	var calls atomic.Int32
+	server := serveEmbeddings(t, &calls)
+	dir, claudeDir := writeProject(t, map[string]string{
+		"retry.go": "package main\n\n// retry retry retry\nfunc retry() {}\n",
+		"parse.go": "package main\n\n// parse input\nfunc parse() {}\n",
+	})
+
+	opts := claude.NewOptions()
+	use := claude.ContentBlock{
+		Type:  "tool_use",
+		ID:    "toolu_1",
+		Name:  "semantic_search",
+		Input: map[string]interface{}{"query": "where do we retry", "limit": float64(1)},
+	}
+
+	// Not indexed: a tool error, not a failed conversation
+	result, err := claude.ExecuteTool(use, dir, claudeDir, opts, "conv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.Content, "Error: ") ||
+		!strings.Contains(result.Content, "claude index") {
+		t.Errorf("without index: %+v", result)
+	}
+
+	eo := claude.EmbedOptions{Model: "nomic-embed-text", URL: server.URL}
+	emb, err := claude.NewEmbedder(eo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := claude.BuildIndex(t.Context(), dir, claudeDir, emb, eo); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = claude.ExecuteTool(use, dir, claudeDir, opts, "conv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(result.Content, "Error: ") {
+		t.Fatalf("semantic_search failed: %s", result.Content)
+	}
+	if !strings.Contains(result.Content, "1 matches") ||
+		!strings.Contains(result.Content, "retry.go:1-4") ||
+		strings.Contains(result.Content, "parse.go") {
+		t.Errorf("result = %q, want only retry.go", result.Content)
+	}
+
+	offered := func(opts *claude.Options) bool {
+		for _, tool := range claude.GetTools(opts) {
+			if tool.Name == "semantic_search" {
+				return true
+			}
+		}
+		return false
+	}
+	if offered(opts) {
+		t.Error("semantic_search offered without SemanticSearch")
+	}
+	opts.SemanticSearch = true
+	if !offered(opts) {
+		t.Error("semantic_search not offered with SemanticSearch")
+	}
+}
+
+func TestRAGContext(t *testing.T) {
+	var calls atomic.Int32
+	server := serveEmbeddings(t, &calls)
+	dir, claudeDir := writeProject(t, map[string]string{
+		"retry.go":  "package main\n\n// retry retry\nfunc retry() {}\n",
+		"parse.go":  "package main\n\n// parse parse\nfunc parse() {}\n",
+		"render.go": "package main\n\n// render\nfunc render() {}\n",
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.RAG = 2
+
+	// No index yet
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "system"); err == nil ||
+		!strings.Contains(err.Error(), "claude index") {
+		t.Fatalf("InitSession without index = %v", err)
+	}
+
+	eo := claude.EmbedOptions{Model: "nomic-embed-text", URL: server.URL}
+	emb, err := claude.NewEmbedder(eo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := claude.BuildIndex(t.Context(), dir, claudeDir, emb, eo); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatalf("InitSession failed: %v", err)
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("it retries", "end_turn")}}
+	sess.SetLLM(mock)
+	prompt := "how does retry work, when do we retry and where do we parse?"
+	if _, err := claude.ExecuteConversation(sess, prompt); err != nil {
+		t.Fatal(err)
+	}
+
+	content := mock.requests[0].Messages[0].Content
+	if len(content) != 2 || content[1].Text != prompt {
+		t.Fatalf("user message = %+v, want context then prompt", content)
+	}
+	context := content[0].Text
+	for _, want := range []string{"-- source: retry.go:1-4", "-- source: parse.go:1-4"} {
+		if !strings.Contains(context, want) {
+			t.Errorf("context missing %q:\n%s", want, context)
+		}
+	}
+	if strings.Contains(context, "render.go") {
+		t.Errorf("context has more than 2 chunks:\n%s", context)
+	}
+
+	// The prompt is still what the history shows
+	history, err := claude.LoadHistory(claudeDir)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("LoadHistory = %v, %v", history, err)
+	}
+	if history[0].Prompt != prompt {
+		t.Errorf("history prompt = %q", history[0].Prompt)
+	}
+	if got := history[0].Meta.Context; len(got) != 2 || got[0] != "retry.go:1-4" {
+		t.Errorf("meta context = %v", got)
+	}
+
+	// Chunks over the budget are left out
+	opts.RAGTokens = 10
+	sess, err = claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock = &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+	sess.SetLLM(mock)
+	if _, err := claude.ExecuteConversation(sess, prompt); err != nil {
+		t.Fatal(err)
+	}
+	last := mock.requests[0].Messages[len(mock.requests[0].Messages)-1]
+	if len(last.Content) != 1 || last.Content[0].Text != prompt {
+		t.Errorf("over budget: user message = %+v, want the prompt only", last.Content)
+	}
+}
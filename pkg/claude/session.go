@@ -3,143 +3,450 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/router"
 	"github.com/marcopeereboom/go-claude/pkg/storage"
+	"github.com/marcopeereboom/go-claude/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // InitSession sets up all state needed for a conversation.
 func InitSession(opts *Options, claudeDir, apiURL, defaultSystemPrompt string) (*session, error) {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
+	if !storage.Ephemeral() {
+		if err := storage.MkdirAll(claudeDir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating .claude dir: %w", err)
+		}
 	}
 
-	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
-		return nil, fmt.Errorf("creating .claude dir: %w", err)
+	if err := registerToolPlugins(opts); err != nil {
+		return nil, err
 	}
 
 	// Load configuration
 	configPath := filepath.Join(claudeDir, "config.json")
 	cfg := storage.LoadOrCreateConfig(configPath)
 
-	selectedModel := SelectModel(opts.Model, cfg.Model)
+	selectedModel, err := ResolveModelAlias(SelectModel(opts.Model, cfg.Model), claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Model == "" {
+		warnSuperseded(selectedModel, claudeDir)
+	}
 	cfg.Model = selectedModel
+	if opts.TurnModel != "" {
+		// This turn only: cfg.Model stays what later turns use
+		selectedModel, err = ResolveDirective(opts.TurnModel, opts, cfg.Model, claudeDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Vertex and Bedrock authenticate with cloud credentials instead; fake
+	// models need none
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" && (opts.Provider == "" || opts.Provider == ProviderClaude) &&
+		!isFakeModel(selectedModel) {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
+	}
 
 	// Validate model exists in cache
 	if err := ValidateModel(selectedModel, claudeDir, opts.OllamaURL); err != nil {
 		return nil, err
 	}
 
-	sysPrompt := SelectSystemPrompt(opts.SystemPrompt, cfg.SystemPrompt, defaultSystemPrompt)
-
-	timestamp := time.Now().Format("20060102_150405")
+	sysPrompt, sysSource := ResolveSystemPrompt(opts.SystemPrompt, cfg.SystemPrompt,
+		defaultSystemPrompt)
+	sysBlocks, err := ComposeSystemPrompt(sysPrompt, opts.SystemFiles)
+	if err != nil {
+		return nil, err
+	}
+	sysPrompt = llm.JoinSystem(sysBlocks)
+	basePrompt := sysPrompt
+	sysHash := SystemPromptHash(sysPrompt)
+	warnSystemDrift(claudeDir, sysHash, sysSource)
+
+	// max_tokens 0 = use the model's output limit, as --timeout allows
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = defaultMaxTokens(selectedModel, claudeDir, opts.Timeout)
+	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Claude dir: %s\n", claudeDir)
-		fmt.Fprintf(os.Stderr, "Model: %s\n", selectedModel)
+	// A sub-agent's tool calls are audited under the turn it works for
+	timestamp := opts.agentOf
+	if timestamp == "" {
+		timestamp = turnTimestamp(claudeDir)
 	}
 
+	slog.Info("session", "claude_dir", claudeDir, "model", selectedModel)
+
 	// Load conversation history from request/response pairs
-	messages, err := storage.LoadConversationHistory(claudeDir)
+	messages, err := loadHistory(claudeDir, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Loaded %d messages\n", len(messages))
+	slog.Info("loaded history", "messages", len(messages))
+	messages = truncateHistory(messages, opts.Truncate)
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting working dir: %w", err)
 	}
 
-	// Handle truncation
-	if opts.Truncate > 0 && len(messages) > opts.Truncate {
-		if opts.IsVerbose() {
-			fmt.Fprintf(os.Stderr, "Truncating: %d → %d messages\n",
-				len(messages), opts.Truncate)
+	sysPrompt += workspacePrompt()
+	opts.startPatch(workingDir)
+	opts.startPending(workingDir)
+	opts.startToolBudget()
+	opts.startWriteHashes()
+	opts.apiURL, opts.agentRuns, opts.turnCost = apiURL, nil, 0
+
+	// Spare the model exploratory tool calls to learn the layout
+	if opts.ProjectContext {
+		tree, err := ProjectTree(workingDir, MaxTreeFiles)
+		if err != nil {
+			slog.Warn("project context unavailable", "err", err)
+		} else {
+			sysPrompt += "\n\nProject files (relative to the working directory):\n" + tree
 		}
-		messages = messages[len(messages)-opts.Truncate:]
 	}
 
-	// Check context size (will add user message in executeConversation)
-	estimatedTokens := EstimateTokens(messages)
-	if estimatedTokens > MaxContextTokens {
-		return nil, fmt.Errorf(
-			"conversation too large (%d tokens, max %d)\n"+
-				"Options:\n"+
-				"  claude --reset           # start fresh\n"+
-				"  claude --truncate N      # keep last N messages",
-			estimatedTokens, MaxContextTokens)
+	if opts.EnvContext {
+		sysPrompt += EnvContext(workingDir, time.Now())
 	}
 
-	workingDir, err := os.Getwd()
+	// Detect LLM provider based on model name
+	var llmClient llm.LLM
+	var unreachable error // Ollama health check
+
+	applyFallbackConfig(opts, cfg.Fallback)
+	if err := validateFallback(opts); err != nil {
+		return nil, err
+	}
+	chain, err := fallbackModels(selectedModel, opts, claudeDir)
 	if err != nil {
-		return nil, fmt.Errorf("getting working dir: %w", err)
+		return nil, err
+	}
+	fallbacks, err := fallbackTiers(chain, opts, claudeDir, apiKey, apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) > 0 {
+		slog.Info("fallback enabled", "primary", selectedModel,
+			"fallback", strings.Join(chain, ","))
 	}
 
-	// Detect LLM provider based on model name
-	var llmClient llm.LLM
-	var fallbackLLM llm.LLM
+	switch {
+	case isFakeModel(selectedModel):
+		llmClient, err = newFakeLLM(selectedModel, claudeDir)
+		if err != nil {
+			return nil, err
+		}
+	case isClaudeModel(selectedModel, opts.Provider):
+		llmClient, err = newClaudeLLM(opts.Provider, apiKey, apiURL)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		ollama := llm.NewOllama(selectedModel, opts.OllamaURL)
+		llmClient = ollama
+
+		// Fail now rather than deep in the loop with a bare connection
+		// error, or go straight to the fallback
+		unreachable = pingOllama(ollama, opts.OllamaURL)
+		if unreachable != nil && (len(fallbacks) == 0 || !opts.fallsBackOn(FallbackOnConnect)) {
+			return nil, unreachable
+		}
+	}
+
+	toolChoice, err := forceToolChoice(opts.ForceTool, GetTools(opts))
+	if err != nil {
+		return nil, err
+	}
+	if toolChoice != nil && !isClaudeModel(selectedModel, opts.Provider) && !isFakeModel(selectedModel) {
+		slog.Warn("--force-tool is ignored by Ollama", "model", selectedModel)
+	}
 
-	if strings.HasPrefix(selectedModel, "claude-") {
-		llmClient = llm.NewClaude(apiKey, apiURL)
+	// The workspace, tree and environment sections become the last block
+	if len(opts.SystemFiles) > 0 {
+		runtime := strings.TrimLeft(strings.TrimPrefix(sysPrompt, basePrompt), "\n")
+		sysBlocks = append(sysBlocks, llm.TextBlocks(runtime)...)
 	} else {
-		llmClient = llm.NewOllama(selectedModel, opts.OllamaURL)
+		sysBlocks = nil
+	}
 
-		// Set up fallback to Claude if enabled
-		if opts.AllowFallback {
-			fallbackModel := opts.FallbackModel
-			if fallbackModel == "" {
-				fallbackModel = DefaultModel
-			}
-			fallbackLLM = llm.NewClaude(apiKey, apiURL)
-			if opts.IsVerbose() {
-				fmt.Fprintf(os.Stderr, "Fallback enabled: %s → %s\n",
-					selectedModel, fallbackModel)
-			}
+	sess := &session{
+		opts:       opts,
+		claudeDir:  claudeDir,
+		config:     cfg,
+		model:      selectedModel,
+		sysPrompt:  sysPrompt,
+		sysBlocks:  sysBlocks,
+		sysHash:    sysHash,
+		sysSource:  sysSource,
+		timestamp:  timestamp,
+		workingDir: workingDir,
+		llmClient:  llmClient,
+		fallbacks:  fallbacks,
+		toolChoice: toolChoice,
+	}
+	if unreachable != nil {
+		tier, _ := sess.nextFallback()
+		slog.Warn("falling back", "err", unreachable, "model", tier.model)
+		sess.model = tier.model
+		sess.llmClient = tier.client
+	}
+
+	// Check context size (will add user message in executeConversation)
+	if err := sess.contextError(sess.model, messages); err != nil {
+		return nil, fmt.Errorf("%w\n"+
+			"Options:\n"+
+			"  claude --reset           # start fresh\n"+
+			"  claude --truncate N      # keep last N messages", err)
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return nil, err
+	}
+	if err := validateToolBudgets(opts.ToolBudgets); err != nil {
+		return nil, err
+	}
+	if err := validateBestOf(opts); err != nil {
+		return nil, err
+	}
+	if sess.judge, err = newJudge(opts, claudeDir, apiURL); err != nil {
+		return nil, err
+	}
+	if opts.RAG > 0 && !storage.HasEmbeddingIndex(claudeDir) {
+		return nil, fmt.Errorf("--rag: no index, run claude index")
+	}
+	sess.startBudget(time.Now())
+	if len(opts.Images) > 0 && !sess.llmClient.GetCapabilities().SupportsVision {
+		return nil, fmt.Errorf("--image: %s doesn't accept images (use a Claude "+
+			"model or a vision model such as llava)", sess.model)
+	}
+	return sess, nil
+}
+
+// requestToolChoice returns the tool_choice of the call after the turn's
+// first n responses. Forcing every call would never let the model finish
+// the turn: after the forced call it answers the tool result freely.
+func (s *session) requestToolChoice(n int) *llm.ToolChoice {
+	var choice *llm.ToolChoice
+	if n == 0 && s.toolChoice != nil {
+		c := *s.toolChoice
+		choice = &c
+	}
+	if s.opts.SequentialTools {
+		if choice == nil {
+			choice = &llm.ToolChoice{Type: llm.ToolChoiceAuto}
+		}
+		choice.DisableParallelToolUse = choice.Type != llm.ToolChoiceNone
+	}
+	return choice
+}
+
+// pingOllama turns an unreachable Ollama into an actionable error
+func pingOllama(client *llm.OllamaClient, url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), OllamaPingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("Ollama not running at %s; start it with `ollama serve` "+
+			"or pass --ollama-url (%v)", url, err)
+	}
+	return nil
+}
+
+// isClaudeModel reports whether model runs on a Claude backend. The
+// provider only picks how Claude is reached; on Bedrock its own IDs of
+// Claude models and inference profiles (anthropic.claude-..., arn:...) are
+// Claude too, other models still go to Ollama.
+func isClaudeModel(model, provider string) bool {
+	if strings.HasPrefix(model, "claude-") {
+		return true
+	}
+	return provider == ProviderBedrock &&
+		(strings.Contains(model, "anthropic.") || strings.HasPrefix(model, "arn:"))
+}
+
+// isFakeModel reports whether model is a scripted fake:SCENARIO
+func isFakeModel(model string) bool {
+	return strings.HasPrefix(model, FakeModelPrefix)
+}
+
+// newFakeLLM returns the client of a fake:SCENARIO model. Scenarios in
+// .claude/fakes win over the built-in ones.
+func newFakeLLM(model, claudeDir string) (llm.LLM, error) {
+	scenario := strings.TrimPrefix(model, FakeModelPrefix)
+	local := filepath.Join(claudeDir, "fakes", scenario+".json")
+	if _, err := os.Stat(local); err == nil {
+		scenario = local
+	}
+	client, err := llm.NewFake(scenario)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// recordRoutingOutcome teaches the router how a turn started on the local
+// model went: whether it fell back and how many iterations it took
+func (s *session) recordRoutingOutcome(userMsg string, iterations int) {
+	if isClaudeModel(s.model, s.opts.Provider) || isFakeModel(s.model) {
+		return
+	}
+	complexity := router.AnalyzeTask(userMsg).Complexity.String()
+	storage.RecordRoutingOutcome(s.config, complexity, len(s.fellBack) > 0, iterations)
+}
+
+// newClaudeLLM returns the client for Claude models on the given provider
+func newClaudeLLM(provider, apiKey, apiURL string) (llm.LLM, error) {
+	switch provider {
+	case "", ProviderClaude:
+		return llm.NewClaude(apiKey, apiURL), nil
+	case ProviderVertex:
+		client, err := llm.NewVertexFromEnv()
+		if err != nil {
+			return nil, err
 		}
+		return client, nil
+	case ProviderBedrock:
+		client, err := llm.NewBedrockFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want %s, %s or %s)",
+			provider, ProviderClaude, ProviderVertex, ProviderBedrock)
+	}
+}
+
+// modelClient returns the client of a single call to model and its
+// provider, for modes that call the model without a session
+func modelClient(model string, opts *Options, claudeDir, apiURL string) (llm.LLM, string, error) {
+	if isFakeModel(model) {
+		client, err := newFakeLLM(model, claudeDir)
+		return client, ProviderFake, err
+	}
+	if !isClaudeModel(model, opts.Provider) {
+		return llm.NewOllama(model, opts.OllamaURL), ProviderOllama, nil
+	}
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" && (opts.Provider == "" || opts.Provider == ProviderClaude) {
+		return nil, "", fmt.Errorf("ANTHROPIC_API_KEY not set")
 	}
+	client, err := newClaudeLLM(opts.Provider, apiKey, apiURL)
+	return client, ProviderClaude, err
+}
 
-	return &session{
-		opts:        opts,
-		claudeDir:   claudeDir,
-		apiKey:      apiKey,
-		config:      cfg,
-		model:       selectedModel,
-		sysPrompt:   sysPrompt,
-		timestamp:   timestamp,
-		workingDir:  workingDir,
-		client:      &http.Client{Timeout: time.Duration(opts.Timeout) * time.Second},
-		llmClient:   llmClient,
-		fallbackLLM: fallbackLLM,
-	}, nil
+// generate calls client inside an llm.generate span and records request
+// metrics
+func generate(ctx context.Context, client llm.LLM, req *llm.Request,
+	provider string, iteration int,
+) (*llm.Response, error) {
+	ctx, span := telemetry.Start(ctx, "llm.generate",
+		attribute.String("provider", provider),
+		attribute.String("model", req.Model),
+		attribute.Int("iteration", iteration))
+
+	start := time.Now()
+	resp, err := client.Generate(ctx, req)
+	call := telemetry.LLMCall{
+		Provider: provider,
+		Model:    req.Model,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if err == nil {
+		call.InputTokens = resp.Usage.InputTokens
+		call.OutputTokens = resp.Usage.OutputTokens
+		call.Cost = UsageCost(req.Model, call.InputTokens, call.OutputTokens)
+		span.SetAttributes(
+			attribute.String("stop_reason", resp.StopReason),
+			attribute.Int("input_tokens", call.InputTokens),
+			attribute.Int("output_tokens", call.OutputTokens))
+	}
+	telemetry.RecordLLMCall(ctx, call)
+	telemetry.End(span, err)
+	return resp, err
 }
 
 // ExecuteConversation runs the agentic loop with tool support and fallback.
-func ExecuteConversation(sess *session, userMsg string) (*conversationResult, error) {
+func ExecuteConversation(sess *session, userMsg string) (result *conversationResult, err error) {
+	ctx, span := telemetry.Start(context.Background(), "agent.conversation",
+		attribute.String("model", sess.model),
+		attribute.String("conversation_id", sess.timestamp))
+	defer func() { telemetry.End(span, err) }()
+
 	// Load conversation history
-	messages, err := storage.LoadConversationHistory(sess.claudeDir)
+	messages, err := loadHistory(sess.claudeDir, sess.opts)
 	if err != nil {
 		return nil, err
 	}
+	messages = truncateHistory(messages, sess.opts.Truncate)
+
+	sess.title = turnTitle(userMsg)
+	sess.startEnvelope(userMsg)
+	if sess.opts.ExplainRouting {
+		sess.explainRouting(userMsg)
+	}
 
-	// Add current user message
+	// Add current user message, images first as the API recommends and
+	// the prompt last
+	content, err := imageBlocks(sess.opts.Images)
+	if err != nil {
+		return nil, err
+	}
+	if sess.opts.RAG > 0 {
+		budget := sess.opts.RAGTokens
+		if budget <= 0 {
+			budget = DefaultRAGTokens
+		}
+		block, sources, err := ragContext(ctx, sess.claudeDir, userMsg,
+			sess.opts.RAG, budget)
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			content = append(content, *block)
+			sess.ragSources = sources
+		}
+	}
 	messages = append(messages, MessageContent{
 		Role: "user",
-		Content: []ContentBlock{{
+		Content: append(content, ContentBlock{
 			Type: "text",
 			Text: userMsg,
-		}},
+		}),
 	})
+	turnStart := len(messages) - 1 // the transcript starts at the prompt
+
+	// Never send credentials to the LLM
+	redactMessages(messages)
 
-	// Save request before calling API
-	if err := storage.SaveRequest(sess.claudeDir, sess.timestamp, messages); err != nil {
-		return nil, fmt.Errorf("saving request: %w", err)
+	if sess.opts.agentOf == "" {
+		warnInterrupted(sess.claudeDir)
+	}
+
+	// Save request before calling API; --read-only and --no-save turns
+	// leave no trace
+	if !sess.ephemeral() {
+		if err := storage.SaveRequest(sess.claudeDir, sess.timestamp, messages); err != nil {
+			return nil, fmt.Errorf("saving request: %w", err)
+		}
 	}
+	sess.startJournal()
+	sess.startSteering()
+	defer sess.stopSteering()
+	sess.startWatch()
+	defer sess.stopWatch()
 
 	var responses []json.RawMessage
 	iterationCost := 0.0
@@ -152,11 +459,23 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 	// Track which provider we're using
 	currentLLM := sess.llmClient
 	currentProvider := "ollama"
-	if strings.HasPrefix(sess.model, "claude-") {
+	switch {
+	case isFakeModel(sess.model):
+		currentProvider = ProviderFake
+	case isClaudeModel(sess.model, sess.opts.Provider):
 		currentProvider = "claude"
 	}
 	currentModel := sess.model
 
+	// Text of a response cut off at max_tokens that the next response
+	// continues (sent as an assistant prefill)
+	var truncated string
+	continuations := 0
+
+	// A tool call cut off at max_tokens is asked for again with more room
+	maxTokens := sess.opts.MaxTokens
+	toolRetries := 0
+
 	// Agentic loop: iterate until Claude is done or limits reached
 	for i := 0; i < maxIter; i++ {
 		// Call LLM via unified interface
@@ -164,43 +483,122 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 			Model:     currentModel,
 			Messages:  messages,
 			Tools:     GetTools(sess.opts),
-			MaxTokens: sess.opts.MaxTokens,
+			MaxTokens: maxTokens,
 			System:    sess.sysPrompt,
+			// With --system-file
+			SystemBlocks: sess.sysBlocks,
+
+			Temperature: sess.opts.Temperature,
+		}
+		req.ToolChoice = sess.requestToolChoice(len(responses))
+		// --prefill starts the answer; later calls go on from tool results
+		if len(responses) == 0 {
+			req.Prefill = sess.opts.Prefill
+		}
+
+		// Tool results grow the conversation every iteration
+		if err := sess.contextError(currentModel, messages); err != nil {
+			return nil, fmt.Errorf("%w after %d iterations (shrink tool "+
+				"results with --compress-results=N)", err, i)
 		}
 
-		ctx := context.Background()
-		llmResp, err := currentLLM.Generate(ctx, req)
+		slog.Debug("calling LLM",
+			"model", currentModel,
+			"messages", len(req.Messages),
+			"tools", len(req.Tools),
+			"max_tokens", req.MaxTokens)
+
+		if err := runHooks(ctx, hookEvent{
+			Event:          storage.HookPreRequest,
+			ConversationID: sess.timestamp,
+			WorkingDir:     sess.workingDir,
+			Request: &hookRequest{
+				Model:     currentModel,
+				Iteration: i + 1,
+				Messages:  len(messages),
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("request blocked by %w", err)
+		}
 
-		// Handle fallback if primary LLM fails
-		if err != nil && sess.fallbackLLM != nil && !sess.usedFallback {
-			if sess.opts.IsVerbose() {
-				fmt.Fprintf(os.Stderr, "Primary LLM failed (%v), falling back to Claude\n", err)
+		callStart := time.Now()
+		sess.watch.calling(i+1, currentProvider, currentModel)
+		done := waitProgress(sess.opts, currentProvider, currentModel, i+1)
+		llmResp, err := generate(ctx, currentLLM, req, currentProvider, i+1)
+		done()
+
+		// Install a missing local model instead of failing the run
+		if errors.Is(err, llm.ErrModelNotFound) && sess.opts.OllamaAutoPull {
+			if perr := PullModel(ctx, sess.claudeDir, sess.opts.OllamaURL, currentModel); perr != nil {
+				err = fmt.Errorf("%w (auto-pull failed: %v)", err, perr)
+			} else {
+				telemetry.RecordRetry(ctx, "ollama_pull")
+				done := waitProgress(sess.opts, currentProvider, currentModel, i+1)
+				llmResp, err = generate(ctx, currentLLM, req, currentProvider, i+1)
+				done()
 			}
+		}
 
-			// Switch to fallback
-			currentLLM = sess.fallbackLLM
-			currentProvider = "claude"
-			currentModel = sess.opts.FallbackModel
-			if currentModel == "" {
-				currentModel = DefaultModel
+		// Retry the model, then fall back through the chain
+		for attempt := 1; err != nil; attempt++ {
+			class := errorClass(err)
+			if !sess.opts.fallsBackOn(class) || ctx.Err() != nil {
+				break
 			}
-			sess.usedFallback = true
-
-			// Retry with fallback
-			req.Model = currentModel
-			llmResp, err = currentLLM.Generate(ctx, req)
+			if attempt <= sess.opts.FallbackRetries {
+				slog.Warn("LLM call failed, retrying", "model", currentModel,
+					"class", class, "attempt", attempt, "err", err)
+				if !retryWait(ctx, attempt) {
+					break
+				}
+				telemetry.RecordRetry(ctx, "retry")
+			} else if tier, ok := sess.nextFallback(); ok {
+				slog.Warn("LLM call failed, falling back", "model", currentModel,
+					"fallback", tier.model, "class", class, "err", err)
+				currentLLM = tier.client
+				currentProvider = tier.provider
+				currentModel = tier.model
+				req.Model = currentModel
+				attempt = 0
+				telemetry.RecordRetry(ctx, "fallback")
+			} else {
+				break
+			}
+			done := waitProgress(sess.opts, currentProvider, currentModel, i+1)
+			llmResp, err = generate(ctx, currentLLM, req, currentProvider, i+1)
+			done()
 		}
 
 		if err != nil {
 			return nil, fmt.Errorf("LLM API call failed: %w", err)
 		}
 
+		// --best-of: sample the first response again and keep the best
+		if len(responses) == 0 && sess.opts.BestOf > 1 {
+			var extra float64
+			llmResp, extra = sess.sampleBestOf(ctx, currentLLM, req, currentProvider,
+				currentModel, llmResp, i+1)
+			iterationCost += extra
+		}
+
 		// Convert to existing APIResponse format for backward compat
 		apiResp := &APIResponse{
+			Model:      currentModel,
 			Content:    llmResp.Content,
 			StopReason: llmResp.StopReason,
 			Usage:      llmResp.Usage,
 		}
+		if llmResp.KeyID != "" && !containsString(sess.apiKeys, llmResp.KeyID) {
+			sess.apiKeys = append(sess.apiKeys, llmResp.KeyID)
+		}
+
+		// Stitch a continuation onto the text it continues so the saved
+		// response (and history rebuilt from it) holds the whole answer
+		if truncated != "" {
+			apiResp.Content = prependText(truncated, apiResp.Content)
+			messages = messages[:len(messages)-1] // drop the prefill
+			truncated = ""
+		}
 
 		// Marshal response for saving
 		respBody, err := json.Marshal(apiResp)
@@ -216,12 +614,23 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 				apiResp.Error.Type, apiResp.Error.Message)
 		}
 
+		sess.summary.recordCall(sess.provider(currentModel), currentModel,
+			apiResp.Usage)
+
 		// Track cost this iteration
-		costIn := float64(apiResp.Usage.InputTokens) * 3.0 / 1000000
-		costOut := float64(apiResp.Usage.OutputTokens) * 15.0 / 1000000
-		iterationCost += costIn + costOut
+		cost := UsageCost(currentModel, apiResp.Usage.InputTokens,
+			apiResp.Usage.OutputTokens)
+		iterationCost += cost
+		sess.envelope.addCall(apiResp, cost, time.Since(callStart))
+		sess.watch.usage(&sess.summary, iterationCost)
+
+		telemetry.RecordIteration(ctx, apiResp.StopReason)
+		span.SetAttributes(
+			attribute.Int("iterations", i+1),
+			attribute.Float64("cost", iterationCost))
 
 		// Check cost limit
+		sess.checkBudgets(ctx, iterationCost)
 		if sess.opts.MaxCost > 0 && iterationCost > sess.opts.MaxCost {
 			return nil, fmt.Errorf(
 				"max cost exceeded ($%.4f > $%.4f) after %d iterations",
@@ -234,12 +643,14 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 		storage.UpdateProviderStats(sess.config, currentProvider,
 			apiResp.Usage.InputTokens, apiResp.Usage.OutputTokens)
 
-		if sess.opts.IsVerbose() {
-			fmt.Fprintf(os.Stderr,
-				"Iteration %d (%s) - Tokens: %d in, %d out (cost: $%.4f)\n",
-				i+1, currentProvider, apiResp.Usage.InputTokens, apiResp.Usage.OutputTokens,
-				costIn+costOut)
-		}
+		slog.Info("iteration",
+			"n", i+1,
+			"provider", currentProvider,
+			"model", currentModel,
+			"stop_reason", apiResp.StopReason,
+			"input_tokens", apiResp.Usage.InputTokens,
+			"output_tokens", apiResp.Usage.OutputTokens,
+			"cost", fmt.Sprintf("$%.4f", cost))
 
 		// Add assistant response to messages
 		messages = append(messages, MessageContent{
@@ -249,22 +660,90 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 
 		// Collect all responses
 		responses = append(responses, json.RawMessage(respBody))
+		sess.journalResponses(responses)
 
 		// Handle different stop reasons
 		switch apiResp.StopReason {
 		case "end_turn":
+			// A steering line typed too late for the tool results is a
+			// follow-up: the turn goes on with it
+			if steer := sess.steering(); len(steer) > 0 {
+				messages = append(messages, MessageContent{
+					Role:    "user",
+					Content: steer,
+				})
+				continue
+			}
+			// Don't accept an answer while the changes fail verification
+			// (re-check: bash_command may have fixed things meanwhile)
+			if sess.summary.Verify == VerifyFailed {
+				v := runVerify(ctx, sess.opts.Verify, sess.workingDir)
+				if !v.passed {
+					slog.Info("verification still failing, continuing")
+					feedback := []ContentBlock{{
+						Type: "text",
+						Text: v.message(sess.opts.Verify),
+					}}
+					redactBlocks(storage.Redactor(), feedback)
+					messages = append(messages, MessageContent{
+						Role:    "user",
+						Content: feedback,
+					})
+					continue
+				}
+				sess.summary.Verify = VerifyPassed
+			}
+			if msg := revalidate(ctx, sess); msg != "" {
+				slog.Info("validation still failing, continuing")
+				feedback := []ContentBlock{{Type: "text", Text: msg}}
+				redactBlocks(storage.Redactor(), feedback)
+				messages = append(messages, MessageContent{
+					Role:    "user",
+					Content: feedback,
+				})
+				continue
+			}
+
 			// Conversation complete - save response
 			assistantText := ExtractResponse(apiResp)
+			sess.recordRoutingOutcome(userMsg, i+1)
 
 			// Save all responses as array
 			responsesJSON, err := json.MarshalIndent(responses, "", "\t")
 			if err != nil {
 				return nil, fmt.Errorf("marshaling responses: %w", err)
 			}
-			if err := storage.SaveResponse(sess.claudeDir, sess.timestamp, responsesJSON); err != nil {
-				return nil, fmt.Errorf("saving responses: %w", err)
+			// Before the response: the turn is complete once that exists
+			if !sess.ephemeral() {
+				if err := storage.SaveTranscript(sess.claudeDir, sess.timestamp,
+					messages[turnStart:]); err != nil {
+					slog.Warn("saving transcript", "err", err)
+				}
+				if err := storage.SaveTurnMeta(sess.claudeDir, sess.timestamp, sess.turnMeta()); err != nil {
+					slog.Warn("saving turn metadata", "err", err)
+				}
+				if err := storage.SaveResponse(sess.claudeDir, sess.timestamp, responsesJSON); err != nil {
+					return nil, fmt.Errorf("saving responses: %w", err)
+				}
+			}
+			sess.endJournal()
+
+			// A sub-agent's answer is a tool result of the turn, not a turn
+			if sess.opts.agentOf == "" {
+				if err := runHooks(ctx, hookEvent{
+					Event:          storage.HookPostTurn,
+					ConversationID: sess.timestamp,
+					WorkingDir:     sess.workingDir,
+					Turn: &hookTurn{
+						Answer:  assistantText,
+						Summary: sess.Summary(nil),
+					},
+				}); err != nil {
+					slog.Warn("post_turn hook failed", "err", err)
+				}
 			}
 
+			sess.envelope.finish(assistantText, sess.Summary(nil))
 			return &conversationResult{
 				assistantText: assistantText,
 				respBody:      respBody,
@@ -272,11 +751,34 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 
 		case "tool_use":
 			// Execute tools and continue
-			toolResults, err := ExecuteTools(apiResp.Content,
+			calls, skipped := apiResp.Content, []ContentBlock(nil)
+			if sess.opts.SequentialTools {
+				calls, skipped = sequentialToolUse(apiResp.Content)
+			}
+			sess.watch.running(calls)
+			sess.opts.turnCost = iterationCost
+			toolResults, err := ExecuteToolsContext(ctx, calls,
 				sess.workingDir, sess.claudeDir, sess.opts, sess.timestamp)
 			if err != nil {
 				return nil, err
 			}
+			sess.summary.recordTools(calls, toolResults, sess.opts)
+			iterationCost += sess.recordAgents()
+			sess.watch.ran(calls, toolResults)
+			sess.watch.usage(&sess.summary, iterationCost)
+			sess.journalTools(calls, toolResults)
+			if v := verifyWrites(ctx, sess, calls, toolResults); v != nil {
+				sess.summary.Verify = VerifyFailed
+				if v.passed {
+					sess.summary.Verify = VerifyPassed
+				}
+			}
+			validateWrites(ctx, sess, calls, toolResults)
+			redactBlocks(storage.Redactor(), toolResults)
+			sess.envelope.addTools(calls, toolResults)
+			compressResults(ctx, sess, calls, toolResults)
+			toolResults = append(toolResults, skipped...)
+			toolResults = append(toolResults, sess.steering()...)
 
 			messages = append(messages, MessageContent{
 				Role:    "user",
@@ -284,6 +786,71 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 			})
 			// Continue loop
 
+		case "pause_turn":
+			// Long running server-side turn paused; sending the partial
+			// assistant turn back as-is lets the model resume it
+			slog.Info("turn paused by API, resuming")
+			telemetry.RecordRetry(ctx, "pause_turn")
+			// Continue loop
+
+		case "refusal":
+			// Don't save the response: the refused turn stays out of
+			// history so the next prompt isn't poisoned by it
+			sess.endJournal()
+			msg := strings.TrimSpace(ExtractResponse(apiResp))
+			if msg == "" {
+				msg = "no explanation given"
+			}
+			return nil, fmt.Errorf("%w: %s\n"+
+				"Rephrase the request, or start over with: claude --reset",
+				ErrRefusal, msg)
+
+		case "max_tokens":
+			// A cut off tool call can't be executed or continued: its
+			// input is incomplete. Drop the response and ask again with a
+			// larger limit.
+			if hasToolUse(apiResp.Content) {
+				limit := ResolveMaxTokens(currentModel, sess.claudeDir)
+				if toolRetries >= MaxToolRetries || maxTokens >= limit {
+					return nil, fmt.Errorf("response truncated at max_tokens (%d) "+
+						"inside a tool call (%s); rerun with a larger --max-tokens "+
+						"(model limit: %d)", maxTokens,
+						describeIncomplete(apiResp.Content, req.Tools), limit)
+				}
+				toolRetries++
+				maxTokens = min(maxTokens*2, limit)
+				telemetry.RecordRetry(ctx, "max_tokens_tool")
+				slog.Info("tool call cut off at max_tokens, retrying",
+					"max_tokens", maxTokens,
+					"retry", toolRetries,
+					"max", MaxToolRetries)
+
+				messages = messages[:len(messages)-1]
+				responses = responses[:len(responses)-1]
+				sess.journalResponses(responses)
+				continue
+			}
+			if continuations >= MaxContinuations {
+				return nil, fmt.Errorf("response still truncated after %d "+
+					"continuations; rerun with a larger --max-tokens (now %d)",
+					continuations, maxTokens)
+			}
+			continuations++
+			telemetry.RecordRetry(ctx, "max_tokens")
+
+			// Prefill the partial answer so the model picks up mid-sentence.
+			// The API rejects prefills ending in whitespace.
+			truncated = strings.TrimRight(ExtractResponse(apiResp), " \t\r\n")
+			messages[len(messages)-1] = MessageContent{
+				Role:    "assistant",
+				Content: []ContentBlock{{Type: "text", Text: truncated}},
+			}
+			slog.Info("output hit max_tokens, continuing",
+				"max_tokens", sess.opts.MaxTokens,
+				"continuation", continuations,
+				"max", MaxContinuations)
+			// Continue loop
+
 		default:
 			return nil, fmt.Errorf("unexpected stop_reason: %s",
 				apiResp.StopReason)
@@ -293,6 +860,21 @@ func ExecuteConversation(sess *session, userMsg string) (*conversationResult, er
 	return nil, fmt.Errorf("max iterations (%d) reached", maxIter)
 }
 
+// turnTimestamp returns a timestamp no saved turn uses yet. Timestamps have
+// second resolution, so back to back turns (playbook steps, quick -c
+// follow-ups) wait for the next second instead of overwriting each other.
+func turnTimestamp(claudeDir string) string {
+	for {
+		now := time.Now()
+		ts := now.Format("20060102_150405")
+		reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", ts))
+		if _, err := os.Stat(reqPath); os.IsNotExist(err) {
+			return ts
+		}
+		time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+	}
+}
+
 // FinalizeSession saves all state and outputs the result.
 func FinalizeSession(sess *session, result *conversationResult, saveJSONFunc func(string, interface{}) error, writeOutputFunc func(string, bool, string, []byte) error) error {
 	// Update timestamps
@@ -302,9 +884,35 @@ func FinalizeSession(sess *session, result *conversationResult, saveJSONFunc fun
 	}
 
 	// Save config
-	configPath := filepath.Join(sess.claudeDir, "config.json")
-	if err := saveJSONFunc(configPath, sess.config); err != nil {
-		return fmt.Errorf("saving config: %w", err)
+	if !storage.Ephemeral() {
+		configPath := filepath.Join(sess.claudeDir, "config.json")
+		if err := saveJSONFunc(configPath, sess.config); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		if err := dropAmended(sess.claudeDir, sess.opts); err != nil {
+			return err
+		}
+	}
+
+	// --output=patch: the patch is the output, the answer goes to stderr
+	if sess.opts.patch != nil {
+		fmt.Fprintln(os.Stderr, result.assistantText)
+		return writePatch(sess.opts, writeOutputFunc)
+	}
+
+	if sess.opts.pending != nil {
+		if err := writePending(sess.opts, sess.claudeDir, sess.timestamp); err != nil {
+			return err
+		}
+	}
+
+	// A streamed turn has an envelope too, but prints it with json-full only
+	if sess.envelope != nil && sess.opts.Output == OutputJSONFull {
+		envelope, err := json.MarshalIndent(sess.envelope, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling output: %w", err)
+		}
+		return writeOutputFunc(sess.opts.OutputFile, true, result.assistantText, envelope)
 	}
 
 	// Output result
@@ -323,39 +931,85 @@ func SelectModel(flagModel, cfgModel string) string {
 	}
 }
 
+// SelectSystemPrompt returns the system prompt ResolveSystemPrompt picks
 func SelectSystemPrompt(flagPrompt, cfgPrompt, defaultSystemPrompt string) string {
-	// System prompt priority:
-	// 1. --system flag (highest priority - one-time override)
-	// 2. CLAUDE_SYSTEM_PROMPT env var (session-level)
-	// 3. config.json SystemPrompt (persisted across conversations)
-	// 4. defaultSystemPrompt (fallback)
+	prompt, _ := ResolveSystemPrompt(flagPrompt, cfgPrompt, defaultSystemPrompt)
+	return prompt
+}
+
+// EstimateTokens approximates the tokens of messages (~4 chars per token),
+// tool calls and results included
+func EstimateTokens(messages []MessageContent) int {
+	return historyTokens(messages)
+}
 
-	if flagPrompt != "" {
-		return flagPrompt
+// hasToolUse reports whether blocks contain a tool call
+func hasToolUse(blocks []ContentBlock) bool {
+	for _, b := range blocks {
+		if b.Type == "tool_use" {
+			return true
+		}
 	}
+	return false
+}
 
-	if envPrompt := os.Getenv("CLAUDE_SYSTEM_PROMPT"); envPrompt != "" {
-		return envPrompt
+// describeIncomplete names the tool calls in blocks and the required inputs
+// they lack, for errors about cut off responses
+func describeIncomplete(blocks []ContentBlock, tools []Tool) string {
+	var calls []string
+	for _, b := range blocks {
+		if b.Type != "tool_use" {
+			continue
+		}
+		call := b.Name
+		if missing := missingInput(b, tools); len(missing) > 0 {
+			call += " missing " + strings.Join(missing, ", ")
+		}
+		calls = append(calls, call)
 	}
+	return strings.Join(calls, "; ")
+}
 
-	if cfgPrompt != "" {
-		return cfgPrompt
+// missingInput returns the required inputs of the tool call b's schema that
+// b doesn't have
+func missingInput(b ContentBlock, tools []Tool) []string {
+	var required []string
+	for _, tool := range tools {
+		if tool.Name != b.Name {
+			continue
+		}
+		schema, _ := tool.InputSchema.(map[string]interface{})
+		switch r := schema["required"].(type) {
+		case []string:
+			required = r
+		case []interface{}:
+			for _, v := range r {
+				if name, ok := v.(string); ok {
+					required = append(required, name)
+				}
+			}
+		}
 	}
 
-	return defaultSystemPrompt
+	var missing []string
+	for _, name := range required {
+		if _, ok := b.Input[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
 }
 
-func EstimateTokens(messages []MessageContent) int {
-	// Rough estimate: ~4 chars per token
-	total := 0
-	for _, msg := range messages {
-		for _, block := range msg.Content {
-			if block.Type == "text" {
-				total += len(block.Text) / 4
-			}
+// prependText joins prefix onto the first text block of blocks
+func prependText(prefix string, blocks []ContentBlock) []ContentBlock {
+	out := append([]ContentBlock(nil), blocks...)
+	for i := range out {
+		if out[i].Type == "text" {
+			out[i].Text = prefix + out[i].Text
+			return out
 		}
 	}
-	return total
+	return append([]ContentBlock{{Type: "text", Text: prefix}}, out...)
 }
 
 func ExtractResponse(apiResp *APIResponse) string {
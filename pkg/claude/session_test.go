@@ -0,0 +1,423 @@
+package claude_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// scriptedLLM returns canned responses in order and records requests
+type scriptedLLM struct {
+	responses []*llm.Response
+	requests  []*llm.Request
+}
+
+func (m *scriptedLLM) Generate(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	// Copy messages: the session keeps appending to its slice
+	cp := *req
+	cp.Messages = append([]llm.MessageContent(nil), req.Messages...)
+	m.requests = append(m.requests, &cp)
+	if len(m.responses) == 0 {
+		return nil, fmt.Errorf("scriptedLLM: no more responses")
+	}
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	return resp, nil
+}
+
+func (m *scriptedLLM) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	return nil, nil
+}
+
+func (m *scriptedLLM) GetCapabilities() llm.ModelCapabilities {
+	return llm.ModelCapabilities{SupportsTools: true, Provider: "claude"}
+}
+
+func textResponse(text, stopReason string) *llm.Response {
+	return &llm.Response{
+		Content:    []claude.ContentBlock{{Type: "text", Text: text}},
+		StopReason: stopReason,
+	}
+}
+
+// runScripted runs one conversation turn in a temp project against mock
+// and returns the answer text and the .claude dir
+func runScripted(t *testing.T, opts *claude.Options, mock *scriptedLLM, prompt string) (string, string, error) {
+	t.Helper()
+	text, claudeDir, _, err := runScriptedSummary(t, opts, mock, prompt)
+	return text, claudeDir, err
+}
+
+// runScriptedSummary is runScripted that also returns the run summary
+func runScriptedSummary(t *testing.T, opts *claude.Options, mock *scriptedLLM, prompt string) (string, string, *claude.RunSummary, error) {
+	t.Helper()
+	workDir := t.TempDir()
+	claudeDir := filepath.Join(workDir, ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	t.Chdir(workDir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	// Skip model discovery
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatalf("InitSession failed: %v", err)
+	}
+	sess.SetLLM(mock)
+
+	result, err := claude.ExecuteConversation(sess, prompt)
+	if err != nil {
+		return "", claudeDir, sess.Summary(err), err
+	}
+	return result.AssistantText(), claudeDir, sess.Summary(nil), nil
+}
+
+func TestMaxTokensContinuation(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		textResponse("The quick brown ", "max_tokens"),
+		textResponse(" fox jumps", "max_tokens"),
+		textResponse(" over the dog.", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	text, claudeDir, err := runScripted(t, opts, mock, "finish the sentence")
+	if err != nil {
+		t.Fatalf("ExecuteConversation failed: %v", err)
+	}
+	want := "The quick brown fox jumps over the dog."
+	if text != want {
+		t.Errorf("stitched text = %q, want %q", text, want)
+	}
+
+	// Continuations prefill the partial answer without trailing whitespace
+	if len(mock.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(mock.requests))
+	}
+	last := mock.requests[2].Messages
+	prefill := last[len(last)-1]
+	if prefill.Role != "assistant" || prefill.Content[0].Text != "The quick brown fox jumps" {
+		t.Errorf("unexpected prefill: %+v", prefill)
+	}
+
+	// History rebuilt from disk holds the whole answer
+	history, err := storage.LoadConversationHistory(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history[len(history)-1].Content[0].Text; got != want {
+		t.Errorf("history answer = %q, want %q", got, want)
+	}
+}
+
+func TestMaxTokensLimits(t *testing.T) {
+	truncatedTool := &llm.Response{
+		Content: []claude.ContentBlock{
+			{Type: "text", Text: "writing"},
+			{Type: "tool_use", ID: "toolu_1", Name: "write_file", Input: map[string]interface{}{"path": "x"}},
+		},
+		StopReason: "max_tokens",
+	}
+	tests := []struct {
+		name      string
+		maxTokens int
+		responses []*llm.Response
+		wantErr   string
+	}{
+		{
+			"truncated tool call at the model limit", 64000,
+			[]*llm.Response{truncatedTool}, "inside a tool call (write_file missing content)",
+		},
+		{
+			"truncated tool call after retries", 1000,
+			[]*llm.Response{truncatedTool, truncatedTool, truncatedTool}, "max_tokens (4000)",
+		},
+		{
+			"too many continuations", 0,
+			[]*llm.Response{
+				textResponse("a", "max_tokens"),
+				textResponse("b", "max_tokens"),
+				textResponse("c", "max_tokens"),
+				textResponse("d", "max_tokens"),
+			},
+			"still truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := claude.NewOptions()
+			opts.SetVerbosity(claude.VerbositySilent)
+			if tt.maxTokens > 0 {
+				opts.MaxTokens = tt.maxTokens
+			}
+			_, _, err := runScripted(t, opts, &scriptedLLM{responses: tt.responses}, "go")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMaxTokensToolRetry(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{
+			Content: []claude.ContentBlock{{
+				Type: "tool_use", ID: "toolu_1", Name: "read_file",
+				Input: map[string]interface{}{},
+			}},
+			StopReason: "max_tokens",
+		},
+		textResponse("done", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.MaxTokens = 1000
+
+	text, claudeDir, err := runScripted(t, opts, mock, "go")
+	if err != nil || text != "done" {
+		t.Fatalf("ExecuteConversation = %q, %v", text, err)
+	}
+	if len(mock.requests) != 2 || mock.requests[1].MaxTokens != 2000 {
+		t.Fatalf("retry not sent with a doubled limit: %d requests", len(mock.requests))
+	}
+	// The cut off call is neither answered nor saved
+	if n := len(mock.requests[1].Messages); n != 1 {
+		t.Errorf("retry sent %d messages, want just the prompt", n)
+	}
+	pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+	responses, _ := storage.LoadResponses(claudeDir, pairs[0])
+	if len(responses) != 1 {
+		t.Errorf("saved %d responses, want 1", len(responses))
+	}
+}
+
+func TestMaxTokensAutoSize(t *testing.T) {
+	for _, tt := range []struct {
+		timeout int
+		want    int
+	}{
+		{claude.DefaultTimeout, 9000}, // what arrives in 300s, not the model's 64000
+		{1200, 36000},
+		{0, 64000}, // no timeout: the model's limit
+	} {
+		mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+		opts := claude.NewOptions()
+		opts.SetVerbosity(claude.VerbositySilent)
+		opts.Model = "claude-sonnet-4-20250514"
+		opts.MaxTokens = 0
+		opts.Timeout = tt.timeout
+
+		if _, _, err := runScripted(t, opts, mock, "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if got := mock.requests[0].MaxTokens; got != tt.want {
+			t.Errorf("timeout %d: auto max_tokens = %d, want %d", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestPauseTurnResumes(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		textResponse("searching", "pause_turn"),
+		textResponse("found it", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	text, _, err := runScripted(t, opts, mock, "look it up")
+	if err != nil {
+		t.Fatalf("ExecuteConversation failed: %v", err)
+	}
+	if text != "found it" {
+		t.Errorf("text = %q", text)
+	}
+
+	// Resume request ends with the paused assistant turn, no new user turn
+	resume := mock.requests[1].Messages
+	if last := resume[len(resume)-1]; last.Role != "assistant" || last.Content[0].Text != "searching" {
+		t.Errorf("resume should send paused turn back, got %+v", last)
+	}
+}
+
+func TestRefusal(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		textResponse("I can't help with that.", "refusal"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	_, claudeDir, err := runScripted(t, opts, mock, "something bad")
+	if !errors.Is(err, claude.ErrRefusal) {
+		t.Fatalf("expected ErrRefusal, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "I can't help with that.") {
+		t.Errorf("error should include the model's message: %v", err)
+	}
+
+	// Refused turn must not become part of history
+	pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+	if len(pairs) != 0 {
+		t.Errorf("refused turn saved as history: %v", pairs)
+	}
+}
+
+func TestTemperaturePassedToLLM(t *testing.T) {
+	zero := 0.0
+	opts := claude.NewOptions()
+	opts.Temperature = &zero
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+	if _, _, err := runScripted(t, opts, mock, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.requests[0].Temperature; got == nil || *got != 0 {
+		t.Errorf("Temperature = %v, want 0", got)
+	}
+}
+
+func TestForceTool(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.ForceTool = "read_file"
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{
+			Content: []claude.ContentBlock{{
+				Type: "tool_use", ID: "t1", Name: "read_file",
+				Input: map[string]interface{}{"path": "missing.txt"},
+			}},
+			StopReason: "tool_use",
+		},
+		textResponse("done", "end_turn"),
+	}}
+	if _, _, err := runScripted(t, opts, mock, "read it"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.requests[0].ToolChoice; got == nil || got.Type != llm.ToolChoiceTool ||
+		got.Name != "read_file" {
+		t.Errorf("first call tool_choice = %+v, want read_file", got)
+	}
+	if got := mock.requests[1].ToolChoice; got != nil {
+		t.Errorf("tool_choice %+v after the forced call, want the default", got)
+	}
+
+	// Unknown tools fail before anything is sent
+	opts.ForceTool = "delete_everything"
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	_, err := claude.InitSession(opts, filepath.Join(t.TempDir(), ".claude"), "http://unused", "system")
+	if err == nil || !strings.Contains(err.Error(), "no such tool") {
+		t.Errorf("InitSession = %v, want unknown tool error", err)
+	}
+}
+
+func TestSequentialTools(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SequentialTools = true
+	read := func(id, path string) claude.ContentBlock {
+		return claude.ContentBlock{
+			Type: "tool_use", ID: id, Name: "read_file",
+			Input: map[string]interface{}{"path": path},
+		}
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{
+			Content:    []claude.ContentBlock{read("t1", "a.txt"), read("t2", "b.txt")},
+			StopReason: "tool_use",
+		},
+		textResponse("done", "end_turn"),
+	}}
+	_, _, summary, err := runScriptedSummary(t, opts, mock, "read both")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, req := range mock.requests {
+		if req.ToolChoice == nil || !req.ToolChoice.DisableParallelToolUse ||
+			req.ToolChoice.Type != llm.ToolChoiceAuto {
+			t.Errorf("call %d tool_choice = %+v, want auto without parallel tool use",
+				i+1, req.ToolChoice)
+		}
+	}
+
+	// Every call is answered, but only the first ran
+	results := mock.requests[1].Messages[len(mock.requests[1].Messages)-1].Content
+	if len(results) != 2 || results[0].ToolUseID != "t1" || results[1].ToolUseID != "t2" {
+		t.Fatalf("unexpected tool results %+v", results)
+	}
+	if !strings.HasPrefix(results[1].Content, "Error: read_file not executed") {
+		t.Errorf("second call answered with %q", results[1].Content)
+	}
+	if summary.ToolsExecuted["read_file"] != 1 {
+		t.Errorf("tools executed = %v, want one read_file", summary.ToolsExecuted)
+	}
+}
+
+func TestImageAttached(t *testing.T) {
+	png := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Images = []string{png}
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("a chart", "end_turn")}}
+	if _, _, err := runScripted(t, opts, mock, "what is this?"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := mock.requests[0].Messages[0].Content
+	if len(content) != 2 || content[0].Type != "image" || content[1].Text != "what is this?" {
+		t.Fatalf("unexpected user message %+v", content)
+	}
+	if src := content[0].Source; src.MediaType != "image/png" || src.Type != "base64" {
+		t.Errorf("unexpected image source %+v", src)
+	}
+
+	// Anything but an image is refused before it is sent
+	opts.Images = []string{filepath.Join(filepath.Dir(png), "notes.txt")}
+	os.WriteFile(opts.Images[0], []byte("just text"), 0o644)
+	mock = &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+	if _, _, err := runScripted(t, opts, mock, "and this?"); err == nil ||
+		!strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("expected unsupported type error, got %v", err)
+	}
+}
+
+func TestMaxCostUsesModelPricing(t *testing.T) {
+	const opus = "claude-opus-4-20250514"
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: opus, Provider: "claude"}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Model = opus
+	opts.MaxCost = 0.5
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// $0.90 at Opus prices, $0.18 at Sonnet's
+	resp := textResponse("expensive", "end_turn")
+	resp.Usage = llm.Usage{InputTokens: 10000, OutputTokens: 10000}
+	sess.SetLLM(&scriptedLLM{responses: []*llm.Response{resp}})
+
+	_, err = claude.ExecuteConversation(sess, "hi")
+	if err == nil || !strings.Contains(err.Error(), "max cost exceeded ($0.9000") {
+		t.Errorf("err = %v, want max cost exceeded at Opus prices", err)
+	}
+}
@@ -0,0 +1,221 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shell.go - Output targets of bash_command
+//
+// Commands may write files through redirections (>, >>, >|, &>, 2>) and
+// tee. The command line is split into words and operators the way bash
+// quotes them, and every target must be a regular file in the working
+// directory, checked with isSafePath like write_file paths. Targets bash
+// would expand ($VAR, ~, globs) can't be checked and are refused, and so
+// are command substitutions (`...` and $(...)) outside single quotes: the
+// commands inside them would run unchecked.
+
+// harmlessTargets may be written from anywhere
+var harmlessTargets = map[string]bool{
+	"/dev/null":   true,
+	"/dev/stdout": true,
+	"/dev/stderr": true,
+}
+
+// shellToken is a word or an operator of a command line
+type shellToken struct {
+	text string
+	op   bool // a separator, < or an output redirection
+}
+
+// separator reports whether tok starts a new command: |, &, ;, a newline
+// or a parenthesis, as in "echo a\ntee x" or "(tee x)"
+func (tok shellToken) separator() bool {
+	return tok.op && strings.Contains("|&;\n()", tok.text)
+}
+
+// errSubstitution refuses command substitutions
+var errSubstitution = errors.New("command substitution not allowed")
+
+// substitution reports whether command[i] starts a command substitution
+func substitution(command string, i int) bool {
+	return command[i] == '`' ||
+		(command[i] == '$' && i+1 < len(command) && command[i+1] == '(')
+}
+
+// shellTokens splits command into words and operators. Quotes and
+// backslashes are removed from words as bash would.
+func shellTokens(command string) ([]shellToken, error) {
+	var tokens []shellToken
+	var word strings.Builder
+	inWord, quoted := false, false
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{text: word.String()})
+		}
+		word.Reset()
+		inWord, quoted = false, false
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			inWord, quoted = true, true
+			i += end + 1
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) {
+					i++
+				} else if substitution(command, i) {
+					return nil, errSubstitution
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			inWord, quoted = true, true
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord, quoted = true, true
+		case substitution(command, i):
+			return nil, errSubstitution
+		case c == ' ' || c == '\t':
+			flush()
+		case c == '>' || (c == '&' && i+1 < len(command) && command[i+1] == '>'):
+			// A number right before > is the descriptor, not a word
+			if inWord && !quoted && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			op := string(c)
+			if c == '&' {
+				i++
+				op += ">"
+			}
+			if i+1 < len(command) && strings.IndexByte(">|&", command[i+1]) >= 0 &&
+				!(op == "&>" && command[i+1] != '>') {
+				i++
+				op += string(command[i])
+			}
+			tokens = append(tokens, shellToken{text: op, op: true})
+		case strings.IndexByte("|&<;\n()", c) >= 0:
+			flush()
+			tokens = append(tokens, shellToken{text: string(c), op: true})
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+// outputTargets returns the files command writes with redirections and
+// tee
+func outputTargets(command string) ([]string, error) {
+	tokens, err := shellTokens(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []string
+	start := true // at the start of a pipeline segment
+	tee := false  // in the arguments of tee
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.op && strings.Contains(tok.text, ">"):
+			if i+1 >= len(tokens) || tokens[i+1].op {
+				return nil, fmt.Errorf("redirect without target")
+			}
+			i++
+			next := tokens[i].text
+			// >&2 and 2>&1 duplicate a descriptor
+			if strings.HasSuffix(tok.text, "&") &&
+				(next == "-" || strings.Trim(next, "0123456789") == "") {
+				continue
+			}
+			targets = append(targets, next)
+		case tok.op:
+			start = tok.separator()
+			tee = false
+		case start:
+			tee = tok.text == "tee"
+			start = false
+		case tee && !strings.HasPrefix(tok.text, "-"):
+			targets = append(targets, tok.text)
+		}
+	}
+	return targets, nil
+}
+
+// commandSegments returns the words of every command of command: its
+// pipeline segments and the commands after newlines, & and parentheses.
+// Redirections and their targets are left out.
+func commandSegments(command string) ([][]string, error) {
+	tokens, err := shellTokens(command)
+	if err != nil {
+		return nil, err
+	}
+	var segments [][]string
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.separator():
+			if len(words) > 0 {
+				segments = append(segments, words)
+			}
+			words = nil
+		case tok.op:
+			i++ // the target of a redirection
+		default:
+			words = append(words, tok.text)
+		}
+	}
+	if len(words) > 0 {
+		segments = append(segments, words)
+	}
+	return segments, nil
+}
+
+// validateOutputTargets checks that command only writes regular files in
+// workingDir
+func validateOutputTargets(command, workingDir string) error {
+	targets, err := outputTargets(command)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if harmlessTargets[target] {
+			continue
+		}
+		if strings.ContainsAny(target, "$`*?[{~") {
+			return fmt.Errorf("output target must be a plain path: %s", target)
+		}
+		path := target
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		if !isSafePath(path, workingDir) {
+			return fmt.Errorf("redirect outside project: %s", target)
+		}
+		if fi, err := os.Stat(path); err == nil && !fi.Mode().IsRegular() {
+			return fmt.Errorf("output target is not a regular file: %s", target)
+		}
+	}
+	return nil
+}
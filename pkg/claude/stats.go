@@ -0,0 +1,321 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// StatsDays is how many days the --stats time series covers
+const StatsDays = 30
+
+// Stats is the usage report of a project (--stats)
+type Stats struct {
+	Project       string       `json:"project"`
+	Model         string       `json:"model"`
+	TotalInput    int          `json:"total_input_tokens"` // all time, including pruned turns
+	TotalOutput   int          `json:"total_output_tokens"`
+	FirstRun      string       `json:"first_run"`
+	LastRun       string       `json:"last_run"`
+	Turns         int          `json:"turns"`
+	Cost          float64      `json:"cost"` // of the saved turns
+	AvgIterations float64      `json:"avg_iterations"`
+	AvgDurationMs int64        `json:"avg_duration_ms,omitempty"` // of the timed turns
+	TimedTurns    int          `json:"timed_turns"`               // turns with metadata
+	Models        []UsageStats `json:"models"`
+	Providers     []UsageStats `json:"providers"`
+	Daily         []DailyStats `json:"daily"`
+	Tools         []ToolStats  `json:"tools"`
+}
+
+// UsageStats is the usage of one model or provider
+type UsageStats struct {
+	Name         string  `json:"name"`
+	Provider     string  `json:"provider,omitempty"` // for models
+	Turns        int     `json:"turns"`
+	Iterations   int     `json:"iterations"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// DailyStats is the usage of one day
+type DailyStats struct {
+	Date         string  `json:"date"` // 2006-01-02
+	Turns        int     `json:"turns"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// ToolStats counts the executions of one tool in the audit log
+type ToolStats struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Failed int    `json:"failed"`
+	DryRun int    `json:"dry_run"`
+}
+
+// LoadStats builds the usage report of claudeDir as of now
+func LoadStats(claudeDir string, now time.Time) (*Stats, error) {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	entries, err := LoadHistory(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{
+		Project:     claudeDir,
+		Model:       cfg.Model,
+		TotalInput:  cfg.TotalInput,
+		TotalOutput: cfg.TotalOutput,
+		FirstRun:    cfg.FirstRun,
+		LastRun:     cfg.LastRun,
+		Turns:       len(entries),
+	}
+
+	// One bucket per day, oldest first, including quiet days
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make(map[string]*DailyStats, StatsDays)
+	for i := StatsDays - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format(time.DateOnly)
+		s.Daily = append(s.Daily, DailyStats{Date: date})
+	}
+	for i := range s.Daily {
+		days[s.Daily[i].Date] = &s.Daily[i]
+	}
+
+	models := make(map[string]*UsageStats)
+	providers := make(map[string]*UsageStats)
+	iterations := 0
+	var took time.Duration
+	for _, e := range entries {
+		s.Cost += e.Cost
+		iterations += e.Iterations
+		if e.Meta != nil {
+			took += e.Meta.Duration()
+			s.TimedTurns++
+		}
+
+		provider := entryProvider(&e)
+		model := e.Model
+		if model == "" {
+			model = "unknown"
+		}
+		addUsage(models, model, provider, &e)
+		addUsage(providers, provider, "", &e)
+
+		if t, err := time.ParseInLocation("20060102_150405", e.Timestamp, now.Location()); err == nil {
+			if day := days[t.Format(time.DateOnly)]; day != nil {
+				day.Turns++
+				day.InputTokens += e.InputTokens
+				day.OutputTokens += e.OutputTokens
+				day.Cost += e.Cost
+			}
+		}
+	}
+	if s.Turns > 0 {
+		s.AvgIterations = float64(iterations) / float64(s.Turns)
+	}
+	if s.TimedTurns > 0 {
+		s.AvgDurationMs = (took / time.Duration(s.TimedTurns)).Milliseconds()
+	}
+	s.Models = sortedUsage(models)
+	s.Providers = sortedUsage(providers)
+
+	audit, err := storage.LoadAuditLog(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	tools := make(map[string]*ToolStats)
+	for _, a := range audit {
+		t := tools[a.Tool]
+		if t == nil {
+			t = &ToolStats{Name: a.Tool}
+			tools[a.Tool] = t
+		}
+		t.Calls++
+		if !a.Success {
+			t.Failed++
+		}
+		if a.DryRun {
+			t.DryRun++
+		}
+	}
+	s.Tools = make([]ToolStats, 0, len(tools))
+	for _, t := range tools {
+		s.Tools = append(s.Tools, *t)
+	}
+	sort.Slice(s.Tools, func(i, j int) bool {
+		if s.Tools[i].Calls != s.Tools[j].Calls {
+			return s.Tools[i].Calls > s.Tools[j].Calls
+		}
+		return s.Tools[i].Name < s.Tools[j].Name
+	})
+	return s, nil
+}
+
+// entryProvider returns who answered e; turns saved without metadata are
+// attributed by model name
+func entryProvider(e *HistoryEntry) string {
+	if e.Meta != nil && e.Meta.Provider != "" {
+		return e.Meta.Provider
+	}
+	if isClaudeModel(e.Model, "") {
+		return ProviderClaude
+	}
+	if isFakeModel(e.Model) {
+		return ProviderFake
+	}
+	return ProviderOllama
+}
+
+func addUsage(m map[string]*UsageStats, name, provider string, e *HistoryEntry) {
+	u := m[name]
+	if u == nil {
+		u = &UsageStats{Name: name, Provider: provider}
+		m[name] = u
+	}
+	u.Turns++
+	u.Iterations += e.Iterations
+	u.InputTokens += e.InputTokens
+	u.OutputTokens += e.OutputTokens
+	u.Cost += e.Cost
+}
+
+// sortedUsage returns the values of m, most turns first
+func sortedUsage(m map[string]*UsageStats) []UsageStats {
+	list := make([]UsageStats, 0, len(m))
+	for _, u := range m {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Turns != list[j].Turns {
+			return list[i].Turns > list[j].Turns
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// sparkBlocks are the bar heights of a sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as one bar per value scaled to the largest;
+// zero is always the lowest bar
+func sparkline(values []float64) string {
+	var top float64
+	for _, v := range values {
+		top = max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = int(v / top * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// StatsCommand handles --stats: prints the usage report, as JSON to stdout
+// with jsonOutput
+func StatsCommand(claudeDir string, jsonOutput bool) error {
+	s, err := LoadStats(claudeDir, time.Now())
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Project: %s\n", s.Project)
+	fmt.Fprintf(os.Stderr, "Model: %s\n", s.Model)
+	fmt.Fprintf(os.Stderr, "Total tokens: %d in, %d out\n", s.TotalInput, s.TotalOutput)
+	fmt.Fprintf(os.Stderr, "Conversation turns: %d ($%.4f, %.1f iterations per turn)\n",
+		s.Turns, s.Cost, s.AvgIterations)
+	if s.TimedTurns > 0 {
+		fmt.Fprintf(os.Stderr, "Average turn: %s (%d turns with metadata)\n",
+			(time.Duration(s.AvgDurationMs) * time.Millisecond).Round(100*time.Millisecond),
+			s.TimedTurns)
+	}
+	fmt.Fprintf(os.Stderr, "First run: %s\n", s.FirstRun)
+	fmt.Fprintf(os.Stderr, "Last run: %s\n", s.LastRun)
+
+	if len(s.Models) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%-30s  %-8s  %5s  %6s  %15s  %9s\n",
+			"MODEL", "PROVIDER", "TURNS", "ITERS", "TOKENS IN/OUT", "COST")
+		for _, u := range s.Models {
+			fmt.Fprintf(os.Stderr, "%-30s  %-8s  %5d  %6d  %7d/%-7d  $%8.4f\n",
+				u.Name, u.Provider, u.Turns, u.Iterations, u.InputTokens, u.OutputTokens, u.Cost)
+		}
+		fmt.Fprintf(os.Stderr, "\nProvider usage:\n")
+		for _, u := range s.Providers {
+			fmt.Fprintf(os.Stderr, "  %s: %d turns (%.1f%%), $%.4f\n", u.Name, u.Turns,
+				float64(u.Turns)/float64(s.Turns)*100, u.Cost)
+		}
+	}
+
+	showDaily(s.Daily)
+
+	if len(s.Tools) > 0 {
+		fmt.Fprintf(os.Stderr, "\nTool use:\n")
+		for _, t := range s.Tools {
+			fmt.Fprintf(os.Stderr, "  %-14s %5d calls", t.Name, t.Calls)
+			if t.Failed > 0 {
+				fmt.Fprintf(os.Stderr, ", %d failed", t.Failed)
+			}
+			if t.DryRun > 0 {
+				fmt.Fprintf(os.Stderr, ", %d dry-run", t.DryRun)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	return nil
+}
+
+// showDaily prints the time series: sparklines on a terminal, the active
+// days otherwise
+func showDaily(daily []DailyStats) {
+	var tokens, cost []float64
+	var maxTokens int
+	var maxCost float64
+	active := false
+	for _, d := range daily {
+		n := d.InputTokens + d.OutputTokens
+		tokens = append(tokens, float64(n))
+		cost = append(cost, d.Cost)
+		maxTokens = max(maxTokens, n)
+		maxCost = max(maxCost, d.Cost)
+		active = active || d.Turns > 0
+	}
+	if !active {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\nLast %d days:\n", len(daily))
+	if display.IsTTY(os.Stderr) {
+		fmt.Fprintf(os.Stderr, "  tokens %s  max %d/day\n", sparkline(tokens), maxTokens)
+		fmt.Fprintf(os.Stderr, "  cost   %s  max $%.4f/day\n", sparkline(cost), maxCost)
+		return
+	}
+	for _, d := range daily {
+		if d.Turns > 0 {
+			fmt.Fprintf(os.Stderr, "  %s  %3d turns  %7d/%-7d  $%.4f\n",
+				d.Date, d.Turns, d.InputTokens, d.OutputTokens, d.Cost)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func saveStatsTurn(t *testing.T, claudeDir, ts, model string, iterations int) {
+	t.Helper()
+	if err := storage.SaveRequest(claudeDir, ts, []storage.MessageContent{{
+		Role: "user", Content: []storage.ContentBlock{{Type: "text", Text: "q"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	var responses []storage.APIResponse
+	for i := 0; i < iterations; i++ {
+		responses = append(responses, storage.APIResponse{
+			Model: model,
+			Usage: claude.Usage{InputTokens: 1000, OutputTokens: 100},
+		})
+	}
+	body, _ := json.Marshal(responses)
+	if err := storage.SaveResponse(claudeDir, ts, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	claudeDir := t.TempDir()
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.Local)
+	saveStatsTurn(t, claudeDir, "20260130_100000", "claude-sonnet-4-20250514", 1)
+	saveStatsTurn(t, claudeDir, "20260131_100000", "claude-sonnet-4-20250514", 3)
+	saveStatsTurn(t, claudeDir, "20260131_110000", "llama3.1:8b", 2)
+	// Outside the time series, still counted
+	saveStatsTurn(t, claudeDir, "20251201_100000", "claude-sonnet-4-20250514", 2)
+
+	for _, entry := range []storage.AuditLogEntry{
+		{Tool: "read_file", Success: true},
+		{Tool: "read_file", Success: false},
+		{Tool: "write_file", Success: true, DryRun: true},
+	} {
+		if err := storage.AppendAuditLog(claudeDir, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := claude.LoadStats(claudeDir, now)
+	if err != nil {
+		t.Fatalf("LoadStats: %v", err)
+	}
+	if s.Turns != 4 || s.AvgIterations != 2 {
+		t.Errorf("turns = %d, avg iterations = %v; want 4, 2", s.Turns, s.AvgIterations)
+	}
+
+	if len(s.Models) != 2 || s.Models[0].Name != "claude-sonnet-4-20250514" ||
+		s.Models[0].Turns != 3 || s.Models[0].Iterations != 6 {
+		t.Errorf("unexpected models %+v", s.Models)
+	}
+	if len(s.Providers) != 2 || s.Providers[1].Name != claude.ProviderOllama ||
+		s.Providers[1].Cost != 0 {
+		t.Errorf("unexpected providers %+v", s.Providers)
+	}
+
+	if len(s.Daily) != claude.StatsDays {
+		t.Fatalf("got %d days, want %d", len(s.Daily), claude.StatsDays)
+	}
+	yesterday, today := s.Daily[len(s.Daily)-2], s.Daily[len(s.Daily)-1]
+	if today.Date != "2026-01-31" || today.Turns != 2 || today.InputTokens != 5000 ||
+		yesterday.Turns != 1 {
+		t.Errorf("unexpected daily stats: %+v, %+v", yesterday, today)
+	}
+
+	want := []claude.ToolStats{
+		{Name: "read_file", Calls: 2, Failed: 1},
+		{Name: "write_file", Calls: 1, DryRun: 1},
+	}
+	if len(s.Tools) != len(want) || s.Tools[0] != want[0] || s.Tools[1] != want[1] {
+		t.Errorf("tools = %+v, want %+v", s.Tools, want)
+	}
+}
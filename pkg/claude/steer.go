@@ -0,0 +1,111 @@
+package claude
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// steer.go - Steering a running turn (--steer)
+//
+// With --steer, a line typed on the terminal while the turn runs, "stop
+// editing foo.go, focus on the tests", goes to the model with the next
+// call, after the results of the tool calls it is waiting for. The run
+// changes course without being killed and restarted with a new prompt.
+// When the model answers before the line was sent, the turn goes on with
+// the line as a follow-up. Steering lines are part of the turn: they are
+// saved in its transcript like the tool results.
+
+// steerPrefix introduces a steering line to the model
+const steerPrefix = "The user interjects while you work: "
+
+// steering reads steering lines from the terminal while a turn runs
+type steering struct {
+	in    io.ReadCloser
+	lines chan string
+}
+
+// newSteering starts reading steering lines from in
+func newSteering(in io.ReadCloser) *steering {
+	s := &steering{in: in, lines: make(chan string, 16)}
+	go func() {
+		defer close(s.lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				fmt.Fprintln(os.Stderr, "Steering noted, it goes with the next call")
+				s.lines <- line
+			}
+		}
+	}()
+	return s
+}
+
+// startSteering starts reading steering lines for the turn of sess with
+// --steer. Without a terminal the turn runs without.
+func (s *session) startSteering() {
+	if !s.opts.Steer || s.opts.agentOf != "" || s.steer != nil {
+		return
+	}
+	f, err := openPromptInput()
+	if err != nil {
+		slog.Warn("--steer needs a terminal, running without", "err", err)
+		return
+	}
+	s.steer = newSteering(f)
+	if !s.opts.IsSilent() {
+		fmt.Fprintln(os.Stderr, "Steer the run: type a message and press Enter")
+	}
+}
+
+// stopSteering stops reading the terminal, so prompts after the turn get
+// what is typed
+func (s *session) stopSteering() {
+	if s.steer == nil {
+		return
+	}
+	if err := s.steer.in.Close(); err != nil {
+		slog.Debug("closing steering input", "err", err)
+	}
+	s.steer = nil
+}
+
+// SetSteering steers the turn of the session with lines, as if they were
+// typed before its first tool results (for tests)
+func (s *session) SetSteering(lines ...string) {
+	s.steer = &steering{in: io.NopCloser(strings.NewReader("")), lines: make(chan string, len(lines))}
+	for _, line := range lines {
+		s.steer.lines <- line
+	}
+	close(s.steer.lines)
+}
+
+// steering returns the steering lines typed since the last call as text
+// blocks, redacted
+func (s *session) steering() []ContentBlock {
+	if s.steer == nil {
+		return nil
+	}
+	var blocks []ContentBlock
+	for done := false; !done; {
+		select {
+		case line, ok := <-s.steer.lines:
+			if !ok {
+				s.steer.lines = nil // closed: a nil channel is never ready
+				done = true
+				break
+			}
+			slog.Info("steering", "message", line)
+			blocks = append(blocks, ContentBlock{Type: "text", Text: steerPrefix + line})
+		default:
+			done = true
+		}
+	}
+	redactBlocks(storage.Redactor(), blocks)
+	return blocks
+}
@@ -0,0 +1,66 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestSteer(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		responses []*llm.Response
+	}{
+		// Sent with the results of the tool calls under way
+		{"tool results", []*llm.Response{
+			toolUseResponse("toolu_1", "read_file", map[string]interface{}{"path": "main.go"}),
+			textResponse("Looking at the tests.", "end_turn"),
+		}},
+		// The model answered first: the turn goes on with it
+		{"follow-up", []*llm.Response{
+			textResponse("Edited foo.go.", "end_turn"),
+			textResponse("Looking at the tests.", "end_turn"),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, claudeDir := writeProject(t, map[string]string{"main.go": "package main\n"})
+			t.Chdir(dir)
+			t.Setenv("ANTHROPIC_API_KEY", "test-key")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+				Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+			})
+
+			opts := claude.NewOptions()
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.SetTool(claude.ToolRead)
+			sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mock := &scriptedLLM{responses: tc.responses}
+			sess.SetLLM(mock)
+			sess.SetSteering("stop editing foo.go, focus on the tests")
+			result, err := claude.ExecuteConversation(sess, "fix the bug")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.AssistantText() != "Looking at the tests." {
+				t.Errorf("answer = %q", result.AssistantText())
+			}
+			if len(mock.requests) != 2 {
+				t.Fatalf("%d calls, want 2", len(mock.requests))
+			}
+			got := lastContent(mock.requests[1])
+			if !strings.Contains(got, "focus on the tests") {
+				t.Errorf("second call lacks the steering:\n%s", got)
+			}
+			if tc.name == "tool results" && !strings.HasPrefix(got, "package main") {
+				t.Errorf("steering not after the tool result:\n%s", got)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package claude
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/marcopeereboom/go-claude/pkg/storage"
 )
@@ -26,17 +28,11 @@ func ReplayResponse(claudeDir string, opts *Options) error {
 		timestamp = opts.Replay
 	}
 
-	respPath := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", timestamp))
-	respBody, err := os.ReadFile(respPath)
+	responses, err := storage.LoadResponses(claudeDir, timestamp)
 	if err != nil {
 		return fmt.Errorf("loading response %s: %w", timestamp, err)
 	}
 
-	var responses []storage.APIResponse
-	if err := json.Unmarshal(respBody, &responses); err != nil {
-		return fmt.Errorf("parsing responses: %w", err)
-	}
-
 	if len(responses) == 0 {
 		return fmt.Errorf("no responses in file")
 	}
@@ -46,28 +42,182 @@ func ReplayResponse(claudeDir string, opts *Options) error {
 		return fmt.Errorf("getting working dir: %w", err)
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Replaying response: %s\n", timestamp)
+	slog.Info("replaying response", "timestamp", timestamp)
+	opts.startPatch(workingDir)
+
+	// Relative tool paths resolve against the directory of the original run
+	meta, err := storage.LoadTurnMeta(claudeDir, timestamp)
+	if err != nil {
+		slog.Warn("turn metadata unreadable", "timestamp", timestamp, "err", err)
+	} else if meta != nil {
+		slog.Info("original turn", "model", meta.Model, "provider", meta.Provider,
+			"tool", meta.Tool, "started", meta.Started)
+		if meta.WorkingDir != "" && meta.WorkingDir != workingDir {
+			slog.Warn("replaying in a different directory than the original run",
+				"original", meta.WorkingDir, "now", workingDir)
+		}
+	}
+
+	sel := newReplaySelector(opts)
+	defer sel.close()
+
+	if err := checkReplayState(responses, meta, sel, opts); err != nil {
+		return err
 	}
 
 	toolCount := 0
 	for respIdx, apiResp := range responses {
+		var selected []ContentBlock
 		for _, block := range apiResp.Content {
 			if block.Type != "tool_use" {
 				continue
 			}
-			toolCount++
-			if opts.IsVerbose() {
-				fmt.Fprintf(os.Stderr, "Iteration %d: %s\n", respIdx, block.Name)
+			ok, err := sel.want(block)
+			if err != nil {
+				return err
 			}
-			if _, err := ExecuteTool(block, workingDir, claudeDir, opts, timestamp); err != nil {
-				return fmt.Errorf("tool %s failed: %w", block.Name, err)
+			if !ok {
+				slog.Info("replay skip", "iteration", respIdx, "tool", block.Name, "id", block.ID)
+				continue
 			}
+			toolCount++
+			slog.Info("replay", "iteration", respIdx, "tool", block.Name, "id", block.ID)
+			selected = append(selected, block)
+		}
+		// Execute per iteration so multi-file writes stay transactional.
+		// Calls approved before a quit still run.
+		if _, err := ExecuteTools(selected, workingDir, claudeDir, opts, timestamp); err != nil {
+			return err
+		}
+		if sel.quit {
+			break
 		}
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Replayed %d tools\n", toolCount)
+	slog.Info("replay done", "tools", toolCount)
+	if opts.patch != nil {
+		return writePatch(opts, printPatch)
 	}
 	return nil
 }
+
+// openPromptInput opens the terminal for interactive prompts. stdin can't be
+// used because it is usually a pipe. Overridden in tests.
+var openPromptInput = openTerminal
+
+func openTerminal() (io.ReadCloser, error) {
+	return os.Open("/dev/tty")
+}
+
+// SetPromptInput answers interactive prompts from what open returns instead
+// of the terminal; nil restores the terminal (for tests)
+func SetPromptInput(open func() (io.ReadCloser, error)) {
+	if open == nil {
+		open = openTerminal
+	}
+	openPromptInput = open
+}
+
+// replaySelector decides which saved tool calls to re-execute, applying the
+// --only and --tool-ids filters and, with --interactive, asking per tool.
+type replaySelector struct {
+	only        map[string]bool
+	ids         map[string]bool
+	interactive bool
+	all         bool // user answered "a" - run the rest without asking
+	quit        bool // user answered "q" - stop replaying
+	in          *bufio.Reader
+	closer      io.Closer
+}
+
+func newReplaySelector(opts *Options) *replaySelector {
+	sel := &replaySelector{interactive: opts.ReplayInteractive}
+	if len(opts.ReplayOnly) > 0 {
+		sel.only = make(map[string]bool)
+		for _, name := range opts.ReplayOnly {
+			sel.only[name] = true
+		}
+	}
+	if len(opts.ReplayToolIDs) > 0 {
+		sel.ids = make(map[string]bool)
+		for _, id := range opts.ReplayToolIDs {
+			sel.ids[id] = true
+		}
+	}
+	return sel
+}
+
+func (s *replaySelector) close() {
+	if s.closer != nil {
+		s.closer.Close()
+	}
+}
+
+// match reports whether block passes the --only and --tool-ids filters
+func (s *replaySelector) match(block ContentBlock) bool {
+	if s.only != nil && !s.only[block.Name] {
+		return false
+	}
+	return s.ids == nil || s.ids[block.ID]
+}
+
+// want reports whether block should be executed.
+func (s *replaySelector) want(block ContentBlock) (bool, error) {
+	if s.quit || !s.match(block) {
+		return false, nil
+	}
+	if !s.interactive || s.all {
+		return true, nil
+	}
+
+	if s.in == nil {
+		f, err := openPromptInput()
+		if err != nil {
+			return false, fmt.Errorf("interactive replay needs a terminal: %w", err)
+		}
+		s.in = bufio.NewReader(f)
+		s.closer = f
+	}
+
+	for {
+		fmt.Fprintf(os.Stderr, "Run %s [%s]? [y]es/[n]o/[a]ll/[q]uit: ",
+			describeToolUse(block), block.ID)
+		line, err := s.in.ReadString('\n')
+		if err != nil && line == "" {
+			// EOF: treat as quit so we never execute unconfirmed tools
+			s.quit = true
+			return false, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no", "":
+			return false, nil
+		case "a", "all":
+			s.all = true
+			return true, nil
+		case "q", "quit":
+			s.quit = true
+			return false, nil
+		}
+	}
+}
+
+// describeToolUse renders a one-line summary of a tool call for prompts.
+func describeToolUse(block ContentBlock) string {
+	switch block.Name {
+	case "read_file", "write_file":
+		if path, ok := block.Input["path"].(string); ok {
+			return fmt.Sprintf("%s(%s)", block.Name, path)
+		}
+	case "bash_command":
+		if cmd, ok := block.Input["command"].(string); ok {
+			return fmt.Sprintf("%s(%q)", block.Name, cmd)
+		}
+	case "semantic_search":
+		if query, ok := block.Input["query"].(string); ok {
+			return fmt.Sprintf("%s(%q)", block.Name, query)
+		}
+	}
+	return block.Name
+}
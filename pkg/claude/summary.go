@@ -0,0 +1,194 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/router"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// RunSummary is the machine-readable outcome of a run (--summary-json)
+type RunSummary struct {
+	Model         string         `json:"model"`
+	Provider      string         `json:"provider"`
+	Iterations    int            `json:"iterations"`
+	InputTokens   int            `json:"input_tokens"`
+	OutputTokens  int            `json:"output_tokens"`
+	Cost          float64        `json:"cost"`
+	ToolsExecuted map[string]int `json:"tools_executed"` // tool name -> calls
+	FilesChanged  []string       `json:"files_changed"`
+	Verify        string         `json:"verify,omitempty"`   // last --verify outcome: passed, failed
+	Validate      string         `json:"validate,omitempty"` // last --validate outcome: passed, failed
+	ExitStatus    int            `json:"exit_status"`        // 0 = success
+	Error         string         `json:"error,omitempty"`
+	Alerts        []string       `json:"alerts,omitempty"` // spend alerts fired
+
+	Routing *router.Decision `json:"routing,omitempty"` // --explain-routing
+}
+
+// recordCall adds one LLM response to the summary
+func (r *RunSummary) recordCall(provider, model string, usage Usage) {
+	r.Provider = provider
+	r.Model = model
+	r.Iterations++
+	r.InputTokens += usage.InputTokens
+	r.OutputTokens += usage.OutputTokens
+	r.Cost += UsageCost(model, usage.InputTokens, usage.OutputTokens)
+}
+
+// recordTools adds the tool calls in content and the files successfully
+// written by them
+func (r *RunSummary) recordTools(content, results []ContentBlock, opts *Options) {
+	for _, block := range content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		if r.ToolsExecuted == nil {
+			r.ToolsExecuted = make(map[string]int)
+		}
+		r.ToolsExecuted[block.Name]++
+	}
+
+	for _, path := range writtenFiles(content, results, opts) {
+		if !containsString(r.FilesChanged, path) {
+			r.FilesChanged = append(r.FilesChanged, path)
+		}
+	}
+}
+
+// writtenFiles returns the paths write_file calls in content changed.
+// Dry-run and --output=patch writes change nothing and aren't listed.
+func writtenFiles(content, results []ContentBlock, opts *Options) []string {
+	if !opts.CanExecuteWrite() || opts.patch != nil {
+		return nil
+	}
+	failed := make(map[string]bool)
+	for _, res := range results {
+		failed[res.ToolUseID] = strings.HasPrefix(res.Content, "Error: ")
+	}
+
+	var paths []string
+	for _, block := range content {
+		if block.Type != "tool_use" || block.Name != "write_file" || failed[block.ID] {
+			continue
+		}
+		if path, _ := block.Input["path"].(string); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Summary returns the run summary, with err (the run's outcome) recorded
+// as exit status 1. A nil session (setup failed) yields just the error.
+func (s *session) Summary(err error) *RunSummary {
+	r := &RunSummary{}
+	if s != nil {
+		*r = s.summary
+		if r.Model == "" {
+			r.Model = s.model
+		}
+		if r.Provider == "" {
+			r.Provider = s.provider(s.model)
+		}
+	}
+	if r.ToolsExecuted == nil {
+		r.ToolsExecuted = map[string]int{}
+	}
+	r.FilesChanged = append([]string{}, r.FilesChanged...)
+	sort.Strings(r.FilesChanged)
+	if err != nil {
+		r.ExitStatus = 1
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Footer returns the one line account of a run shown after it on a
+// terminal: model, tokens, cost, iterations and elapsed time, and the
+// last spend alert
+func (r *RunSummary) Footer(elapsed time.Duration) string {
+	iterations := "iterations"
+	if r.Iterations == 1 {
+		iterations = "iteration"
+	}
+	footer := fmt.Sprintf("%s, %d/%d tokens, $%.4f, %d %s, %.1fs", r.Model,
+		r.InputTokens, r.OutputTokens, r.Cost, r.Iterations, iterations,
+		elapsed.Seconds())
+	if len(r.Alerts) > 0 {
+		footer += " (" + r.Alerts[len(r.Alerts)-1] + ")"
+	}
+	return footer
+}
+
+// provider names the backend serving model
+func (s *session) provider(model string) string {
+	if isFakeModel(model) {
+		return ProviderFake
+	}
+	if !isClaudeModel(model, s.opts.Provider) {
+		return ProviderOllama
+	}
+	if s.opts.Provider == "" {
+		return ProviderClaude
+	}
+	return s.opts.Provider
+}
+
+// turnMeta returns the metadata saved with the turn
+func (s *session) turnMeta() *storage.TurnMeta {
+	r := s.Summary(nil)
+	meta := &storage.TurnMeta{
+		Model:        r.Model,
+		Provider:     r.Provider,
+		Fallback:     len(s.fellBack) > 0,
+		Tool:         s.opts.Tool,
+		WorkingDir:   s.workingDir,
+		Iterations:   r.Iterations,
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
+		Cost:         r.Cost,
+		Context:      s.ragSources,
+		Title:        s.title,
+		Tags:         s.opts.Tags,
+		User:         UsageUser(s.config),
+		APIKeys:      s.apiKeys,
+		Prefill:      s.opts.Prefill,
+		WriteHashes:  s.opts.writeHashes,
+
+		SystemPromptSHA256: s.sysHash,
+		SystemSource:       s.sysSource,
+		BestOf:             s.bestOf,
+	}
+	if s.journal != nil {
+		meta.Started = s.journal.Started
+		meta.DurationMs = time.Since(s.journal.Started).Milliseconds()
+	}
+	return meta
+}
+
+// WriteSummary writes summary as JSON to path. Use /dev/fd/N to write to
+// an inherited file descriptor.
+func WriteSummary(path string, summary *RunSummary) error {
+	data, err := json.MarshalIndent(summary, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshaling summary: %w", err)
+	}
+	if err := storage.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+func TestRunSummary(t *testing.T) {
+	tests := []struct {
+		name      string
+		tool      string
+		wantFiles []string
+	}{
+		{"dry-run", claude.DefaultTool, []string{}},
+		{"write", claude.ToolWrite, []string{"a.txt", "b.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writes := &llm.Response{
+				Content: []claude.ContentBlock{
+					{Type: "tool_use", ID: "toolu_1", Name: "write_file",
+						Input: map[string]interface{}{"path": "b.txt", "content": "b"}},
+					{Type: "tool_use", ID: "toolu_2", Name: "write_file",
+						Input: map[string]interface{}{"path": "a.txt", "content": "a"}},
+				},
+				StopReason: "tool_use",
+				Usage:      llm.Usage{InputTokens: 1000, OutputTokens: 100},
+			}
+			// A failed write isn't a changed file
+			escape := &llm.Response{
+				Content: []claude.ContentBlock{
+					{Type: "tool_use", ID: "toolu_3", Name: "write_file",
+						Input: map[string]interface{}{"path": "../escape.txt", "content": "x"}},
+				},
+				StopReason: "tool_use",
+			}
+			done := textResponse("done", "end_turn")
+			done.Usage = llm.Usage{InputTokens: 2000, OutputTokens: 50}
+			mock := &scriptedLLM{responses: []*llm.Response{writes, escape, done}}
+
+			opts := claude.NewOptions()
+			opts.SetTool(tt.tool)
+			opts.SetVerbosity(claude.VerbositySilent)
+			_, _, summary, err := runScriptedSummary(t, opts, mock, "write files")
+			if err != nil {
+				t.Fatalf("ExecuteConversation: %v", err)
+			}
+
+			if summary.Model != claude.DefaultModel || summary.Provider != claude.ProviderClaude {
+				t.Errorf("model/provider = %s/%s", summary.Model, summary.Provider)
+			}
+			if summary.Iterations != 3 {
+				t.Errorf("Iterations = %d, want 3", summary.Iterations)
+			}
+			if summary.InputTokens != 3000 || summary.OutputTokens != 150 {
+				t.Errorf("tokens = %d/%d, want 3000/150",
+					summary.InputTokens, summary.OutputTokens)
+			}
+			want := claude.UsageCost(claude.DefaultModel, 3000, 150)
+			if summary.Cost != want {
+				t.Errorf("Cost = %f, want %f", summary.Cost, want)
+			}
+			if summary.ToolsExecuted["write_file"] != 3 {
+				t.Errorf("ToolsExecuted = %v, want 3 write_file", summary.ToolsExecuted)
+			}
+			if !reflect.DeepEqual(summary.FilesChanged, tt.wantFiles) {
+				t.Errorf("FilesChanged = %v, want %v", summary.FilesChanged, tt.wantFiles)
+			}
+			if summary.ExitStatus != 0 || summary.Error != "" {
+				t.Errorf("exit = %d %q, want success", summary.ExitStatus, summary.Error)
+			}
+		})
+	}
+}
+
+func TestRunSummaryFailure(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		textResponse("I can't help with that.", "refusal"),
+	}}
+	_, _, summary, err := runScriptedSummary(t, claude.NewOptions(), mock, "hi")
+	if !errors.Is(err, claude.ErrRefusal) {
+		t.Fatalf("err = %v, want ErrRefusal", err)
+	}
+	if summary.ExitStatus != 1 || summary.Error == "" {
+		t.Errorf("exit = %d %q, want failure", summary.ExitStatus, summary.Error)
+	}
+	if summary.Iterations != 1 {
+		t.Errorf("Iterations = %d, want 1", summary.Iterations)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	in := &claude.RunSummary{
+		Model:         claude.DefaultModel,
+		Provider:      claude.ProviderClaude,
+		Iterations:    1,
+		ToolsExecuted: map[string]int{"read_file": 2},
+		FilesChanged:  []string{},
+	}
+	if err := claude.WriteSummary(path, in); err != nil {
+		t.Fatalf("WriteSummary: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	for _, key := range []string{
+		"model", "provider", "iterations", "input_tokens", "output_tokens",
+		"cost", "tools_executed", "files_changed", "exit_status",
+	} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("summary missing %q", key)
+		}
+	}
+	if _, ok := got["error"]; ok {
+		t.Error("error present for a successful run")
+	}
+}
+
+func TestRunSummaryFooter(t *testing.T) {
+	r := &claude.RunSummary{Model: "claude-sonnet-4-5", Iterations: 2,
+		InputTokens: 1200, OutputTokens: 340, Cost: 0.0123}
+	want := "claude-sonnet-4-5, 1200/340 tokens, $0.0123, 2 iterations, 14.3s"
+	if got := r.Footer(14300 * time.Millisecond); got != want {
+		t.Errorf("Footer = %q, want %q", got, want)
+	}
+	r.Iterations = 1
+	if got := r.Footer(time.Second); got != "claude-sonnet-4-5, 1200/340 tokens, $0.0123, 1 iteration, 1.0s" {
+		t.Errorf("Footer = %q", got)
+	}
+}
+
+func TestRunSummaryRouting(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.ExplainRouting = true
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("done", "end_turn")}}
+	_, _, summary, err := runScriptedSummary(t, opts, mock, "refactor the parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Routing == nil {
+		t.Fatal("no routing decision in the summary")
+	}
+	if summary.Routing.Provider != claude.ProviderClaude || summary.Routing.Rule != "complex" {
+		t.Errorf("routing = %+v", summary.Routing)
+	}
+
+	data, _ := json.Marshal(summary)
+	var got struct {
+		Routing struct {
+			Rule     string `json:"rule"`
+			Analysis struct {
+				Complexity string `json:"complexity"`
+			} `json:"analysis"`
+		} `json:"routing"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Routing.Rule != "complex" || got.Routing.Analysis.Complexity != "complex" {
+		t.Errorf("routing JSON %s", data)
+	}
+}
@@ -0,0 +1,111 @@
+package claude
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// sysprompt.go - System prompt pinning (--show-system)
+//
+// The system prompt comes from the first of --system, CLAUDE_SYSTEM_PROMPT,
+// config.json and the built-in default that is set. Changing the flag, the
+// environment or the config between turns silently changes how the
+// conversation goes on, so every turn records the hash and source of its
+// prompt and the next turn warns when they differ.
+//
+// Every --system-file adds its content as a block of its own after the
+// prompt. Claude gets the blocks as the system array, so their boundaries
+// survive; the hash covers them all.
+
+// System prompt sources, in priority order
+const (
+	SystemSourceFlag    = "--system"
+	SystemSourceEnv     = "CLAUDE_SYSTEM_PROMPT"
+	SystemSourceConfig  = "config.json"
+	SystemSourceDefault = "default"
+)
+
+// ResolveSystemPrompt returns the system prompt and where it came from
+func ResolveSystemPrompt(flagPrompt, cfgPrompt, defaultSystemPrompt string) (string, string) {
+	if flagPrompt != "" {
+		return flagPrompt, SystemSourceFlag
+	}
+	if envPrompt := os.Getenv("CLAUDE_SYSTEM_PROMPT"); envPrompt != "" {
+		return envPrompt, SystemSourceEnv
+	}
+	if cfgPrompt != "" {
+		return cfgPrompt, SystemSourceConfig
+	}
+	return defaultSystemPrompt, SystemSourceDefault
+}
+
+// ComposeSystemPrompt returns the system prompt blocks of prompt and the
+// --system-file paths: prompt, then the content of every file in order.
+// Empty files add no block.
+func ComposeSystemPrompt(prompt string, files []string) ([]llm.SystemBlock, error) {
+	texts := []string{prompt}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("--system-file: %w", err)
+		}
+		texts = append(texts, strings.TrimSpace(string(data)))
+	}
+	return llm.TextBlocks(texts...), nil
+}
+
+// SystemPromptHash returns the hex SHA-256 of prompt
+func SystemPromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// warnSystemDrift warns when the last turn was answered under another
+// system prompt than hash. The workspace and project tree sections added
+// at run time follow the project and aren't part of the hash.
+func warnSystemDrift(claudeDir, hash, source string) {
+	pairs, err := storage.ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return
+	}
+	for i := len(pairs) - 1; i >= 0; i-- {
+		meta, err := storage.LoadTurnMeta(claudeDir, pairs[i])
+		if err != nil || meta == nil {
+			continue
+		}
+		// Turns from before pinning have no hash to compare
+		if meta.SystemPromptSHA256 != "" && meta.SystemPromptSHA256 != hash {
+			slog.Warn("system prompt changed since the last turn (see --show-system)",
+				"source", source, "was", meta.SystemSource, "turn", pairs[i])
+		}
+		return
+	}
+}
+
+// ShowSystemCommand prints the system prompt the next turn would use and
+// where it came from, the blocks of files included
+func ShowSystemCommand(claudeDir, flagPrompt string, files []string,
+	defaultSystemPrompt string) error {
+	cfg := storage.LoadOrCreateConfig(filepath.Join(claudeDir, "config.json"))
+	prompt, source := ResolveSystemPrompt(flagPrompt, cfg.SystemPrompt, defaultSystemPrompt)
+	blocks, err := ComposeSystemPrompt(prompt, files)
+	if err != nil {
+		return err
+	}
+	prompt = llm.JoinSystem(blocks)
+	if len(files) > 0 {
+		source = fmt.Sprintf("%s and %d --system-file", source, len(files))
+	}
+	fmt.Fprintf(os.Stderr, "System prompt from %s (sha256 %s)\n", source,
+		SystemPromptHash(prompt)[:12])
+	_, err = fmt.Println(prompt)
+	return err
+}
@@ -0,0 +1,111 @@
+package claude_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestSystemPromptDrift(t *testing.T) {
+	dir, claudeDir := writeProject(t, nil)
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	t.Setenv("CLAUDE_SYSTEM_PROMPT", "")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	turn := func() *storage.TurnMeta {
+		t.Helper()
+		opts := claude.NewOptions()
+		opts.SetVerbosity(claude.VerbositySilent)
+		sess, err := claude.InitSession(opts, claudeDir, "http://unused", "default prompt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess.SetLLM(&scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}})
+		result, err := claude.ExecuteConversation(sess, "hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		noOutput := func(string, bool, string, []byte) error { return nil }
+		if err := claude.FinalizeSession(sess, result, storage.SaveJSON, noOutput); err != nil {
+			t.Fatal(err)
+		}
+		pairs, _ := storage.ListRequestResponsePairs(claudeDir)
+		meta, err := storage.LoadTurnMeta(claudeDir, pairs[len(pairs)-1])
+		if err != nil || meta == nil {
+			t.Fatalf("turn metadata: %v", err)
+		}
+		return meta
+	}
+
+	meta := turn()
+	if meta.SystemSource != claude.SystemSourceDefault ||
+		meta.SystemPromptSHA256 != claude.SystemPromptHash("default prompt") {
+		t.Errorf("recorded %s %s, want the default prompt", meta.SystemSource,
+			meta.SystemPromptSHA256)
+	}
+	turn()
+	if strings.Contains(logs.String(), "system prompt changed") {
+		t.Errorf("warned about an unchanged prompt:\n%s", logs.String())
+	}
+
+	t.Setenv("CLAUDE_SYSTEM_PROMPT", "be terse")
+	if meta = turn(); meta.SystemSource != claude.SystemSourceEnv {
+		t.Errorf("recorded source %s, want %s", meta.SystemSource, claude.SystemSourceEnv)
+	}
+	if !strings.Contains(logs.String(), "system prompt changed") ||
+		!strings.Contains(logs.String(), "was=default") {
+		t.Errorf("no drift warning:\n%s", logs.String())
+	}
+}
+
+func TestSystemFiles(t *testing.T) {
+	dir, claudeDir := writeProject(t, map[string]string{
+		"style.md": "Use tabs.\n",
+		"empty.md": "",
+	})
+	t.Chdir(dir)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	t.Setenv("CLAUDE_SYSTEM_PROMPT", "")
+	storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+		Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+	})
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.SystemFiles = []string{"style.md", "empty.md"}
+	opts.EnvContext = true
+	sess, err := claude.InitSession(opts, claudeDir, "http://unused", "default prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+	sess.SetLLM(mock)
+	if _, err := claude.ExecuteConversation(sess, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	blocks := mock.requests[0].SystemBlocks
+	if len(blocks) != 3 || blocks[0].Text != "default prompt" ||
+		blocks[1].Text != "Use tabs." || !strings.Contains(blocks[2].Text, "Environment") {
+		t.Fatalf("system blocks = %+v", blocks)
+	}
+	if got := mock.requests[0].SystemText(); got != mock.requests[0].System {
+		t.Errorf("joined blocks %q, System %q", got, mock.requests[0].System)
+	}
+
+	opts.SystemFiles = []string{"missing.md"}
+	if _, err := claude.InitSession(opts, claudeDir, "http://unused", "default prompt"); err == nil {
+		t.Error("missing --system-file: no error")
+	}
+}
@@ -393,6 +393,40 @@ func TestBashCommandValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "not in whitelist: python",
 		},
+		{
+			name:    "not in whitelist after newline",
+			command: "echo a\npython script.py",
+			wantErr: true,
+			errMsg:  "not in whitelist: python",
+		},
+		{
+			name:    "substitution",
+			command: "echo $(python script.py)",
+			wantErr: true,
+			errMsg:  "command substitution not allowed",
+		},
+		{
+			name:    "backtick substitution",
+			command: "echo `python script.py`",
+			wantErr: true,
+			errMsg:  "command substitution not allowed",
+		},
+		{
+			name:    "quoted substitution",
+			command: `echo "$(python script.py)"`,
+			wantErr: true,
+			errMsg:  "command substitution not allowed",
+		},
+		{
+			name:    "single-quoted substitution is an argument",
+			command: "echo '$(date) `date`'",
+			wantErr: false,
+		},
+		{
+			name:    "quoted pipe is an argument",
+			command: `git log --format="%h|%s"`,
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -416,3 +450,155 @@ func TestBashCommandValidation(t *testing.T) {
 		})
 	}
 }
+
+// TestSymlinkEscapes verifies links inside the project can't reach outside
+func TestSymlinkEscapes(t *testing.T) {
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	links := map[string]string{
+		"file":     secret,
+		"dir":      outside,
+		"dangling": filepath.Join(outside, "new.txt"),
+		"inside":   filepath.Join(tmpDir, "real.txt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tmpDir, name)); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(tmpDir, "real.txt"), []byte("real"), 0o644)
+
+	opts := claude.NewOptions()
+	opts.SetTool("all")
+	opts.SetVerbosity(claude.VerbositySilent)
+	run := func(name, path string, write bool) string {
+		t.Helper()
+		block := claude.ContentBlock{Type: "tool_use", ID: name, Name: "read_file",
+			Input: map[string]interface{}{"path": path}}
+		if write {
+			block.Name = "write_file"
+			block.Input["content"] = "pwned"
+		}
+		result, err := claude.ExecuteTool(block, tmpDir, claudeDir, opts, "20260105_120000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content
+	}
+
+	escapes := []struct {
+		name  string
+		path  string
+		write bool
+	}{
+		{"read link to file", filepath.Join(tmpDir, "file"), false},
+		{"read through link to dir", filepath.Join(tmpDir, "dir", "secret.txt"), false},
+		{"write link to file", filepath.Join(tmpDir, "file"), true},
+		{"write new file in linked dir", filepath.Join(tmpDir, "dir", "new.txt"), true},
+		{"write dangling link", filepath.Join(tmpDir, "dangling"), true},
+		// The kernel takes .. from where the link points, not lexically
+		{"write through link and up", tmpDir + "/dir/../escaped.txt", true},
+		{"read through link and up", tmpDir + "/dir/../" + filepath.Base(outside) + "/secret.txt", false},
+	}
+	for _, tt := range escapes {
+		if got := run(tt.name, tt.path, tt.write); !strings.Contains(got, "outside project") {
+			t.Errorf("%s: got %q, want path outside project", tt.name, got)
+		}
+	}
+	if data, _ := os.ReadFile(secret); string(data) != "secret" {
+		t.Errorf("secret.txt changed to %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("file created outside the project")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(outside), "escaped.txt")); err == nil {
+		t.Error("file created outside the project through link/..")
+	}
+
+	// Links that stay inside are fine
+	if got := run("read inside link", filepath.Join(tmpDir, "inside"), false); got != "real" {
+		t.Errorf("read through inside link = %q", got)
+	}
+
+	// Redirects are checked the same way
+	block := claude.ContentBlock{Type: "tool_use", ID: "redirect", Name: "bash_command",
+		Input: map[string]interface{}{"command": "echo pwned > dir/x.txt", "reason": "test"}}
+	result, err := claude.ExecuteTool(block, tmpDir, claudeDir, opts, "20260105_120000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Content, "redirect outside project") {
+		t.Errorf("redirect through link: %q", result.Content)
+	}
+	block.Input["command"] = "echo ok > out.txt 2>/dev/null"
+	result, _ = claude.ExecuteTool(block, tmpDir, claudeDir, opts, "20260105_120000")
+	if !strings.Contains(result.Content, "Exit code: 0") {
+		t.Errorf("redirect inside the project: %q", result.Content)
+	}
+}
+
+// TestBashOutputTargets verifies redirections and tee only write in the project
+func TestBashOutputTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	claudeDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := claude.NewOptions() // dry-run: validation only
+	opts.SetVerbosity(claude.VerbositySilent)
+
+	tests := []struct {
+		command string
+		errMsg  string // "" = allowed
+	}{
+		{"echo hi > out.txt", ""},
+		{"echo hi >> sub/out.txt", ""},
+		{"go test ./pkg 2>&1 | tee test.log", ""},
+		{"go vet ./pkg &> vet.log", ""},
+		{"echo hi >/dev/null 2>/dev/null", ""},
+		{"echo 'a > /etc/passwd'", ""},
+		{`grep ">" main.go`, ""},
+		{"echo hi > /etc/passwd", "redirect outside project"},
+		{"echo hi >/tmp/x", "redirect outside project"},
+		{"echo hi 2>>/tmp/x", "redirect outside project"},
+		{"echo hi &>/tmp/x", "redirect outside project"},
+		{"echo hi >&/tmp/x", "redirect outside project"},
+		{"echo hi | tee -a /tmp/x", "redirect outside project"},
+		{"echo hi | tee ok.txt /tmp/x", "redirect outside project"},
+		{`echo hi > "/tmp/x"`, "redirect outside project"},
+		// A newline or parenthesis starts a command like a pipe
+		{"echo a\ntee /tmp/x", "redirect outside project"},
+		{"echo a & tee /tmp/x", "redirect outside project"},
+		{"(tee /tmp/x)", "redirect outside project"},
+		{"echo $(tee /tmp/x)", "command substitution not allowed"},
+		{`echo "$(tee /etc/x)"`, "command substitution not allowed"},
+		{"echo `tee ~/.bashrc`", "command substitution not allowed"},
+		{"echo \"`tee ~/.bashrc`\"", "command substitution not allowed"},
+		{"echo hi > $HOME/x", "must be a plain path"},
+		{"echo hi > ~/x", "must be a plain path"},
+		{"echo hi > *.go", "must be a plain path"},
+		{"echo hi > sub", "not a regular file"},
+		{"echo hi >", "redirect without target"},
+		{"echo 'oops > x", "unterminated quote"},
+	}
+	for _, tt := range tests {
+		block := claude.ContentBlock{Type: "tool_use", ID: "b", Name: "bash_command",
+			Input: map[string]interface{}{"command": tt.command, "reason": "test"}}
+		result, err := claude.ExecuteTool(block, tmpDir, claudeDir, opts, "20260105_120000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case tt.errMsg == "" && !strings.HasPrefix(result.Content, "Dry-run"):
+			t.Errorf("%q: got %q, want it allowed", tt.command, result.Content)
+		case tt.errMsg != "" && !strings.Contains(result.Content, tt.errMsg):
+			t.Errorf("%q: got %q, want %q", tt.command, result.Content, tt.errMsg)
+		}
+	}
+}
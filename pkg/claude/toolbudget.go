@@ -0,0 +1,96 @@
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// toolbudget.go - Per-turn tool call budgets (--tool-budget)
+//
+// A local model stuck in a loop calls the same tool again and again, each
+// result filling the context further. A budget caps the calls of a tool
+// per turn: calls over it aren't run and get a tool_result error telling
+// the model to finish with what it has. The key * caps the calls of all
+// tools together.
+
+// ToolBudgetAll is the budget key of all tools together
+const ToolBudgetAll = "*"
+
+// ParseToolBudgets parses budgets like read_file=20,bash_command=5,*=40
+func ParseToolBudgets(s string) (map[string]int, error) {
+	budgets := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, n, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("tool budget %q: want TOOL=N", part)
+		}
+		calls, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || calls < 1 {
+			return nil, fmt.Errorf("tool budget %q: want a count of at least 1", part)
+		}
+		budgets[strings.TrimSpace(name)] = calls
+	}
+	return budgets, nil
+}
+
+// FormatToolBudgets formats budgets the way ParseToolBudgets reads them
+func FormatToolBudgets(budgets map[string]int) string {
+	parts := make([]string, 0, len(budgets))
+	for name, n := range budgets {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// validateToolBudgets rejects budgets of tools that don't exist
+func validateToolBudgets(budgets map[string]int) error {
+	for name := range budgets {
+		if _, ok := tools.Lookup(name); !ok && name != ToolBudgetAll {
+			return fmt.Errorf("--tool-budget: unknown tool %q", name)
+		}
+	}
+	return nil
+}
+
+// toolBudget counts the tool calls of a turn against the budgets
+type toolBudget struct {
+	budgets map[string]int
+	used    map[string]int
+	total   int
+}
+
+// startToolBudget starts counting the tool calls of a turn. Copies of o
+// made afterwards share the count.
+func (o *Options) startToolBudget() {
+	o.toolBudget = nil
+	if len(o.ToolBudgets) > 0 {
+		o.toolBudget = &toolBudget{budgets: o.ToolBudgets, used: make(map[string]int)}
+	}
+}
+
+// take counts a call of tool and returns why it may not run, or "" when
+// it is within its budgets
+func (b *toolBudget) take(tool string) string {
+	if b == nil {
+		return ""
+	}
+	if n, ok := b.budgets[tool]; ok && b.used[tool] >= n {
+		return fmt.Sprintf("%s budget exhausted: at most %d %s calls per turn. "+
+			"Don't call %s again; continue with what you have, use other tools "+
+			"or answer now.", tool, n, tool, tool)
+	}
+	if n, ok := b.budgets[ToolBudgetAll]; ok && b.total >= n {
+		return fmt.Sprintf("tool budget exhausted: at most %d tool calls per turn. "+
+			"Answer now with what you have.", n)
+	}
+	b.used[tool]++
+	b.total++
+	return ""
+}
@@ -0,0 +1,66 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+func TestParseToolBudgets(t *testing.T) {
+	budgets, err := claude.ParseToolBudgets("read_file=20, bash_command=5,*=40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := claude.FormatToolBudgets(budgets); s != "*=40,bash_command=5,read_file=20" {
+		t.Errorf("budgets = %s", s)
+	}
+	for _, bad := range []string{"read_file", "read_file=0", "read_file=x"} {
+		if _, err := claude.ParseToolBudgets(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+func TestToolBudget(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.ToolBudgets = map[string]int{"read_file": 2}
+	read := func(id string) claude.ContentBlock {
+		return claude.ContentBlock{
+			Type: "tool_use", ID: id, Name: "read_file",
+			Input: map[string]interface{}{"path": "a.txt"},
+		}
+	}
+	mock := &scriptedLLM{responses: []*llm.Response{
+		{Content: []claude.ContentBlock{read("t1")}, StopReason: "tool_use"},
+		{Content: []claude.ContentBlock{read("t2"), read("t3")}, StopReason: "tool_use"},
+		textResponse("done", "end_turn"),
+	}}
+	_, _, err := runScripted(t, opts, mock, "read it")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The budget spans iterations: the third call is refused
+	results := mock.requests[2].Messages[len(mock.requests[2].Messages)-1].Content
+	if len(results) != 2 || results[1].ToolUseID != "t3" {
+		t.Fatalf("unexpected tool results %+v", results)
+	}
+	if strings.Contains(results[0].Content, "budget") {
+		t.Errorf("call within budget answered with %q", results[0].Content)
+	}
+	if !strings.HasPrefix(results[1].Content, "Error: read_file budget exhausted") {
+		t.Errorf("call over budget answered with %q", results[1].Content)
+	}
+}
+
+func TestToolBudgetUnknownTool(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.ToolBudgets = map[string]int{"read_files": 2}
+	if _, err := claude.InitSession(opts, t.TempDir(), "http://unused", "system"); err == nil ||
+		!strings.Contains(err.Error(), "read_files") {
+		t.Errorf("err = %v, want unknown tool", err)
+	}
+}
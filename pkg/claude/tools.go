@@ -2,15 +2,23 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
+	"github.com/marcopeereboom/go-claude/pkg/llm"
 	"github.com/marcopeereboom/go-claude/pkg/storage"
+	"github.com/marcopeereboom/go-claude/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Command whitelist for bash_command tool
@@ -27,14 +35,69 @@ var allowedCommands = map[string]bool{
 	"date": true,
 	"git":  true, // validated separately
 	"go":   true, // all go subcommands allowed
+	"tee":  true, // targets validated like redirects
 }
 
+// GetTools returns the tools offered to the LLM: the built-ins plus any
+// registered with RegisterTool
 func GetTools(opts *Options) []Tool {
 	if !opts.CanUseTools() {
 		return nil
 	}
+	return tools.Available(opts)
+}
+
+// forceToolChoice returns the tool_choice of --force-tool: auto, any, none
+// or the name of one of tools. Empty leaves the provider default.
+func forceToolChoice(force string, tools []Tool) (*llm.ToolChoice, error) {
+	switch force {
+	case "":
+		return nil, nil
+	case llm.ToolChoiceAuto, llm.ToolChoiceAny, llm.ToolChoiceNone:
+		if force == llm.ToolChoiceAny && len(tools) == 0 {
+			return nil, fmt.Errorf("--force-tool=any: no tools enabled (--tool=%s)", ToolNone)
+		}
+		return &llm.ToolChoice{Type: force}, nil
+	}
 
-	return []Tool{{
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Name == force {
+			return &llm.ToolChoice{Type: llm.ToolChoiceTool, Name: force}, nil
+		}
+		names = append(names, tool.Name)
+	}
+	return nil, fmt.Errorf("--force-tool=%s: no such tool (want auto, any, none or one of: %s)",
+		force, strings.Join(names, ", "))
+}
+
+// sequentialToolUse splits content for --sequential-tools: run keeps the
+// first tool call (and the other blocks), skipped answers every further
+// call with an error asking the model to make it again. Providers that
+// ignore disable_parallel_tool_use get the same one-call-at-a-time loop.
+func sequentialToolUse(content []ContentBlock) (run, skipped []ContentBlock) {
+	for _, block := range content {
+		if block.Type != "tool_use" {
+			run = append(run, block)
+			continue
+		}
+		if !hasToolUse(run) {
+			run = append(run, block)
+			continue
+		}
+		skipped = append(skipped, ContentBlock{
+			Type:      "tool_result",
+			ToolUseID: block.ID,
+			Content: fmt.Sprintf("Error: %s not executed: only one tool call per "+
+				"response is allowed; call it again after this result", block.Name),
+		})
+	}
+	return run, skipped
+}
+
+// builtinTools are the tools implemented in this package
+func builtinTools() []ToolExecutor {
+	schemas := []Tool{{
 		Name:        "read_file",
 		Description: "Read the contents of a file",
 		InputSchema: map[string]interface{}{
@@ -61,6 +124,11 @@ func GetTools(opts *Options) []Tool {
 					"type":        "string",
 					"description": "Content to write to the file",
 				},
+				"complete": map[string]string{
+					"type": "boolean",
+					"description": "Set only when a write was refused as elided or " +
+						"truncated but the content is complete as it is",
+				},
 			},
 			"required": []string{"path", "content"},
 		},
@@ -68,7 +136,7 @@ func GetTools(opts *Options) []Tool {
 		Name: "bash_command",
 		Description: `Execute a bash command in the working directory.
 
-Allowed commands: ls, cat, grep, find, head, tail, wc, echo, pwd, date
+Allowed commands: ls, cat, grep, find, head, tail, wc, echo, pwd, date, tee
 Also allowed: git (log, diff, show, status, blame) and go (all subcommands)
 Pipes and safe redirects (to working dir only) are permitted.
 
@@ -90,23 +158,42 @@ Use 'reason' to explain why this command is needed (for audit trail).`,
 			"required": []string{"command", "reason"},
 		},
 	}}
+	run := map[string]builtinFunc{
+		"read_file":    ExecuteReadFile,
+		"write_file":   ExecuteWriteFile,
+		"bash_command": ExecuteBashCommand,
+	}
+
+	executors := make([]ToolExecutor, 0, len(schemas)+1)
+	for _, schema := range schemas {
+		executors = append(executors, &builtinTool{
+			schema: schema,
+			run:    run[schema.Name],
+		})
+	}
+	return append(executors, getToolResultTool, semanticSearchTool{}, spawnAgentTool{})
 }
 
+// ExecuteTool runs toolUse with the executor registered for its name
 func ExecuteTool(toolUse ContentBlock, workingDir string, claudeDir string,
 	opts *Options, conversationID string,
 ) (ContentBlock, error) {
-	switch toolUse.Name {
-	case "read_file":
-		return ExecuteReadFile(toolUse, workingDir, claudeDir, opts, conversationID)
-	case "write_file":
-		return ExecuteWriteFile(toolUse, workingDir, claudeDir, opts, conversationID)
-	case "bash_command":
-		return ExecuteBashCommand(toolUse, workingDir, claudeDir, opts,
-			conversationID)
-	default:
+	return executeTool(context.Background(), ToolCall{
+		Use:            toolUse,
+		WorkingDir:     workingDir,
+		ClaudeDir:      claudeDir,
+		Opts:           opts,
+		ConversationID: conversationID,
+	})
+}
+
+func executeTool(ctx context.Context, call ToolCall) (ContentBlock, error) {
+	executor, ok := tools.Lookup(call.Use.Name)
+	if !ok {
 		return ContentBlock{}, fmt.Errorf("unknown tool: %s",
-			toolUse.Name)
+			call.Use.Name)
 	}
+	return executor.Execute(ctx, call)
 }
 
 func ExecuteReadFile(toolUse ContentBlock, workingDir string, claudeDir string,
@@ -122,16 +209,30 @@ func ExecuteReadFile(toolUse ContentBlock, workingDir string, claudeDir string,
 		return makeToolError(toolUse.ID, "path must be a string")
 	}
 
-	if !isSafePath(path, workingDir) {
-		errMsg := fmt.Sprintf("path outside project: %s", path)
+	if errMsg := pathError(path, workingDir, false); errMsg != "" {
 		logAuditEntry(claudeDir, "read_file", toolUse.Input, map[string]interface{}{
 			"error": errMsg,
 		}, false, conversationID, startTime, false)
 		return makeToolError(toolUse.ID, errMsg)
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Tool: read_file(%s)\n", path)
+	slog.Info("tool", "name", "read_file", "path", path)
+
+	// --output=patch: the model sees what it wrote
+	if opts.patch != nil {
+		if content, ok := opts.patch.content(path); ok {
+			logAuditEntry(claudeDir, "read_file", toolUse.Input, map[string]interface{}{
+				"success": true,
+				"path":    path,
+				"size":    len(content),
+				"patched": true,
+			}, true, conversationID, startTime, false)
+			return ContentBlock{
+				Type:      "tool_result",
+				ToolUseID: toolUse.ID,
+				Content:   content,
+			}, nil
+		}
 	}
 
 	content, err := os.ReadFile(path)
@@ -176,15 +277,30 @@ func ExecuteWriteFile(toolUse ContentBlock, workingDir string, claudeDir string,
 		return makeToolError(toolUse.ID, "content must be a string")
 	}
 
-	if !isSafePath(path, workingDir) {
-		errMsg := fmt.Sprintf("path outside project: %s", path)
+	if errMsg := pathError(path, workingDir, true); errMsg != "" {
+		logAuditEntry(claudeDir, "write_file", toolUse.Input, map[string]interface{}{
+			"error": errMsg,
+		}, false, conversationID, startTime, false)
+		return makeToolError(toolUse.ID, errMsg)
+	}
+
+	old, err := os.ReadFile(path)
+	existed := err == nil
+	complete, _ := toolUse.Input["complete"].(bool)
+	if errMsg := writeContentError(path, content, old, complete); errMsg != "" {
 		logAuditEntry(claudeDir, "write_file", toolUse.Input, map[string]interface{}{
 			"error": errMsg,
 		}, false, conversationID, startTime, false)
 		return makeToolError(toolUse.ID, errMsg)
 	}
 
-	old, _ := os.ReadFile(path)
+	content, formatNote := formatWrite(path, content, opts)
+	opts.recordPreWrite(toolUse.ID, old, existed)
+
+	if opts.patch != nil {
+		return patchWriteFile(toolUse, path, content, claudeDir, opts,
+			conversationID, startTime)
+	}
 
 	// Only show diff in normal/verbose mode
 	if !opts.IsSilent() {
@@ -194,6 +310,7 @@ func ExecuteWriteFile(toolUse ContentBlock, workingDir string, claudeDir string,
 
 	if !opts.CanExecuteWrite() {
 		fmt.Fprintf(os.Stderr, "(dry-run: use --tool=write to apply)\n\n")
+		opts.recordPending(path, content)
 		logAuditEntry(claudeDir, "write_file", toolUse.Input, map[string]interface{}{
 			"dry_run": true,
 			"path":    path,
@@ -207,11 +324,9 @@ func ExecuteWriteFile(toolUse ContentBlock, workingDir string, claudeDir string,
 		}, nil
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Tool: write_file(%s)\n", path)
-	}
+	slog.Info("tool", "name", "write_file", "path", path)
 
-	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+	if err := storage.WriteFile(path, []byte(content), 0o644); err != nil {
 		logAuditEntry(claudeDir, "write_file", toolUse.Input, map[string]interface{}{
 			"error": err.Error(),
 		}, false, conversationID, startTime, false)
@@ -227,7 +342,7 @@ func ExecuteWriteFile(toolUse ContentBlock, workingDir string, claudeDir string,
 	return ContentBlock{
 		Type:      "tool_result",
 		ToolUseID: toolUse.ID,
-		Content:   fmt.Sprintf("Successfully wrote to %s", path),
+		Content:   fmt.Sprintf("Successfully wrote to %s", path) + formatNote,
 	}, nil
 }
 
@@ -255,6 +370,10 @@ func ExecuteBashCommand(toolUse ContentBlock, workingDir string, claudeDir strin
 		return logAndReturnError(toolUse.ID, claudeDir, "bash_command",
 			toolUse.Input, err.Error(), conversationID, startTime)
 	}
+	if err := validateOutputTargets(command, workingDir); err != nil {
+		return logAndReturnError(toolUse.ID, claudeDir, "bash_command",
+			toolUse.Input, err.Error(), conversationID, startTime)
+	}
 
 	// Dry-run mode: show what would execute
 	if !opts.CanExecuteCommand() {
@@ -280,21 +399,21 @@ func ExecuteBashCommand(toolUse ContentBlock, workingDir string, claudeDir strin
 		}, nil
 	}
 
-	if opts.IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Tool: bash_command(%q)\n", command)
-	}
+	slog.Info("tool", "name", "bash_command", "command", command)
 
 	// Execute command with timeout
 	ctx, cancel := context.WithTimeout(context.Background(),
 		BashCommandTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	argv := limitedCommand(command, commandLimits)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
 	cmd.Dir = workingDir
 
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout := &cappedBuffer{max: commandLimits.MaxOutputBytes}
+	stderr := &cappedBuffer{max: commandLimits.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	err := cmd.Run()
 	exitCode := 0
@@ -369,15 +488,14 @@ func ValidateCommand(command string) error {
 		}
 	}
 
-	// Parse commands (handle pipes)
-	pipePattern := regexp.MustCompile(`\s*\|\s*`)
-	commands := pipePattern.Split(command, -1)
+	// Every command counts: pipeline segments, and the commands after a
+	// newline, & or parenthesis
+	commands, err := commandSegments(command)
+	if err != nil {
+		return err
+	}
 
-	for _, cmd := range commands {
-		parts := strings.Fields(cmd)
-		if len(parts) == 0 {
-			continue
-		}
+	for _, parts := range commands {
 		firstWord := parts[0]
 
 		// Check whitelist
@@ -406,19 +524,97 @@ func ValidateCommand(command string) error {
 // isSafePath checks if path is within workingDir
 // Returns false if path escapes workingDir through .. or symlinks
 func isSafePath(path, workingDir string) bool {
-	abs, err := filepath.Abs(path)
+	// Not filepath.Abs: cleaning "link/../x" to "x" before the link is
+	// resolved hides where the kernel goes
+	abs := path
+	if !filepath.IsAbs(abs) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return false
+		}
+		abs = cwd + string(filepath.Separator) + path
+	}
+	working, err := filepath.Abs(workingDir)
 	if err != nil {
 		return false
 	}
 
+	// Compare where the paths really are: a symlink inside workingDir
+	// may point anywhere
+	if abs, err = resolveSymlinks(abs); err != nil {
+		return false
+	}
+	if working, err = resolveSymlinks(working); err != nil {
+		return false
+	}
+
 	// Clean both paths and ensure workingDir has trailing separator
 	// to prevent "/home/user/project" matching "/home/user/project-evil"
-	cleanWorking := filepath.Clean(workingDir) + string(filepath.Separator)
+	cleanWorking := filepath.Clean(working) + string(filepath.Separator)
 	cleanAbs := filepath.Clean(abs) + string(filepath.Separator)
 
 	return strings.HasPrefix(cleanAbs, cleanWorking)
 }
 
+// maxSymlinks bounds the links followed by resolveSymlinks (as ELOOP)
+const maxSymlinks = 40
+
+// resolveSymlinks returns the absolute path with its symlinks evaluated
+// the way the kernel walks it: component by component, a ".." going up
+// from where the components before it really are. The part that doesn't
+// exist yet, a file about to be written, is kept as is; a dangling symlink
+// resolves to its target.
+func resolveSymlinks(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("not an absolute path: %s", path)
+	}
+	vol := filepath.VolumeName(path)
+	resolved := vol + string(filepath.Separator)
+	pending := splitPath(path[len(vol):])
+	for links := 0; len(pending) > 0; {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved) // holds no links: it is resolved
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		fi, err := os.Lstat(next)
+		switch {
+		case err == nil && fi.Mode()&os.ModeSymlink != 0:
+			if links++; links > maxSymlinks {
+				return "", fmt.Errorf("too many symlinks: %s", path)
+			}
+			target, err := os.Readlink(next)
+			if err != nil {
+				return "", err
+			}
+			if filepath.IsAbs(target) {
+				vol := filepath.VolumeName(target)
+				resolved, target = vol+string(filepath.Separator), target[len(vol):]
+			}
+			pending = append(splitPath(target), pending...)
+			continue
+		// A file used as a directory fails later, where it is written
+		case err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR):
+			return "", err
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
+
+// splitPath returns the components of path
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r))
+	})
+}
+
 func makeToolError(toolUseID, errMsg string) (ContentBlock, error) {
 	return ContentBlock{
 		Type:      "tool_result",
@@ -440,7 +636,9 @@ func logAndReturnError(toolUseID, claudeDir, tool string,
 func logAuditEntry(claudeDir, tool string, input, result map[string]interface{},
 	success bool, conversationID string, startTime time.Time, dryRun bool,
 ) {
-	duration := time.Since(startTime).Milliseconds()
+	elapsed := time.Since(startTime)
+	duration := elapsed.Milliseconds()
+	telemetry.RecordTool(context.Background(), tool, elapsed, success, dryRun)
 
 	entry := storage.AuditLogEntry{
 		Timestamp:      time.Now().Format("20060102_150405"),
@@ -460,9 +658,11 @@ func logAuditEntry(claudeDir, tool string, input, result map[string]interface{},
 	}
 
 	// Log to audit file (best effort, don't fail tool execution)
+	if storage.Ephemeral() {
+		return
+	}
 	if err := storage.AppendAuditLog(claudeDir, entry); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n",
-			err)
+		slog.Warn("failed to write audit log", "err", err)
 	}
 }
 
@@ -470,16 +670,114 @@ func logAuditEntry(claudeDir, tool string, input, result map[string]interface{},
 func ExecuteTools(content []ContentBlock, workingDir string, claudeDir string,
 	opts *Options, conversationID string,
 ) ([]ContentBlock, error) {
-	results := []ContentBlock{}
+	return ExecuteToolsContext(context.Background(), content, workingDir,
+		claudeDir, opts, conversationID)
+}
+
+// ExecuteToolsContext is ExecuteTools with each tool traced as a child
+// span of ctx
+func ExecuteToolsContext(ctx context.Context, content []ContentBlock,
+	workingDir string, claudeDir string, opts *Options, conversationID string,
+) ([]ContentBlock, error) {
+	// Replayed responses may call plugins without a session
+	if err := registerToolPlugins(opts); err != nil {
+		return nil, err
+	}
+
+	// Tools over their budget and those pre_tool hooks veto don't run
+	vetoed := make(map[string]ContentBlock)
 	for _, block := range content {
 		if block.Type == "tool_use" {
-			result, err := ExecuteTool(block, workingDir, claudeDir, opts,
+			if msg := opts.toolBudget.take(block.Name); msg != "" {
+				slog.Warn("tool over budget", "tool", block.Name)
+				vetoed[block.ID], _ = logAndReturnError(block.ID, claudeDir,
+					block.Name, block.Input, msg, conversationID, time.Now())
+				continue
+			}
+			ev := toolEvent(storage.HookPreTool, block, nil, workingDir, opts,
 				conversationID)
+			if err := runHooks(ctx, ev); err != nil {
+				slog.Warn("tool blocked by hook", "tool", block.Name, "err", err)
+				vetoed[block.ID], _ = logAndReturnError(block.ID, claudeDir,
+					block.Name, block.Input, "blocked by "+err.Error(),
+					conversationID, time.Now())
+				continue
+			}
+		}
+	}
+
+	results := []ContentBlock{}
+	planned := make(map[string]ContentBlock)
+	for i, block := range content {
+		if block.Type == "tool_use" {
+			if result, ok := vetoed[block.ID]; ok {
+				results = append(results, result)
+				continue
+			}
+			// Consecutive writes are applied as a single transaction, in
+			// the place of the first. A patch is all or nothing anyway.
+			_, done := planned[block.ID]
+			if block.Name == "write_file" && !done && opts.patch == nil {
+				run := writeRun(content[i:], vetoed)
+				if plan := newWritePlan(run, workingDir); plan.size() > 1 {
+					_, span := telemetry.Start(ctx, "tool.write_plan",
+						attribute.Int("files", len(plan.changes)))
+					maps.Copy(planned, executeWritePlan(plan, claudeDir, opts,
+						conversationID))
+					span.End()
+				}
+			}
+			if result, ok := planned[block.ID]; ok {
+				results = append(results, result)
+				postToolHooks(ctx, block, result, workingDir, opts, conversationID)
+				continue
+			}
+			toolCtx, span := telemetry.Start(ctx, "tool."+block.Name,
+				attribute.String("tool.id", block.ID))
+			result, err := executeTool(toolCtx, ToolCall{
+				Use:            block,
+				WorkingDir:     workingDir,
+				ClaudeDir:      claudeDir,
+				Opts:           opts,
+				ConversationID: conversationID,
+			})
+			telemetry.End(span, err)
 			if err != nil {
 				return nil, fmt.Errorf("tool error: %w", err)
 			}
 			results = append(results, result)
+			postToolHooks(ctx, block, result, workingDir, opts, conversationID)
 		}
 	}
 	return results, nil
 }
+
+// writeRun returns the write_file calls at the start of blocks, up to the
+// first other tool. Vetoed calls don't run and don't end the run.
+func writeRun(blocks []ContentBlock, vetoed map[string]ContentBlock) []ContentBlock {
+	var run []ContentBlock
+	for _, block := range blocks {
+		if block.Type != "tool_use" {
+			continue
+		}
+		if _, ok := vetoed[block.ID]; ok {
+			continue
+		}
+		if block.Name != "write_file" {
+			break
+		}
+		run = append(run, block)
+	}
+	return run
+}
+
+// postToolHooks runs the post_tool hooks for block; failures are logged
+func postToolHooks(ctx context.Context, block, result ContentBlock,
+	workingDir string, opts *Options, conversationID string,
+) {
+	ev := toolEvent(storage.HookPostTool, block, &result, workingDir, opts,
+		conversationID)
+	if err := runHooks(ctx, ev); err != nil {
+		slog.Warn("post_tool hook failed", "tool", block.Name, "err", err)
+	}
+}
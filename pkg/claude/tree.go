@@ -0,0 +1,186 @@
+package claude
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tree.go - Project file tree for the system prompt (--project-context)
+//
+// Files come from git when dir is a repository so .gitignore is honored
+// exactly; otherwise the tree is walked with a simple reading of the top
+// level .gitignore. .git and .claude are always left out.
+
+// ProjectTree returns an indented file tree of dir listing at most
+// maxFiles files
+func ProjectTree(dir string, maxFiles int) (string, error) {
+	files, err := projectFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	shown := files
+	if len(shown) > maxFiles {
+		shown = shown[:maxFiles]
+	}
+
+	var b strings.Builder
+	var prev []string // directory components of the previous file
+	for _, f := range shown {
+		dirs := strings.Split(path.Dir(f), "/")
+		if dirs[0] == "." {
+			dirs = nil
+		}
+		common := 0
+		for common < len(dirs) && common < len(prev) && dirs[common] == prev[common] {
+			common++
+		}
+		for i := common; i < len(dirs); i++ {
+			fmt.Fprintf(&b, "%s%s/\n", strings.Repeat("  ", i), dirs[i])
+		}
+		fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", len(dirs)), path.Base(f))
+		prev = dirs
+	}
+	if len(files) > len(shown) {
+		fmt.Fprintf(&b, "(%d more files not shown)\n", len(files)-len(shown))
+	}
+	return b.String(), nil
+}
+
+// projectFiles returns the sorted slash separated paths of the files of
+// dir that aren't ignored
+func projectFiles(dir string) ([]string, error) {
+	files, err := gitFiles(dir)
+	if err != nil {
+		files, err = walkFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// skipPath reports whether a slash separated relative path is never listed
+func skipPath(rel string) bool {
+	first := strings.SplitN(rel, "/", 2)[0]
+	return first == ".git" || first == ".claude"
+}
+
+// gitFiles lists tracked and untracked, not ignored files
+func gitFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others",
+		"--exclude-standard")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, f := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if f == "" || skipPath(f) {
+			continue
+		}
+		// Deleted but still tracked files don't belong in the tree
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(f))); err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// walkFiles lists files under dir, skipping what .gitignore excludes
+func walkFiles(dir string) ([]string, error) {
+	ignore := loadGitignore(filepath.Join(dir, ".gitignore"))
+
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skipPath(rel) || ignore.match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// gitignore is the subset of .gitignore syntax used without git: globs,
+// a leading / to anchor and a trailing / for directories. Negation isn't
+// supported.
+type gitignore []ignorePattern
+
+type ignorePattern struct {
+	glob     string
+	anchored bool // match the full path instead of any name
+	dirOnly  bool
+}
+
+func loadGitignore(file string) gitignore {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var g gitignore
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		p.glob = line
+		g = append(g, p)
+	}
+	return g
+}
+
+func (g gitignore) match(rel string, isDir bool) bool {
+	for _, p := range g {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		name := path.Base(rel)
+		if p.anchored {
+			name = rel
+		}
+		if ok, _ := path.Match(p.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package claude_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// makeProject creates files (relative paths) under a temp dir
+func makeProject(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, ".gitignore"),
+		[]byte("# build output\n*.log\nbuild/\n/secret.txt\n"), 0o644)
+	return dir
+}
+
+var projectFiles = []string{
+	"go.mod", "cmd/tool/main.go", "pkg/a/a.go", "pkg/a/a_test.go",
+	"debug.log", "build/out.bin", "secret.txt", "docs/secret.txt",
+	".claude/config.json",
+}
+
+const wantTree = `.gitignore
+cmd/
+  tool/
+    main.go
+docs/
+  secret.txt
+go.mod
+pkg/
+  a/
+    a.go
+    a_test.go
+`
+
+func TestProjectTreeWalk(t *testing.T) {
+	dir := makeProject(t, projectFiles...)
+
+	tree, err := claude.ProjectTree(dir, 100)
+	if err != nil {
+		t.Fatalf("ProjectTree: %v", err)
+	}
+	if tree != wantTree {
+		t.Errorf("tree =\n%s\nwant\n%s", tree, wantTree)
+	}
+}
+
+func TestProjectTreeGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := makeProject(t, projectFiles...)
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	tree, err := claude.ProjectTree(dir, 100)
+	if err != nil {
+		t.Fatalf("ProjectTree: %v", err)
+	}
+	if tree != wantTree {
+		t.Errorf("tree =\n%s\nwant\n%s", tree, wantTree)
+	}
+}
+
+func TestProjectTreeCap(t *testing.T) {
+	dir := makeProject(t, "a.go", "b.go", "c.go")
+
+	tree, err := claude.ProjectTree(dir, 2)
+	if err != nil {
+		t.Fatalf("ProjectTree: %v", err)
+	}
+	// .gitignore, a.go shown; b.go, c.go cut
+	if !strings.HasSuffix(tree, "a.go\n(2 more files not shown)\n") {
+		t.Errorf("tree =\n%s", tree)
+	}
+}
+
+func TestProjectContextInSystemPrompt(t *testing.T) {
+	opts := claude.NewOptions()
+	opts.ProjectContext = true
+	mock := &scriptedLLM{responses: []*llm.Response{textResponse("ok", "end_turn")}}
+	if _, _, err := runScripted(t, opts, mock, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mock.requests[0].System, "system\n\nProject files") {
+		t.Errorf("system prompt = %q", mock.requests[0].System)
+	}
+}
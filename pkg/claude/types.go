@@ -1,7 +1,7 @@
 package claude
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 	"time"
 
@@ -12,9 +12,10 @@ import (
 const (
 	DefaultModel         = "claude-sonnet-4-20250514"
 	APIVersion           = "2023-06-01"
-	MaxContextTokens     = 100000
 	DefaultMaxIterations = 15
 	DefaultMaxCost       = 1.0 // dollars
+	MaxContinuations     = 3   // max_tokens continuations per turn
+	MaxToolRetries       = 2   // max_tokens retries of a cut off tool call
 
 	// Defaults
 	DefaultMaxTokens = 8192
@@ -36,22 +37,60 @@ const (
 	DefaultTool = "" // dry-run
 
 	// Output formats
-	OutputText    = "text"
-	OutputJSON    = "json"
-	DefaultOutput = OutputText
+	OutputText     = "text"
+	OutputJSON     = "json"
+	OutputJSONFull = "json-full" // the whole turn as one JSON document
+	OutputPatch    = "patch"     // write_file changes as a unified diff
+	OutputCSV      = "csv"       // --usage-export only
+	DefaultOutput  = OutputText
 
 	// bash_command timeout
 	BashCommandTimeout = 30 * time.Second
 
+	// External tool plugin timeout (per call)
+	ToolPluginTimeout = 60 * time.Second
+
+	// Default hook timeout (hooks.json "timeout" overrides it)
+	HookTimeout = 30 * time.Second
+
+	// --verify command timeout and how much of its output the model sees
+	VerifyTimeout   = 10 * time.Minute
+	MaxVerifyOutput = 8000 // bytes
+
+	// --project-context file tree size cap
+	MaxTreeFiles = 500
+
+	// --image size cap (the Messages API limit)
+	MaxImageSize = 5 << 20
+
 	// Default Ollama URL
 	DefaultOllamaURL = "http://localhost:11434"
 
+	// Ollama health check at session start
+	OllamaPingTimeout = 5 * time.Second
+
 	// Smart routing defaults
 	DefaultPreferLocal    = true
 	DefaultAllowFallback  = true
 	DefaultMaxClaudeRatio = 0.10 // 10%
+
+	// Providers. Claude models run on claude (Anthropic API), vertex or
+	// bedrock; ollama is selected by model name and only used in profiles,
+	// fake by --model=fake:SCENARIO.
+	ProviderClaude  = "claude"
+	ProviderVertex  = "vertex"
+	ProviderBedrock = "bedrock"
+	ProviderOllama  = "ollama"
+	ProviderFake    = "fake"
+
+	// FakeModelPrefix selects the scripted provider: fake:SCENARIO plays
+	// .claude/fakes/SCENARIO.json, a built-in scenario or a file
+	FakeModelPrefix = "fake:"
 )
 
+// ErrRefusal is returned when the model declines to answer
+var ErrRefusal = errors.New("the model declined to respond to this request")
+
 // Type aliases for LLM interface types
 type ContentBlock = llm.ContentBlock
 type MessageContent = llm.MessageContent
@@ -63,35 +102,22 @@ type Config = storage.Config
 type ModelsCache = storage.ModelsCache
 type AuditLogEntry = storage.AuditLogEntry
 type Request = storage.Request
-
-// API types (for backward compatibility with existing code)
-type APIRequest struct {
-	Model     string      `json:"model"`
-	MaxTokens int         `json:"max_tokens"`
-	System    string      `json:"system,omitempty"`
-	Messages  interface{} `json:"messages"`
-	Tools     []Tool      `json:"tools,omitempty"`
-}
-
 type APIResponse = storage.APIResponse
 
-type APIError struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-}
-
 // Options for CLI
 type Options struct {
-	// Modes
-	ModelsList    bool
-	ModelsRefresh bool
-	Reset         bool
-	ShowStats     bool
-	Replay        string
-	PruneOld      int
-	Estimate      bool
-	Execute       bool
-	MaxCostFlag   float64
+	// Replay: timestamp of the turn to replay, "" for the latest
+	Replay string
+
+	// Amend: timestamp of the turn this one replaces (--amend)
+	Amend string
+
+	// Replay filters: only re-execute matching tool calls
+	ReplayOnly        []string
+	ReplayToolIDs     []string
+	ReplayInteractive bool
+	// ReplayForce replays writes of files changed since the original run
+	ReplayForce bool
 
 	// Core
 	MaxTokens     int
@@ -100,23 +126,123 @@ type Options struct {
 	Model         string
 	Timeout       int
 	SystemPrompt  string
+	SystemFiles   []string // --system-file: blocks appended to the prompt
+	Prefill       string   // --prefill: start of the assistant's answer
 	Truncate      int
-	ResumeDir     string
 	OutputFile    string
 	OllamaURL     string
+	Temperature   *float64 // nil = provider default
 
 	// Smart routing
 	PreferLocal    bool
 	AllowFallback  bool
 	MaxClaudeRatio float64
+	Provider       string
+	ExplainRouting bool   // show the router's view of each prompt
+	TurnModel      string // prompt directive: model of this turn only
+
+	// Verification: command run after files change (e.g. "go test ./...")
+	Verify string
+
+	// Validate runs the validators of written files (validators.go), whose
+	// failures are fed back at most MaxFixAttempts times a turn
+	Validate       bool
+	MaxFixAttempts int
+
+	// FormatWrites formats the content of write_file calls first (format.go)
+	FormatWrites bool
+
+	// BestOf samples the first response of a turn BestOf times and keeps
+	// the one Judge (consensus or a model) prefers (bestof.go)
+	BestOf int
+	Judge  string
+
+	// Tool result compression: results over CompressResults tokens
+	// (0 = off) are summarized by CompressModel (Ollama) or head/tailed
+	CompressResults int
+	CompressModel   string
+
+	// ProjectContext adds the project file tree to the system prompt
+	ProjectContext bool
+	// EnvContext adds the date, OS, working directory and git branch
+	EnvContext bool
+
+	// OllamaAutoPull downloads a missing Ollama model and retries
+	OllamaAutoPull bool
+
+	// PlanApprove runs a --plan plan without asking for approval
+	PlanApprove bool
+
+	// ForceTool sets tool_choice for the first call of a turn: auto, any,
+	// none or a tool name (Claude only)
+	ForceTool string
+
+	// SequentialTools allows one tool call per response, so tool side
+	// effects happen strictly in order
+	SequentialTools bool
+
+	// Steer reads lines typed on the terminal during the turn and sends
+	// them to the model with the next call
+	Steer bool
+
+	// Watch shows a live panel of the running turn on the terminal
+	Watch bool
+
+	// Images are attached to the user message (vision models only)
+	Images []string
+
+	// SemanticSearch offers the semantic_search tool (needs claude index)
+	SemanticSearch bool
+
+	// RAG adds the RAG chunks of the claude index closest to the prompt
+	// to the user message, within RAGTokens (0 = DefaultRAGTokens)
+	RAG       int
+	RAGTokens int
+
+	// Tags label the turn in --history
+	Tags []string
 
 	// Fallback (legacy)
 	FallbackModel string
 
+	// Fallback chain: models tried in order when a call fails with an
+	// error of a FallbackOn class (empty = all), each after
+	// FallbackRetries retries of the failing model. Unset fields come
+	// from the fallback section of config.json; without a chain Ollama
+	// falls back to FallbackModel.
+	FallbackChain   []string
+	FallbackOn      []string
+	FallbackRetries int
+
 	// Behavior
 	Verbosity string
 	Tool      string
 	Output    string
+
+	// PendingPatch saves the writes of a dry-run turn as
+	// .claude/pending_<timestamp>.patch
+	PendingPatch bool
+
+	// SpendAlerts are the percentages of --max-cost and DailyBudget that
+	// warn (nil = project config or DefaultSpendAlerts, empty = none).
+	// DailyBudget is the project's soft limit in dollars per day.
+	SpendAlerts []int
+	DailyBudget float64
+
+	// ToolBudgets caps the calls of a tool per turn, by tool name or
+	// ToolBudgetAll
+	ToolBudgets map[string]int
+
+	patch      *patchSet   // --output=patch writes, see startPatch
+	pending    *patchSet   // --pending-patch writes, see startPending
+	toolBudget *toolBudget // tool calls of the turn, see startToolBudget
+
+	writeHashes map[string]string // files before the writes of the turn, see startWriteHashes
+
+	apiURL    string        // Claude API of the session, for spawn_agent
+	agentOf   string        // conversation a spawn_agent sub-agent works for
+	agentRuns []*RunSummary // sub-agents of the turn not yet counted, see recordAgents
+	turnCost  float64       // spent by the turn before its tool calls, for spawn_agent
 }
 
 // NewOptions creates a new Options with default values (for tests)
@@ -137,6 +263,7 @@ func NewOptions() *Options {
 		AllowFallback:  DefaultAllowFallback,
 		MaxClaudeRatio: DefaultMaxClaudeRatio,
 		FallbackModel:  "",
+		MaxFixAttempts: DefaultMaxFixAttempts,
 	}
 }
 
@@ -187,18 +314,44 @@ func (o *Options) WantsJSON() bool {
 
 // session holds all state needed for a conversation execution.
 type session struct {
-	opts         *Options
-	claudeDir    string
-	apiKey       string
-	config       *Config
-	model        string
-	sysPrompt    string
-	timestamp    string
-	workingDir   string
-	client       *http.Client
-	llmClient    llm.LLM
-	fallbackLLM  llm.LLM // fallback client (Claude) if primary fails
-	usedFallback bool    // track if we used fallback this session
+	opts       *Options
+	claudeDir  string
+	config     *Config
+	model      string
+	sysPrompt  string
+	sysBlocks  []llm.SystemBlock // sysPrompt in blocks, with --system-file
+	sysHash    string            // of the resolved system prompt
+	sysSource  string            // where it came from, a SystemSource*
+	timestamp  string
+	workingDir string
+	llmClient  llm.LLM
+	fallbacks  []fallbackTier // chain models not tried yet, in order
+	fellBack   []string       // models fallen back to this session
+	summary    RunSummary
+	journal    *storage.Journal // in-progress turn record
+	toolChoice *llm.ToolChoice  // --force-tool, first call of the turn only
+	ragSources []string         // --rag chunks sent with the prompt
+	title      string           // of the turn, from the prompt
+	envelope   *Envelope        // --output=json-full record of the turn
+	spend      *spendTracker    // --spend-alerts fired
+	stream     func(TurnEvent)  // --serve client of the turn
+	apiKeys    []string         // IDs of the Claude API keys that answered
+	invalid    map[string]bool  // written files failing --validate
+	fixes      int              // --validate failures fed back
+	judge      *judge           // --best-of judge model, nil for consensus
+	bestOf     *storage.BestOf  // --best-of candidates of the turn
+	steer      *steering        // --steer lines typed during the turn
+	watch      *watch           // --watch panel of the turn
+}
+
+// SetLLM replaces the primary LLM client (for tests)
+func (s *session) SetLLM(client llm.LLM) {
+	s.llmClient = client
+}
+
+// AssistantText returns the final answer text
+func (r *conversationResult) AssistantText() string {
+	return r.assistantText
 }
 
 // conversationResult holds the outcome of a conversation execution.
@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EnvUser names who runs the turns in their metadata, over the user of the
+// project config and the login name
+const EnvUser = "CLAUDE_USER"
+
+// UsageRow is the usage of one model by one user on one day (--usage-export)
+type UsageRow struct {
+	Date         string  `json:"date"` // 2006-01-02, local time
+	User         string  `json:"user"`
+	Model        string  `json:"model"`
+	Provider     string  `json:"provider"`
+	Turns        int     `json:"turns"`
+	Iterations   int     `json:"iterations"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// usageColumns is the CSV header of the usage export
+var usageColumns = []string{"date", "user", "model", "provider", "turns", "iterations",
+	"input_tokens", "output_tokens", "cost"}
+
+// UsageUser returns who runs turns: $CLAUDE_USER, else the user of cfg,
+// else the login name
+func UsageUser(cfg *Config) string {
+	if u := os.Getenv(EnvUser); u != "" {
+		return u
+	}
+	if cfg != nil && cfg.User != "" {
+		return cfg.User
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// UsageExport aggregates the saved turns of claudeDir from from to to,
+// both dates inclusive and either zero for no bound, by day, user and
+// model. Rows are sorted by date, user and model.
+func UsageExport(claudeDir string, from, to time.Time) ([]UsageRow, error) {
+	entries, err := LoadHistory(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[[3]string]*UsageRow)
+	for _, e := range entries {
+		t, err := time.ParseInLocation("20060102_150405", e.Timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		if (!from.IsZero() && day.Before(from)) || (!to.IsZero() && day.After(to)) {
+			continue
+		}
+
+		who := "unknown" // saved before turns recorded their user
+		if e.Meta != nil && e.Meta.User != "" {
+			who = e.Meta.User
+		}
+		model := e.Model
+		if model == "" {
+			model = "unknown"
+		}
+		key := [3]string{day.Format(time.DateOnly), who, model}
+		r := rows[key]
+		if r == nil {
+			r = &UsageRow{Date: key[0], User: who, Model: model, Provider: entryProvider(&e)}
+			rows[key] = r
+		}
+		r.Turns++
+		r.Iterations += e.Iterations
+		r.InputTokens += e.InputTokens
+		r.OutputTokens += e.OutputTokens
+		r.Cost += e.Cost
+	}
+
+	list := make([]UsageRow, 0, len(rows))
+	for _, r := range rows {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		return a.Model < b.Model
+	})
+	return list, nil
+}
+
+// parseUsageDate parses a --from or --to date; empty is no bound
+func parseUsageDate(flag, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--%s %q: want a date like 2006-01-02", flag, s)
+	}
+	return t, nil
+}
+
+// UsageExportCommand handles --usage-export: writes the usage of claudeDir
+// between the dates from and to (2006-01-02, inclusive, empty for no
+// bound) to w as CSV, or as JSON with format OutputJSON
+func UsageExportCommand(w io.Writer, claudeDir, from, to, format string) error {
+	fromDay, err := parseUsageDate("from", from)
+	if err != nil {
+		return err
+	}
+	toDay, err := parseUsageDate("to", to)
+	if err != nil {
+		return err
+	}
+	if !fromDay.IsZero() && !toDay.IsZero() && toDay.Before(fromDay) {
+		return fmt.Errorf("--to %s is before --from %s", to, from)
+	}
+	rows, err := UsageExport(claudeDir, fromDay, toDay)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling usage: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case OutputCSV, OutputText:
+		cw := csv.NewWriter(w)
+		cw.Write(usageColumns)
+		for _, r := range rows {
+			cw.Write([]string{r.Date, r.User, r.Model, r.Provider,
+				strconv.Itoa(r.Turns), strconv.Itoa(r.Iterations),
+				strconv.Itoa(r.InputTokens), strconv.Itoa(r.OutputTokens),
+				strconv.FormatFloat(r.Cost, 'f', 6, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("--usage-export writes csv or json, not %s", format)
+	}
+}
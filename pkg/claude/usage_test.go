@@ -0,0 +1,80 @@
+package claude_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestUsageExport(t *testing.T) {
+	claudeDir := t.TempDir()
+	for _, turn := range []struct{ ts, model, user string }{
+		{"20260130_100000", "claude-sonnet-4-20250514", "alice"},
+		{"20260131_100000", "claude-sonnet-4-20250514", "alice"},
+		{"20260131_110000", "claude-sonnet-4-20250514", "alice"},
+		{"20260131_120000", "llama3.1:8b", "bob"},
+		{"20260131_130000", "llama3.1:8b", ""}, // no metadata
+		{"20260201_100000", "claude-sonnet-4-20250514", "bob"},
+	} {
+		saveStatsTurn(t, claudeDir, turn.ts, turn.model, 2)
+		if turn.user != "" {
+			if err := storage.SaveTurnMeta(claudeDir, turn.ts, &storage.TurnMeta{
+				Model: turn.model, User: turn.user, Iterations: 2,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := claude.UsageExportCommand(&out, claudeDir, "2026-01-31", "2026-01-31",
+		claude.OutputCSV); err != nil {
+		t.Fatalf("UsageExportCommand: %v", err)
+	}
+	want := "date,user,model,provider,turns,iterations,input_tokens,output_tokens,cost\n" +
+		"2026-01-31,alice,claude-sonnet-4-20250514,claude,2,4,4000,400,"
+	if got := out.String(); !strings.HasPrefix(got, want) ||
+		!strings.Contains(got, "\n2026-01-31,bob,llama3.1:8b,ollama,1,2,2000,200,0.000000\n") ||
+		!strings.Contains(got, "\n2026-01-31,unknown,llama3.1:8b,ollama,1,") ||
+		strings.Count(got, "\n") != 4 {
+		t.Errorf("CSV export:\n%s", got)
+	}
+
+	out.Reset()
+	if err := claude.UsageExportCommand(&out, claudeDir, "2026-02-01", "",
+		claude.OutputJSON); err != nil {
+		t.Fatalf("UsageExportCommand: %v", err)
+	}
+	var rows []claude.UsageRow
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("JSON export: %v\n%s", err, out.String())
+	}
+	if len(rows) != 1 || rows[0].User != "bob" || rows[0].Turns != 1 {
+		t.Errorf("rows = %+v", rows)
+	}
+
+	for _, tc := range []struct{ from, to string }{
+		{"31/01/2026", ""},
+		{"2026-02-01", "2026-01-31"},
+	} {
+		if err := claude.UsageExportCommand(&out, claudeDir, tc.from, tc.to,
+			claude.OutputCSV); err == nil {
+			t.Errorf("--from %q --to %q: no error", tc.from, tc.to)
+		}
+	}
+}
+
+func TestUsageUser(t *testing.T) {
+	t.Setenv(claude.EnvUser, "")
+	if got := claude.UsageUser(&claude.Config{User: "carol"}); got != "carol" {
+		t.Errorf("config user: got %q", got)
+	}
+	t.Setenv(claude.EnvUser, "dave")
+	if got := claude.UsageUser(&claude.Config{User: "carol"}); got != "dave" {
+		t.Errorf("%s: got %q", claude.EnvUser, got)
+	}
+}
@@ -0,0 +1,180 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// validators.go - Per-file validators (--validate)
+//
+// With --validate every file a write_file call changes goes through the
+// validator of its extension: gofmt and go vet for Go, eslint for
+// JavaScript and TypeScript, jq for JSON, or what the "validators" of
+// config.json say. Failures are appended to the result of the write so
+// the model fixes them, and an answer isn't accepted while a file fails.
+// Every round of failures fed back uses one of --max-fix-attempts; after
+// the last one failures are only logged.
+
+// DefaultMaxFixAttempts is the --max-fix-attempts default
+const DefaultMaxFixAttempts = 3
+
+// defaultValidators are the validators by extension. {file} is replaced
+// by the quoted path of the written file, {dir} by its directory (./dir
+// when relative, as go vet wants it).
+var defaultValidators = map[string]string{
+	".go": `out=$(gofmt -l {file} 2>&1) && [ -z "$out" ] || ` +
+		`{ echo "not gofmt-clean: $out"; gofmt -d {file}; exit 1; }; go vet {dir}`,
+	".js":   "npx --no-install eslint {file}",
+	".jsx":  "npx --no-install eslint {file}",
+	".ts":   "npx --no-install eslint {file}",
+	".tsx":  "npx --no-install eslint {file}",
+	".json": "jq empty {file}",
+}
+
+// validatorFor returns the validator command of path: the one configured
+// for its extension, else the default; "" when there is none
+func validatorFor(path string, configured map[string]string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if command, ok := configured[ext]; ok {
+		return command // "" turns a default off
+	}
+	return defaultValidators[ext]
+}
+
+// expandValidator fills in the placeholders of command for path
+func expandValidator(command, path string) string {
+	dir := filepath.Dir(path)
+	if !filepath.IsAbs(dir) {
+		dir = "./" + dir
+	}
+	return strings.NewReplacer("{file}", shellQuote(path), "{dir}", shellQuote(dir)).
+		Replace(command)
+}
+
+// shellQuote quotes s for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// validateFile runs the validator of path, if any. It returns the failure
+// fed back to the model, or "" when path passes.
+func validateFile(ctx context.Context, sess *session, path string) string {
+	command := validatorFor(path, sess.config.Validators)
+	if command == "" {
+		return ""
+	}
+	command = expandValidator(command, path)
+	r := runVerify(ctx, command, sess.workingDir)
+	if r.passed {
+		return ""
+	}
+	return fmt.Sprintf("Validation of %s failed: %s (exit code %d)\n%s\n"+
+		"Fix the problems above.", path, command, r.exitCode,
+		tailOutput(r.output, MaxVerifyOutput))
+}
+
+// fixAttempt uses up one of --max-fix-attempts for failures. It returns
+// false, and logs them, when none are left.
+func (s *session) fixAttempt(failures map[string]string) bool {
+	if s.fixes >= s.opts.MaxFixAttempts {
+		paths := make([]string, 0, len(failures))
+		for path := range failures {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		slog.Warn("validation failing, no fix attempts left",
+			"files", strings.Join(paths, ","), "max_fix_attempts", s.opts.MaxFixAttempts)
+		return false
+	}
+	s.fixes++
+	return true
+}
+
+// recordValidation keeps which files fail validation and sets the outcome
+// in the summary
+func (s *session) recordValidation(failures map[string]string, checked []string) {
+	if s.invalid == nil {
+		s.invalid = make(map[string]bool)
+	}
+	for _, path := range checked {
+		if failures[path] != "" {
+			s.invalid[path] = true
+		} else {
+			delete(s.invalid, path)
+		}
+	}
+	s.summary.Validate = VerifyPassed
+	if len(s.invalid) > 0 {
+		s.summary.Validate = VerifyFailed
+	}
+}
+
+// validateWrites runs the validators of the files the tool calls in
+// content wrote and, while fix attempts are left, appends failures to the
+// results of the writes
+func validateWrites(ctx context.Context, sess *session, content, results []ContentBlock) {
+	if !sess.opts.Validate {
+		return
+	}
+	written := writtenFiles(content, results, sess.opts)
+	failures := make(map[string]string)
+	for _, path := range written {
+		if msg := validateFile(ctx, sess, path); msg != "" {
+			failures[path] = msg
+		}
+	}
+	sess.recordValidation(failures, written)
+	if len(failures) == 0 || !sess.fixAttempt(failures) {
+		return
+	}
+
+	// The last write of a file gets its failure
+	ids := make(map[string]string)
+	for _, block := range content {
+		if block.Type == "tool_use" && block.Name == "write_file" {
+			if path, _ := block.Input["path"].(string); failures[path] != "" {
+				ids[block.ID] = path
+			}
+		}
+	}
+	attached := make(map[string]bool)
+	for i := len(results) - 1; i >= 0; i-- {
+		path := ids[results[i].ToolUseID]
+		if path != "" && !attached[path] {
+			results[i].Content += "\n\n" + failures[path]
+			attached[path] = true
+		}
+	}
+}
+
+// revalidate checks the files that failed validation again before an
+// answer is accepted. It returns the failures to feed back, or "" when
+// they pass now or no fix attempts are left.
+func revalidate(ctx context.Context, sess *session) string {
+	if len(sess.invalid) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(sess.invalid))
+	for path := range sess.invalid {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	failures := make(map[string]string)
+	var msgs []string
+	for _, path := range paths {
+		if msg := validateFile(ctx, sess, path); msg != "" {
+			failures[path] = msg
+			msgs = append(msgs, msg)
+		}
+	}
+	sess.recordValidation(failures, paths)
+	if len(failures) == 0 || !sess.fixAttempt(failures) {
+		return ""
+	}
+	return strings.Join(msgs, "\n\n")
+}
@@ -0,0 +1,106 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+func TestValidateWrites(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		responses   []*llm.Response
+		wantFed     []int // requests whose last message carries a failure
+		wantOutcome string
+	}{
+		{
+			name:        "fixed",
+			maxAttempts: 3,
+			responses: []*llm.Response{
+				writeResponse("toolu_1", "broken"),
+				writeResponse("toolu_2", "fixed"),
+				textResponse("done", "end_turn"),
+			},
+			wantFed:     []int{1},
+			wantOutcome: claude.VerifyPassed,
+		},
+		{
+			name:        "answer while failing",
+			maxAttempts: 3,
+			responses: []*llm.Response{
+				writeResponse("toolu_1", "broken"),
+				textResponse("done", "end_turn"),
+				writeResponse("toolu_2", "fixed"),
+				textResponse("done", "end_turn"),
+			},
+			wantFed:     []int{1, 2},
+			wantOutcome: claude.VerifyPassed,
+		},
+		{
+			name:        "out of attempts",
+			maxAttempts: 1,
+			responses: []*llm.Response{
+				writeResponse("toolu_1", "broken"),
+				writeResponse("toolu_2", "still broken"),
+				textResponse("done", "end_turn"),
+			},
+			wantFed:     []int{1},
+			wantOutcome: claude.VerifyFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, claudeDir := writeProject(t, map[string]string{
+				".claude/config.json": `{"validators": {".txt": ` +
+					`"grep -qx fixed {file} || { echo {file} is broken; exit 3; }"}}`,
+			})
+			t.Chdir(dir)
+			t.Setenv("ANTHROPIC_API_KEY", "test-key")
+			storage.SaveModelsCache(claudeDir, &storage.ModelsCache{
+				Models: []llm.ModelInfo{{Name: claude.DefaultModel, Provider: "claude"}},
+			})
+
+			opts := claude.NewOptions()
+			opts.SetTool(claude.ToolWrite)
+			opts.SetVerbosity(claude.VerbositySilent)
+			opts.Validate = true
+			opts.MaxFixAttempts = tt.maxAttempts
+			sess, err := claude.InitSession(opts, claudeDir, "http://unused", "system")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mock := &scriptedLLM{responses: tt.responses}
+			sess.SetLLM(mock)
+			result, err := claude.ExecuteConversation(sess, "fix a.txt")
+			if err != nil {
+				t.Fatalf("ExecuteConversation: %v", err)
+			}
+			if result.AssistantText() != "done" {
+				t.Errorf("answer = %q", result.AssistantText())
+			}
+
+			fed := make(map[int]bool)
+			for _, i := range tt.wantFed {
+				fed[i] = true
+			}
+			for i, req := range mock.requests {
+				last := lastContent(req)
+				got := strings.Contains(last, "Validation of a.txt failed")
+				if got != fed[i] {
+					t.Errorf("request %d: failure fed back %v, want %v: %q", i, got, fed[i], last)
+				}
+				if got && !strings.Contains(last, "a.txt is broken") {
+					t.Errorf("request %d: no validator output: %q", i, last)
+				}
+			}
+			if got := sess.Summary(nil).Validate; got != tt.wantOutcome {
+				t.Errorf("validate outcome = %q, want %q", got, tt.wantOutcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// verify.go - Post-write verification (--verify)
+//
+// After a turn that changed files the verify command (e.g. "go build ./...
+// && go test ./...") runs in the working directory. Its outcome is appended
+// to the last write_file result so the model sees failures and can keep
+// fixing them until the command passes or a budget runs out.
+
+// Verification outcomes recorded in RunSummary.Verify
+const (
+	VerifyPassed = "passed"
+	VerifyFailed = "failed"
+)
+
+// verifyResult is the outcome of one verify run
+type verifyResult struct {
+	passed   bool
+	exitCode int
+	output   string
+}
+
+// runVerify runs command through bash in workingDir
+func runVerify(ctx context.Context, command, workingDir string) verifyResult {
+	ctx, cancel := context.WithTimeout(ctx, VerifyTimeout)
+	defer cancel()
+
+	slog.Info("verifying", "command", command)
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = workingDir
+	out, err := cmd.CombinedOutput()
+
+	r := verifyResult{passed: err == nil, output: string(out)}
+	if err != nil {
+		r.exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			r.exitCode = exitErr.ExitCode()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			r.output += fmt.Sprintf("\n(timed out after %v)", VerifyTimeout)
+		}
+	}
+	slog.Info("verification", "passed", r.passed, "exit_code", r.exitCode)
+	return r
+}
+
+// message is the text fed back to the model
+func (r verifyResult) message(command string) string {
+	if r.passed {
+		return fmt.Sprintf("Verification passed: %s", command)
+	}
+	return fmt.Sprintf("Verification failed: %s (exit code %d)\n%s\n"+
+		"Fix the problems above.", command, r.exitCode,
+		tailOutput(r.output, MaxVerifyOutput))
+}
+
+// tailOutput keeps the last max bytes of output, where errors usually are
+func tailOutput(output string, max int) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= max {
+		return output
+	}
+	return "...(truncated)\n" + output[len(output)-max:]
+}
+
+// verifyWrites runs the verify command when the tool calls in content
+// changed files and appends the outcome to the last write_file result.
+// It returns the outcome, or nil when nothing was verified.
+func verifyWrites(ctx context.Context, sess *session, content,
+	results []ContentBlock,
+) *verifyResult {
+	command := sess.opts.Verify
+	if command == "" {
+		return nil
+	}
+	written := writtenFiles(content, results, sess.opts)
+	if len(written) == 0 {
+		return nil
+	}
+
+	r := runVerify(ctx, command, sess.workingDir)
+
+	// Attach to the result of the last successful write
+	lastWrite := ""
+	for _, block := range content {
+		if block.Type == "tool_use" && block.Name == "write_file" {
+			if path, _ := block.Input["path"].(string); path == written[len(written)-1] {
+				lastWrite = block.ID
+			}
+		}
+	}
+	for i := range results {
+		if results[i].ToolUseID == lastWrite {
+			results[i].Content += "\n\n" + r.message(command)
+		}
+	}
+	return &r
+}
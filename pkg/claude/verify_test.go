@@ -0,0 +1,135 @@
+package claude_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+func writeResponse(id, content string) *llm.Response {
+	return &llm.Response{
+		Content: []claude.ContentBlock{{
+			Type: "tool_use", ID: id, Name: "write_file",
+			Input: map[string]interface{}{"path": "a.txt", "content": content},
+		}},
+		StopReason: "tool_use",
+	}
+}
+
+// lastContent returns the text of the last message sent in req
+func lastContent(req *llm.Request) string {
+	msg := req.Messages[len(req.Messages)-1]
+	var parts []string
+	for _, b := range msg.Content {
+		parts = append(parts, b.Text+b.Content)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func TestVerifyLoop(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_1", "broken"),
+		writeResponse("toolu_2", "fixed"),
+		textResponse("done", "end_turn"),
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolWrite)
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Verify = "grep -q fixed a.txt || { echo 'a.txt is broken'; exit 3; }"
+
+	text, _, summary, err := runScriptedSummary(t, opts, mock, "fix a.txt")
+	if err != nil {
+		t.Fatalf("ExecuteConversation: %v", err)
+	}
+	if text != "done" {
+		t.Errorf("answer = %q", text)
+	}
+
+	failed := lastContent(mock.requests[1])
+	if !strings.Contains(failed, "Verification failed") ||
+		!strings.Contains(failed, "exit code 3") ||
+		!strings.Contains(failed, "a.txt is broken") {
+		t.Errorf("failure not fed back: %q", failed)
+	}
+	if passed := lastContent(mock.requests[2]); !strings.Contains(passed, "Verification passed") {
+		t.Errorf("success not fed back: %q", passed)
+	}
+	if summary.Verify != claude.VerifyPassed {
+		t.Errorf("summary Verify = %q, want passed", summary.Verify)
+	}
+}
+
+func TestVerifyRejectsFailingAnswer(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_1", "broken"),
+		textResponse("looks good to me", "end_turn"),
+		writeResponse("toolu_2", "fixed"),
+		textResponse("done", "end_turn"),
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolWrite)
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Verify = "grep -q fixed a.txt"
+
+	text, _, _, err := runScriptedSummary(t, opts, mock, "fix a.txt")
+	if err != nil {
+		t.Fatalf("ExecuteConversation: %v", err)
+	}
+	if text != "done" {
+		t.Errorf("answer = %q, want the answer after the fix", text)
+	}
+	if len(mock.requests) != 4 {
+		t.Fatalf("got %d requests, want 4", len(mock.requests))
+	}
+	if got := lastContent(mock.requests[2]); !strings.Contains(got, "Verification failed") {
+		t.Errorf("answer accepted while failing: %q", got)
+	}
+}
+
+func TestVerifyLimits(t *testing.T) {
+	responses := []*llm.Response{writeResponse("toolu_1", "broken")}
+	for i := 0; i < 5; i++ {
+		responses = append(responses, textResponse("can't fix it", "end_turn"))
+	}
+	mock := &scriptedLLM{responses: responses}
+
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolWrite)
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.MaxIterations = 3
+	opts.Verify = "false"
+
+	_, _, summary, err := runScriptedSummary(t, opts, mock, "fix a.txt")
+	if err == nil || !strings.Contains(err.Error(), "max iterations") {
+		t.Fatalf("err = %v, want max iterations", err)
+	}
+	if summary.Verify != claude.VerifyFailed {
+		t.Errorf("summary Verify = %q, want failed", summary.Verify)
+	}
+}
+
+func TestVerifySkippedInDryRun(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		writeResponse("toolu_1", "broken"),
+		textResponse("done", "end_turn"),
+	}}
+
+	opts := claude.NewOptions()
+	opts.SetVerbosity(claude.VerbositySilent)
+	opts.Verify = "false"
+
+	_, _, summary, err := runScriptedSummary(t, opts, mock, "fix a.txt")
+	if err != nil {
+		t.Fatalf("ExecuteConversation: %v", err)
+	}
+	if summary.Verify != "" {
+		t.Errorf("summary Verify = %q, want none for dry-run", summary.Verify)
+	}
+	if got := lastContent(mock.requests[1]); strings.Contains(got, "Verification") {
+		t.Errorf("dry-run write verified: %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+package claude
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/display"
+)
+
+// watch.go - Live view of a running turn (--watch)
+//
+// With --watch a panel at the bottom of the terminal shows where a long
+// agentic run is: the iteration and what it is doing, the last tool call
+// with the start of its output, the tokens and cost so far and the elapsed
+// time. It replaces the waiting spinner and goes away when the turn ends;
+// without a terminal the turn runs as without --watch.
+
+// watchHeight is the height of the --watch panel
+const watchHeight = 4
+
+// watch is the state shown by the --watch panel of a turn
+type watch struct {
+	start time.Time
+	panel *display.Panel
+
+	mu        sync.Mutex
+	iteration int
+	status    string // what the turn is doing
+	lastTool  string // the last tool call and its result
+	tokensIn  int
+	tokensOut int
+	cost      float64
+}
+
+// startWatch shows the --watch panel of the turn of s
+func (s *session) startWatch() {
+	if !s.opts.Watch || s.opts.IsSilent() || s.opts.agentOf != "" {
+		return
+	}
+	s.watch = &watch{start: time.Now(), status: "starting"}
+	s.watch.panel = display.StartPanel(watchHeight, s.watch.lines)
+}
+
+// stopWatch removes the --watch panel
+func (s *session) stopWatch() {
+	if s.watch == nil {
+		return
+	}
+	s.watch.panel.Stop()
+	s.watch = nil
+}
+
+// update changes the state under the lock and redraws the panel. A nil
+// watch, without --watch, ignores it.
+func (w *watch) update(change func(w *watch)) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	change(w)
+	w.mu.Unlock()
+	w.panel.Refresh()
+}
+
+// calling shows the LLM call of iteration n
+func (w *watch) calling(n int, provider, model string) {
+	w.update(func(w *watch) {
+		w.iteration = n
+		w.status = fmt.Sprintf("waiting for %s (%s)", provider, model)
+	})
+}
+
+// usage shows the totals of the turn
+func (w *watch) usage(summary *RunSummary, cost float64) {
+	w.update(func(w *watch) {
+		w.tokensIn, w.tokensOut = summary.InputTokens, summary.OutputTokens
+		w.cost = cost
+	})
+}
+
+// running shows the tool calls under way
+func (w *watch) running(calls []ContentBlock) {
+	if w == nil {
+		return
+	}
+	var names []string
+	for _, block := range calls {
+		if block.Type == "tool_use" {
+			names = append(names, describeToolUse(block))
+		}
+	}
+	w.update(func(w *watch) { w.status = "running " + strings.Join(names, ", ") })
+}
+
+// ran shows the last tool call of calls with the start of its result
+func (w *watch) ran(calls, results []ContentBlock) {
+	if w == nil {
+		return
+	}
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].Type != "tool_use" {
+			continue
+		}
+		outcome := "no result"
+		for _, r := range results {
+			if r.ToolUseID != calls[i].ID {
+				continue
+			}
+			outcome = firstLine(r.Content)
+		}
+		last := describeToolUse(calls[i]) + " → " + outcome
+		w.update(func(w *watch) { w.lastTool = last })
+		return
+	}
+}
+
+// lines renders the panel
+func (w *watch) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := w.lastTool
+	if last == "" {
+		last = "none yet"
+	}
+	return []string{
+		strings.Repeat("─", 3) + " claude --watch",
+		fmt.Sprintf("iteration %d  %s  %s", w.iteration,
+			time.Since(w.start).Truncate(time.Second), w.status),
+		"last tool: " + last,
+		fmt.Sprintf("tokens %d in / %d out  $%.4f", w.tokensIn, w.tokensOut, w.cost),
+	}
+}
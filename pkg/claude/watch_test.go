@@ -0,0 +1,27 @@
+package claude_test
+
+import (
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/llm"
+)
+
+// Without a terminal there is no panel, and the turn runs as usual
+func TestWatchWithoutTerminal(t *testing.T) {
+	mock := &scriptedLLM{responses: []*llm.Response{
+		toolUseResponse("toolu_1", "read_file", map[string]interface{}{"path": "missing.go"}),
+		textResponse("There is no missing.go.", "end_turn"),
+	}}
+	opts := claude.NewOptions()
+	opts.SetTool(claude.ToolRead)
+	opts.Watch = true
+
+	text, _, summary, err := runScriptedSummary(t, opts, mock, "read missing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "There is no missing.go." || summary.Iterations != 2 {
+		t.Errorf("answer %q after %d iterations", text, summary.Iterations)
+	}
+}
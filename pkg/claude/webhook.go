@@ -0,0 +1,123 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// webhook.go - Posting results to a webhook (--post-webhook)
+//
+// With --post-webhook=URL the final answer and the run summary are posted
+// as JSON when a turn or playbook ends, failed or not, so scheduled jobs
+// deliver their results to a channel without scripting. In the slack
+// format, the default for Slack incoming webhooks, the payload also has
+// Block Kit blocks Slack renders.
+
+// Webhook formats (--webhook-format)
+const (
+	WebhookJSON  = "json"
+	WebhookSlack = "slack"
+)
+
+// maxSlackText is the longest text of a Slack section block
+const maxSlackText = 3000
+
+// WebhookPayload is what --post-webhook posts
+type WebhookPayload struct {
+	Text    string       `json:"text"` // the answer; Slack's notification text
+	Summary *RunSummary  `json:"summary"`
+	Project string       `json:"project,omitempty"` // working directory
+	Blocks  []slackBlock `json:"blocks,omitempty"`  // slack format only
+}
+
+// slackBlock is a Block Kit block: a section or context with mrkdwn text
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// WebhookFormat returns the format of the payload for url: format when
+// set, else slack for Slack incoming webhooks and json otherwise
+func WebhookFormat(format, webhookURL string) (string, error) {
+	switch format {
+	case WebhookJSON, WebhookSlack:
+		return format, nil
+	case "":
+		if u, err := url.Parse(webhookURL); err == nil && u.Host == "hooks.slack.com" {
+			return WebhookSlack, nil
+		}
+		return WebhookJSON, nil
+	}
+	return "", fmt.Errorf("invalid --webhook-format %q (want %s or %s)", format,
+		WebhookJSON, WebhookSlack)
+}
+
+// NewWebhookPayload returns the payload reporting a run of project
+func NewWebhookPayload(summary *RunSummary, answer, project, format string) *WebhookPayload {
+	p := &WebhookPayload{Text: answer, Summary: summary, Project: project}
+	if format != WebhookSlack {
+		return p
+	}
+
+	status := "*claude finished*"
+	if summary.ExitStatus != 0 {
+		status = "*claude failed*: " + summary.Error
+	}
+	if project != "" {
+		status += " in `" + project + "`"
+	}
+	p.Blocks = append(p.Blocks, slackBlock{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: cutText(status, maxSlackText, "a Slack message")},
+	})
+	if answer = strings.TrimSpace(answer); answer != "" {
+		p.Blocks = append(p.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: cutText(answer, maxSlackText, "a Slack message")},
+		})
+	}
+	facts := fmt.Sprintf("%s · %d/%d tokens · $%.4f · %d iterations", summary.Model,
+		summary.InputTokens, summary.OutputTokens, summary.Cost, summary.Iterations)
+	if len(summary.FilesChanged) > 0 {
+		facts += fmt.Sprintf(" · %d files changed", len(summary.FilesChanged))
+	}
+	p.Blocks = append(p.Blocks, slackBlock{
+		Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: facts}},
+	})
+	return p
+}
+
+// PostWebhook posts payload to webhookURL
+func PostWebhook(client *http.Client, webhookURL string, payload *WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling webhook payload: %w", err)
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// The URL is often the secret, keep it out of logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("posting webhook: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
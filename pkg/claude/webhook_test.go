@@ -0,0 +1,116 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestPostWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	summary := &claude.RunSummary{Model: "claude-test", InputTokens: 10, OutputTokens: 5, Iterations: 1}
+	payload := claude.NewWebhookPayload(summary, "All tests pass.", "/src/app", claude.WebhookJSON)
+	if err := claude.PostWebhook(srv.Client(), srv.URL, payload); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "All tests pass." || got["project"] != "/src/app" {
+		t.Errorf("payload = %v", got)
+	}
+	if s, ok := got["summary"].(map[string]any); !ok || s["model"] != "claude-test" {
+		t.Errorf("summary = %v", got["summary"])
+	}
+	if _, ok := got["blocks"]; ok {
+		t.Errorf("json payload has blocks: %v", got["blocks"])
+	}
+}
+
+func TestPostWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	payload := claude.NewWebhookPayload(&claude.RunSummary{}, "", "", claude.WebhookJSON)
+	err := claude.PostWebhook(srv.Client(), srv.URL+"/secret", payload)
+	if err == nil || !strings.Contains(err.Error(), "invalid_token") {
+		t.Fatalf("err = %v, want the response", err)
+	}
+
+	// Unreachable: the error must not carry the URL
+	srv.Close()
+	err = claude.PostWebhook(srv.Client(), srv.URL+"/secret", payload)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v, want one without the URL", err)
+	}
+}
+
+func TestWebhookSlackBlocks(t *testing.T) {
+	summary := &claude.RunSummary{
+		Model: "claude-test", ExitStatus: 1, Error: "max cost exceeded",
+		FilesChanged: []string{"a.go"},
+	}
+	payload := claude.NewWebhookPayload(summary, strings.Repeat("line\n", 1000), "/src/app",
+		claude.WebhookSlack)
+	if len(payload.Blocks) != 3 {
+		t.Fatalf("got %d blocks, want status, answer and context", len(payload.Blocks))
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+			Elements []struct {
+				Text string `json:"text"`
+			} `json:"elements"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if status := decoded.Blocks[0].Text.Text; !strings.Contains(status, "max cost exceeded") ||
+		!strings.Contains(status, "/src/app") {
+		t.Errorf("status = %q", status)
+	}
+	if answer := decoded.Blocks[1].Text.Text; len(answer) > 3000 || !strings.Contains(answer, "cut to fit") {
+		t.Errorf("answer block is %d bytes, want it cut to 3000", len(answer))
+	}
+	if decoded.Blocks[2].Type != "context" || !strings.Contains(decoded.Blocks[2].Elements[0].Text, "1 files changed") {
+		t.Errorf("context = %+v", decoded.Blocks[2])
+	}
+}
+
+func TestWebhookFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format, url, want string
+	}{
+		{"", "https://hooks.slack.com/services/T0/B0/x", claude.WebhookSlack},
+		{"", "https://example.com/hook", claude.WebhookJSON},
+		{"json", "https://hooks.slack.com/services/T0/B0/x", claude.WebhookJSON},
+		{"slack", "https://chat.example.com/hook", claude.WebhookSlack},
+	} {
+		got, err := claude.WebhookFormat(tc.format, tc.url)
+		if err != nil || got != tc.want {
+			t.Errorf("WebhookFormat(%q, %q) = %q, %v, want %q", tc.format, tc.url, got, err, tc.want)
+		}
+	}
+	if _, err := claude.WebhookFormat("xml", "https://example.com"); err == nil {
+		t.Error("WebhookFormat(xml) succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// workspace.go - Extra directories for the tools (policy.json workspace)
+//
+// The tools are confined to the working directory. A project that builds
+// against related repositories can list them as workspace roots: the
+// tools may read them, and write them only when the root says so.
+
+// workspaceRoots are the roots of the policy with absolute paths
+var workspaceRoots []storage.WorkspaceRoot
+
+// ConfigureWorkspace loads the workspace roots of .claude/policy.json.
+// Relative roots are taken from the project directory holding claudeDir.
+func ConfigureWorkspace(claudeDir string) error {
+	policy, err := storage.LoadPolicy(claudeDir)
+	if err != nil {
+		return err
+	}
+	project := filepath.Dir(claudeDir)
+	roots := make([]storage.WorkspaceRoot, 0, len(policy.Workspace.Roots))
+	for _, root := range policy.Workspace.Roots {
+		path := root.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(project, path)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("workspace root: %w", err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("workspace root %s is not a directory", path)
+		}
+		roots = append(roots, storage.WorkspaceRoot{
+			Path:  filepath.Clean(path),
+			Write: root.Write,
+		})
+	}
+	workspaceRoots = roots
+	return nil
+}
+
+// pathError returns why the tools may not use path, or "" when they may.
+// Paths in the working directory are always allowed.
+func pathError(path, workingDir string, write bool) string {
+	if isSafePath(path, workingDir) {
+		return ""
+	}
+	for _, root := range workspaceRoots {
+		if isSafePath(path, root.Path) {
+			if write && !root.Write {
+				return fmt.Sprintf("path in read-only workspace root %s: %s",
+					root.Path, path)
+			}
+			return ""
+		}
+	}
+	return fmt.Sprintf("path outside project: %s", path)
+}
+
+// workspacePrompt tells the model about the workspace roots
+func workspacePrompt() string {
+	if len(workspaceRoots) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nBesides the working directory, the tools can use these directories (absolute paths):\n")
+	for _, root := range workspaceRoots {
+		mode := "read-only"
+		if root.Write {
+			mode = "read-write"
+		}
+		fmt.Fprintf(&b, "- %s (%s)\n", root.Path, mode)
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package claude_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+)
+
+func TestWorkspaceRoots(t *testing.T) {
+	parent := t.TempDir()
+	project := filepath.Join(parent, "app")
+	shared := filepath.Join(parent, "shared")
+	docs := filepath.Join(parent, "docs")
+	claudeDir := filepath.Join(project, ".claude")
+	for _, dir := range []string{claudeDir, shared, docs} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(shared, "lib.go"), []byte("package lib\n"), 0o644)
+	os.WriteFile(filepath.Join(claudeDir, "policy.json"), []byte(`{"workspace": {"roots": [
+		{"path": "../shared"},
+		{"path": "`+docs+`", "write": true}
+	]}}`), 0o644)
+
+	if err := claude.ConfigureWorkspace(claudeDir); err != nil {
+		t.Fatalf("ConfigureWorkspace failed: %v", err)
+	}
+	t.Cleanup(func() { claude.ConfigureWorkspace(t.TempDir()) })
+
+	opts := claude.NewOptions()
+	opts.SetTool("write")
+	opts.SetVerbosity(claude.VerbositySilent)
+	run := func(block claude.ContentBlock) string {
+		t.Helper()
+		result, err := claude.ExecuteTool(block, project, claudeDir, opts, "20260105_120000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content
+	}
+	read := func(path string) claude.ContentBlock {
+		return claude.ContentBlock{Type: "tool_use", ID: "r", Name: "read_file",
+			Input: map[string]interface{}{"path": path}}
+	}
+
+	if got := run(read(filepath.Join(shared, "lib.go"))); got != "package lib\n" {
+		t.Errorf("reading the shared root = %q", got)
+	}
+	got := run(writeBlock("w1", filepath.Join(shared, "lib.go"), "x"))
+	if !strings.Contains(got, "read-only workspace root") {
+		t.Errorf("writing a read-only root = %q", got)
+	}
+	got = run(writeBlock("w2", filepath.Join(docs, "notes.md"), "x"))
+	if strings.HasPrefix(got, "Error") {
+		t.Errorf("writing a writable root = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(docs, "notes.md")); err != nil {
+		t.Errorf("notes.md not written: %v", err)
+	}
+	if got := run(read(filepath.Join(parent, "other.txt"))); !strings.Contains(got, "outside project") {
+		t.Errorf("reading outside every root = %q", got)
+	}
+
+	// A root that doesn't exist is a configuration error
+	os.WriteFile(filepath.Join(claudeDir, "policy.json"),
+		[]byte(`{"workspace": {"roots": [{"path": "../missing"}]}}`), 0o644)
+	if err := claude.ConfigureWorkspace(claudeDir); err == nil {
+		t.Error("ConfigureWorkspace accepted a missing root")
+	}
+}
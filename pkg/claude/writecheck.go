@@ -0,0 +1,205 @@
+package claude
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/marcopeereboom/go-claude/pkg/redact"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// writecheck.go - Content checks of write_file
+//
+// write_file replaces the whole file, and models get that wrong in a few
+// typical ways: they elide what they didn't change ("// rest of file
+// unchanged"), stop before the end of the file, or send a diff or merge
+// conflict instead of the content. Written as is, any of these silently
+// corrupts the file. The content is checked before it is written, and a
+// failed check is a tool error asking the model to send the whole file.
+// The checks are heuristics: a call setting "complete" skips them, for
+// content that is meant as it is. But for one: secrets are redacted from
+// what the model reads, so content it writes back may hold placeholders
+// where the file has secrets. Written, they would replace the secrets, so
+// that content is always refused.
+
+// shrinkMinLines is the size of a file below which shrinking isn't checked
+const shrinkMinLines = 30
+
+// shrinkRatio is the fraction of its lines a file may shrink to before the
+// write looks truncated
+const shrinkRatio = 0.4
+
+// commentPattern splits a comment line into its marker and its text
+var commentPattern = regexp.MustCompile(`^\s*(//|#|--|;|/\*|<!--|\*|\{/\*)(.*?)(\*/\}?|-->)?\s*$`)
+
+// elisionWords say that code was left out; codeWords say what code
+var (
+	elisionWords = regexp.MustCompile(`\b(rest|remainder|remaining|existing|unchanged|same|omitted|elided|brevity|before)\b`)
+	codeWords    = regexp.MustCompile(`\b(code|file|functions?|class|methods?|implementation|content|imports|tests|body|logic|module|section|part|fields|handlers|cases)\b`)
+)
+
+// hunkPattern matches the hunk header of a unified diff
+var hunkPattern = regexp.MustCompile(`^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`)
+
+// braceExts are the extensions of languages whose blocks are in braces
+var braceExts = map[string]bool{
+	".go": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true,
+	".java": true, ".kt": true, ".scala": true, ".cs": true, ".swift": true,
+	".rs": true, ".js": true, ".mjs": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".php": true, ".css": true, ".scss": true, ".json": true, ".proto": true,
+}
+
+// writeContentError checks content about to be written to path over old (nil
+// for a new file) and returns why it looks corrupt, "" when it doesn't, or
+// when complete says it is as meant.
+func writeContentError(path, content string, old []byte, complete bool) string {
+	if msg := redactedError(content, old); msg != "" || complete {
+		return msg
+	}
+	oldLines := make(map[string]bool)
+	for _, line := range strings.Split(string(old), "\n") {
+		oldLines[strings.TrimSpace(line)] = true
+	}
+	lines := strings.Split(content, "\n")
+	ext := strings.ToLower(filepath.Ext(path))
+
+	// What the file already had isn't the model's doing
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || oldLines[trimmed] {
+			continue
+		}
+		if placeholderLine(line) {
+			return resendError(fmt.Sprintf("line %d, %q, stands for elided code; if "+
+				`it belongs in the file, write it again with "complete": true`, i+1, trimmed))
+		}
+	}
+	if ext != ".patch" && ext != ".diff" && ext != ".md" {
+		conflict := 0
+		for i, line := range lines {
+			if oldLines[strings.TrimSpace(line)] {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> "):
+				conflict++
+			case hunkPattern.MatchString(line):
+				return resendError(fmt.Sprintf("line %d is a diff hunk header; write_file "+
+					"takes the content of the file, not a diff", i+1))
+			}
+		}
+		if conflict >= 2 {
+			return resendError("it holds merge conflict markers (<<<<<<< and >>>>>>>)")
+		}
+	}
+	if n, was := countLines(content), countLines(string(old)); was >= shrinkMinLines &&
+		float64(n) < float64(was)*shrinkRatio {
+		return resendError(fmt.Sprintf("it has %d lines where the file has %d, which "+
+			"looks truncated; if it is meant to be this short, write it again with "+
+			`"complete": true`, n, was))
+	}
+	if braceExts[ext] {
+		if open := braceBalance(content); open > 0 && open > braceBalance(string(old)) {
+			return resendError(fmt.Sprintf("%d { are never closed, which looks truncated; "+
+				`if the content is complete, write it again with "complete": true`, open))
+		}
+	}
+	return ""
+}
+
+// redactedError returns why content holds redaction placeholders old
+// doesn't, "" when it doesn't. Without redaction the model saw what the
+// files hold, placeholders included.
+func redactedError(content string, old []byte) string {
+	if storage.Redactor() == nil {
+		return ""
+	}
+	had := make(map[string]int)
+	for _, p := range redact.Placeholders(string(old)) {
+		had[p]++
+	}
+	for i, line := range strings.Split(content, "\n") {
+		for _, p := range redact.Placeholders(line) {
+			if had[p]--; had[p] < 0 {
+				return fmt.Sprintf("content not written: line %d holds %s, which stands "+
+					"for a secret redacted from what you read; writing it would replace "+
+					"the secret. Leave this file to the user and say what to change in it",
+					i+1, p)
+			}
+		}
+	}
+	return ""
+}
+
+// placeholderLine reports whether line is a comment standing for elided
+// code: "// ...", "// rest of file unchanged", "# ... existing code ..."
+func placeholderLine(line string) bool {
+	m := commentPattern.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	text := strings.ToLower(m[2])
+	ellipsis := strings.Contains(text, "...") || strings.Contains(text, "…")
+	words := strings.Fields(strings.NewReplacer(".", " ", "…", " ", "(", " ", ")", " ",
+		"[", " ", "]", " ").Replace(text))
+	switch {
+	case len(words) == 0:
+		return ellipsis
+	case len(words) > 8:
+		return false
+	}
+	text = strings.Join(words, " ")
+	return codeWords.MatchString(text) && (ellipsis || elisionWords.MatchString(text))
+}
+
+// resendError is the tool error of a failed content check
+func resendError(reason string) string {
+	return "content not written: " + reason + ". Send the whole file content, " +
+		"every line of it, in one write_file call."
+}
+
+// countLines returns the number of lines of s
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+}
+
+// braceBalance returns the number of { in src without a }, skipping
+// strings, characters and comments as C-like languages write them
+func braceBalance(src string) int {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"', '\'', '`':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && c != '`' {
+					i++
+				} else if src[i] == '\n' && c != '`' {
+					break // unterminated, e.g. an apostrophe in CSS
+				}
+			}
+		case '/':
+			switch {
+			case strings.HasPrefix(src[i:], "//"):
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			case strings.HasPrefix(src[i:], "/*"):
+				end := strings.Index(src[i+2:], "*/")
+				if end < 0 {
+					return depth
+				}
+				i += end + 3
+			}
+		}
+	}
+	return depth
+}
@@ -0,0 +1,118 @@
+package claude_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/claude"
+	"github.com/marcopeereboom/go-claude/pkg/storage"
+)
+
+// goFile is a Go file of n functions
+func goFile(n int) string {
+	var sb strings.Builder
+	sb.WriteString("package big\n")
+	for i := range n {
+		fmt.Fprintf(&sb, "\n// F%d is function %d\nfunc F%d() int {\n\treturn %d\n}\n", i, i, i, i)
+	}
+	return sb.String()
+}
+
+func TestWriteContentChecks(t *testing.T) {
+	big := goFile(10)
+	tests := []struct {
+		name, path, content string
+		complete            bool
+		want                string // in the error, "" when written
+	}{
+		{"placeholder", "big.go", "package big\n\nfunc F0() int {\n\treturn 0\n}\n\n// ... rest of file unchanged ...\n",
+			false, "elided code"},
+		{"hash placeholder", "run.py", "import os\n\n# ... existing code ...\nprint(os.getcwd())\n",
+			false, "elided code"},
+		{"ellipsis", "new.go", "package new\n\nfunc New() {\n\t// ...\n}\n", false, "elided code"},
+		{"comment", "new.go", "package new\n\n// New returns the rest of the queue\nfunc New() {}\n", false, ""},
+		// A comment the file already had is the file's
+		{"kept comment", "keep.go", "package keep\n\n// ... existing code ...\n\nfunc Keep() {}\n", false, ""},
+		{"diff", "big.go", "@@ -1,3 +1,3 @@\n-package big\n+package small\n", false, "not a diff"},
+		{"conflict", "new.txt", "<<<<<<< HEAD\na\n=======\nb\n>>>>>>> main\n", false, "conflict markers"},
+		{"shrunk", "big.go", "package big\n\nfunc F0() int {\n\treturn 0\n}\n", false, "looks truncated"},
+		{"shrunk complete", "big.go", "package big\n\nfunc F0() int {\n\treturn 0\n}\n", true, ""},
+		{"unclosed", "new.go", "package new\n\nfunc New() {\n\tif true {\n\t\treturn\n", false, "2 { are never closed"},
+		{"braces in strings", "new.go", "package new\n\nvar s = \"{\" + `{{` + string('{') // {\n", false, ""},
+		{"grown", "big.go", big + "\nfunc G() {}\n", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, claudeDir := writeProject(t, map[string]string{
+				"big.go":  big,
+				"keep.go": "package keep\n\n// ... existing code ...\n",
+			})
+			t.Chdir(dir)
+			opts := &claude.Options{Tool: claude.ToolWrite, Verbosity: claude.VerbositySilent}
+			input := map[string]interface{}{"path": tt.path, "content": tt.content}
+			if tt.complete {
+				input["complete"] = true
+			}
+			toolUse := claude.ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "write_file", Input: input}
+			result, err := claude.ExecuteWriteFile(toolUse, dir, claudeDir, opts, "test-conv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(tt.path)
+			if tt.want == "" {
+				if string(got) != tt.content {
+					t.Errorf("not written: %s", result.Content)
+				}
+				return
+			}
+			if !strings.Contains(result.Content, tt.want) || !strings.Contains(result.Content, "whole file") {
+				t.Errorf("result = %q, want %q", result.Content, tt.want)
+			}
+			if string(got) == tt.content {
+				t.Error("written anyway")
+			}
+		})
+	}
+}
+
+func TestWriteRedactedContent(t *testing.T) {
+	key := "sk-ant-" + strings.Repeat("a1B2", 8)
+	config := "api_key: " + key + "\nport: 8080\n"
+	dir, claudeDir := writeProject(t, map[string]string{
+		"config.yaml": config,
+		"doc.txt":     "keys show as [REDACTED:anthropic_api_key]\n",
+	})
+	t.Chdir(dir)
+	if err := claude.ConfigureRedaction(claudeDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.SetRedactor(nil) })
+
+	write := func(path, content string) string {
+		t.Helper()
+		opts := &claude.Options{Tool: claude.ToolWrite, Verbosity: claude.VerbositySilent}
+		toolUse := claude.ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "write_file",
+			Input: map[string]interface{}{"path": path, "content": content, "complete": true}}
+		result, err := claude.ExecuteWriteFile(toolUse, dir, claudeDir, opts, "test-conv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content
+	}
+
+	// What the model read of config.yaml, with the port changed
+	edited := strings.Replace(storage.Redactor().String(config), "8080", "9090", 1)
+	if got := write("config.yaml", edited); !strings.Contains(got, "[REDACTED:anthropic_api_key]") {
+		t.Errorf("result = %q, want the placeholder refused", got)
+	}
+	if data, _ := os.ReadFile("config.yaml"); string(data) != config {
+		t.Errorf("config.yaml = %q, want the secret kept", data)
+	}
+
+	// Placeholders the file already had are its own
+	if got := write("doc.txt", "keys show as [REDACTED:anthropic_api_key]\nin logs\n"); strings.HasPrefix(got, "Error") {
+		t.Errorf("doc.txt: %s", got)
+	}
+}
@@ -0,0 +1,217 @@
+// Package diff computes line edit scripts and groups them into hunks.
+//
+// It backs the unified diffs of --output=patch and the diffs shown when
+// files are written. Lines keep their newline so a missing newline at the
+// end of a file is a difference like any other.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxEdits bounds the Myers search; texts differing in more lines are
+// diffed as a whole replacement
+const MaxEdits = 2000
+
+// Op kinds
+const (
+	Keep   = ' '
+	Delete = '-'
+	Insert = '+'
+)
+
+// Op is one line of an edit script
+type Op struct {
+	Kind byte // Keep, Delete or Insert
+	Line string
+}
+
+// Hunk is a run of changes with the unchanged lines around them
+type Hunk struct {
+	OldLine, OldCount int // lines of the old text before the hunk, and in it
+	NewLine, NewCount int
+	Ops               []Op
+}
+
+// Lines splits s into lines that keep their newline
+func Lines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Edits returns the line edits turning a into b
+func Edits(a, b []string) []Op {
+	// Most edits change a few lines; matching the ends first keeps the
+	// search small
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre &&
+		a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for _, line := range a[:pre] {
+		ops = append(ops, Op{Keep, line})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, Op{Keep, line})
+	}
+	return ops
+}
+
+// HasChange reports whether ops change anything
+func HasChange(ops []Op) bool {
+	for _, op := range ops {
+		if op.Kind != Keep {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks groups the changes of ops with up to context unchanged lines
+// around them. Changes closer than twice the context share a hunk.
+func Hunks(ops []Op, context int) []Hunk {
+	var spans [][2]int
+	for i, op := range ops {
+		if op.Kind == Keep {
+			continue
+		}
+		start, end := max(0, i-context), min(len(ops), i+1+context)
+		if n := len(spans); n > 0 && start <= spans[n-1][1] {
+			spans[n-1][1] = end
+		} else {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+
+	var hunks []Hunk
+	oldLine, newLine, next := 0, 0, 0 // lines before ops[next]
+	for _, span := range spans {
+		for ; next < span[0]; next++ {
+			oldLine, newLine = advance(ops[next], oldLine, newLine)
+		}
+		h := Hunk{OldLine: oldLine, NewLine: newLine, Ops: ops[span[0]:span[1]]}
+		for _, op := range h.Ops {
+			h.OldCount, h.NewCount = advance(op, h.OldCount, h.NewCount)
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// Header returns the @@ -start,count +start,count @@ line of h
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldLine, h.OldCount),
+		hunkRange(h.NewLine, h.NewCount))
+}
+
+// advance counts op in the old and new line numbers
+func advance(op Op, oldLine, newLine int) (int, int) {
+	if op.Kind != Insert {
+		oldLine++
+	}
+	if op.Kind != Delete {
+		newLine++
+	}
+	return oldLine, newLine
+}
+
+// hunkRange formats the start,count of the count lines after line
+// before; an empty range is named by the line before it
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// myers returns a shortest edit script from a to b (Myers 1986), or a
+// replacement of all lines when more than MaxEdits edits are needed
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	// trace[d][k+d] is the furthest x on diagonal k = x-y after d edits
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > MaxEdits {
+			return replace(a, b)
+		}
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+			case k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]):
+				x = trace[d-1][k+1+d-1] // down: insert b[y]
+			default:
+				x = trace[d-1][k-1+d-1] + 1 // right: delete a[x]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, append(trace, v))
+			}
+		}
+		trace = append(trace, v)
+	}
+	return nil // a and b are empty
+}
+
+// backtrack walks trace back from the end into an edit script
+func backtrack(a, b []string, trace [][]int) []Op {
+	var ops []Op
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		pk := k - 1
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			pk = k + 1
+		}
+		px := prev[pk+d-1]
+		py := px - pk
+		for x > px && y > py {
+			ops = append(ops, Op{Keep, a[x-1]})
+			x--
+			y--
+		}
+		if pk == k+1 {
+			ops = append(ops, Op{Insert, b[y-1]})
+			y--
+		} else {
+			ops = append(ops, Op{Delete, a[x-1]})
+			x--
+		}
+	}
+	for ; x > 0; x-- {
+		ops = append(ops, Op{Keep, a[x-1]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func replace(a, b []string) []Op {
+	ops := make([]Op, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, Op{Delete, line})
+	}
+	for _, line := range b {
+		ops = append(ops, Op{Insert, line})
+	}
+	return ops
+}
@@ -0,0 +1,45 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/diff"
+)
+
+func TestHunks(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	edited := append([]string{}, lines...)
+	edited[1] = "changed\n"
+	edited = append(edited[:12], append([]string{"added\n"}, edited[12:]...)...)
+	ops := diff.Edits(lines, edited)
+
+	for _, tc := range []struct {
+		context int
+		headers []string
+	}{
+		{3, []string{"@@ -1,5 +1,5 @@", "@@ -10,6 +10,7 @@"}},
+		{0, []string{"@@ -2,1 +2,1 @@", "@@ -12,0 +13,1 @@"}},
+		// Changes closer than twice the context share a hunk
+		{6, []string{"@@ -1,18 +1,19 @@"}},
+	} {
+		var headers []string
+		for _, h := range diff.Hunks(ops, tc.context) {
+			headers = append(headers, h.Header())
+		}
+		if strings.Join(headers, " ") != strings.Join(tc.headers, " ") {
+			t.Errorf("context %d: hunks %v, want %v", tc.context, headers, tc.headers)
+		}
+	}
+
+	if diff.HasChange(diff.Edits(lines, lines)) {
+		t.Error("identical lines have changes")
+	}
+	if got := diff.Lines("a\nb"); len(got) != 2 || got[1] != "b" {
+		t.Errorf("Lines = %q", got)
+	}
+}
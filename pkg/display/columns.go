@@ -0,0 +1,113 @@
+package display
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// columns.go - Texts side by side (--compare-models)
+//
+// Columns prints texts next to each other under their titles, wrapping
+// each to the width of its column. On a terminal too narrow for that, or
+// when stdout isn't one, the texts are printed one after the other.
+
+// DefaultColumnsWidth is the width Columns assumes off a terminal
+const DefaultColumnsWidth = 160
+
+// MinColumnWidth is the narrowest column Columns wraps text into
+const MinColumnWidth = 30
+
+// Columns writes texts to w side by side under titles, in at most width
+// columns; width 0 is that of the terminal stdout is
+func Columns(w io.Writer, titles, texts []string, width int) error {
+	if width == 0 {
+		width = terminalWidth(os.Stdout)
+	}
+	if width == 0 {
+		width = DefaultColumnsWidth
+	}
+	n := len(texts)
+	col := (width - 3*(n-1)) / max(n, 1) // " │ " between the columns
+	if col < MinColumnWidth {
+		return sequential(w, titles, texts)
+	}
+
+	wrapped := make([][]string, n)
+	rows := 0
+	for i, text := range texts {
+		wrapped[i] = WrapText(text, col)
+		rows = max(rows, len(wrapped[i]))
+	}
+	var sb strings.Builder
+	row := func(cells []string) {
+		line := strings.Join(cells, " │ ")
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	cells := make([]string, n)
+	for i := range titles {
+		cells[i] = fitColumn(titles[i], col)
+	}
+	row(cells)
+	for i := range cells {
+		cells[i] = strings.Repeat("─", col)
+	}
+	sb.WriteString(strings.Join(cells, "─┼─") + "\n")
+	for r := range rows {
+		for i := range wrapped {
+			line := ""
+			if r < len(wrapped[i]) {
+				line = wrapped[i][r]
+			}
+			cells[i] = fitColumn(line, col)
+		}
+		row(cells)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// sequential writes texts one after the other under their titles
+func sequential(w io.Writer, titles, texts []string) error {
+	var sb strings.Builder
+	for i, text := range texts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("=== " + titles[i] + " ===\n")
+		sb.WriteString(strings.TrimRight(text, "\n") + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WrapText breaks text into lines of at most width runes, at spaces where
+// it can. Tabs are expanded and blank lines kept.
+func WrapText(text string, width int) []string {
+	var lines []string
+	text = strings.ReplaceAll(strings.TrimRight(text, "\n"), "\t", "    ")
+	for _, para := range strings.Split(text, "\n") {
+		// Keep the indentation of code and lists
+		indent := para[:len(para)-len(strings.TrimLeft(para, " "))]
+		line := ""
+		for _, word := range strings.Fields(para) {
+			switch {
+			case line == "":
+				line = indent + word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = indent + word
+			}
+			for utf8.RuneCountInString(line) > width {
+				r := []rune(line)
+				lines = append(lines, string(r[:width]))
+				line = string(r[width:])
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,55 @@
+package display
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"fits", "short text", 30, []string{"short text"}},
+		{"at spaces", "one two three four", 9, []string{"one two", "three", "four"}},
+		{"long words are cut", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"blank lines kept", "a\n\nb\n", 10, []string{"a", "", "b"}},
+		{"indentation kept", "  x y z", 5, []string{"  x y", "  z"}},
+		{"tabs expanded", "\tx", 10, []string{"    x"}},
+		{"runes", "ééé ééé", 3, []string{"ééé", "ééé"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WrapText(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WrapText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	var sb strings.Builder
+	if err := Columns(&sb, []string{"left", "right"},
+		[]string{"one two three four five six seven eight", "short"}, 63); err != nil {
+		t.Fatal(err)
+	}
+	want := "left                           │ right\n" +
+		strings.Repeat("─", 30) + "─┼─" + strings.Repeat("─", 30) + "\n" +
+		"one two three four five six    │ short\n" +
+		"seven eight                    │\n"
+	if got := sb.String(); got != want {
+		t.Errorf("Columns =\n%s\nwant\n%s", got, want)
+	}
+
+	// Too narrow for columns: one text after the other
+	sb.Reset()
+	if err := Columns(&sb, []string{"a", "b"}, []string{"x\n", "y"}, 40); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), "=== a ===\nx\n\n=== b ===\ny\n"; got != want {
+		t.Errorf("narrow Columns = %q, want %q", got, want)
+	}
+}
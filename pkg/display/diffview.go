@@ -0,0 +1,142 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/marcopeereboom/go-claude/pkg/diff"
+	"golang.org/x/term"
+)
+
+// diffview.go - Diff context and side by side view (--diff-context,
+// --diff-view)
+//
+// The diffs shown when files are written keep --diff-context unchanged
+// lines around each change. --diff-view=split shows the old and new
+// lines in two columns instead, when the terminal is wide enough for
+// both; elsewhere the unified view is used.
+
+// Diff views (--diff-view)
+const (
+	DiffUnified = "unified"
+	DiffSplit   = "split"
+)
+
+// DefaultDiffContext is the number of unchanged lines around a change
+const DefaultDiffContext = 3
+
+// MinSplitWidth is the narrowest terminal the split view is used on
+const MinSplitWidth = 100
+
+var (
+	diffContext = DefaultDiffContext
+	diffView    = DiffUnified
+)
+
+// SetDiffOptions sets the context lines and the view of ShowDiff
+func SetDiffOptions(context int, view string) error {
+	if context < 0 {
+		return fmt.Errorf("invalid --diff-context %d (want 0 or more)", context)
+	}
+	if view != DiffUnified && view != DiffSplit {
+		return fmt.Errorf("invalid --diff-view %q (want %s or %s)", view,
+			DiffUnified, DiffSplit)
+	}
+	diffContext, diffView = context, view
+	return nil
+}
+
+// terminalWidth returns the columns of the terminal f, 0 when it isn't one
+func terminalWidth(f *os.File) int {
+	if !IsTTY(f) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// generateSplitDiff renders the diff of old and new in two columns that
+// fit width, old on the left
+func generateSplitDiff(old, new string, width int, color bool) string {
+	oldLines, newLines := diff.Lines(old), diff.Lines(new)
+	ops := diff.Edits(oldLines, newLines)
+	col := (width - 3) / 2 // " │ " between the columns
+	numWidth := len(fmt.Sprint(max(len(oldLines), len(newLines))))
+	textWidth := col - numWidth - 1
+
+	var sb strings.Builder
+	style := func(s, code string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + colorReset
+	}
+	row := func(left, right string) {
+		sb.WriteString(left + " " + style("│", colorGray) + " " + right + "\n")
+	}
+	// side is one column: line number n (0 = none) and text
+	side := func(n int, text, code string) string {
+		num := strings.Repeat(" ", numWidth)
+		if n > 0 {
+			num = fmt.Sprintf("%*d", numWidth, n)
+		}
+		return style(num, colorGray) + " " + style(fitColumn(text, textWidth), code)
+	}
+
+	from, to := diffLabels(old, new)
+	row(style(fitColumn("--- "+from, col), colorBold), style("+++ "+to, colorBold))
+	if !diff.HasChange(ops) {
+		sb.WriteString("(no changes)\n")
+		return sb.String()
+	}
+
+	for _, h := range diff.Hunks(ops, diffContext) {
+		sb.WriteString(style(h.Header(), colorCyan) + "\n")
+		oldN, newN := h.OldLine, h.NewLine
+		for i := 0; i < len(h.Ops); {
+			if h.Ops[i].Kind == diff.Keep {
+				oldN++
+				newN++
+				row(side(oldN, h.Ops[i].Line, ""), side(newN, h.Ops[i].Line, ""))
+				i++
+				continue
+			}
+			// A run of removed lines faces the lines that replace it
+			var dels, ins []string
+			for ; i < len(h.Ops) && h.Ops[i].Kind == diff.Delete; i++ {
+				dels = append(dels, h.Ops[i].Line)
+			}
+			for ; i < len(h.Ops) && h.Ops[i].Kind == diff.Insert; i++ {
+				ins = append(ins, h.Ops[i].Line)
+			}
+			for r := range max(len(dels), len(ins)) {
+				left, right := side(0, "", ""), side(0, "", "")
+				if r < len(dels) {
+					oldN++
+					left = side(oldN, dels[r], colorRed)
+				}
+				if r < len(ins) {
+					newN++
+					right = side(newN, ins[r], colorGreen)
+				}
+				row(left, right)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// fitColumn expands tabs in line and pads or cuts it to width runes
+func fitColumn(line string, width int) string {
+	line = strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\t", "    ")
+	n := utf8.RuneCountInString(line)
+	if n > width {
+		return string([]rune(line)[:max(width-1, 0)]) + "…"
+	}
+	return line + strings.Repeat(" ", width-n)
+}
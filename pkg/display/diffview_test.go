@@ -0,0 +1,97 @@
+package display
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{
+			"changed and added",
+			"a\nb\nc\nd\n", "a\nB\nc\nd\ne\n",
+			[]string{
+				"--- old             │ +++ new",
+				"@@ -1,4 +1,5 @@",
+				"1 a                 │ 1 a                ",
+				"2 b                 │ 2 B                ",
+				"3 c                 │ 3 c                ",
+				"4 d                 │ 4 d                ",
+				"                    │ 5 e                ",
+			},
+		},
+		{
+			"new file",
+			"", "x\n",
+			[]string{
+				"--- /dev/null       │ +++ new file",
+				"@@ -0,0 +1,1 @@",
+				"                    │ 1 x                ",
+			},
+		},
+		{
+			"no changes",
+			"same\n", "same\n",
+			[]string{
+				"--- old             │ +++ new",
+				"(no changes)",
+			},
+		},
+		{
+			"long lines are cut",
+			"a very long line that does not fit\n", "short\n",
+			[]string{
+				"--- old             │ +++ new",
+				"@@ -1,1 +1,1 @@",
+				"1 a very long line… │ 1 short            ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generateSplitDiff(tt.old, tt.new, 41, false)
+			if want := strings.Join(tt.want, "\n") + "\n"; got != want {
+				t.Errorf("generateSplitDiff =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitDiffColor(t *testing.T) {
+	got := generateSplitDiff("a\n", "b\n", 41, true)
+	if !strings.Contains(got, colorRed+"a") || !strings.Contains(got, colorGreen+"b") {
+		t.Errorf("generateSplitDiff without removed in red and added in green: %q", got)
+	}
+	if plain := ansiEscape.ReplaceAllString(got, ""); plain != generateSplitDiff("a\n", "b\n", 41, false) {
+		t.Errorf("colored split diff differs from the plain one: %q", plain)
+	}
+}
+
+func TestSetDiffOptions(t *testing.T) {
+	t.Cleanup(func() { SetDiffOptions(DefaultDiffContext, DiffUnified) })
+	for _, tt := range []struct {
+		context int
+		view    string
+		ok      bool
+	}{
+		{3, DiffUnified, true},
+		{0, DiffSplit, true},
+		{-1, DiffUnified, false},
+		{3, "sideways", false},
+	} {
+		if err := SetDiffOptions(tt.context, tt.view); (err == nil) != tt.ok {
+			t.Errorf("SetDiffOptions(%d, %q) = %v", tt.context, tt.view, err)
+		}
+	}
+
+	// No context: the hunk is the change alone
+	SetDiffOptions(0, DiffUnified)
+	got := generateUnifiedDiff("a\nb\nc\n", "a\nB\nc\n")
+	if want := "--- old\n+++ new\n@@ -2,1 +2,1 @@\n-b\n+B\n"; got != want {
+		t.Errorf("generateUnifiedDiff = %q, want %q", got, want)
+	}
+}
@@ -8,13 +8,14 @@ import (
 	"strings"
 
 	"github.com/alecthomas/chroma/v2/quick"
+	"github.com/marcopeereboom/go-claude/pkg/diff"
 	"golang.org/x/term"
 )
 
 // display.go - Terminal output formatting and syntax highlighting
 //
 // CRITICAL: This file ONLY handles terminal display. It NEVER writes files.
-// All functions that colorize check IsTTY and return plain text if false.
+// All functions that colorize check UseColor and return plain text if false.
 //
 // Separation of concerns:
 // - display.go: Format output for humans (terminal)
@@ -33,16 +34,73 @@ const (
 	colorBold   = "\033[1m"
 )
 
+// colorDisabled turns all terminal formatting off (--ci)
+var colorDisabled bool
+
+// SetColor enables or disables terminal formatting. When disabled IsTTY
+// reports false so output is plain even on a terminal.
+func SetColor(enabled bool) {
+	colorDisabled = !enabled
+}
+
 // IsTTY detects if output is going to a terminal (not a file/pipe)
 func IsTTY(f *os.File) bool {
+	if colorDisabled {
+		return false
+	}
 	return term.IsTerminal(int(f.Fd()))
 }
 
-// ShowDiff displays a unified diff between old and new content.
-// Adds git-style colors if stderr is a TTY.
+// Color modes (--color)
+const (
+	ColorAuto   = "auto"   // on terminals, per NO_COLOR and CLICOLOR_FORCE
+	ColorAlways = "always" // also when piped, e.g. into less -R
+	ColorNever  = "never"
+)
+
+var colorMode = ColorAuto
+
+// SetColorMode sets when output is colored, one of the Color modes
+func SetColorMode(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		colorMode = mode
+		return nil
+	}
+	return fmt.Errorf("invalid --color %q (want %s, %s or %s)", mode,
+		ColorAuto, ColorAlways, ColorNever)
+}
+
+// UseColor reports whether output to f is colored. --color=always and
+// --color=never decide, otherwise a set NO_COLOR turns color off and a
+// CLICOLOR_FORCE other than 0 turns it on (https://no-color.org,
+// https://bixense.com/clicolors), and without either terminals get color.
+func UseColor(f *os.File) bool {
+	switch {
+	case colorMode == ColorAlways:
+		return true
+	case colorMode == ColorNever || colorDisabled:
+		return false
+	case os.Getenv("NO_COLOR") != "":
+		return false
+	case os.Getenv("CLICOLOR_FORCE") != "" && os.Getenv("CLICOLOR_FORCE") != "0":
+		return true
+	}
+	return IsTTY(f)
+}
+
+// ShowDiff displays a diff between old and new content: unified, or side
+// by side with --diff-view=split on a wide enough terminal.
+// Adds git-style colors if stderr is colored.
 // Never modifies the actual content - only display formatting.
 func ShowDiff(old, new string) {
-	usesColor := IsTTY(os.Stderr)
+	usesColor := UseColor(os.Stderr)
+	if diffView == DiffSplit {
+		if width := terminalWidth(os.Stderr); width >= MinSplitWidth {
+			fmt.Fprint(os.Stderr, generateSplitDiff(old, new, width, usesColor))
+			return
+		}
+	}
 	diff := generateUnifiedDiff(old, new)
 
 	// Print line by line with optional coloring
@@ -55,105 +113,45 @@ func ShowDiff(old, new string) {
 	}
 }
 
-// generateUnifiedDiff creates a unified diff between old and new.
+// generateUnifiedDiff creates a unified diff between old and new with
+// --diff-context lines around the changes.
 // Returns plain text (no ANSI codes) - coloring happens in display layer.
 func generateUnifiedDiff(old, new string) string {
 	// Handle edge cases
 	if old == "" && new == "" {
 		return ""
 	}
-	if old == "" {
-		// New file
-		lines := strings.Split(strings.TrimRight(new, "\n"), "\n")
-		var sb strings.Builder
-		sb.WriteString("--- /dev/null\n")
-		sb.WriteString("+++ new file\n")
-		fmt.Fprintf(&sb, "@@ -0,0 +1,%d @@\n", len(lines))
-		for _, line := range lines {
-			sb.WriteString("+" + line + "\n")
-		}
-		return sb.String()
-	}
-	if new == "" {
-		// File deletion
-		lines := strings.Split(strings.TrimRight(old, "\n"), "\n")
-		var sb strings.Builder
-		sb.WriteString("--- old file\n")
-		sb.WriteString("+++ /dev/null\n")
-		fmt.Fprintf(&sb, "@@ -1,%d +0,0 @@\n", len(lines))
-		for _, line := range lines {
-			sb.WriteString("-" + line + "\n")
-		}
-		return sb.String()
-	}
-
-	// Both files exist - compute diff
-	oldLines := strings.Split(strings.TrimRight(old, "\n"), "\n")
-	newLines := strings.Split(strings.TrimRight(new, "\n"), "\n")
-
-	return simpleDiff(oldLines, newLines)
-}
-
-// simpleDiff creates a basic unified diff (not Myers algorithm, but good enough)
-func simpleDiff(oldLines, newLines []string) string {
+	from, to := diffLabels(old, new)
 	var sb strings.Builder
-	sb.WriteString("--- old\n")
-	sb.WriteString("+++ new\n")
+	sb.WriteString("--- " + from + "\n+++ " + to + "\n")
 
-	// Simple line-by-line comparison
-	maxLen := len(oldLines)
-	if len(newLines) > maxLen {
-		maxLen = len(newLines)
+	ops := diff.Edits(diff.Lines(old), diff.Lines(new))
+	if !diff.HasChange(ops) {
+		sb.WriteString("(no changes)\n")
+		return sb.String()
 	}
-
-	// Track changes for hunk header
-	changeStart := -1
-	oldCount := 0
-	newCount := 0
-
-	for i := 0; i < maxLen; i++ {
-		oldLine := ""
-		newLine := ""
-		if i < len(oldLines) {
-			oldLine = oldLines[i]
-		}
-		if i < len(newLines) {
-			newLine = newLines[i]
-		}
-
-		if oldLine != newLine {
-			// Start new hunk if needed
-			if changeStart == -1 {
-				changeStart = i
-			}
-
-			// Track what changed
-			if oldLine != "" && newLine != "" {
-				// Line modified
-				sb.WriteString("-" + oldLine + "\n")
-				sb.WriteString("+" + newLine + "\n")
-				oldCount++
-				newCount++
-			} else if oldLine != "" {
-				// Line deleted
-				sb.WriteString("-" + oldLine + "\n")
-				oldCount++
-			} else {
-				// Line added
-				sb.WriteString("+" + newLine + "\n")
-				newCount++
+	for _, h := range diff.Hunks(ops, diffContext) {
+		sb.WriteString(h.Header() + "\n")
+		for _, op := range h.Ops {
+			sb.WriteString(string(op.Kind) + strings.TrimSuffix(op.Line, "\n") + "\n")
+			if !strings.HasSuffix(op.Line, "\n") {
+				sb.WriteString("\\ No newline at end of file\n")
 			}
 		}
 	}
+	return sb.String()
+}
 
-	if changeStart == -1 {
-		return "--- old\n+++ new\n(no changes)\n"
+// diffLabels names the sides of a diff: a new file has no old side and a
+// deleted one no new side
+func diffLabels(old, new string) (string, string) {
+	switch {
+	case old == "":
+		return "/dev/null", "new file"
+	case new == "":
+		return "old file", "/dev/null"
 	}
-
-	// Prepend hunk header
-	hunkHeader := fmt.Sprintf("@@ -%d,%d +%d,%d @@\n",
-		changeStart+1, oldCount, changeStart+1, newCount)
-	return "--- old\n+++ new\n" + hunkHeader + sb.String()[len("--- old\n+++ new\n"):]
+	return "old", "new"
 }
 
 // printColoredDiffLine prints a single diff line with git-style colors
@@ -190,11 +188,11 @@ func printColoredDiffLine(line string) {
 }
 
 // FormatResponse formats Claude's API response for display.
-// Uses chroma for syntax highlighting if output is a TTY.
+// Uses chroma for syntax highlighting if stdout is colored.
 // Never modifies actual content - only display layer.
 func FormatResponse(w io.Writer, content string) {
-	if !IsTTY(os.Stdout) {
-		// Not a TTY - write plain text (e.g., piped to file)
+	if !UseColor(os.Stdout) {
+		// No color - write plain text (e.g., piped to file)
 		fmt.Fprint(w, content)
 		return
 	}
@@ -211,8 +209,18 @@ func formatMarkdownWithChroma(w io.Writer, content string) {
 	inCodeBlock := false
 	var codeBuffer strings.Builder
 	var codeLang string
+	var table []string // rows of the pipe table being read
 
 	for i, line := range lines {
+		if !inCodeBlock && isTableRow(line) {
+			table = append(table, line)
+			continue
+		}
+		if len(table) > 0 {
+			formatTable(w, table)
+			table = nil
+		}
+
 		// Detect code fence markers
 		if strings.HasPrefix(line, "```") {
 			if inCodeBlock {
@@ -250,6 +258,10 @@ func formatMarkdownWithChroma(w io.Writer, content string) {
 		}
 	}
 
+	if len(table) > 0 {
+		formatTable(w, table)
+	}
+
 	// Handle unclosed code block
 	if inCodeBlock {
 		highlightedCode := highlightCode(codeBuffer.String(), codeLang)
@@ -286,7 +298,7 @@ func formatMarkdownLine(w io.Writer, line string) {
 	// Headers
 	if strings.HasPrefix(line, "#") {
 		fmt.Fprintf(w, "%s%s%s%s\n",
-			colorBold, colorBlue, line, colorReset)
+			colorBold, colorBlue, formatInline(line, colorBold+colorBlue), colorReset)
 		return
 	}
 
@@ -295,31 +307,31 @@ func formatMarkdownLine(w io.Writer, line string) {
 	if strings.HasPrefix(trimmed, "-") ||
 		strings.HasPrefix(trimmed, "*") ||
 		strings.HasPrefix(trimmed, "+") {
-		fmt.Fprintf(w, "%s%s%s\n", colorCyan, line, colorReset)
+		fmt.Fprintf(w, "%s%s%s\n", colorCyan, formatInline(line, colorCyan), colorReset)
 		return
 	}
 
 	// Numbered lists
 	if len(trimmed) > 0 && trimmed[0] >= '0' && trimmed[0] <= '9' {
 		if idx := strings.Index(trimmed, "."); idx > 0 && idx < 4 {
-			fmt.Fprintf(w, "%s%s%s\n", colorCyan, line, colorReset)
+			fmt.Fprintf(w, "%s%s%s\n", colorCyan, formatInline(line, colorCyan), colorReset)
 			return
 		}
 	}
 
 	// Block quotes
 	if strings.HasPrefix(trimmed, ">") {
-		fmt.Fprintf(w, "%s%s%s\n", colorGray, line, colorReset)
+		fmt.Fprintf(w, "%s%s%s\n", colorGray, formatInline(line, colorGray), colorReset)
 		return
 	}
 
 	// Regular text
-	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, formatInline(line, ""))
 }
 
 // ToolHeader prints a styled tool execution header to stderr
 func ToolHeader(name string, dryRun bool) {
-	if !IsTTY(os.Stderr) {
+	if !UseColor(os.Stderr) {
 		if dryRun {
 			fmt.Fprintf(os.Stderr, "\n=== %s (dry-run) ===\n", name)
 		} else {
@@ -340,7 +352,7 @@ func ToolHeader(name string, dryRun bool) {
 
 // ToolResult prints a styled tool execution result to stderr
 func ToolResult(success bool, message string) {
-	if !IsTTY(os.Stderr) {
+	if !UseColor(os.Stderr) {
 		fmt.Fprintln(os.Stderr, message)
 		return
 	}
@@ -357,7 +369,7 @@ func ToolResult(success bool, message string) {
 // Warning prints a warning message to stderr
 func Warning(format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
-	if !IsTTY(os.Stderr) {
+	if !UseColor(os.Stderr) {
 		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
 		return
 	}
@@ -368,7 +380,7 @@ func Warning(format string, args ...interface{}) {
 
 // Info prints an informational message to stderr
 func Info(format string, args ...interface{}) {
-	if !IsTTY(os.Stderr) {
+	if !UseColor(os.Stderr) {
 		fmt.Fprintf(os.Stderr, format+"\n", args...)
 		return
 	}
@@ -0,0 +1,49 @@
+package display
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetColorMode(t *testing.T) {
+	if err := SetColorMode("sometimes"); err == nil {
+		t.Error("SetColorMode accepted an invalid mode")
+	}
+	t.Cleanup(func() { SetColorMode(ColorAuto) })
+
+	// Tests write to a file, never a terminal
+	out, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	tests := []struct {
+		name       string
+		mode       string
+		noColor    string
+		forceColor string
+		want       bool
+	}{
+		{"auto, not a terminal", ColorAuto, "", "", false},
+		{"auto, CLICOLOR_FORCE", ColorAuto, "", "1", true},
+		{"auto, CLICOLOR_FORCE=0", ColorAuto, "", "0", false},
+		{"auto, NO_COLOR wins", ColorAuto, "1", "1", false},
+		{"always", ColorAlways, "", "", true},
+		{"always over NO_COLOR", ColorAlways, "1", "", true},
+		{"never", ColorNever, "", "", false},
+		{"never over CLICOLOR_FORCE", ColorNever, "", "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("CLICOLOR_FORCE", tt.forceColor)
+			if err := SetColorMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if got := UseColor(out); got != tt.want {
+				t.Errorf("UseColor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package display
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// markdown.go - Inline markdown and tables for the terminal
+//
+// Spans inside a line (`code`, **bold**, *italic*, [links](url)) are
+// styled instead of showing their markers, and pipe tables are aligned
+// inside box drawing borders. Like the rest of the display layer this
+// only runs for colored output; plain output keeps the markdown as is.
+
+const (
+	colorItalic    = "\033[3m"
+	colorUnderline = "\033[4m"
+)
+
+// maxLinkWidth is the width links are shortened to
+const maxLinkWidth = 40
+
+// ansiEscape matches the SGR sequences the display layer writes
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// formatInline styles the inline markdown spans of s. base is the style
+// of the surrounding text, restored after each span.
+func formatInline(s, base string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				b.WriteString(colorYellow + s[i+1:i+1+end] + colorReset + base)
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "**") || strings.HasPrefix(s[i:], "__"):
+			delim := s[i : i+2]
+			if end := closingDelim(s, i+2, delim); end > 0 {
+				b.WriteString(colorBold + formatInline(s[i+2:end], base+colorBold) +
+					colorReset + base)
+				i = end + 2
+				continue
+			}
+		case (c == '*' || c == '_') && opensEmphasis(s, i):
+			if end := closingDelim(s, i+1, s[i:i+1]); end > 0 {
+				b.WriteString(colorItalic + formatInline(s[i+1:end], base+colorItalic) +
+					colorReset + base)
+				i = end + 1
+				continue
+			}
+		case c == '[':
+			if text, url, n := parseLink(s[i:]); n > 0 {
+				b.WriteString(colorUnderline + text + colorReset + base)
+				if url != text {
+					b.WriteString(" " + colorGray + "(" + shortenLink(url) + ")" +
+						colorReset + base)
+				}
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// opensEmphasis reports whether the * or _ at s[i] starts a span: it is
+// followed by text, and an _ isn't inside a word like snake_case
+func opensEmphasis(s string, i int) bool {
+	if i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == s[i] {
+		return false
+	}
+	return s[i] == '*' || i == 0 || !isWordByte(s[i-1])
+}
+
+// closingDelim returns the index of the delim closing a span whose text
+// starts at from, or -1
+func closingDelim(s string, from int, delim string) int {
+	for j := from + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' {
+			continue
+		}
+		// A lone * or _ isn't part of a ** or __
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			j++
+			continue
+		}
+		if delim[0] == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) ||
+		unicode.IsDigit(rune(c))
+}
+
+// parseLink parses the [text](url) starting s and returns its length, 0
+// when s doesn't start with a link
+func parseLink(s string) (string, string, int) {
+	mid := strings.Index(s, "](")
+	if mid <= 1 || strings.ContainsAny(s[1:mid], "[]") {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[mid+2:], ')')
+	if end <= 0 || strings.ContainsRune(s[mid+2:mid+2+end], ' ') {
+		return "", "", 0
+	}
+	return s[1:mid], s[mid+2 : mid+2+end], mid + 3 + end
+}
+
+// shortenLink drops the scheme of url and cuts it to maxLinkWidth
+func shortenLink(url string) string {
+	for _, scheme := range []string{"https://", "http://"} {
+		url = strings.TrimPrefix(url, scheme)
+	}
+	url = strings.TrimPrefix(strings.TrimSuffix(url, "/"), "www.")
+	if utf8.RuneCountInString(url) <= maxLinkWidth {
+		return url
+	}
+	return string([]rune(url)[:maxLinkWidth-1]) + "…"
+}
+
+// isTableRow reports whether line is a row of a pipe table
+func isTableRow(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) > 1 && trimmed[0] == '|'
+}
+
+// tableCells splits a table row into its trimmed cells
+func tableCells(line string) []string {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "|")
+	trimmed = strings.TrimSuffix(trimmed, "|")
+	// An escaped \| is part of the cell
+	trimmed = strings.ReplaceAll(trimmed, `\|`, "\x00")
+	cells := strings.Split(trimmed, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(strings.ReplaceAll(cell, "\x00", "|"))
+	}
+	return cells
+}
+
+// tableAlignments parses the |---|:--:|--:| row under a table header, ok
+// false when line isn't one
+func tableAlignments(line string) ([]byte, bool) {
+	cells := tableCells(line)
+	aligns := make([]byte, len(cells))
+	for i, cell := range cells {
+		if strings.Trim(cell, ":-") != "" || !strings.Contains(cell, "-") {
+			return nil, false
+		}
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[i] = 'c'
+		case right:
+			aligns[i] = 'r'
+		default:
+			aligns[i] = 'l'
+		}
+	}
+	return aligns, true
+}
+
+// formatTable writes the lines of a pipe table aligned in box drawing
+// borders. Lines that aren't a table (no alignment row) are written as
+// regular lines.
+func formatTable(w io.Writer, lines []string) {
+	var aligns []byte
+	ok := false
+	if len(lines) >= 2 {
+		aligns, ok = tableAlignments(lines[1])
+	}
+	if !ok {
+		for _, line := range lines {
+			formatMarkdownLine(w, line)
+		}
+		return
+	}
+
+	rows := [][]string{tableCells(lines[0])}
+	for _, line := range lines[2:] {
+		rows = append(rows, tableCells(line))
+	}
+	cols := len(aligns)
+	widths := make([]int, cols)
+	for r, row := range rows {
+		base := ""
+		if r == 0 {
+			base = colorBold
+		}
+		cells := make([]string, cols)
+		for c := range cells {
+			if c < len(row) {
+				cells[c] = base + formatInline(row[c], base) + colorReset
+			}
+			widths[c] = max(widths[c], visibleWidth(cells[c]))
+		}
+		rows[r] = cells
+	}
+
+	border := func(left, mid, right string) {
+		parts := make([]string, cols)
+		for c, width := range widths {
+			parts[c] = strings.Repeat("─", width+2)
+		}
+		io.WriteString(w, colorGray+left+strings.Join(parts, mid)+right+colorReset+"\n")
+	}
+	bar := colorGray + "│" + colorReset
+	border("┌", "┬", "┐")
+	for r, row := range rows {
+		var b strings.Builder
+		b.WriteString(bar)
+		for c, cell := range row {
+			b.WriteString(" " + pad(cell, widths[c], aligns[c]) + " " + bar)
+		}
+		io.WriteString(w, b.String()+"\n")
+		if r == 0 {
+			border("├", "┼", "┤")
+		}
+	}
+	border("└", "┴", "┘")
+}
+
+// visibleWidth is the number of runes of s shown on the terminal
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+// pad pads cell to width, aligned left (l), right (r) or centered (c)
+func pad(cell string, width int, align byte) string {
+	space := width - visibleWidth(cell)
+	switch align {
+	case 'r':
+		return strings.Repeat(" ", space) + cell
+	case 'c':
+		return strings.Repeat(" ", space/2) + cell + strings.Repeat(" ", space-space/2)
+	}
+	return cell + strings.Repeat(" ", space)
+}
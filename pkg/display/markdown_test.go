@@ -0,0 +1,110 @@
+package display
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatInline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "nothing to style", "nothing to style"},
+		{"code", "run `go test`", "run " + colorYellow + "go test" + colorReset},
+		{"bold", "a **big** deal", "a " + colorBold + "big" + colorReset + " deal"},
+		{"bold underscores", "__big__", colorBold + "big" + colorReset},
+		{"italic", "an *odd* one", "an " + colorItalic + "odd" + colorReset + " one"},
+		{"nested", "**very *odd* one**", colorBold + "very " + colorItalic + "odd" +
+			colorReset + colorBold + " one" + colorReset},
+		{"snake_case", "use snake_case_names", "use snake_case_names"},
+		{"lone star", "2 * 3 * 4", "2 * 3 * 4"},
+		{"unclosed code", "a `b", "a `b"},
+		{"code keeps markers", "`**x**`", colorYellow + "**x**" + colorReset},
+		{"link", "see [the docs](https://www.example.com/docs/)",
+			"see " + colorUnderline + "the docs" + colorReset + " " + colorGray +
+				"(example.com/docs)" + colorReset},
+		{"bare link", "[https://go.dev](https://go.dev)",
+			colorUnderline + "https://go.dev" + colorReset},
+		{"not a link", "[a] (b)", "[a] (b)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatInline(tt.in, ""); got != tt.want {
+				t.Errorf("formatInline(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatInlineBase(t *testing.T) {
+	// The style of the surrounding text comes back after a span
+	got := formatInline("a `b` c", colorBold)
+	if want := "a " + colorYellow + "b" + colorReset + colorBold + " c"; got != want {
+		t.Errorf("formatInline = %q, want %q", got, want)
+	}
+}
+
+func TestShortenLink(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("é", 50)
+	got := shortenLink(long)
+	if n := len([]rune(got)); n != maxLinkWidth {
+		t.Errorf("shortenLink = %q, %d runes, want %d", got, n, maxLinkWidth)
+	}
+	if !strings.HasSuffix(got, "é…") {
+		t.Errorf("shortenLink = %q, cut inside a rune", got)
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{
+			"aligned",
+			[]string{
+				"| Name | Count | Note |",
+				"|:-----|------:|:----:|",
+				"| go | 1 | `fast` |",
+				"| rust | 12 | x |",
+			},
+			"┌──────┬───────┬──────┐\n" +
+				"│ Name │ Count │ Note │\n" +
+				"├──────┼───────┼──────┤\n" +
+				"│ go   │     1 │ fast │\n" +
+				"│ rust │    12 │  x   │\n" +
+				"└──────┴───────┴──────┘\n",
+		},
+		{
+			"short rows and escaped pipes",
+			[]string{
+				"| a | b |",
+				"|---|---|",
+				`| x \| y |`,
+			},
+			"┌───────┬───┐\n" +
+				"│ a     │ b │\n" +
+				"├───────┼───┤\n" +
+				"│ x | y │   │\n" +
+				"└───────┴───┘\n",
+		},
+		{
+			"no alignment row",
+			[]string{"| just | pipes |", "| more | text |"},
+			"| just | pipes |\n| more | text |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			formatTable(&buf, tt.lines)
+			if got := ansiEscape.ReplaceAllString(buf.String(), ""); got != tt.want {
+				t.Errorf("formatTable =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package display
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// notify.go - Completion notifications (--notify)
+
+// Notify rings the terminal bell and shows a desktop notification with
+// notify-send (Linux) or osascript (macOS). Both are best effort: a missing
+// helper or a non-TTY stderr just skips that part.
+func Notify(title, message string) {
+	if IsTTY(os.Stderr) {
+		fmt.Fprint(os.Stderr, "\a")
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			cmd = exec.Command(path, title, message)
+		}
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf(
+			"display notification %s with title %s",
+			appleScriptString(message), appleScriptString(title)))
+	}
+	if cmd == nil {
+		return
+	}
+	if err := cmd.Run(); err != nil {
+		slog.Debug("desktop notification failed", "err", err)
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	out := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out = append(out, '\\', c)
+		case '\n':
+			out = append(out, ' ')
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(append(out, '"'))
+}
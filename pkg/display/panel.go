@@ -0,0 +1,136 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// panel.go - Live status panel (--watch)
+//
+// The panel keeps the bottom lines of the terminal for a status that is
+// redrawn in place. A scroll region above it takes the rest of the output,
+// so tool headers, diffs and warnings scroll by without tearing the panel.
+
+// panelRefresh is how often the panel is redrawn, so elapsed times tick
+const panelRefresh = 250 * time.Millisecond
+
+// Panel is a status panel at the bottom of stderr
+type Panel struct {
+	render func() []string
+	height int
+
+	mu   sync.Mutex
+	rows int // terminal height the scroll region was set for
+
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	stopped sync.Once
+}
+
+// StartPanel reserves the bottom height lines of the terminal for the lines
+// render returns, redrawn until Stop. It returns nil, which Refresh and Stop
+// accept, when stderr isn't a terminal or is too small.
+func StartPanel(height int, render func() []string) *Panel {
+	if !IsTTY(os.Stderr) {
+		return nil
+	}
+	_, rows, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || rows <= height+2 {
+		return nil
+	}
+	p := &Panel{render: render, height: height, stop: make(chan struct{})}
+
+	// Make room below the cursor, then keep the output above the panel
+	fmt.Fprint(os.Stderr, strings.Repeat("\n", height)+fmt.Sprintf("\033[%dA", height))
+	p.draw()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(panelRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.draw()
+			}
+		}
+	}()
+	return p
+}
+
+// Refresh redraws the panel now, e.g. after its contents changed
+func (p *Panel) Refresh() {
+	if p == nil {
+		return
+	}
+	p.draw()
+}
+
+// draw renders the panel lines into the bottom of the terminal and leaves
+// the cursor where the output is
+func (p *Panel) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	width, rows, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || rows <= p.height+2 {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("\0337") // save the cursor
+	if rows != p.rows {
+		// Setting the region homes the cursor; it is restored below
+		fmt.Fprintf(&sb, "\033[1;%dr", rows-p.height)
+		p.rows = rows
+	}
+	lines := p.render()
+	for i := 0; i < p.height; i++ {
+		fmt.Fprintf(&sb, "\033[%d;1H\033[2K", rows-p.height+1+i)
+		if i < len(lines) {
+			sb.WriteString(fitWidth(lines[i], width-1))
+		}
+	}
+	sb.WriteString("\0338") // restore the cursor
+	fmt.Fprint(os.Stderr, sb.String())
+}
+
+// Stop removes the panel and gives its lines back to the output
+func (p *Panel) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopped.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		var sb strings.Builder
+		sb.WriteString("\0337\033[r") // the whole screen scrolls again
+		for i := 0; i < p.height; i++ {
+			fmt.Fprintf(&sb, "\033[%d;1H\033[2K", p.rows-p.height+1+i)
+		}
+		sb.WriteString("\0338")
+		fmt.Fprint(os.Stderr, sb.String())
+	})
+}
+
+// fitWidth cuts s to width runes, marking the cut with an ellipsis
+func fitWidth(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", " ")
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(r[:width-1]) + "…"
+}
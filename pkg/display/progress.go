@@ -0,0 +1,55 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// progress.go - Status line while waiting (e.g. for the LLM)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Spinner is an animated status line on stderr
+type Spinner struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// StartSpinner shows "⠋ label (12s)" on stderr until Stop. It returns nil,
+// which Stop accepts, when stderr isn't a terminal.
+func StartSpinner(label string) *Spinner {
+	if !IsTTY(os.Stderr) {
+		return nil
+	}
+	s := &Spinner{stop: make(chan struct{})}
+	start := time.Now()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			fmt.Fprintf(os.Stderr, "\r%s %s (%s)\033[K",
+				spinnerFrames[frame%len(spinnerFrames)], label,
+				time.Since(start).Truncate(time.Second))
+			select {
+			case <-s.stop:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop clears the status line
+func (s *Spinner) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const bedrockAPIVersion = "bedrock-2023-05-31"
+
+// AWSCredentials authenticate Bedrock requests, either with SigV4 access
+// keys or with a Bedrock API key (BearerToken).
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	BearerToken     string
+}
+
+// BedrockClient implements the LLM interface for Claude on AWS Bedrock.
+type BedrockClient struct {
+	region  string
+	baseURL string
+	creds   AWSCredentials
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewBedrock creates a new Bedrock runtime client.
+func NewBedrock(region string, creds AWSCredentials) *BedrockClient {
+	return &BedrockClient{
+		region:  region,
+		baseURL: fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region),
+		creds:   creds,
+		client:  defaultClient,
+		now:     time.Now,
+	}
+}
+
+// NewBedrockFromEnv creates a Bedrock client from the standard AWS
+// environment variables (AWS_REGION, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN) or AWS_BEARER_TOKEN_BEDROCK.
+func NewBedrockFromEnv() (*BedrockClient, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("bedrock: AWS_REGION not set")
+	}
+
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		BearerToken:     os.Getenv("AWS_BEARER_TOKEN_BEDROCK"),
+	}
+	if creds.BearerToken == "" && (creds.AccessKeyID == "" || creds.SecretAccessKey == "") {
+		return nil, fmt.Errorf("bedrock: set AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or AWS_BEARER_TOKEN_BEDROCK")
+	}
+	return NewBedrock(region, creds), nil
+}
+
+// GetCapabilities returns the capabilities of Claude models on Bedrock.
+func (b *BedrockClient) GetCapabilities() ModelCapabilities {
+	return ModelCapabilities{
+		SupportsTools:       true,
+		SupportsVision:      true,
+		SupportsStreaming:   true,
+		MaxContextTokens:    200000,
+		Provider:            "bedrock",
+		RecommendedForTasks: []string{"code", "reasoning", "analysis", "writing"},
+	}
+}
+
+// Generate sends a request to Claude via Bedrock InvokeModel.
+func (b *BedrockClient) Generate(ctx context.Context, req *Request) (*Response, error) {
+	reqBody, err := marshalMessagesRequest(req, map[string]interface{}{
+		"anthropic_version": bedrockAPIVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Model IDs contain ':' which must be percent-encoded in the path
+	modelID := BedrockModelID(req.Model)
+	endpoint := b.baseURL + "/model/" + awsEscape(modelID) + "/invoke"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("accept", "application/json")
+
+	if b.creds.BearerToken != "" {
+		httpReq.Header.Set("authorization", "Bearer "+b.creds.BearerToken)
+	} else {
+		signV4(httpReq, reqBody, b.creds, b.region, "bedrock", b.now())
+	}
+
+	resp, err := doMessagesRequest(b.client, httpReq)
+	return req.prefilled(resp), err
+}
+
+// ListModels returns the Claude models available on Bedrock.
+func (b *BedrockClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return claudeModels("bedrock"), nil
+}
+
+// BedrockModelID converts an Anthropic model ID to a Bedrock model ID
+// (claude-sonnet-4-20250514 -> anthropic.claude-sonnet-4-20250514-v1:0).
+// IDs that already name a Bedrock model or inference profile are returned
+// unchanged.
+func BedrockModelID(model string) string {
+	if strings.Contains(model, "anthropic.") || strings.HasPrefix(model, "arn:") {
+		return model
+	}
+	return "anthropic." + model + "-v1:0"
+}
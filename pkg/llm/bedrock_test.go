@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBedrockGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		creds    AWSCredentials
+		wantAuth string
+	}{
+		{
+			"sigv4",
+			AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "sess"},
+			"AWS4-HMAC-SHA256 Credential=AKID/20260105/us-west-2/bedrock/aws4_request",
+		},
+		{
+			"api key",
+			AWSCredentials{BearerToken: "bedrock-key"},
+			"Bearer bedrock-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotAuth, gotToken string
+			var gotBody map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.EscapedPath()
+				gotAuth = r.Header.Get("authorization")
+				gotToken = r.Header.Get("x-amz-security-token")
+				json.NewDecoder(r.Body).Decode(&gotBody)
+				json.NewEncoder(w).Encode(claudeResponse{
+					Content:    []ContentBlock{{Type: "text", Text: "from bedrock"}},
+					StopReason: "end_turn",
+				})
+			}))
+			defer server.Close()
+
+			client := NewBedrock("us-west-2", tt.creds)
+			client.baseURL = server.URL
+			client.now = func() time.Time { return time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC) }
+
+			resp, err := client.Generate(context.Background(), &Request{
+				Model:     "claude-sonnet-4-20250514",
+				MaxTokens: 100,
+				Messages:  []MessageContent{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "hi"}}}},
+			})
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if resp.Content[0].Text != "from bedrock" {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+			if gotPath != "/model/anthropic.claude-sonnet-4-20250514-v1%3A0/invoke" {
+				t.Errorf("path = %s", gotPath)
+			}
+			if !strings.HasPrefix(gotAuth, tt.wantAuth) {
+				t.Errorf("authorization = %q, want prefix %q", gotAuth, tt.wantAuth)
+			}
+			if tt.creds.SessionToken != "" && gotToken != tt.creds.SessionToken {
+				t.Errorf("x-amz-security-token = %q", gotToken)
+			}
+			if gotBody["anthropic_version"] != bedrockAPIVersion {
+				t.Errorf("anthropic_version = %v", gotBody["anthropic_version"])
+			}
+		})
+	}
+}
+
+func TestBedrockModelID(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"claude-sonnet-4-20250514", "anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"us.anthropic.claude-sonnet-4-20250514-v1:0", "us.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"arn:aws:bedrock:us-east-1:123:inference-profile/x", "arn:aws:bedrock:us-east-1:123:inference-profile/x"},
+	}
+	for _, tt := range tests {
+		if got := BedrockModelID(tt.in); got != tt.want {
+			t.Errorf("BedrockModelID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewBedrockFromEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := NewBedrockFromEnv(); err == nil {
+		t.Error("expected error without region")
+	}
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_BEARER_TOKEN_BEDROCK", "")
+	if _, err := NewBedrockFromEnv(); err == nil {
+		t.Error("expected error without credentials")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	if _, err := NewBedrockFromEnv(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -131,6 +131,29 @@ func TestOllamaCapabilities_ToolSupportDetection(t *testing.T) {
 	}
 }
 
+func TestOllamaCapabilities_VisionDetection(t *testing.T) {
+	tests := []struct {
+		model  string
+		vision bool
+	}{
+		{"llava:7b", true},
+		{"moondream:latest", true},
+		{"llama3.2-vision:11b", true},
+		{"llama3.2:3b", false},
+		{"qwen2.5-coder:7b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			caps := llm.NewOllama(tt.model, "http://localhost:11434").GetCapabilities()
+			if caps.SupportsVision != tt.vision {
+				t.Errorf("Model %s: expected SupportsVision=%v, got %v",
+					tt.model, tt.vision, caps.SupportsVision)
+			}
+		})
+	}
+}
+
 func TestOllamaCapabilities_ContextSizeDetection(t *testing.T) {
 	tests := []struct {
 		model            string
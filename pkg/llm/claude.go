@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 const (
@@ -15,17 +18,19 @@ const (
 
 // ClaudeClient implements the LLM interface for Claude API.
 type ClaudeClient struct {
-	apiKey  string
-	baseURL string
-	client  *http.Client
+	keys      []*apiKey
+	endpoints []*endpoint
+	client    *http.Client
 }
 
-// NewClaude creates a new Claude client.
+// NewClaude creates a new Claude client. apiKey may list several
+// comma-separated keys to spread requests over (see keys.go), baseURL
+// several endpoints to fail over between (see endpoints.go).
 func NewClaude(apiKey, baseURL string) *ClaudeClient {
 	return &ClaudeClient{
-		apiKey:  apiKey,
-		baseURL: baseURL,
-		client:  &http.Client{},
+		keys:      keysFor(apiKey),
+		endpoints: endpointsFor(baseURL),
+		client:    defaultClient,
 	}
 }
 
@@ -43,46 +48,127 @@ func (c *ClaudeClient) GetCapabilities() ModelCapabilities {
 
 // Generate sends a request to Claude API.
 func (c *ClaudeClient) Generate(ctx context.Context, req *Request) (*Response, error) {
-	// Convert to Claude API format
+	reqBody, err := marshalMessagesRequest(req, map[string]interface{}{
+		"model": req.Model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	tried := 0
+	ks := orderKeys(c.keys, time.Now())
+	for _, ep := range orderEndpoints(c.endpoints, time.Now()) {
+		var header http.Header
+		for i, k := range ks {
+			httpReq, err := http.NewRequestWithContext(ctx, "POST", ep.url, bytes.NewReader(reqBody))
+			if err != nil {
+				return nil, fmt.Errorf("creating request: %w", err)
+			}
+
+			httpReq.Header.Set("x-api-key", k.key)
+			httpReq.Header.Set("anthropic-version", claudeAPIVersion)
+			httpReq.Header.Set("content-type", "application/json")
+
+			var resp *Response
+			resp, header, err = sendMessagesRequest(c.client, httpReq)
+			if err == nil {
+				if len(ks) > 1 {
+					// The rate limits are the key's, not the endpoint's
+					k.succeeded(header, time.Now())
+					header = nil
+				}
+				ep.succeeded(header, time.Now())
+				resp.KeyID = KeyID(k.key)
+				return req.prefilled(resp), nil
+			}
+			tried++
+			lastErr = err
+			if len(ks) == 1 || ctx.Err() != nil || !isRateLimited(err) {
+				break
+			}
+			k.limited(header, time.Now())
+			if i < len(ks)-1 {
+				slog.Warn("Claude API key rate limited, trying the next", "key", KeyID(k.key))
+			}
+		}
+		if ctx.Err() != nil || !ep.failed(lastErr, header, time.Now()) {
+			break
+		}
+		if len(c.endpoints) > 1 {
+			slog.Warn("Claude endpoint failed, trying the next", "url", ep.url, "err", lastErr)
+		}
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("no Claude API URL")
+	}
+	if tried > 1 {
+		return nil, fmt.Errorf("%d attempts failed, last: %w", tried, lastErr)
+	}
+	return nil, lastErr
+}
+
+// isRateLimited reports whether err is a 429 answer
+func isRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// marshalMessagesRequest builds a Messages API body. Vertex and Bedrock use
+// the same schema but carry the model in the URL and the API version in the
+// body, so callers add those via extra.
+func marshalMessagesRequest(req *Request, extra map[string]interface{}) ([]byte, error) {
 	apiReq := map[string]interface{}{
-		"model":      req.Model,
 		"max_tokens": req.MaxTokens,
-		"messages":   req.Messages,
+		"messages":   req.prefillMessages(),
 	}
-	if req.System != "" {
+	for k, v := range extra {
+		apiReq[k] = v
+	}
+	if len(req.SystemBlocks) > 0 {
+		apiReq["system"] = req.SystemBlocks
+	} else if req.System != "" {
 		apiReq["system"] = req.System
 	}
 	if len(req.Tools) > 0 {
 		apiReq["tools"] = req.Tools
+		if req.ToolChoice != nil {
+			apiReq["tool_choice"] = req.ToolChoice
+		}
+	}
+	if req.Temperature != nil {
+		apiReq["temperature"] = *req.Temperature
 	}
 
 	reqBody, err := json.Marshal(apiReq)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
+	return reqBody, nil
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	httpReq.Header.Set("x-api-key", c.apiKey)
-	httpReq.Header.Set("anthropic-version", claudeAPIVersion)
-	httpReq.Header.Set("content-type", "application/json")
+// doMessagesRequest sends a Messages API request and parses the response
+func doMessagesRequest(client *http.Client, httpReq *http.Request) (*Response, error) {
+	resp, _, err := sendMessagesRequest(client, httpReq)
+	return resp, err
+}
 
-	resp, err := c.client.Do(httpReq)
+// sendMessagesRequest is doMessagesRequest that also returns the response
+// headers, nil when there was no response
+func sendMessagesRequest(client *http.Client, httpReq *http.Request) (*Response, http.Header, error) {
+	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("making API call: %w", err)
+		return nil, nil, fmt.Errorf("making API call: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		return nil, resp.Header, fmt.Errorf("reading response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, parseClaudeError(resp.StatusCode, respBody)
+		return nil, resp.Header, parseClaudeError(resp.StatusCode, respBody)
 	}
 
 	var apiResp struct {
@@ -91,52 +177,62 @@ func (c *ClaudeClient) Generate(ctx context.Context, req *Request) (*Response, e
 		Usage      Usage          `json:"usage"`
 	}
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("parsing response: %w", err)
+		return nil, resp.Header, fmt.Errorf("parsing response: %w", err)
 	}
 
 	return &Response{
 		Content:    apiResp.Content,
 		StopReason: apiResp.StopReason,
 		Usage:      apiResp.Usage,
-	}, nil
+	}, resp.Header, nil
 }
 
 // ListModels returns available Claude models.
 func (c *ClaudeClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	// Claude doesn't have a public models API endpoint yet
 	// Return hardcoded list of known models
+	return claudeModels("claude"), nil
+}
+
+// claudeModels returns the known Claude models tagged with provider
+func claudeModels(provider string) []ModelInfo {
 	return []ModelInfo{
 		{
-			ID:          "claude-opus-4-20250514",
-			Name:        "claude-opus-4-20250514",
-			Description: "Claude Opus 4",
-			Provider:    "claude",
+			ID:              "claude-opus-4-20250514",
+			Name:            "claude-opus-4-20250514",
+			Description:     "Claude Opus 4",
+			Provider:        provider,
+			MaxOutputTokens: 32000,
 		},
 		{
-			ID:          "claude-sonnet-4-20250514",
-			Name:        "claude-sonnet-4-20250514",
-			Description: "Claude Sonnet 4",
-			Provider:    "claude",
+			ID:              "claude-sonnet-4-20250514",
+			Name:            "claude-sonnet-4-20250514",
+			Description:     "Claude Sonnet 4",
+			Provider:        provider,
+			MaxOutputTokens: 64000,
 		},
 		{
-			ID:          "claude-sonnet-4-5-20250929",
-			Name:        "claude-sonnet-4-5-20250929",
-			Description: "Claude Sonnet 4.5",
-			Provider:    "claude",
+			ID:              "claude-sonnet-4-5-20250929",
+			Name:            "claude-sonnet-4-5-20250929",
+			Description:     "Claude Sonnet 4.5",
+			Provider:        provider,
+			MaxOutputTokens: 64000,
 		},
 		{
-			ID:          "claude-haiku-4-5-20251001",
-			Name:        "claude-haiku-4-5-20251001",
-			Description: "Claude Haiku 4.5",
-			Provider:    "claude",
+			ID:              "claude-haiku-4-5-20251001",
+			Name:            "claude-haiku-4-5-20251001",
+			Description:     "Claude Haiku 4.5",
+			Provider:        provider,
+			MaxOutputTokens: 64000,
 		},
 		{
-			ID:          "claude-3-5-sonnet-20241022",
-			Name:        "claude-3-5-sonnet-20241022",
-			Description: "Claude 3.5 Sonnet",
-			Provider:    "claude",
+			ID:              "claude-3-5-sonnet-20241022",
+			Name:            "claude-3-5-sonnet-20241022",
+			Description:     "Claude 3.5 Sonnet",
+			Provider:        provider,
+			MaxOutputTokens: 8192,
 		},
-	}, nil
+	}
 }
 
 func parseClaudeError(statusCode int, body []byte) error {
@@ -147,7 +243,7 @@ func parseClaudeError(statusCode int, body []byte) error {
 		} `json:"error"`
 	}
 	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Type != "" {
-		return fmt.Errorf("API error [%s]: %s", apiErr.Error.Type, apiErr.Error.Message)
+		return &APIError{StatusCode: statusCode, Type: apiErr.Error.Type, Message: apiErr.Error.Message}
 	}
-	return fmt.Errorf("API error %d: %s", statusCode, string(body))
+	return &APIError{StatusCode: statusCode, Message: string(body)}
 }
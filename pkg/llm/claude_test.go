@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/marcopeereboom/go-claude/pkg/llm/recorder"
 )
 
 // Test helper types to match Claude API response structure
@@ -282,3 +284,317 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestClaudeGenerate_Temperature(t *testing.T) {
+	zero := 0.0
+	tests := []struct {
+		name        string
+		temperature *float64
+		wantSet     bool
+	}{
+		{"default", nil, false},
+		{"zero", &zero, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					json.NewDecoder(r.Body).Decode(&body)
+					json.NewEncoder(w).Encode(claudeResponse{
+						Content:    []ContentBlock{{Type: "text", Text: "ok"}},
+						StopReason: "end_turn",
+					})
+				}))
+			defer server.Close()
+
+			client := NewClaude("test-key", server.URL)
+			_, err := client.Generate(context.Background(), &Request{
+				Model:       "claude-sonnet-4-5-20250929",
+				MaxTokens:   100,
+				Temperature: tt.temperature,
+			})
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			temp, ok := body["temperature"]
+			if ok != tt.wantSet {
+				t.Fatalf("temperature sent = %v, want %v", ok, tt.wantSet)
+			}
+			if ok && temp != 0.0 {
+				t.Errorf("temperature = %v, want 0", temp)
+			}
+		})
+	}
+}
+
+func TestClaudeGenerate_ToolChoice(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body = nil
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(claudeResponse{
+				Content:    []ContentBlock{{Type: "text", Text: "ok"}},
+				StopReason: "end_turn",
+			})
+		}))
+	defer server.Close()
+
+	client := NewClaude("test-key", server.URL)
+	req := &Request{
+		Model:      "claude-sonnet-4-5-20250929",
+		MaxTokens:  100,
+		Tools:      []Tool{{Name: "write_file"}},
+		ToolChoice: &ToolChoice{Type: ToolChoiceTool, Name: "write_file"},
+	}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	choice, _ := body["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != "write_file" {
+		t.Errorf("tool_choice = %v, want tool write_file", body["tool_choice"])
+	}
+
+	// The API rejects tool_choice without tools
+	req.Tools = nil
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, ok := body["tool_choice"]; ok {
+		t.Error("tool_choice sent without tools")
+	}
+}
+
+func TestClaudeGenerate_SystemBlocks(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body = nil
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(claudeResponse{
+				Content:    []ContentBlock{{Type: "text", Text: "ok"}},
+				StopReason: "end_turn",
+			})
+		}))
+	defer server.Close()
+
+	client := NewClaude("test-key", server.URL)
+	req := &Request{
+		Model:     "claude-sonnet-4-5-20250929",
+		MaxTokens: 100,
+		System:    "be terse\n\nuse tabs",
+		SystemBlocks: []SystemBlock{
+			{Type: "text", Text: "be terse", CacheControl: &CacheControl{Type: "ephemeral"}},
+			{Type: "text", Text: "use tabs"},
+		},
+	}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	system, _ := body["system"].([]interface{})
+	if len(system) != 2 {
+		t.Fatalf("system = %v, want 2 blocks", body["system"])
+	}
+	first, _ := system[0].(map[string]interface{})
+	second, _ := system[1].(map[string]interface{})
+	if first["text"] != "be terse" || first["cache_control"] == nil ||
+		second["text"] != "use tabs" || second["cache_control"] != nil {
+		t.Errorf("system = %v", system)
+	}
+	if got := req.SystemText(); got != req.System {
+		t.Errorf("SystemText = %q, want %q", got, req.System)
+	}
+
+	req.SystemBlocks = nil
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if body["system"] != req.System {
+		t.Errorf("system = %v, want the string", body["system"])
+	}
+}
+
+func TestClaudeGenerate_Prefill(t *testing.T) {
+	var body struct {
+		Messages []MessageContent `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(claudeResponse{
+				Content:    []ContentBlock{{Type: "text", Text: "\n{\"ok\": true}\n```"}},
+				StopReason: "end_turn",
+			})
+		}))
+	defer server.Close()
+
+	client := NewClaude("test-key", server.URL)
+	req := &Request{
+		Model:     "claude-sonnet-4-5-20250929",
+		MaxTokens: 100,
+		Messages: []MessageContent{{Role: "user",
+			Content: []ContentBlock{{Type: "text", Text: "status?"}}}},
+		Prefill: "```json\n",
+	}
+	resp, err := client.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(body.Messages) != 2 || body.Messages[1].Role != "assistant" ||
+		body.Messages[1].Content[0].Text != "```json" {
+		t.Errorf("messages = %+v, want the prefill without trailing whitespace last", body.Messages)
+	}
+	if len(req.Messages) != 1 {
+		t.Errorf("request messages changed: %+v", req.Messages)
+	}
+	if got := resp.Content[0].Text; got != "```json\n{\"ok\": true}\n```" {
+		t.Errorf("response text = %q, want it to start with the prefill", got)
+	}
+}
+
+func TestClaudeGenerate_EndpointFailover(t *testing.T) {
+	// server answers its calls with statuses in order, then with 200
+	server := func(statuses ...int) (*httptest.Server, *int) {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls <= len(statuses) {
+				w.WriteHeader(statuses[calls-1])
+				w.Write([]byte(`{"error":{"type":"overloaded_error","message":"Overloaded"}}`))
+				return
+			}
+			w.Header().Set("anthropic-ratelimit-requests-remaining", "0")
+			w.Header().Set("anthropic-ratelimit-requests-reset", time.Now().Add(time.Hour).Format(time.RFC3339))
+			w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &calls
+	}
+	req := &Request{Model: "claude-sonnet-4-20250514", MaxTokens: 10}
+
+	direct, directCalls := server(529)
+	gateway, gatewayCalls := server()
+	client := NewClaude("test-key", direct.URL+", "+gateway.URL)
+
+	// An overloaded endpoint fails over to the next
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if *directCalls != 1 || *gatewayCalls != 1 {
+		t.Fatalf("calls: direct %d, gateway %d; want 1, 1", *directCalls, *gatewayCalls)
+	}
+
+	// Both are sidelined now: the gateway's requests are used up until
+	// the reset, the direct API cools down for less long
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if *directCalls != 2 || *gatewayCalls != 1 {
+		t.Errorf("calls: direct %d, gateway %d; want 2, 1", *directCalls, *gatewayCalls)
+	}
+
+	// Clients of the same URLs share their health
+	if eps := NewClaude("other-key", gateway.URL).endpoints; eps[0].requestsRemaining != 0 {
+		t.Errorf("gateway requests remaining = %d, want 0", eps[0].requestsRemaining)
+	}
+
+	// An invalid request doesn't fail over
+	bad, _ := server(http.StatusBadRequest)
+	good, goodCalls := server()
+	if _, err := NewClaude("test-key", bad.URL+","+good.URL).Generate(context.Background(), req); err == nil {
+		t.Error("invalid request: no error")
+	}
+	if *goodCalls != 0 {
+		t.Errorf("invalid request was sent to the next endpoint")
+	}
+}
+
+func TestClaudeGenerate_KeyRotation(t *testing.T) {
+	var used []string
+	limited := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-api-key")
+		used = append(used, key)
+		if limited[key] {
+			w.Header().Set("retry-after", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"type":"rate_limit_error","message":"Rate limited"}}`))
+			return
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`))
+	}))
+	defer srv.Close()
+	req := &Request{Model: "claude-sonnet-4-20250514", MaxTokens: 10}
+	client := NewClaude("sk-rotate-key-aaaa, sk-rotate-key-bbbb", srv.URL)
+
+	// Requests are spread over the keys
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Generate(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[resp.KeyID] = true
+	}
+	if !ids["...aaaa"] || !ids["...bbbb"] || used[0] == used[1] {
+		t.Fatalf("keys used %v, key IDs %v; want both", used, ids)
+	}
+
+	// A rate limited key is sidelined and the request sent with the next
+	limited["sk-rotate-key-aaaa"] = true
+	used = nil
+	for i := 0; i < 3; i++ {
+		resp, err := client.Generate(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.KeyID != "...bbbb" {
+			t.Errorf("request %d answered by %s", i, resp.KeyID)
+		}
+	}
+	if n := len(used); n > 4 || used[len(used)-1] != "sk-rotate-key-bbbb" {
+		t.Errorf("keys used %v; want the limited key tried at most once", used)
+	}
+
+	// Every key limited fails the request
+	limited["sk-rotate-key-bbbb"] = true
+	if _, err := client.Generate(context.Background(), req); !isRateLimited(err) {
+		t.Errorf("err = %v, want rate limited", err)
+	}
+}
+
+func TestClaudeGenerate_Cassette(t *testing.T) {
+	rec, err := recorder.New("testdata/claude_tool_use.json", recorder.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClaude("test-key", "https://api.anthropic.com/v1/messages")
+	client.client = rec.Client()
+
+	resp, err := client.Generate(context.Background(), &Request{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 1024,
+		Messages: []MessageContent{{
+			Role:    "user",
+			Content: []ContentBlock{{Type: "text", Text: "what is in main.go?"}},
+		}},
+		Tools: []Tool{{
+			Name:        "read_file",
+			Description: "Read the contents of a file",
+			InputSchema: map[string]interface{}{"type": "object"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != "tool_use" || len(resp.Content) != 2 ||
+		resp.Content[1].Input["path"] != "main.go" || resp.Usage.InputTokens != 412 {
+		t.Errorf("response = %+v", resp)
+	}
+	if n := rec.Unplayed(); n != 0 {
+		t.Errorf("%d interactions unplayed", n)
+	}
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// embeddings.go - Text embeddings for semantic search
+//
+// Embeddings are a separate interface from LLM: Claude has no embeddings
+// endpoint, so vectors come from Ollama or an OpenAI-compatible API such
+// as OpenAI or Voyage.
+
+// OpenAI-compatible embeddings endpoints
+const (
+	OpenAIBaseURL = "https://api.openai.com/v1"
+	VoyageBaseURL = "https://api.voyageai.com/v1"
+)
+
+// Embedder turns texts into vectors, one per text in the same order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embed implements Embedder with /api/embeddings, one call per text
+func (o *OllamaClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	endpoint := strings.TrimRight(o.baseURL, "/") + "/api/embeddings"
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		var apiResp struct {
+			Embedding []float32 `json:"embedding"`
+		}
+		err := postJSON(ctx, o.client, endpoint, nil, map[string]string{
+			"model":  o.model,
+			"prompt": text,
+		}, &apiResp)
+		if err != nil {
+			return nil, err
+		}
+		if len(apiResp.Embedding) == 0 {
+			return nil, fmt.Errorf("model %s returned no embedding", o.model)
+		}
+		vectors = append(vectors, apiResp.Embedding)
+	}
+	return vectors, nil
+}
+
+// OpenAIEmbeddings implements Embedder for OpenAI-compatible /embeddings
+// endpoints (OpenAI, Voyage, most self-hosted gateways)
+type OpenAIEmbeddings struct {
+	model   string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOpenAIEmbeddings creates an embeddings client for baseURL, e.g.
+// OpenAIBaseURL or VoyageBaseURL
+func NewOpenAIEmbeddings(model, baseURL, apiKey string) *OpenAIEmbeddings {
+	return &OpenAIEmbeddings{
+		model:   model,
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		client:  defaultClient,
+	}
+}
+
+// Embed implements Embedder with one batched call
+func (e *OpenAIEmbeddings) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	endpoint := strings.TrimRight(e.baseURL, "/") + "/embeddings"
+	headers := map[string]string{"Authorization": "Bearer " + e.apiKey}
+	var apiResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	err := postJSON(ctx, e.client, endpoint, headers, map[string]interface{}{
+		"model": e.model,
+		"input": texts,
+	}, &apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range apiResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// postJSON posts body to endpoint and decodes the JSON answer into out
+func postJSON(ctx context.Context, client *http.Client, endpoint string,
+	headers map[string]string, body, out interface{},
+) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint,
+		bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("making API call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaEmbed(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/embeddings" {
+				t.Errorf("wrong path: %s", r.URL.Path)
+			}
+			var req struct {
+				Model  string `json:"model"`
+				Prompt string `json:"prompt"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatalf("decoding request: %v", err)
+			}
+			if req.Model != "nomic-embed-text" {
+				t.Errorf("model = %q", req.Model)
+			}
+			prompts = append(prompts, req.Prompt)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"embedding": []float32{float32(len(prompts)), 0.5},
+			})
+		}))
+	defer server.Close()
+
+	client := NewOllama("nomic-embed-text", server.URL)
+	vectors, err := client.Embed(context.Background(), []string{"one", "two"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(prompts) != 2 || prompts[0] != "one" || prompts[1] != "two" {
+		t.Errorf("prompts = %q, want one call per text in order", prompts)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 2 {
+		t.Errorf("vectors = %v", vectors)
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/embeddings" {
+				t.Errorf("wrong path: %s", r.URL.Path)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+				t.Errorf("Authorization = %q", got)
+			}
+			var req struct {
+				Model string   `json:"model"`
+				Input []string `json:"input"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatalf("decoding request: %v", err)
+			}
+			if req.Model != "voyage-code-3" || len(req.Input) != 2 {
+				t.Errorf("request = %+v, want one batched call", req)
+			}
+			// Out of order on purpose: index decides the position
+			w.Write([]byte(`{"data": [
+				{"index": 1, "embedding": [0, 1]},
+				{"index": 0, "embedding": [1, 0]}
+			]}`))
+		}))
+	defer server.Close()
+
+	client := NewOpenAIEmbeddings("voyage-code-3", server.URL+"/v1", "test-key")
+	vectors, err := client.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("vectors = %v, want them ordered by index", vectors)
+	}
+}
+
+func TestOpenAIEmbed_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "bad key"}`))
+		}))
+	defer server.Close()
+
+	client := NewOpenAIEmbeddings("text-embedding-3-small", server.URL, "wrong")
+	if _, err := client.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatal("Embed succeeded with a 401")
+	}
+}
@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claude API endpoints
+//
+// NewClaude takes a comma-separated list of Messages API URLs, e.g. the
+// direct API and a gateway. Requests go to the first healthy endpoint;
+// connection failures, 5xx and 529 overloads put it in a cooldown that
+// doubles with every failure in a row, and a 429 or an exhausted
+// anthropic-ratelimit-* limit sidelines it until the limit resets. Health
+// is kept per URL for the whole process, so every client shares it.
+
+const (
+	// endpointCooldown is the first cooldown of a failing endpoint
+	endpointCooldown = 5 * time.Second
+	// endpointMaxCooldown caps the cooldown of an endpoint that keeps failing
+	endpointMaxCooldown = 5 * time.Minute
+)
+
+// endpoint is a Messages API URL and what its responses said about it
+type endpoint struct {
+	url       string
+	downUntil time.Time // skipped until then
+	failures  int       // in a row
+
+	// From the anthropic-ratelimit-* headers of the last response, -1
+	// when not sent
+	requestsRemaining int
+	tokensRemaining   int
+}
+
+var (
+	endpointsMu sync.Mutex
+	endpoints   = make(map[string]*endpoint) // by URL
+)
+
+// endpointsFor returns the shared endpoints of a comma-separated URL list
+func endpointsFor(urls string) []*endpoint {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	var eps []*endpoint
+	for _, url := range strings.Split(urls, ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		ep, ok := endpoints[url]
+		if !ok {
+			ep = &endpoint{url: url, requestsRemaining: -1, tokensRemaining: -1}
+			endpoints[url] = ep
+		}
+		eps = append(eps, ep)
+	}
+	return eps
+}
+
+// orderEndpoints returns eps in the order to try them: the available ones
+// as configured, then the sidelined ones by when they come back. A request
+// is never refused because every endpoint is down.
+func orderEndpoints(eps []*endpoint, now time.Time) []*endpoint {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	var up, down []*endpoint
+	for _, ep := range eps {
+		if ep.downUntil.After(now) {
+			down = append(down, ep)
+		} else {
+			up = append(up, ep)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].downUntil.Before(down[j].downUntil)
+	})
+	return append(up, down...)
+}
+
+// succeeded records a response of ep and the rate limits it reported
+func (ep *endpoint) succeeded(header http.Header, now time.Time) {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	ep.failures = 0
+	ep.downUntil = time.Time{}
+	ep.trackLimits(header, now)
+}
+
+// failed records a failure of ep worth trying another endpoint for and
+// reports whether it was one. header is nil for connection failures.
+func (ep *endpoint) failed(err error, header http.Header, now time.Time) bool {
+	var apiErr *APIError
+	isAPIErr := errors.As(err, &apiErr)
+	switch {
+	case !isAPIErr:
+		// Connection failure or timeout
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+	case apiErr.StatusCode >= 500:
+	default:
+		return false // the request itself is wrong
+	}
+
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	ep.failures++
+	cooldown := endpointCooldown << (ep.failures - 1)
+	if cooldown > endpointMaxCooldown || cooldown <= 0 {
+		cooldown = endpointMaxCooldown
+	}
+	ep.downUntil = now.Add(cooldown)
+	if isAPIErr && apiErr.StatusCode == http.StatusTooManyRequests {
+		if wait, err := strconv.Atoi(header.Get("retry-after")); err == nil {
+			ep.downUntil = now.Add(time.Duration(wait) * time.Second)
+		}
+	}
+	ep.trackLimits(header, now)
+	return true
+}
+
+// trackLimits keeps the remaining requests and tokens of ep and sidelines
+// it until an exhausted limit resets. Called with endpointsMu held.
+func (ep *endpoint) trackLimits(header http.Header, now time.Time) {
+	trackLimits(header, now, &ep.downUntil, &ep.requestsRemaining, &ep.tokensRemaining)
+}
+
+// trackLimits stores the remaining requests and tokens of the
+// anthropic-ratelimit-* headers and moves downUntil to when an exhausted
+// limit resets
+func trackLimits(header http.Header, now time.Time, downUntil *time.Time, requests, tokens *int) {
+	for _, limit := range []struct {
+		name      string
+		remaining *int
+	}{
+		{"requests", requests},
+		{"tokens", tokens},
+	} {
+		n, err := strconv.Atoi(header.Get("anthropic-ratelimit-" + limit.name + "-remaining"))
+		if err != nil {
+			continue
+		}
+		*limit.remaining = n
+		if n > 0 {
+			continue
+		}
+		reset, err := time.Parse(time.RFC3339, header.Get("anthropic-ratelimit-"+limit.name+"-reset"))
+		if err == nil && reset.After(now) && reset.After(*downUntil) {
+			*downUntil = reset
+		}
+	}
+}
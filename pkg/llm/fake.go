@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FakeClient implements the LLM interface with scripted responses, for
+// demos and tests of the tool pipeline without network or cost.
+//
+// A scenario is a JSON file of responses returned in order, one per
+// Generate call:
+//
+//	{"responses": [
+//	  {"content": [{"type": "tool_use", "name": "read_file", "input": {"path": "go.mod"}}]},
+//	  {"text": "It is a Go module."}
+//	]}
+//
+// text is shorthand for a single text block. A missing stop_reason is
+// tool_use when the response calls a tool and end_turn otherwise; tool
+// calls without an id get one.
+type FakeClient struct {
+	scenario string
+
+	mu        sync.Mutex
+	responses []fakeResponse
+	calls     int
+}
+
+// fakeScenario is the content of a scenario file
+type fakeScenario struct {
+	Description string         `json:"description,omitempty"`
+	Responses   []fakeResponse `json:"responses"`
+}
+
+// fakeResponse is a scripted response
+type fakeResponse struct {
+	Text       string         `json:"text,omitempty"`
+	Content    []ContentBlock `json:"content,omitempty"`
+	StopReason string         `json:"stop_reason,omitempty"`
+	Usage      Usage          `json:"usage"`
+}
+
+//go:embed fakes/*.json
+var builtinFakes embed.FS
+
+// FakeScenarios returns the names of the built-in scenarios
+func FakeScenarios() []string {
+	entries, _ := builtinFakes.ReadDir("fakes")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFake creates a client playing scenario: the name of a built-in
+// scenario (see FakeScenarios) or the path of a scenario file
+func NewFake(scenario string) (*FakeClient, error) {
+	data, err := builtinFakes.ReadFile(path.Join("fakes", scenario+".json"))
+	if err != nil {
+		data, err = os.ReadFile(scenario)
+		if err != nil {
+			return nil, fmt.Errorf("fake scenario %q is neither built in (%s) nor a readable file: %w",
+				scenario, strings.Join(FakeScenarios(), ", "), err)
+		}
+	}
+	var s fakeScenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing fake scenario %s: %w", scenario, err)
+	}
+	if len(s.Responses) == 0 {
+		return nil, fmt.Errorf("fake scenario %s has no responses", scenario)
+	}
+	return &FakeClient{scenario: scenario, responses: s.Responses}, nil
+}
+
+// Generate returns the next response of the scenario
+func (f *FakeClient) Generate(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls >= len(f.responses) {
+		return nil, fmt.Errorf("fake scenario %s: no response left for call %d",
+			f.scenario, f.calls+1)
+	}
+	r := f.responses[f.calls]
+	f.calls++
+
+	content := append([]ContentBlock(nil), r.Content...)
+	if r.Text != "" {
+		content = append(content, ContentBlock{Type: "text", Text: r.Text})
+	}
+	stop := r.StopReason
+	for i := range content {
+		if content[i].Type != "tool_use" {
+			continue
+		}
+		if content[i].ID == "" {
+			content[i].ID = fmt.Sprintf("toolu_fake_%d_%d", f.calls, i)
+		}
+		if stop == "" {
+			stop = "tool_use"
+		}
+	}
+	if stop == "" {
+		stop = "end_turn"
+	}
+	return req.prefilled(&Response{Content: content, StopReason: stop, Usage: r.Usage}), nil
+}
+
+// ListModels returns the built-in scenarios as fake: models
+func (f *FakeClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	for _, name := range FakeScenarios() {
+		models = append(models, ModelInfo{ID: "fake:" + name, Name: "fake:" + name, Provider: "fake"})
+	}
+	return models, nil
+}
+
+// GetCapabilities returns what fake models support: everything
+func (f *FakeClient) GetCapabilities() ModelCapabilities {
+	return ModelCapabilities{
+		SupportsTools:       true,
+		SupportsVision:      true,
+		MaxContextTokens:    200000,
+		Provider:            "fake",
+		RecommendedForTasks: []string{"demo", "test"},
+	}
+}
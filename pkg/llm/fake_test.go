@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFakeBuiltin(t *testing.T) {
+	f, err := NewFake("edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stops []string
+	for i := 0; i < 3; i++ {
+		resp, err := f.Generate(context.Background(), &Request{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stops = append(stops, resp.StopReason)
+		for _, block := range resp.Content {
+			if block.Type == "tool_use" && !strings.HasPrefix(block.ID, "toolu_fake_") {
+				t.Errorf("tool call without id: %+v", block)
+			}
+		}
+	}
+	if got := strings.Join(stops, ","); got != "tool_use,tool_use,end_turn" {
+		t.Errorf("stop reasons %s", got)
+	}
+	if _, err := f.Generate(context.Background(), &Request{}); err == nil ||
+		!strings.Contains(err.Error(), "no response left") {
+		t.Errorf("err = %v, want scenario exhausted", err)
+	}
+}
+
+func TestFakeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.json")
+	os.WriteFile(path, []byte(`{"responses": [{"text": "hi", "usage": {"input_tokens": 3}}]}`), 0o644)
+	f, err := NewFake(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := f.Generate(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content[0].Text != "hi" || resp.StopReason != "end_turn" || resp.Usage.InputTokens != 3 {
+		t.Errorf("response %+v", resp)
+	}
+
+	os.WriteFile(path, []byte(`{"responses": []}`), 0o644)
+	if _, err := NewFake(path); err == nil {
+		t.Error("empty scenario accepted")
+	}
+	if _, err := NewFake("nonexistent"); err == nil || !strings.Contains(err.Error(), "hello") {
+		t.Errorf("err = %v, want built-in scenarios listed", err)
+	}
+}
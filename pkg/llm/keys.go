@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claude API keys
+//
+// NewClaude takes a comma-separated list of API keys, e.g. the keys of
+// several workspaces. Requests are spread over them round-robin; a 429 or
+// an exhausted anthropic-ratelimit-* limit sidelines a key until the limit
+// resets and the request is sent again with the next key. Like endpoints,
+// keys are shared by every client of the process.
+
+// apiKey is an API key and what its responses said about it
+type apiKey struct {
+	key       string
+	downUntil time.Time // skipped until then
+
+	// From the anthropic-ratelimit-* headers of the last response, -1
+	// when not sent
+	requestsRemaining int
+	tokensRemaining   int
+}
+
+var (
+	keysMu sync.Mutex
+	keys   = make(map[string]*apiKey) // by key
+	// keyTurn is the number of requests sent, picking the key to start at
+	keyTurn int
+)
+
+// KeyID returns how key is named in logs and turn metadata: its last four
+// characters, as the Anthropic console shows them
+func KeyID(key string) string {
+	if len(key) < 8 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// keysFor returns the shared keys of a comma-separated key list
+func keysFor(list string) []*apiKey {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	var ks []*apiKey
+	for _, key := range strings.Split(list, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		k, ok := keys[key]
+		if !ok {
+			k = &apiKey{key: key, requestsRemaining: -1, tokensRemaining: -1}
+			keys[key] = k
+		}
+		ks = append(ks, k)
+	}
+	if len(ks) == 0 {
+		// Requests still go out and the API says what is wrong
+		ks = append(ks, &apiKey{requestsRemaining: -1, tokensRemaining: -1})
+	}
+	return ks
+}
+
+// orderKeys returns ks in the order to try them for the next request: the
+// available ones starting at the next in turn, then the sidelined ones by
+// when they come back
+func orderKeys(ks []*apiKey, now time.Time) []*apiKey {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	start := keyTurn % len(ks)
+	keyTurn++
+	var up, down []*apiKey
+	for i := range ks {
+		k := ks[(start+i)%len(ks)]
+		if k.downUntil.After(now) {
+			down = append(down, k)
+		} else {
+			up = append(up, k)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].downUntil.Before(down[j].downUntil)
+	})
+	return append(up, down...)
+}
+
+// succeeded records the rate limits a response to k reported
+func (k *apiKey) succeeded(header http.Header, now time.Time) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	k.downUntil = time.Time{}
+	k.trackLimits(header, now)
+}
+
+// limited records a 429 answer to k, sidelining it for the retry-after
+// seconds of header or until its limits reset
+func (k *apiKey) limited(header http.Header, now time.Time) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	k.downUntil = now.Add(endpointCooldown)
+	if wait, err := strconv.Atoi(header.Get("retry-after")); err == nil {
+		k.downUntil = now.Add(time.Duration(wait) * time.Second)
+	}
+	k.trackLimits(header, now)
+}
+
+// trackLimits keeps the remaining requests and tokens of k. Called with
+// keysMu held.
+func (k *apiKey) trackLimits(header http.Header, now time.Time) {
+	trackLimits(header, now, &k.downUntil, &k.requestsRemaining, &k.tokensRemaining)
+}
@@ -1,15 +1,25 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 )
 
+// ErrModelNotFound is returned by Ollama Generate when the model isn't
+// installed (see Pull)
+var ErrModelNotFound = errors.New("ollama model not found")
+
+// ErrToolsUnsupported is returned by Ollama Generate when the model can't
+// call tools
+var ErrToolsUnsupported = errors.New("ollama model does not support tools")
+
 // OllamaClient implements the LLM interface for Ollama.
 type OllamaClient struct {
 	model   string
@@ -22,7 +32,7 @@ func NewOllama(model, baseURL string) *OllamaClient {
 	return &OllamaClient{
 		model:   model,
 		baseURL: baseURL,
-		client:  &http.Client{},
+		client:  defaultClient,
 	}
 }
 
@@ -42,11 +52,24 @@ func (o *OllamaClient) GetCapabilities() ModelCapabilities {
 		}
 	}
 
+	// Multimodal models accept images
+	supportsVision := false
+	visionModels := []string{"llava", "bakllava", "moondream", "llama3.2-vision",
+		"minicpm-v", "qwen2.5vl", "gemma3", "granite3.2-vision"}
+	for _, vm := range visionModels {
+		if strings.Contains(modelLower, vm) {
+			supportsVision = true
+			break
+		}
+	}
+
 	// Detect specialized models
 	if strings.Contains(modelLower, "code") || strings.Contains(modelLower, "coder") {
 		recommendedTasks = []string{"code", "programming"}
 	} else if strings.Contains(modelLower, "embed") {
 		recommendedTasks = []string{"embeddings"}
+	} else if supportsVision {
+		recommendedTasks = []string{"chat", "vision"}
 	}
 
 	// Context size varies by model, use conservative default
@@ -59,7 +82,7 @@ func (o *OllamaClient) GetCapabilities() ModelCapabilities {
 
 	return ModelCapabilities{
 		SupportsTools:       supportsTools,
-		SupportsVision:      false, // Most Ollama models don't support vision yet
+		SupportsVision:      supportsVision,
 		SupportsStreaming:   true,
 		MaxContextTokens:    maxTokens,
 		Provider:            "ollama",
@@ -71,17 +94,26 @@ func (o *OllamaClient) GetCapabilities() ModelCapabilities {
 func (o *OllamaClient) Generate(ctx context.Context, req *Request) (*Response, error) {
 	// Convert messages to Ollama format
 	var messages []map[string]interface{}
-	for _, msg := range req.Messages {
+	for _, msg := range req.prefillMessages() {
 		content := ""
+		var images []string
 		for _, block := range msg.Content {
-			if block.Type == "text" {
+			switch {
+			case block.Type == "text":
 				content += block.Text
+			case block.Type == "image" && block.Source != nil:
+				images = append(images, block.Source.Data)
 			}
 		}
-		messages = append(messages, map[string]interface{}{
+		m := map[string]interface{}{
 			"role":    msg.Role,
 			"content": content,
-		})
+		}
+		// Multimodal models (llava, moondream) take base64 images
+		if len(images) > 0 {
+			m["images"] = images
+		}
+		messages = append(messages, m)
 	}
 
 	// Build Ollama request
@@ -90,12 +122,17 @@ func (o *OllamaClient) Generate(ctx context.Context, req *Request) (*Response, e
 		"messages": messages,
 		"stream":   false,
 	}
-	if req.System != "" {
-		apiReq["system"] = req.System
+	if system := req.SystemText(); system != "" {
+		apiReq["system"] = system
 	}
 	if len(req.Tools) > 0 {
 		apiReq["tools"] = convertToolsToOllama(req.Tools)
 	}
+	if req.Temperature != nil {
+		apiReq["options"] = map[string]interface{}{
+			"temperature": *req.Temperature,
+		}
+	}
 
 	reqBody, err := json.Marshal(apiReq)
 	if err != nil {
@@ -121,8 +158,14 @@ func (o *OllamaClient) Generate(ctx context.Context, req *Request) (*Response, e
 		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound && bytes.Contains(respBody, []byte("not found")) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, string(respBody))
+	}
+	if resp.StatusCode == http.StatusBadRequest && bytes.Contains(respBody, []byte("does not support tools")) {
+		return nil, fmt.Errorf("%w: %s", ErrToolsUnsupported, string(respBody))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	var apiResp struct {
@@ -164,14 +207,20 @@ func (o *OllamaClient) Generate(ctx context.Context, req *Request) (*Response, e
 		}}
 	}
 
-	return &Response{
+	return req.prefilled(&Response{
 		Content:    content,
 		StopReason: stopReason,
 		Usage: Usage{
 			InputTokens:  0, // Ollama doesn't provide token counts
 			OutputTokens: 0,
 		},
-	}, nil
+	}), nil
+}
+
+// Ping checks that Ollama answers at its base URL
+func (o *OllamaClient) Ping(ctx context.Context) error {
+	_, err := o.ListModels(ctx)
+	return err
 }
 
 // ListModels returns available Ollama models.
@@ -194,7 +243,7 @@ func (o *OllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	var apiResp struct {
@@ -203,6 +252,10 @@ func (o *OllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
 			Model      string `json:"model"`
 			ModifiedAt string `json:"modified_at"`
 			Size       int64  `json:"size"`
+			Details    struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
 		} `json:"models"`
 	}
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
@@ -212,9 +265,12 @@ func (o *OllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	var models []ModelInfo
 	for _, m := range apiResp.Models {
 		models = append(models, ModelInfo{
-			ID:       m.Name,
-			Name:     m.Name,
-			Provider: "ollama",
+			ID:            m.Name,
+			Name:          m.Name,
+			Provider:      "ollama",
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+			Size:          m.Size,
 		})
 	}
 
@@ -235,3 +291,68 @@ func convertToolsToOllama(tools []Tool) []map[string]interface{} {
 	}
 	return ollamaTools
 }
+
+// PullProgress is one status update streamed by Pull
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Pull downloads model through /api/pull, calling progress for every
+// update. Downloads take long, so the request timeout doesn't apply.
+func (o *OllamaClient) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":  model,
+		"stream": true,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+
+	endpoint := strings.TrimRight(o.baseURL, "/") + "/api/pull"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+
+	client := *o.client
+	client.Timeout = 0
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("making API call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+
+	// One JSON object per line, ending with status "success"
+	scanner := bufio.NewScanner(resp.Body)
+	last := ""
+	for scanner.Scan() {
+		var p PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return fmt.Errorf("parsing pull progress: %w", err)
+		}
+		if p.Error != "" {
+			return fmt.Errorf("pulling %s: %s", model, p.Error)
+		}
+		last = p.Status
+		if progress != nil {
+			progress(p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading pull progress: %w", err)
+	}
+	if last != "success" {
+		return fmt.Errorf("pulling %s: stream ended with status %q", model, last)
+	}
+	return nil
+}
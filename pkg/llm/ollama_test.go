@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -305,3 +306,167 @@ func TestOllamaGenerate_SystemMessage(t *testing.T) {
 		t.Fatal("expected response content")
 	}
 }
+
+func TestOllamaGenerate_Temperature(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(ollamaResponse{
+				Model:   "llama2",
+				Message: ollamaMessage{Role: "assistant", Content: "ok"},
+				Done:    true,
+			})
+		}))
+	defer server.Close()
+
+	zero := 0.0
+	client := NewOllama("llama2", server.URL)
+	_, err := client.Generate(context.Background(), &Request{
+		Model:       "llama2",
+		Temperature: &zero,
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	options, ok := body["options"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("options not sent: %v", body)
+	}
+	if options["temperature"] != 0.0 {
+		t.Errorf("temperature = %v, want 0", options["temperature"])
+	}
+}
+
+func TestOllamaGenerate_Images(t *testing.T) {
+	var body struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(ollamaResponse{
+				Model:   "llava",
+				Message: ollamaMessage{Role: "assistant", Content: "a cat"},
+				Done:    true,
+			})
+		}))
+	defer server.Close()
+
+	client := NewOllama("llava", server.URL)
+	_, err := client.Generate(context.Background(), &Request{
+		Model: "llava",
+		Messages: []MessageContent{{
+			Role: "user",
+			Content: []ContentBlock{
+				{Type: "image", Source: &ImageSource{Type: "base64", MediaType: "image/png", Data: "iVBORw0K"}},
+				{Type: "text", Text: "what is this?"},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	msg := body.Messages[0]
+	images, _ := msg["images"].([]interface{})
+	if msg["content"] != "what is this?" || len(images) != 1 || images[0] != "iVBORw0K" {
+		t.Errorf("unexpected message %v", msg)
+	}
+}
+
+func TestOllamaModelNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model \"llama9\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	client := NewOllama("llama9", server.URL)
+	_, err := client.Generate(context.Background(), &Request{Model: "llama9"})
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("expected ErrModelNotFound, got %v", err)
+	}
+}
+
+func TestOllamaPull(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			stream: `{"status":"pulling manifest"}
+{"status":"pulling abc","digest":"sha256:abc","total":100,"completed":50}
+{"status":"pulling abc","digest":"sha256:abc","total":100,"completed":100}
+{"status":"success"}
+`,
+		},
+		{
+			name:    "error in stream",
+			stream:  `{"status":"pulling manifest"}` + "\n" + `{"error":"pull model manifest: file does not exist"}` + "\n",
+			wantErr: true,
+		},
+		{
+			name:    "stream cut short",
+			stream:  `{"status":"pulling manifest"}` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/pull" {
+					t.Errorf("wrong path: %s", r.URL.Path)
+				}
+				var req map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&req)
+				if req["model"] != "llama9" {
+					t.Errorf("pulled %v, want llama9", req["model"])
+				}
+				w.Write([]byte(tt.stream))
+			}))
+			defer server.Close()
+
+			var updates []PullProgress
+			err := NewOllama("llama9", server.URL).Pull(context.Background(), "llama9",
+				func(p PullProgress) { updates = append(updates, p) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Pull error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(updates) != 4 || updates[1].Completed != 50) {
+				t.Errorf("unexpected progress updates: %+v", updates)
+			}
+		})
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("wrong path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3.1:8b","size":4920753328,
+			"details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"}}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewOllama("", server.URL).ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	want := ModelInfo{
+		ID:            "llama3.1:8b",
+		Name:          "llama3.1:8b",
+		Provider:      "ollama",
+		ParameterSize: "8.0B",
+		Quantization:  "Q4_K_M",
+		Size:          4920753328,
+	}
+	if len(models) != 1 || models[0] != want {
+		t.Errorf("ListModels = %+v, want %+v", models, want)
+	}
+}
@@ -0,0 +1,46 @@
+package llm
+
+import "strings"
+
+// Assistant prefill
+//
+// A Request with Prefill ends in an assistant message holding it, so the
+// model goes on from there instead of starting its answer afresh: a
+// prefill of "```json" gets JSON. Responses don't repeat the prefill; the
+// clients put it back in front so callers see, and save, the whole answer.
+
+// prefillText returns what is sent as the prefill of req: the API rejects
+// a final assistant message ending in whitespace
+func (req *Request) prefillText() string {
+	return strings.TrimRightFunc(req.Prefill, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// prefillMessages returns the messages of req followed by the prefill, if
+// any
+func (req *Request) prefillMessages() []MessageContent {
+	prefill := req.prefillText()
+	if prefill == "" {
+		return req.Messages
+	}
+	messages := append([]MessageContent(nil), req.Messages...)
+	return append(messages, MessageContent{
+		Role:    "assistant",
+		Content: []ContentBlock{{Type: "text", Text: prefill}},
+	})
+}
+
+// prefilled puts the prefill of req in front of the text of resp
+func (req *Request) prefilled(resp *Response) *Response {
+	prefill := req.prefillText()
+	if prefill == "" || resp == nil {
+		return resp
+	}
+	if len(resp.Content) > 0 && resp.Content[0].Type == "text" {
+		resp.Content[0].Text = prefill + resp.Content[0].Text
+		return resp
+	}
+	resp.Content = append([]ContentBlock{{Type: "text", Text: prefill}}, resp.Content...)
+	return resp
+}
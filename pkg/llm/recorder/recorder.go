@@ -0,0 +1,280 @@
+// Package recorder records the HTTP interactions of LLM clients to
+// cassette files and replays them offline.
+//
+// A cassette is a JSON file of request/response pairs. Recording keeps no
+// request headers, only the response headers clients look at, and scrubs
+// credentials from URLs and bodies, so cassettes can be committed as test
+// fixtures or shared as demos. Replaying answers each request with the
+// first unplayed interaction of the same method and URL, preferring one
+// whose body matches exactly: prompts that differ between runs (a temp
+// dir in the system prompt) still replay in order.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/marcopeereboom/go-claude/pkg/redact"
+)
+
+// Mode says whether a Recorder records or replays
+type Mode int
+
+const (
+	// ModeRecord sends requests and appends them to the cassette
+	ModeRecord Mode = iota
+	// ModeReplay answers requests from the cassette without a network
+	ModeReplay
+)
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a sanitized recorded request
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body"`
+}
+
+// keptHeaders are the response headers recorded besides the
+// anthropic-ratelimit-* ones
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+// secretParams are query parameters whose values aren't recorded
+var secretParams = []string{"key", "api_key", "access_token", "token"}
+
+// Recorder records or replays the interactions of one cassette file
+type Recorder struct {
+	path     string
+	mode     Mode
+	redactor *redact.Redactor
+
+	mu       sync.Mutex
+	cassette Cassette
+	played   []bool // ModeReplay: interactions answered
+}
+
+// New returns a Recorder of the cassette at path. Recording starts a new
+// cassette; replaying loads it.
+func New(path string, mode Mode) (*Recorder, error) {
+	redactor, err := redact.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{path: path, mode: mode, redactor: redactor}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	r.played = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Transport returns a RoundTripper recording the requests sent through
+// next, or replaying them without using next
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{r: r, next: next}
+}
+
+// Client returns an http.Client replaying or recording through r
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r.Transport(nil)}
+}
+
+// Unplayed returns the number of interactions not replayed yet
+func (r *Recorder) Unplayed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, played := range r.played {
+		if !played {
+			n++
+		}
+	}
+	return n
+}
+
+type roundTripper struct {
+	r    *Recorder
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("recorder: reading request: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := Request{
+		Method: req.Method,
+		URL:    rt.r.sanitizeURL(req.URL),
+		Body:   string(rt.r.redactor.Bytes(body)),
+	}
+
+	if rt.r.mode == ModeReplay {
+		resp, err := rt.r.replay(recorded)
+		if err != nil {
+			return nil, err
+		}
+		return resp.httpResponse(req), nil
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("recorder: reading response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	out := Response{Status: resp.StatusCode, Body: string(rt.r.redactor.Bytes(respBody))}
+	for _, name := range keptHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			setHeader(&out, name, v)
+		}
+	}
+	for name := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "anthropic-ratelimit-") {
+			setHeader(&out, name, resp.Header.Get(name))
+		}
+	}
+	if err := rt.r.record(Interaction{Request: recorded, Response: out}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func setHeader(resp *Response, name, value string) {
+	if resp.Header == nil {
+		resp.Header = make(map[string]string)
+	}
+	resp.Header[http.CanonicalHeaderKey(name)] = value
+}
+
+// sanitizeURL returns u without credentials
+func (r *Recorder) sanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	if clean.RawQuery != "" {
+		q := clean.Query()
+		for _, p := range secretParams {
+			if q.Has(p) {
+				q.Set(p, redact.Placeholder("query"))
+			}
+		}
+		clean.RawQuery = q.Encode()
+	}
+	return r.redactor.String(clean.String())
+}
+
+// record appends in to the cassette and saves it, so a run that dies
+// keeps what it recorded
+func (r *Recorder) record(in Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("recorder: %w", err)
+		}
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("recorder: saving cassette: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("recorder: saving cassette: %w", err)
+	}
+	return nil
+}
+
+// replay returns the response recorded for req and marks it played
+func (r *Recorder) replay(req Request) (*Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, in := range r.cassette.Interactions {
+		if r.played[i] || in.Request.Method != req.Method || in.Request.URL != req.URL {
+			continue
+		}
+		if in.Request.Body == req.Body {
+			match = i
+			break
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("recorder: no recorded interaction left for %s %s in %s",
+			req.Method, req.URL, r.path)
+	}
+	r.played[match] = true
+	return &r.cassette.Interactions[match].Response, nil
+}
+
+// httpResponse returns resp as the response to req
+func (resp *Response) httpResponse(req *http.Request) *http.Response {
+	header := make(http.Header)
+	for name, v := range resp.Header {
+		header.Set(name, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+		StatusCode:    resp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,77 @@
+package recorder
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	const secret = "sk-ant-REDACTED"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Anthropic-Ratelimit-Requests-Remaining", "41")
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	rec, err := New(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(client *http.Client, body string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/messages?key="+secret, strings.NewReader(body))
+		req.Header.Set("X-Api-Key", secret)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+	if _, body := post(rec.Client(), `{"n":1,"note":"`+secret+`"}`); !strings.Contains(body, secret) {
+		t.Errorf("recording changed the live response: %s", body)
+	}
+	post(rec.Client(), `{"n":2}`)
+
+	// Credentials and unneeded headers are never written
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, secret) || strings.Contains(s, "s3cr3t") ||
+		!strings.Contains(s, "[REDACTED:anthropic_api_key]") || !strings.Contains(s, "41") {
+		t.Errorf("cassette not sanitized:\n%s", s)
+	}
+
+	// Replaying needs no server; a request whose body differs takes the
+	// next interaction of its URL
+	srv.Close()
+	rec, err = New(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := post(rec.Client(), `{"n":2}`)
+	if resp.StatusCode != http.StatusOK || body != `{"echo":{"n":2}}` ||
+		resp.Header.Get("Anthropic-Ratelimit-Requests-Remaining") != "41" {
+		t.Errorf("replayed %d %s %v", resp.StatusCode, body, resp.Header)
+	}
+	if _, body := post(rec.Client(), `{"n":3}`); !strings.Contains(body, `"n":1`) {
+		t.Errorf("replayed %s, want the first interaction", body)
+	}
+	if n := rec.Unplayed(); n != 0 {
+		t.Errorf("%d interactions unplayed", n)
+	}
+	if _, err := rec.Client().Post(srv.URL+"/v1/messages", "application/json", nil); err == nil ||
+		!strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("err = %v, want cassette exhausted", err)
+	}
+}
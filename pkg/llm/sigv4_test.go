@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4Vanilla checks against the get-vanilla case from the AWS
+// SigV4 test suite
+func TestSignV4Vanilla(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, nil, creds, "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("authorization"); got != want {
+		t.Errorf("authorization =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalURIDoubleEncodes(t *testing.T) {
+	req, _ := http.NewRequest("POST",
+		"https://bedrock-runtime.us-east-1.amazonaws.com/model/"+
+			awsEscape("anthropic.claude-sonnet-4-20250514-v1:0")+"/invoke", nil)
+
+	if got := req.URL.EscapedPath(); !strings.Contains(got, "v1%3A0") {
+		t.Errorf("wire path should escape ':' once, got %s", got)
+	}
+	if got := canonicalURI(req.URL); got != "/model/anthropic.claude-sonnet-4-20250514-v1%253A0/invoke" {
+		t.Errorf("canonical URI = %s", got)
+	}
+}
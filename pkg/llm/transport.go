@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TransportConfig controls how provider clients reach the network.
+// The zero value behaves like http.DefaultTransport: HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY are honored and system roots are trusted.
+type TransportConfig struct {
+	// ProxyURL overrides HTTPS_PROXY/HTTP_PROXY; NO_PROXY still applies
+	ProxyURL string
+	// CACertFile is a PEM bundle trusted in addition to system roots
+	CACertFile string
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool
+	// Timeout bounds each request (0 = no timeout)
+	Timeout time.Duration
+	// Wrap, when set, wraps the transport, e.g. to record or replay
+	// requests (see package recorder)
+	Wrap func(http.RoundTripper) http.RoundTripper
+}
+
+// defaultClient is used by NewClaude and NewOllama
+var defaultClient = &http.Client{}
+
+// ConfigureTransport builds the HTTP client used by all clients created
+// after the call.
+func ConfigureTransport(cfg TransportConfig) error {
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	defaultClient = client
+	return nil
+}
+
+// HTTPClient returns the client of ConfigureTransport, for requests other
+// than provider calls that must take the same proxy and TLS settings
+func HTTPClient() *http.Client {
+	return defaultClient
+}
+
+// NewHTTPClient returns an http.Client configured per cfg
+func NewHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxyCfg := httpproxy.FromEnvironment()
+	if cfg.ProxyURL != "" {
+		if _, err := url.Parse(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", cfg.ProxyURL, err)
+		}
+		proxyCfg.HTTPProxy = cfg.ProxyURL
+		proxyCfg.HTTPSProxy = cfg.ProxyURL
+	}
+	proxyFunc := proxyCfg.ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	if cfg.CACertFile != "" || cfg.InsecureSkipVerify {
+		tlsCfg := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // explicit opt-in flag
+		}
+		if cfg.CACertFile != "" {
+			pem, err := os.ReadFile(cfg.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("read CA cert: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	var rt http.RoundTripper = transport
+	if cfg.Wrap != nil {
+		rt = cfg.Wrap(rt)
+	}
+	return &http.Client{Transport: rt, Timeout: cfg.Timeout}, nil
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHTTPClient_CACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     TransportConfig
+		wantErr bool
+	}{
+		{"system roots reject test cert", TransportConfig{}, true},
+		{"custom CA trusted", TransportConfig{CACertFile: caFile}, false},
+		{"skip verify", TransportConfig{InsecureSkipVerify: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewHTTPClient failed: %v", err)
+			}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClient_BadCACert(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	os.WriteFile(bad, []byte("not a cert"), 0o644)
+
+	if _, err := NewHTTPClient(TransportConfig{CACertFile: bad}); err == nil {
+		t.Error("expected error for file without certificates")
+	}
+	if _, err := NewHTTPClient(TransportConfig{CACertFile: bad + ".missing"}); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "internal.example.com")
+
+	client, err := NewHTTPClient(TransportConfig{ProxyURL: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := client.Transport.(*http.Transport).Proxy
+
+	req, _ := http.NewRequest("GET", "https://api.anthropic.com/v1/messages", nil)
+	u, err := proxy(req)
+	if err != nil || u == nil || u.Host != "proxy.example.com:3128" {
+		t.Errorf("expected request via proxy, got %v (err %v)", u, err)
+	}
+
+	req, _ = http.NewRequest("GET", "https://internal.example.com/v1/messages", nil)
+	if u, _ := proxy(req); u != nil {
+		t.Errorf("NO_PROXY host should bypass proxy, got %v", u)
+	}
+}
@@ -1,7 +1,11 @@
 // Package llm provides interfaces and types for interacting with different LLM backends.
 package llm
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 // ModelInfo contains metadata about an available model.
 type ModelInfo struct {
@@ -9,6 +13,18 @@ type ModelInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	Provider    string `json:"provider"` // "claude" or "ollama"
+	// MaxOutputTokens is the model's max_tokens ceiling (0 = unknown)
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+
+	// Local model details reported by Ollama
+	ParameterSize string `json:"parameter_size,omitempty"` // e.g. "8.0B"
+	Quantization  string `json:"quantization,omitempty"`   // e.g. "Q4_K_M"
+	Size          int64  `json:"size,omitempty"`           // bytes on disk
+
+	// Capabilities, filled in when listing
+	ContextWindow  int  `json:"context_window,omitempty"`
+	SupportsTools  bool `json:"supports_tools,omitempty"`
+	SupportsVision bool `json:"supports_vision,omitempty"`
 }
 
 // ModelCapabilities describes what features a model supports.
@@ -40,6 +56,75 @@ type Request struct {
 	Tools     []Tool           `json:"tools,omitempty"`
 	MaxTokens int              `json:"max_tokens"`
 	System    string           `json:"system,omitempty"`
+	// SystemBlocks is sent as the system array instead of System when set.
+	// Providers without one send the joined text (SystemText).
+	SystemBlocks []SystemBlock `json:"system_blocks,omitempty"`
+	// Prefill starts the assistant's answer, which then begins with it (see
+	// prefill.go)
+	Prefill string `json:"prefill,omitempty"`
+	// Temperature overrides the provider default when set (0 = deterministic)
+	Temperature *float64 `json:"temperature,omitempty"`
+	// ToolChoice constrains tool calls when set (Claude only; Ollama ignores it)
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// SystemBlock is one text block of a system prompt array
+type SystemBlock struct {
+	Type string `json:"type"` // always "text"
+	Text string `json:"text"`
+	// CacheControl marks the end of a prefix the API may cache
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl is the Messages API cache_control parameter
+type CacheControl struct {
+	Type string `json:"type"` // "ephemeral"
+}
+
+// TextBlocks returns a system block of every non-empty text
+func TextBlocks(texts ...string) []SystemBlock {
+	var blocks []SystemBlock
+	for _, text := range texts {
+		if text != "" {
+			blocks = append(blocks, SystemBlock{Type: "text", Text: text})
+		}
+	}
+	return blocks
+}
+
+// SystemText returns the system prompt of req as one text: System, or
+// the joined blocks
+func (req *Request) SystemText() string {
+	if len(req.SystemBlocks) == 0 {
+		return req.System
+	}
+	return JoinSystem(req.SystemBlocks)
+}
+
+// JoinSystem returns the texts of blocks separated by blank lines
+func JoinSystem(blocks []SystemBlock) string {
+	texts := make([]string, len(blocks))
+	for i, b := range blocks {
+		texts[i] = b.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Tool choice types
+const (
+	ToolChoiceAuto = "auto" // the model decides (default)
+	ToolChoiceAny  = "any"  // must call some tool
+	ToolChoiceTool = "tool" // must call the tool named by Name
+	ToolChoiceNone = "none" // must not call tools
+)
+
+// ToolChoice is the Messages API tool_choice parameter
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"` // with ToolChoiceTool
+	// DisableParallelToolUse limits responses to one tool call (not with
+	// ToolChoiceNone)
+	DisableParallelToolUse bool `json:"disable_parallel_tool_use,omitempty"`
 }
 
 // Response contains the LLM's response.
@@ -47,6 +132,7 @@ type Response struct {
 	Content    []ContentBlock `json:"content"`
 	StopReason string         `json:"stop_reason"`
 	Usage      Usage          `json:"usage"`
+	KeyID      string         `json:"-"` // the Claude API key that answered, see KeyID
 }
 
 // MessageContent represents a single message in the conversation.
@@ -64,6 +150,14 @@ type ContentBlock struct {
 	Input     map[string]interface{} `json:"input,omitempty"`
 	ToolUseID string                 `json:"tool_use_id,omitempty"`
 	Content   string                 `json:"content,omitempty"`
+	Source    *ImageSource           `json:"source,omitempty"` // image blocks
+}
+
+// ImageSource is the data of an image block
+type ImageSource struct {
+	Type      string `json:"type"`       // base64
+	MediaType string `json:"media_type"` // image/png, image/jpeg, image/gif or image/webp
+	Data      string `json:"data"`       // base64 encoded image
 }
 
 // Tool represents a tool that can be called by the LLM.
@@ -78,3 +172,17 @@ type Usage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
 }
+
+// APIError is a provider's error response
+type APIError struct {
+	StatusCode int
+	Type       string // provider error type, e.g. overloaded_error
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("API error [%s]: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
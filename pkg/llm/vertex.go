@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vertexAPIVersion    = "vertex-2023-10-16"
+	defaultVertexRegion = "us-east5"
+)
+
+// TokenSource returns an OAuth2 access token
+type TokenSource func(ctx context.Context) (string, error)
+
+// VertexClient implements the LLM interface for Claude on Google Vertex AI.
+type VertexClient struct {
+	projectID string
+	region    string
+	baseURL   string
+	token     TokenSource
+	client    *http.Client
+}
+
+// NewVertex creates a new Vertex AI client. An empty region defaults to
+// us-east5.
+func NewVertex(projectID, region string, token TokenSource) *VertexClient {
+	if region == "" {
+		region = defaultVertexRegion
+	}
+	host := region + "-aiplatform.googleapis.com"
+	if region == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	return &VertexClient{
+		projectID: projectID,
+		region:    region,
+		baseURL:   "https://" + host,
+		token:     token,
+		client:    defaultClient,
+	}
+}
+
+// NewVertexFromEnv creates a Vertex client from ANTHROPIC_VERTEX_PROJECT_ID
+// (or GOOGLE_CLOUD_PROJECT) and CLOUD_ML_REGION.
+func NewVertexFromEnv() (*VertexClient, error) {
+	project := os.Getenv("ANTHROPIC_VERTEX_PROJECT_ID")
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		return nil, fmt.Errorf("vertex: ANTHROPIC_VERTEX_PROJECT_ID not set")
+	}
+	return NewVertex(project, os.Getenv("CLOUD_ML_REGION"), GcloudTokenSource()), nil
+}
+
+// GcloudTokenSource returns GOOGLE_OAUTH_ACCESS_TOKEN if set, otherwise a
+// token from `gcloud auth print-access-token`, cached for 45 minutes
+// (gcloud tokens live for an hour).
+func GcloudTokenSource() TokenSource {
+	var (
+		mu      sync.Mutex
+		cached  string
+		expires time.Time
+	)
+	return func(ctx context.Context) (string, error) {
+		if tok := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); tok != "" {
+			return tok, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if cached != "" && time.Now().Before(expires) {
+			return cached, nil
+		}
+		out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			return "", fmt.Errorf("vertex: gcloud auth print-access-token: %w", err)
+		}
+		cached = strings.TrimSpace(string(out))
+		expires = time.Now().Add(45 * time.Minute)
+		return cached, nil
+	}
+}
+
+// GetCapabilities returns the capabilities of Claude models on Vertex.
+func (v *VertexClient) GetCapabilities() ModelCapabilities {
+	return ModelCapabilities{
+		SupportsTools:       true,
+		SupportsVision:      true,
+		SupportsStreaming:   true,
+		MaxContextTokens:    200000,
+		Provider:            "vertex",
+		RecommendedForTasks: []string{"code", "reasoning", "analysis", "writing"},
+	}
+}
+
+// Generate sends a request to Claude via Vertex AI rawPredict.
+func (v *VertexClient) Generate(ctx context.Context, req *Request) (*Response, error) {
+	reqBody, err := marshalMessagesRequest(req, map[string]interface{}{
+		"anthropic_version": vertexAPIVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:rawPredict",
+		v.baseURL, v.projectID, v.region, VertexModelID(req.Model))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	token, err := v.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("authorization", "Bearer "+token)
+	httpReq.Header.Set("content-type", "application/json")
+
+	resp, err := doMessagesRequest(v.client, httpReq)
+	return req.prefilled(resp), err
+}
+
+// ListModels returns the Claude models available on Vertex.
+func (v *VertexClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return claudeModels("vertex"), nil
+}
+
+// modelDateSuffix matches the -YYYYMMDD version suffix of Claude model IDs
+var modelDateSuffix = regexp.MustCompile(`-(\d{8})$`)
+
+// VertexModelID converts an Anthropic model ID to Vertex form
+// (claude-sonnet-4-20250514 -> claude-sonnet-4@20250514). IDs already
+// containing "@" are returned unchanged.
+func VertexModelID(model string) string {
+	if strings.Contains(model, "@") {
+		return model
+	}
+	return modelDateSuffix.ReplaceAllString(model, "@$1")
+}
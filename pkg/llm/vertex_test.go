@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVertexGenerate(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		json.NewEncoder(w).Encode(claudeResponse{
+			Content:    []ContentBlock{{Type: "text", Text: "from vertex"}},
+			StopReason: "end_turn",
+			Usage:      Usage{InputTokens: 3, OutputTokens: 2},
+		})
+	}))
+	defer server.Close()
+
+	client := NewVertex("my-proj", "europe-west1", func(ctx context.Context) (string, error) {
+		return "ya29.token", nil
+	})
+	client.baseURL = server.URL
+
+	resp, err := client.Generate(context.Background(), &Request{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 100,
+		Messages:  []MessageContent{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "hi"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if resp.Content[0].Text != "from vertex" || resp.Usage.InputTokens != 3 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	wantPath := "/v1/projects/my-proj/locations/europe-west1/publishers/anthropic/models/claude-sonnet-4@20250514:rawPredict"
+	if gotPath != wantPath {
+		t.Errorf("path = %s, want %s", gotPath, wantPath)
+	}
+	if gotAuth != "Bearer ya29.token" {
+		t.Errorf("authorization = %q", gotAuth)
+	}
+	if gotBody["anthropic_version"] != vertexAPIVersion {
+		t.Errorf("anthropic_version = %v", gotBody["anthropic_version"])
+	}
+	if _, ok := gotBody["model"]; ok {
+		t.Error("vertex body must not contain model")
+	}
+}
+
+func TestVertexModelID(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"claude-sonnet-4-20250514", "claude-sonnet-4@20250514"},
+		{"claude-3-5-sonnet-20241022", "claude-3-5-sonnet@20241022"},
+		{"claude-opus-4@20250514", "claude-opus-4@20250514"},
+	}
+	for _, tt := range tests {
+		if got := VertexModelID(tt.in); got != tt.want {
+			t.Errorf("VertexModelID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
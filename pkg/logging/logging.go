@@ -0,0 +1,150 @@
+// Package logging configures the process-wide slog logger.
+//
+// Diagnostics (verbose traces, warnings) go through log/slog so they can be
+// filtered by level, emitted as JSON and redirected to a file. User facing
+// output (answers, diffs, tables) does not belong here and keeps writing to
+// stdout/stderr directly.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Level maps a --verbosity value to a log level: silent shows errors only,
+// normal adds warnings, verbose adds info, debug shows everything.
+func Level(verbosity string) slog.Level {
+	switch verbosity {
+	case "silent":
+		return slog.LevelError
+	case "verbose":
+		return slog.LevelInfo
+	case "debug":
+		return slog.LevelDebug
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// Setup installs the default slog logger. With path set logs are appended
+// to that file instead of stderr, so verbose traces can be captured
+// without cluttering the terminal. The returned func closes the file.
+func Setup(verbosity, format, path string) (func() error, error) {
+	var w io.Writer = os.Stderr
+	closeFn := func() error { return nil }
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open log file: %w", err)
+		}
+		w = f
+		closeFn = f.Close
+	}
+
+	handler, err := NewHandler(w, format, Level(verbosity), path != "")
+	if err != nil {
+		closeFn()
+		return nil, err
+	}
+	slog.SetDefault(slog.New(handler))
+	return closeFn, nil
+}
+
+// NewHandler returns a handler for format. File output gets timestamps;
+// terminal text output is kept terse.
+func NewHandler(w io.Writer, format string, level slog.Level, timestamps bool) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	case "", FormatText:
+		if timestamps {
+			return slog.NewTextHandler(w, opts), nil
+		}
+		return &consoleHandler{w: w, level: level, mu: &sync.Mutex{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %s or %s)",
+			format, FormatText, FormatJSON)
+	}
+}
+
+// consoleHandler prints "msg key=value ..." lines, prefixing warnings and
+// errors the way the CLI always has ("Warning: ...")
+type consoleHandler struct {
+	w      io.Writer
+	level  slog.Level
+	attrs  string // preformatted WithAttrs
+	prefix string // WithGroup key prefix
+	mu     *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("Warning: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	v := a.Value.String()
+	if strings.ContainsAny(v, " \t\n\"=") || v == "" {
+		v = fmt.Sprintf("%q", v)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, v)
+}
@@ -0,0 +1,90 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcopeereboom/go-claude/pkg/logging"
+)
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		verbosity string
+		want      slog.Level
+	}{
+		{"silent", slog.LevelError},
+		{"normal", slog.LevelWarn},
+		{"", slog.LevelWarn},
+		{"verbose", slog.LevelInfo},
+		{"debug", slog.LevelDebug},
+	}
+	for _, tt := range tests {
+		if got := logging.Level(tt.verbosity); got != tt.want {
+			t.Errorf("Level(%q) = %v, want %v", tt.verbosity, got, tt.want)
+		}
+	}
+}
+
+func TestConsoleHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := logging.NewHandler(&buf, logging.FormatText, slog.LevelInfo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h)
+
+	logger.Debug("hidden")
+	logger.Info("loaded messages", "count", 3)
+	logger.With("tool", "read_file").Warn("audit log failed", "err", "disk full")
+
+	want := "loaded messages count=3\n" +
+		"Warning: audit log failed tool=read_file err=\"disk full\"\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := logging.NewHandler(&buf, logging.FormatJSON, slog.LevelDebug, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(h).Debug("iteration", "n", 2)
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid JSON log line: %v\n%s", err, buf.String())
+	}
+	if rec["msg"] != "iteration" || rec["n"] != float64(2) || rec["level"] != "DEBUG" {
+		t.Errorf("unexpected record: %v", rec)
+	}
+
+	if _, err := logging.NewHandler(&buf, "xml", slog.LevelInfo, false); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestSetupLogFile(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	path := filepath.Join(t.TempDir(), "run.log")
+	closeFn, err := logging.Setup("debug", logging.FormatText, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("trace line", "iteration", 1)
+	closeFn()
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "msg=\"trace line\" iteration=1") ||
+		!strings.Contains(string(data), "time=") {
+		t.Errorf("unexpected log file contents: %s", data)
+	}
+}
@@ -0,0 +1,97 @@
+// Package redact scrubs credentials from text before it leaves the machine
+// or lands on disk.
+//
+// A Redactor applies a fixed set of built-in rules (API keys, AWS
+// credentials, private keys, ...) plus any custom regexes from policy.
+// Every match is replaced with a placeholder naming the rule, e.g.
+// [REDACTED:aws_access_key], so the LLM and anyone reading the logs can
+// still tell that something was there.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// rule is one secret pattern. When the regex has a capture group, only
+// the text after group 1 is replaced so the surrounding key name stays
+// readable (aws_secret_access_key=[REDACTED:...]).
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinRules are always applied; order matters where patterns overlap
+var builtinRules = []rule{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"anthropic_api_key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{"openai_api_key", regexp.MustCompile(`sk-(?:proj-)?[A-Za-z0-9_\-]{32,}`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws_secret_key", regexp.MustCompile(`(?i)(aws_secret_access_key[\\"'\s]*[:=][\\"'\s]*)[A-Za-z0-9/+]{40}`)},
+	{"github_token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`)},
+}
+
+// Redactor replaces secrets with placeholders. A nil *Redactor is valid
+// and redacts nothing.
+type Redactor struct {
+	rules []rule
+}
+
+// New returns a Redactor using the built-in rules plus the given custom
+// regexes. Custom matches are replaced with [REDACTED:custom].
+func New(patterns []string) (*Redactor, error) {
+	rules := append([]rule(nil), builtinRules...)
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		rules = append(rules, rule{name: "custom", re: re})
+	}
+	return &Redactor{rules: rules}, nil
+}
+
+// Placeholder returns the replacement text used for rule name
+func Placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// placeholderPattern matches the placeholders of every rule
+var placeholderPattern = regexp.MustCompile(`\[REDACTED:[a-z_]+\]`)
+
+// Placeholders returns the placeholders in s, in order
+func Placeholders(s string) []string {
+	return placeholderPattern.FindAllString(s, -1)
+}
+
+// String returns s with all secrets replaced
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, rl := range r.rules {
+		repl := Placeholder(rl.name)
+		if rl.re.NumSubexp() > 0 {
+			repl = "${1}" + repl
+		}
+		s = rl.re.ReplaceAllString(s, repl)
+	}
+	return s
+}
+
+// Bytes returns b with all secrets replaced. The placeholders contain no
+// quotes or backslashes, so redacting serialized JSON keeps it valid.
+func (r *Redactor) Bytes(b []byte) []byte {
+	if r == nil || len(b) == 0 {
+		return b
+	}
+	for _, rl := range r.rules {
+		repl := []byte(Placeholder(rl.name))
+		if rl.re.NumSubexp() > 0 {
+			repl = append([]byte("${1}"), repl...)
+		}
+		b = rl.re.ReplaceAll(b, repl)
+	}
+	return b
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -18,16 +19,16 @@ const (
 
 // RequiredFeatures represents what features a task needs
 type RequiredFeatures struct {
-	NeedsTools        bool
-	NeedsVision       bool
-	NeedsLargeContext bool
+	NeedsTools        bool `json:"tools"`
+	NeedsVision       bool `json:"vision"`
+	NeedsLargeContext bool `json:"large_context"`
 }
 
 // TaskAnalysis contains the result of analyzing a user prompt
 type TaskAnalysis struct {
-	Complexity TaskComplexity
-	Features   RequiredFeatures
-	Reasoning  string
+	Complexity TaskComplexity   `json:"complexity"`
+	Features   RequiredFeatures `json:"features"`
+	Reasoning  string           `json:"reasoning"`
 }
 
 // AnalyzeTask examines a user prompt and determines its complexity and required features
@@ -129,3 +130,19 @@ func (c TaskComplexity) String() string {
 		return "unknown"
 	}
 }
+
+// MarshalText encodes the complexity by name
+func (c TaskComplexity) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a complexity encoded by MarshalText
+func (c *TaskComplexity) UnmarshalText(text []byte) error {
+	for _, v := range []TaskComplexity{ComplexitySimple, ComplexityModerate, ComplexityComplex} {
+		if v.String() == string(text) {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown task complexity %q", text)
+}
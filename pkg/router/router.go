@@ -7,12 +7,29 @@ import (
 	"github.com/marcopeereboom/go-claude/pkg/storage"
 )
 
+// Adaptive routing: once the local model ran MinOutcomes tasks of a
+// complexity, a fallback rate of at least StruggleRate sends that
+// complexity to Claude and one of at most TrustRate keeps it local where
+// the static rules wouldn't.
+const (
+	MinOutcomes  = 5
+	StruggleRate = 0.5
+	TrustRate    = 0.1
+)
+
 // Decision represents a routing decision for which LLM provider to use.
 type Decision struct {
-	Provider        string // "claude" or "ollama"
-	ModelName       string
-	Reason          string
-	FallbackAllowed bool
+	Provider        string `json:"provider"` // "claude" or "ollama"
+	ModelName       string `json:"model"`
+	Reason          string `json:"reason"`
+	FallbackAllowed bool   `json:"fallback_allowed"`
+
+	// What the decision was based on
+	Rule        string       `json:"rule"` // the rule that fired, e.g. "complex"
+	Analysis    TaskAnalysis `json:"analysis"`
+	ClaudeRatio float64      `json:"claude_ratio"` // share of requests that went to Claude
+	MaxRatio    float64      `json:"max_claude_ratio"`
+	OverQuota   bool         `json:"over_quota"`
 }
 
 // Options configures the routing behavior.
@@ -67,6 +84,10 @@ func (r *Router) Route(prompt string) (*Decision, error) {
 	// Decision logic
 	decision := &Decision{
 		FallbackAllowed: r.opts.AllowFallback,
+		Analysis:        analysis,
+		ClaudeRatio:     storage.GetClaudeUsageRatio(r.config),
+		MaxRatio:        r.opts.MaxClaudeRatio,
+		OverQuota:       overQuota,
 	}
 
 	// Rule 1: If over quota, must use Ollama (unless impossible)
@@ -74,18 +95,30 @@ func (r *Router) Route(prompt string) (*Decision, error) {
 		if r.canUseOllama(&analysis, ollamaCaps, needsTools, needsVision) {
 			decision.Provider = "ollama"
 			decision.ModelName = r.opts.OllamaModel
+			decision.Rule = "quota"
 			decision.Reason = fmt.Sprintf("over Claude quota (%.1f%%), using Ollama", storage.GetClaudeUsageRatio(r.config)*100)
 			return decision, nil
 		}
 		// Can't use Ollama but over quota - must use Claude anyway
 		decision.Provider = "claude"
 		decision.ModelName = r.opts.ClaudeModel
+		decision.Rule = "quota-needs-claude"
 		decision.Reason = "over quota but task requires Claude capabilities"
 		decision.FallbackAllowed = false // No fallback makes sense here
 		return decision, nil
 	}
 
-	// Rule 2: Vision or large context always needs Claude
+	// Rule 2: Vision stays local with a multimodal Ollama model
+	if needsVision && !needsLargeContext && r.opts.PreferLocal &&
+		r.canUseOllama(&analysis, ollamaCaps, needsTools, needsVision) {
+		decision.Provider = "ollama"
+		decision.ModelName = r.opts.OllamaModel
+		decision.Rule = "local-vision"
+		decision.Reason = "local model supports vision"
+		return decision, nil
+	}
+
+	// Rule 3: Otherwise vision or large context needs Claude
 	if needsVision || needsLargeContext {
 		decision.Provider = "claude"
 		decision.ModelName = r.opts.ClaudeModel
@@ -97,35 +130,60 @@ func (r *Router) Route(prompt string) (*Decision, error) {
 		if needsLargeContext {
 			reasons = append(reasons, "large context")
 		}
+		decision.Rule = "needs-claude"
 		decision.Reason = fmt.Sprintf("requires Claude: %v", reasons)
 		decision.FallbackAllowed = false
 		return decision, nil
 	}
 
-	// Rule 3: Complex tasks go to Claude
+	// Rule 4: Learn from how the local model did on this complexity
+	if outcome, ok := r.learnedOutcome(analysis.Complexity); ok && r.opts.PreferLocal {
+		switch rate := outcome.FallbackRate(); {
+		case rate >= StruggleRate:
+			decision.Provider = "claude"
+			decision.ModelName = r.opts.ClaudeModel
+			decision.Rule = "learned-struggle"
+			decision.Reason = fmt.Sprintf("learned: local model fell back on %d/%d %s tasks",
+				outcome.Fallbacks, outcome.Runs, analysis.Complexity)
+			decision.FallbackAllowed = false
+			return decision, nil
+		case rate <= TrustRate && r.hasCapabilities(ollamaCaps, needsTools, needsVision):
+			decision.Provider = "ollama"
+			decision.ModelName = r.opts.OllamaModel
+			decision.Rule = "learned-trust"
+			decision.Reason = fmt.Sprintf("learned: local model handled %d/%d %s tasks (%.1f iterations on average)",
+				outcome.Runs-outcome.Fallbacks, outcome.Runs, analysis.Complexity, outcome.AvgIterations())
+			return decision, nil
+		}
+	}
+
+	// Rule 5: Complex tasks go to Claude
 	if analysis.Complexity == ComplexityComplex {
 		decision.Provider = "claude"
 		decision.ModelName = r.opts.ClaudeModel
+		decision.Rule = "complex"
 		decision.Reason = "complex task requires Claude"
 		decision.FallbackAllowed = false
 		return decision, nil
 	}
 
-	// Rule 4: Check if Ollama can handle this task
+	// Rule 6: Check if Ollama can handle this task
 	if r.opts.PreferLocal && r.canUseOllama(&analysis, ollamaCaps, needsTools, needsVision) {
 		// Prefer Ollama for simple and moderate tasks
 		if analysis.Complexity == ComplexitySimple || (analysis.Complexity == ComplexityModerate && ollamaCaps.SupportsTools) {
 			decision.Provider = "ollama"
 			decision.ModelName = r.opts.OllamaModel
+			decision.Rule = "local-capable"
 			decision.Reason = fmt.Sprintf("local model capable (%s task)", analysis.Complexity)
 			return decision, nil
 		}
 	}
 
-	// Rule 5: Tools required but Ollama doesn't support them
+	// Rule 7: Tools required but Ollama doesn't support them
 	if needsTools && !ollamaCaps.SupportsTools {
 		decision.Provider = "claude"
 		decision.ModelName = r.opts.ClaudeModel
+		decision.Rule = "tools-unsupported"
 		decision.Reason = "requires tools, Ollama model doesn't support them"
 		decision.FallbackAllowed = false
 		return decision, nil
@@ -135,10 +193,12 @@ func (r *Router) Route(prompt string) (*Decision, error) {
 	if r.opts.PreferLocal && r.ollamaClient != nil {
 		decision.Provider = "ollama"
 		decision.ModelName = r.opts.OllamaModel
+		decision.Rule = "default-local"
 		decision.Reason = "default to local"
 	} else {
 		decision.Provider = "claude"
 		decision.ModelName = r.opts.ClaudeModel
+		decision.Rule = "default-claude"
 		decision.Reason = "default to Claude"
 		decision.FallbackAllowed = false
 	}
@@ -146,18 +206,28 @@ func (r *Router) Route(prompt string) (*Decision, error) {
 	return decision, nil
 }
 
-// canUseOllama checks if Ollama can handle the task given complexity and capabilities.
-func (r *Router) canUseOllama(analysis *TaskAnalysis, caps llm.ModelCapabilities, needsTools, needsVision bool) bool {
+// learnedOutcome returns the local model outcomes of complexity once
+// there are enough of them to go by.
+func (r *Router) learnedOutcome(c TaskComplexity) (storage.RoutingOutcome, bool) {
+	outcome, ok := r.config.RoutingOutcomes[c.String()]
+	return outcome, ok && outcome.Runs >= MinOutcomes
+}
+
+// hasCapabilities checks if Ollama is available with the required capabilities.
+func (r *Router) hasCapabilities(caps llm.ModelCapabilities, needsTools, needsVision bool) bool {
 	if r.ollamaClient == nil {
 		return false
 	}
-
-	// Can't use Ollama if it lacks required capabilities
 	if needsVision && !caps.SupportsVision {
 		return false
 	}
+	return !needsTools || caps.SupportsTools
+}
 
-	if needsTools && !caps.SupportsTools {
+// canUseOllama checks if Ollama can handle the task given complexity and capabilities.
+func (r *Router) canUseOllama(analysis *TaskAnalysis, caps llm.ModelCapabilities, needsTools, needsVision bool) bool {
+	// Can't use Ollama if it lacks required capabilities
+	if !r.hasCapabilities(caps, needsTools, needsVision) {
 		return false
 	}
 
@@ -183,3 +253,20 @@ func (r *Router) canUseOllama(analysis *TaskAnalysis, caps llm.ModelCapabilities
 func (d *Decision) String() string {
 	return fmt.Sprintf("%s (%s): %s", d.Provider, d.ModelName, d.Reason)
 }
+
+// Explain returns what the decision was based on, one fact per line.
+func (d *Decision) Explain() string {
+	quota := "under"
+	if d.OverQuota {
+		quota = "over"
+	}
+	f := d.Analysis.Features
+	return fmt.Sprintf("routing:    %s\n"+
+		"complexity: %s (%s)\n"+
+		"features:   tools=%t vision=%t large-context=%t\n"+
+		"quota:      Claude %.1f%% of requests, max %.1f%% (%s)\n"+
+		"rule:       %s",
+		d, d.Analysis.Complexity, d.Analysis.Reasoning,
+		f.NeedsTools, f.NeedsVision, f.NeedsLargeContext,
+		d.ClaudeRatio*100, d.MaxRatio*100, quota, d.Rule)
+}
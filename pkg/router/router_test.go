@@ -2,6 +2,7 @@ package router_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/marcopeereboom/go-claude/pkg/llm"
@@ -298,6 +299,40 @@ func TestRouter_VisionRequired_UseClaude(t *testing.T) {
 	}
 }
 
+func TestRouter_VisionRequired_LocalVisionModel(t *testing.T) {
+	ollama := &mockLLM{
+		caps: llm.ModelCapabilities{
+			SupportsVision: true,
+			Provider:       "ollama",
+		},
+	}
+	claude := &mockLLM{
+		caps: llm.ModelCapabilities{
+			SupportsTools:  true,
+			SupportsVision: true,
+			Provider:       "claude",
+		},
+	}
+
+	opts := router.Options{
+		PreferLocal:    true,
+		AllowFallback:  true,
+		MaxClaudeRatio: 0.1,
+		OllamaModel:    "llava:7b",
+		ClaudeModel:    "claude-sonnet-4",
+		RequireVision:  true,
+	}
+
+	r := router.NewRouter(ollama, claude, &storage.Config{}, opts)
+	decision, err := r.Route("Describe this image")
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if decision.Provider != "ollama" || decision.ModelName != "llava:7b" {
+		t.Errorf("Expected the local vision model, got %s", decision)
+	}
+}
+
 func TestRouter_LargeContext_UseClaude(t *testing.T) {
 	ollama := &mockLLM{
 		caps: llm.ModelCapabilities{
@@ -415,3 +450,109 @@ func TestRouter_NilOllamaClient(t *testing.T) {
 		t.Errorf("Expected claude when Ollama not available, got %s", decision.Provider)
 	}
 }
+
+func TestRouter_LearnedOutcomes(t *testing.T) {
+	ollama := &mockLLM{
+		caps: llm.ModelCapabilities{
+			SupportsTools: true,
+			Provider:      "ollama",
+		},
+	}
+	opts := router.Options{
+		PreferLocal:    true,
+		AllowFallback:  true,
+		MaxClaudeRatio: 1.0,
+		OllamaModel:    "llama3.1:8b",
+		ClaudeModel:    "claude-sonnet-4",
+	}
+
+	tests := []struct {
+		name     string
+		prompt   string
+		outcomes map[string]storage.RoutingOutcome
+		want     string
+	}{
+		{
+			name:   "moderate tasks keep falling back",
+			prompt: "Write a function to calculate fibonacci",
+			outcomes: map[string]storage.RoutingOutcome{
+				"moderate": {Runs: 6, Fallbacks: 4, Iterations: 20},
+			},
+			want: "claude",
+		},
+		{
+			name:   "complex tasks handled locally",
+			prompt: "Refactor the parser",
+			outcomes: map[string]storage.RoutingOutcome{
+				"complex": {Runs: 10, Fallbacks: 1, Iterations: 30},
+			},
+			want: "ollama",
+		},
+		{
+			name:   "too few runs to learn from",
+			prompt: "Refactor the parser",
+			outcomes: map[string]storage.RoutingOutcome{
+				"complex": {Runs: router.MinOutcomes - 1, Iterations: 4},
+			},
+			want: "claude",
+		},
+		{
+			name:   "mixed results keep the static rules",
+			prompt: "Write a function to calculate fibonacci",
+			outcomes: map[string]storage.RoutingOutcome{
+				"moderate": {Runs: 10, Fallbacks: 3, Iterations: 30},
+			},
+			want: "ollama",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &storage.Config{RoutingOutcomes: tt.outcomes}
+			decision, err := router.NewRouter(ollama, nil, config, opts).Route(tt.prompt)
+			if err != nil {
+				t.Fatalf("Route failed: %v", err)
+			}
+			if decision.Provider != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, decision)
+			}
+		})
+	}
+}
+
+func TestDecision_Explain(t *testing.T) {
+	ollama := &mockLLM{
+		caps: llm.ModelCapabilities{
+			SupportsTools: true,
+			Provider:      "ollama",
+		},
+	}
+	config := &storage.Config{
+		ClaudeStats: storage.ProviderStats{RequestCount: 1},
+		OllamaStats: storage.ProviderStats{RequestCount: 3},
+	}
+	opts := router.Options{
+		PreferLocal:    true,
+		MaxClaudeRatio: 0.1,
+		OllamaModel:    "llama3.1:8b",
+		ClaudeModel:    "claude-sonnet-4",
+	}
+
+	decision, err := router.NewRouter(ollama, nil, config, opts).Route("Analyze this code")
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if decision.Rule != "quota" {
+		t.Errorf("Expected the quota rule, got %s", decision.Rule)
+	}
+	if !decision.OverQuota || decision.ClaudeRatio != 0.25 || decision.MaxRatio != 0.1 {
+		t.Errorf("Unexpected quota state %+v", decision)
+	}
+	explanation := decision.Explain()
+	for _, want := range []string{
+		"complexity: moderate", "tools=false", "Claude 25.0% of requests, max 10.0% (over)", "rule:       quota",
+	} {
+		if !strings.Contains(explanation, want) {
+			t.Errorf("Explanation lacks %q:\n%s", want, explanation)
+		}
+	}
+}
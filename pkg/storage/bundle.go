@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bundle.go - Session export/import (--export-session, --import-session)
+//
+// A bundle is a .tar.gz holding manifest.json followed by the files of the
+// .claude directory under .claude/. The manifest lists every file with its
+// size and SHA-256; import verifies all of them before the directory is put
+// in place. Files are copied as stored, so encrypted sessions need the same
+// passphrase on the other machine.
+//
+// Bundles come from other people, e.g. attached to a bug report, so import
+// drops the settings that would run their commands on the next invocation
+// or let the tools write elsewhere, unless the bundle is trusted.
+
+// BundleVersion is the manifest format written by ExportSession
+const BundleVersion = 1
+
+const (
+	bundleManifest = "manifest.json"
+	bundlePrefix   = ".claude/"
+)
+
+// BundleManifest describes the contents of a session bundle
+type BundleManifest struct {
+	Version  int          `json:"version"`
+	Created  time.Time    `json:"created"`
+	Hostname string       `json:"hostname,omitempty"`
+	Files    []BundleFile `json:"files"`
+}
+
+// BundleFile is one file of a bundle, relative to the .claude directory
+type BundleFile struct {
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// bundleCommands are the settings import drops from untrusted bundles: a
+// file is dropped whole when it lists no keys, else only the keys
+var bundleCommands = []struct {
+	file string
+	keys []string
+}{
+	{"hooks.json", nil},
+	{"policy.json", []string{"format", "workspace"}},
+	{"config.json", []string{"tool_plugins", "validators"}},
+}
+
+// bundleSkip reports whether a .claude file stays out of bundles: the
+// session lock and half written temp files
+func bundleSkip(rel string) bool {
+	return rel == "lock" || strings.HasSuffix(rel, ".tmp")
+}
+
+// ExportSession writes the files of claudeDir to the bundle at dest
+func ExportSession(claudeDir, dest string) (*BundleManifest, error) {
+	hostname, _ := os.Hostname()
+	manifest := &BundleManifest{
+		Version:  BundleVersion,
+		Created:  time.Now().UTC(),
+		Hostname: hostname,
+	}
+	err := filepath.WalkDir(claudeDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(claudeDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if bundleSkip(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, BundleFile{
+			Path:   rel,
+			Size:   info.Size(),
+			Mode:   info.Mode().Perm(),
+			SHA256: sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", claudeDir, err)
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("no session to export in %s", claudeDir)
+	}
+
+	tmpPath := dest + ".tmp"
+	if err := writeBundle(tmpPath, claudeDir, manifest); err != nil {
+		remove(tmpPath)
+		return nil, err
+	}
+	if err := rename(tmpPath, dest); err != nil {
+		remove(tmpPath)
+		return nil, fmt.Errorf("atomic rename: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeBundle(dest, claudeDir string, manifest *BundleManifest) error {
+	f, err := createFile(dest)
+	if err != nil {
+		return fmt.Errorf("create bundle: %w", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    bundleManifest,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: manifest.Created,
+	}); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	for _, file := range manifest.Files {
+		if err := addBundleFile(tw, claudeDir, file, manifest.Created); err != nil {
+			return fmt.Errorf("add %s: %w", file.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return f.Close()
+}
+
+func addBundleFile(tw *tar.Writer, claudeDir string, file BundleFile, modTime time.Time) error {
+	src, err := os.Open(filepath.Join(claudeDir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    bundlePrefix + file.Path,
+		Mode:    int64(file.Mode),
+		Size:    file.Size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	// A file that changed since it was hashed fails here or at import
+	_, err = io.CopyN(tw, src, file.Size)
+	return err
+}
+
+func readManifest(tr *tar.Reader) (*BundleManifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	if hdr.Name != bundleManifest {
+		return nil, fmt.Errorf("not a session bundle: starts with %q", hdr.Name)
+	}
+	var manifest BundleManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// ImportSession verifies the bundle at src and extracts it as claudeDir,
+// which must not exist yet. Nothing is left behind when verification fails.
+// Unless trusted, the settings of bundleCommands are dropped; they are
+// returned as "hooks.json" or "config.json tool_plugins".
+func ImportSession(claudeDir, src string, trusted bool) (*BundleManifest, []string, error) {
+	if _, err := os.Stat(claudeDir); err == nil {
+		return nil, nil, fmt.Errorf("%s already exists; move it away before importing", claudeDir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Extract next to claudeDir and rename it into place once verified
+	tmpDir, err := mkdirTemp(filepath.Dir(claudeDir), ".claude-import-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create import dir: %w", err)
+	}
+	if err := extractBundle(tr, tmpDir, manifest); err != nil {
+		removeAll(tmpDir)
+		return nil, nil, err
+	}
+	var dropped []string
+	if !trusted {
+		if dropped, err = dropBundleCommands(tmpDir); err != nil {
+			removeAll(tmpDir)
+			return nil, nil, err
+		}
+	}
+	if err := chmod(tmpDir, 0o755); err != nil {
+		removeAll(tmpDir)
+		return nil, nil, err
+	}
+	if err := rename(tmpDir, claudeDir); err != nil {
+		removeAll(tmpDir)
+		return nil, nil, fmt.Errorf("move import into place: %w", err)
+	}
+	return manifest, dropped, nil
+}
+
+// dropBundleCommands removes the settings of bundleCommands from the
+// extracted bundle in dir and returns what it removed
+func dropBundleCommands(dir string) ([]string, error) {
+	var dropped []string
+	for _, bc := range bundleCommands {
+		p := filepath.Join(dir, bc.file)
+		data, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bc.keys == nil {
+			if err := remove(p); err != nil {
+				return nil, err
+			}
+			dropped = append(dropped, bc.file)
+			continue
+		}
+
+		// Other keys, unknown ones included, are kept as they are
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", bc.file, err)
+		}
+		n := len(dropped)
+		for _, key := range bc.keys {
+			if _, ok := fields[key]; ok {
+				delete(fields, key)
+				dropped = append(dropped, bc.file+" "+key)
+			}
+		}
+		if len(dropped) == n {
+			continue
+		}
+		data, err = json.MarshalIndent(fields, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(p, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return dropped, nil
+}
+
+func extractBundle(tr *tar.Reader, dir string, manifest *BundleManifest) error {
+	want := make(map[string]BundleFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		want[file.Path] = file
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("bundle entry %s: not a regular file", hdr.Name)
+		}
+		rel, ok := strings.CutPrefix(hdr.Name, bundlePrefix)
+		if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) || path.Clean(rel) != rel {
+			return fmt.Errorf("bundle entry %s: invalid path", hdr.Name)
+		}
+		file, ok := want[rel]
+		if !ok {
+			return fmt.Errorf("bundle entry %s: not in manifest", hdr.Name)
+		}
+		delete(want, rel)
+		if err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(rel)), file); err != nil {
+			return fmt.Errorf("bundle entry %s: %w", hdr.Name, err)
+		}
+	}
+
+	for rel := range want {
+		return fmt.Errorf("bundle is missing %s", rel)
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, dest string, file BundleFile) error {
+	if err := mkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	out, err := openFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, file.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if err != nil {
+		return err
+	}
+	if n != file.Size || hex.EncodeToString(h.Sum(nil)) != file.SHA256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return out.Close()
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
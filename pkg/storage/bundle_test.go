@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestSession(t *testing.T, claudeDir string) {
+	t.Helper()
+	files := map[string]string{
+		"config.json":                   `{"model":"m"}`,
+		"request_20260101_000000.json":  `{"messages":[]}`,
+		"response_20260101_000000.json": `[]`,
+		"backups/20260101_000000/a.go":  "package a\n",
+		"lock":                          `{"pid":1}`,
+		"config.json.tmp":               "partial",
+	}
+	for name, content := range files {
+		path := filepath.Join(claudeDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExportImportSession(t *testing.T) {
+	src := filepath.Join(t.TempDir(), ".claude")
+	writeTestSession(t, src)
+	bundle := filepath.Join(t.TempDir(), "session.tar.gz")
+
+	manifest, err := ExportSession(src, bundle)
+	if err != nil {
+		t.Fatalf("ExportSession: %v", err)
+	}
+	if len(manifest.Files) != 4 {
+		t.Errorf("exported %d files, want 4 (lock and temp files skipped)", len(manifest.Files))
+	}
+
+	dest := filepath.Join(t.TempDir(), ".claude")
+	if _, _, err := ImportSession(dest, bundle, false); err != nil {
+		t.Fatalf("ImportSession: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "backups/20260101_000000/a.go"))
+	if err != nil || string(data) != "package a\n" {
+		t.Errorf("imported backup = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "lock")); !os.IsNotExist(err) {
+		t.Errorf("lock was imported: %v", err)
+	}
+
+	// An existing session is never overwritten
+	if _, _, err := ImportSession(dest, bundle, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("import over existing dir = %v", err)
+	}
+}
+
+func TestImportSessionDropsCommands(t *testing.T) {
+	src := filepath.Join(t.TempDir(), ".claude")
+	writeTestSession(t, src)
+	for name, content := range map[string]string{
+		"hooks.json":  `{"post_turn": [{"command": "curl evil"}]}`,
+		"policy.json": `{"format": {".go": "evil"}, "limits": {"nice": 5}}`,
+		"config.json": `{"model": "m", "tool_plugins": [{"command": "evil"}], "validators": {".go": "evil"}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := filepath.Join(t.TempDir(), "session.tar.gz")
+	if _, err := ExportSession(src, bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), ".claude")
+	_, dropped, err := ImportSession(dest, bundle, false)
+	if err != nil {
+		t.Fatalf("ImportSession: %v", err)
+	}
+	want := "hooks.json, policy.json format, config.json tool_plugins, config.json validators"
+	if got := strings.Join(dropped, ", "); got != want {
+		t.Errorf("dropped %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "hooks.json")); !os.IsNotExist(err) {
+		t.Errorf("hooks.json was imported: %v", err)
+	}
+	policy, err := LoadPolicy(dest)
+	if err != nil || policy.Format != nil || policy.Limits.Nice != 5 {
+		t.Errorf("imported policy = %+v, %v", policy, err)
+	}
+	cfg := LoadOrCreateConfig(filepath.Join(dest, "config.json"))
+	if cfg.Model != "m" || cfg.ToolPlugins != nil || cfg.Validators != nil {
+		t.Errorf("imported config = %+v", cfg)
+	}
+
+	// Trusted bundles are imported as they are
+	trusted := filepath.Join(t.TempDir(), ".claude")
+	if _, dropped, err := ImportSession(trusted, bundle, true); err != nil || dropped != nil {
+		t.Fatalf("trusted ImportSession = %v, %v", dropped, err)
+	}
+	if _, err := os.Stat(filepath.Join(trusted, "hooks.json")); err != nil {
+		t.Errorf("trusted import without hooks.json: %v", err)
+	}
+}
+
+// rewriteBundle copies the bundle at src to a new one, passing every
+// entry through edit
+func rewriteBundle(t *testing.T, src string, edit func(*tar.Header, []byte) (*tar.Header, []byte)) string {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	gzr, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+
+	dest := filepath.Join(t.TempDir(), "edited.tar.gz")
+	out, err := os.Create(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		if hdr, data = edit(hdr, data); hdr == nil {
+			continue
+		}
+		hdr.Size = int64(len(data))
+		tw.WriteHeader(hdr)
+		tw.Write(data)
+	}
+	tw.Close()
+	gzw.Close()
+	return dest
+}
+
+func TestImportSessionRejectsBadBundles(t *testing.T) {
+	src := filepath.Join(t.TempDir(), ".claude")
+	writeTestSession(t, src)
+	bundle := filepath.Join(t.TempDir(), "session.tar.gz")
+	if _, err := ExportSession(src, bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(*tar.Header, []byte) (*tar.Header, []byte)
+		want string
+	}{
+		{"tampered", func(h *tar.Header, d []byte) (*tar.Header, []byte) {
+			if h.Name == ".claude/config.json" {
+				d = []byte(`{"model":"x"}`)
+			}
+			return h, d
+		}, "checksum mismatch"},
+		{"missing file", func(h *tar.Header, d []byte) (*tar.Header, []byte) {
+			if h.Name == ".claude/config.json" {
+				return nil, nil
+			}
+			return h, d
+		}, "missing config.json"},
+		{"path traversal", func(h *tar.Header, d []byte) (*tar.Header, []byte) {
+			if h.Name == ".claude/config.json" {
+				h.Name = ".claude/../../evil"
+			}
+			return h, d
+		}, "invalid path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), ".claude")
+			_, _, err := ImportSession(dest, rewriteBundle(t, bundle, tt.edit), false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ImportSession = %v, want error containing %q", err, tt.want)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(dest))
+			if len(entries) != 0 {
+				t.Errorf("failed import left %d entries behind", len(entries))
+			}
+		})
+	}
+}
@@ -297,3 +297,21 @@ func TestProviderStats_90_10_Ratio(t *testing.T) {
 		t.Error("IsOverClaudeQuota(0.1) = false, want true (over quota after 11th Claude request)")
 	}
 }
+
+func TestRecordRoutingOutcome(t *testing.T) {
+	cfg := &storage.Config{}
+	storage.RecordRoutingOutcome(cfg, "moderate", false, 2)
+	storage.RecordRoutingOutcome(cfg, "moderate", true, 4)
+	storage.RecordRoutingOutcome(cfg, "simple", false, 1)
+
+	o := cfg.RoutingOutcomes["moderate"]
+	if o.Runs != 2 || o.Fallbacks != 1 || o.Iterations != 6 {
+		t.Errorf("moderate outcome = %+v", o)
+	}
+	if o.FallbackRate() != 0.5 || o.AvgIterations() != 3 {
+		t.Errorf("rate %v, iterations %v", o.FallbackRate(), o.AvgIterations())
+	}
+	if cfg.RoutingOutcomes["simple"].Runs != 1 {
+		t.Errorf("simple outcome = %+v", cfg.RoutingOutcomes["simple"])
+	}
+}
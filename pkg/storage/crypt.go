@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// crypt.go - Optional AES-GCM encryption at rest
+//
+// Request, response, audit log and backup files can contain proprietary
+// source code. When a key is set, every write of those files goes through
+// writeSealedFile and every read through readSealedFile. Files written before encryption
+// was enabled are still readable (no magic header = plaintext), so turning
+// encryption on never strands existing history.
+//
+// config.json and models.json are never encrypted: they hold no source and
+// config.json carries the key derivation salt.
+
+// encryptedMagic prefixes every encrypted file
+const encryptedMagic = "GOCLAUDE-ENC1\n"
+
+// auditLinePrefix marks an encrypted line in the JSONL audit log
+const auditLinePrefix = "enc:"
+
+// pbkdf2Iterations follows current OWASP guidance for PBKDF2-HMAC-SHA256
+const pbkdf2Iterations = 600_000
+
+// ErrEncrypted is returned when reading an encrypted file without a key
+var ErrEncrypted = errors.New("file is encrypted but no encryption key is configured")
+
+// fileAEAD is the active cipher; nil means files are written in plaintext
+var fileAEAD cipher.AEAD
+
+// SetEncryptionKey enables encryption at rest with a 32-byte AES-256 key.
+// A nil key disables encryption for subsequent writes.
+func SetEncryptionKey(key []byte) error {
+	if key == nil {
+		fileAEAD = nil
+		return nil
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("create GCM: %w", err)
+	}
+	fileAEAD = aead
+	return nil
+}
+
+// EncryptionEnabled reports whether a key is configured
+func EncryptionEnabled() bool {
+	return fileAEAD != nil
+}
+
+// DeriveKey derives a 32-byte key from a passphrase and salt
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+}
+
+// NewSalt returns a random salt for DeriveKey
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// seal encrypts data if a key is configured, otherwise returns it unchanged
+func seal(data []byte) ([]byte, error) {
+	if fileAEAD == nil {
+		return data, nil
+	}
+	nonce := make([]byte, fileAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	out := make([]byte, 0, len(encryptedMagic)+len(nonce)+len(data)+fileAEAD.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, nonce...)
+	return fileAEAD.Seal(out, nonce, data, nil), nil
+}
+
+// unseal decrypts data written by seal; plaintext passes through untouched
+func unseal(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return data, nil
+	}
+	if fileAEAD == nil {
+		return nil, ErrEncrypted
+	}
+	data = data[len(encryptedMagic):]
+	nonceSize := fileAEAD.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("decrypt: ciphertext too short")
+	}
+	plain, err := fileAEAD.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: wrong key or corrupt file: %w", err)
+	}
+	return plain, nil
+}
+
+// sealAuditLine encrypts one audit log line (without trailing newline)
+func sealAuditLine(line []byte) ([]byte, error) {
+	if fileAEAD == nil {
+		return line, nil
+	}
+	sealed, err := seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(auditLinePrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// UnsealAuditLine decrypts one line of tool_log.jsonl
+func UnsealAuditLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, []byte(auditLinePrefix)) {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(line[len(auditLinePrefix):]))
+	if err != nil {
+		return nil, fmt.Errorf("decode audit line: %w", err)
+	}
+	return unseal(sealed)
+}
+
+// writeSealedFile atomically writes data, encrypting it if enabled
+func writeSealedFile(path string, data []byte) error {
+	sealed, err := seal(data)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := writeFile(tmpPath, sealed, 0o644); err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := rename(tmpPath, path); err != nil {
+		remove(tmpPath)
+		return fmt.Errorf("atomic rename: %w", err)
+	}
+	return nil
+}
+
+// readSealedFile reads a file written by writeSealedFile, inflating it if
+// --gc compressed it
+func readSealedFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = unseal(data)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(data)
+}
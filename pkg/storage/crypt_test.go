@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncryptedRequestRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := SetEncryptionKey(testKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetEncryptionKey(nil) })
+
+	messages := []MessageContent{{
+		Role:    "user",
+		Content: []ContentBlock{{Type: "text", Text: "proprietary secret sauce"}},
+	}}
+	if err := SaveRequest(tmpDir, "20260105_120000", messages); err != nil {
+		t.Fatalf("SaveRequest failed: %v", err)
+	}
+	if err := SaveResponse(tmpDir, "20260105_120000", []byte(`[{"content":[{"type":"text","text":"more sauce"}]}]`)); err != nil {
+		t.Fatalf("SaveResponse failed: %v", err)
+	}
+	if _, err := SaveComparison(tmpDir, "20260105_120000", map[string]string{"answer": "compared sauce"}); err != nil {
+		t.Fatalf("SaveComparison failed: %v", err)
+	}
+
+	// Nothing readable on disk
+	for _, name := range []string{"request_20260105_120000.json", "response_20260105_120000.json",
+		"compare/compare_20260105_120000.json"} {
+		raw, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, []byte("sauce")) {
+			t.Errorf("%s contains plaintext", name)
+		}
+		if !bytes.HasPrefix(raw, []byte(encryptedMagic)) {
+			t.Errorf("%s missing encryption header", name)
+		}
+	}
+
+	history, err := LoadConversationHistory(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadConversationHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Content[0].Text != "proprietary secret sauce" ||
+		history[1].Content[0].Text != "more sauce" {
+		t.Errorf("unexpected history after decrypt: %+v", history)
+	}
+	var cmp map[string]string
+	if err := LoadComparison(filepath.Join(tmpDir, "compare", "compare_20260105_120000.json"), &cmp); err != nil ||
+		cmp["answer"] != "compared sauce" {
+		t.Errorf("LoadComparison = %v, %v", cmp, err)
+	}
+}
+
+func TestEncryptedFileWithoutKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	SetEncryptionKey(testKey(1))
+	t.Cleanup(func() { SetEncryptionKey(nil) })
+	if err := SaveRequest(tmpDir, "20260105_120000", nil); err != nil {
+		t.Fatal(err)
+	}
+	reqPath := filepath.Join(tmpDir, "request_20260105_120000.json")
+
+	SetEncryptionKey(nil)
+	if _, err := LoadRequest(reqPath); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("expected ErrEncrypted without key, got %v", err)
+	}
+
+	SetEncryptionKey(testKey(2))
+	if _, err := LoadRequest(reqPath); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("expected wrong key error, got %v", err)
+	}
+}
+
+func TestPlaintextReadableWithKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := SaveRequest(tmpDir, "20260105_120000", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	SetEncryptionKey(testKey(1))
+	t.Cleanup(func() { SetEncryptionKey(nil) })
+	if _, err := LoadRequest(filepath.Join(tmpDir, "request_20260105_120000.json")); err != nil {
+		t.Errorf("plaintext request should stay readable once encryption is on: %v", err)
+	}
+}
+
+func TestEncryptedAuditLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	SetEncryptionKey(testKey(1))
+	t.Cleanup(func() { SetEncryptionKey(nil) })
+
+	if err := AppendAuditLog(tmpDir, AuditLogEntry{Tool: "read_file", Error: "sauce"}); err != nil {
+		t.Fatalf("AppendAuditLog failed: %v", err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(tmpDir, "tool_log.jsonl"))
+	if bytes.Contains(raw, []byte("sauce")) {
+		t.Error("audit log contains plaintext")
+	}
+
+	line, err := UnsealAuditLine(bytes.TrimRight(raw, "\n"))
+	if err != nil {
+		t.Fatalf("UnsealAuditLine failed: %v", err)
+	}
+	if !bytes.Contains(line, []byte(`"tool":"read_file"`)) {
+		t.Errorf("unexpected decrypted line: %s", line)
+	}
+}
+
+func TestSetEncryptionKeyLength(t *testing.T) {
+	if err := SetEncryptionKey([]byte("short")); err == nil {
+		t.Error("expected error for short key")
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// embeddings.go - Semantic search index (.claude/index/)
+//
+// claude index splits project files into chunks and stores one embedding
+// vector per chunk together with the chunk text, so semantic_search can
+// answer without reading the files again. The embedder is recorded because
+// queries must be embedded by the same model to be comparable.
+
+// EmbeddingIndex is the semantic search index of a project
+type EmbeddingIndex struct {
+	Provider   string            `json:"provider"` // ollama or openai
+	Model      string            `json:"model"`
+	URL        string            `json:"url"`
+	Dimensions int               `json:"dimensions"`
+	Updated    time.Time         `json:"updated"`
+	Files      map[string]string `json:"files"` // path -> SHA-256 when indexed
+	Chunks     []EmbeddedChunk   `json:"chunks"`
+}
+
+// EmbeddedChunk is a line range of a file and its embedding
+type EmbeddedChunk struct {
+	Path      string    `json:"path"` // slash separated, relative to the project
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"` // inclusive
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+}
+
+func embeddingIndexPath(claudeDir string) string {
+	return filepath.Join(claudeDir, "index", "embeddings.json")
+}
+
+// HasEmbeddingIndex reports whether claude index has been run
+func HasEmbeddingIndex(claudeDir string) bool {
+	_, err := os.Stat(embeddingIndexPath(claudeDir))
+	return err == nil
+}
+
+// SaveEmbeddingIndex replaces the semantic search index
+func SaveEmbeddingIndex(claudeDir string, idx *EmbeddingIndex) error {
+	path := embeddingIndexPath(claudeDir)
+	if err := mkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create index directory: %w", err)
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("marshal JSON: %w", err)
+	}
+	return writeSealedFile(path, data)
+}
+
+// LoadEmbeddingIndex loads the semantic search index. Projects that were
+// never indexed return nil and no error.
+func LoadEmbeddingIndex(claudeDir string) (*EmbeddingIndex, error) {
+	data, err := readSealedFile(embeddingIndexPath(claudeDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read embedding index: %w", err)
+	}
+	var idx EmbeddingIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("unmarshal embedding index: %w", err)
+	}
+	return &idx, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fork.go - Conversation forks (--fork)
+//
+// A fork is a new .claude directory holding the history of another one up
+// to and including a given turn, so a conversation can continue in a
+// different direction while the original stays as it is. Turn files are
+// copied as stored (encrypted sessions keep their key) and both configs
+// record the link between the two.
+
+// Fork links a session to a session forked from it
+type Fork struct {
+	Dir     string    `json:"dir"`  // the other .claude directory
+	Turn    string    `json:"turn"` // the last turn both share
+	Created time.Time `json:"created"`
+}
+
+// turnFile matches the files of a turn, and the backups made during it
+var turnFile = regexp.MustCompile(`^(?:(?:request|response|meta|transcript)_(\d{8}_\d{6})\.json|backups/(\d{8}_\d{6})/.*)$`)
+
+// forkSkip reports whether the file rel of a session stays out of a fork
+// at turn upTo: turns after upTo and what only the original session
+// uses, its lock, audit log, journals, quarantine and pending patches.
+// The history index is rebuilt by the first load of the fork.
+func forkSkip(rel, upTo string) bool {
+	if m := turnFile.FindStringSubmatch(rel); m != nil {
+		return m[1]+m[2] > upTo
+	}
+	switch {
+	case bundleSkip(rel), strings.HasSuffix(rel, ".deleting"),
+		rel == "history_index.jsonl", rel == "tool_log.jsonl",
+		strings.HasPrefix(rel, "journal_"), strings.HasPrefix(rel, "pending_"),
+		strings.HasPrefix(rel, "quarantine/"):
+		return true
+	}
+	return false
+}
+
+// ForkSession copies the history of srcDir up to and including turn upTo
+// into dstDir, which must not exist yet, and records the fork in both
+// configs. It returns the number of turns copied.
+func ForkSession(srcDir, dstDir, upTo string) (int, error) {
+	pairs, err := ListRequestResponsePairs(srcDir)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(pairs, upTo) {
+		return 0, fmt.Errorf("no turn %s in %s", upTo, srcDir)
+	}
+	if _, err := os.Stat(dstDir); err == nil {
+		return 0, fmt.Errorf("%s already exists; fork into a directory without a session", dstDir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err := mkdirAll(filepath.Dir(dstDir), 0o755); err != nil {
+		return 0, err
+	}
+
+	// Copy next to dstDir and rename it into place when complete
+	tmpDir, err := mkdirTemp(filepath.Dir(dstDir), ".claude-fork-")
+	if err != nil {
+		return 0, fmt.Errorf("create fork dir: %w", err)
+	}
+	err = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		if forkSkip(filepath.ToSlash(rel), upTo) {
+			return nil
+		}
+		return copyFile(p, filepath.Join(tmpDir, rel))
+	})
+	if err != nil {
+		removeAll(tmpDir)
+		return 0, fmt.Errorf("copy %s: %w", srcDir, err)
+	}
+
+	created := time.Now().UTC()
+	cfg := LoadOrCreateConfig(filepath.Join(tmpDir, "config.json"))
+	cfg.ForkedFrom = &Fork{Dir: srcDir, Turn: upTo, Created: created}
+	cfg.Forks = nil
+	if err := SaveJSON(filepath.Join(tmpDir, "config.json"), cfg); err != nil {
+		removeAll(tmpDir)
+		return 0, err
+	}
+	if err := chmod(tmpDir, 0o755); err != nil {
+		removeAll(tmpDir)
+		return 0, err
+	}
+	if err := rename(tmpDir, dstDir); err != nil {
+		removeAll(tmpDir)
+		return 0, fmt.Errorf("move fork into place: %w", err)
+	}
+
+	// The fork is complete without this; the link only serves --history
+	srcConfig := filepath.Join(srcDir, "config.json")
+	src := LoadOrCreateConfig(srcConfig)
+	src.Forks = append(src.Forks, Fork{Dir: dstDir, Turn: upTo, Created: created})
+	if err := SaveJSON(srcConfig, src); err != nil {
+		return 0, fmt.Errorf("recording fork in %s: %w", srcConfig, err)
+	}
+
+	n := 0
+	for _, ts := range pairs {
+		if ts <= upTo {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// copyFile copies src to the new file dst, keeping its permissions
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := mkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := openFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForkSession(t *testing.T) {
+	src := filepath.Join(t.TempDir(), ".claude")
+	writeTestSession(t, src)
+	for name, content := range map[string]string{
+		"request_20260102_000000.json":  `{"messages":[]}`,
+		"response_20260102_000000.json": `[]`,
+		"meta_20260102_000000.json":     `{}`,
+		"backups/20260102_000000/b.go":  "package b\n",
+		"tool_log.jsonl":                "{}\n",
+		"journal_20260103_000000.json":  "{}",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "alt", ".claude")
+	n, err := ForkSession(src, dst, "20260101_000000")
+	if err != nil {
+		t.Fatalf("ForkSession: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("forked %d turns, want 1", n)
+	}
+	pairs, err := ListRequestResponsePairs(dst)
+	if err != nil || len(pairs) != 1 || pairs[0] != "20260101_000000" {
+		t.Errorf("fork turns = %v, %v", pairs, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "backups/20260101_000000/a.go")); err != nil {
+		t.Errorf("backup of the forked turn missing: %v", err)
+	}
+	for _, name := range []string{
+		"meta_20260102_000000.json", "backups/20260102_000000", "lock",
+		"tool_log.jsonl", "journal_20260103_000000.json", "config.json.tmp",
+	} {
+		if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was copied into the fork", name)
+		}
+	}
+
+	// Both configs link the sessions
+	fork := LoadOrCreateConfig(filepath.Join(dst, "config.json"))
+	if fork.Model != "m" || fork.ForkedFrom == nil || fork.ForkedFrom.Dir != src ||
+		fork.ForkedFrom.Turn != "20260101_000000" {
+		t.Errorf("fork config = %+v", fork)
+	}
+	orig := LoadOrCreateConfig(filepath.Join(src, "config.json"))
+	if len(orig.Forks) != 1 || orig.Forks[0].Dir != dst {
+		t.Errorf("original forks = %+v", orig.Forks)
+	}
+	if _, err := os.Stat(filepath.Join(src, "request_20260102_000000.json")); err != nil {
+		t.Errorf("original lost a turn: %v", err)
+	}
+
+	if _, err := ForkSession(src, dst, "20260101_000000"); err == nil ||
+		!strings.Contains(err.Error(), "already exists") {
+		t.Errorf("fork over existing dir = %v", err)
+	}
+	if _, err := ForkSession(src, filepath.Join(t.TempDir(), ".claude"), "20251231_000000"); err == nil ||
+		!strings.Contains(err.Error(), "no turn") {
+		t.Errorf("fork at unknown turn = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fsck.go - Consistency checks of .claude (--fsck)
+//
+// Every complete turn must parse, and its files must still match the
+// checksums recorded in the history index when they were saved. A corrupt
+// turn can be quarantined: its files move to .claude/quarantine/<ts>/ where
+// they no longer take part in the history.
+
+// FsckProblem is one finding of Fsck
+type FsckProblem struct {
+	Timestamp string `json:"timestamp"`
+	File      string `json:"file"`
+	Problem   string `json:"problem"`
+}
+
+// Fsck checks the turns in claudeDir and returns the problems found
+func Fsck(claudeDir string) ([]FsckProblem, error) {
+	pairs, err := ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	index := loadIndex(claudeDir)
+
+	var problems []FsckProblem
+	report := func(ts, path string, format string, args ...any) {
+		problems = append(problems, FsckProblem{
+			Timestamp: ts,
+			File:      filepath.Base(path),
+			Problem:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, ts := range pairs {
+		reqPath, respPath := requestPath(claudeDir, ts), responsePath(claudeDir, ts)
+		req, err := LoadRequest(reqPath)
+		if err != nil {
+			report(ts, reqPath, "%v", err)
+		} else if len(req.Messages) == 0 {
+			report(ts, reqPath, "request has no messages")
+		}
+		responses, err := LoadResponses(claudeDir, ts)
+		if err != nil {
+			report(ts, respPath, "%v", err)
+		} else if len(responses) == 0 {
+			report(ts, respPath, "response has no messages")
+		}
+		if _, err := LoadTurnMeta(claudeDir, ts); err != nil {
+			report(ts, metaPath(claudeDir, ts), "%v", err)
+		}
+		if _, err := LoadTranscript(claudeDir, ts); err != nil {
+			report(ts, transcriptPath(claudeDir, ts), "%v", err)
+		}
+
+		entry, ok := index[ts]
+		if !ok {
+			continue
+		}
+		for _, f := range []struct{ path, sum string }{
+			{reqPath, entry.RequestSHA256},
+			{respPath, entry.ResponseSHA256},
+		} {
+			if f.sum == "" {
+				continue
+			}
+			if sum, err := fileSHA256(f.path); err == nil && sum != f.sum {
+				report(ts, f.path, "checksum mismatch: changed since it was saved")
+			}
+		}
+	}
+
+	// Responses whose request is gone are invisible to the history
+	entries, err := os.ReadDir(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "response_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, "response_"), ".json")
+		if _, err := os.Stat(requestPath(claudeDir, ts)); os.IsNotExist(err) {
+			report(ts, name, "response without request")
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Timestamp < problems[j].Timestamp
+	})
+	return problems, nil
+}
+
+// Quarantine moves the files of turn ts to .claude/quarantine/<ts>/ and
+// returns that directory
+func Quarantine(claudeDir, ts string) (string, error) {
+	dir := filepath.Join(claudeDir, "quarantine", ts)
+	if err := mkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create quarantine dir: %w", err)
+	}
+	for _, path := range []string{
+		requestPath(claudeDir, ts), responsePath(claudeDir, ts), metaPath(claudeDir, ts),
+		transcriptPath(claudeDir, ts),
+	} {
+		err := rename(path, filepath.Join(dir, filepath.Base(path)))
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("quarantine %s: %w", ts, err)
+		}
+	}
+	if err := compactIndex(claudeDir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFsck(t *testing.T) {
+	claudeDir := t.TempDir()
+	truncated := saveTurnAt(t, claudeDir, 3*time.Hour, 10)
+	edited := saveTurnAt(t, claudeDir, 2*time.Hour, 10)
+	saveTurnAt(t, claudeDir, time.Hour, 10)
+
+	if problems, err := Fsck(claudeDir); err != nil || len(problems) != 0 {
+		t.Fatalf("Fsck on a clean session = %+v, %v", problems, err)
+	}
+
+	// A response cut short by a crash, and a request changed behind our back
+	data, err := os.ReadFile(responsePath(claudeDir, truncated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(responsePath(claudeDir, truncated), data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveRequest(claudeDir, edited, []MessageContent{{
+		Role: "user", Content: []ContentBlock{{Type: "text", Text: "edited"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Fsck(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 3 {
+		t.Fatalf("Fsck found %+v, want parse error and checksum mismatch of %s, "+
+			"checksum mismatch of %s", problems, truncated, edited)
+	}
+	got := map[string]string{}
+	for _, p := range problems {
+		got[p.Timestamp+" "+p.File] += p.Problem + ";"
+	}
+	if !strings.Contains(got[truncated+" response_"+truncated+".json"], "checksum mismatch") ||
+		!strings.Contains(got[edited+" request_"+edited+".json"], "checksum mismatch") {
+		t.Errorf("unexpected problems: %v", got)
+	}
+}
+
+func TestFsckQuarantine(t *testing.T) {
+	claudeDir := t.TempDir()
+	corrupt := saveTurnAt(t, claudeDir, 2*time.Hour, 10)
+	saveTurnAt(t, claudeDir, time.Hour, 10)
+
+	// Without an index entry the corrupt turn has to be parsed at load
+	os.Remove(indexPath(claudeDir))
+	if err := os.WriteFile(responsePath(claudeDir, corrupt), []byte(`[{"content":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConversationHistory(claudeDir); err == nil ||
+		!strings.Contains(err.Error(), corrupt) || !strings.Contains(err.Error(), "--fsck") {
+		t.Fatalf("LoadConversationHistory = %v, want error naming %s", err, corrupt)
+	}
+
+	// An orphaned response is reported too
+	orphan := "20200101_000000"
+	if err := os.WriteFile(responsePath(claudeDir, orphan), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := Fsck(claudeDir)
+	if err != nil || len(problems) != 2 {
+		t.Fatalf("Fsck = %+v, %v; want corrupt turn and orphan", problems, err)
+	}
+
+	dir, err := Quarantine(claudeDir, corrupt)
+	if err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "response_"+corrupt+".json")); err != nil {
+		t.Errorf("response not moved to quarantine: %v", err)
+	}
+	messages, err := LoadConversationHistory(claudeDir)
+	if err != nil || len(messages) != 2 {
+		t.Errorf("after quarantine: %d messages, %v", len(messages), err)
+	}
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// gc.go - Garbage collection of .claude (--gc)
+//
+// Old turns are compressed in place: the file names stay the same and
+// readSealedFile inflates gzip content transparently, so everything that
+// loads requests and responses keeps working. Turns past the age limit, and
+// then the oldest turns until .claude fits the size limit, are deleted with
+// their backups and history index lines. The newest turn is always kept.
+
+// GCOptions are the limits enforced by GarbageCollect; zero disables one
+type GCOptions struct {
+	MaxAge        time.Duration // delete turns older than this
+	MaxSize       int64         // bytes the .claude directory may use
+	CompressAfter time.Duration // gzip turns older than this
+}
+
+// GCResult reports what GarbageCollect did
+type GCResult struct {
+	Deleted    []string // timestamps of deleted turns
+	Compressed int      // turns compressed
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// gzipMagic starts every gzip stream; JSON never does
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress inflates gzip data and passes anything else through
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	defer zr.Close()
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	return plain, nil
+}
+
+// turnTime parses a conversation timestamp
+func turnTime(ts string) (time.Time, error) {
+	return time.ParseInLocation("20060102_150405", ts, time.Local)
+}
+
+// GarbageCollect enforces opts on claudeDir
+func GarbageCollect(claudeDir string, opts GCOptions) (*GCResult, error) {
+	if err := CleanupOrphanedDeletingFiles(claudeDir); err != nil {
+		return nil, fmt.Errorf("cleanup orphaned files: %w", err)
+	}
+	pairs, err := ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &GCResult{}
+	if res.SizeBefore, err = dirSize(claudeDir); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	// The newest turn is never deleted: that's what --reset is for
+	if len(pairs) > 0 {
+		pairs = pairs[:len(pairs)-1]
+	}
+
+	var kept []string
+	for _, ts := range pairs {
+		t, err := turnTime(ts)
+		if err != nil {
+			kept = append(kept, ts)
+			continue
+		}
+		age := now.Sub(t)
+		if opts.MaxAge > 0 && age > opts.MaxAge {
+			if err := removeTurn(claudeDir, ts); err != nil {
+				return res, err
+			}
+			res.Deleted = append(res.Deleted, ts)
+			continue
+		}
+		kept = append(kept, ts)
+
+		if opts.CompressAfter > 0 && age > opts.CompressAfter {
+			compressed, err := compressTurn(claudeDir, ts)
+			if err != nil {
+				return res, err
+			}
+			if compressed {
+				// Record the new checksums; compaction drops the old line
+				res.Compressed++
+				if err := indexTurn(claudeDir, ts); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+
+	size, err := gcSize(claudeDir, len(res.Deleted) > 0 || res.Compressed > 0)
+	if err != nil {
+		return res, err
+	}
+	for opts.MaxSize > 0 && size > opts.MaxSize && len(kept) > 0 {
+		ts := kept[0]
+		kept = kept[1:]
+		if err := removeTurn(claudeDir, ts); err != nil {
+			return res, err
+		}
+		res.Deleted = append(res.Deleted, ts)
+		if size, err = gcSize(claudeDir, true); err != nil {
+			return res, err
+		}
+	}
+	res.SizeAfter = size
+	return res, nil
+}
+
+// gcSize returns the size of claudeDir, first dropping stale lines from
+// the history index if compact is set
+func gcSize(claudeDir string, compact bool) (int64, error) {
+	if compact {
+		if err := compactIndex(claudeDir); err != nil {
+			return 0, err
+		}
+	}
+	return dirSize(claudeDir)
+}
+
+// compressTurn gzips the request and response of ts unless they already
+// are. It reports whether anything was compressed.
+func compressTurn(claudeDir, ts string) (bool, error) {
+	compressed := false
+	for _, name := range []string{"request_%s.json", "response_%s.json"} {
+		path := filepath.Join(claudeDir, fmt.Sprintf(name, ts))
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return compressed, fmt.Errorf("compress %s: %w", ts, err)
+		}
+		plain, err := unseal(raw)
+		if err != nil {
+			return compressed, fmt.Errorf("compress %s: %w", ts, err)
+		}
+		if bytes.HasPrefix(plain, gzipMagic) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(plain); err != nil {
+			return compressed, fmt.Errorf("compress %s: %w", ts, err)
+		}
+		if err := zw.Close(); err != nil {
+			return compressed, fmt.Errorf("compress %s: %w", ts, err)
+		}
+		if err := writeSealedFile(path, buf.Bytes()); err != nil {
+			return compressed, fmt.Errorf("compress %s: %w", ts, err)
+		}
+		compressed = true
+	}
+	return compressed, nil
+}
+
+// removeTurn deletes the request, response, metadata, transcript and
+// backups of ts
+func removeTurn(claudeDir, ts string) error {
+	if err := removePair(claudeDir, ts); err != nil {
+		return err
+	}
+	if err := removeAll(filepath.Join(claudeDir, "backups", ts)); err != nil {
+		return fmt.Errorf("remove backups of %s: %w", ts, err)
+	}
+	return nil
+}
+
+// removePair deletes the request, response, metadata and transcript of ts. Like
+// PruneResponses the pair is renamed to .deleting first so an interrupted
+// removal never leaves half a turn in the history.
+func removePair(claudeDir, ts string) error {
+	reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", ts))
+	respPath := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", ts))
+	if err := rename(reqPath, reqPath+".deleting"); err != nil {
+		return fmt.Errorf("remove %s: %w", ts, err)
+	}
+	if err := rename(respPath, respPath+".deleting"); err != nil {
+		rename(reqPath+".deleting", reqPath)
+		return fmt.Errorf("remove %s: %w", ts, err)
+	}
+	for _, path := range []string{reqPath + ".deleting", respPath + ".deleting"} {
+		if err := remove(path); err != nil {
+			return fmt.Errorf("remove %s: %w", ts, err)
+		}
+	}
+	if err := removeTurnMeta(claudeDir, ts); err != nil {
+		return err
+	}
+	return removeTranscript(claudeDir, ts)
+}
+
+// dirSize returns the bytes used by the files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("size of %s: %w", dir, err)
+	}
+	return size, nil
+}
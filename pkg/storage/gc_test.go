@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveTurnAt saves a turn with a timestamp age ago and a response of
+// roughly size bytes
+func saveTurnAt(t *testing.T, claudeDir string, age time.Duration, size int) string {
+	t.Helper()
+	ts := time.Now().Add(-age).Format("20060102_150405")
+	msg := MessageContent{Role: "user", Content: []ContentBlock{{Type: "text", Text: "q " + ts}}}
+	if err := SaveRequest(claudeDir, ts, []MessageContent{msg}); err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := json.Marshal([]APIResponse{{
+		Role:    "assistant",
+		Content: []ContentBlock{{Type: "text", Text: strings.Repeat("a", size)}},
+	}})
+	if err := SaveResponse(claudeDir, ts, resp); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestGarbageCollectAgeAndCompression(t *testing.T) {
+	claudeDir := t.TempDir()
+	day := 24 * time.Hour
+	ancient := saveTurnAt(t, claudeDir, 40*day, 100)
+	old := saveTurnAt(t, claudeDir, 10*day, 10000)
+	recent := saveTurnAt(t, claudeDir, time.Hour, 100)
+	if err := os.MkdirAll(filepath.Join(claudeDir, "backups", ancient), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := GarbageCollect(claudeDir, GCOptions{MaxAge: 30 * day, CompressAfter: 7 * day})
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if len(res.Deleted) != 1 || res.Deleted[0] != ancient || res.Compressed != 1 {
+		t.Errorf("result = %+v, want %s deleted and one turn compressed", res, ancient)
+	}
+	if _, err := os.Stat(filepath.Join(claudeDir, "backups", ancient)); !os.IsNotExist(err) {
+		t.Errorf("backups of deleted turn kept: %v", err)
+	}
+	if res.SizeAfter >= res.SizeBefore {
+		t.Errorf("size went from %d to %d", res.SizeBefore, res.SizeAfter)
+	}
+
+	// Compressed turns still load
+	raw, _ := os.ReadFile(filepath.Join(claudeDir, "response_"+old+".json"))
+	if len(raw) > 1000 {
+		t.Errorf("response of %s not compressed (%d bytes)", old, len(raw))
+	}
+	messages, err := LoadConversationHistory(claudeDir)
+	if err != nil || len(messages) != 4 {
+		t.Fatalf("LoadConversationHistory = %d messages, %v; want 4", len(messages), err)
+	}
+	if messages[0].Content[0].Text != "q "+old || len(messages[1].Content[0].Text) != 10000 {
+		t.Errorf("compressed turn loaded wrong: %+v", messages[0])
+	}
+
+	// Running again changes nothing
+	res, err = GarbageCollect(claudeDir, GCOptions{MaxAge: 30 * day, CompressAfter: 7 * day})
+	if err != nil || len(res.Deleted) != 0 || res.Compressed != 0 {
+		t.Errorf("second run = %+v, %v", res, err)
+	}
+	if pairs, _ := ListRequestResponsePairs(claudeDir); len(pairs) != 2 || pairs[1] != recent {
+		t.Errorf("pairs = %v", pairs)
+	}
+}
+
+func TestGarbageCollectMaxSize(t *testing.T) {
+	claudeDir := t.TempDir()
+	var turns []string
+	for i := 5; i > 0; i-- {
+		turns = append(turns, saveTurnAt(t, claudeDir, time.Duration(i)*time.Hour, 2000))
+	}
+
+	res, err := GarbageCollect(claudeDir, GCOptions{MaxSize: 5000})
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if res.SizeAfter > 5000 {
+		t.Errorf("size after = %d, want at most 5000", res.SizeAfter)
+	}
+	pairs, _ := ListRequestResponsePairs(claudeDir)
+	if len(pairs) == 0 || pairs[len(pairs)-1] != turns[4] {
+		t.Fatalf("newest turn not kept: %v", pairs)
+	}
+	for i, ts := range res.Deleted {
+		if ts != turns[i] {
+			t.Errorf("deleted %v, want the oldest first", res.Deleted)
+		}
+	}
+
+	// A limit nothing fits in keeps the newest turn
+	GarbageCollect(claudeDir, GCOptions{MaxSize: 1})
+	if pairs, _ := ListRequestResponsePairs(claudeDir); len(pairs) != 1 || pairs[0] != turns[4] {
+		t.Errorf("pairs = %v, want only the newest", pairs)
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// hooks.go - Lifecycle hooks (.claude/hooks.json)
+//
+// Hooks are shell commands run at fixed points of a conversation. Like
+// policy.json the file is edited by hand and never rewritten.
+
+// Hook lifecycle events
+const (
+	HookPreTool    = "pre_tool"    // before a tool runs; non-zero exit vetoes it
+	HookPostTool   = "post_tool"   // after a tool ran
+	HookPreRequest = "pre_request" // before each LLM request; non-zero exit aborts
+	HookPostTurn   = "post_turn"   // after the final answer of a turn
+	HookBudget     = "budget"      // when spend crosses a budget alert threshold
+)
+
+// Hook is one command run through sh -c with the event as JSON on stdin
+type Hook struct {
+	Command string   `json:"command"`
+	Tools   []string `json:"tools,omitempty"`   // tool hooks only: limit to these tools
+	Timeout int      `json:"timeout,omitempty"` // seconds (0 = default)
+}
+
+// Hooks is the contents of .claude/hooks.json
+type Hooks struct {
+	PreTool    []Hook `json:"pre_tool,omitempty"`
+	PostTool   []Hook `json:"post_tool,omitempty"`
+	PreRequest []Hook `json:"pre_request,omitempty"`
+	PostTurn   []Hook `json:"post_turn,omitempty"`
+	Budget     []Hook `json:"budget,omitempty"`
+}
+
+// For returns the hooks registered for event
+func (h *Hooks) For(event string) []Hook {
+	if h == nil {
+		return nil
+	}
+	switch event {
+	case HookPreTool:
+		return h.PreTool
+	case HookPostTool:
+		return h.PostTool
+	case HookPreRequest:
+		return h.PreRequest
+	case HookPostTurn:
+		return h.PostTurn
+	case HookBudget:
+		return h.Budget
+	}
+	return nil
+}
+
+// LoadHooks reads .claude/hooks.json. A missing file yields no hooks.
+func LoadHooks(claudeDir string) (*Hooks, error) {
+	var h Hooks
+	data, err := os.ReadFile(filepath.Join(claudeDir, "hooks.json"))
+	if os.IsNotExist(err) {
+		return &h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read hooks: %w", err)
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("parse hooks.json: %w", err)
+	}
+	for _, event := range []string{HookPreTool, HookPostTool, HookPreRequest, HookPostTurn, HookBudget} {
+		for i, hook := range h.For(event) {
+			if hook.Command == "" {
+				return nil, fmt.Errorf("hooks.json: %s[%d] has no command", event, i)
+			}
+		}
+	}
+	return &h, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	h, err := LoadHooks(tmpDir)
+	if err != nil {
+		t.Fatalf("missing hooks should not error: %v", err)
+	}
+	if len(h.For(HookPreTool)) != 0 {
+		t.Errorf("unexpected default hooks: %+v", h)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "hooks.json"), []byte(`{
+		"pre_tool": [{"command": "./lint.sh", "tools": ["write_file"]}],
+		"post_turn": [{"command": "notify-send done", "timeout": 5}]
+	}`), 0o644)
+	h, err = LoadHooks(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pre := h.For(HookPreTool); len(pre) != 1 || pre[0].Tools[0] != "write_file" {
+		t.Errorf("pre_tool = %+v", pre)
+	}
+	if post := h.For(HookPostTurn); len(post) != 1 || post[0].Timeout != 5 {
+		t.Errorf("post_turn = %+v", post)
+	}
+	if h.For("unknown") != nil {
+		t.Error("unknown event has hooks")
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "hooks.json"),
+		[]byte(`{"pre_request": [{"tools": ["x"]}]}`), 0o644)
+	if _, err := LoadHooks(tmpDir); err == nil {
+		t.Error("expected error for hook without command")
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "hooks.json"), []byte(`{`), 0o644)
+	if _, err := LoadHooks(tmpDir); err == nil {
+		t.Error("expected error for invalid hooks.json")
+	}
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// index.go - History index (.claude/history_index.jsonl)
+//
+// Rebuilding the history means parsing every request/response pair. The
+// index keeps the two messages each turn contributes, one JSON line per
+// turn, appended when the response is saved. LoadConversationHistory only
+// parses the files of turns the index doesn't have yet (turns from before
+// the index existed, or whose append failed) and adds them. Turns that were
+// pruned are skipped because the pair list still comes from the directory.
+// Lines are sealed like audit log lines when encryption is on. Each entry
+// also records the SHA-256 of the files as saved, which --fsck checks.
+
+// IndexEntry is the history contribution of one turn
+type IndexEntry struct {
+	Timestamp string          `json:"ts"`
+	User      *MessageContent `json:"user,omitempty"`
+	Assistant *MessageContent `json:"assistant,omitempty"`
+
+	RequestSHA256  string `json:"request_sha256,omitempty"`
+	ResponseSHA256 string `json:"response_sha256,omitempty"`
+}
+
+func indexPath(claudeDir string) string {
+	return filepath.Join(claudeDir, "history_index.jsonl")
+}
+
+func requestPath(claudeDir, ts string) string {
+	return filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", ts))
+}
+
+func responsePath(claudeDir, ts string) string {
+	return filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", ts))
+}
+
+// turnEntry parses the pair of ts into its index entry
+func turnEntry(claudeDir, ts string) (*IndexEntry, error) {
+	req, err := LoadRequest(requestPath(claudeDir, ts))
+	if err != nil {
+		return nil, err
+	}
+	entry := &IndexEntry{Timestamp: ts}
+	// The last message of a request is always the user's
+	if len(req.Messages) > 0 {
+		entry.User = &req.Messages[len(req.Messages)-1]
+	}
+
+	responses, err := LoadResponses(claudeDir, ts)
+	if err != nil {
+		return nil, err
+	}
+	// The last response has the final text
+	if len(responses) > 0 {
+		entry.Assistant = &MessageContent{
+			Role:    "assistant",
+			Content: responses[len(responses)-1].Content,
+		}
+	}
+
+	if entry.RequestSHA256, err = fileSHA256(requestPath(claudeDir, ts)); err != nil {
+		return nil, err
+	}
+	if entry.ResponseSHA256, err = fileSHA256(responsePath(claudeDir, ts)); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// loadIndex reads the index. Lines that can't be read are skipped; their
+// turns are parsed from the files again. A later line for the same turn
+// replaces an earlier one.
+func loadIndex(claudeDir string) map[string]*IndexEntry {
+	entries := make(map[string]*IndexEntry)
+	f, err := os.Open(indexPath(claudeDir))
+	if err != nil {
+		return entries
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line, err := UnsealAuditLine(bytes.TrimSpace(scanner.Bytes()))
+		if err != nil || len(line) == 0 {
+			continue
+		}
+		var entry IndexEntry
+		if json.Unmarshal(line, &entry) == nil && entry.Timestamp != "" {
+			entries[entry.Timestamp] = &entry
+		}
+	}
+	return entries
+}
+
+// appendIndex adds entries to the index
+func appendIndex(claudeDir string, entries []*IndexEntry) error {
+	data, err := encodeIndex(entries)
+	if err != nil {
+		return err
+	}
+	f, err := openFile(indexPath(claudeDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open history index: %w", err)
+	}
+	defer f.Close()
+	// One write so concurrent readers catching up can't interleave lines
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write history index: %w", err)
+	}
+	return f.Close()
+}
+
+// encodeIndex renders entries as (sealed) index lines
+func encodeIndex(entries []*IndexEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("marshal index entry: %w", err)
+		}
+		data, err = sealAuditLine(data)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt index entry: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// indexTurn appends the turn ts to the index
+func indexTurn(claudeDir, ts string) error {
+	entry, err := turnEntry(claudeDir, ts)
+	if err != nil {
+		return err
+	}
+	return appendIndex(claudeDir, []*IndexEntry{entry})
+}
+
+// compactIndex drops the index lines of deleted turns and duplicates
+func compactIndex(claudeDir string) error {
+	pairs, err := ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return err
+	}
+	index := loadIndex(claudeDir)
+	var entries []*IndexEntry
+	for _, ts := range pairs {
+		if entry, ok := index[ts]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return writeIndex(claudeDir, entries)
+}
+
+// writeIndex atomically replaces the index with entries
+func writeIndex(claudeDir string, entries []*IndexEntry) error {
+	data, err := encodeIndex(entries)
+	if err != nil {
+		return err
+	}
+
+	path := indexPath(claudeDir)
+	tmpPath := path + ".tmp"
+	if err := writeFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := rename(tmpPath, path); err != nil {
+		remove(tmpPath)
+		return fmt.Errorf("atomic rename: %w", err)
+	}
+	return nil
+}
+
+// RebuildIndex rewrites the index from the request/response files, for
+// --reindex after corruption. It returns the number of turns indexed.
+func RebuildIndex(claudeDir string) (int, error) {
+	pairs, err := ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return 0, err
+	}
+	var entries []*IndexEntry
+	for _, ts := range pairs {
+		entry, err := turnEntry(claudeDir, ts)
+		if err != nil {
+			return 0, fmt.Errorf("turn %s: %w", ts, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := writeIndex(claudeDir, entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryIndex(t *testing.T) {
+	claudeDir := t.TempDir()
+	first := saveTurnAt(t, claudeDir, 2*time.Hour, 10)
+	saveTurnAt(t, claudeDir, time.Hour, 10)
+
+	if index := loadIndex(claudeDir); len(index) != 2 {
+		t.Fatalf("index has %d turns after two saves, want 2", len(index))
+	}
+
+	// The index answers for turns whose files it already has: an edit the
+	// index doesn't know about isn't seen
+	if err := SaveRequest(claudeDir, first, []MessageContent{{
+		Role: "user", Content: []ContentBlock{{Type: "text", Text: "edited"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := LoadConversationHistory(claudeDir)
+	if err != nil || len(messages) != 4 {
+		t.Fatalf("LoadConversationHistory = %d messages, %v", len(messages), err)
+	}
+	if messages[0].Content[0].Text != "q "+first {
+		t.Errorf("history not loaded from the index: %q", messages[0].Content[0].Text)
+	}
+
+	// Rebuilding picks up the files as they are
+	if n, err := RebuildIndex(claudeDir); err != nil || n != 2 {
+		t.Fatalf("RebuildIndex = %d, %v", n, err)
+	}
+	messages, _ = LoadConversationHistory(claudeDir)
+	if messages[0].Content[0].Text != "edited" {
+		t.Errorf("rebuilt index has %q", messages[0].Content[0].Text)
+	}
+}
+
+func TestHistoryIndexCatchUp(t *testing.T) {
+	claudeDir := t.TempDir()
+	saveTurnAt(t, claudeDir, 3*time.Hour, 10)
+	pruned := saveTurnAt(t, claudeDir, 2*time.Hour, 10)
+	saveTurnAt(t, claudeDir, time.Hour, 10)
+
+	// A corrupt line and a missing turn are parsed from the files again
+	data, _ := os.ReadFile(indexPath(claudeDir))
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(indexPath(claudeDir), []byte(lines[0]+"{garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(responsePath(claudeDir, pruned))
+
+	messages, err := LoadConversationHistory(claudeDir)
+	if err != nil || len(messages) != 4 {
+		t.Fatalf("LoadConversationHistory = %d messages, %v; want 4", len(messages), err)
+	}
+	if index := loadIndex(claudeDir); len(index) != 2 {
+		t.Errorf("index not caught up: %d turns", len(index))
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// jobs.go - Background jobs (.claude/jobs/<id>/)
+//
+// A job is a turn run by a detached claude process (claude job submit).
+// job.json holds its state and is rewritten as it changes. events.jsonl
+// gets a line for every model call and every batch of tool results as they
+// happen, so the progress of a job survives the terminal that submitted
+// it and can be shown again from the start. output.log is the stderr of
+// the process, which runs silent: it only holds diagnostics, like a crash.
+// The answer is the last event, sealed and redacted like the rest.
+
+// Job states
+const (
+	JobQueued   = "queued"   // waiting for the session lock
+	JobRunning  = "running"  // the turn is running
+	JobDone     = "done"     // the turn is saved
+	JobFailed   = "failed"   // the turn failed
+	JobCanceled = "canceled" // claude job cancel
+	JobLost     = "lost"     // the process died before finishing (never saved)
+)
+
+// Job is the contents of job.json
+type Job struct {
+	ID         string     `json:"id"`
+	Prompt     string     `json:"prompt"`
+	Args       []string   `json:"args,omitempty"` // flags of the turn
+	Status     string     `json:"status"`
+	PID        int        `json:"pid,omitempty"`
+	Submitted  time.Time  `json:"submitted"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Turn       string     `json:"turn,omitempty"` // timestamp of the saved turn
+	Iterations int        `json:"iterations,omitempty"`
+	Cost       float64    `json:"cost,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Active reports whether the job is still to finish
+func (j *Job) Active() bool {
+	return j.Status == JobQueued || j.Status == JobRunning
+}
+
+// State returns the status of the job, JobLost for an active job whose
+// process is gone
+func (j *Job) State() string {
+	if j.Active() && j.PID > 0 && !processAlive(j.PID) {
+		return JobLost
+	}
+	return j.Status
+}
+
+// jobIDPattern matches job IDs: a timestamp, numbered when several jobs are
+// submitted in the same second
+var jobIDPattern = regexp.MustCompile(`^\d{8}_\d{6}(_\d+)?$`)
+
+// ValidateJobID rejects IDs that aren't job IDs, and so paths
+func ValidateJobID(id string) error {
+	if !jobIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid job ID %q (see claude job list)", id)
+	}
+	return nil
+}
+
+// JobDir returns the directory of job id
+func JobDir(claudeDir, id string) string {
+	return filepath.Join(claudeDir, "jobs", id)
+}
+
+// CreateJob gives j a new ID and saves it
+func CreateJob(claudeDir string, j *Job) error {
+	if err := mkdirAll(filepath.Join(claudeDir, "jobs"), 0o755); err != nil {
+		return fmt.Errorf("create jobs directory: %w", err)
+	}
+	ts := CurrentTimestamp()
+	j.ID = ts
+	for n := 2; ; n++ {
+		err := os.Mkdir(JobDir(claudeDir, j.ID), 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("create job directory: %w", err)
+		}
+		j.ID = fmt.Sprintf("%s_%d", ts, n)
+	}
+	return SaveJob(claudeDir, j)
+}
+
+// SaveJob atomically replaces the job.json of j
+func SaveJob(claudeDir string, j *Job) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+	return writeSealedFile(filepath.Join(JobDir(claudeDir, j.ID), "job.json"),
+		fileRedactor.Bytes(data))
+}
+
+// LoadJob reads the job.json of job id
+func LoadJob(claudeDir, id string) (*Job, error) {
+	if err := ValidateJobID(id); err != nil {
+		return nil, err
+	}
+	data, err := readSealedFile(filepath.Join(JobDir(claudeDir, id), "job.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no job %s (see claude job list)", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read job %s: %w", id, err)
+	}
+	var j Job
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("unmarshal job %s: %w", id, err)
+	}
+	return &j, nil
+}
+
+// ListJobs returns the jobs of claudeDir, oldest first. Jobs that can't be
+// read are skipped.
+func ListJobs(claudeDir string) ([]*Job, error) {
+	entries, err := os.ReadDir(filepath.Join(claudeDir, "jobs"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read jobs directory: %w", err)
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		if !e.IsDir() || ValidateJobID(e.Name()) != nil {
+			continue
+		}
+		if j, err := LoadJob(claudeDir, e.Name()); err == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Submitted.Before(jobs[b].Submitted) })
+	return jobs, nil
+}
+
+// CreateJobLog opens the output.log of job id for appending
+func CreateJobLog(claudeDir, id string) (*os.File, error) {
+	return openFile(filepath.Join(JobDir(claudeDir, id), "output.log"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+}
+
+// AppendJobEvent appends a JSON event to the events.jsonl of job id
+func AppendJobEvent(claudeDir, id string, event []byte) error {
+	f, err := openFile(filepath.Join(JobDir(claudeDir, id), "events.jsonl"),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open job events: %w", err)
+	}
+	defer f.Close()
+	data, err := sealAuditLine(fileRedactor.Bytes(event))
+	if err != nil {
+		return fmt.Errorf("encrypt job event: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write job events: %w", err)
+	}
+	return f.Sync()
+}
+
+// ReadJobEvents returns the events of job id from byte offset on and the
+// offset after the last complete line, to read on from when more arrive
+func ReadJobEvents(claudeDir, id string, offset int64) ([][]byte, int64, error) {
+	f, err := os.Open(filepath.Join(JobDir(claudeDir, id), "events.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, offset, nil
+	}
+	if err != nil {
+		return nil, offset, fmt.Errorf("open job events: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("read job events: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, offset, fmt.Errorf("read job events: %w", err)
+	}
+
+	var events [][]byte
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break // a line being written
+		}
+		line, err := UnsealAuditLine(bytes.TrimSpace(data[:i]))
+		data = data[i+1:]
+		offset += int64(i + 1)
+		if err == nil && len(line) > 0 {
+			events = append(events, line)
+		}
+	}
+	return events, offset, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	claudeDir := t.TempDir()
+	if jobs, err := ListJobs(claudeDir); err != nil || len(jobs) != 0 {
+		t.Fatalf("ListJobs on empty dir = %v, %v", jobs, err)
+	}
+
+	// Jobs submitted in the same second get numbered IDs
+	var ids []string
+	for i := range 2 {
+		j := &Job{Prompt: "p", Status: JobQueued, Submitted: time.Now().Add(time.Duration(i))}
+		if err := CreateJob(claudeDir, j); err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateJobID(j.ID); err != nil {
+			t.Error(err)
+		}
+		ids = append(ids, j.ID)
+	}
+	if ids[0] == ids[1] {
+		t.Fatalf("jobs share ID %s", ids[0])
+	}
+	jobs, err := ListJobs(claudeDir)
+	if err != nil || len(jobs) != 2 || jobs[0].ID != ids[0] {
+		t.Fatalf("ListJobs = %v, %v", jobs, err)
+	}
+
+	// A job whose process is gone is lost
+	jobs[0].PID = 1 << 30
+	if jobs[0].State() != JobLost {
+		t.Errorf("state = %s, want %s", jobs[0].State(), JobLost)
+	}
+	jobs[0].Status = JobDone
+	if jobs[0].State() != JobDone || jobs[0].Active() {
+		t.Errorf("finished job: state %s, active %v", jobs[0].State(), jobs[0].Active())
+	}
+
+	if _, err := LoadJob(claudeDir, "../config"); err == nil {
+		t.Error("LoadJob accepted a path")
+	}
+}
+
+func TestJobEvents(t *testing.T) {
+	claudeDir := t.TempDir()
+	j := &Job{Status: JobRunning}
+	if err := CreateJob(claudeDir, j); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []string{`{"type":"call"}`, `{"type":"tools"}`} {
+		if err := AppendJobEvent(claudeDir, j.ID, []byte(ev)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, offset, err := ReadJobEvents(claudeDir, j.ID, 0)
+	if err != nil || len(events) != 2 || string(events[1]) != `{"type":"tools"}` {
+		t.Fatalf("events = %q, %v", events, err)
+	}
+
+	// A line being written is read once it is complete
+	path := filepath.Join(JobDir(claudeDir, j.ID), "events.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"do`)
+	if events, next, _ := ReadJobEvents(claudeDir, j.ID, offset); len(events) != 0 || next != offset {
+		t.Errorf("partial line read: %q, offset %d -> %d", events, offset, next)
+	}
+	f.WriteString("ne\"}\n")
+	f.Close()
+	if events, _, _ := ReadJobEvents(claudeDir, j.ID, offset); len(events) != 1 || string(events[0]) != `{"type":"done"}` {
+		t.Errorf("events after the line = %q", events)
+	}
+}
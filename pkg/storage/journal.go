@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// journal.go - In-progress turn journal (.claude/journal_<timestamp>.json)
+//
+// A turn's responses are only saved once it completes. The journal is
+// rewritten after every iteration so a crash leaves a record of what the
+// turn already did; it is removed when the turn finishes normally.
+
+// Journal records an in-progress turn
+type Journal struct {
+	ConversationID string            `json:"conversation_id"`
+	Model          string            `json:"model"`
+	Started        time.Time         `json:"started"`
+	Updated        time.Time         `json:"updated"`
+	Iterations     int               `json:"iterations"`
+	Responses      []json.RawMessage `json:"responses"`
+	Tools          []JournalTool     `json:"tools,omitempty"`
+}
+
+// JournalTool is one executed tool call. Inputs aren't kept (write_file
+// content can be large); the path is enough to find what changed.
+type JournalTool struct {
+	Iteration int    `json:"iteration"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"`
+	Failed    bool   `json:"failed,omitempty"`
+}
+
+func journalPath(claudeDir, conversationID string) string {
+	return filepath.Join(claudeDir, fmt.Sprintf("journal_%s.json", conversationID))
+}
+
+// SaveJournal atomically replaces the journal of j.ConversationID
+func SaveJournal(claudeDir string, j *Journal) error {
+	j.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	return writeSealedFile(journalPath(claudeDir, j.ConversationID),
+		fileRedactor.Bytes(data))
+}
+
+// LoadJournal reads the journal of conversationID
+func LoadJournal(claudeDir, conversationID string) (*Journal, error) {
+	data, err := readSealedFile(journalPath(claudeDir, conversationID))
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("unmarshal journal: %w", err)
+	}
+	return &j, nil
+}
+
+// RemoveJournal deletes the journal of conversationID if there is one
+func RemoveJournal(claudeDir, conversationID string) error {
+	err := remove(journalPath(claudeDir, conversationID))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
+
+// ListJournals returns the conversation IDs of interrupted turns, oldest
+// first
+func ListJournals(claudeDir string) ([]string, error) {
+	entries, err := os.ReadDir(claudeDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "journal_") && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(name, "journal_"), ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
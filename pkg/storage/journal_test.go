@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	claudeDir := t.TempDir()
+
+	if ids, err := ListJournals(claudeDir); err != nil || len(ids) != 0 {
+		t.Fatalf("ListJournals on empty dir = %v, %v", ids, err)
+	}
+
+	for _, id := range []string{"20260102_000000", "20260101_000000"} {
+		j := &Journal{
+			ConversationID: id,
+			Model:          "m",
+			Iterations:     1,
+			Responses:      []json.RawMessage{json.RawMessage(`{"stop_reason":"tool_use"}`)},
+			Tools:          []JournalTool{{Iteration: 1, ID: "t1", Name: "write_file", Path: "a.go"}},
+		}
+		if err := SaveJournal(claudeDir, j); err != nil {
+			t.Fatalf("SaveJournal: %v", err)
+		}
+	}
+
+	ids, err := ListJournals(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"20260101_000000", "20260102_000000"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ListJournals = %v, want %v", ids, want)
+	}
+
+	j, err := LoadJournal(claudeDir, ids[0])
+	if err != nil {
+		t.Fatalf("LoadJournal: %v", err)
+	}
+	if j.Updated.IsZero() || len(j.Responses) != 1 || j.Tools[0].Path != "a.go" {
+		t.Errorf("LoadJournal = %+v", j)
+	}
+
+	if err := RemoveJournal(claudeDir, ids[0]); err != nil {
+		t.Fatalf("RemoveJournal: %v", err)
+	}
+	if err := RemoveJournal(claudeDir, ids[0]); err != nil {
+		t.Errorf("removing a missing journal: %v", err)
+	}
+	if ids, _ := ListJournals(claudeDir); len(ids) != 1 {
+		t.Errorf("after remove: %v", ids)
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// lock.go - Advisory session lock (.claude/lock)
+//
+// Two invocations in the same directory would interleave request/response
+// files and race on config.json. The first one creates .claude/lock
+// exclusively; others fail (or wait with --wait) until it is released. A
+// lock whose process is gone is stale and taken over.
+
+// Lock file polling and stale detection
+const (
+	LockPollInterval = 200 * time.Millisecond
+	// StaleLockAge is when a lock held by another host, which can't be
+	// probed, is considered abandoned
+	StaleLockAge = 24 * time.Hour
+)
+
+// ErrLocked is returned when another session holds the lock
+var ErrLocked = errors.New("claude directory is locked")
+
+// LockInfo is the contents of .claude/lock
+type LockInfo struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Started  time.Time `json:"started"`
+}
+
+// Lock is a held session lock
+type Lock struct {
+	path string
+	info LockInfo
+}
+
+// AcquireLock takes the session lock of claudeDir, waiting up to wait for
+// another session to release it. The error wraps ErrLocked when the lock
+// is still held.
+func AcquireLock(claudeDir string, wait time.Duration) (*Lock, error) {
+	if err := mkdirAll(claudeDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create claude dir: %w", err)
+	}
+	path := filepath.Join(claudeDir, "lock")
+	hostname, _ := os.Hostname()
+	l := &Lock{
+		path: path,
+		info: LockInfo{PID: os.Getpid(), Hostname: hostname, Started: time.Now().UTC()},
+	}
+	data, err := json.Marshal(l.info)
+	if err != nil {
+		return nil, fmt.Errorf("marshal lock: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err := createExclusive(path, data)
+		if err == nil {
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("create lock: %w", err)
+		}
+
+		holder, seen, err := readLock(path)
+		if os.IsNotExist(err) {
+			continue // released in the meantime
+		}
+		if err == nil && isStale(holder, hostname) {
+			// The lock is still only owned by the O_EXCL create, which is
+			// retried once the stale lock is gone
+			if err := removeStale(path, seen); err != nil {
+				return nil, fmt.Errorf("remove stale lock: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			// Unreadable or half written lock: treat it as held
+			holder = &LockInfo{}
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w by pid %d on %s since %s",
+				ErrLocked, holder.PID, holder.Hostname,
+				holder.Started.Format(time.RFC3339))
+		}
+		time.Sleep(LockPollInterval)
+	}
+}
+
+// ReadLock returns the current holder of the claudeDir lock
+func ReadLock(claudeDir string) (*LockInfo, error) {
+	info, _, err := readLock(filepath.Join(claudeDir, "lock"))
+	return info, err
+}
+
+// readLock returns the holder of the lock at path and the lock file as read
+func readLock(path string) (*LockInfo, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, data, fmt.Errorf("parse lock: %w", err)
+	}
+	return &info, data, nil
+}
+
+// removeStale removes the lock at path if it is still seen, the stale lock
+// read before. Two sessions taking a stale lock over at once would
+// otherwise both remove it, the second one the lock the first just
+// created. So the lock is first renamed away, which only one of them can
+// do, and checked there; a lock that isn't the stale one is put back.
+func removeStale(path string, seen []byte) error {
+	moved := fmt.Sprintf("%s.stale.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return nil // taken over by another session
+		}
+		return err
+	}
+	if data, err := os.ReadFile(moved); err == nil && !bytes.Equal(data, seen) {
+		// Linking fails when yet another session created a lock meanwhile
+		if err := link(moved, path); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return remove(moved)
+}
+
+// Release removes the lock if it is still ours. A lock already removed
+// (e.g. by --reset) is not an error.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	holder, err := ReadLock(filepath.Dir(l.path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil && (holder.PID != l.info.PID || holder.Hostname != l.info.Hostname) {
+		// Taken over as stale; the new owner releases it
+		return nil
+	}
+	if err := remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("release lock: %w", err)
+	}
+	return nil
+}
+
+// createExclusive writes data to a file that must not exist yet
+func createExclusive(path string, data []byte) error {
+	f, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// isStale reports whether the lock holder has gone away
+func isStale(holder *LockInfo, hostname string) bool {
+	if holder.Hostname != hostname {
+		return time.Since(holder.Started) > StaleLockAge
+	}
+	return !processAlive(holder.PID)
+}
+
+// processAlive probes pid with signal 0. Windows can't be probed this way,
+// so there a same-host lock is only released by its owner.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
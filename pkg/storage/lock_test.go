@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeLock(t *testing.T, claudeDir string, info LockInfo) {
+	t.Helper()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(claudeDir, "lock"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+
+	l, err := AcquireLock(claudeDir, 0)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	holder, err := ReadLock(claudeDir)
+	if err != nil {
+		t.Fatalf("ReadLock: %v", err)
+	}
+	if holder.PID != os.Getpid() {
+		t.Errorf("lock pid = %d, want %d", holder.PID, os.Getpid())
+	}
+
+	// A second session in this (live) process is refused
+	if _, err := AcquireLock(claudeDir, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second AcquireLock = %v, want ErrLocked", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(claudeDir, "lock")); !os.IsNotExist(err) {
+		t.Errorf("lock still present after Release: %v", err)
+	}
+	// Releasing twice (or after --reset) is fine
+	if err := l.Release(); err != nil {
+		t.Errorf("second Release: %v", err)
+	}
+}
+
+func TestAcquireLockStale(t *testing.T) {
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name  string
+		info  LockInfo
+		stale bool
+	}{
+		{"dead process", LockInfo{PID: 1 << 30, Hostname: hostname, Started: time.Now()}, true},
+		{"live process", LockInfo{PID: os.Getpid(), Hostname: hostname, Started: time.Now()}, false},
+		{"other host, recent", LockInfo{PID: 1, Hostname: "elsewhere", Started: time.Now()}, false},
+		{"other host, old", LockInfo{PID: 1, Hostname: "elsewhere", Started: time.Now().Add(-2 * StaleLockAge)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claudeDir := t.TempDir()
+			writeLock(t, claudeDir, tt.info)
+
+			l, err := AcquireLock(claudeDir, 0)
+			if tt.stale {
+				if err != nil {
+					t.Fatalf("stale lock not taken over: %v", err)
+				}
+				l.Release()
+				return
+			}
+			if !errors.Is(err, ErrLocked) {
+				t.Fatalf("AcquireLock = %v, want ErrLocked", err)
+			}
+		})
+	}
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+	claudeDir := t.TempDir()
+	path := filepath.Join(claudeDir, "lock")
+	stale := LockInfo{PID: 1 << 30, Hostname: "here", Started: time.Now()}
+	writeLock(t, claudeDir, stale)
+	seen := mustReadFile(t, path)
+
+	// Another session took the stale lock over after it was read
+	fresh := LockInfo{PID: os.Getpid(), Hostname: "here", Started: time.Now()}
+	writeLock(t, claudeDir, fresh)
+	if err := removeStale(path, seen); err != nil {
+		t.Fatalf("removeStale: %v", err)
+	}
+	if holder, err := ReadLock(claudeDir); err != nil || holder.PID != fresh.PID {
+		t.Errorf("removeStale removed the lock of the new owner: %v %+v", err, holder)
+	}
+
+	if err := removeStale(path, mustReadFile(t, path)); err != nil {
+		t.Fatalf("removeStale: %v", err)
+	}
+	entries, err := os.ReadDir(claudeDir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("stale lock left %v (%v)", entries, err)
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAcquireLockWait(t *testing.T) {
+	claudeDir := t.TempDir()
+	l, err := AcquireLock(claudeDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan error, 1)
+	go func() {
+		time.Sleep(2 * LockPollInterval)
+		released <- l.Release()
+	}()
+
+	l2, err := AcquireLock(claudeDir, 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting AcquireLock: %v", err)
+	}
+	if err := <-released; err != nil {
+		t.Errorf("Release: %v", err)
+	}
+	l2.Release()
+}
+
+func TestReleaseTakenOverLock(t *testing.T) {
+	claudeDir := t.TempDir()
+	l, err := AcquireLock(claudeDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another session took the lock over as stale
+	other := LockInfo{PID: os.Getpid() + 1, Hostname: "elsewhere", Started: time.Now()}
+	writeLock(t, claudeDir, other)
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	holder, err := ReadLock(claudeDir)
+	if err != nil || holder.Hostname != "elsewhere" {
+		t.Errorf("Release removed another session's lock: %v %+v", err, holder)
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// meta.go - Per-turn metadata (.claude/meta_<ts>.json)
+//
+// The request and response files hold what was sent and received; the
+// sidecar records how: the model and provider that answered, what the turn
+// cost and how long it took. It is written just before the response, so a
+// complete turn has one unless it was saved before metadata existed.
+
+// TurnMeta describes how a turn was run
+type TurnMeta struct {
+	Model        string    `json:"model"`
+	Provider     string    `json:"provider"`
+	Fallback     bool      `json:"fallback,omitempty"` // answered by the fallback model
+	Tool         string    `json:"tool,omitempty"`     // --tool mode
+	WorkingDir   string    `json:"working_dir,omitempty"`
+	Iterations   int       `json:"iterations"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost"`
+	Started      time.Time `json:"started"`
+	DurationMs   int64     `json:"duration_ms"`
+	Context      []string  `json:"context,omitempty"` // --rag chunks, path:lines
+	Title        string    `json:"title,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`     // --tag labels
+	User         string    `json:"user,omitempty"`     // who ran the turn
+	APIKeys      []string  `json:"api_keys,omitempty"` // IDs (last four characters) of the keys used
+	Prefill      string    `json:"prefill,omitempty"`  // --prefill the answer started with
+
+	// WriteHashes are the hex SHA-256 of the files the write_file calls
+	// changed, as they were before the call, by tool use ID; "" for a file
+	// that didn't exist
+	WriteHashes map[string]string `json:"write_hashes,omitempty"`
+
+	SystemPromptSHA256 string `json:"system_prompt_sha256,omitempty"`
+	SystemSource       string `json:"system_source,omitempty"` // --system, env, config or default
+
+	BestOf *BestOf `json:"best_of,omitempty"` // --best-of candidates
+}
+
+// BestOf records the sampled first responses of a --best-of turn and the
+// one the judge chose
+type BestOf struct {
+	Judge      string      `json:"judge"`  // consensus or the judge model
+	Chosen     int         `json:"chosen"` // index into Candidates
+	Reason     string      `json:"reason,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is one sampled response of a --best-of turn
+type Candidate struct {
+	Content      []ContentBlock `json:"content,omitempty"`
+	StopReason   string         `json:"stop_reason,omitempty"`
+	InputTokens  int            `json:"input_tokens"`
+	OutputTokens int            `json:"output_tokens"`
+	Cost         float64        `json:"cost"`
+	Score        float64        `json:"score,omitempty"` // consensus: mean similarity to the others
+	Error        string         `json:"error,omitempty"`
+}
+
+// Duration returns how long the turn took
+func (m *TurnMeta) Duration() time.Duration {
+	return time.Duration(m.DurationMs) * time.Millisecond
+}
+
+func metaPath(claudeDir, ts string) string {
+	return filepath.Join(claudeDir, fmt.Sprintf("meta_%s.json", ts))
+}
+
+// SaveTurnMeta saves the metadata of turn ts
+func SaveTurnMeta(claudeDir, ts string, meta *TurnMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal JSON: %w", err)
+	}
+	return writeSealedFile(metaPath(claudeDir, ts), data)
+}
+
+// LoadTurnMeta loads the metadata of turn ts. Turns without any return nil
+// and no error.
+func LoadTurnMeta(claudeDir, ts string) (*TurnMeta, error) {
+	data, err := readSealedFile(metaPath(claudeDir, ts))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	var meta TurnMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("unmarshal metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// removeTurnMeta deletes the metadata of turn ts, if any
+func removeTurnMeta(claudeDir, ts string) error {
+	if err := remove(metaPath(claudeDir, ts)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove metadata of %s: %w", ts, err)
+	}
+	return nil
+}
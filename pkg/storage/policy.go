@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/marcopeereboom/go-claude/pkg/redact"
+)
+
+// policy.go - Project policy (.claude/policy.json)
+//
+// Policy holds rules a project wants enforced regardless of flags. It lives
+// next to config.json but is meant to be edited by hand and committed,
+// whereas config.json is rewritten on every run.
+
+// Policy is the contents of .claude/policy.json
+type Policy struct {
+	Redaction RedactionPolicy `json:"redaction"`
+	Workspace WorkspacePolicy `json:"workspace"`
+	Limits    CommandLimits   `json:"limits"`
+	// --format-writes formatters by extension (".ts"), reading stdin and
+	// writing stdout; "" turns a default off
+	Format map[string]string `json:"format,omitempty"`
+	Agents AgentPolicy       `json:"agents"`
+}
+
+// RedactionPolicy controls secret redaction. Built-in rules are on unless
+// Disabled is set; Patterns adds project specific regexes.
+type RedactionPolicy struct {
+	Disabled bool     `json:"disabled,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// WorkspacePolicy lists directories outside the project the tools may use,
+// e.g. a sibling repository with a shared library
+type WorkspacePolicy struct {
+	Roots []WorkspaceRoot `json:"roots,omitempty"`
+}
+
+// WorkspaceRoot is an extra directory for the tools. It is read-only
+// unless Write is set.
+type WorkspaceRoot struct {
+	Path  string `json:"path"` // absolute, or relative to the project directory
+	Write bool   `json:"write,omitempty"`
+}
+
+// CommandLimits constrain bash_command children. Zero leaves a limit
+// unset, except MaxOutputBytes, which then defaults to 1 MiB.
+type CommandLimits struct {
+	CPUSeconds     int `json:"cpu_seconds,omitempty"`      // ulimit -t
+	MemoryMB       int `json:"memory_mb,omitempty"`        // ulimit -v, address space
+	MaxOutputBytes int `json:"max_output_bytes,omitempty"` // per stream, more is cut
+	Nice           int `json:"nice,omitempty"`             // 1-19, lower priority
+}
+
+// AgentPolicy allows the spawn_agent tool and bounds every sub-agent it
+// starts. Zero caps take the defaults.
+type AgentPolicy struct {
+	Enabled       bool    `json:"enabled,omitempty"`
+	Tool          string  `json:"tool,omitempty"`           // the most a sub-agent may do, default read
+	Model         string  `json:"model,omitempty"`          // default the model of the turn
+	MaxCost       float64 `json:"max_cost,omitempty"`       // dollars per sub-agent
+	MaxIterations int     `json:"max_iterations,omitempty"` // per sub-agent
+}
+
+// LoadPolicy reads .claude/policy.json. A missing file yields the default
+// policy.
+func LoadPolicy(claudeDir string) (*Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(filepath.Join(claudeDir, "policy.json"))
+	if os.IsNotExist(err) {
+		return &p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse policy.json: %w", err)
+	}
+	return &p, nil
+}
+
+// RequirePolicy is LoadPolicy for runs that must be governed by a policy
+// (--ci): a missing policy.json is an error.
+func RequirePolicy(claudeDir string) (*Policy, error) {
+	path := filepath.Join(claudeDir, "policy.json")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("policy required: %w", err)
+	}
+	return LoadPolicy(claudeDir)
+}
+
+// fileRedactor scrubs request, response and audit log data before it is
+// written; nil disables redaction
+var fileRedactor *redact.Redactor
+
+// SetRedactor sets the redactor applied to saved conversation files
+func SetRedactor(r *redact.Redactor) {
+	fileRedactor = r
+}
+
+// Redactor returns the active redactor (nil when redaction is off)
+func Redactor() *redact.Redactor {
+	return fileRedactor
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// prompts.go - Prompt library (claude prompt)
+//
+// Saved prompts are plain text files in .claude/prompts/ of the project,
+// or in claude/prompts/ of the user config directory for every project
+// ($XDG_CONFIG_HOME, ~/.config on Linux). A prompt is named after its
+// file without the extension, so an existing folder of prompt files can
+// be used as is; a project prompt hides a global one of the same name.
+// Prompts aren't conversation files: they aren't encrypted or redacted.
+
+// promptExt is the extension of saved prompts
+const promptExt = ".md"
+
+// promptExts are the extensions of prompt files, in the order looked up
+var promptExts = []string{promptExt, ".txt", ""}
+
+var promptNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SavedPrompt is a prompt of the library
+type SavedPrompt struct {
+	Name   string
+	Path   string
+	Text   string
+	Global bool // from the user config directory
+}
+
+// PromptsDir returns the prompts directory of the project
+func PromptsDir(claudeDir string) string {
+	return filepath.Join(claudeDir, "prompts")
+}
+
+// GlobalPromptsDir returns the prompts directory shared by every project,
+// "" when there is no user config directory
+func GlobalPromptsDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "claude", "prompts")
+}
+
+// ValidatePromptName checks that name can be a prompt file name
+func ValidatePromptName(name string) error {
+	if !promptNameRe.MatchString(name) {
+		return fmt.Errorf("invalid prompt name %q (letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// SavePrompt saves text as prompt name of the project, replacing the one
+// there is. It returns the path written.
+func SavePrompt(claudeDir, name, text string) (string, error) {
+	if err := ValidatePromptName(name); err != nil {
+		return "", err
+	}
+	dir := PromptsDir(claudeDir)
+	if err := mkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create prompts directory: %w", err)
+	}
+	// Replace name.txt or name rather than hiding it behind name.md
+	path := filepath.Join(dir, name+promptExt)
+	if p, err := findPrompt(dir, name); err == nil && p != "" {
+		path = p
+	}
+	if err := writeFile(path, []byte(text), 0o644); err != nil {
+		return "", fmt.Errorf("save prompt %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// LoadPrompt returns prompt name, from the project or else the global
+// directory
+func LoadPrompt(claudeDir, name string) (*SavedPrompt, error) {
+	if err := ValidatePromptName(name); err != nil {
+		return nil, err
+	}
+	for _, global := range []bool{false, true} {
+		dir := PromptsDir(claudeDir)
+		if global {
+			if dir = GlobalPromptsDir(); dir == "" {
+				continue
+			}
+		}
+		path, err := findPrompt(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read prompt %s: %w", name, err)
+		}
+		return &SavedPrompt{Name: name, Path: path, Text: string(data), Global: global}, nil
+	}
+	return nil, fmt.Errorf("no prompt %s (see claude prompt list)", name)
+}
+
+// findPrompt returns the file of prompt name in dir, "" when there is none
+func findPrompt(dir, name string) (string, error) {
+	for _, ext := range promptExts {
+		path := filepath.Join(dir, name+ext)
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("prompt %s: %w", name, err)
+		}
+		if fi.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// ListPrompts returns the prompts of the project and the global directory
+// by name
+func ListPrompts(claudeDir string) ([]SavedPrompt, error) {
+	byName := make(map[string]SavedPrompt)
+	for _, global := range []bool{true, false} {
+		dir := PromptsDir(claudeDir)
+		if global {
+			if dir = GlobalPromptsDir(); dir == "" {
+				continue
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list prompts: %w", err)
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+			if ValidatePromptName(name) != nil {
+				continue
+			}
+			if p, ok := byName[name]; ok && p.Global == global {
+				continue // name.md and name.txt: the first looked up wins
+			}
+			path, err := findPrompt(dir, name)
+			if err != nil || path == "" {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read prompt %s: %w", name, err)
+			}
+			// Project prompts are read last and hide global ones
+			byName[name] = SavedPrompt{Name: name, Path: path, Text: string(data), Global: global}
+		}
+	}
+
+	prompts := make([]SavedPrompt, 0, len(byName))
+	for _, p := range byName {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts, nil
+}
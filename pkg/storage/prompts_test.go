@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrompts(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	global := filepath.Join(config, "claude", "prompts")
+	os.MkdirAll(global, 0o755)
+	os.WriteFile(filepath.Join(global, "shared.txt"), []byte("global shared"), 0o644)
+	os.WriteFile(filepath.Join(global, "review.md"), []byte("global review"), 0o644)
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+
+	if _, err := SavePrompt(claudeDir, "review", "project review"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SavePrompt(claudeDir, "../escape", "x"); err == nil {
+		t.Error("saved a prompt named ../escape")
+	}
+	// A plain file without extension is a prompt too, and saving keeps its name
+	os.WriteFile(filepath.Join(PromptsDir(claudeDir), "notes"), []byte("old notes"), 0o644)
+	path, err := SavePrompt(claudeDir, "notes", "new notes")
+	if err != nil || path != filepath.Join(PromptsDir(claudeDir), "notes") {
+		t.Errorf("SavePrompt(notes) = %s, %v", path, err)
+	}
+
+	p, err := LoadPrompt(claudeDir, "review")
+	if err != nil || p.Text != "project review" || p.Global {
+		t.Errorf("LoadPrompt(review) = %+v, %v", p, err)
+	}
+	p, err = LoadPrompt(claudeDir, "shared")
+	if err != nil || p.Text != "global shared" || !p.Global {
+		t.Errorf("LoadPrompt(shared) = %+v, %v", p, err)
+	}
+	if _, err := LoadPrompt(claudeDir, "missing"); err == nil {
+		t.Error("loaded a missing prompt")
+	}
+
+	prompts, err := ListPrompts(claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name, text string
+		global     bool
+	}{
+		{"notes", "new notes", false},
+		{"review", "project review", false},
+		{"shared", "global shared", true},
+	}
+	if len(prompts) != len(want) {
+		t.Fatalf("ListPrompts = %+v", prompts)
+	}
+	for i, w := range want {
+		if p := prompts[i]; p.Name != w.name || p.Text != w.text || p.Global != w.global {
+			t.Errorf("prompt %d = %+v, want %+v", i, p, w)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+// readonly.go - Read-only (--read-only) and ephemeral (--no-save) modes
+//
+// Every write of the package goes through the wrappers below, so with
+// read-only mode on nothing under .claude (or anywhere else) is created,
+// changed or removed: the write fails with ErrReadOnly instead. Callers
+// skip the writes they can do without; the guard catches the ones they
+// miss.
+//
+// Ephemeral mode refuses nothing. It tells callers to keep the
+// conversation in memory: no turn files, audit entries or config updates.
+
+// ErrReadOnly is returned by writes in read-only mode
+var ErrReadOnly = errors.New("read-only mode")
+
+// readOnly refuses every write when set; writes of any goroutine check it
+var readOnly atomic.Bool
+
+// SetReadOnly turns read-only mode on or off
+func SetReadOnly(on bool) {
+	readOnly.Store(on)
+}
+
+// ReadOnly reports whether read-only mode is on
+func ReadOnly() bool {
+	return readOnly.Load()
+}
+
+// ephemeral keeps conversations in memory when set
+var ephemeral bool
+
+// SetEphemeral turns ephemeral mode on or off
+func SetEphemeral(on bool) {
+	ephemeral = on
+}
+
+// Ephemeral reports whether turns are kept in memory only: in ephemeral or
+// read-only mode
+func Ephemeral() bool {
+	return ephemeral || readOnly.Load()
+}
+
+// checkWritable returns ErrReadOnly for a write to path in read-only mode
+func checkWritable(op, path string) error {
+	if readOnly.Load() {
+		return &os.PathError{Op: op, Path: path, Err: ErrReadOnly}
+	}
+	return nil
+}
+
+// WriteFile is os.WriteFile refused in read-only mode, for the writes of
+// other packages outside .claude
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	return writeFile(name, data, perm)
+}
+
+// MkdirAll is os.MkdirAll refused in read-only mode
+func MkdirAll(path string, perm os.FileMode) error {
+	return mkdirAll(path, perm)
+}
+
+// Create is os.Create refused in read-only mode
+func Create(name string) (*os.File, error) {
+	return createFile(name)
+}
+
+// Rename is os.Rename refused in read-only mode
+func Rename(oldpath, newpath string) error {
+	return rename(oldpath, newpath)
+}
+
+// Remove is os.Remove refused in read-only mode
+func Remove(name string) error {
+	return remove(name)
+}
+
+// RemoveAll is os.RemoveAll refused in read-only mode
+func RemoveAll(path string) error {
+	return removeAll(path)
+}
+
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	if err := checkWritable("write", name); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+func createFile(name string) (*os.File, error) {
+	if err := checkWritable("create", name); err != nil {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+// openFile opens name for writing
+func openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if err := checkWritable("open", name); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, flag, perm)
+}
+
+func mkdirAll(path string, perm os.FileMode) error {
+	if err := checkWritable("mkdir", path); err != nil {
+		return err
+	}
+	return os.MkdirAll(path, perm)
+}
+
+func mkdirTemp(dir, pattern string) (string, error) {
+	if err := checkWritable("mkdirtemp", dir); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, pattern)
+}
+
+func chmod(name string, mode os.FileMode) error {
+	if err := checkWritable("chmod", name); err != nil {
+		return err
+	}
+	return os.Chmod(name, mode)
+}
+
+func rename(oldpath, newpath string) error {
+	if err := checkWritable("rename", oldpath); err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func link(oldname, newname string) error {
+	if err := checkWritable("link", newname); err != nil {
+		return err
+	}
+	return os.Link(oldname, newname)
+}
+
+func remove(name string) error {
+	if err := checkWritable("remove", name); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+func removeAll(path string) error {
+	if err := checkWritable("remove", path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
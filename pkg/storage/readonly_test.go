@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	SetReadOnly(true)
+	t.Cleanup(func() { SetReadOnly(false) })
+
+	for name, write := range map[string]func() error{
+		"SaveJSON": func() error { return SaveJSON(filepath.Join(claudeDir, "config.json"), &Config{}) },
+		"SaveRequest": func() error {
+			return SaveRequest(claudeDir, "20260101_120000", nil)
+		},
+		"AppendAuditLog": func() error { return AppendAuditLog(claudeDir, AuditLogEntry{Tool: "read_file"}) },
+		"WriteFile":      func() error { return WriteFile(filepath.Join(claudeDir, "x.md"), nil, 0o644) },
+		"MkdirAll":       func() error { return MkdirAll(filepath.Join(claudeDir, "cron"), 0o755) },
+		"Create": func() error {
+			_, err := Create(filepath.Join(claudeDir, "out.txt"))
+			return err
+		},
+		"Rename":    func() error { return Rename(claudeDir, claudeDir+".old") },
+		"Remove":    func() error { return Remove(claudeDir) },
+		"RemoveAll": func() error { return RemoveAll(claudeDir) },
+		"AcquireLock": func() error {
+			_, err := AcquireLock(claudeDir, 0)
+			return err
+		},
+	} {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: err = %v, want ErrReadOnly", name, err)
+		}
+	}
+	if _, err := os.Stat(claudeDir); !os.IsNotExist(err) {
+		t.Errorf("read-only writes created %s", claudeDir)
+	}
+
+	SetReadOnly(false)
+	if err := SaveJSON(filepath.Join(t.TempDir(), "config.json"), &Config{}); err != nil {
+		t.Errorf("after read-only mode: %v", err)
+	}
+}
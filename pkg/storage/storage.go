@@ -1,10 +1,13 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -47,6 +50,30 @@ type ProviderStats struct {
 	TokensOutput int `json:"tokens_output"`
 }
 
+// RoutingOutcome tracks how the local model did on the tasks of one
+// complexity, for adaptive routing
+type RoutingOutcome struct {
+	Runs       int `json:"runs"`
+	Fallbacks  int `json:"fallbacks"`  // runs handed over to another model
+	Iterations int `json:"iterations"` // summed over the runs
+}
+
+// FallbackRate returns the share of runs that fell back (0.0 to 1.0)
+func (o RoutingOutcome) FallbackRate() float64 {
+	if o.Runs == 0 {
+		return 0
+	}
+	return float64(o.Fallbacks) / float64(o.Runs)
+}
+
+// AvgIterations returns the average iterations of a run
+func (o RoutingOutcome) AvgIterations() float64 {
+	if o.Runs == 0 {
+		return 0
+	}
+	return float64(o.Iterations) / float64(o.Runs)
+}
+
 // Config stores aggregate stats and settings
 type Config struct {
 	Model        string `json:"model"`
@@ -58,6 +85,67 @@ type Config struct {
 	// Provider usage tracking for smart routing
 	ClaudeStats ProviderStats `json:"claude_stats"`
 	OllamaStats ProviderStats `json:"ollama_stats"`
+	// Local model outcomes by task complexity, learned by the router
+	RoutingOutcomes map[string]RoutingOutcome `json:"routing_outcomes,omitempty"`
+	// Encryption at rest (nil = disabled)
+	Encryption *EncryptionConfig `json:"encryption,omitempty"`
+	// Named flag bundles selected with --profile
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// External tool executables offered to the LLM
+	ToolPlugins []ToolPlugin `json:"tool_plugins,omitempty"`
+	// Lineage: the session this one was forked from, and its forks
+	ForkedFrom *Fork  `json:"forked_from,omitempty"`
+	Forks      []Fork `json:"forks,omitempty"`
+	// What happens when a model call fails; flags override it
+	Fallback *FallbackConfig `json:"fallback,omitempty"`
+	// Soft spend limits; flags override them
+	Budget *BudgetConfig `json:"budget,omitempty"`
+	// Who runs the turns, for usage exports; CLAUDE_USER overrides it
+	User string `json:"user,omitempty"`
+	// --validate commands by file extension (".go"), over the defaults
+	Validators map[string]string `json:"validators,omitempty"`
+}
+
+// BudgetConfig is the spend alert policy of a project
+type BudgetConfig struct {
+	Daily  float64 `json:"daily,omitempty"` // dollars per day, 0 = none
+	Alerts []int   `json:"alerts"`          // percentages that warn, nil = default, [] = none
+}
+
+// FallbackConfig is the fallback policy of a project
+type FallbackConfig struct {
+	Chain   []string `json:"chain,omitempty"`   // models tried in order after the primary
+	On      []string `json:"on,omitempty"`      // error classes that fall back, empty = all
+	Retries int      `json:"retries,omitempty"` // retries of a model before the next
+}
+
+// ToolPlugin is an external tool executable speaking JSON over stdio
+type ToolPlugin struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Profile bundles settings selected with --profile. Unset fields leave the
+// flag default alone; pointers distinguish "unset" from meaningful zeros
+// (max_cost 0 = unlimited, tool "" = dry-run).
+type Profile struct {
+	Model          string   `json:"model,omitempty"`
+	Provider       string   `json:"provider,omitempty"` // claude, vertex, bedrock, or ollama (local only)
+	SystemPrompt   string   `json:"system_prompt,omitempty"`
+	MaxTokens      int      `json:"max_tokens,omitempty"`
+	MaxCost        *float64 `json:"max_cost,omitempty"`
+	MaxIterations  *int     `json:"max_iterations,omitempty"`
+	MaxClaudeRatio *float64 `json:"max_claude_ratio,omitempty"`
+	Tool           *string  `json:"tool,omitempty"`
+	OllamaURL      string   `json:"ollama_url,omitempty"`
+	Verify         string   `json:"verify,omitempty"`
+}
+
+// EncryptionConfig controls encryption at rest of conversation files
+type EncryptionConfig struct {
+	Enabled   bool   `json:"enabled"`
+	KeySource string `json:"key_source,omitempty"` // passphrase (default), env, keyring
+	Salt      string `json:"salt,omitempty"`       // base64 salt for derived keys
 }
 
 // ModelsCache stores cached model listings from providers
@@ -86,7 +174,7 @@ func CurrentTimestamp() string {
 
 // LoadRequest loads a request from the given path
 func LoadRequest(path string) (*Request, error) {
-	data, err := os.ReadFile(path)
+	data, err := readSealedFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read request: %w", err)
 	}
@@ -106,62 +194,90 @@ func SaveRequest(claudeDir, timestamp string, messages []MessageContent) error {
 		Messages:  messages,
 	}
 	path := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", timestamp))
-	return SaveJSON(path, req)
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal JSON: %w", err)
+	}
+	return writeSealedFile(path, fileRedactor.Bytes(data))
 }
 
-// SaveResponse saves the raw API response to disk
+// SaveResponse saves the raw API response to disk and adds the completed
+// turn to the history index
 func SaveResponse(claudeDir, timestamp string, respBody []byte) error {
 	path := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", timestamp))
-	return os.WriteFile(path, respBody, 0o644)
+	if err := writeSealedFile(path, fileRedactor.Bytes(respBody)); err != nil {
+		return err
+	}
+	// The index is a cache: a turn missing from it is parsed and added by
+	// the next LoadConversationHistory
+	indexTurn(claudeDir, timestamp)
+	return nil
+}
+
+// LoadResponses loads the array of API responses saved for timestamp
+func LoadResponses(claudeDir, timestamp string) ([]APIResponse, error) {
+	path := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", timestamp))
+	data, err := readSealedFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var responses []APIResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return responses, nil
 }
 
-// LoadConversationHistory reconstructs conversation from request/response pairs
+// LoadConversationHistory reconstructs conversation from request/response
+// pairs, using the history index for the turns it already has. A turn that
+// can't be read is an error rather than a silent gap in the conversation.
 func LoadConversationHistory(claudeDir string) ([]MessageContent, error) {
+	return loadConversationHistory(claudeDir, "")
+}
+
+// LoadConversationHistoryBefore reconstructs the conversation of the turns
+// saved before turn ts
+func LoadConversationHistoryBefore(claudeDir, ts string) ([]MessageContent, error) {
+	return loadConversationHistory(claudeDir, ts)
+}
+
+// loadConversationHistory reconstructs the conversation of the turns before
+// before, or of all turns when it is empty
+func loadConversationHistory(claudeDir, before string) ([]MessageContent, error) {
 	pairs, err := ListRequestResponsePairs(claudeDir)
 	if err != nil {
 		return nil, err
 	}
+	if before != "" {
+		pairs = slices.DeleteFunc(pairs, func(ts string) bool { return ts >= before })
+	}
 
+	index := loadIndex(claudeDir)
 	var messages []MessageContent
+	var missing []*IndexEntry
 
 	for _, ts := range pairs {
-		// Load request - extract the user message (always last in request)
-		reqPath := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json", ts))
-		reqData, err := os.ReadFile(reqPath)
-		if err != nil {
-			continue
+		entry, ok := index[ts]
+		if !ok {
+			if entry, err = turnEntry(claudeDir, ts); err != nil {
+				return nil, fmt.Errorf("turn %s: %w (inspect with --fsck, "+
+					"set it aside with --fsck --quarantine)", ts, err)
+			}
+			missing = append(missing, entry)
 		}
-
-		var req Request
-		if err := json.Unmarshal(reqData, &req); err != nil {
-			continue
+		if entry.User != nil {
+			messages = append(messages, *entry.User)
 		}
-
-		// Add user message from request (last message is always user)
-		if len(req.Messages) > 0 {
-			messages = append(messages, req.Messages[len(req.Messages)-1])
-		}
-
-		// Load response - extract assistant content
-		respPath := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json", ts))
-		respData, err := os.ReadFile(respPath)
-		if err != nil {
-			continue
-		}
-
-		var responses []APIResponse
-		if err := json.Unmarshal(respData, &responses); err != nil {
-			continue
+		if entry.Assistant != nil {
+			messages = append(messages, *entry.Assistant)
 		}
+	}
 
-		// Add assistant response (use last response, which has final text)
-		if len(responses) > 0 {
-			lastResp := responses[len(responses)-1]
-			messages = append(messages, MessageContent{
-				Role:    "assistant",
-				Content: lastResp.Content,
-			})
-		}
+	// Catch the index up so the next load doesn't parse these again
+	if len(missing) > 0 {
+		appendIndex(claudeDir, missing)
 	}
 
 	return messages, nil
@@ -238,6 +354,37 @@ func SaveModelsCache(claudeDir string, cache *ModelsCache) error {
 	return SaveJSON(path, cache)
 }
 
+// SaveComparison saves a --compare-models run as
+// compare/compare_TIMESTAMP.json and returns its path. The answers are
+// conversation content, redacted and encrypted like turns.
+func SaveComparison(claudeDir, timestamp string, v interface{}) (string, error) {
+	dir := filepath.Join(claudeDir, "compare")
+	if err := mkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create compare directory: %w", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal comparison: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("compare_%s.json", timestamp))
+	if err := writeSealedFile(path, fileRedactor.Bytes(data)); err != nil {
+		return "", fmt.Errorf("save comparison: %w", err)
+	}
+	return path, nil
+}
+
+// LoadComparison decodes the comparison saved at path into v
+func LoadComparison(path string, v interface{}) error {
+	data, err := readSealedFile(path)
+	if err != nil {
+		return fmt.Errorf("read comparison: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse comparison: %w", err)
+	}
+	return nil
+}
+
 // UpdateProviderStats updates usage statistics for a provider
 func UpdateProviderStats(cfg *Config, provider string, inputTokens, outputTokens int) {
 	switch provider {
@@ -255,6 +402,20 @@ func UpdateProviderStats(cfg *Config, provider string, inputTokens, outputTokens
 	cfg.TotalOutput += outputTokens
 }
 
+// RecordRoutingOutcome adds a local model run of a task of complexity
+func RecordRoutingOutcome(cfg *Config, complexity string, fellBack bool, iterations int) {
+	if cfg.RoutingOutcomes == nil {
+		cfg.RoutingOutcomes = make(map[string]RoutingOutcome)
+	}
+	o := cfg.RoutingOutcomes[complexity]
+	o.Runs++
+	if fellBack {
+		o.Fallbacks++
+	}
+	o.Iterations += iterations
+	cfg.RoutingOutcomes[complexity] = o
+}
+
 // GetClaudeUsageRatio returns the ratio of Claude requests to total requests (0.0 to 1.0)
 func GetClaudeUsageRatio(cfg *Config) float64 {
 	totalRequests := cfg.ClaudeStats.RequestCount + cfg.OllamaStats.RequestCount
@@ -281,7 +442,7 @@ func CleanupOrphanedDeletingFiles(claudeDir string) error {
 		name := entry.Name()
 		if strings.HasSuffix(name, ".deleting") {
 			path := filepath.Join(claudeDir, name)
-			if err := os.Remove(path); err != nil {
+			if err := remove(path); err != nil {
 				cleanupErrors = append(cleanupErrors, fmt.Sprintf("%s: %v", name, err))
 			}
 		}
@@ -331,15 +492,15 @@ func PruneResponses(claudeDir string, keepLast int, verbose bool) error {
 		respDeleting := respPath + ".deleting"
 
 		// Rename request file
-		if err := os.Rename(reqPath, reqDeleting); err != nil {
+		if err := rename(reqPath, reqDeleting); err != nil {
 			renameErrors = append(renameErrors, fmt.Sprintf("request %s: %v", ts, err))
 			continue
 		}
 
 		// Rename response file - rollback request rename if this fails
-		if err := os.Rename(respPath, respDeleting); err != nil {
+		if err := rename(respPath, respDeleting); err != nil {
 			// Rollback: restore request file
-			os.Rename(reqDeleting, reqPath)
+			rename(reqDeleting, reqPath)
 			renameErrors = append(renameErrors, fmt.Sprintf("response %s: %v", ts, err))
 			continue
 		}
@@ -356,8 +517,8 @@ func PruneResponses(claudeDir string, keepLast int, verbose bool) error {
 		reqDeleting := filepath.Join(claudeDir, fmt.Sprintf("request_%s.json.deleting", ts))
 		respDeleting := filepath.Join(claudeDir, fmt.Sprintf("response_%s.json.deleting", ts))
 
-		reqErr := os.Remove(reqDeleting)
-		respErr := os.Remove(respDeleting)
+		reqErr := remove(reqDeleting)
+		respErr := remove(respDeleting)
 
 		// Track errors but continue - files are already marked for deletion
 		if reqErr != nil {
@@ -366,6 +527,12 @@ func PruneResponses(claudeDir string, keepLast int, verbose bool) error {
 		if respErr != nil {
 			deleteErrors = append(deleteErrors, fmt.Sprintf("response %s: %v", ts, respErr))
 		}
+		if err := removeTurnMeta(claudeDir, ts); err != nil {
+			deleteErrors = append(deleteErrors, err.Error())
+		}
+		if err := removeTranscript(claudeDir, ts); err != nil {
+			deleteErrors = append(deleteErrors, err.Error())
+		}
 
 		// Count as deleted even if Remove failed - files are renamed and invisible to system
 		deletedCount++
@@ -392,14 +559,50 @@ func PruneResponses(claudeDir string, keepLast int, verbose bool) error {
 	return nil
 }
 
+// DropTurns deletes the last n request/response pairs with their metadata,
+// newest first, and returns their timestamps. Backups stay so files the
+// turns changed can still be restored by hand.
+func DropTurns(claudeDir string, n int) ([]string, error) {
+	if err := CleanupOrphanedDeletingFiles(claudeDir); err != nil {
+		return nil, fmt.Errorf("cleanup orphaned files: %w", err)
+	}
+	pairs, err := ListRequestResponsePairs(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for i := len(pairs) - 1; i >= 0 && len(dropped) < n; i-- {
+		if err := removePair(claudeDir, pairs[i]); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, pairs[i])
+	}
+	if len(dropped) > 0 {
+		if err := compactIndex(claudeDir); err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// DropTurn deletes the request/response pair of ts with its metadata,
+// keeping its backups like DropTurns
+func DropTurn(claudeDir, ts string) error {
+	if err := removePair(claudeDir, ts); err != nil {
+		return err
+	}
+	return compactIndex(claudeDir)
+}
+
 // AppendAuditLog appends a tool execution entry to the audit log
 func AppendAuditLog(claudeDir string, entry AuditLogEntry) error {
-	if err := os.MkdirAll(claudeDir, 0o755); err != nil {
+	if err := mkdirAll(claudeDir, 0o755); err != nil {
 		return fmt.Errorf("ensure .claude dir: %w", err)
 	}
 
 	logPath := filepath.Join(claudeDir, "tool_log.jsonl")
-	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	f, err := openFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return fmt.Errorf("open audit log: %w", err)
 	}
@@ -409,6 +612,10 @@ func AppendAuditLog(claudeDir string, entry AuditLogEntry) error {
 	if err != nil {
 		return fmt.Errorf("marshal audit entry: %w", err)
 	}
+	data, err = sealAuditLine(fileRedactor.Bytes(data))
+	if err != nil {
+		return fmt.Errorf("encrypt audit entry: %w", err)
+	}
 
 	if _, err := f.Write(append(data, '\n')); err != nil {
 		return fmt.Errorf("write audit log: %w", err)
@@ -417,6 +624,89 @@ func AppendAuditLog(claudeDir string, entry AuditLogEntry) error {
 	return f.Sync()
 }
 
+// LoadAuditLog reads the audit log. Lines that can't be read are skipped;
+// a missing log has no entries.
+func LoadAuditLog(claudeDir string) ([]AuditLogEntry, error) {
+	f, err := os.Open(filepath.Join(claudeDir, "tool_log.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []AuditLogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line, err := UnsealAuditLine(bytes.TrimSpace(scanner.Bytes()))
+		if err != nil || len(line) == 0 {
+			continue
+		}
+		var entry AuditLogEntry
+		if json.Unmarshal(line, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// SaveBackup stores the pre-write contents of a file under
+// .claude/backups/<conversationID>/ so an interrupted or rolled back
+// multi-file apply can always be recovered by hand
+func SaveBackup(claudeDir, conversationID, path string, data []byte) (string, error) {
+	backupDir := filepath.Join(claudeDir, "backups", conversationID)
+	if err := mkdirAll(backupDir, 0o755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	// Flatten the path so backups of a/b.go and c/b.go don't collide
+	name := strings.ReplaceAll(filepath.Clean(path), string(filepath.Separator), "__")
+	backupPath := filepath.Join(backupDir, name)
+	if err := writeSealedFile(backupPath, data); err != nil {
+		return "", fmt.Errorf("write backup: %w", err)
+	}
+
+	return backupPath, nil
+}
+
+// SaveToolResult stores the full output of a tool call whose result was
+// compressed before being sent to the LLM (.claude/results/<id>.txt)
+func SaveToolResult(claudeDir, toolUseID, content string) error {
+	path, err := toolResultPath(claudeDir, toolUseID)
+	if err != nil {
+		return err
+	}
+	if err := mkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create results dir: %w", err)
+	}
+	return writeSealedFile(path, fileRedactor.Bytes([]byte(content)))
+}
+
+// LoadToolResult returns the output saved by SaveToolResult
+func LoadToolResult(claudeDir, toolUseID string) (string, error) {
+	path, err := toolResultPath(claudeDir, toolUseID)
+	if err != nil {
+		return "", err
+	}
+	data, err := readSealedFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toolResultPath(claudeDir, toolUseID string) (string, error) {
+	if toolUseID == "" || strings.ContainsAny(toolUseID, `/\`) || strings.Contains(toolUseID, "..") {
+		return "", fmt.Errorf("invalid tool result id %q", toolUseID)
+	}
+	return filepath.Join(claudeDir, "results", toolUseID+".txt"), nil
+}
+
 // SaveJSON is a helper to atomically write JSON to disk
 func SaveJSON(path string, v interface{}) error {
 	data, err := json.MarshalIndent(v, "", "  ")
@@ -425,12 +715,12 @@ func SaveJSON(path string, v interface{}) error {
 	}
 
 	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+	if err := writeFile(tmpPath, data, 0o644); err != nil {
 		return fmt.Errorf("write temp file: %w", err)
 	}
 
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath)
+	if err := rename(tmpPath, path); err != nil {
+		remove(tmpPath)
 		return fmt.Errorf("atomic rename: %w", err)
 	}
 
@@ -194,6 +194,58 @@ func TestPruneResponses(t *testing.T) {
 	}
 }
 
+// TestDropTurns tests removal of the newest pairs
+func TestDropTurns(t *testing.T) {
+	tmpDir := t.TempDir()
+	timestamps := []string{"20260105_100000", "20260105_110000", "20260105_120000"}
+	for _, ts := range timestamps {
+		msg := MessageContent{Role: "user", Content: []ContentBlock{{Type: "text", Text: ts}}}
+		SaveRequest(tmpDir, ts, []MessageContent{msg})
+		SaveResponse(tmpDir, ts, []byte("[]"))
+		SaveTurnMeta(tmpDir, ts, &TurnMeta{Model: "m"})
+		SaveTranscript(tmpDir, ts, []MessageContent{msg})
+	}
+	backup := filepath.Join(tmpDir, "backups", timestamps[2], "a.go")
+	os.MkdirAll(filepath.Dir(backup), 0o755)
+	os.WriteFile(backup, []byte("package a\n"), 0o644)
+
+	dropped, err := DropTurns(tmpDir, 2)
+	if err != nil {
+		t.Fatalf("DropTurns failed: %v", err)
+	}
+	if len(dropped) != 2 || dropped[0] != timestamps[2] || dropped[1] != timestamps[1] {
+		t.Errorf("dropped %v, want the newest two", dropped)
+	}
+	pairs, _ := ListRequestResponsePairs(tmpDir)
+	if len(pairs) != 1 || pairs[0] != timestamps[0] {
+		t.Errorf("pairs after drop = %v", pairs)
+	}
+	if meta, _ := LoadTurnMeta(tmpDir, timestamps[1]); meta != nil {
+		t.Errorf("metadata of a dropped turn remains")
+	}
+	if tr, _ := LoadTranscript(tmpDir, timestamps[1]); tr != nil {
+		t.Errorf("transcript of a dropped turn remains")
+	}
+	if tr, err := LoadTranscript(tmpDir, timestamps[0]); err != nil || tr == nil ||
+		tr.Messages[0].Content[0].Text != timestamps[0] {
+		t.Errorf("transcript of a kept turn = %+v, %v", tr, err)
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Errorf("backup of a dropped turn was deleted: %v", err)
+	}
+
+	// The index no longer serves the dropped turns
+	messages, err := LoadConversationHistory(tmpDir)
+	if err != nil || len(messages) != 1 || messages[0].Content[0].Text != timestamps[0] {
+		t.Errorf("history after drop = %+v, %v", messages, err)
+	}
+
+	// Dropping more than there are drops all
+	if dropped, err := DropTurns(tmpDir, 5); err != nil || len(dropped) != 1 {
+		t.Errorf("DropTurns(5) = %v, %v", dropped, err)
+	}
+}
+
 // TestPruneResponsesVerbose tests verbose output mode
 func TestPruneResponsesVerbose(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -914,3 +966,27 @@ func TestPruneResponsesAtomicRollback(t *testing.T) {
 		t.Error(".deleting file should not exist after rollback")
 	}
 }
+
+func TestToolResults(t *testing.T) {
+	claudeDir := t.TempDir()
+
+	if err := SaveToolResult(claudeDir, "toolu_1", "full output"); err != nil {
+		t.Fatalf("SaveToolResult: %v", err)
+	}
+	got, err := LoadToolResult(claudeDir, "toolu_1")
+	if err != nil {
+		t.Fatalf("LoadToolResult: %v", err)
+	}
+	if got != "full output" {
+		t.Errorf("LoadToolResult = %q", got)
+	}
+
+	if _, err := LoadToolResult(claudeDir, "toolu_missing"); err == nil {
+		t.Error("expected error for missing result")
+	}
+	for _, id := range []string{"", "../config", "a/b"} {
+		if err := SaveToolResult(claudeDir, id, "x"); err == nil {
+			t.Errorf("SaveToolResult(%q) should fail", id)
+		}
+	}
+}
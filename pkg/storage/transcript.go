@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// transcript.go - Per-turn transcripts (.claude/transcript_<ts>.json)
+//
+// The response file holds what the model said, tool_use blocks included,
+// but the tool results are only sent with the next call and end up in the
+// request of the next turn, if there is one. The transcript keeps the turn
+// as the model saw it: the prompt, every assistant message and the tool
+// results and feedback in between, in order. It is written just before the
+// response; turns saved before transcripts existed, or finalized by
+// --recover, have none.
+
+// Transcript is the interleaved messages of one turn
+type Transcript struct {
+	Timestamp string           `json:"timestamp"`
+	Messages  []MessageContent `json:"messages"` // from the prompt on
+}
+
+func transcriptPath(claudeDir, ts string) string {
+	return filepath.Join(claudeDir, fmt.Sprintf("transcript_%s.json", ts))
+}
+
+// SaveTranscript saves the messages of turn ts
+func SaveTranscript(claudeDir, ts string, messages []MessageContent) error {
+	data, err := json.MarshalIndent(Transcript{Timestamp: ts, Messages: messages}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal JSON: %w", err)
+	}
+	return writeSealedFile(transcriptPath(claudeDir, ts), fileRedactor.Bytes(data))
+}
+
+// LoadTranscript loads the transcript of turn ts. Turns without one return
+// nil and no error.
+func LoadTranscript(claudeDir, ts string) (*Transcript, error) {
+	data, err := readSealedFile(transcriptPath(claudeDir, ts))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	var t Transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("unmarshal transcript: %w", err)
+	}
+	return &t, nil
+}
+
+// removeTranscript deletes the transcript of turn ts, if any
+func removeTranscript(claudeDir, ts string) error {
+	if err := remove(transcriptPath(claudeDir, ts)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove transcript of %s: %w", ts, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package telemetry exports OpenTelemetry traces and metrics for agentic
+// runs.
+//
+// Nothing is exported unless an OTLP endpoint is configured through the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT (or the per-signal
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT / _METRICS_ENDPOINT) variables. Until
+// Setup installs real providers the global no-op providers make every
+// helper here free to call.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans and metrics from this module
+const instrumentationName = "github.com/marcopeereboom/go-claude"
+
+// serviceName is the default service.name (OTEL_SERVICE_NAME overrides it)
+const serviceName = "go-claude"
+
+// Enabled reports whether an OTLP endpoint is configured
+func Enabled() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	for _, env := range []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT",
+	} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup installs OTLP/HTTP trace and metric providers when Enabled. The
+// returned shutdown flushes pending data and must be called before exit.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("telemetry resource: %w", err)
+	}
+	// Environment last so OTEL_SERVICE_NAME/OTEL_RESOURCE_ATTRIBUTES win
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, fmt.Errorf("telemetry resource: %w", err)
+	}
+
+	traceExp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp trace exporter: %w", err)
+	}
+	metricExp, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp metric exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExp),
+		sdktrace.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp)),
+		sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
+}
+
+// Start starts a span named name
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// instruments are created on first use from the global meter provider
+type instruments struct {
+	llmRequests   metric.Int64Counter
+	llmTokens     metric.Int64Counter
+	llmCost       metric.Float64Counter
+	llmDuration   metric.Float64Histogram
+	llmRetries    metric.Int64Counter
+	iterations    metric.Int64Counter
+	toolCalls     metric.Int64Counter
+	toolDurations metric.Float64Histogram
+}
+
+var (
+	instOnce sync.Once
+	inst     instruments
+)
+
+func meters() *instruments {
+	instOnce.Do(func() {
+		m := otel.Meter(instrumentationName)
+		// Errors only occur for invalid names; fall back to no-ops
+		inst.llmRequests, _ = m.Int64Counter("claude.llm.requests",
+			metric.WithDescription("LLM API calls"))
+		inst.llmTokens, _ = m.Int64Counter("claude.llm.tokens",
+			metric.WithDescription("Tokens used"), metric.WithUnit("{token}"))
+		inst.llmCost, _ = m.Float64Counter("claude.llm.cost",
+			metric.WithDescription("Estimated cost"), metric.WithUnit("USD"))
+		inst.llmDuration, _ = m.Float64Histogram("claude.llm.duration",
+			metric.WithDescription("LLM call latency"), metric.WithUnit("s"))
+		inst.llmRetries, _ = m.Int64Counter("claude.llm.retries",
+			metric.WithDescription("Fallbacks, continuations and resumed turns"))
+		inst.iterations, _ = m.Int64Counter("claude.agent.iterations",
+			metric.WithDescription("Agentic loop iterations"))
+		inst.toolCalls, _ = m.Int64Counter("claude.tool.executions",
+			metric.WithDescription("Tool executions"))
+		inst.toolDurations, _ = m.Float64Histogram("claude.tool.duration",
+			metric.WithDescription("Tool execution latency"), metric.WithUnit("s"))
+	})
+	return &inst
+}
+
+// LLMCall describes one finished LLM request
+type LLMCall struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	Duration     time.Duration
+	Err          error
+}
+
+// RecordLLMCall records request count, tokens, cost and latency
+func RecordLLMCall(ctx context.Context, c LLMCall) {
+	m := meters()
+	attrs := metric.WithAttributes(
+		attribute.String("provider", c.Provider),
+		attribute.String("model", c.Model),
+		attribute.Bool("error", c.Err != nil))
+	m.llmRequests.Add(ctx, 1, attrs)
+	m.llmDuration.Record(ctx, c.Duration.Seconds(), attrs)
+	if c.Err != nil {
+		return
+	}
+
+	base := []attribute.KeyValue{
+		attribute.String("provider", c.Provider),
+		attribute.String("model", c.Model),
+	}
+	m.llmTokens.Add(ctx, int64(c.InputTokens),
+		metric.WithAttributes(append(base, attribute.String("type", "input"))...))
+	m.llmTokens.Add(ctx, int64(c.OutputTokens),
+		metric.WithAttributes(append(base, attribute.String("type", "output"))...))
+	m.llmCost.Add(ctx, c.Cost, metric.WithAttributes(base...))
+}
+
+// RecordRetry counts a retried or resumed LLM call (reason: fallback,
+// max_tokens, pause_turn)
+func RecordRetry(ctx context.Context, reason string) {
+	meters().llmRetries.Add(ctx, 1,
+		metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// RecordIteration counts one agentic loop iteration
+func RecordIteration(ctx context.Context, stopReason string) {
+	meters().iterations.Add(ctx, 1,
+		metric.WithAttributes(attribute.String("stop_reason", stopReason)))
+}
+
+// RecordTool records one tool execution
+func RecordTool(ctx context.Context, tool string, d time.Duration, success, dryRun bool) {
+	m := meters()
+	attrs := metric.WithAttributes(
+		attribute.String("tool", tool),
+		attribute.Bool("success", success),
+		attribute.Bool("dry_run", dryRun))
+	m.toolCalls.Add(ctx, 1, attrs)
+	m.toolDurations.Record(ctx, d.Seconds(), attrs)
+}
@@ -0,0 +1,150 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/marcopeereboom/go-claude/pkg/telemetry"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{"unset", nil, false},
+		{"endpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"}, true},
+		{"traces only", map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4318/v1/traces"}, true},
+		{"metrics only", map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://localhost:4318/v1/metrics"}, true},
+		{"sdk disabled", map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+			"OTEL_SDK_DISABLED":           "true",
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT",
+				"OTEL_SDK_DISABLED",
+			} {
+				t.Setenv(env, tt.env[env])
+			}
+			if got := telemetry.Enabled(); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+
+	shutdown, err := telemetry.Setup(context.Background())
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	ctx, parent := telemetry.Start(context.Background(), "agent.conversation")
+	_, child := telemetry.Start(ctx, "llm.generate")
+	telemetry.End(child, errors.New("boom"))
+	telemetry.End(parent, nil)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name() != "llm.generate" || spans[0].Status().Code != codes.Error {
+		t.Errorf("child span = %q status %v, want failed llm.generate",
+			spans[0].Name(), spans[0].Status().Code)
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("llm.generate is not a child of agent.conversation")
+	}
+	if spans[1].Status().Code == codes.Error {
+		t.Error("parent span marked failed")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	// Must run before any other test touches the instruments
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	ctx := context.Background()
+	telemetry.RecordLLMCall(ctx, telemetry.LLMCall{
+		Provider:     "claude",
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  100,
+		OutputTokens: 20,
+		Cost:         0.01,
+		Duration:     time.Second,
+	})
+	telemetry.RecordRetry(ctx, "max_tokens")
+	telemetry.RecordIteration(ctx, "end_turn")
+	telemetry.RecordTool(ctx, "read_file", time.Millisecond, true, false)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+	for _, name := range []string{
+		"claude.llm.requests",
+		"claude.llm.tokens",
+		"claude.llm.cost",
+		"claude.llm.duration",
+		"claude.llm.retries",
+		"claude.agent.iterations",
+		"claude.tool.executions",
+		"claude.tool.duration",
+	} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("metric %s not recorded", name)
+		}
+	}
+
+	tokens, ok := got["claude.llm.tokens"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("claude.llm.tokens has type %T", got["claude.llm.tokens"])
+	}
+	var total int64
+	for _, dp := range tokens.DataPoints {
+		total += dp.Value
+	}
+	if total != 120 {
+		t.Errorf("tokens = %d, want 120", total)
+	}
+}